@@ -14,6 +14,8 @@ import (
 
 type Client struct {
 	Options []option.RequestOption
+	// Session is the member the client acts as, its token goes with every API request
+	Session *MemberSession
 	Room    *RoomService
 	Message *MessageService
 	Health  *HealthService
@@ -47,10 +49,16 @@ func NewClient(opts ...option.RequestOption) *Client {
 	if u, ok := os.LookupEnv("VISPER_AI_BASE_URL"); ok {
 		aiBaseURL = u
 	}
-	aiOpts := append(opts, option.WithBaseURL(aiBaseURL))
+	aiOpts := slices.Concat(opts, []option.RequestOption{option.WithBaseURL(aiBaseURL)})
+
+	// The AI service verifies the same member tokens
+	session := NewMemberSession("")
+	opts = slices.Concat(opts, []option.RequestOption{session.Option()})
+	aiOpts = slices.Concat(aiOpts, []option.RequestOption{session.Option()})
 
 	r := &Client{
 		Options: opts,
+		Session: session,
 		Room:    NewRoomService(opts...),
 		Message: NewMessageService(opts...),
 		Health:  NewHealthService(opts...),
@@ -32,12 +32,16 @@ type FileResponse struct {
 	Filename  string    `json:"filename"`
 	MimeType  string    `json:"mime_type"`
 	Size      int64     `json:"size"`
-	URL       string    `json:"url"`
+	URL       string    `json:"url"` // Signed and short lived, list the room files again for a fresh one
 	CreatedAt time.Time `json:"created_at"`
 	Uploader  struct {
 		ID       string `json:"id"`
 		Username string `json:"username"`
 	} `json:"uploader"`
+	// Variants maps "thumbnail" and "medium" to smaller copies, use them for previews when present
+	Variants map[string]string `json:"variants,omitempty"`
+	// ScanStatus is "pending" until the malware scan is done, "infected" files have no URL
+	ScanStatus string `json:"scanStatus,omitempty"`
 }
 
 func (r *FileResponse) UnmarshalJSON(data []byte) error {
@@ -46,7 +50,9 @@ func (r *FileResponse) UnmarshalJSON(data []byte) error {
 	return json.Unmarshal(data, (*plain)(r))
 }
 
-func (s *FileService) Upload(ctx context.Context, roomID, filePath, userID string, opts ...option.RequestOption) (*FileResponse, error) {
+// Upload sends the file as the member of the client's session, or the MemberTokenHeader
+// given in opts
+func (s *FileService) Upload(ctx context.Context, roomID, filePath string, opts ...option.RequestOption) (*FileResponse, error) {
 	opts = slices.Concat(s.Options, opts)
 
 	// Build the config to get base URL and HTTP client
@@ -80,8 +86,8 @@ func (s *FileService) Upload(ctx context.Context, roomID, filePath, userID strin
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	if userID != "" {
-		req.Header.Set("X-User-ID", userID)
+	if token := cfg.Request.Header.Get(MemberTokenHeader); token != "" {
+		req.Header.Set(MemberTokenHeader, token)
 	}
 
 	resp, err := cfg.HTTPClient.Do(req)
@@ -3,8 +3,10 @@ module github.com/hilthontt/visper/api-sdk
 go 1.25.7
 
 require (
+	github.com/gorilla/websocket v1.5.3
 	github.com/tidwall/gjson v1.18.0
 	github.com/tidwall/sjson v1.2.5
+	golang.org/x/crypto v0.47.0
 )
 
 require (
@@ -0,0 +1,9 @@
+package apisdk
+
+import "crypto/rand"
+
+// newIdempotencyKey is generated once per call, the SDK's own retries then share it.
+// Pass option.WithIdempotencyKey to keep the same key across calls of your own.
+func newIdempotencyKey() string {
+	return rand.Text()
+}
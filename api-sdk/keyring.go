@@ -0,0 +1,80 @@
+package apisdk
+
+import (
+	"errors"
+	"sync"
+)
+
+var errNoRoomKey = errors.New("no room key for this message")
+
+// roomKeyring holds the room key and the ones it replaced. The room socket refreshes it
+// on rotation while requests read it, hence the lock.
+type roomKeyring struct {
+	mu      sync.RWMutex
+	current string
+	version int
+	retired map[int]string
+}
+
+func (k *roomKeyring) set(keys *RoomKeysResponse) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.current = keys.Key
+	k.version = keys.KeyVersion
+	k.retired = make(map[int]string, len(keys.RetiredKeys))
+	for _, retired := range keys.RetiredKeys {
+		k.retired[retired.KeyVersion] = retired.Key
+	}
+}
+
+func (k *roomKeyring) setKey(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.current = key
+	k.version = 0
+	k.retired = nil
+}
+
+func (k *roomKeyring) enabled() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current != ""
+}
+
+// seal encrypts with the current key and reports which version that is
+func (k *roomKeyring) seal(plaintext string) (string, int, error) {
+	k.mu.RLock()
+	key, version := k.current, k.version
+	k.mu.RUnlock()
+
+	ciphertext, err := EncryptWithKeyB64(plaintext, key)
+	return ciphertext, version, err
+}
+
+// open decrypts with the key the message names. Messages without a version, from
+// older servers or edits, are tried against every key from the newest down.
+func (k *roomKeyring) open(ciphertext string, version int) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if version != 0 {
+		if version == k.version {
+			return DecryptWithKeyB64(ciphertext, k.current)
+		}
+		if key, ok := k.retired[version]; ok {
+			return DecryptWithKeyB64(ciphertext, key)
+		}
+	}
+
+	if plaintext, err := DecryptWithKeyB64(ciphertext, k.current); err == nil {
+		return plaintext, nil
+	}
+	for _, key := range k.retired {
+		if plaintext, err := DecryptWithKeyB64(ciphertext, key); err == nil {
+			return plaintext, nil
+		}
+	}
+	return "", errNoRoomKey
+}
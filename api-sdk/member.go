@@ -0,0 +1,65 @@
+package apisdk
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/hilthontt/visper/api-sdk/internal/requestconfig"
+	"github.com/hilthontt/visper/api-sdk/option"
+)
+
+// MemberTokenHeader carries the signed token the API knows a member by, it sends a new one
+// back in the same header whenever it issues one
+const MemberTokenHeader = "X-Member-Token"
+
+// MemberSession holds a client's member token. Every request of the client sends it and a
+// token the API replies with replaces it, so the client stays the same member without
+// keeping cookies.
+type MemberSession struct {
+	mu    sync.RWMutex
+	token string
+}
+
+// NewMemberSession starts a session with a token saved earlier, an empty one makes the API
+// issue a new member
+func NewMemberSession(token string) *MemberSession {
+	return &MemberSession{token: token}
+}
+
+// Token is the current member token, empty until the API issued one
+func (s *MemberSession) Token() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.token
+}
+
+// keep takes the token the API sent back, if it sent one
+func (s *MemberSession) keep(header http.Header) {
+	token := header.Get(MemberTokenHeader)
+	if token == "" {
+		return
+	}
+	s.mu.Lock()
+	s.token = token
+	s.mu.Unlock()
+}
+
+// Option sends the session's token with a request and keeps the one the response carries.
+// The header is set when the request is built, so requests the SDK sends itself, like file
+// uploads and WebSocket handshakes, carry it too.
+func (s *MemberSession) Option() option.RequestOption {
+	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
+		if token := s.Token(); token != "" {
+			if err := r.Apply(option.WithHeader(MemberTokenHeader, token)); err != nil {
+				return err
+			}
+		}
+		return r.Apply(option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			resp, err := next(req)
+			if resp != nil {
+				s.keep(resp.Header)
+			}
+			return resp, err
+		}))
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/hilthontt/visper/api-sdk/internal/apijson"
@@ -14,8 +15,8 @@ import (
 )
 
 type MessageService struct {
-	Options       []option.RequestOption
-	encryptionKey string // Room encryption key (set when joining room)
+	Options []option.RequestOption
+	keys    roomKeyring // Room keys, set after joining with SetRoomKeys
 }
 
 func NewMessageService(opts ...option.RequestOption) *MessageService {
@@ -23,26 +24,40 @@ func NewMessageService(opts ...option.RequestOption) *MessageService {
 	return m
 }
 
-// SetEncryptionKey sets the room's encryption key for automatic encrypt/decrypt
+// SetEncryptionKey sets a single room key for automatic encrypt/decrypt.
+// Prefer SetRoomKeys, it keeps history readable after the key is rotated.
 func (m *MessageService) SetEncryptionKey(key string) {
-	m.encryptionKey = key
+	m.keys.setKey(key)
+}
+
+// SetRoomKeys loads the keys from RoomService.GetKeys, call it again when RoomKeyRotated arrives
+func (m *MessageService) SetRoomKeys(keys *RoomKeysResponse) {
+	m.keys.set(keys)
+}
+
+// Decrypt opens content sealed with the room key of the given version, zero if unknown
+func (m *MessageService) Decrypt(content string, keyVersion int) (string, error) {
+	return m.keys.open(content, keyVersion)
 }
 
 // Send encrypts and sends a message to a room
 func (m *MessageService) Send(ctx context.Context, roomID string, body SendMessageParams, opts ...option.RequestOption) (*MessageResponse, error) {
-	opts = slices.Concat(m.Options, opts)
+	opts = slices.Concat([]option.RequestOption{option.WithIdempotencyKey(newIdempotencyKey())}, m.Options, opts)
 	if roomID == "" {
 		return nil, ErrMissingIDParameter
 	}
 
 	// Encrypt the message content before sending
-	if m.encryptionKey != "" {
-		encryptedContent, err := EncryptWithKeyB64(body.Content, m.encryptionKey)
+	if m.keys.enabled() {
+		body.Mentions = ParseMentions(body.Content)
+
+		encryptedContent, keyVersion, err := m.keys.seal(body.Content)
 		if err != nil {
 			return nil, fmt.Errorf("encryption failed: %w", err)
 		}
 		body.Content = encryptedContent
 		body.Encrypted = true
+		body.KeyVersion = keyVersion
 	}
 
 	path := fmt.Sprintf("api/v1/rooms/%s/messages", roomID)
@@ -53,8 +68,8 @@ func (m *MessageService) Send(ctx context.Context, roomID string, body SendMessa
 	}
 
 	// Decrypt response for convenience
-	if m.encryptionKey != "" && res.Encrypted {
-		decrypted, err := DecryptWithKeyB64(res.Content, m.encryptionKey)
+	if m.keys.enabled() && res.Encrypted {
+		decrypted, err := m.keys.open(res.Content, res.KeyVersion)
 		if err != nil {
 			return res, fmt.Errorf("decryption failed: %w", err)
 		}
@@ -65,6 +80,70 @@ func (m *MessageService) Send(ctx context.Context, roomID string, body SendMessa
 	return res, nil
 }
 
+// Announce encrypts and posts a pinned announcement (only owner can announce)
+func (m *MessageService) Announce(ctx context.Context, roomID string, body AnnouncementParams, opts ...option.RequestOption) (*MessageResponse, error) {
+	opts = slices.Concat(m.Options, opts)
+	if roomID == "" {
+		return nil, ErrMissingIDParameter
+	}
+
+	if m.keys.enabled() {
+		encryptedContent, _, err := m.keys.seal(body.Content)
+		if err != nil {
+			return nil, fmt.Errorf("encryption failed: %w", err)
+		}
+		body.Content = encryptedContent
+		body.Encrypted = true
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/announcements", roomID)
+	res := &MessageResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodPost, path, body, &res, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.keys.enabled() && res.Encrypted {
+		if decrypted, err := m.keys.open(res.Content, res.KeyVersion); err == nil {
+			res.Content = decrypted
+			res.Encrypted = false
+		}
+	}
+
+	return res, nil
+}
+
+// ListAnnouncements returns the room's pinned announcements, oldest first
+func (m *MessageService) ListAnnouncements(ctx context.Context, roomID string, opts ...option.RequestOption) (*AnnouncementsResponse, error) {
+	opts = slices.Concat(m.Options, opts)
+	if roomID == "" {
+		return nil, ErrMissingIDParameter
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/announcements", roomID)
+	res := &AnnouncementsResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodGet, path, nil, &res, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.keys.enabled() {
+		for i := range res.Announcements {
+			if res.Announcements[i].Encrypted {
+				decrypted, err := m.keys.open(res.Announcements[i].Content, res.Announcements[i].KeyVersion)
+				if err != nil {
+					res.Announcements[i].Content = "[Decryption failed]"
+					continue
+				}
+				res.Announcements[i].Content = decrypted
+				res.Announcements[i].Encrypted = false
+			}
+		}
+	}
+
+	return res, nil
+}
+
 // Update encrypts and updates a message
 func (m *MessageService) Update(ctx context.Context, roomID, messageID string, body UpdateMessageParams, opts ...option.RequestOption) (*MessageUpdatedResponse, error) {
 	opts = slices.Concat(m.Options, opts)
@@ -73,8 +152,8 @@ func (m *MessageService) Update(ctx context.Context, roomID, messageID string, b
 	}
 
 	// Encrypt before updating
-	if m.encryptionKey != "" {
-		encryptedContent, err := EncryptWithKeyB64(body.Content, m.encryptionKey)
+	if m.keys.enabled() {
+		encryptedContent, _, err := m.keys.seal(body.Content)
 		if err != nil {
 			return nil, fmt.Errorf("encryption failed: %w", err)
 		}
@@ -90,8 +169,8 @@ func (m *MessageService) Update(ctx context.Context, roomID, messageID string, b
 	}
 
 	// Decrypt response
-	if m.encryptionKey != "" && res.Encrypted {
-		decrypted, err := DecryptWithKeyB64(res.Content, m.encryptionKey)
+	if m.keys.enabled() && res.Encrypted {
+		decrypted, err := m.keys.open(res.Content, res.KeyVersion)
 		if err != nil {
 			return res, fmt.Errorf("decryption failed: %w", err)
 		}
@@ -121,10 +200,10 @@ func (m *MessageService) List(ctx context.Context, roomID string, query MessageL
 	}
 
 	// Decrypt all messages
-	if m.encryptionKey != "" {
+	if m.keys.enabled() {
 		for i := range res.Messages {
 			if res.Messages[i].Encrypted {
-				decrypted, err := DecryptWithKeyB64(res.Messages[i].Content, m.encryptionKey)
+				decrypted, err := m.keys.open(res.Messages[i].Content, res.Messages[i].KeyVersion)
 				if err != nil {
 					// Log but don't fail - show encrypted content
 					res.Messages[i].Content = "[Decryption failed]"
@@ -157,10 +236,10 @@ func (m *MessageService) ListAfter(ctx context.Context, roomID string, query Mes
 		return nil, err
 	}
 
-	if m.encryptionKey != "" {
+	if m.keys.enabled() {
 		for i := range res.Messages {
 			if res.Messages[i].Encrypted {
-				decrypted, err := DecryptWithKeyB64(res.Messages[i].Content, m.encryptionKey)
+				decrypted, err := m.keys.open(res.Messages[i].Content, res.Messages[i].KeyVersion)
 				if err != nil {
 					res.Messages[i].Content = "[Decryption failed]"
 					continue
@@ -203,12 +282,25 @@ func (m *MessageService) Count(ctx context.Context, roomID string, opts ...optio
 type SendMessageParams struct {
 	Content   string `json:"content"`
 	Encrypted bool   `json:"encrypted,omitempty"`
+	// Filled in by Send, a stale version is refused so the sender refetches the keys
+	KeyVersion int `json:"key_version,omitempty"`
+	// Filled in by Send when the content gets encrypted, the server can't parse it then
+	Mentions []string `json:"mentions,omitempty"`
 }
 
 func (r *SendMessageParams) MarshalJSON() ([]byte, error) {
 	return apijson.MarshalRoot(r)
 }
 
+type AnnouncementParams struct {
+	Content   string `json:"content"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+func (r *AnnouncementParams) MarshalJSON() ([]byte, error) {
+	return apijson.MarshalRoot(r)
+}
+
 type UpdateMessageParams struct {
 	Content   string `json:"content"`
 	Encrypted bool   `json:"encrypted,omitempty"`
@@ -219,13 +311,25 @@ func (r *UpdateMessageParams) MarshalJSON() ([]byte, error) {
 }
 
 type MessageResponse struct {
-	ID        string    `json:"id"`
-	RoomID    string    `json:"room_id"`
-	UserID    string    `json:"user_id"`
-	Username  string    `json:"username"`
-	Content   string    `json:"content"`
-	Encrypted bool      `json:"encrypted"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string            `json:"id"`
+	RoomID     string            `json:"room_id"`
+	UserID     string            `json:"user_id"`
+	Username   string            `json:"username"`
+	Content    string            `json:"content"`
+	Encrypted  bool              `json:"encrypted"`
+	KeyVersion int               `json:"key_version,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Mentions   []MentionResponse `json:"mentions,omitempty"`
+	Type       string            `json:"type,omitempty"` // "announcement", empty for regular messages
+}
+
+func (r MessageResponse) IsAnnouncement() bool {
+	return r.Type == "announcement"
+}
+
+type MentionResponse struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
 }
 
 func (r *MessageResponse) UnmarshalJSON(data []byte) error {
@@ -242,11 +346,23 @@ func (r *MessagesResponse) UnmarshalJSON(data []byte) error {
 	return apijson.UnmarshalRoot(data, r)
 }
 
+type AnnouncementsResponse struct {
+	RoomID        string            `json:"room_id"`
+	Announcements []MessageResponse `json:"announcements"`
+	Count         int               `json:"count"`
+}
+
+func (r *AnnouncementsResponse) UnmarshalJSON(data []byte) error {
+	return apijson.UnmarshalRoot(data, r)
+}
+
 type MessageUpdatedResponse struct {
 	Success   bool   `json:"success"`
 	MessageID string `json:"message_id"`
 	Content   string `json:"content"`
 	Encrypted bool   `json:"encrypted"`
+	// Not sent by the server yet, Decrypt falls back to trying every key
+	KeyVersion int `json:"key_version,omitempty"`
 }
 
 func (r *MessageUpdatedResponse) UnmarshalJSON(data []byte) error {
@@ -279,3 +395,19 @@ type MessageListAfterParams struct {
 	Timestamp time.Time
 	Limit     int64 // Optional, defaults to 100 on server
 }
+
+// ParseMentions returns the @usernames in plaintext content, the server checks them against the room
+func ParseMentions(content string) []string {
+	var names []string
+	for _, word := range strings.Fields(content) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+
+		name := strings.TrimRight(strings.TrimPrefix(word, "@"), ".,!?:;")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
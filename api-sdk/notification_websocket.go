@@ -28,7 +28,6 @@ type NotificationWSMessage struct {
 
 type NotificationWebSocket struct {
 	conn           *websocket.Conn
-	userID         string
 	mu             sync.RWMutex
 	closed         bool
 	messageHandler func(NotificationWSMessage)
@@ -62,22 +61,22 @@ func (ws *NotificationWebSocket) SetErrorHandler(handler func(error)) {
 func (ws *NotificationWebSocket) Listen(ctx context.Context) error {
 	defer ws.Close()
 
-	log.Printf("[Notification WS] Started listening for user %s", ws.userID)
+	log.Printf("[Notification WS] Started listening")
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("[Notification WS] Context done for user %s: %v", ws.userID, ctx.Err())
+			log.Printf("[Notification WS] Context done: %v", ctx.Err())
 			return ctx.Err()
 		default:
 			var msg NotificationWSMessage
 			err := ws.conn.ReadJSON(&msg)
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Printf("[Notification WS] Unexpected close error for user %s: %v", ws.userID, err)
+					log.Printf("[Notification WS] Unexpected close error: %v", err)
 					return fmt.Errorf("websocket read error: %w", err)
 				}
-				log.Printf("[Notification WS] Read error for user %s: %v", ws.userID, err)
+				log.Printf("[Notification WS] Read error: %v", err)
 				return err
 			}
 
@@ -148,17 +147,17 @@ func (c *Client) ConnectNotificationWebSocket(
 		}
 	}
 
-	conn, _, err := dialer.DialContext(ctx, path, headers)
+	conn, resp, err := dialer.DialContext(ctx, path, headers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to notification websocket: %w", err)
 	}
-
-	// Extract userID from headers if available
-	userID := cfg.Request.Header.Get("X-User-ID")
+	// The handshake issues a token when the session had none
+	if resp != nil && c.Session != nil {
+		c.Session.keep(resp.Header)
+	}
 
 	ws := &NotificationWebSocket{
-		conn:   conn,
-		userID: userID,
+		conn: conn,
 	}
 
 	return ws, nil
@@ -114,6 +114,12 @@ func WithHeader(key, value string) RequestOption {
 	})
 }
 
+// WithIdempotencyKey returns a RequestOption that sets the Idempotency-Key header. Retries of the
+// request reuse the key, so the server replays the first response instead of acting twice.
+func WithIdempotencyKey(key string) RequestOption {
+	return WithHeader("Idempotency-Key", key)
+}
+
 // WithHeaderDel returns a RequestOption that deletes the header value(s) associated with the given key.
 func WithHeaderDel(key string) RequestOption {
 	return requestconfig.RequestOptionFunc(func(r *requestconfig.RequestConfig) error {
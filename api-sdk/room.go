@@ -23,7 +23,7 @@ func NewRoomService(opts ...option.RequestOption) *RoomService {
 
 // Create creates a new room with specified expiry hours
 func (r *RoomService) Create(ctx context.Context, body RoomCreateParams, opts ...option.RequestOption) (*RoomResponse, error) {
-	opts = slices.Concat(r.Options, opts)
+	opts = slices.Concat([]option.RequestOption{option.WithIdempotencyKey(newIdempotencyKey())}, r.Options, opts)
 	path := "api/v1/rooms"
 
 	res := &RoomResponse{}
@@ -128,6 +128,63 @@ func (r *RoomService) CheckMembership(ctx context.Context, id string, opts ...op
 	return res, err
 }
 
+// IssueSocketTicket returns a single use ticket for authenticating the room's WebSocket,
+// ConnectWebSocket fetches one itself
+func (r *RoomService) IssueSocketTicket(ctx context.Context, id string, opts ...option.RequestOption) (*SocketTicketResponse, error) {
+	opts = slices.Concat(r.Options, opts)
+	if id == "" {
+		return nil, ErrMissingIDParameter
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/ws-ticket", id)
+	res := &SocketTicketResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodPost, path, nil, &res, opts...)
+
+	return res, err
+}
+
+// GetKeys returns the room key and the retired ones, only members on the client they joined from get them
+func (r *RoomService) GetKeys(ctx context.Context, id string, opts ...option.RequestOption) (*RoomKeysResponse, error) {
+	opts = slices.Concat(r.Options, opts)
+	if id == "" {
+		return nil, ErrMissingIDParameter
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/keys", id)
+	res := &RoomKeysResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodGet, path, nil, &res, opts...)
+
+	return res, err
+}
+
+// RotateKey replaces the room key (only owner can rotate), members get a RoomKeyRotated event
+func (r *RoomService) RotateKey(ctx context.Context, id string, opts ...option.RequestOption) (*RoomKeysResponse, error) {
+	opts = slices.Concat(r.Options, opts)
+	if id == "" {
+		return nil, ErrMissingIDParameter
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/keys/rotate", id)
+	res := &RoomKeysResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodPost, path, nil, &res, opts...)
+
+	return res, err
+}
+
+// Extend pushes the room's expiry out (only owner can extend), members get a RoomExtended event
+func (r *RoomService) Extend(ctx context.Context, id string, body ExtendRoomParams, opts ...option.RequestOption) (*RoomResponse, error) {
+	opts = slices.Concat(r.Options, opts)
+	if id == "" {
+		return nil, ErrMissingIDParameter
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/extend", id)
+	res := &RoomResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodPost, path, body, &res, opts...)
+
+	return res, err
+}
+
 // KickMember kicks a member from the room (only owner can kick)
 func (r *RoomService) KickMember(ctx context.Context, roomID, userID string, opts ...option.RequestOption) (*SuccessResponse, error) {
 	opts = slices.Concat(r.Options, opts)
@@ -145,6 +202,65 @@ func (r *RoomService) KickMember(ctx context.Context, roomID, userID string, opt
 	return res, err
 }
 
+// GetPresence returns the online/away/offline status of every room member
+func (r *RoomService) GetPresence(ctx context.Context, id string, opts ...option.RequestOption) (*RoomPresenceResponse, error) {
+	opts = slices.Concat(r.Options, opts)
+	if id == "" {
+		return nil, ErrMissingIDParameter
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/presence", id)
+	res := &RoomPresenceResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodGet, path, nil, &res, opts...)
+
+	return res, err
+}
+
+// CreateInvite creates an invite link for the room (only owner can create)
+func (r *RoomService) CreateInvite(ctx context.Context, id string, body CreateInviteParams, opts ...option.RequestOption) (*RoomInviteResponse, error) {
+	opts = slices.Concat(r.Options, opts)
+	if id == "" {
+		return nil, ErrMissingIDParameter
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/invites", id)
+	res := &RoomInviteResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodPost, path, body, &res, opts...)
+
+	return res, err
+}
+
+// RevokeInvite revokes an invite without rotating the room's join code
+func (r *RoomService) RevokeInvite(ctx context.Context, roomID, token string, opts ...option.RequestOption) (*SuccessResponse, error) {
+	opts = slices.Concat(r.Options, opts)
+	if roomID == "" {
+		return nil, ErrMissingIDParameter
+	}
+	if token == "" {
+		return nil, fmt.Errorf("invite token is required")
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/%s/invites/%s", roomID, token)
+	res := &SuccessResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodDelete, path, nil, &res, opts...)
+
+	return res, err
+}
+
+// RedeemInvite joins the room an invite points to
+func (r *RoomService) RedeemInvite(ctx context.Context, token string, body JoinRoomParams, opts ...option.RequestOption) (*RoomResponse, error) {
+	opts = slices.Concat(r.Options, opts)
+	if token == "" {
+		return nil, fmt.Errorf("invite token is required")
+	}
+
+	path := fmt.Sprintf("api/v1/rooms/invites/%s/redeem", token)
+	res := &RoomResponse{}
+	err := requestconfig.ExecuteNewRequest(ctx, http.MethodPost, path, body, &res, opts...)
+
+	return res, err
+}
+
 // Request/Response types
 type RoomCreateParams struct {
 	ExpiryHours int `json:"expiry_hours"` // 1 to 168 hours (1 hour to 7 days)
@@ -154,6 +270,14 @@ func (r *RoomCreateParams) MarshalJSON() ([]byte, error) {
 	return apijson.MarshalRoot(r)
 }
 
+type ExtendRoomParams struct {
+	Hours int `json:"hours"` // 1 to 168, the room can't be pushed more than 7 days ahead
+}
+
+func (r *ExtendRoomParams) MarshalJSON() ([]byte, error) {
+	return apijson.MarshalRoot(r)
+}
+
 type JoinByCodeParams struct {
 	JoinCode string `json:"join_code"` // 6-character join code
 	Username string `json:"username,omitempty"`
@@ -177,15 +301,23 @@ type UserResponse struct {
 }
 
 type RoomResponse struct {
-	ID            string         `json:"id"`
-	JoinCode      string         `json:"join_code"`
-	QRCodeURL     string         `json:"qr_code_url"`
-	Owner         UserResponse   `json:"owner"`
-	CreatedAt     time.Time      `json:"created_at"`
-	ExpiresAt     time.Time      `json:"expires_at"`
-	Members       []UserResponse `json:"members"`
-	CurrentUser   UserResponse   `json:"current_user"`
-	EncryptionKey string         `json:"encryption_key"`
+	ID          string         `json:"id"`
+	JoinCode    string         `json:"join_code"`
+	QRCodeURL   string         `json:"qr_code_url"`
+	Owner       UserResponse   `json:"owner"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	Members     []UserResponse `json:"members"`
+	CurrentUser UserResponse   `json:"current_user"`
+	// Deprecated: no longer sent, fetch the key with GetKeys
+	EncryptionKey string `json:"encryption_key"`
+	// Storage is nil when the server couldn't report usage
+	Storage *StorageUsage `json:"storage,omitempty"`
+}
+
+type StorageUsage struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
 }
 
 func (r *RoomResponse) UnmarshalJSON(data []byte) error {
@@ -201,6 +333,32 @@ func (r *SuccessResponse) UnmarshalJSON(data []byte) error {
 	return apijson.UnmarshalRoot(data, r)
 }
 
+type RoomKeysResponse struct {
+	RoomID      string            `json:"room_id"`
+	KeyVersion  int               `json:"key_version"`
+	Key         string            `json:"key"`
+	RetiredKeys []RoomKeyResponse `json:"retired_keys,omitempty"`
+}
+
+func (r *RoomKeysResponse) UnmarshalJSON(data []byte) error {
+	return apijson.UnmarshalRoot(data, r)
+}
+
+type RoomKeyResponse struct {
+	KeyVersion int       `json:"key_version"`
+	Key        string    `json:"key"`
+	RetiredAt  time.Time `json:"retired_at"`
+}
+
+type SocketTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (r *SocketTicketResponse) UnmarshalJSON(data []byte) error {
+	return apijson.UnmarshalRoot(data, r)
+}
+
 type MembershipResponse struct {
 	IsMember bool   `json:"is_member"`
 	RoomID   string `json:"room_id"`
@@ -210,3 +368,41 @@ type MembershipResponse struct {
 func (r *MembershipResponse) UnmarshalJSON(data []byte) error {
 	return apijson.UnmarshalRoot(data, r)
 }
+
+type PresenceResponse struct {
+	UserID       string     `json:"user_id"`
+	Username     string     `json:"username"`
+	Status       string     `json:"status"` // online, away or offline
+	LastActiveAt *time.Time `json:"last_active_at,omitempty"`
+}
+
+type RoomPresenceResponse struct {
+	RoomID  string             `json:"room_id"`
+	Members []PresenceResponse `json:"members"`
+}
+
+func (r *RoomPresenceResponse) UnmarshalJSON(data []byte) error {
+	return apijson.UnmarshalRoot(data, r)
+}
+
+type CreateInviteParams struct {
+	MaxUses          int `json:"max_uses,omitempty"`           // 0 means unlimited
+	ExpiresInMinutes int `json:"expires_in_minutes,omitempty"` // 0 lasts as long as the room
+}
+
+func (r *CreateInviteParams) MarshalJSON() ([]byte, error) {
+	return apijson.MarshalRoot(r)
+}
+
+type RoomInviteResponse struct {
+	Token     string     `json:"token"`
+	RoomID    string     `json:"room_id"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (r *RoomInviteResponse) UnmarshalJSON(data []byte) error {
+	return apijson.UnmarshalRoot(data, r)
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,25 +20,56 @@ const (
 	MemberJoined = "member.joined"
 	MemberLeft   = "member.left"
 	MemberList   = "member.list"
+	// The member's connection stopped answering pings, they are still in the room
+	MemberDisconnected = "member.disconnected"
 
 	MessageReceived = "message.received"
 	MessageDeleted  = "message.deleted"
 	MessageUpdated  = "message.updated"
+	Mentioned       = "message.mentioned"
+	Announcement    = "message.announcement"
+	MessageFlagged  = "message.flagged"
+	// Confirms a frame written with SendFrame, only the sender gets it
+	MessageSendAck = "message.send_ack"
+	// Follows message.received once the link in the message has been fetched
+	MessagePreviewReady = "message.preview_ready"
+
+	// Only the room owner gets it, the file is no longer served
+	FileQuarantined = "file.quarantined"
+	// Sent to the uploader while an upload over 1MB is being received
+	UploadProgress = "file.upload_progress"
+
+	PresenceChanged = "presence.changed"
+
+	// Sent on resume when the missed events are no longer buffered, refetch over REST
+	ReplayTruncated = "replay.truncated"
 
 	ErrorEvent          = "error"
 	AuthenticationError = "error.auth"
 	JoinFailed          = "error.join"
 	RateLimited         = "error.rate_limited"
 	Kicked              = "error.kicked"
+	SlowMode            = "error.slow_mode"
 
 	RoomDeleted = "room.deleted"
+	RoomExpired = "room.expired"
 	RoomUpdated = "room.updated"
+	// The room key was rotated, refetch with RoomService.GetKeys and pass them to SetRoomKeys
+	RoomKeyRotated = "room.key_rotated"
+	// Sent as the room's remaining time passes each of the server's warning thresholds
+	RoomExpiringSoon = "room.expiring_soon"
+	RoomExtended     = "room.extended"
+
+	// An operator's notice, sent to every room at once
+	SystemBroadcast = "system.broadcast"
 )
 
 type WSMessage struct {
 	Type   string `json:"type"`
 	RoomID string `json:"roomId"`
 	Data   any    `json:"data"`
+	Seq    uint64 `json:"seq,omitempty"`
+	Epoch  string `json:"epoch,omitempty"`
 }
 
 type MessagePayload struct {
@@ -48,6 +80,64 @@ type MessagePayload struct {
 	Timestamp string `json:"timestamp"`
 }
 
+type AnnouncementPayload struct {
+	ID        string `json:"id"`
+	Content   string `json:"content"`
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	Timestamp string `json:"timestamp"`
+	Encrypted bool   `json:"encrypted"`
+	Pinned    bool   `json:"pinned"`
+}
+
+type MentionPayload struct {
+	MessageID       string `json:"messageId"`
+	UserID          string `json:"userId"`
+	Username        string `json:"username"`
+	MentionedUserID string `json:"mentionedUserId"`
+	Content         string `json:"content"`
+	Timestamp       string `json:"timestamp"`
+	Encrypted       bool   `json:"encrypted"`
+}
+
+// MessageFlaggedPayload is only sent to the room owner
+type MessageFlaggedPayload struct {
+	MessageID string `json:"messageId,omitempty"`
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	Content   string `json:"content"`
+	Blocked   bool   `json:"blocked"`
+	Timestamp string `json:"timestamp"`
+}
+
+// FileQuarantinedPayload is only sent to the room owner, UserID is the uploader
+type FileQuarantinedPayload struct {
+	FileID    string `json:"fileId"`
+	Filename  string `json:"filename"`
+	UserID    string `json:"userId"`
+	Signature string `json:"signature"`
+}
+
+// UploadProgressPayload counts the whole request body, multipart framing included
+type UploadProgressPayload struct {
+	Percent       int   `json:"percent"`
+	BytesReceived int64 `json:"bytesReceived"`
+	TotalBytes    int64 `json:"totalBytes"`
+}
+
+type LinkPreviewPayload struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+	SiteName    string `json:"siteName,omitempty"`
+}
+
+type MessagePreviewPayload struct {
+	MessageID string             `json:"messageId"`
+	Preview   LinkPreviewPayload `json:"preview"`
+}
+
 type MessageDeletedPayload struct {
 	ID string `json:"id"`
 }
@@ -62,10 +152,19 @@ type MemberListPayload struct {
 	Members []MemberPayload `json:"members"`
 }
 
+type PresenceChangedPayload struct {
+	UserID       string `json:"userId"`
+	Username     string `json:"username"`
+	Status       string `json:"status"`
+	LastActiveAt string `json:"lastActiveAt"`
+}
+
 type ErrorPayload struct {
 	Code    string `json:"code,omitempty"`
 	Message string `json:"message"`
 	Retry   bool   `json:"retry,omitempty"`
+
+	RetryAfter int `json:"retryAfter,omitempty"` // seconds, set for error.slow_mode
 }
 
 type BootPayload struct {
@@ -73,16 +172,41 @@ type BootPayload struct {
 	Reason   string `json:"reason"`
 }
 
+type ReplayTruncatedPayload struct {
+	LastSeq uint64 `json:"lastSeq"`
+}
+
 type RoomDeletedPayload struct {
 	RoomID string `json:"roomid"`
 }
 
+type RoomExpiredPayload struct {
+	RoomID    string `json:"roomId"`
+	ExpiredAt string `json:"expiredAt"`
+}
+
+// RoomExpiryPayload comes with RoomExpiringSoon and RoomExtended
+type RoomExpiryPayload struct {
+	ExpiresAt   string `json:"expiresAt"`
+	SecondsLeft int64  `json:"secondsLeft"`
+}
+
+// SystemBroadcastPayload comes with SystemBroadcast, Level is info, warning or critical
+type SystemBroadcastPayload struct {
+	Message string `json:"message"`
+	Level   string `json:"level"`
+	SentAt  string `json:"sentAt"`
+}
+
 type RoomWebSocket struct {
 	conn           *websocket.Conn
 	roomID         string
 	username       string
 	mu             sync.RWMutex
 	closed         bool
+	lastSeq        uint64
+	lastEpoch      string
+	acks           bool
 	messageHandler func(WSMessage)
 	errorHandler   func(error)
 }
@@ -99,6 +223,20 @@ func (ws *RoomWebSocket) Close() error {
 	return ws.conn.Close()
 }
 
+// LastSeq is the newest event sequence seen, pass it to ResumeWebSocket after a disconnect
+func (ws *RoomWebSocket) LastSeq() uint64 {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.lastSeq
+}
+
+// LastEpoch is the epoch LastSeq was numbered in, ResumeWebSocket needs both
+func (ws *RoomWebSocket) LastEpoch() string {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.lastEpoch
+}
+
 func (ws *RoomWebSocket) SetMessageHandler(handler func(WSMessage)) {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
@@ -135,9 +273,28 @@ func (ws *RoomWebSocket) Listen(ctx context.Context) error {
 
 			log.Printf("[WS] Received message - Type: %s, RoomID: %s, Data: %+v", msg.Type, msg.RoomID, msg.Data)
 
-			ws.mu.RLock()
+			ws.mu.Lock()
+			// Every server instance numbers events on its own, a new epoch starts over
+			if msg.Epoch != "" && msg.Epoch != ws.lastEpoch {
+				ws.lastEpoch = msg.Epoch
+				ws.lastSeq = 0
+			}
+			// Replays and retransmits can repeat an event, the sequence number filters them out
+			duplicate := msg.Seq > 0 && msg.Seq <= ws.lastSeq
+			if msg.Seq > ws.lastSeq {
+				ws.lastSeq = msg.Seq
+			}
 			handler := ws.messageHandler
-			ws.mu.RUnlock()
+			if ws.acks && msg.Seq > 0 && !ws.closed {
+				if err := ws.conn.WriteJSON(ackFrame{Type: "ack", Seq: ws.lastSeq}); err != nil {
+					log.Printf("[WS] Failed to ack seq %d for room %s: %v", ws.lastSeq, ws.roomID, err)
+				}
+			}
+			ws.mu.Unlock()
+
+			if duplicate {
+				continue
+			}
 
 			if handler != nil {
 				log.Printf("[WS] Calling message handler for type: %s", msg.Type)
@@ -160,17 +317,90 @@ func (ws *RoomWebSocket) SendMessage(content string) error {
 	return ws.conn.WriteMessage(websocket.TextMessage, []byte(content))
 }
 
+// SendMessageFrame sends a message over the socket, RequestID comes back on the
+// MessageSendAck or error event so the caller can match them up
+type SendMessageFrame struct {
+	Type            string   `json:"type"`
+	RequestID       string   `json:"requestId,omitempty"`
+	Content         string   `json:"content"`
+	Encrypted       bool     `json:"encrypted,omitempty"`
+	KeyVersion      int      `json:"keyVersion,omitempty"`
+	ParentMessageID string   `json:"parentMessageId,omitempty"`
+	Mentions        []string `json:"mentions,omitempty"`
+}
+
+// SendFrame sends a message through the same validation and rate limits as the REST endpoint
+func (ws *RoomWebSocket) SendFrame(frame SendMessageFrame) error {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	if ws.closed {
+		return fmt.Errorf("websocket connection is closed")
+	}
+
+	frame.Type = "send_message"
+	return ws.conn.WriteJSON(frame)
+}
+
+// authFrame has to be the first frame the server receives
+type authFrame struct {
+	Type   string `json:"type"`
+	Ticket string `json:"ticket"`
+}
+
+// ackFrame acknowledges every event up to Seq
+type ackFrame struct {
+	Type string `json:"type"`
+	Seq  uint64 `json:"seq"`
+}
+
+type WebSocketOptions struct {
+	// LastSeq resumes after this event of LastEpoch, see ResumeWebSocket
+	LastSeq   uint64
+	LastEpoch string
+	// Acks has the client acknowledge events, the server resends the ones it doesn't hear back about
+	Acks bool
+}
+
 func (r *RoomService) ConnectWebSocket(
 	ctx context.Context,
 	roomID string,
 	opts ...option.RequestOption,
 ) (*RoomWebSocket, error) {
-	opts = append(r.Options, opts...)
+	return r.ConnectWebSocketWith(ctx, roomID, WebSocketOptions{}, opts...)
+}
+
+// ResumeWebSocket reconnects and has the server replay the events after lastSeq before live
+// delivery resumes. A ReplayTruncated event arrives instead when the gap is too old to replay
+// or the connection landed on another server instance than lastEpoch's.
+func (r *RoomService) ResumeWebSocket(
+	ctx context.Context,
+	roomID string,
+	lastEpoch string,
+	lastSeq uint64,
+	opts ...option.RequestOption,
+) (*RoomWebSocket, error) {
+	return r.ConnectWebSocketWith(ctx, roomID, WebSocketOptions{LastSeq: lastSeq, LastEpoch: lastEpoch}, opts...)
+}
 
+func (r *RoomService) ConnectWebSocketWith(
+	ctx context.Context,
+	roomID string,
+	wsOpts WebSocketOptions,
+	opts ...option.RequestOption,
+) (*RoomWebSocket, error) {
 	if roomID == "" {
 		return nil, ErrMissingIDParameter
 	}
 
+	// The server wants proof the socket comes from the member that joined, not just their user ID
+	ticket, err := r.IssueSocketTicket(ctx, roomID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get websocket ticket: %w", err)
+	}
+
+	opts = append(r.Options, opts...)
+
 	cfg, err := requestconfig.NewRequestConfig(ctx, http.MethodGet, "", nil, nil, opts...)
 	if err != nil {
 		return nil, err
@@ -186,7 +416,20 @@ func (r *RoomService) ConnectWebSocket(
 		wsURL = "ws://" + after0
 	}
 
+	query := url.Values{}
+	query.Set("auth", "ticket")
+	if wsOpts.LastSeq > 0 {
+		query.Set("last_seq", strconv.FormatUint(wsOpts.LastSeq, 10))
+		query.Set("last_epoch", wsOpts.LastEpoch)
+	}
+	if wsOpts.Acks {
+		query.Set("acks", "true")
+	}
+
 	path := fmt.Sprintf("%s/api/v1/rooms/%s/ws", wsURL, roomID)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
 
 	log.Printf("[WEBSOCKET_URL]: %s\n", path)
 
@@ -217,9 +460,17 @@ func (r *RoomService) ConnectWebSocket(
 		return nil, fmt.Errorf("failed to connect to websocket: %w", err)
 	}
 
+	if err := conn.WriteJSON(authFrame{Type: "auth", Ticket: ticket.Ticket}); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to authenticate websocket: %w", err)
+	}
+
 	ws := &RoomWebSocket{
-		conn:   conn,
-		roomID: roomID,
+		conn:      conn,
+		roomID:    roomID,
+		lastSeq:   wsOpts.LastSeq,
+		lastEpoch: wsOpts.LastEpoch,
+		acks:      wsOpts.Acks,
 	}
 
 	return ws, nil
@@ -0,0 +1,425 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type RoomSummary struct {
+	Room         *model.Room
+	MemberCount  int
+	MessageCount int64
+}
+
+// UserInfo is what an operator sees of a user
+type UserInfo struct {
+	User       *model.User
+	Ban        *model.Ban      // Nil unless the user is banned
+	Identity   *model.Identity // Nil unless an account is linked
+	OwnedRooms []string
+	MemberOf   []string
+	BotCount   int
+}
+
+type AdminUseCase interface {
+	ListRooms(ctx context.Context) ([]RoomSummary, error)
+	ForceDeleteRoom(ctx context.Context, roomID string) error
+	// ListRoomIDs returns every live room, the rooms a broadcast goes to
+	ListRoomIDs(ctx context.Context) ([]string, error)
+	GetUserInfo(ctx context.Context, userID string) (*UserInfo, error)
+	BanUser(ctx context.Context, userID, reason string, duration time.Duration) (*model.Ban, error)
+	UnbanUser(ctx context.Context, userID string) error
+	ListBans(ctx context.Context) ([]*model.Ban, error)
+	ListRateLimitBlocks(ctx context.Context) ([]*model.RateLimitBlock, error)
+	ClearRateLimitBlock(ctx context.Context, userID string) error
+	ListRateLimitExemptions(ctx context.Context) ([]*model.RateLimitExemption, error)
+	AddRateLimitExemption(ctx context.Context, kind model.RateLimitExemptionKind, value, note string) (*model.RateLimitExemption, error)
+	RemoveRateLimitExemption(ctx context.Context, kind model.RateLimitExemptionKind, value string) error
+	ListFeatureFlags(ctx context.Context) ([]*model.FeatureFlagState, error)
+	SetFeatureFlag(ctx context.Context, flag model.FeatureFlag, enabled bool, actor string) (*model.FeatureFlagState, error)
+	ListAuditLogs(ctx context.Context, filter repository.AuditLogFilter) ([]model.AuditLog, error)
+}
+
+type adminUseCase struct {
+	roomRepository        repository.RoomRepository
+	messageRepository     repository.MessageRepository
+	banRepository         repository.BanRepository
+	rateLimitRepository   repository.RateLimitRepository
+	userRepository        repository.UserRepository
+	identityRepository    repository.IdentityRepository
+	botRepository         repository.BotRepository
+	featureFlagRepository repository.FeatureFlagRepository
+	auditLogRepository    repository.AuditLogRepository
+	logger                *logger.Logger
+}
+
+func NewAdminUseCase(
+	roomRepository repository.RoomRepository,
+	messageRepository repository.MessageRepository,
+	banRepository repository.BanRepository,
+	rateLimitRepository repository.RateLimitRepository,
+	userRepository repository.UserRepository,
+	identityRepository repository.IdentityRepository,
+	botRepository repository.BotRepository,
+	featureFlagRepository repository.FeatureFlagRepository,
+	auditLogRepository repository.AuditLogRepository,
+	logger *logger.Logger,
+) AdminUseCase {
+	return &adminUseCase{
+		roomRepository:        roomRepository,
+		messageRepository:     messageRepository,
+		banRepository:         banRepository,
+		rateLimitRepository:   rateLimitRepository,
+		userRepository:        userRepository,
+		identityRepository:    identityRepository,
+		botRepository:         botRepository,
+		featureFlagRepository: featureFlagRepository,
+		auditLogRepository:    auditLogRepository,
+		logger:                logger,
+	}
+}
+
+func (uc *adminUseCase) ListRooms(ctx context.Context) ([]RoomSummary, error) {
+	rooms, err := uc.roomRepository.GetAll(ctx)
+	if err != nil {
+		uc.logger.Error("failed to get rooms", zap.Error(err))
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+
+	summaries := make([]RoomSummary, 0, len(rooms))
+	for _, room := range rooms {
+		messageCount, err := uc.messageRepository.Count(ctx, room.ID)
+		if err != nil {
+			uc.logger.Warn("failed to count room messages", zap.Error(err), zap.String("roomID", room.ID))
+		}
+
+		summaries = append(summaries, RoomSummary{
+			Room:         room,
+			MemberCount:  room.MemberCount(),
+			MessageCount: messageCount,
+		})
+	}
+
+	return summaries, nil
+}
+
+func (uc *adminUseCase) ForceDeleteRoom(ctx context.Context, roomID string) error {
+	if roomID == "" {
+		return apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
+	}
+
+	if _, err := uc.roomRepository.GetByID(ctx, roomID); err != nil {
+		if err == redis.Nil {
+			return apperror.ErrRoomNotFound
+		}
+		return fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if err := uc.roomRepository.Delete(ctx, roomID); err != nil {
+		uc.logger.Error("failed to force delete room", zap.Error(err), zap.String("roomID", roomID))
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+
+	uc.logger.Warn("room force deleted by operator", zap.String("roomID", roomID))
+	return nil
+}
+
+func (uc *adminUseCase) ListRoomIDs(ctx context.Context) ([]string, error) {
+	rooms, err := uc.roomRepository.GetAll(ctx)
+	if err != nil {
+		uc.logger.Error("failed to get rooms", zap.Error(err))
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+
+	roomIDs := make([]string, 0, len(rooms))
+	for _, room := range rooms {
+		if room != nil {
+			roomIDs = append(roomIDs, room.ID)
+		}
+	}
+	return roomIDs, nil
+}
+
+func (uc *adminUseCase) GetUserInfo(ctx context.Context, userID string) (*UserInfo, error) {
+	if userID == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
+	}
+
+	user, err := uc.userRepository.GetByID(ctx, userID)
+	if err != nil {
+		if err == redis.Nil {
+			return nil, apperror.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	info := &UserInfo{
+		User:       user,
+		OwnedRooms: make([]string, 0),
+		MemberOf:   make([]string, 0),
+	}
+
+	ban, err := uc.banRepository.GetByUserID(ctx, userID)
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get ban: %w", err)
+	}
+	info.Ban = ban
+
+	if info.Identity, err = uc.identityRepository.GetByUserID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	bots, err := uc.botRepository.GetByOwner(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bots: %w", err)
+	}
+	info.BotCount = len(bots)
+
+	rooms, err := uc.roomRepository.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rooms: %w", err)
+	}
+	for _, room := range rooms {
+		switch {
+		case room == nil:
+		case room.Owner.ID == userID:
+			info.OwnedRooms = append(info.OwnedRooms, room.ID)
+		case room.IsMember(userID):
+			info.MemberOf = append(info.MemberOf, room.ID)
+		}
+	}
+
+	return info, nil
+}
+
+func (uc *adminUseCase) BanUser(ctx context.Context, userID, reason string, duration time.Duration) (*model.Ban, error) {
+	if userID == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
+	}
+
+	ban := &model.Ban{
+		UserID: userID,
+		Reason: reason,
+	}
+
+	if err := uc.banRepository.Create(ctx, ban, duration); err != nil {
+		uc.logger.Error("failed to ban user", zap.Error(err), zap.String("userID", userID))
+		return nil, fmt.Errorf("failed to ban user: %w", err)
+	}
+
+	uc.logger.Warn("user globally banned by operator", zap.String("userID", userID), zap.String("reason", reason), zap.Duration("duration", duration))
+	return ban, nil
+}
+
+func (uc *adminUseCase) UnbanUser(ctx context.Context, userID string) error {
+	if userID == "" {
+		return apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
+	}
+
+	if err := uc.banRepository.Delete(ctx, userID); err != nil {
+		uc.logger.Error("failed to unban user", zap.Error(err), zap.String("userID", userID))
+		return fmt.Errorf("failed to unban user: %w", err)
+	}
+
+	uc.logger.Info("user unbanned by operator", zap.String("userID", userID))
+	return nil
+}
+
+func (uc *adminUseCase) ListBans(ctx context.Context) ([]*model.Ban, error) {
+	bans, err := uc.banRepository.GetAll(ctx)
+	if err != nil {
+		uc.logger.Error("failed to list bans", zap.Error(err))
+		return nil, fmt.Errorf("failed to list bans: %w", err)
+	}
+
+	return bans, nil
+}
+
+func (uc *adminUseCase) ListRateLimitBlocks(ctx context.Context) ([]*model.RateLimitBlock, error) {
+	blocks, err := uc.rateLimitRepository.GetBlocks(ctx)
+	if err != nil {
+		uc.logger.Error("failed to list rate limit blocks", zap.Error(err))
+		return nil, fmt.Errorf("failed to list rate limit blocks: %w", err)
+	}
+
+	return blocks, nil
+}
+
+func (uc *adminUseCase) ClearRateLimitBlock(ctx context.Context, userID string) error {
+	if userID == "" {
+		return apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
+	}
+
+	if err := uc.rateLimitRepository.DeleteBlock(ctx, userID); err != nil {
+		uc.logger.Error("failed to clear rate limit block", zap.Error(err), zap.String("userID", userID))
+		return fmt.Errorf("failed to clear rate limit block: %w", err)
+	}
+
+	uc.logger.Info("rate limit block cleared by operator", zap.String("userID", userID))
+	return nil
+}
+
+func (uc *adminUseCase) ListRateLimitExemptions(ctx context.Context) ([]*model.RateLimitExemption, error) {
+	exemptions, err := uc.rateLimitRepository.GetExemptions(ctx)
+	if err != nil {
+		uc.logger.Error("failed to list rate limit exemptions", zap.Error(err))
+		return nil, fmt.Errorf("failed to list rate limit exemptions: %w", err)
+	}
+
+	return exemptions, nil
+}
+
+func (uc *adminUseCase) AddRateLimitExemption(ctx context.Context, kind model.RateLimitExemptionKind, value, note string) (*model.RateLimitExemption, error) {
+	value, err := normalizeExemptionValue(kind, value)
+	if err != nil {
+		return nil, err
+	}
+
+	exemption := &model.RateLimitExemption{
+		Kind:      kind,
+		Value:     value,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+
+	if err := uc.rateLimitRepository.SaveExemption(ctx, exemption); err != nil {
+		uc.logger.Error("failed to save rate limit exemption", zap.Error(err), zap.String("value", value))
+		return nil, fmt.Errorf("failed to save rate limit exemption: %w", err)
+	}
+
+	uc.logger.Info("rate limit exemption added by operator", zap.String("kind", string(kind)), zap.String("value", value))
+	return exemption, nil
+}
+
+func (uc *adminUseCase) RemoveRateLimitExemption(ctx context.Context, kind model.RateLimitExemptionKind, value string) error {
+	value, err := normalizeExemptionValue(kind, value)
+	if err != nil {
+		return err
+	}
+
+	removed, err := uc.rateLimitRepository.DeleteExemption(ctx, kind, value)
+	if err != nil {
+		uc.logger.Error("failed to remove rate limit exemption", zap.Error(err), zap.String("value", value))
+		return fmt.Errorf("failed to remove rate limit exemption: %w", err)
+	}
+	if !removed {
+		return apperror.ErrExemptionNotFound
+	}
+
+	uc.logger.Info("rate limit exemption removed by operator", zap.String("kind", string(kind)), zap.String("value", value))
+	return nil
+}
+
+// normalizeExemptionValue checks value fits kind, networks are written as their masked
+// CIDR so the same network can't be listed twice
+func normalizeExemptionValue(kind model.RateLimitExemptionKind, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !kind.IsValid() {
+		return "", apperror.ErrInvalidInput.WithMessage("kind must be user, cidr or bot")
+	}
+	if value == "" {
+		return "", apperror.ErrInvalidInput.WithMessage("value cannot be empty")
+	}
+
+	switch kind {
+	case model.RateLimitExemptBot:
+		if !model.IsBotID(value) {
+			return "", apperror.ErrInvalidInput.WithMessage("value must be a bot ID")
+		}
+	case model.RateLimitExemptUser:
+		if model.IsBotID(value) {
+			return "", apperror.ErrInvalidInput.WithMessage("bots are exempted with the bot kind")
+		}
+	case model.RateLimitExemptCIDR:
+		if addr, err := netip.ParseAddr(value); err == nil {
+			addr = addr.Unmap()
+			return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+		}
+		network, err := netip.ParsePrefix(value)
+		if err != nil {
+			return "", apperror.ErrInvalidInput.WithMessage("value must be an address or a CIDR")
+		}
+		return network.Masked().String(), nil
+	}
+
+	return value, nil
+}
+
+// ListFeatureFlags returns every known flag, the ones never toggled as enabled
+func (uc *adminUseCase) ListFeatureFlags(ctx context.Context) ([]*model.FeatureFlagState, error) {
+	saved, err := uc.featureFlagRepository.GetAll(ctx)
+	if err != nil {
+		uc.logger.Error("failed to list feature flags", zap.Error(err))
+		return nil, fmt.Errorf("failed to list feature flags: %w", err)
+	}
+
+	byName := make(map[model.FeatureFlag]*model.FeatureFlagState, len(saved))
+	for _, state := range saved {
+		byName[state.Name] = state
+	}
+
+	states := make([]*model.FeatureFlagState, 0, len(model.FeatureFlags))
+	for _, flag := range model.FeatureFlags {
+		state, ok := byName[flag]
+		if !ok {
+			state = &model.FeatureFlagState{Name: flag, Enabled: true}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (uc *adminUseCase) SetFeatureFlag(ctx context.Context, flag model.FeatureFlag, enabled bool, actor string) (*model.FeatureFlagState, error) {
+	if !flag.IsValid() {
+		return nil, apperror.ErrInvalidInput.WithMessage("unknown feature flag")
+	}
+
+	state := &model.FeatureFlagState{
+		Name:      flag,
+		Enabled:   enabled,
+		UpdatedBy: actor,
+		UpdatedAt: time.Now(),
+	}
+
+	if err := uc.featureFlagRepository.Save(ctx, state); err != nil {
+		uc.logger.Error("failed to save feature flag", zap.Error(err), zap.String("flag", string(flag)))
+		return nil, fmt.Errorf("failed to save feature flag: %w", err)
+	}
+
+	uc.logger.Warn("feature flag toggled by operator", zap.String("flag", string(flag)), zap.Bool("enabled", enabled), zap.String("actor", actor))
+	return state, nil
+}
+
+const (
+	defaultAuditLogLimit = 100
+	maxAuditLogLimit     = 1000
+)
+
+// ListAuditLogs returns the newest entries first, at most maxAuditLogLimit of them
+func (uc *adminUseCase) ListAuditLogs(ctx context.Context, filter repository.AuditLogFilter) ([]model.AuditLog, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLogLimit
+	}
+	filter.Limit = min(filter.Limit, maxAuditLogLimit)
+
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return nil, apperror.ErrInvalidInput.WithMessage("since must be before until")
+	}
+
+	entries, err := uc.auditLogRepository.ListAuditLogs(ctx, filter)
+	if err != nil {
+		uc.logger.Error("failed to list audit logs", zap.Error(err))
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/oidc"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const loginStateTTL = 10 * time.Minute
+
+var ErrLoginExpired = apperror.ErrInvalidInput.WithMessage("the login expired or was already used, please sign in again")
+
+// AdminRules decide which signed in accounts are admins
+type AdminRules struct {
+	Subjects []string
+	Groups   []string
+}
+
+// LoginResult is who a finished login signed in as
+type LoginResult struct {
+	User     *model.User
+	Identity *model.Identity
+	ReturnTo string
+}
+
+type AuthUseCase interface {
+	// StartLogin returns the provider URL the user signs in at
+	StartLogin(ctx context.Context, userID, returnTo string) (string, error)
+	// CompleteLogin links the account to the user that started the login, or switches to
+	// the user it was linked to before
+	CompleteLogin(ctx context.Context, userID, state, code string) (*LoginResult, error)
+	GetIdentity(ctx context.Context, userID string) (*model.Identity, error)
+	Unlink(ctx context.Context, userID string) error
+	IsAdmin(ctx context.Context, userID string) (bool, error)
+}
+
+type authUseCase struct {
+	provider           *oidc.Provider
+	admins             AdminRules
+	identityRepository repository.IdentityRepository
+	userRepository     repository.UserRepository
+	logger             *logger.Logger
+}
+
+func NewAuthUseCase(
+	provider *oidc.Provider,
+	admins AdminRules,
+	identityRepository repository.IdentityRepository,
+	userRepository repository.UserRepository,
+	logger *logger.Logger,
+) AuthUseCase {
+	return &authUseCase{
+		provider:           provider,
+		admins:             admins,
+		identityRepository: identityRepository,
+		userRepository:     userRepository,
+		logger:             logger,
+	}
+}
+
+func (uc *authUseCase) StartLogin(ctx context.Context, userID, returnTo string) (string, error) {
+	state, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	if err := uc.identityRepository.SaveLoginState(ctx, &model.LoginState{
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		UserID:    userID,
+		ReturnTo:  returnTo,
+		ExpiresAt: time.Now().Add(loginStateTTL),
+	}); err != nil {
+		return "", fmt.Errorf("failed to save login state: %w", err)
+	}
+
+	authURL, err := uc.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		uc.logger.Error("failed to build OIDC login URL", zap.Error(err))
+		return "", fmt.Errorf("identity provider unavailable: %w", err)
+	}
+
+	return authURL, nil
+}
+
+func (uc *authUseCase) CompleteLogin(ctx context.Context, userID, state, code string) (*LoginResult, error) {
+	if state == "" || code == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("state and code are required")
+	}
+
+	loginState, err := uc.identityRepository.ConsumeLoginState(ctx, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load login state: %w", err)
+	}
+	// Finishing a login another browser started would sign this one in as someone else
+	if loginState == nil || loginState.UserID != userID {
+		return nil, ErrLoginExpired
+	}
+
+	claims, err := uc.provider.Exchange(ctx, code, loginState.Verifier, loginState.Nonce)
+	if err != nil {
+		uc.logger.Warn("OIDC code exchange failed", zap.Error(err))
+		return nil, apperror.ErrUnauthorized.WithMessage("sign in failed")
+	}
+
+	identity, err := uc.identityRepository.GetBySubject(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	now := time.Now()
+	if identity == nil {
+		identity, err = uc.link(ctx, userID, claims, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	identity.Email = claims.Email
+	identity.Name = claims.Name
+	identity.Admin = uc.isAdminAccount(claims)
+	identity.LastLoginAt = now
+	if err := uc.identityRepository.Save(ctx, identity); err != nil {
+		return nil, fmt.Errorf("failed to save identity: %w", err)
+	}
+
+	user, err := uc.member(ctx, identity.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.logger.Info("user signed in", zap.String("userID", user.ID), zap.String("subject", claims.Subject), zap.Bool("admin", identity.Admin))
+	return &LoginResult{User: user, Identity: identity, ReturnTo: loginState.ReturnTo}, nil
+}
+
+// link gives a new account the guest that signed in, unless the guest already belongs to
+// another account, then it starts with a user of its own
+func (uc *authUseCase) link(ctx context.Context, userID string, claims *oidc.Claims, now time.Time) (*model.Identity, error) {
+	existing, err := uc.identityRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	if existing != nil {
+		userID = uuid.NewString()
+	}
+
+	return &model.Identity{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		UserID:   userID,
+		LinkedAt: now,
+	}, nil
+}
+
+// member returns the linked user, no longer a guest, creating it when it's gone
+func (uc *authUseCase) member(ctx context.Context, userID string, now time.Time) (*model.User, error) {
+	user, err := uc.userRepository.GetByID(ctx, userID)
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		user = &model.User{
+			ID:        userID,
+			Username:  "member-" + userID[:min(8, len(userID))],
+			CreatedAt: now,
+		}
+	} else if !user.IsGuest {
+		return user, nil
+	}
+
+	user.IsGuest = false
+	if err := uc.userRepository.Create(ctx, user); err != nil {
+		uc.logger.Error("failed to save signed in user", zap.Error(err), zap.String("userID", userID))
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+	return user, nil
+}
+
+func (uc *authUseCase) GetIdentity(ctx context.Context, userID string) (*model.Identity, error) {
+	identity, err := uc.identityRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	if identity == nil {
+		return nil, apperror.ErrIdentityNotFound
+	}
+	return identity, nil
+}
+
+// Unlink drops the account link, the user stays but no account signs in as it any more
+func (uc *authUseCase) Unlink(ctx context.Context, userID string) error {
+	identity, err := uc.GetIdentity(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.identityRepository.Delete(ctx, identity); err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+
+	uc.logger.Info("identity unlinked", zap.String("userID", userID))
+	return nil
+}
+
+func (uc *authUseCase) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	identity, err := uc.identityRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return identity != nil && identity.Admin, nil
+}
+
+func (uc *authUseCase) isAdminAccount(claims *oidc.Claims) bool {
+	if slices.Contains(uc.admins.Subjects, claims.Subject) {
+		return true
+	}
+	for _, group := range claims.Groups {
+		if slices.Contains(uc.admins.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	maxBotsPerOwner = 10
+
+	// Lets secret scanners and humans tell a bot token apart from other credentials
+	tokenPrefix = "vbt_"
+)
+
+type BotUseCase interface {
+	Register(ctx context.Context, ownerID, name string, scopes []model.BotScope) (*model.Bot, string, error)
+	List(ctx context.Context, ownerID string) ([]*model.Bot, error)
+	Get(ctx context.Context, ownerID, botID string) (*model.Bot, error)
+	Revoke(ctx context.Context, ownerID, botID string) error
+	Authenticate(ctx context.Context, token string) (*model.Bot, error)
+}
+
+type botUseCase struct {
+	repository repository.BotRepository
+	logger     *logger.Logger
+}
+
+func NewBotUseCase(repository repository.BotRepository, logger *logger.Logger) BotUseCase {
+	return &botUseCase{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// Register returns the plain token once, only its hash is kept
+func (uc *botUseCase) Register(ctx context.Context, ownerID, name string, scopes []model.BotScope) (*model.Bot, string, error) {
+	if model.IsBotID(ownerID) {
+		return nil, "", apperror.ErrForbidden.WithMessage("bots cannot register other bots")
+	}
+
+	name = strings.TrimSpace(name)
+	if len(name) < 3 || len(name) > 50 {
+		return nil, "", apperror.ErrInvalidInput.WithMessage("bot name must be between 3 and 50 characters")
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", apperror.ErrInvalidInput.WithMessage("a bot needs at least one scope")
+	}
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return nil, "", apperror.ErrInvalidInput.WithMessage("unknown bot scope")
+		}
+	}
+
+	existing, err := uc.repository.GetByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to register bot: %w", err)
+	}
+	if len(existing) >= maxBotsPerOwner {
+		return nil, "", apperror.ErrLimitReached.WithMessage("too many bots")
+	}
+
+	token := tokenPrefix + rand.Text()
+	bot := &model.Bot{
+		ID:        model.BotIDPrefix + uuid.NewString(),
+		Name:      name,
+		OwnerID:   ownerID,
+		Scopes:    scopes,
+		TokenHash: hashToken(token),
+	}
+
+	if err := uc.repository.Create(ctx, bot); err != nil {
+		uc.logger.Error("failed to register bot", zap.Error(err), zap.String("ownerID", ownerID))
+		return nil, "", fmt.Errorf("failed to register bot: %w", err)
+	}
+
+	uc.logger.Info("bot registered", zap.String("botID", bot.ID), zap.String("ownerID", ownerID))
+	return bot, token, nil
+}
+
+func (uc *botUseCase) List(ctx context.Context, ownerID string) ([]*model.Bot, error) {
+	return uc.repository.GetByOwner(ctx, ownerID)
+}
+
+func (uc *botUseCase) Get(ctx context.Context, ownerID, botID string) (*model.Bot, error) {
+	bot, err := uc.repository.GetByID(ctx, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot: %w", err)
+	}
+
+	// Someone else's bot is reported as missing rather than forbidden
+	if bot == nil || bot.OwnerID != ownerID {
+		return nil, apperror.ErrBotNotFound
+	}
+
+	return bot, nil
+}
+
+func (uc *botUseCase) Revoke(ctx context.Context, ownerID, botID string) error {
+	bot, err := uc.Get(ctx, ownerID, botID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.repository.Delete(ctx, bot); err != nil {
+		uc.logger.Error("failed to revoke bot", zap.Error(err), zap.String("botID", botID))
+		return fmt.Errorf("failed to revoke bot: %w", err)
+	}
+
+	uc.logger.Info("bot revoked", zap.String("botID", botID), zap.String("ownerID", ownerID))
+	return nil
+}
+
+func (uc *botUseCase) Authenticate(ctx context.Context, token string) (*model.Bot, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, apperror.ErrUnauthorized.WithMessage("invalid bot token")
+	}
+
+	bot, err := uc.repository.GetByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate bot: %w", err)
+	}
+	if bot == nil {
+		return nil, apperror.ErrUnauthorized.WithMessage("invalid bot token")
+	}
+
+	return bot, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,219 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/crypto"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// Archive header: magic, version, key source, salt. Version 2 seals the zip as a
+	// crypto stream with counter and last-frame nonces.
+	archiveMagic   = "VSPX"
+	archiveVersion = 2
+
+	keySourceRoom       byte = 0
+	keySourcePassphrase byte = 1
+
+	minPassphraseLength = 8
+)
+
+type ExportUseCase interface {
+	PrepareExport(ctx context.Context, roomID, userID, passphrase string) (*model.Room, error)
+	WriteArchive(ctx context.Context, room *model.Room, passphrase string, w io.Writer) error
+	PrepareTranscript(ctx context.Context, roomID, userID string) (*model.Room, error)
+	WriteTranscript(ctx context.Context, room *model.Room, format TranscriptFormat, w io.Writer) error
+}
+
+type exportUseCase struct {
+	roomRepository    repository.RoomRepository
+	messageRepository repository.MessageRepository
+	fileRepository    repository.FileRepository
+	membershipLog     repository.MembershipLogRepository
+	exportLimit       repository.ExportLimitRepository
+	storage           storage.Storage
+	logger            *logger.Logger
+}
+
+func NewExportUseCase(
+	roomRepository repository.RoomRepository,
+	messageRepository repository.MessageRepository,
+	fileRepository repository.FileRepository,
+	membershipLog repository.MembershipLogRepository,
+	exportLimit repository.ExportLimitRepository,
+	fileStorage storage.Storage,
+	logger *logger.Logger,
+) ExportUseCase {
+	return &exportUseCase{
+		roomRepository:    roomRepository,
+		messageRepository: messageRepository,
+		fileRepository:    fileRepository,
+		membershipLog:     membershipLog,
+		exportLimit:       exportLimit,
+		storage:           fileStorage,
+		logger:            logger,
+	}
+}
+
+// PrepareExport validates the request before anything is written to the client
+func (uc *exportUseCase) PrepareExport(ctx context.Context, roomID, userID, passphrase string) (*model.Room, error) {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != userID {
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can export the room")
+	}
+
+	if passphrase == "" && room.EncryptionKey == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("passphrase is required for this room")
+	}
+
+	if passphrase != "" && len(passphrase) < minPassphraseLength {
+		return nil, apperror.ErrInvalidInput.WithMessage(fmt.Sprintf("passphrase must be at least %d characters", minPassphraseLength))
+	}
+
+	return room, nil
+}
+
+func (uc *exportUseCase) WriteArchive(ctx context.Context, room *model.Room, passphrase string, w io.Writer) error {
+	key, header, err := uc.archiveKey(room, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	encrypted, err := crypto.NewStreamWriter(w, key)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(encrypted)
+
+	if err := uc.writeMessages(ctx, archive, room.ID); err != nil {
+		uc.logger.Error("failed to export messages", zap.Error(err), zap.String("roomID", room.ID))
+		return err
+	}
+
+	if err := uc.writeFiles(ctx, archive, room.ID); err != nil {
+		uc.logger.Error("failed to export files", zap.Error(err), zap.String("roomID", room.ID))
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	if err := encrypted.Close(); err != nil {
+		return err
+	}
+
+	uc.logger.Info("room exported", zap.String("roomID", room.ID), zap.Bool("passphrase", passphrase != ""))
+	return nil
+}
+
+func (uc *exportUseCase) archiveKey(room *model.Room, passphrase string) ([]byte, []byte, error) {
+	header := append([]byte(archiveMagic), archiveVersion)
+
+	if passphrase != "" {
+		salt, err := crypto.GenerateSalt()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		header = append(header, keySourcePassphrase)
+		header = append(header, salt...)
+		return crypto.DeriveKey(passphrase, salt), header, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(room.EncryptionKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid room encryption key: %w", err)
+	}
+
+	header = append(header, keySourceRoom)
+	header = append(header, make([]byte, crypto.SaltSize)...)
+	return key, header, nil
+}
+
+func (uc *exportUseCase) writeMessages(ctx context.Context, archive *zip.Writer, roomID string) error {
+	// A limit of 0 returns the whole sorted set
+	messages, err := uc.messageRepository.GetByRoom(ctx, roomID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	// Stored newest first, transcripts read oldest first
+	slices.Reverse(messages)
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     "messages.json",
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(messages)
+}
+
+func (uc *exportUseCase) writeFiles(ctx context.Context, archive *zip.Writer, roomID string) error {
+	files, err := uc.fileRepository.GetByRoomID(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get files: %w", err)
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := uc.writeFile(ctx, archive, file); err != nil {
+			// Missing blobs shouldn't sink the whole export
+			uc.logger.Warn("skipping file in export", zap.Error(err), zap.String("fileID", file.ID))
+		}
+	}
+
+	return nil
+}
+
+func (uc *exportUseCase) writeFile(ctx context.Context, archive *zip.Writer, file *model.File) error {
+	object, err := uc.storage.Get(ctx, file.Path)
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     path.Join("files", file.ID+"_"+path.Base(file.Filename)),
+		Method:   zip.Store, // Uploads are images, already compressed
+		Modified: file.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entry, object.Body)
+	return err
+}
@@ -0,0 +1,268 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"go.uber.org/zap"
+)
+
+type TranscriptFormat string
+
+const (
+	TranscriptJSON TranscriptFormat = "json"
+	TranscriptText TranscriptFormat = "txt"
+	TranscriptHTML TranscriptFormat = "html"
+)
+
+const (
+	// One transcript per room per interval, rendering walks the whole history
+	transcriptCooldown = time.Minute
+
+	// Caps keep a single export from pinning memory, the oldest entries are dropped first
+	maxTranscriptEntries = 10000
+	maxTranscriptBytes   = 8 << 20
+)
+
+// ExportLimitError carries how long the owner has to wait before exporting the room again
+type ExportLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ExportLimitError) Error() string {
+	return "the room was exported recently, please wait before exporting again"
+}
+
+func ParseTranscriptFormat(format string) (TranscriptFormat, bool) {
+	switch TranscriptFormat(format) {
+	case TranscriptJSON, TranscriptText, TranscriptHTML:
+		return TranscriptFormat(format), true
+	case "":
+		return TranscriptJSON, true
+	}
+	return "", false
+}
+
+func (f TranscriptFormat) ContentType() string {
+	switch f {
+	case TranscriptText:
+		return "text/plain; charset=utf-8"
+	case TranscriptHTML:
+		return "text/html; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+type transcriptEntry struct {
+	Kind            string    `json:"kind"` // message or membership
+	CreatedAt       time.Time `json:"created_at"`
+	UserID          string    `json:"user_id"`
+	Username        string    `json:"username"`
+	MessageID       string    `json:"message_id,omitempty"`
+	ParentMessageID string    `json:"parent_message_id,omitempty"`
+	Content         string    `json:"content,omitempty"`
+	Encrypted       bool      `json:"encrypted,omitempty"`
+	Announcement    bool      `json:"announcement,omitempty"`
+	Action          string    `json:"action,omitempty"`
+}
+
+type transcript struct {
+	RoomID     string            `json:"room_id"`
+	Name       string            `json:"name,omitempty"`
+	Topic      string            `json:"topic,omitempty"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Truncated  bool              `json:"truncated"`
+	Entries    []transcriptEntry `json:"entries"`
+}
+
+// PrepareTranscript validates the request and takes the export cooldown, so it has to run before anything is written
+func (uc *exportUseCase) PrepareTranscript(ctx context.Context, roomID, userID string) (*model.Room, error) {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != userID {
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can export the room")
+	}
+
+	retryAfter, err := uc.exportLimit.Acquire(ctx, roomID, userID, transcriptCooldown)
+	if err != nil {
+		// Fail open, a Redis hiccup shouldn't block the owner from saving their history
+		uc.logger.Warn("failed to check export limit", zap.Error(err), zap.String("roomID", roomID))
+	}
+	if retryAfter > 0 {
+		return nil, &ExportLimitError{RetryAfter: retryAfter}
+	}
+
+	return room, nil
+}
+
+func (uc *exportUseCase) WriteTranscript(ctx context.Context, room *model.Room, format TranscriptFormat, w io.Writer) error {
+	t, err := uc.buildTranscript(ctx, room)
+	if err != nil {
+		uc.logger.Error("failed to build transcript", zap.Error(err), zap.String("roomID", room.ID))
+		return err
+	}
+
+	switch format {
+	case TranscriptText:
+		err = writeTextTranscript(w, t)
+	case TranscriptHTML:
+		err = htmlTranscript.Execute(w, t)
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(t)
+	}
+	if err != nil {
+		return err
+	}
+
+	uc.logger.Info("room transcript exported",
+		zap.String("roomID", room.ID),
+		zap.String("format", string(format)),
+		zap.Int("entries", len(t.Entries)),
+		zap.Bool("truncated", t.Truncated),
+	)
+	return nil
+}
+
+func (uc *exportUseCase) buildTranscript(ctx context.Context, room *model.Room) (*transcript, error) {
+	// A limit of 0 returns the whole sorted set
+	messages, err := uc.messageRepository.GetByRoom(ctx, room.ID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	membership, err := uc.membershipLog.GetAll(ctx, room.ID)
+	if err != nil {
+		// Messages are what owners are after, carry on without the join and leave lines
+		uc.logger.Warn("failed to get membership log", zap.Error(err), zap.String("roomID", room.ID))
+	}
+
+	entries := make([]transcriptEntry, 0, len(messages)+len(membership))
+	for _, message := range messages {
+		entries = append(entries, transcriptEntry{
+			Kind:            "message",
+			CreatedAt:       message.CreatedAt,
+			UserID:          message.UserID,
+			Username:        message.Username,
+			MessageID:       message.ID,
+			ParentMessageID: message.ParentMessageID,
+			Content:         message.Content,
+			Encrypted:       message.Encrypted,
+			Announcement:    message.IsAnnouncement(),
+		})
+	}
+	for _, event := range membership {
+		entries = append(entries, transcriptEntry{
+			Kind:      "membership",
+			CreatedAt: event.CreatedAt,
+			UserID:    event.UserID,
+			Username:  event.Username,
+			Action:    string(event.Action),
+		})
+	}
+
+	// Newest first while applying the caps, so whatever gets cut is the oldest history
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+
+	t := &transcript{
+		RoomID:     room.ID,
+		Name:       room.Settings.Name,
+		Topic:      room.Settings.Topic,
+		ExportedAt: time.Now(),
+	}
+
+	size := 0
+	for i, entry := range entries {
+		size += len(entry.Content) + len(entry.Username)
+		if i >= maxTranscriptEntries || size > maxTranscriptBytes {
+			entries = entries[:i]
+			t.Truncated = true
+			break
+		}
+	}
+
+	slices.Reverse(entries)
+	t.Entries = entries
+	return t, nil
+}
+
+func writeTextTranscript(w io.Writer, t *transcript) error {
+	title := t.RoomID
+	if t.Name != "" {
+		title = fmt.Sprintf("%s (%s)", t.Name, t.RoomID)
+	}
+
+	if _, err := fmt.Fprintf(w, "Visper transcript for %s\nExported %s\n", title, t.ExportedAt.UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if t.Truncated {
+		if _, err := fmt.Fprintln(w, "Older history was left out to keep the export within size limits."); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+
+	for _, entry := range t.Entries {
+		if _, err := fmt.Fprintf(w, "[%s] %s\n", entry.CreatedAt.UTC().Format("2006-01-02 15:04:05"), entry.line()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e transcriptEntry) line() string {
+	if e.Kind == "membership" {
+		return fmt.Sprintf("* %s %s", e.Username, e.Action)
+	}
+
+	content := e.Content
+	if e.Encrypted {
+		content = "[encrypted] " + content
+	}
+	if e.Announcement {
+		return fmt.Sprintf("ANNOUNCEMENT %s: %s", e.Username, content)
+	}
+	return fmt.Sprintf("%s: %s", e.Username, content)
+}
+
+var htmlTranscript = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"timestamp": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Visper transcript {{if .Name}}{{.Name}}{{else}}{{.RoomID}}{{end}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48rem; margin: 2rem auto; }
+.entry { margin: 0.25rem 0; white-space: pre-wrap; }
+.time { color: #888; font-size: 0.85em; }
+.membership { color: #666; font-style: italic; }
+.announcement { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{if .Name}}{{.Name}}{{else}}{{.RoomID}}{{end}}</h1>
+{{if .Topic}}<p>{{.Topic}}</p>{{end}}
+<p class="time">Exported {{timestamp .ExportedAt}} UTC</p>
+{{if .Truncated}}<p>Older history was left out to keep the export within size limits.</p>{{end}}
+{{range .Entries}}<div class="entry{{if eq .Kind "membership"}} membership{{else if .Announcement}} announcement{{end}}"><span class="time">{{timestamp .CreatedAt}}</span> {{if eq .Kind "membership"}}{{.Username}} {{.Action}}{{else}}<b>{{.Username}}</b>: {{if .Encrypted}}[encrypted] {{end}}{{.Content}}{{end}}</div>
+{{end}}</body>
+</html>
+`))
@@ -2,78 +2,142 @@ package file
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mime/multipart"
+	"strconv"
+	"time"
 
+	"github.com/hilthontt/visper/api/domain/apperror"
 	"github.com/hilthontt/visper/api/domain/model"
 	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/imaging"
+	"github.com/hilthontt/visper/api/infrastructure/scanner"
 	"github.com/hilthontt/visper/api/infrastructure/storage"
+	"github.com/hilthontt/visper/api/infrastructure/workerpool"
 )
 
 type FileUseCase interface {
 	UploadFile(ctx context.Context, fileHeader *multipart.FileHeader, roomID, userID string) (*model.File, error)
 	GetFile(ctx context.Context, fileID string) (*model.File, error)
-	GetRoomFiles(ctx context.Context, roomID string) ([]*model.File, error)
+	GetRoomFiles(ctx context.Context, roomID, userID string) ([]*model.File, error)
 	DeleteFile(ctx context.Context, fileID, userID string) error
 	CleanupOrphanedFiles(ctx context.Context) error
+	// DeleteRoomFiles removes every file of a room that is being deleted, quarantined ones included
+	DeleteRoomFiles(ctx context.Context, roomID string) error
+	// VerifyLink checks the expires and sig query parameters of a download link
+	VerifyLink(relativePath, expires, signature string) error
+	// GetRoomUsage returns the bytes the room's files take up and the room's quota
+	GetRoomUsage(ctx context.Context, roomID string) (used, quota int64, err error)
+	ScanFile(ctx context.Context, fileID string) (*model.File, error)
 }
 
 type fileUseCase struct {
-	fileRepo     repository.FileRepository
-	roomRepo     repository.RoomRepository
-	localStorage *storage.LocalStorage
-	serverURL    string
+	fileRepo  repository.FileRepository
+	roomRepo  repository.RoomRepository
+	statsRepo repository.StatsRepository
+	storage   storage.Storage
+	signer    *storage.URLSigner
+	linkTTL   time.Duration
+	workers   *workerpool.Pool
+	scanner   scanner.Scanner // nil when scanning is off
+	serverURL string
+	// keepMetadata leaves EXIF and XMP on uploaded JPEGs
+	keepMetadata bool
+	roomQuota    int64
 }
 
 func NewFileUseCase(
 	fileRepo repository.FileRepository,
 	roomRepo repository.RoomRepository,
-	localStorage *storage.LocalStorage,
+	statsRepo repository.StatsRepository,
+	fileStorage storage.Storage,
+	signer *storage.URLSigner,
+	linkTTL time.Duration,
+	workers *workerpool.Pool,
+	fileScanner scanner.Scanner,
 	serverURL string,
+	keepMetadata bool,
+	roomQuota int64,
 ) FileUseCase {
 	return &fileUseCase{
-		fileRepo:     fileRepo,
-		roomRepo:     roomRepo,
-		localStorage: localStorage,
-		serverURL:    serverURL,
+		fileRepo:  fileRepo,
+		roomRepo:  roomRepo,
+		statsRepo: statsRepo,
+		storage:   fileStorage,
+		signer:    signer,
+		linkTTL:   linkTTL,
+		workers:   workers,
+		scanner:   fileScanner,
+		serverURL: serverURL,
+
+		keepMetadata: keepMetadata,
+		roomQuota:    roomQuota,
 	}
 }
 
 func (uc *fileUseCase) UploadFile(ctx context.Context, fileHeader *multipart.FileHeader, roomID, userID string) (*model.File, error) {
 	room, err := uc.roomRepo.GetByID(ctx, roomID)
 	if err != nil {
-		return nil, fmt.Errorf("room not found")
+		return nil, apperror.ErrRoomNotFound
 	}
 
 	if room.HasExpired() {
-		return nil, fmt.Errorf("room has expired")
+		return nil, apperror.ErrRoomExpired
 	}
 
 	if !room.IsMember(userID) {
-		return nil, fmt.Errorf("user is not a member of this room")
+		return nil, apperror.ErrNotMember
 	}
 
-	relativePath, fileID, err := uc.localStorage.SaveFile(fileHeader, roomID)
+	// Checked up front, so concurrent uploads can overshoot the quota by at most one file each
+	used, err := uc.fileRepo.GetRoomUsage(ctx, roomID)
 	if err != nil {
+		return nil, fmt.Errorf("failed to get room storage usage: %w", err)
+	}
+	if used+fileHeader.Size > uc.roomQuota {
+		return nil, apperror.ErrRoomStorageFull
+	}
+
+	upload, err := storage.SaveUpload(ctx, uc.storage, fileHeader, roomID, uc.keepMetadata)
+	switch {
+	case errors.Is(err, storage.ErrFileTooLarge):
+		return nil, apperror.ErrFileTooLarge
+	case errors.Is(err, imaging.ErrUnrecognizedImage), errors.Is(err, imaging.ErrPolyglot):
+		return nil, apperror.ErrInvalidFileType.WithMessage(err.Error())
+	case err != nil:
 		return nil, err
 	}
 
 	file := &model.File{
-		ID:       fileID,
+		ID:       upload.FileID,
 		RoomID:   roomID,
 		UserID:   userID,
 		Filename: fileHeader.Filename,
-		MimeType: fileHeader.Header.Get("Content-Type"),
-		Size:     fileHeader.Size,
-		Path:     relativePath,
-		URL:      fmt.Sprintf("%s/api/v1/d/%s", uc.serverURL, relativePath),
+		MimeType: upload.ContentType,
+		Size:     upload.Size,
+		Path:     upload.Key,
+		URL:      fmt.Sprintf("%s/api/v1/d/%s", uc.serverURL, upload.Key),
+	}
+
+	file.ScanStatus = model.ScanSkipped
+	if uc.scanner != nil {
+		file.ScanStatus = model.ScanPending
 	}
 
 	if err := uc.fileRepo.Create(ctx, file); err != nil {
-		_ = uc.localStorage.DeleteFile(relativePath)
+		_ = uc.storage.Delete(ctx, upload.Key)
 		return nil, fmt.Errorf("failed to save file metadata: %w", err)
 	}
 
+	// Upload stats are best effort and must not fail the upload
+	_ = uc.statsRepo.AddUploadBytes(ctx, file.Size)
+
+	// The stored URL stays unsigned, every response hands out a fresh link
+	if err := uc.signLinks(ctx, file); err != nil {
+		return nil, err
+	}
+
 	return file, nil
 }
 
@@ -81,32 +145,50 @@ func (uc *fileUseCase) GetFile(ctx context.Context, fileID string) (*model.File,
 	return uc.fileRepo.GetByID(ctx, fileID)
 }
 
-func (uc *fileUseCase) GetRoomFiles(ctx context.Context, roomID string) ([]*model.File, error) {
-	_, err := uc.roomRepo.GetByID(ctx, roomID)
+func (uc *fileUseCase) GetRoomFiles(ctx context.Context, roomID, userID string) ([]*model.File, error) {
+	room, err := uc.roomRepo.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	// Links are the only way to a file, so only members get them
+	if !room.IsMember(userID) {
+		return nil, apperror.ErrNotMember
+	}
+
+	files, err := uc.fileRepo.GetByRoomID(ctx, roomID)
 	if err != nil {
-		return nil, fmt.Errorf("room not found")
+		return nil, err
 	}
 
-	return uc.fileRepo.GetByRoomID(ctx, roomID)
+	for _, file := range files {
+		if err := uc.signLinks(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
 }
 
 func (uc *fileUseCase) DeleteFile(ctx context.Context, fileID, userID string) error {
 	file, err := uc.fileRepo.GetByID(ctx, fileID)
 	if err != nil {
-		return fmt.Errorf("file not found")
+		return apperror.ErrFileNotFound
 	}
 
 	room, err := uc.roomRepo.GetByID(ctx, file.RoomID)
 	if err != nil {
-		return fmt.Errorf("room not found")
+		return apperror.ErrRoomNotFound
 	}
 
 	if file.UserID != userID && room.Owner.ID != userID {
-		return fmt.Errorf("only the file uploader or room owner can delete files")
+		return apperror.ErrNotOwner.WithMessage("only the file uploader or room owner can delete files")
 	}
 
-	if err := uc.localStorage.DeleteFile(file.Path); err != nil {
-		return fmt.Errorf("failed to delete file from storage: %w", err)
+	for _, key := range file.StorageKeys() {
+		if err := uc.storage.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete file from storage: %w", err)
+		}
 	}
 
 	if err := uc.fileRepo.Delete(ctx, fileID); err != nil {
@@ -116,6 +198,31 @@ func (uc *fileUseCase) DeleteFile(ctx context.Context, fileID, userID string) er
 	return nil
 }
 
+func (uc *fileUseCase) DeleteRoomFiles(ctx context.Context, roomID string) error {
+	files, err := uc.fileRepo.GetByRoomID(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get room files: %w", err)
+	}
+
+	for _, file := range files {
+		for _, key := range file.StorageKeys() {
+			if err := uc.storage.Delete(ctx, key); err != nil {
+				return fmt.Errorf("failed to delete file from storage: %w", err)
+			}
+		}
+	}
+
+	if err := uc.fileRepo.DeleteByRoomID(ctx, roomID); err != nil {
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+
+	if sweeper, ok := uc.storage.(storage.RoomSweeper); ok {
+		_ = sweeper.DeleteRoomFiles(roomID)
+	}
+
+	return nil
+}
+
 func (uc *fileUseCase) CleanupOrphanedFiles(ctx context.Context) error {
 	orphanedFiles, err := uc.fileRepo.GetOrphanedFiles(ctx)
 	if err != nil {
@@ -123,14 +230,21 @@ func (uc *fileUseCase) CleanupOrphanedFiles(ctx context.Context) error {
 	}
 
 	for _, file := range orphanedFiles {
-		// Delete from local storage
-		_ = uc.localStorage.DeleteFile(file.Path)
+		// Delete from storage
+		for _, key := range file.StorageKeys() {
+			_ = uc.storage.Delete(ctx, key)
+		}
 
 		// Delete metadata
 		_ = uc.fileRepo.Delete(ctx, file.ID)
 	}
 
-	roomDirs, err := uc.localStorage.GetAllRoomDirectories()
+	sweeper, ok := uc.storage.(storage.RoomSweeper)
+	if !ok {
+		return nil
+	}
+
+	roomDirs, err := sweeper.GetAllRoomDirectories()
 	if err != nil {
 		return fmt.Errorf("failed to get room directories: %w", err)
 	}
@@ -139,9 +253,34 @@ func (uc *fileUseCase) CleanupOrphanedFiles(ctx context.Context) error {
 		_, err := uc.roomRepo.GetByID(ctx, roomID)
 		if err != nil {
 			// Room doesn't exist, delete the directory
-			_ = uc.localStorage.DeleteRoomFiles(roomID)
+			_ = sweeper.DeleteRoomFiles(roomID)
 		}
 	}
 
 	return nil
 }
+
+func (uc *fileUseCase) GetRoomUsage(ctx context.Context, roomID string) (int64, int64, error) {
+	used, err := uc.fileRepo.GetRoomUsage(ctx, roomID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return used, uc.roomQuota, nil
+}
+
+func (uc *fileUseCase) VerifyLink(relativePath, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || signature == "" {
+		return apperror.ErrFileLinkInvalid
+	}
+
+	if !uc.signer.Valid(relativePath, expiresAt, signature) {
+		return apperror.ErrFileLinkInvalid
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return apperror.ErrFileLinkExpired
+	}
+
+	return nil
+}
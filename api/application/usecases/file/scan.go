@@ -0,0 +1,103 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+// ScanFile runs the upload through the malware scanner and records the verdict.
+// Infected files are moved under the room's quarantine prefix and their variants removed,
+// so every link handed out for them stops working. Variants are only rendered once a
+// verdict lets the file be served.
+func (uc *fileUseCase) ScanFile(ctx context.Context, fileID string) (*model.File, error) {
+	file, err := uc.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		return nil, apperror.ErrFileNotFound
+	}
+
+	if uc.scanner == nil {
+		file.ScanStatus = model.ScanSkipped
+		return file, uc.recordServable(ctx, file)
+	}
+
+	object, err := uc.storage.Get(ctx, file.Path)
+	if err != nil {
+		return nil, err
+	}
+	verdict, err := uc.scanner.Scan(ctx, object.Body)
+	object.Body.Close()
+
+	if err != nil {
+		file.ScanStatus = model.ScanFailed
+		if updateErr := uc.recordServable(ctx, file); updateErr != nil {
+			return nil, updateErr
+		}
+		return file, fmt.Errorf("failed to scan file: %w", err)
+	}
+
+	if !verdict.Infected {
+		file.ScanStatus = model.ScanClean
+		return file, uc.recordServable(ctx, file)
+	}
+
+	if err := uc.quarantine(ctx, file); err != nil {
+		return nil, err
+	}
+
+	file.ScanStatus = model.ScanInfected
+	file.ScanSignature = verdict.Signature
+	return file, uc.fileRepo.Update(ctx, file)
+}
+
+// recordServable stores a verdict that leaves the file served and only then queues its variants,
+// so rendering never overlaps the scan's own read and write of the record
+func (uc *fileUseCase) recordServable(ctx context.Context, file *model.File) error {
+	if err := uc.fileRepo.Update(ctx, file); err != nil {
+		return err
+	}
+
+	// Variants are a nicety, a full queue just leaves clients with the original
+	stored := *file
+	uc.workers.Submit("file variants "+file.ID, func(ctx context.Context) error {
+		return uc.generateVariants(ctx, &stored)
+	})
+	return nil
+}
+
+// quarantine keeps the original for the room owner to look into, it is removed with the room
+func (uc *fileUseCase) quarantine(ctx context.Context, file *model.File) error {
+	key := path.Join(file.RoomID, "quarantine", path.Base(file.Path))
+
+	object, err := uc.storage.Get(ctx, file.Path)
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	if err := uc.storage.Put(ctx, key, object.Body, object.Size, "application/octet-stream"); err != nil {
+		return fmt.Errorf("failed to quarantine file: %w", err)
+	}
+
+	// Variants recorded since file was read are removed as well
+	keys := file.StorageKeys()
+	if current, err := uc.fileRepo.GetByID(ctx, file.ID); err == nil {
+		for _, key := range current.Variants {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, old := range keys {
+		if err := uc.storage.Delete(ctx, old); err != nil {
+			return fmt.Errorf("failed to remove quarantined file: %w", err)
+		}
+	}
+
+	file.Path = key
+	file.URL = ""
+	file.Variants = nil
+	return nil
+}
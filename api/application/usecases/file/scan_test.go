@@ -0,0 +1,269 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/scanner"
+	"github.com/hilthontt/visper/api/infrastructure/storage"
+	"github.com/hilthontt/visper/api/infrastructure/workerpool"
+)
+
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memStorage) Put(_ context.Context, key string, body io.Reader, _ int64, _ string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memStorage) Get(_ context.Context, key string) (*storage.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, storage.ErrObjectNotFound
+	}
+	return &storage.Object{Body: io.NopCloser(bytes.NewReader(data)), Size: int64(len(data))}, nil
+}
+
+func (s *memStorage) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memStorage) SignedURL(_ context.Context, key string, _ time.Duration) (string, error) {
+	return "/d/" + key, nil
+}
+
+func (s *memStorage) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// memFileRepo hands out copies, like a store that serializes records
+type memFileRepo struct {
+	repository.FileRepository
+
+	mu    sync.Mutex
+	files map[string]model.File
+}
+
+func (r *memFileRepo) GetByID(_ context.Context, id string) (*model.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file, ok := r.files[id]
+	if !ok {
+		return nil, storage.ErrObjectNotFound
+	}
+	file.Variants = maps.Clone(file.Variants)
+	return &file, nil
+}
+
+func (r *memFileRepo) Update(_ context.Context, file *model.File) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.files[file.ID]; ok {
+		stored := *file
+		stored.Variants = maps.Clone(file.Variants)
+		r.files[file.ID] = stored
+	}
+	return nil
+}
+
+// hookScanner runs during before it answers, standing in for work that lands mid-scan
+type hookScanner struct {
+	infected bool
+	during   func()
+}
+
+func (s *hookScanner) Scan(_ context.Context, content io.Reader) (*scanner.Verdict, error) {
+	if _, err := io.Copy(io.Discard, content); err != nil {
+		return nil, err
+	}
+	if s.during != nil {
+		s.during()
+	}
+	return &scanner.Verdict{Infected: s.infected, Signature: "Eicar-Test-Signature"}, nil
+}
+
+type scanFixture struct {
+	uc      *fileUseCase
+	storage *memStorage
+	repo    *memFileRepo
+	workers *workerpool.Pool
+	file    *model.File
+}
+
+// newScanFixture stores a pending upload large enough to get a thumbnail
+func newScanFixture(t *testing.T, scan *hookScanner) *scanFixture {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for x := range 400 {
+		img.Set(x, x%300, color.RGBA{R: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := logger.NewDevelopmentLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := workerpool.New(1, 8, log)
+	workers.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		workers.Wait()
+	})
+
+	file := &model.File{ID: "file-1", RoomID: "room-1", Path: "room-1/file-1.png", ScanStatus: model.ScanPending}
+	f := &scanFixture{
+		storage: &memStorage{objects: map[string][]byte{file.Path: buf.Bytes()}},
+		repo:    &memFileRepo{files: map[string]model.File{file.ID: *file}},
+		workers: workers,
+		file:    file,
+	}
+	f.uc = &fileUseCase{fileRepo: f.repo, storage: f.storage, workers: workers, scanner: scan}
+	return f
+}
+
+// drain waits for every task queued so far, the pool has a single worker taking them in order
+func (f *scanFixture) drain(t *testing.T) {
+	t.Helper()
+
+	done := make(chan struct{})
+	f.workers.Submit("drain", func(context.Context) error {
+		close(done)
+		return nil
+	})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker pool didn't drain")
+	}
+}
+
+func (f *scanFixture) stored(t *testing.T) *model.File {
+	t.Helper()
+	file, err := f.repo.GetByID(context.Background(), f.file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func (f *scanFixture) variantObjects() []string {
+	var keys []string
+	for _, key := range f.storage.keys() {
+		if strings.Contains(key, "_") {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestScanFileCleanGeneratesVariants(t *testing.T) {
+	f := newScanFixture(t, &hookScanner{})
+
+	if _, err := f.uc.ScanFile(context.Background(), f.file.ID); err != nil {
+		t.Fatalf("ScanFile() error = %v", err)
+	}
+	f.drain(t)
+
+	stored := f.stored(t)
+	if stored.ScanStatus != model.ScanClean {
+		t.Errorf("scan status = %s, want %s", stored.ScanStatus, model.ScanClean)
+	}
+	if len(stored.Variants) == 0 {
+		t.Fatal("clean file got no variants")
+	}
+	if objects := f.variantObjects(); len(objects) != len(stored.Variants) {
+		t.Errorf("stored variant objects %v, want the %d recorded", objects, len(stored.Variants))
+	}
+}
+
+func TestScanFileInfectedGetsNoVariants(t *testing.T) {
+	f := newScanFixture(t, &hookScanner{infected: true})
+
+	if _, err := f.uc.ScanFile(context.Background(), f.file.ID); err != nil {
+		t.Fatalf("ScanFile() error = %v", err)
+	}
+	f.drain(t)
+
+	stored := f.stored(t)
+	if !stored.IsQuarantined() {
+		t.Errorf("scan status = %s, want %s", stored.ScanStatus, model.ScanInfected)
+	}
+	if len(stored.Variants) != 0 {
+		t.Errorf("quarantined file has variants %v", stored.Variants)
+	}
+	if objects := f.variantObjects(); len(objects) != 0 {
+		t.Errorf("variant objects %v were rendered for an infected file", objects)
+	}
+}
+
+// TestQuarantineRemovesVariantsStoredMidScan renders and records variants after ScanFile read the
+// record but before its verdict, the ordering that used to leave them behind
+func TestQuarantineRemovesVariantsStoredMidScan(t *testing.T) {
+	scan := &hookScanner{infected: true}
+	f := newScanFixture(t, scan)
+	scan.during = func() {
+		pending := *f.file
+		if err := f.uc.generateVariants(context.Background(), &pending); err != nil {
+			t.Errorf("generateVariants() error = %v", err)
+		}
+		if len(f.stored(t).Variants) == 0 {
+			t.Error("generateVariants() recorded no variants")
+		}
+	}
+
+	if _, err := f.uc.ScanFile(context.Background(), f.file.ID); err != nil {
+		t.Fatalf("ScanFile() error = %v", err)
+	}
+	f.drain(t)
+
+	stored := f.stored(t)
+	if !stored.IsQuarantined() {
+		t.Errorf("scan status = %s, want %s", stored.ScanStatus, model.ScanInfected)
+	}
+	if len(stored.Variants) != 0 {
+		t.Errorf("quarantined file has variants %v", stored.Variants)
+	}
+	if objects := f.variantObjects(); len(objects) != 0 {
+		t.Errorf("variant objects %v outlived the quarantine", objects)
+	}
+	if keys := f.storage.keys(); len(keys) != 1 || keys[0] != stored.Path {
+		t.Errorf("storage holds %v, want only the quarantined %s", keys, stored.Path)
+	}
+}
@@ -0,0 +1,84 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/imaging"
+)
+
+// generateVariants renders the resized copies of an upload and records them on the file.
+// ScanFile queues it on the worker pool once the verdict is stored, so clients see variants
+// on a later listing, not in the upload response.
+func (uc *fileUseCase) generateVariants(ctx context.Context, file *model.File) error {
+	object, err := uc.storage.Get(ctx, file.Path)
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	renditions, err := imaging.Render(object.Body, imaging.Variants)
+	if errors.Is(err, imaging.ErrUnsupportedImage) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(renditions) == 0 {
+		return nil
+	}
+
+	variants := make(map[string]string, len(renditions))
+	for _, rendition := range renditions {
+		key := path.Join(file.RoomID, file.ID+"_"+rendition.Variant.Name+rendition.Ext)
+		if err := uc.storage.Put(ctx, key, bytes.NewReader(rendition.Data), int64(len(rendition.Data)), rendition.ContentType); err != nil {
+			return fmt.Errorf("failed to store %s variant: %w", rendition.Variant.Name, err)
+		}
+		variants[rendition.Variant.Name] = key
+	}
+
+	// Reload so the update doesn't race a delete, Update won't bring a deleted file back
+	current, err := uc.fileRepo.GetByID(ctx, file.ID)
+	if err != nil || current.IsQuarantined() {
+		for _, key := range variants {
+			_ = uc.storage.Delete(ctx, key)
+		}
+		return nil
+	}
+
+	current.Variants = variants
+	return uc.fileRepo.Update(ctx, current)
+}
+
+// signLinks replaces the stored URLs with fresh signed ones for the response, quarantined files get none
+func (uc *fileUseCase) signLinks(ctx context.Context, file *model.File) error {
+	if file.IsQuarantined() {
+		file.URL = ""
+		return nil
+	}
+
+	url, err := uc.storage.SignedURL(ctx, file.Path, uc.linkTTL)
+	if err != nil {
+		return fmt.Errorf("failed to sign file link: %w", err)
+	}
+	file.URL = url
+
+	if len(file.Variants) == 0 {
+		return nil
+	}
+
+	file.VariantURLs = make(map[string]string, len(file.Variants))
+	for name, key := range file.Variants {
+		url, err := uc.storage.SignedURL(ctx, key, uc.linkTTL)
+		if err != nil {
+			return fmt.Errorf("failed to sign %s link: %w", name, err)
+		}
+		file.VariantURLs[name] = url
+	}
+
+	return nil
+}
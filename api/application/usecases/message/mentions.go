@@ -0,0 +1,58 @@
+package message
+
+import (
+	"strings"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+// maxMentionsPerMessage caps fan-out so a single message can't page the whole room
+const maxMentionsPerMessage = 20
+
+// parseMentions collects the lowercased @usernames in plaintext content
+func parseMentions(content string) []string {
+	names := make([]string, 0)
+	for _, word := range strings.Fields(content) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+
+		name := strings.TrimRight(strings.TrimPrefix(word, "@"), ".,!?:;")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// resolveMentions keeps the names that belong to someone in the room, never the sender.
+// Usernames aren't unique, so every member answering to a name is mentioned.
+func resolveMentions(room *model.Room, senderID string, names []string) []model.Mention {
+	if room == nil || len(names) == 0 {
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[strings.ToLower(strings.TrimPrefix(name, "@"))] = true
+	}
+
+	members := append([]model.User{room.Owner}, room.Members...)
+	seen := make(map[string]bool, len(members))
+
+	var mentions []model.Mention
+	for _, member := range members {
+		if member.ID == senderID || seen[member.ID] || !wanted[strings.ToLower(member.Username)] {
+			continue
+		}
+		seen[member.ID] = true
+
+		mentions = append(mentions, model.Mention{UserID: member.ID, Username: member.Username})
+		if len(mentions) == maxMentionsPerMessage {
+			break
+		}
+	}
+
+	return mentions
+}
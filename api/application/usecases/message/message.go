@@ -8,10 +8,17 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/domain/apperror"
 	"github.com/hilthontt/visper/api/domain/model"
 	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/activity"
+	"github.com/hilthontt/visper/api/infrastructure/crypto"
 	"github.com/hilthontt/visper/api/infrastructure/events"
+	"github.com/hilthontt/visper/api/infrastructure/linkpreview"
 	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
+	"github.com/hilthontt/visper/api/infrastructure/moderation"
+	"github.com/hilthontt/visper/api/infrastructure/webhook"
 	"go.uber.org/zap"
 )
 
@@ -23,15 +30,36 @@ const (
 	// Default limits
 	defaultMessageLimit = 50
 	maxMessageLimit     = 200
-
-	// Message retention
-	messageRetentionDays = 7
 )
 
+// SlowModeError carries how long the member has to wait before posting again
+type SlowModeError struct {
+	RetryAfter time.Duration
+}
+
+func (e *SlowModeError) Error() string {
+	return "slow mode is enabled, please wait before sending another message"
+}
+
+// ContentBlockedError is returned when the room's content filter is set to block and the message matched
+type ContentBlockedError struct {
+	Terms []string
+}
+
+func (e *ContentBlockedError) Error() string {
+	return "message was blocked by the room's content filter"
+}
+
 type MessageUseCase interface {
 	Delete(ctx context.Context, roomID, messageID, userID string) error
 	Update(ctx context.Context, roomID, messageID, userID, content string, encrypted bool) error
-	Send(ctx context.Context, roomID, userID, username, content string, encrypted bool) (*model.Message, error)
+	Send(ctx context.Context, roomID, userID, username, content string, encrypted bool, keyVersion int, parentMessageID string, mentions []string) (*model.Message, error)
+	Announce(ctx context.Context, roomID, userID, username, content string, encrypted bool) (*model.Message, error)
+	GetAnnouncements(ctx context.Context, roomID string) ([]*model.Message, error)
+	Unpin(ctx context.Context, roomID, messageID, userID string) error
+	GetReplies(ctx context.Context, roomID, parentMessageID string, offset, limit int64) ([]*model.Message, int64, error)
+	GetReplyCount(ctx context.Context, roomID, parentMessageID string) (int64, error)
+	GeneratePreview(ctx context.Context, message *model.Message) (*model.LinkPreview, error)
 	GetRoomMessages(ctx context.Context, roomID string, limit int64) ([]*model.Message, error)
 	GetMessagesAfter(ctx context.Context, roomID string, after time.Time, limit int64) ([]*model.Message, error)
 	GetMessageCount(ctx context.Context, roomID string) (int64, error)
@@ -40,32 +68,62 @@ type MessageUseCase interface {
 }
 
 type messageUseCase struct {
-	repository     repository.MessageRepository
-	eventPublisher *events.EventPublisher
-	logger         *logger.Logger
+	repository      repository.MessageRepository
+	roomRepository  repository.RoomRepository
+	statsRepository repository.StatsRepository
+	muteRepository  repository.MuteRepository
+	slowModeRepo    repository.SlowModeRepository
+	announcements   repository.AnnouncementRepository
+	moderation      *moderation.Pipeline
+	previews        *linkpreview.Fetcher
+	webhooks        *webhook.Dispatcher
+	activity        *activity.RoomTracker
+	eventPublisher  *events.EventPublisher
+	metrics         metrics.Manager
+	logger          *logger.Logger
 }
 
 func NewMessageUseCase(
 	repository repository.MessageRepository,
+	roomRepository repository.RoomRepository,
+	statsRepository repository.StatsRepository,
+	muteRepository repository.MuteRepository,
+	slowModeRepo repository.SlowModeRepository,
+	announcements repository.AnnouncementRepository,
+	moderation *moderation.Pipeline,
+	previews *linkpreview.Fetcher,
+	webhooks *webhook.Dispatcher,
+	activity *activity.RoomTracker,
 	eventPublisher *events.EventPublisher,
+	metrics metrics.Manager,
 	logger *logger.Logger,
 ) MessageUseCase {
 	return &messageUseCase{
-		repository:     repository,
-		eventPublisher: eventPublisher,
-		logger:         logger,
+		repository:      repository,
+		roomRepository:  roomRepository,
+		statsRepository: statsRepository,
+		muteRepository:  muteRepository,
+		slowModeRepo:    slowModeRepo,
+		announcements:   announcements,
+		moderation:      moderation,
+		previews:        previews,
+		webhooks:        webhooks,
+		activity:        activity,
+		eventPublisher:  eventPublisher,
+		metrics:         metrics,
+		logger:          logger,
 	}
 }
 
 func (uc *messageUseCase) Update(ctx context.Context, roomID, messageID, userID, content string, encrypted bool) error {
 	if roomID == "" {
-		return fmt.Errorf("room ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 	if messageID == "" {
-		return fmt.Errorf("message ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("message ID cannot be empty")
 	}
 	if userID == "" {
-		return fmt.Errorf("user ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
 	}
 
 	// Validate message content
@@ -76,16 +134,30 @@ func (uc *messageUseCase) Update(ctx context.Context, roomID, messageID, userID,
 	existingMessage, err := uc.repository.GetByID(ctx, roomID, messageID)
 	if err != nil {
 		uc.logger.Error("failed to get message for update", zap.Error(err), zap.String("messageID", messageID))
-		return fmt.Errorf("message not found: %w", err)
+		return apperror.ErrMessageNotFound
 	}
 
 	// Verify the user owns this message
 	if existingMessage.UserID != userID {
-		return fmt.Errorf("unauthorized: you can only edit your own messages")
+		return apperror.ErrNotAuthor.WithMessage("unauthorized: you can only edit your own messages")
+	}
+
+	keyVersion := 0
+	if encrypted {
+		room, _ := uc.roomRepository.GetByID(ctx, roomID)
+		if keyVersion, err = sealedWith(room, content, 0); err != nil {
+			return err
+		}
+	}
+
+	// A preview for a link that was edited out would be misleading
+	if existingMessage.Preview != nil && (encrypted || linkpreview.FirstURL(content) != existingMessage.Preview.URL) {
+		existingMessage.Preview = nil
 	}
 
 	existingMessage.Content = strings.TrimSpace(content)
 	existingMessage.Encrypted = encrypted
+	existingMessage.KeyVersion = keyVersion
 	existingMessage.UpdatedAt = time.Now()
 
 	if err := uc.repository.Update(ctx, existingMessage); err != nil {
@@ -93,6 +165,10 @@ func (uc *messageUseCase) Update(ctx context.Context, roomID, messageID, userID,
 		return fmt.Errorf("failed to update message: %w", err)
 	}
 
+	if existingMessage.IsAnnouncement() {
+		uc.refreshPin(ctx, existingMessage)
+	}
+
 	uc.logger.Info("message updated",
 		zap.String("messageID", messageID),
 		zap.String("roomID", roomID),
@@ -103,23 +179,23 @@ func (uc *messageUseCase) Update(ctx context.Context, roomID, messageID, userID,
 
 func (uc *messageUseCase) Delete(ctx context.Context, roomID, messageID, userID string) error {
 	if roomID == "" {
-		return fmt.Errorf("room ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 	if messageID == "" {
-		return fmt.Errorf("message ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("message ID cannot be empty")
 	}
 	if userID == "" {
-		return fmt.Errorf("user ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
 	}
 
 	existingMessage, err := uc.repository.GetByID(ctx, roomID, messageID)
 	if err != nil {
 		uc.logger.Error("failed to get message for deletion", zap.Error(err), zap.String("messageID", messageID))
-		return fmt.Errorf("message not found: %w", err)
+		return apperror.ErrMessageNotFound
 	}
 
 	if existingMessage.UserID != userID {
-		return fmt.Errorf("unauthorized: you can only delete your own messages")
+		return apperror.ErrNotAuthor.WithMessage("unauthorized: you can only delete your own messages")
 	}
 
 	if err := uc.repository.Delete(ctx, roomID, messageID); err != nil {
@@ -127,6 +203,12 @@ func (uc *messageUseCase) Delete(ctx context.Context, roomID, messageID, userID
 		return fmt.Errorf("failed to delete message: %w", err)
 	}
 
+	if existingMessage.IsAnnouncement() {
+		if _, err := uc.announcements.Unpin(ctx, roomID, messageID); err != nil {
+			uc.logger.Warn("failed to unpin deleted announcement", zap.Error(err), zap.String("messageID", messageID))
+		}
+	}
+
 	uc.logger.Info("message deleted",
 		zap.String("messageID", messageID),
 		zap.String("roomID", roomID),
@@ -140,11 +222,18 @@ func (uc *messageUseCase) CleanupAllOldMessages(ctx context.Context, roomIDs []s
 		return nil
 	}
 
-	cutoffTime := time.Now().Add(-messageRetentionDays * 24 * time.Hour)
 	errorCount := 0
 	successCount := 0
+	skippedCount := 0
 
 	for _, roomID := range roomIDs {
+		retention, ok := uc.retentionFor(ctx, roomID)
+		if !ok {
+			skippedCount++
+			continue
+		}
+
+		cutoffTime := time.Now().Add(-retention)
 		if err := uc.repository.DeleteOldMessages(ctx, roomID, cutoffTime); err != nil {
 			uc.logger.Error("failed to cleanup messages for room", zap.Error(err), zap.String("roomID", roomID))
 			errorCount++
@@ -156,8 +245,8 @@ func (uc *messageUseCase) CleanupAllOldMessages(ctx context.Context, roomIDs []s
 	uc.logger.Info("bulk message cleanup completed",
 		zap.Int("totalRooms", len(roomIDs)),
 		zap.Int("successful", successCount),
-		zap.Int("failed", errorCount),
-		zap.Time("cutoffTime", cutoffTime))
+		zap.Int("skipped", skippedCount),
+		zap.Int("failed", errorCount))
 
 	if errorCount > 0 {
 		return fmt.Errorf("cleanup partially failed: %d/%d rooms had errors", errorCount, len(roomIDs))
@@ -168,10 +257,16 @@ func (uc *messageUseCase) CleanupAllOldMessages(ctx context.Context, roomIDs []s
 
 func (uc *messageUseCase) CleanupOldMessages(ctx context.Context, roomID string) error {
 	if roomID == "" {
-		return fmt.Errorf("room ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
-	cutoffTime := time.Now().Add(-messageRetentionDays * 24 * time.Hour)
+	retention, ok := uc.retentionFor(ctx, roomID)
+	if !ok {
+		uc.logger.Debug("room keeps messages until deleted, skipping cleanup", zap.String("roomID", roomID))
+		return nil
+	}
+
+	cutoffTime := time.Now().Add(-retention)
 
 	if err := uc.repository.DeleteOldMessages(ctx, roomID, cutoffTime); err != nil {
 		uc.logger.Error("failed to cleanup old messages", zap.Error(err), zap.String("roomID", roomID))
@@ -182,9 +277,20 @@ func (uc *messageUseCase) CleanupOldMessages(ctx context.Context, roomID string)
 	return nil
 }
 
+// retentionFor reports false when the room's messages must be kept until it is deleted.
+// A room that can't be loaded gets the default, so its messages still age out.
+func (uc *messageUseCase) retentionFor(ctx context.Context, roomID string) (time.Duration, bool) {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return model.DefaultMessageRetention, true
+	}
+
+	return room.Settings.Retention()
+}
+
 func (uc *messageUseCase) GetMessageCount(ctx context.Context, roomID string) (int64, error) {
 	if roomID == "" {
-		return 0, fmt.Errorf("room ID cannot be empty")
+		return 0, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
 	count, err := uc.repository.Count(ctx, roomID)
@@ -198,7 +304,7 @@ func (uc *messageUseCase) GetMessageCount(ctx context.Context, roomID string) (i
 
 func (uc *messageUseCase) GetMessagesAfter(ctx context.Context, roomID string, after time.Time, limit int64) ([]*model.Message, error) {
 	if roomID == "" {
-		return nil, fmt.Errorf("room ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
 	limit = uc.normalizeLimit(limit)
@@ -215,7 +321,7 @@ func (uc *messageUseCase) GetMessagesAfter(ctx context.Context, roomID string, a
 
 func (uc *messageUseCase) GetRoomMessages(ctx context.Context, roomID string, limit int64) ([]*model.Message, error) {
 	if roomID == "" {
-		return nil, fmt.Errorf("room ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
 	limit = uc.normalizeLimit(limit)
@@ -237,15 +343,18 @@ func (uc *messageUseCase) Send(
 	username string,
 	content string,
 	encrypted bool,
+	keyVersion int,
+	parentMessageID string,
+	mentions []string,
 ) (*model.Message, error) {
 	if roomID == "" {
-		return nil, fmt.Errorf("room ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 	if userID == "" {
-		return nil, fmt.Errorf("user ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
 	}
 	if username == "" {
-		return nil, fmt.Errorf("username cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("username cannot be empty")
 	}
 
 	// Validate message content
@@ -253,14 +362,67 @@ func (uc *messageUseCase) Send(
 		return nil, err
 	}
 
+	// Fail open, a Redis hiccup shouldn't silence the whole room
+	if _, err := uc.muteRepository.Get(ctx, roomID, userID); err == nil {
+		return nil, apperror.ErrMuted
+	}
+
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err == nil && room != nil {
+		if !room.CanPost(userID) {
+			return nil, apperror.ErrRoomReadOnly
+		}
+
+		if err := uc.checkSlowMode(ctx, room, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if encrypted {
+		if keyVersion, err = sealedWith(room, content, keyVersion); err != nil {
+			return nil, err
+		}
+	} else {
+		keyVersion = 0
+	}
+
+	var flagged []string
+	if room != nil && !encrypted {
+		flagged, err = uc.moderate(ctx, room, userID, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if parentMessageID != "" {
+		parent, err := uc.repository.GetByID(ctx, roomID, parentMessageID)
+		if err != nil {
+			return nil, apperror.ErrMessageNotFound.WithMessage("parent message not found")
+		}
+
+		// Threads are a single level deep, replying to a reply joins the same thread
+		if parent.ParentMessageID != "" {
+			parentMessageID = parent.ParentMessageID
+		}
+	}
+
+	// Encrypted content is opaque to the server, so those senders declare their mentions
+	if !encrypted {
+		mentions = parseMentions(content)
+	}
+
 	message := &model.Message{
-		ID:        uuid.NewString(),
-		RoomID:    roomID,
-		UserID:    userID,
-		Username:  username,
-		Content:   strings.TrimSpace(content),
-		Encrypted: encrypted,
-		CreatedAt: time.Now(),
+		ID:              uuid.NewString(),
+		RoomID:          roomID,
+		UserID:          userID,
+		Username:        username,
+		Content:         strings.TrimSpace(content),
+		Encrypted:       encrypted,
+		KeyVersion:      keyVersion,
+		CreatedAt:       time.Now(),
+		ParentMessageID: parentMessageID,
+		Mentions:        resolveMentions(room, userID, mentions),
+		Flagged:         len(flagged) > 0,
 	}
 
 	if err := uc.repository.Create(ctx, message); err != nil {
@@ -268,9 +430,20 @@ func (uc *messageUseCase) Send(
 		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
 
+	if err := uc.statsRepository.IncrementMessages(ctx); err != nil {
+		uc.logger.Warn("failed to record message stats", zap.Error(err))
+	}
+	uc.activity.RecordMessage(ctx, roomID, len(message.Content))
+
+	if message.Flagged {
+		uc.publishFlagged(ctx, room, userID, message.ID, flagged)
+	}
+
+	uc.webhooks.Dispatch(room, model.WebhookMessageSent, message)
+
 	go func() {
 		messageSize := len(message.Content)
-		if err := uc.eventPublisher.PublishMessageSent(roomID, userID, message.ID, messageSize); err != nil {
+		if err := uc.eventPublisher.PublishMessageSent(ctx, roomID, userID, message.ID, messageSize); err != nil {
 			log.Printf("Failed to publish message sent event: %v", err)
 		}
 	}()
@@ -279,24 +452,269 @@ func (uc *messageUseCase) Send(
 	return message, nil
 }
 
+// Announce skips mute, read-only and slow mode since only the owner can announce
+func (uc *messageUseCase) Announce(ctx context.Context, roomID, userID, username, content string, encrypted bool) (*model.Message, error) {
+	if err := uc.validateMessageContent(content); err != nil {
+		return nil, err
+	}
+
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != userID {
+		uc.logger.Warn("unauthorized announcement attempt", zap.String("roomID", roomID), zap.String("userID", userID))
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can post announcements")
+	}
+
+	keyVersion := 0
+	if encrypted {
+		if keyVersion, err = sealedWith(room, content, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	message := &model.Message{
+		ID:         uuid.NewString(),
+		RoomID:     roomID,
+		UserID:     userID,
+		Username:   username,
+		Content:    strings.TrimSpace(content),
+		Encrypted:  encrypted,
+		KeyVersion: keyVersion,
+		Type:       model.MessageTypeAnnouncement,
+	}
+
+	if err := uc.repository.Create(ctx, message); err != nil {
+		uc.logger.Error("failed to create announcement", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	// The message itself is stored, a failed pin only hides it from late joiners
+	if err := uc.announcements.Pin(ctx, message, roomExpiresAt(room)); err != nil {
+		uc.logger.Error("failed to pin announcement", zap.Error(err), zap.String("roomID", roomID), zap.String("messageID", message.ID))
+	}
+
+	if err := uc.statsRepository.IncrementMessages(ctx); err != nil {
+		uc.logger.Warn("failed to record message stats", zap.Error(err))
+	}
+	uc.activity.RecordMessage(ctx, roomID, len(message.Content))
+
+	uc.logger.Info("announcement posted", zap.String("roomID", roomID), zap.String("messageID", message.ID))
+	return message, nil
+}
+
+func (uc *messageUseCase) GetAnnouncements(ctx context.Context, roomID string) ([]*model.Message, error) {
+	if roomID == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
+	}
+
+	announcements, err := uc.announcements.GetAll(ctx, roomID)
+	if err != nil {
+		uc.logger.Error("failed to get announcements", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to get announcements: %w", err)
+	}
+
+	return announcements, nil
+}
+
+// Unpin keeps the message in history, it only stops being an active announcement
+func (uc *messageUseCase) Unpin(ctx context.Context, roomID, messageID, userID string) error {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != userID {
+		return apperror.ErrNotOwner.WithMessage("only the room owner can post announcements")
+	}
+
+	removed, err := uc.announcements.Unpin(ctx, roomID, messageID)
+	if err != nil {
+		uc.logger.Error("failed to unpin announcement", zap.Error(err), zap.String("roomID", roomID), zap.String("messageID", messageID))
+		return fmt.Errorf("failed to unpin announcement: %w", err)
+	}
+
+	if !removed {
+		return apperror.ErrAnnouncementNotFound
+	}
+
+	return nil
+}
+
+func (uc *messageUseCase) refreshPin(ctx context.Context, message *model.Message) {
+	room, err := uc.roomRepository.GetByID(ctx, message.RoomID)
+	if err != nil || room == nil {
+		return
+	}
+
+	if err := uc.announcements.Pin(ctx, message, roomExpiresAt(room)); err != nil {
+		uc.logger.Warn("failed to refresh pinned announcement", zap.Error(err), zap.String("messageID", message.ID))
+	}
+}
+
+// roomExpiresAt is zero for rooms that never expire
+func roomExpiresAt(room *model.Room) time.Time {
+	if room.Expiry <= 0 {
+		return time.Time{}
+	}
+	return room.CreatedAt.Add(room.Expiry)
+}
+
+func (uc *messageUseCase) GetReplies(ctx context.Context, roomID, parentMessageID string, offset, limit int64) ([]*model.Message, int64, error) {
+	if roomID == "" {
+		return nil, 0, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
+	}
+	if parentMessageID == "" {
+		return nil, 0, apperror.ErrInvalidInput.WithMessage("message ID cannot be empty")
+	}
+
+	if _, err := uc.repository.GetByID(ctx, roomID, parentMessageID); err != nil {
+		return nil, 0, apperror.ErrMessageNotFound
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	limit = uc.normalizeLimit(limit)
+
+	replies, total, err := uc.repository.GetReplies(ctx, roomID, parentMessageID, offset, limit)
+	if err != nil {
+		uc.logger.Error("failed to get replies", zap.Error(err), zap.String("roomID", roomID), zap.String("parentMessageID", parentMessageID))
+		return nil, 0, fmt.Errorf("failed to retrieve replies: %w", err)
+	}
+
+	uc.logger.Debug("retrieved replies", zap.String("roomID", roomID), zap.String("parentMessageID", parentMessageID), zap.Int("count", len(replies)))
+	return replies, total, nil
+}
+
+func (uc *messageUseCase) GetReplyCount(ctx context.Context, roomID, parentMessageID string) (int64, error) {
+	_, total, err := uc.repository.GetReplies(ctx, roomID, parentMessageID, 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get reply count: %w", err)
+	}
+	return total, nil
+}
+
+// The owner is exempt, and a Redis error lets the message through rather than blocking the room
+func (uc *messageUseCase) checkSlowMode(ctx context.Context, room *model.Room, userID string) error {
+	if room.Settings.SlowModeSeconds <= 0 || room.Owner.ID == userID {
+		return nil
+	}
+
+	interval := time.Duration(room.Settings.SlowModeSeconds) * time.Second
+	retryAfter, err := uc.slowModeRepo.Acquire(ctx, room.ID, userID, interval)
+	if err != nil {
+		uc.logger.Warn("failed to check slow mode", zap.Error(err), zap.String("roomID", room.ID), zap.String("userID", userID))
+		return nil
+	}
+
+	if retryAfter > 0 {
+		return &SlowModeError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// GeneratePreview fetches metadata for the first link in the message and stores it on the message.
+// It returns nil without an error when there is nothing to preview.
+func (uc *messageUseCase) GeneratePreview(ctx context.Context, message *model.Message) (*model.LinkPreview, error) {
+	// The server can't read encrypted content, and fetching would leak the link anyway
+	if message.Encrypted {
+		return nil, nil
+	}
+
+	link := linkpreview.FirstURL(message.Content)
+	if link == "" {
+		return nil, nil
+	}
+
+	preview, err := uc.previews.Fetch(ctx, link)
+	if err != nil {
+		uc.logger.Debug("link preview unavailable", zap.Error(err), zap.String("messageID", message.ID))
+		return nil, nil
+	}
+
+	if err := uc.repository.SetPreview(ctx, message.RoomID, message.ID, preview); err != nil {
+		uc.logger.Warn("failed to store link preview", zap.Error(err), zap.String("messageID", message.ID))
+		return nil, fmt.Errorf("failed to store link preview: %w", err)
+	}
+
+	return preview, nil
+}
+
+// moderate returns the matched terms when the room only flags, and an error when it blocks.
+// Encrypted content is opaque to the server and never reaches here.
+func (uc *messageUseCase) moderate(ctx context.Context, room *model.Room, userID, content string) ([]string, error) {
+	mode := room.Settings.ModerationMode()
+	if mode == model.ModerationOff {
+		return nil, nil
+	}
+
+	verdict, err := uc.moderation.Check(ctx, content)
+	if err != nil {
+		// Fail open like the other room rules, a broken filter shouldn't silence the room
+		uc.logger.Warn("content filter failed", zap.Error(err), zap.String("roomID", room.ID))
+	}
+
+	if !verdict.Flagged {
+		return nil, nil
+	}
+
+	uc.metrics.IncrementCounter(ctx, "messages_flagged", "mode", string(mode))
+
+	if mode == model.ModerationBlock {
+		uc.publishFlagged(ctx, room, userID, "", verdict.Terms)
+		uc.logger.Info("message blocked by content filter", zap.String("roomID", room.ID), zap.String("userID", userID))
+		return nil, &ContentBlockedError{Terms: verdict.Terms}
+	}
+
+	return verdict.Terms, nil
+}
+
+func (uc *messageUseCase) publishFlagged(ctx context.Context, room *model.Room, userID, messageID string, terms []string) {
+	mode := string(room.Settings.ModerationMode())
+	go func() {
+		if err := uc.eventPublisher.PublishMessageFlagged(ctx, room.ID, userID, messageID, mode, terms); err != nil {
+			log.Printf("Failed to publish message flagged event: %v", err)
+		}
+	}()
+}
+
 func (uc *messageUseCase) validateMessageContent(content string) error {
 	trimmed := strings.TrimSpace(content)
 
 	if len(trimmed) < minMessageLength {
-		return fmt.Errorf("message cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("message cannot be empty")
 	}
 
 	if len(trimmed) > maxMessageLength {
-		return fmt.Errorf("message cannot exceed %d characters (got %d)", maxMessageLength, len(trimmed))
+		return apperror.ErrInvalidInput.WithMessage(fmt.Sprintf("message cannot exceed %d characters (got %d)", maxMessageLength, len(trimmed)))
 	}
 
 	if isOnlyWhitespace(trimmed) {
-		return fmt.Errorf("message cannot contain only whitespace")
+		return apperror.ErrInvalidInput.WithMessage("message cannot contain only whitespace")
 	}
 
 	return nil
 }
 
+// sealedWith checks encrypted content is ciphertext and returns the key version to store it under.
+// Zero means the client didn't say, older clients don't, and is taken to be the current key.
+func sealedWith(room *model.Room, content string, keyVersion int) (int, error) {
+	if !crypto.IsCiphertext(strings.TrimSpace(content)) {
+		return 0, apperror.ErrInvalidCiphertext
+	}
+	if room == nil {
+		return keyVersion, nil
+	}
+	if keyVersion != 0 && keyVersion != room.KeyVersion {
+		return 0, apperror.ErrStaleRoomKey
+	}
+	return room.KeyVersion, nil
+}
+
 func (uc *messageUseCase) normalizeLimit(limit int64) int64 {
 	if limit <= 0 {
 		return defaultMessageLimit
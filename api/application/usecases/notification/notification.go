@@ -0,0 +1,267 @@
+package notification
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
+	"github.com/hilthontt/visper/api/infrastructure/push"
+	"go.uber.org/zap"
+)
+
+const (
+	maxSubscriptionsPerUser = 10
+
+	// Members who never picked a level only hear about mentions
+	defaultNotificationLevel = model.NotificationLevelMentions
+)
+
+// PresenceFunc reports whether a user already sees the room live over a WebSocket
+type PresenceFunc func(roomID, userID string) bool
+
+type NotificationUseCase interface {
+	RegisterSubscription(ctx context.Context, subscription *model.PushSubscription) (*model.PushSubscription, error)
+	ListSubscriptions(ctx context.Context, userID string) ([]*model.PushSubscription, error)
+	DeleteSubscription(ctx context.Context, userID, subscriptionID string) error
+	GetRoomPreference(ctx context.Context, roomID, userID string) (model.NotificationLevel, error)
+	SetRoomPreference(ctx context.Context, roomID, userID string, level model.NotificationLevel) error
+	NotifyNewMessage(ctx context.Context, message *model.Message)
+}
+
+type notificationUseCase struct {
+	subscriptionRepository repository.PushSubscriptionRepository
+	preferenceRepository   repository.NotificationPreferenceRepository
+	roomRepository         repository.RoomRepository
+	dispatcher             *push.Dispatcher
+	isOnline               PresenceFunc
+	metrics                metrics.Manager
+	logger                 *logger.Logger
+}
+
+func NewNotificationUseCase(
+	subscriptionRepository repository.PushSubscriptionRepository,
+	preferenceRepository repository.NotificationPreferenceRepository,
+	roomRepository repository.RoomRepository,
+	dispatcher *push.Dispatcher,
+	isOnline PresenceFunc,
+	metrics metrics.Manager,
+	logger *logger.Logger,
+) NotificationUseCase {
+	return &notificationUseCase{
+		subscriptionRepository: subscriptionRepository,
+		preferenceRepository:   preferenceRepository,
+		roomRepository:         roomRepository,
+		dispatcher:             dispatcher,
+		isOnline:               isOnline,
+		metrics:                metrics,
+		logger:                 logger,
+	}
+}
+
+func (uc *notificationUseCase) RegisterSubscription(ctx context.Context, subscription *model.PushSubscription) (*model.PushSubscription, error) {
+	if !uc.dispatcher.Supports(subscription.Platform) {
+		return nil, apperror.ErrPushPlatformUnsupported
+	}
+
+	var identity string
+	switch subscription.Platform {
+	case push.PlatformWebPush:
+		if subscription.Endpoint == "" || subscription.P256dh == "" || subscription.Auth == "" {
+			return nil, apperror.ErrInvalidInput.WithMessage("endpoint, p256dh and auth are required for web push")
+		}
+		if err := push.ValidateWebPushEndpoint(subscription.Endpoint); err != nil {
+			return nil, apperror.ErrInvalidInput.WithMessage("push endpoint must be an https URL of a known push service")
+		}
+		identity = subscription.Endpoint
+	case push.PlatformFCM:
+		if subscription.Token == "" {
+			return nil, apperror.ErrInvalidInput.WithMessage("token is required for fcm")
+		}
+		identity = subscription.Token
+	}
+
+	// Derive the ID from the device so re-registering overwrites instead of piling up
+	hash := sha256.Sum256([]byte(subscription.Platform + ":" + identity))
+	subscription.ID = hex.EncodeToString(hash[:16])
+	subscription.CreatedAt = time.Now()
+
+	existing, err := uc.subscriptionRepository.GetByUserID(ctx, subscription.UserID)
+	if err != nil {
+		uc.logger.Error("failed to get push subscriptions", zap.Error(err), zap.String("userID", subscription.UserID))
+		return nil, fmt.Errorf("failed to register subscription: %w", err)
+	}
+
+	known := false
+	for _, s := range existing {
+		if s.ID == subscription.ID {
+			known = true
+			break
+		}
+	}
+	if !known && len(existing) >= maxSubscriptionsPerUser {
+		return nil, apperror.ErrLimitReached.WithMessage("too many push subscriptions")
+	}
+
+	if err := uc.subscriptionRepository.Save(ctx, subscription); err != nil {
+		uc.logger.Error("failed to save push subscription", zap.Error(err), zap.String("userID", subscription.UserID))
+		return nil, fmt.Errorf("failed to register subscription: %w", err)
+	}
+
+	uc.logger.Info("push subscription registered",
+		zap.String("userID", subscription.UserID),
+		zap.String("platform", subscription.Platform))
+
+	return subscription, nil
+}
+
+func (uc *notificationUseCase) ListSubscriptions(ctx context.Context, userID string) ([]*model.PushSubscription, error) {
+	return uc.subscriptionRepository.GetByUserID(ctx, userID)
+}
+
+func (uc *notificationUseCase) DeleteSubscription(ctx context.Context, userID, subscriptionID string) error {
+	if err := uc.subscriptionRepository.Delete(ctx, userID, subscriptionID); err != nil {
+		uc.logger.Error("failed to delete push subscription", zap.Error(err), zap.String("userID", userID))
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	return nil
+}
+
+func (uc *notificationUseCase) GetRoomPreference(ctx context.Context, roomID, userID string) (model.NotificationLevel, error) {
+	if _, err := uc.getMemberRoom(ctx, roomID, userID); err != nil {
+		return "", err
+	}
+
+	level, err := uc.preferenceRepository.Get(ctx, userID, roomID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get notification preference: %w", err)
+	}
+
+	if level == "" {
+		return defaultNotificationLevel, nil
+	}
+	return level, nil
+}
+
+func (uc *notificationUseCase) SetRoomPreference(ctx context.Context, roomID, userID string, level model.NotificationLevel) error {
+	if !level.IsValid() {
+		return apperror.ErrInvalidInput.WithMessage("invalid notification level")
+	}
+
+	if _, err := uc.getMemberRoom(ctx, roomID, userID); err != nil {
+		return err
+	}
+
+	if err := uc.preferenceRepository.Set(ctx, userID, roomID, level); err != nil {
+		uc.logger.Error("failed to set notification preference", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return fmt.Errorf("failed to set notification preference: %w", err)
+	}
+
+	return nil
+}
+
+// NotifyNewMessage pushes to offline members, it is meant to run in the background
+func (uc *notificationUseCase) NotifyNewMessage(ctx context.Context, message *model.Message) {
+	room, err := uc.roomRepository.GetByID(ctx, message.RoomID)
+	if err != nil {
+		uc.logger.Warn("skipping push for missing room", zap.String("roomID", message.RoomID))
+		return
+	}
+
+	// Content never leaves the server, push services only see who wrote in which room
+	mentioned := make(map[string]bool, len(message.Mentions))
+	for _, mention := range message.Mentions {
+		mentioned[mention.UserID] = true
+	}
+
+	for _, member := range room.Members {
+		if member.ID == message.UserID || uc.isOnline(room.ID, member.ID) {
+			continue
+		}
+
+		isMention := mentioned[member.ID]
+		if !uc.wantsNotification(ctx, room.ID, member.ID, isMention) {
+			continue
+		}
+
+		notification := &push.Notification{
+			Title:  "New message",
+			Body:   fmt.Sprintf("%s sent a message", message.Username),
+			RoomID: room.ID,
+			Data:   map[string]string{"type": "message"},
+		}
+		if isMention {
+			notification.Title = "New mention"
+			notification.Body = fmt.Sprintf("%s mentioned you", message.Username)
+			notification.Data["type"] = "mention"
+		}
+
+		uc.deliver(ctx, member.ID, notification)
+	}
+}
+
+func (uc *notificationUseCase) wantsNotification(ctx context.Context, roomID, userID string, isMention bool) bool {
+	level, err := uc.preferenceRepository.Get(ctx, userID, roomID)
+	if err != nil {
+		uc.logger.Warn("failed to get notification preference", zap.Error(err), zap.String("userID", userID))
+		return false
+	}
+
+	if level == "" {
+		level = defaultNotificationLevel
+	}
+
+	switch level {
+	case model.NotificationLevelAll:
+		return true
+	case model.NotificationLevelMentions:
+		return isMention
+	default:
+		return false
+	}
+}
+
+func (uc *notificationUseCase) deliver(ctx context.Context, userID string, notification *push.Notification) {
+	subscriptions, err := uc.subscriptionRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		uc.logger.Warn("failed to get push subscriptions", zap.Error(err), zap.String("userID", userID))
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		err := uc.dispatcher.Send(ctx, subscription, notification)
+		switch {
+		case err == nil:
+			uc.metrics.IncrementCounter(ctx, "push_notifications_sent", "platform", subscription.Platform)
+		case errors.Is(err, push.ErrSubscriptionGone):
+			uc.metrics.IncrementCounter(ctx, "push_subscriptions_expired", "platform", subscription.Platform)
+			_ = uc.subscriptionRepository.Delete(ctx, userID, subscription.ID)
+		default:
+			uc.metrics.IncrementCounter(ctx, "push_notifications_failed", "platform", subscription.Platform)
+			uc.logger.Warn("failed to deliver push notification",
+				zap.Error(err),
+				zap.String("userID", userID),
+				zap.String("platform", subscription.Platform))
+		}
+	}
+}
+
+func (uc *notificationUseCase) getMemberRoom(ctx context.Context, roomID, userID string) (*model.Room, error) {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if !room.IsMember(userID) {
+		return nil, apperror.ErrNotMember
+	}
+
+	return room, nil
+}
@@ -0,0 +1,258 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/events"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/storage"
+	"go.uber.org/zap"
+)
+
+type PrivacyUseCase interface {
+	PurgeUserData(ctx context.Context, userID string) (*PurgeSummary, error)
+}
+
+// PurgeSummary reports what was removed, Incomplete is set when some data could not be deleted
+type PurgeSummary struct {
+	UserID           string
+	MessagesDeleted  int
+	FilesDeleted     int
+	BotsRevoked      int
+	IdentityUnlinked bool
+	RoomsLeft        []string
+	RoomsDeleted     []string
+	UsernameReleased bool
+	Incomplete       bool
+	PurgedAt         time.Time
+}
+
+type privacyUseCase struct {
+	userRepository         repository.UserRepository
+	roomRepository         repository.RoomRepository
+	messageRepository      repository.MessageRepository
+	fileRepository         repository.FileRepository
+	announcementRepository repository.AnnouncementRepository
+	botRepository          repository.BotRepository
+	identityRepository     repository.IdentityRepository
+	storage                storage.Storage
+	eventPublisher         *events.EventPublisher
+	logger                 *logger.Logger
+}
+
+func NewPrivacyUseCase(
+	userRepository repository.UserRepository,
+	roomRepository repository.RoomRepository,
+	messageRepository repository.MessageRepository,
+	fileRepository repository.FileRepository,
+	announcementRepository repository.AnnouncementRepository,
+	botRepository repository.BotRepository,
+	identityRepository repository.IdentityRepository,
+	fileStorage storage.Storage,
+	eventPublisher *events.EventPublisher,
+	logger *logger.Logger,
+) PrivacyUseCase {
+	return &privacyUseCase{
+		userRepository:         userRepository,
+		roomRepository:         roomRepository,
+		messageRepository:      messageRepository,
+		fileRepository:         fileRepository,
+		announcementRepository: announcementRepository,
+		botRepository:          botRepository,
+		identityRepository:     identityRepository,
+		storage:                fileStorage,
+		eventPublisher:         eventPublisher,
+		logger:                 logger,
+	}
+}
+
+// PurgeUserData removes the user's messages, files, bots, account link, memberships and
+// username index.
+// Rooms the user owns are deleted outright, since they can't outlive their owner.
+// Each step is best effort so one failing repository doesn't leave the rest behind.
+func (uc *privacyUseCase) PurgeUserData(ctx context.Context, userID string) (*PurgeSummary, error) {
+	if userID == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
+	}
+
+	rooms, err := uc.roomRepository.GetAll(ctx)
+	if err != nil {
+		uc.logger.Error("failed to list rooms for purge", zap.Error(err), zap.String("userID", userID))
+		return nil, fmt.Errorf("failed to purge user data: %w", err)
+	}
+
+	summary := &PurgeSummary{
+		UserID:       userID,
+		RoomsLeft:    make([]string, 0),
+		RoomsDeleted: make([]string, 0),
+	}
+
+	for _, room := range rooms {
+		if room == nil {
+			continue
+		}
+
+		if room.Owner.ID == userID {
+			uc.deleteOwnedRoom(ctx, room, summary)
+			continue
+		}
+
+		deleted, err := uc.messageRepository.DeleteByUser(ctx, room.ID, userID)
+		if err != nil {
+			uc.logger.Error("failed to delete user messages", zap.Error(err), zap.String("roomID", room.ID), zap.String("userID", userID))
+			summary.Incomplete = true
+		}
+		summary.MessagesDeleted += deleted
+
+		if !room.IsMember(userID) {
+			continue
+		}
+
+		if err := uc.roomRepository.RemoveUser(ctx, room.ID, userID); err != nil {
+			uc.logger.Error("failed to remove user from room", zap.Error(err), zap.String("roomID", room.ID), zap.String("userID", userID))
+			summary.Incomplete = true
+			continue
+		}
+		summary.RoomsLeft = append(summary.RoomsLeft, room.ID)
+	}
+
+	files, err := uc.fileRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to list user files", zap.Error(err), zap.String("userID", userID))
+		summary.Incomplete = true
+	}
+	for _, file := range files {
+		if uc.deleteFile(ctx, file) {
+			summary.FilesDeleted++
+		} else {
+			summary.Incomplete = true
+		}
+	}
+
+	bots, err := uc.botRepository.GetByOwner(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to list user bots", zap.Error(err), zap.String("userID", userID))
+		summary.Incomplete = true
+	}
+	for _, bot := range bots {
+		if err := uc.botRepository.Delete(ctx, bot); err != nil {
+			uc.logger.Error("failed to revoke bot", zap.Error(err), zap.String("botID", bot.ID))
+			summary.Incomplete = true
+			continue
+		}
+		summary.BotsRevoked++
+	}
+
+	identity, err := uc.identityRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to get user identity", zap.Error(err), zap.String("userID", userID))
+		summary.Incomplete = true
+	} else if identity != nil {
+		if err := uc.identityRepository.Delete(ctx, identity); err != nil {
+			uc.logger.Error("failed to unlink identity", zap.Error(err), zap.String("userID", userID))
+			summary.Incomplete = true
+		} else {
+			summary.IdentityUnlinked = true
+		}
+	}
+
+	user, err := uc.userRepository.GetByID(ctx, userID)
+	if err == nil {
+		released, err := uc.userRepository.DeleteUsernameIndex(ctx, user.Username, userID)
+		if err != nil {
+			uc.logger.Error("failed to delete username index", zap.Error(err), zap.String("userID", userID))
+			summary.Incomplete = true
+		}
+		summary.UsernameReleased = released
+	}
+
+	if err := uc.userRepository.Delete(ctx, userID); err != nil {
+		uc.logger.Error("failed to delete user", zap.Error(err), zap.String("userID", userID))
+		summary.Incomplete = true
+	}
+
+	summary.PurgedAt = time.Now()
+
+	go func() {
+		if err := uc.eventPublisher.PublishUserPurged(ctx, userID, map[string]any{
+			"messages_deleted":  summary.MessagesDeleted,
+			"files_deleted":     summary.FilesDeleted,
+			"bots_revoked":      summary.BotsRevoked,
+			"identity_unlinked": summary.IdentityUnlinked,
+			"rooms_left":        len(summary.RoomsLeft),
+			"rooms_deleted":     len(summary.RoomsDeleted),
+			"username_released": summary.UsernameReleased,
+			"incomplete":        summary.Incomplete,
+		}); err != nil {
+			log.Printf("Failed to publish user purged event: %v", err)
+		}
+	}()
+
+	uc.logger.Info("user data purged",
+		zap.String("userID", userID),
+		zap.Int("messagesDeleted", summary.MessagesDeleted),
+		zap.Int("filesDeleted", summary.FilesDeleted),
+		zap.Int("roomsLeft", len(summary.RoomsLeft)),
+		zap.Int("roomsDeleted", len(summary.RoomsDeleted)),
+		zap.Bool("incomplete", summary.Incomplete),
+	)
+	return summary, nil
+}
+
+func (uc *privacyUseCase) deleteOwnedRoom(ctx context.Context, room *model.Room, summary *PurgeSummary) {
+	count, err := uc.messageRepository.Count(ctx, room.ID)
+	if err == nil {
+		summary.MessagesDeleted += int(count)
+	}
+
+	if err := uc.messageRepository.DeleteOldMessages(ctx, room.ID, time.Now()); err != nil {
+		uc.logger.Error("failed to delete room messages", zap.Error(err), zap.String("roomID", room.ID))
+		summary.Incomplete = true
+	}
+
+	announcements, err := uc.announcementRepository.GetAll(ctx, room.ID)
+	if err == nil {
+		for _, announcement := range announcements {
+			_, _ = uc.announcementRepository.Unpin(ctx, room.ID, announcement.ID)
+		}
+	}
+
+	files, err := uc.fileRepository.GetByRoomID(ctx, room.ID)
+	if err == nil {
+		for _, file := range files {
+			if uc.deleteFile(ctx, file) {
+				summary.FilesDeleted++
+			} else {
+				summary.Incomplete = true
+			}
+		}
+	}
+
+	if err := uc.roomRepository.Delete(ctx, room.ID); err != nil {
+		uc.logger.Error("failed to delete owned room", zap.Error(err), zap.String("roomID", room.ID))
+		summary.Incomplete = true
+		return
+	}
+	summary.RoomsDeleted = append(summary.RoomsDeleted, room.ID)
+}
+
+func (uc *privacyUseCase) deleteFile(ctx context.Context, file *model.File) bool {
+	for _, key := range file.StorageKeys() {
+		if err := uc.storage.Delete(ctx, key); err != nil {
+			uc.logger.Error("failed to delete file from storage", zap.Error(err), zap.String("fileID", file.ID))
+			return false
+		}
+	}
+
+	if err := uc.fileRepository.Delete(ctx, file.ID); err != nil {
+		uc.logger.Error("failed to delete file record", zap.Error(err), zap.String("fileID", file.ID))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package reaction
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// Enough for multi-codepoint emoji such as flags and skin tone sequences
+	maxEmojiRunes = 16
+
+	maxReactionsPerMessage = 50
+)
+
+type ReactionUseCase interface {
+	AddReaction(ctx context.Context, roomID, messageID, userID, emoji string) (map[string]int, error)
+	RemoveReaction(ctx context.Context, roomID, messageID, userID, emoji string) (map[string]int, error)
+	GetReactionCounts(ctx context.Context, roomID string, messageIDs []string) (map[string]map[string]int, error)
+	ClearReactions(ctx context.Context, roomID, messageID string) error
+}
+
+type reactionUseCase struct {
+	repository        repository.ReactionRepository
+	messageRepository repository.MessageRepository
+	roomRepository    repository.RoomRepository
+	logger            *logger.Logger
+}
+
+func NewReactionUseCase(
+	repository repository.ReactionRepository,
+	messageRepository repository.MessageRepository,
+	roomRepository repository.RoomRepository,
+	logger *logger.Logger,
+) ReactionUseCase {
+	return &reactionUseCase{
+		repository:        repository,
+		messageRepository: messageRepository,
+		roomRepository:    roomRepository,
+		logger:            logger,
+	}
+}
+
+func (uc *reactionUseCase) AddReaction(ctx context.Context, roomID, messageID, userID, emoji string) (map[string]int, error) {
+	emoji = strings.TrimSpace(emoji)
+	if err := validateEmoji(emoji); err != nil {
+		return nil, err
+	}
+
+	if err := uc.checkAccess(ctx, roomID, messageID, userID); err != nil {
+		return nil, err
+	}
+
+	counts, err := uc.repository.GetCounts(ctx, roomID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add reaction: %w", err)
+	}
+	if _, exists := counts[emoji]; !exists && len(counts) >= maxReactionsPerMessage {
+		return nil, apperror.ErrLimitReached.WithMessage("message has too many different reactions")
+	}
+
+	added, err := uc.repository.Add(ctx, roomID, messageID, userID, emoji)
+	if err != nil {
+		uc.logger.Error("failed to add reaction", zap.Error(err), zap.String("messageID", messageID))
+		return nil, fmt.Errorf("failed to add reaction: %w", err)
+	}
+	if !added {
+		return nil, apperror.ErrReactionExists
+	}
+
+	return uc.repository.GetCounts(ctx, roomID, messageID)
+}
+
+func (uc *reactionUseCase) RemoveReaction(ctx context.Context, roomID, messageID, userID, emoji string) (map[string]int, error) {
+	emoji = strings.TrimSpace(emoji)
+	if err := validateEmoji(emoji); err != nil {
+		return nil, err
+	}
+
+	if err := uc.checkAccess(ctx, roomID, messageID, userID); err != nil {
+		return nil, err
+	}
+
+	removed, err := uc.repository.Remove(ctx, roomID, messageID, userID, emoji)
+	if err != nil {
+		uc.logger.Error("failed to remove reaction", zap.Error(err), zap.String("messageID", messageID))
+		return nil, fmt.Errorf("failed to remove reaction: %w", err)
+	}
+	if !removed {
+		return nil, apperror.ErrReactionNotFound
+	}
+
+	return uc.repository.GetCounts(ctx, roomID, messageID)
+}
+
+func (uc *reactionUseCase) GetReactionCounts(ctx context.Context, roomID string, messageIDs []string) (map[string]map[string]int, error) {
+	return uc.repository.GetCountsForMessages(ctx, roomID, messageIDs)
+}
+
+func (uc *reactionUseCase) ClearReactions(ctx context.Context, roomID, messageID string) error {
+	return uc.repository.DeleteByMessage(ctx, roomID, messageID)
+}
+
+func (uc *reactionUseCase) checkAccess(ctx context.Context, roomID, messageID, userID string) error {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil {
+		return apperror.ErrRoomNotFound
+	}
+
+	if !room.IsMember(userID) {
+		return apperror.ErrNotMember
+	}
+
+	if _, err := uc.messageRepository.GetByID(ctx, roomID, messageID); err != nil {
+		return apperror.ErrMessageNotFound
+	}
+
+	return nil
+}
+
+func validateEmoji(emoji string) error {
+	if emoji == "" {
+		return apperror.ErrInvalidInput.WithMessage("emoji cannot be empty")
+	}
+
+	if utf8.RuneCountInString(emoji) > maxEmojiRunes {
+		return apperror.ErrInvalidInput.WithMessage("emoji is too long")
+	}
+
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return apperror.ErrInvalidInput.WithMessage("emoji cannot contain whitespace")
+		}
+	}
+
+	return nil
+}
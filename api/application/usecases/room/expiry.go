@@ -0,0 +1,59 @@
+package room
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"go.uber.org/zap"
+)
+
+// A room can be kept alive indefinitely, but never more than the longest lifetime
+// it could have been created with from now
+const maxRoomLifetime = 7 * 24 * time.Hour
+
+// Extend pushes the room's expiry out by the given duration, only the owner can extend
+func (uc *roomUseCase) Extend(ctx context.Context, roomID, requesterID string, by time.Duration) (*model.Room, error) {
+	if by <= 0 {
+		return nil, apperror.ErrInvalidInput.WithMessage("extension must be positive")
+	}
+
+	room, err := uc.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if room.Owner.ID != requesterID {
+		uc.logger.Warn("unauthorized room extension attempt", zap.String("roomID", roomID), zap.String("requesterID", requesterID), zap.String("ownerID", room.Owner.ID))
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can extend the room")
+	}
+
+	if room.Expiry <= 0 {
+		return nil, apperror.ErrInvalidInput.WithMessage("room does not expire")
+	}
+
+	expiresAt := roomExpiresAt(room).Add(by)
+	if time.Until(expiresAt) > maxRoomLifetime {
+		return nil, apperror.ErrInvalidInput.WithMessage("rooms cannot be extended more than 7 days ahead")
+	}
+
+	room.Expiry += by
+
+	if err := uc.repository.Update(ctx, room); err != nil {
+		uc.logger.Error("failed to extend room", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to extend room: %w", err)
+	}
+
+	// Member tokens, bans and the membership log expire with the room
+	if err := uc.banRepository.ExtendExpiry(ctx, roomID, expiresAt); err != nil {
+		uc.logger.Warn("failed to extend room ban expiry", zap.Error(err), zap.String("roomID", roomID))
+	}
+	if err := uc.membershipLog.ExtendExpiry(ctx, roomID, expiresAt); err != nil {
+		uc.logger.Warn("failed to extend membership log expiry", zap.Error(err), zap.String("roomID", roomID))
+	}
+
+	uc.logger.Info("room extended", zap.String("roomID", roomID), zap.Duration("by", by), zap.Time("expiresAt", expiresAt))
+	return room, nil
+}
@@ -0,0 +1,68 @@
+package room
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/crypto"
+	"go.uber.org/zap"
+)
+
+// GetKeys returns the room with its current and retired keys, only to a member
+// on the client they joined from
+func (uc *roomUseCase) GetKeys(ctx context.Context, roomID, userID, memberToken string) (*model.Room, error) {
+	room, err := uc.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !room.IsMember(userID) {
+		return nil, apperror.ErrNotMember
+	}
+
+	if err := uc.checkMemberClient(ctx, roomID, userID, memberToken); err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+func (uc *roomUseCase) RotateKey(ctx context.Context, roomID, requesterID string) (*model.Room, error) {
+	room, err := uc.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if room.Owner.ID != requesterID {
+		uc.logger.Warn("unauthorized key rotation attempt", zap.String("roomID", roomID), zap.String("requesterID", requesterID), zap.String("ownerID", room.Owner.ID))
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can rotate the room key")
+	}
+
+	return uc.rotateKey(ctx, roomID)
+}
+
+// rotateKey reloads the room so a member removed just before isn't written back with it
+func (uc *roomUseCase) rotateKey(ctx context.Context, roomID string) (*model.Room, error) {
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	key, err := crypto.GenerateKeyBase64()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate room key: %w", err)
+	}
+
+	room.RotateKey(key, time.Now())
+
+	if err := uc.repository.Update(ctx, room); err != nil {
+		uc.logger.Error("failed to rotate room key", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to rotate room key: %w", err)
+	}
+
+	uc.logger.Info("room key rotated", zap.String("roomID", roomID), zap.Int("keyVersion", room.KeyVersion))
+	return room, nil
+}
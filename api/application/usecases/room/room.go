@@ -2,16 +2,20 @@ package room
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/domain/apperror"
 	"github.com/hilthontt/visper/api/domain/model"
 	"github.com/hilthontt/visper/api/domain/repository"
 	"github.com/hilthontt/visper/api/infrastructure/crypto"
 	"github.com/hilthontt/visper/api/infrastructure/events"
 	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/webhook"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
@@ -24,25 +28,60 @@ type RoomUseCase interface {
 	GetByID(ctx context.Context, id string) (*model.Room, error)
 	GetByJoinCode(ctx context.Context, joinCode string) (*model.Room, error)
 	Delete(ctx context.Context, id string, userID string) error
-	JoinRoom(ctx context.Context, roomID string, user model.User) error
+	JoinRoom(ctx context.Context, roomID string, user model.User, memberToken string) error
 	LeaveRoom(ctx context.Context, roomID string, userID string) error
 	IsUserInRoom(ctx context.Context, roomID string, userID string) (bool, error)
 	KickMember(ctx context.Context, roomID, userID, requesterID string) error
+	MuteMember(ctx context.Context, roomID, userID, requesterID string, duration time.Duration) (*model.Mute, error)
+	UnmuteMember(ctx context.Context, roomID, userID, requesterID string) error
+	IsMuted(ctx context.Context, roomID, userID string) (bool, error)
+	BanMember(ctx context.Context, roomID, userID, requesterID, reason string) (*model.RoomBan, error)
+	UnbanMember(ctx context.Context, roomID, userID, requesterID string) error
+	ListBans(ctx context.Context, roomID, requesterID string) ([]*model.RoomBan, error)
+	CreateInvite(ctx context.Context, roomID, requesterID string, maxUses int, ttl time.Duration) (*model.RoomInvite, error)
+	ListInvites(ctx context.Context, roomID, requesterID string) ([]*model.RoomInvite, error)
+	RevokeInvite(ctx context.Context, roomID, token, requesterID string) error
+	GetInvite(ctx context.Context, token string) (*model.RoomInvite, error)
+	RedeemInvite(ctx context.Context, token string, user model.User, memberToken string) (*model.Room, error)
+	UpdateSettings(ctx context.Context, userID, id string, update SettingsUpdate) (*model.Room, error)
+	Extend(ctx context.Context, roomID, requesterID string, by time.Duration) (*model.Room, error)
+	GetKeys(ctx context.Context, roomID, userID, memberToken string) (*model.Room, error)
+	RotateKey(ctx context.Context, roomID, requesterID string) (*model.Room, error)
+	IssueSocketTicket(ctx context.Context, roomID, userID, memberToken string) (*model.SocketTicket, error)
+	RedeemSocketTicket(ctx context.Context, roomID, userID, token, memberToken string) error
 }
 
 type roomUseCase struct {
 	repository     repository.RoomRepository
+	muteRepository repository.MuteRepository
+	banRepository  repository.RoomBanRepository
+	inviteRepo     repository.RoomInviteRepository
+	ticketRepo     repository.SocketTicketRepository
+	membershipLog  repository.MembershipLogRepository
+	webhooks       *webhook.Dispatcher
 	eventPublisher *events.EventPublisher
 	logger         *logger.Logger
 }
 
 func NewRoomUseCase(
 	repository repository.RoomRepository,
+	muteRepository repository.MuteRepository,
+	banRepository repository.RoomBanRepository,
+	inviteRepo repository.RoomInviteRepository,
+	ticketRepo repository.SocketTicketRepository,
+	membershipLog repository.MembershipLogRepository,
+	webhooks *webhook.Dispatcher,
 	eventPublisher *events.EventPublisher,
 	logger *logger.Logger,
 ) RoomUseCase {
 	return &roomUseCase{
 		repository:     repository,
+		muteRepository: muteRepository,
+		banRepository:  banRepository,
+		inviteRepo:     inviteRepo,
+		ticketRepo:     ticketRepo,
+		membershipLog:  membershipLog,
+		webhooks:       webhooks,
 		eventPublisher: eventPublisher,
 		logger:         logger,
 	}
@@ -50,42 +89,29 @@ func NewRoomUseCase(
 
 func (uc *roomUseCase) GetByJoinCodeWithSecureToken(ctx context.Context, joinCode string, secureCode string) (*model.Room, error) {
 	if joinCode == "" {
-		return nil, fmt.Errorf("join code cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("join code cannot be empty")
 	}
 
 	if secureCode == "" {
-		return nil, fmt.Errorf("secure token cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("secure token cannot be empty")
 	}
 
-	rooms, err := uc.repository.GetAll(ctx)
+	room, err := uc.findByJoinCode(ctx, joinCode)
 	if err != nil {
-		uc.logger.Error("failed to get rooms", zap.Error(err))
-		return nil, fmt.Errorf("failed to search for room: %w", err)
+		return nil, err
 	}
 
-	for _, room := range rooms {
-		if room.JoinCode == joinCode {
-			if uc.isRoomExpired(room) {
-				uc.logger.Info("room has expired, deleting", zap.String("roomID", room.ID))
-				_ = uc.repository.Delete(ctx, room.ID)
-				return nil, fmt.Errorf("room has expired")
-			}
-
-			if room.SecureCode != secureCode {
-				uc.logger.Warn("invalid secure token provided", zap.String("joinCode", joinCode))
-				return nil, fmt.Errorf("invalid secure token")
-			}
-
-			return room, nil
-		}
+	if room.SecureCode != secureCode {
+		uc.logger.Warn("invalid secure token provided", zap.String("joinCode", joinCode))
+		return nil, apperror.ErrForbidden.WithMessage("invalid secure token")
 	}
 
-	return nil, fmt.Errorf("room not found with join code: %s", joinCode)
+	return room, nil
 }
 
 func (uc *roomUseCase) RegenerateSecureCode(ctx context.Context, userID, id string) (*model.Room, error) {
 	if id == "" {
-		return nil, fmt.Errorf("room ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
 	room, err := uc.repository.GetByID(ctx, id)
@@ -95,12 +121,12 @@ func (uc *roomUseCase) RegenerateSecureCode(ctx context.Context, userID, id stri
 	}
 
 	if room == nil {
-		return nil, fmt.Errorf("room not found")
+		return nil, apperror.ErrRoomNotFound
 	}
 
 	if room.Owner.ID != userID {
 		uc.logger.Warn("unauthorized secure code regeneration attempt", zap.String("roomID", id), zap.String("userID", userID), zap.String("ownerID", room.Owner.ID))
-		return nil, fmt.Errorf("only the room owner can update the room")
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can update the room")
 	}
 
 	room.SecureCode = generateSecureCode()
@@ -116,7 +142,7 @@ func (uc *roomUseCase) RegenerateSecureCode(ctx context.Context, userID, id stri
 
 func (uc *roomUseCase) GenerateNewJoinCode(ctx context.Context, userID, id string) (*model.Room, error) {
 	if id == "" {
-		return nil, fmt.Errorf("room ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
 	room, err := uc.repository.GetByID(ctx, id)
@@ -126,12 +152,12 @@ func (uc *roomUseCase) GenerateNewJoinCode(ctx context.Context, userID, id strin
 	}
 
 	if room == nil {
-		return nil, fmt.Errorf("room not found")
+		return nil, apperror.ErrRoomNotFound
 	}
 
 	if room.Owner.ID != userID {
 		uc.logger.Warn("unauthorized room deletion attempt", zap.String("roomID", id), zap.String("userID", userID), zap.String("ownerID", room.Owner.ID))
-		return nil, fmt.Errorf("only the room owner can update the room")
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can update the room")
 	}
 
 	room.JoinCode = generateJoinCode()
@@ -144,6 +170,96 @@ func (uc *roomUseCase) GenerateNewJoinCode(ctx context.Context, userID, id strin
 	return room, nil
 }
 
+const (
+	minMessageRetention = time.Hour
+	maxMessageRetention = 30 * 24 * time.Hour
+)
+
+// SettingsUpdate is a partial update, nil fields keep their current value
+type SettingsUpdate struct {
+	Name               *string
+	Topic              *string
+	MaxMembers         *int
+	SlowModeSeconds    *int
+	ReadOnly           *bool
+	Moderation         *model.ModerationMode
+	MessageRetention   *time.Duration
+	RetainUntilDeleted *bool
+}
+
+func (uc *roomUseCase) UpdateSettings(ctx context.Context, userID, id string, update SettingsUpdate) (*model.Room, error) {
+	if id == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
+	}
+
+	room, err := uc.repository.GetByID(ctx, id)
+	if err != nil {
+		uc.logger.Error("failed to get room for settings update", zap.Error(err), zap.String("roomID", id))
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != userID {
+		uc.logger.Warn("unauthorized room settings update attempt", zap.String("roomID", id), zap.String("userID", userID), zap.String("ownerID", room.Owner.ID))
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can update the room")
+	}
+
+	settings := room.Settings
+	if update.Name != nil {
+		settings.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Topic != nil {
+		settings.Topic = strings.TrimSpace(*update.Topic)
+	}
+	if update.MaxMembers != nil {
+		settings.MaxMembers = *update.MaxMembers
+	}
+	if update.SlowModeSeconds != nil {
+		settings.SlowModeSeconds = *update.SlowModeSeconds
+	}
+	if update.ReadOnly != nil {
+		settings.ReadOnly = *update.ReadOnly
+	}
+	if update.Moderation != nil {
+		settings.Moderation = *update.Moderation
+	}
+	if update.RetainUntilDeleted != nil {
+		settings.RetainUntilDeleted = *update.RetainUntilDeleted
+	}
+	if update.MessageRetention != nil {
+		settings.MessageRetention = *update.MessageRetention
+		settings.RetainUntilDeleted = false
+	}
+
+	if settings.RetainUntilDeleted {
+		settings.MessageRetention = 0
+	} else if settings.MessageRetention != 0 &&
+		(settings.MessageRetention < minMessageRetention || settings.MessageRetention > maxMessageRetention) {
+		return nil, apperror.ErrInvalidInput.WithMessage("message retention must be between 1 hour and 30 days")
+	}
+
+	room.Settings = settings
+
+	if err := uc.repository.Update(ctx, room); err != nil {
+		uc.logger.Error("failed to update room settings", zap.Error(err), zap.String("roomID", id))
+		return nil, fmt.Errorf("failed to update room: %w", err)
+	}
+
+	uc.logger.Info("room settings updated",
+		zap.String("roomID", id),
+		zap.Int("maxMembers", settings.MaxMembers),
+		zap.Int("slowModeSeconds", settings.SlowModeSeconds),
+		zap.Bool("readOnly", settings.ReadOnly),
+		zap.String("moderation", string(settings.ModerationMode())),
+		zap.Duration("messageRetention", settings.MessageRetention),
+		zap.Bool("retainUntilDeleted", settings.RetainUntilDeleted))
+
+	return room, nil
+}
+
 func (uc *roomUseCase) Create(ctx context.Context, owner model.User, expiry time.Duration) (*model.Room, error) {
 	encryptionKey, err := crypto.GenerateKeyBase64()
 	if err != nil {
@@ -159,6 +275,7 @@ func (uc *roomUseCase) Create(ctx context.Context, owner model.User, expiry time
 		Members:       []model.User{owner}, // Add the owner as a member for the room (as he technically is)
 		SecureCode:    generateSecureCode(),
 		EncryptionKey: encryptionKey,
+		KeyVersion:    1,
 	}
 
 	if err := uc.repository.Create(ctx, room); err != nil {
@@ -174,7 +291,7 @@ func (uc *roomUseCase) Create(ctx context.Context, owner model.User, expiry time
 	}
 
 	go func() {
-		if err := uc.eventPublisher.PublishRoomCreated(room.ID, owner.ID, room.Expiry); err != nil {
+		if err := uc.eventPublisher.PublishRoomCreated(ctx, room.ID, owner.ID, room.Expiry); err != nil {
 			log.Printf("Failed to publish room created event: %v", err)
 		}
 	}()
@@ -185,7 +302,7 @@ func (uc *roomUseCase) Create(ctx context.Context, owner model.User, expiry time
 
 func (uc *roomUseCase) Delete(ctx context.Context, id string, userID string) error {
 	if id == "" {
-		return fmt.Errorf("room ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
 	room, err := uc.repository.GetByID(ctx, id)
@@ -195,13 +312,13 @@ func (uc *roomUseCase) Delete(ctx context.Context, id string, userID string) err
 	}
 
 	if room == nil {
-		return fmt.Errorf("room not found")
+		return apperror.ErrRoomNotFound
 	}
 
 	// Only owner can delete the room
 	if room.Owner.ID != userID {
 		uc.logger.Warn("unauthorized room deletion attempt", zap.String("roomID", id), zap.String("userID", userID), zap.String("ownerID", room.Owner.ID))
-		return fmt.Errorf("only the room owner can delete the room")
+		return apperror.ErrNotOwner.WithMessage("only the room owner can delete the room")
 	}
 
 	if err := uc.repository.Delete(ctx, id); err != nil {
@@ -215,26 +332,26 @@ func (uc *roomUseCase) Delete(ctx context.Context, id string, userID string) err
 
 func (uc *roomUseCase) GetByID(ctx context.Context, id string) (*model.Room, error) {
 	if id == "" {
-		return nil, fmt.Errorf("room ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
 	room, err := uc.repository.GetByID(ctx, id)
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("room not found")
+			return nil, apperror.ErrRoomNotFound
 		}
 		uc.logger.Error("failed to get room by ID", zap.Error(err), zap.String("roomID", id))
 		return nil, fmt.Errorf("failed to get room: %w", err)
 	}
 
 	if room == nil {
-		return nil, fmt.Errorf("room not found")
+		return nil, apperror.ErrRoomNotFound
 	}
 
 	if uc.isRoomExpired(room) {
 		uc.logger.Info("room has expired, deleting", zap.String("roomID", room.ID))
 		_ = uc.repository.Delete(ctx, room.ID)
-		return nil, fmt.Errorf("room has expired")
+		return nil, apperror.ErrRoomExpired
 	}
 
 	return room, nil
@@ -242,7 +359,7 @@ func (uc *roomUseCase) GetByID(ctx context.Context, id string) (*model.Room, err
 
 func (uc *roomUseCase) KickMember(ctx context.Context, roomID, userID, requesterID string) error {
 	if roomID == "" || userID == "" || requesterID == "" {
-		return fmt.Errorf("room ID, user ID, and requester ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("room ID, user ID, and requester ID cannot be empty")
 	}
 
 	room, err := uc.repository.GetByID(ctx, roomID)
@@ -252,64 +369,151 @@ func (uc *roomUseCase) KickMember(ctx context.Context, roomID, userID, requester
 	}
 
 	if room == nil {
-		return fmt.Errorf("room not found")
+		return apperror.ErrRoomNotFound
 	}
 
-	if room.Owner.ID != requesterID {
+	// The repository checks ownership and membership in the same step that removes the member
+	err = uc.repository.KickUser(ctx, roomID, userID, requesterID)
+	switch {
+	case errors.Is(err, repository.ErrNotRoomOwner):
 		uc.logger.Warn("unauthorized kick attempt", zap.String("roomID", roomID), zap.String("requesterID", requesterID), zap.String("ownerID", room.Owner.ID))
-		return fmt.Errorf("only the room owner can kick members")
+		return apperror.ErrNotOwner.WithMessage("only the room owner can kick members")
+	case errors.Is(err, repository.ErrOwnerProtected):
+		return apperror.ErrOwnerProtected.WithMessage("room owner cannot be kicked, delete the room instead")
+	case errors.Is(err, repository.ErrNotRoomMember):
+		return apperror.ErrNotMember
+	case err != nil:
+		uc.logger.Error("failed to kick user from room", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return fmt.Errorf("failed to kick member: %w", err)
+	}
+
+	uc.recordMembership(ctx, room, userID, memberUsername(room, userID), model.MembershipKicked)
+
+	// The kicked member holds the key, anything sent from now on must be out of their reach
+	if _, err := uc.rotateKey(ctx, roomID); err != nil {
+		uc.logger.Error("failed to rotate room key after kick", zap.Error(err), zap.String("roomID", roomID))
+	}
+
+	uc.logger.Info("user kicked from room", zap.String("roomID", roomID), zap.String("kickedUserID", userID), zap.String("kickedBy", requesterID))
+	return nil
+}
+
+func (uc *roomUseCase) MuteMember(ctx context.Context, roomID, userID, requesterID string, duration time.Duration) (*model.Mute, error) {
+	if roomID == "" || userID == "" || requesterID == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID, user ID, and requester ID cannot be empty")
+	}
+
+	if duration <= 0 {
+		return nil, apperror.ErrInvalidInput.WithMessage("mute duration must be positive")
+	}
+
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		uc.logger.Warn("unauthorized mute attempt", zap.String("roomID", roomID), zap.String("requesterID", requesterID), zap.String("ownerID", room.Owner.ID))
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can mute members")
 	}
 
 	if userID == room.Owner.ID {
-		return fmt.Errorf("room owner cannot be kicked, delete the room instead")
+		return nil, apperror.ErrOwnerProtected.WithMessage("room owner cannot be muted")
 	}
 
-	isInRoom, err := uc.IsUserInRoom(ctx, roomID, userID)
+	if !room.IsMember(userID) {
+		return nil, apperror.ErrNotMember
+	}
+
+	mute := &model.Mute{
+		RoomID:  roomID,
+		UserID:  userID,
+		MutedBy: requesterID,
+	}
+
+	if err := uc.muteRepository.Create(ctx, mute, duration); err != nil {
+		uc.logger.Error("failed to mute member", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return nil, fmt.Errorf("failed to mute member: %w", err)
+	}
+
+	uc.logger.Info("user muted in room", zap.String("roomID", roomID), zap.String("mutedUserID", userID), zap.String("mutedBy", requesterID), zap.Duration("duration", duration))
+	return mute, nil
+}
+
+func (uc *roomUseCase) UnmuteMember(ctx context.Context, roomID, userID, requesterID string) error {
+	if roomID == "" || userID == "" || requesterID == "" {
+		return apperror.ErrInvalidInput.WithMessage("room ID, user ID, and requester ID cannot be empty")
+	}
+
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		return apperror.ErrNotOwner.WithMessage("only the room owner can mute members")
+	}
+
+	muted, err := uc.IsMuted(ctx, roomID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to verify user membership: %w", err)
+		return err
 	}
 
-	if !isInRoom {
-		return fmt.Errorf("user is not a member of this room")
+	if !muted {
+		return apperror.ErrNotMuted
 	}
 
-	if err := uc.repository.RemoveUser(ctx, roomID, userID); err != nil {
-		uc.logger.Error("failed to kick user from room", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
-		return fmt.Errorf("failed to kick member: %w", err)
+	if err := uc.muteRepository.Delete(ctx, roomID, userID); err != nil {
+		uc.logger.Error("failed to unmute member", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return fmt.Errorf("failed to unmute member: %w", err)
 	}
 
-	uc.logger.Info("user kicked from room", zap.String("roomID", roomID), zap.String("kickedUserID", userID), zap.String("kickedBy", requesterID))
+	uc.logger.Info("user unmuted in room", zap.String("roomID", roomID), zap.String("unmutedUserID", userID), zap.String("unmutedBy", requesterID))
 	return nil
 }
 
+func (uc *roomUseCase) IsMuted(ctx context.Context, roomID, userID string) (bool, error) {
+	_, err := uc.muteRepository.Get(ctx, roomID, userID)
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check mute: %w", err)
+	}
+	return true, nil
+}
+
 func (uc *roomUseCase) GetByJoinCode(ctx context.Context, joinCode string) (*model.Room, error) {
 	if joinCode == "" {
-		return nil, fmt.Errorf("join code cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("join code cannot be empty")
 	}
 
-	rooms, err := uc.repository.GetAll(ctx)
+	return uc.findByJoinCode(ctx, joinCode)
+}
+
+// findByJoinCode deletes the room it finds when that room has expired
+func (uc *roomUseCase) findByJoinCode(ctx context.Context, joinCode string) (*model.Room, error) {
+	room, err := uc.repository.GetByJoinCode(ctx, joinCode)
+	if err == redis.Nil {
+		return nil, apperror.ErrRoomNotFound.WithMessage(fmt.Sprintf("room not found with join code: %s", joinCode))
+	}
 	if err != nil {
-		uc.logger.Error("failed to get rooms", zap.Error(err))
+		uc.logger.Error("failed to look up join code", zap.Error(err))
 		return nil, fmt.Errorf("failed to search for room: %w", err)
 	}
 
-	for _, room := range rooms {
-		if room.JoinCode == joinCode {
-			if uc.isRoomExpired(room) {
-				uc.logger.Info("room has expired, deleting", zap.String("roomID", room.ID))
-				_ = uc.repository.Delete(ctx, room.ID)
-				return nil, fmt.Errorf("room has expired")
-			}
-			return room, nil
-		}
+	if uc.isRoomExpired(room) {
+		uc.logger.Info("room has expired, deleting", zap.String("roomID", room.ID))
+		_ = uc.repository.Delete(ctx, room.ID)
+		return nil, apperror.ErrRoomExpired
 	}
 
-	return nil, fmt.Errorf("room not found with join code: %s", joinCode)
+	return room, nil
 }
 
 func (uc *roomUseCase) IsUserInRoom(ctx context.Context, roomID string, userID string) (bool, error) {
 	if roomID == "" || userID == "" {
-		return false, fmt.Errorf("room ID and user ID cannot be empty")
+		return false, apperror.ErrInvalidInput.WithMessage("room ID and user ID cannot be empty")
 	}
 
 	userIDs, err := uc.repository.GetUsers(ctx, roomID)
@@ -327,9 +531,9 @@ func (uc *roomUseCase) IsUserInRoom(ctx context.Context, roomID string, userID s
 	return false, nil
 }
 
-func (uc *roomUseCase) JoinRoom(ctx context.Context, roomID string, user model.User) error {
+func (uc *roomUseCase) JoinRoom(ctx context.Context, roomID string, user model.User, memberToken string) error {
 	if roomID == "" {
-		return fmt.Errorf("room ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("room ID cannot be empty")
 	}
 
 	room, err := uc.GetByID(ctx, roomID)
@@ -337,6 +541,23 @@ func (uc *roomUseCase) JoinRoom(ctx context.Context, roomID string, user model.U
 		return err
 	}
 
+	banned, err := uc.banRepository.IsBanned(ctx, roomID, user.ID, memberToken)
+	if err != nil {
+		// Fail open like the platform ban check
+		uc.logger.Error("failed to check room ban", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", user.ID))
+	}
+	if banned {
+		uc.logger.Warn("banned user attempted to join room", zap.String("roomID", roomID), zap.String("userID", user.ID))
+		return apperror.ErrBannedFromRoom
+	}
+
+	// Remembered so a later ban also covers this client if it comes back under a new user ID
+	if memberToken != "" && user.ID != room.Owner.ID {
+		if err := uc.banRepository.SetMemberToken(ctx, roomID, user.ID, memberToken, roomExpiresAt(room)); err != nil {
+			uc.logger.Warn("failed to record member token", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", user.ID))
+		}
+	}
+
 	// Check if user is already in the room
 	for _, member := range room.Members {
 		if member.ID == user.ID {
@@ -345,13 +566,19 @@ func (uc *roomUseCase) JoinRoom(ctx context.Context, roomID string, user model.U
 		}
 	}
 
+	// The member limit is enforced by AddUser, checking room.IsFull here would race other joins
 	if err := uc.repository.AddUser(ctx, roomID, user); err != nil {
+		if errors.Is(err, repository.ErrRoomFull) {
+			return apperror.ErrRoomFull
+		}
 		uc.logger.Error("failed to add user to room", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", user.ID))
 		return fmt.Errorf("failed to join room: %w", err)
 	}
 
+	uc.recordMembership(ctx, room, user.ID, user.Username, model.MembershipJoined)
+
 	go func() {
-		if err := uc.eventPublisher.PublishRoomJoined(room.ID, room.Owner.ID); err != nil {
+		if err := uc.eventPublisher.PublishRoomJoined(ctx, room.ID, room.Owner.ID); err != nil {
 			log.Printf("Failed to publish room joined event: %v", err)
 		}
 	}()
@@ -362,7 +589,7 @@ func (uc *roomUseCase) JoinRoom(ctx context.Context, roomID string, user model.U
 
 func (uc *roomUseCase) LeaveRoom(ctx context.Context, roomID string, userID string) error {
 	if roomID == "" || userID == "" {
-		return fmt.Errorf("room ID and user ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("room ID and user ID cannot be empty")
 	}
 
 	room, err := uc.repository.GetByID(ctx, roomID)
@@ -371,22 +598,300 @@ func (uc *roomUseCase) LeaveRoom(ctx context.Context, roomID string, userID stri
 	}
 
 	if room == nil {
-		return fmt.Errorf("room not found")
-	}
-
-	if room.Owner.ID == userID {
-		return fmt.Errorf("room owner cannot leave, delete the room instead")
+		return apperror.ErrRoomNotFound
 	}
 
 	if err := uc.repository.RemoveUser(ctx, roomID, userID); err != nil {
+		if errors.Is(err, repository.ErrOwnerProtected) {
+			return apperror.ErrOwnerProtected.WithMessage("room owner cannot leave, delete the room instead")
+		}
 		uc.logger.Error("failed to remove user from room", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
 		return fmt.Errorf("failed to leave room: %w", err)
 	}
 
+	uc.recordMembership(ctx, room, userID, memberUsername(room, userID), model.MembershipLeft)
+
 	uc.logger.Info("user left room", zap.String("roomID", roomID), zap.String("userID", userID))
 	return nil
 }
 
+func (uc *roomUseCase) BanMember(ctx context.Context, roomID, userID, requesterID, reason string) (*model.RoomBan, error) {
+	if roomID == "" || userID == "" || requesterID == "" {
+		return nil, apperror.ErrInvalidInput.WithMessage("room ID, user ID, and requester ID cannot be empty")
+	}
+
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		uc.logger.Warn("unauthorized room ban attempt", zap.String("roomID", roomID), zap.String("requesterID", requesterID), zap.String("ownerID", room.Owner.ID))
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can ban members")
+	}
+
+	if userID == room.Owner.ID {
+		return nil, apperror.ErrOwnerProtected.WithMessage("room owner cannot be banned")
+	}
+
+	// Missing token just means the user never joined, the ID ban still applies
+	tokenHash, err := uc.banRepository.GetMemberToken(ctx, roomID, userID)
+	if err != nil {
+		uc.logger.Warn("failed to look up member token", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+	}
+
+	ban := &model.RoomBan{
+		RoomID:    roomID,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		Reason:    reason,
+		BannedBy:  requesterID,
+	}
+
+	if err := uc.banRepository.Create(ctx, ban, roomExpiresAt(room)); err != nil {
+		uc.logger.Error("failed to ban member", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return nil, fmt.Errorf("failed to ban member: %w", err)
+	}
+
+	if room.IsMember(userID) {
+		if err := uc.repository.RemoveUser(ctx, roomID, userID); err != nil {
+			uc.logger.Error("failed to remove banned user from room", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+			return nil, fmt.Errorf("failed to ban member: %w", err)
+		}
+	}
+
+	uc.recordMembership(ctx, room, userID, memberUsername(room, userID), model.MembershipBanned)
+
+	if _, err := uc.rotateKey(ctx, roomID); err != nil {
+		uc.logger.Error("failed to rotate room key after ban", zap.Error(err), zap.String("roomID", roomID))
+	}
+
+	uc.logger.Info("user banned from room", zap.String("roomID", roomID), zap.String("bannedUserID", userID), zap.String("bannedBy", requesterID))
+	return ban, nil
+}
+
+func (uc *roomUseCase) UnbanMember(ctx context.Context, roomID, userID, requesterID string) error {
+	if roomID == "" || userID == "" || requesterID == "" {
+		return apperror.ErrInvalidInput.WithMessage("room ID, user ID, and requester ID cannot be empty")
+	}
+
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		return apperror.ErrNotOwner.WithMessage("only the room owner can ban members")
+	}
+
+	removed, err := uc.banRepository.Delete(ctx, roomID, userID)
+	if err != nil {
+		uc.logger.Error("failed to unban member", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return fmt.Errorf("failed to unban member: %w", err)
+	}
+
+	if !removed {
+		return apperror.ErrNotBanned
+	}
+
+	uc.logger.Info("user unbanned from room", zap.String("roomID", roomID), zap.String("unbannedUserID", userID), zap.String("unbannedBy", requesterID))
+	return nil
+}
+
+func (uc *roomUseCase) ListBans(ctx context.Context, roomID, requesterID string) ([]*model.RoomBan, error) {
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can ban members")
+	}
+
+	bans, err := uc.banRepository.GetAll(ctx, roomID)
+	if err != nil {
+		uc.logger.Error("failed to list room bans", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to list bans: %w", err)
+	}
+
+	return bans, nil
+}
+
+// CreateInvite never outlives the room, a zero ttl means the invite lasts as long as the room does
+func (uc *roomUseCase) CreateInvite(ctx context.Context, roomID, requesterID string, maxUses int, ttl time.Duration) (*model.RoomInvite, error) {
+	if maxUses < 0 || ttl < 0 {
+		return nil, apperror.ErrInvalidInput.WithMessage("invalid invite limits")
+	}
+
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		uc.logger.Warn("unauthorized invite creation attempt", zap.String("roomID", roomID), zap.String("requesterID", requesterID), zap.String("ownerID", room.Owner.ID))
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can manage invites")
+	}
+
+	expiresAt := roomExpiresAt(room)
+	if ttl > 0 {
+		if until := time.Now().Add(ttl); expiresAt.IsZero() || until.Before(expiresAt) {
+			expiresAt = until
+		}
+	}
+
+	invite := &model.RoomInvite{
+		Token:     generateSecureCode(),
+		RoomID:    roomID,
+		CreatedBy: requesterID,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := uc.inviteRepo.Create(ctx, invite); err != nil {
+		uc.logger.Error("failed to create invite", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	uc.logger.Info("room invite created", zap.String("roomID", roomID), zap.Int("maxUses", maxUses), zap.Time("expiresAt", expiresAt))
+	return invite, nil
+}
+
+func (uc *roomUseCase) ListInvites(ctx context.Context, roomID, requesterID string) ([]*model.RoomInvite, error) {
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can manage invites")
+	}
+
+	invites, err := uc.inviteRepo.GetAll(ctx, roomID)
+	if err != nil {
+		uc.logger.Error("failed to list invites", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+
+	return invites, nil
+}
+
+func (uc *roomUseCase) RevokeInvite(ctx context.Context, roomID, token, requesterID string) error {
+	room, err := uc.repository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		return apperror.ErrNotOwner.WithMessage("only the room owner can manage invites")
+	}
+
+	removed, err := uc.inviteRepo.Delete(ctx, roomID, token)
+	if err != nil {
+		uc.logger.Error("failed to revoke invite", zap.Error(err), zap.String("roomID", roomID))
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+
+	if !removed {
+		return apperror.ErrInviteNotFound
+	}
+
+	uc.logger.Info("room invite revoked", zap.String("roomID", roomID), zap.String("revokedBy", requesterID))
+	return nil
+}
+
+func (uc *roomUseCase) GetInvite(ctx context.Context, token string) (*model.RoomInvite, error) {
+	invite, err := uc.inviteRepo.Get(ctx, token)
+	if err != nil {
+		uc.logger.Error("failed to get invite", zap.Error(err))
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+
+	if invite == nil {
+		return nil, apperror.ErrInviteNotFound
+	}
+
+	return invite, nil
+}
+
+// RedeemInvite only counts a use when it actually admits someone, existing members and failed joins are free
+func (uc *roomUseCase) RedeemInvite(ctx context.Context, token string, user model.User, memberToken string) (*model.Room, error) {
+	invite, err := uc.GetInvite(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if invite.IsExpired(time.Now()) {
+		return nil, apperror.ErrInviteUnavailable.WithMessage("invite has expired")
+	}
+
+	room, err := uc.GetByID(ctx, invite.RoomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if room.IsMember(user.ID) {
+		return room, nil
+	}
+
+	ok, err := uc.inviteRepo.Redeem(ctx, invite)
+	if err != nil {
+		uc.logger.Error("failed to redeem invite", zap.Error(err), zap.String("roomID", room.ID))
+		return nil, fmt.Errorf("failed to redeem invite: %w", err)
+	}
+
+	if !ok {
+		return nil, apperror.ErrInviteUnavailable.WithMessage("invite has reached its usage limit")
+	}
+
+	if err := uc.JoinRoom(ctx, room.ID, user, memberToken); err != nil {
+		if releaseErr := uc.inviteRepo.Release(ctx, token); releaseErr != nil {
+			uc.logger.Warn("failed to release invite use", zap.Error(releaseErr), zap.String("roomID", room.ID))
+		}
+		return nil, err
+	}
+
+	uc.logger.Info("room invite redeemed", zap.String("roomID", room.ID), zap.String("userID", user.ID), zap.Int("uses", invite.Uses))
+
+	if joined, err := uc.GetByID(ctx, room.ID); err == nil {
+		return joined, nil
+	}
+	return room, nil
+}
+
+// recordMembership feeds the transcript export, losing an entry isn't worth failing the request over
+func (uc *roomUseCase) recordMembership(ctx context.Context, room *model.Room, userID, username string, action model.MembershipAction) {
+	event := &model.MembershipEvent{
+		RoomID:   room.ID,
+		UserID:   userID,
+		Username: username,
+		Action:   action,
+	}
+
+	if err := uc.membershipLog.Append(ctx, event, roomExpiresAt(room)); err != nil {
+		uc.logger.Warn("failed to record membership event", zap.Error(err), zap.String("roomID", room.ID), zap.String("userID", userID), zap.String("action", string(action)))
+	}
+
+	uc.webhooks.Dispatch(room, model.MembershipWebhookEvent(action), event)
+}
+
+func memberUsername(room *model.Room, userID string) string {
+	for _, member := range room.Members {
+		if member.ID == userID {
+			return member.Username
+		}
+	}
+	return ""
+}
+
+// roomExpiresAt is zero for rooms that never expire
+func roomExpiresAt(room *model.Room) time.Time {
+	if room.Expiry <= 0 {
+		return time.Time{}
+	}
+	return room.CreatedAt.Add(room.Expiry)
+}
+
 func (uc *roomUseCase) isRoomExpired(room *model.Room) bool {
 	if room.Expiry == 0 {
 		return false
@@ -0,0 +1,103 @@
+package room
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"go.uber.org/zap"
+)
+
+// socketTicketTTL only has to cover the gap between fetching a ticket and dialing the socket
+const socketTicketTTL = 30 * time.Second
+
+var errInvalidSocketTicket = apperror.ErrForbidden.WithMessage("invalid or expired socket ticket")
+
+// IssueSocketTicket hands a member a single use ticket for the room's socket. A member whose
+// join was recorded from another client is refused, so a user ID lifted from somewhere else
+// isn't enough to listen in.
+func (uc *roomUseCase) IssueSocketTicket(ctx context.Context, roomID, userID, memberToken string) (*model.SocketTicket, error) {
+	room, err := uc.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !room.IsMember(userID) {
+		return nil, apperror.ErrNotMember
+	}
+
+	if err := uc.checkMemberClient(ctx, roomID, userID, memberToken); err != nil {
+		return nil, err
+	}
+
+	ticket := &model.SocketTicket{
+		Token:       generateSecureCode(),
+		RoomID:      roomID,
+		UserID:      userID,
+		MemberToken: memberToken,
+		ExpiresAt:   time.Now().Add(socketTicketTTL),
+	}
+
+	if err := uc.ticketRepo.Create(ctx, ticket); err != nil {
+		uc.logger.Error("failed to create socket ticket", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return nil, fmt.Errorf("failed to create socket ticket: %w", err)
+	}
+
+	return ticket, nil
+}
+
+// RedeemSocketTicket uses up the ticket and checks it was issued for this room, user and client.
+// Membership is checked again since the user may have been kicked in the meantime.
+func (uc *roomUseCase) RedeemSocketTicket(ctx context.Context, roomID, userID, token, memberToken string) error {
+	if token == "" {
+		return errInvalidSocketTicket
+	}
+
+	ticket, err := uc.ticketRepo.Consume(ctx, token)
+	if err != nil {
+		uc.logger.Error("failed to redeem socket ticket", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return fmt.Errorf("failed to redeem socket ticket: %w", err)
+	}
+
+	if ticket == nil || ticket.RoomID != roomID || ticket.UserID != userID || ticket.MemberToken != memberToken {
+		uc.logger.Warn("rejected socket ticket", zap.String("roomID", roomID), zap.String("userID", userID))
+		return errInvalidSocketTicket
+	}
+
+	room, err := uc.GetByID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if !room.IsMember(userID) {
+		return apperror.ErrNotMember
+	}
+
+	return uc.checkMemberClient(ctx, roomID, userID, memberToken)
+}
+
+// checkMemberClient refuses banned clients and, when the join was recorded, any client other
+// than the one that joined. Owners and members from before tokens were recorded have none.
+func (uc *roomUseCase) checkMemberClient(ctx context.Context, roomID, userID, memberToken string) error {
+	banned, err := uc.banRepository.IsBanned(ctx, roomID, userID, memberToken)
+	if err != nil {
+		// Fail open like the join check
+		uc.logger.Error("failed to check room ban", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+	}
+	if banned {
+		return apperror.ErrBannedFromRoom
+	}
+
+	joinedFrom, err := uc.banRepository.GetMemberToken(ctx, roomID, userID)
+	if err != nil {
+		uc.logger.Warn("failed to look up member token", zap.Error(err), zap.String("roomID", roomID), zap.String("userID", userID))
+		return nil
+	}
+	if joinedFrom != "" && joinedFrom != memberToken {
+		uc.logger.Warn("socket requested from a different client than the join", zap.String("roomID", roomID), zap.String("userID", userID))
+		return apperror.ErrForbidden.WithMessage("this client did not join the room")
+	}
+
+	return nil
+}
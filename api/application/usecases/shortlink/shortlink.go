@@ -0,0 +1,167 @@
+package shortlink
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	codeCharset = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength  = 8
+
+	maxCodeAttempts = 5
+)
+
+type ShortLinkUseCase interface {
+	Create(ctx context.Context, roomID, userID string, includeToken bool) (*model.ShortLink, error)
+	// Resolve returns the join URL the short code currently points to
+	Resolve(ctx context.Context, code string) (string, error)
+	GetShortURL(link *model.ShortLink) string
+}
+
+type shortLinkUseCase struct {
+	shortLinkRepository repository.ShortLinkRepository
+	roomRepository      repository.RoomRepository
+	frontEndURL         string
+	serverURL           string
+	logger              *logger.Logger
+}
+
+func NewShortLinkUseCase(
+	shortLinkRepository repository.ShortLinkRepository,
+	roomRepository repository.RoomRepository,
+	frontEndURL string,
+	serverURL string,
+	logger *logger.Logger,
+) ShortLinkUseCase {
+	return &shortLinkUseCase{
+		shortLinkRepository: shortLinkRepository,
+		roomRepository:      roomRepository,
+		frontEndURL:         frontEndURL,
+		serverURL:           serverURL,
+		logger:              logger,
+	}
+}
+
+func (uc *shortLinkUseCase) Create(ctx context.Context, roomID, userID string, includeToken bool) (*model.ShortLink, error) {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.HasExpired() {
+		return nil, apperror.ErrRoomExpired
+	}
+
+	if !room.IsMember(userID) {
+		return nil, apperror.ErrNotMember
+	}
+
+	// The secure token lets anyone skip the join code check
+	if includeToken && room.Owner.ID != userID {
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can share the secure token")
+	}
+
+	link := &model.ShortLink{
+		RoomID:       roomID,
+		CreatedBy:    userID,
+		IncludeToken: includeToken,
+		CreatedAt:    time.Now(),
+	}
+
+	// Links live exactly as long as the room does
+	var ttl time.Duration
+	if room.Expiry > 0 {
+		link.ExpiresAt = room.CreatedAt.Add(room.Expiry)
+		ttl = time.Until(link.ExpiresAt)
+	}
+
+	for range maxCodeAttempts {
+		code, err := generateCode()
+		if err != nil {
+			return nil, err
+		}
+		link.Code = code
+
+		created, err := uc.shortLinkRepository.Create(ctx, link, ttl)
+		if err != nil {
+			uc.logger.Error("failed to create short link", zap.Error(err), zap.String("roomID", roomID))
+			return nil, fmt.Errorf("failed to create short link: %w", err)
+		}
+		if created {
+			uc.logger.Info("short link created",
+				zap.String("roomID", roomID),
+				zap.String("userID", userID),
+				zap.Bool("includeToken", includeToken))
+			return link, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to generate a unique short code")
+}
+
+func (uc *shortLinkUseCase) Resolve(ctx context.Context, code string) (string, error) {
+	link, err := uc.shortLinkRepository.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", apperror.ErrShortLinkNotFound
+		}
+		return "", fmt.Errorf("failed to resolve short link: %w", err)
+	}
+
+	// Resolve against the current room so regenerated join codes are picked up
+	room, err := uc.roomRepository.GetByID(ctx, link.RoomID)
+	if err != nil {
+		return "", apperror.ErrRoomNotFound
+	}
+
+	if room.HasExpired() {
+		return "", apperror.ErrRoomExpired
+	}
+
+	if link.IncludeToken {
+		return room.GetQRCodeURL(uc.frontEndURL), nil
+	}
+
+	u, err := url.Parse(uc.frontEndURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid front end URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("joinCode", room.JoinCode)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+func (uc *shortLinkUseCase) GetShortURL(link *model.ShortLink) string {
+	return fmt.Sprintf("%s/j/%s", uc.serverURL, link.Code)
+}
+
+func generateCode() (string, error) {
+	max := big.NewInt(int64(len(codeCharset)))
+
+	code := make([]byte, codeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate short code: %w", err)
+		}
+		code[i] = codeCharset[n.Int64()]
+	}
+
+	return string(code), nil
+}
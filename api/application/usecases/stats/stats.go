@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+type StatsUseCase interface {
+	GetUsageStats(ctx context.Context) (*model.UsageStats, error)
+	GetRoomStats(ctx context.Context, roomID, requesterID string) (*model.RoomStats, error)
+}
+
+type statsUseCase struct {
+	roomRepository  repository.RoomRepository
+	statsRepository repository.StatsRepository
+	logger          *logger.Logger
+}
+
+func NewStatsUseCase(
+	roomRepository repository.RoomRepository,
+	statsRepository repository.StatsRepository,
+	logger *logger.Logger,
+) StatsUseCase {
+	return &statsUseCase{
+		roomRepository:  roomRepository,
+		statsRepository: statsRepository,
+		logger:          logger,
+	}
+}
+
+func (uc *statsUseCase) GetUsageStats(ctx context.Context) (*model.UsageStats, error) {
+	activeRooms, err := uc.roomRepository.Count(ctx)
+	if err != nil {
+		uc.logger.Error("failed to count rooms", zap.Error(err))
+		return nil, fmt.Errorf("failed to get usage stats: %w", err)
+	}
+
+	messages, err := uc.statsRepository.GetMessageCount(ctx, time.Minute)
+	if err != nil {
+		uc.logger.Error("failed to get message count", zap.Error(err))
+		return nil, fmt.Errorf("failed to get usage stats: %w", err)
+	}
+
+	uploadBytes, err := uc.statsRepository.GetUploadBytes(ctx, time.Hour)
+	if err != nil {
+		uc.logger.Error("failed to get upload bytes", zap.Error(err))
+		return nil, fmt.Errorf("failed to get usage stats: %w", err)
+	}
+
+	return &model.UsageStats{
+		ActiveRooms:        activeRooms,
+		MessagesPerMinute:  messages,
+		UploadBytesPerHour: uploadBytes,
+		GeneratedAt:        time.Now(),
+	}, nil
+}
+
+func (uc *statsUseCase) GetRoomStats(ctx context.Context, roomID, requesterID string) (*model.RoomStats, error) {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can view room stats")
+	}
+
+	stats, err := uc.statsRepository.GetRoomStats(ctx, roomID)
+	if err != nil {
+		uc.logger.Error("failed to get room stats", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to get room stats: %w", err)
+	}
+
+	stats.Members = len(room.Members)
+	return stats, nil
+}
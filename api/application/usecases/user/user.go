@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/domain/apperror"
 	"github.com/hilthontt/visper/api/domain/model"
 	"github.com/hilthontt/visper/api/domain/repository"
 	"github.com/hilthontt/visper/api/infrastructure/logger"
@@ -22,23 +23,30 @@ type UserUseCase interface {
 	UpdateUsername(ctx context.Context, userID string, newUsername string) error
 	Delete(ctx context.Context, id string) error
 	IsUsernameAvailable(ctx context.Context, username string) (bool, error)
+	IsBanned(ctx context.Context, id string) (bool, error)
 }
 
 type userUseCase struct {
-	repository repository.UserRepository
-	logger     *logger.Logger
+	repository    repository.UserRepository
+	banRepository repository.BanRepository
+	logger        *logger.Logger
 }
 
-func NewUserUseCase(repository repository.UserRepository, logger *logger.Logger) UserUseCase {
+func NewUserUseCase(
+	repository repository.UserRepository,
+	banRepository repository.BanRepository,
+	logger *logger.Logger,
+) UserUseCase {
 	return &userUseCase{
-		repository: repository,
-		logger:     logger,
+		repository:    repository,
+		banRepository: banRepository,
+		logger:        logger,
 	}
 }
 
 func (uc *userUseCase) GetOrCreateUser(ctx context.Context, id string) (*model.User, error) {
 	if id == "" {
-		return nil, fmt.Errorf("user ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
 	}
 
 	// Try to get existing user
@@ -110,7 +118,7 @@ func (uc *userUseCase) Create(ctx context.Context, username string) (*model.User
 
 func (uc *userUseCase) Delete(ctx context.Context, id string) error {
 	if id == "" {
-		return fmt.Errorf("user ID cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
 	}
 
 	user, err := uc.repository.GetByID(ctx, id)
@@ -133,7 +141,7 @@ func (uc *userUseCase) Delete(ctx context.Context, id string) error {
 
 func (uc *userUseCase) GetByID(ctx context.Context, id string) (*model.User, error) {
 	if id == "" {
-		return nil, fmt.Errorf("user ID cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
 	}
 
 	user, err := uc.repository.GetByID(ctx, id)
@@ -147,7 +155,7 @@ func (uc *userUseCase) GetByID(ctx context.Context, id string) (*model.User, err
 
 func (uc *userUseCase) GetByUsername(ctx context.Context, username string) (*model.User, error) {
 	if username == "" {
-		return nil, fmt.Errorf("username cannot be empty")
+		return nil, apperror.ErrInvalidInput.WithMessage("username cannot be empty")
 	}
 
 	user, err := uc.repository.GetByUsername(ctx, username)
@@ -161,7 +169,7 @@ func (uc *userUseCase) GetByUsername(ctx context.Context, username string) (*mod
 
 func (uc *userUseCase) IsUsernameAvailable(ctx context.Context, username string) (bool, error) {
 	if username == "" {
-		return false, fmt.Errorf("username cannot be empty")
+		return false, apperror.ErrInvalidInput.WithMessage("username cannot be empty")
 	}
 
 	_, err := uc.repository.GetByUsername(ctx, username)
@@ -174,9 +182,27 @@ func (uc *userUseCase) IsUsernameAvailable(ctx context.Context, username string)
 	return false, nil
 }
 
+func (uc *userUseCase) IsBanned(ctx context.Context, id string) (bool, error) {
+	if id == "" {
+		return false, apperror.ErrInvalidInput.WithMessage("user ID cannot be empty")
+	}
+
+	_, err := uc.banRepository.GetByUserID(ctx, id)
+	if err == nil {
+		return true, nil
+	}
+
+	if err == redis.Nil {
+		return false, nil
+	}
+
+	uc.logger.Error("failed to check user ban", zap.Error(err), zap.String("userID", id))
+	return false, fmt.Errorf("failed to check user ban: %w", err)
+}
+
 func (uc *userUseCase) UpdateUsername(ctx context.Context, userID string, newUsername string) error {
 	if userID == "" {
-		return fmt.Errorf("user Id cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("user Id cannot be empty")
 	}
 
 	if err := uc.validateUsername(newUsername); err != nil {
@@ -230,27 +256,27 @@ func (uc *userUseCase) validateUsername(username string) error {
 	username = strings.TrimSpace(username)
 
 	if username == "" {
-		return fmt.Errorf("username cannot be empty")
+		return apperror.ErrInvalidInput.WithMessage("username cannot be empty")
 	}
 
 	if len(username) < 3 {
-		return fmt.Errorf("username must be at least 3 characters long")
+		return apperror.ErrInvalidInput.WithMessage("username must be at least 3 characters long")
 	}
 
 	if len(username) > 20 {
-		return fmt.Errorf("username must be at most 20 characters long")
+		return apperror.ErrInvalidInput.WithMessage("username must be at most 20 characters long")
 	}
 
 	// Check for valid characters (alphanumeric, underscore, hyphen)
 	for _, char := range username {
 		if !isValidUsernameChar(char) {
-			return fmt.Errorf("username can only contain letters, numbers, underscores, and hyphens")
+			return apperror.ErrInvalidInput.WithMessage("username can only contain letters, numbers, underscores, and hyphens")
 		}
 	}
 
 	firstChar := rune(username[0])
 	if !isAlphanumeric(firstChar) {
-		return fmt.Errorf("username must start with a letter or number")
+		return apperror.ErrInvalidInput.WithMessage("username must start with a letter or number")
 	}
 
 	return nil
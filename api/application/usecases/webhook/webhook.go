@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+	"go.uber.org/zap"
+)
+
+const (
+	maxWebhooksPerRoom = 5
+	maxURLLength       = 2048
+)
+
+type WebhookUseCase interface {
+	Create(ctx context.Context, roomID, requesterID, rawURL string, events []model.WebhookEvent) (*model.RoomWebhook, error)
+	List(ctx context.Context, roomID, requesterID string) ([]*model.RoomWebhook, error)
+	Delete(ctx context.Context, roomID, webhookID, requesterID string) error
+	GetDeliveries(ctx context.Context, roomID, webhookID, requesterID string, limit int64) ([]*model.WebhookDelivery, error)
+	CreateIncomingToken(ctx context.Context, roomID, requesterID string) (string, error)
+	RevokeIncomingToken(ctx context.Context, roomID, requesterID string) error
+	ResolveIncomingToken(ctx context.Context, token string) (*model.Room, error)
+}
+
+type webhookUseCase struct {
+	repository     repository.WebhookRepository
+	roomRepository repository.RoomRepository
+	logger         *logger.Logger
+}
+
+func NewWebhookUseCase(
+	repository repository.WebhookRepository,
+	roomRepository repository.RoomRepository,
+	logger *logger.Logger,
+) WebhookUseCase {
+	return &webhookUseCase{
+		repository:     repository,
+		roomRepository: roomRepository,
+		logger:         logger,
+	}
+}
+
+func (uc *webhookUseCase) Create(ctx context.Context, roomID, requesterID, rawURL string, events []model.WebhookEvent) (*model.RoomWebhook, error) {
+	room, err := uc.getOwnedRoom(ctx, roomID, requesterID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateWebhookURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if !event.IsValid() {
+			return nil, apperror.ErrInvalidInput.WithMessage("unknown webhook event")
+		}
+	}
+
+	count, err := uc.repository.Count(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	if count >= maxWebhooksPerRoom {
+		return nil, apperror.ErrLimitReached.WithMessage("room has too many webhooks")
+	}
+
+	webhook := &model.RoomWebhook{
+		ID:        uuid.NewString(),
+		RoomID:    roomID,
+		URL:       rawURL,
+		Secret:    rand.Text(),
+		Events:    events,
+		CreatedBy: requesterID,
+	}
+
+	if err := uc.repository.Create(ctx, webhook, roomExpiresAt(room)); err != nil {
+		uc.logger.Error("failed to create webhook", zap.Error(err), zap.String("roomID", roomID))
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	uc.logger.Info("room webhook created", zap.String("roomID", roomID), zap.String("webhookID", webhook.ID))
+	return webhook, nil
+}
+
+func (uc *webhookUseCase) List(ctx context.Context, roomID, requesterID string) ([]*model.RoomWebhook, error) {
+	if _, err := uc.getOwnedRoom(ctx, roomID, requesterID); err != nil {
+		return nil, err
+	}
+
+	return uc.repository.GetAll(ctx, roomID)
+}
+
+func (uc *webhookUseCase) Delete(ctx context.Context, roomID, webhookID, requesterID string) error {
+	if _, err := uc.getOwnedRoom(ctx, roomID, requesterID); err != nil {
+		return err
+	}
+
+	removed, err := uc.repository.Delete(ctx, roomID, webhookID)
+	if err != nil {
+		uc.logger.Error("failed to delete webhook", zap.Error(err), zap.String("roomID", roomID), zap.String("webhookID", webhookID))
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if !removed {
+		return apperror.ErrWebhookNotFound
+	}
+
+	uc.logger.Info("room webhook deleted", zap.String("roomID", roomID), zap.String("webhookID", webhookID))
+	return nil
+}
+
+func (uc *webhookUseCase) GetDeliveries(ctx context.Context, roomID, webhookID, requesterID string, limit int64) ([]*model.WebhookDelivery, error) {
+	if _, err := uc.getOwnedRoom(ctx, roomID, requesterID); err != nil {
+		return nil, err
+	}
+
+	webhook, err := uc.repository.Get(ctx, roomID, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	if webhook == nil {
+		return nil, apperror.ErrWebhookNotFound
+	}
+
+	return uc.repository.GetDeliveries(ctx, roomID, webhookID, limit)
+}
+
+// CreateIncomingToken rotates the room's incoming token, the plain token is only returned here
+func (uc *webhookUseCase) CreateIncomingToken(ctx context.Context, roomID, requesterID string) (string, error) {
+	room, err := uc.getOwnedRoom(ctx, roomID, requesterID)
+	if err != nil {
+		return "", err
+	}
+
+	token := rand.Text()
+	if err := uc.repository.SetIncomingToken(ctx, roomID, hashToken(token), roomExpiresAt(room)); err != nil {
+		uc.logger.Error("failed to create incoming webhook token", zap.Error(err), zap.String("roomID", roomID))
+		return "", fmt.Errorf("failed to create incoming webhook: %w", err)
+	}
+
+	uc.logger.Info("incoming webhook token created", zap.String("roomID", roomID))
+	return token, nil
+}
+
+func (uc *webhookUseCase) RevokeIncomingToken(ctx context.Context, roomID, requesterID string) error {
+	if _, err := uc.getOwnedRoom(ctx, roomID, requesterID); err != nil {
+		return err
+	}
+
+	removed, err := uc.repository.DeleteIncomingToken(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke incoming webhook: %w", err)
+	}
+	if !removed {
+		return apperror.ErrWebhookNotFound.WithMessage("incoming webhook not found")
+	}
+
+	uc.logger.Info("incoming webhook token revoked", zap.String("roomID", roomID))
+	return nil
+}
+
+func (uc *webhookUseCase) ResolveIncomingToken(ctx context.Context, token string) (*model.Room, error) {
+	if token == "" {
+		return nil, apperror.ErrUnauthorized.WithMessage("invalid webhook token")
+	}
+
+	roomID, err := uc.repository.GetRoomByIncomingToken(ctx, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve webhook token: %w", err)
+	}
+	if roomID == "" {
+		return nil, apperror.ErrUnauthorized.WithMessage("invalid webhook token")
+	}
+
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrUnauthorized.WithMessage("invalid webhook token")
+	}
+
+	return room, nil
+}
+
+func (uc *webhookUseCase) getOwnedRoom(ctx context.Context, roomID, requesterID string) (*model.Room, error) {
+	room, err := uc.roomRepository.GetByID(ctx, roomID)
+	if err != nil || room == nil {
+		return nil, apperror.ErrRoomNotFound
+	}
+
+	if room.Owner.ID != requesterID {
+		uc.logger.Warn("unauthorized webhook management attempt", zap.String("roomID", roomID), zap.String("requesterID", requesterID), zap.String("ownerID", room.Owner.ID))
+		return nil, apperror.ErrNotOwner.WithMessage("only the room owner can manage webhooks")
+	}
+
+	return room, nil
+}
+
+// The dispatcher re-checks every resolved address, this only turns away URLs that can never work
+func validateWebhookURL(rawURL string) error {
+	if len(rawURL) > maxURLLength {
+		return apperror.ErrInvalidInput.WithMessage("invalid webhook url")
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Host == "" || target.User != nil {
+		return apperror.ErrInvalidInput.WithMessage("invalid webhook url")
+	}
+
+	if target.Scheme != "https" && target.Scheme != "http" {
+		return apperror.ErrInvalidInput.WithMessage("invalid webhook url")
+	}
+
+	if ip := net.ParseIP(target.Hostname()); ip != nil && !security.IsPublicIP(ip) {
+		return apperror.ErrInvalidInput.WithMessage("invalid webhook url")
+	}
+
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// roomExpiresAt is zero for rooms that never expire
+func roomExpiresAt(room *model.Room) time.Time {
+	if room.Expiry <= 0 {
+		return time.Time{}
+	}
+	return room.CreatedAt.Add(room.Expiry)
+}
@@ -18,9 +18,6 @@ import (
 )
 
 func main() {
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer shutdownCancel()
-
 	cfg := config.GetConfig()
 	err := sentry.Init(sentry.ClientOptions{
 		Dsn:            cfg.Sentry.Dsn,
@@ -32,11 +29,10 @@ func main() {
 	}
 	defer sentry.Flush(2 * time.Second)
 
-	container, err := dependency.NewContainer(shutdownCtx)
+	container, err := dependency.NewContainer(context.Background())
 	if err != nil {
 		log.Fatal(fmt.Errorf("failed to initialize dependencies: %w", err))
 	}
-	defer container.Shutdown()
 
 	var wg sync.WaitGroup
 
@@ -77,13 +73,16 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	container.Logger.Info("Shutting down server...")
+	container.Logger.Info("Shutting down server...", zap.Duration("timeout", container.ShutdownTimeout()))
 
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		container.Logger.Fatal("Server forced to shutdown", zap.Error(err))
-	}
+	// The deadline starts now, a context created at startup would long have expired
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), container.ShutdownTimeout())
+	defer shutdownCancel()
 
-	container.EventConsumer.Stop()
+	if err := container.Shutdown(shutdownCtx, srv); err != nil {
+		container.Logger.Error("Server forced to shutdown", zap.Error(err))
+		return
+	}
 
 	wg.Wait()
 
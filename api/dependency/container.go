@@ -3,27 +3,61 @@ package dependency
 import (
 	"context"
 	"fmt"
+	"sync"
 
+	adminUseCase "github.com/hilthontt/visper/api/application/usecases/admin"
+	authUseCase "github.com/hilthontt/visper/api/application/usecases/auth"
+	botUseCase "github.com/hilthontt/visper/api/application/usecases/bot"
+	exportUseCase "github.com/hilthontt/visper/api/application/usecases/export"
 	fileUseCase "github.com/hilthontt/visper/api/application/usecases/file"
 	messageUseCase "github.com/hilthontt/visper/api/application/usecases/message"
+	notificationUseCase "github.com/hilthontt/visper/api/application/usecases/notification"
+	privacyUseCase "github.com/hilthontt/visper/api/application/usecases/privacy"
+	reactionUseCase "github.com/hilthontt/visper/api/application/usecases/reaction"
 	roomUseCase "github.com/hilthontt/visper/api/application/usecases/room"
+	shortLinkUseCase "github.com/hilthontt/visper/api/application/usecases/shortlink"
+	statsUseCase "github.com/hilthontt/visper/api/application/usecases/stats"
 	userUseCase "github.com/hilthontt/visper/api/application/usecases/user"
+	webhookUseCase "github.com/hilthontt/visper/api/application/usecases/webhook"
 	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/activity"
+	"github.com/hilthontt/visper/api/infrastructure/broker"
 	"github.com/hilthontt/visper/api/infrastructure/cache"
 	"github.com/hilthontt/visper/api/infrastructure/config"
 	"github.com/hilthontt/visper/api/infrastructure/events"
 	"github.com/hilthontt/visper/api/infrastructure/jobs"
+	"github.com/hilthontt/visper/api/infrastructure/linkpreview"
 	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/maintenance"
 	"github.com/hilthontt/visper/api/infrastructure/metrics"
+	"github.com/hilthontt/visper/api/infrastructure/moderation"
+	"github.com/hilthontt/visper/api/infrastructure/oidc"
 	"github.com/hilthontt/visper/api/infrastructure/profiler"
+	"github.com/hilthontt/visper/api/infrastructure/push"
+	"github.com/hilthontt/visper/api/infrastructure/scanner"
+	"github.com/hilthontt/visper/api/infrastructure/security"
 	"github.com/hilthontt/visper/api/infrastructure/storage"
+	"github.com/hilthontt/visper/api/infrastructure/webhook"
 	"github.com/hilthontt/visper/api/infrastructure/websocket"
+	"github.com/hilthontt/visper/api/infrastructure/workerpool"
+	"github.com/hilthontt/visper/api/presentation/controllers/admin"
+	authCtrl "github.com/hilthontt/visper/api/presentation/controllers/auth"
+	"github.com/hilthontt/visper/api/presentation/controllers/bot"
 	"github.com/hilthontt/visper/api/presentation/controllers/file"
+	"github.com/hilthontt/visper/api/presentation/controllers/loglevel"
 	"github.com/hilthontt/visper/api/presentation/controllers/message"
+	"github.com/hilthontt/visper/api/presentation/controllers/notification"
+	"github.com/hilthontt/visper/api/presentation/controllers/profiles"
 	"github.com/hilthontt/visper/api/presentation/controllers/room"
+	"github.com/hilthontt/visper/api/presentation/controllers/shortlink"
+	"github.com/hilthontt/visper/api/presentation/controllers/stats"
+	"github.com/hilthontt/visper/api/presentation/controllers/user"
+	webhookCtrl "github.com/hilthontt/visper/api/presentation/controllers/webhook"
 	wsCtrl "github.com/hilthontt/visper/api/presentation/controllers/websocket"
 	"github.com/hilthontt/visper/api/presentation/middlewares"
+	"github.com/nats-io/nats.go"
 	"go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
 )
 
 type Container struct {
@@ -39,33 +73,102 @@ type Container struct {
 	FileRepo     repository.FileRepository
 	AuditLogRepo repository.AuditLogRepository
 
+	BanRepo       repository.BanRepository
+	RateLimitRepo repository.RateLimitRepository
+	StatsRepo     repository.StatsRepository
+	ShortLinkRepo repository.ShortLinkRepository
+
+	PushSubscriptionRepo repository.PushSubscriptionRepository
+	NotificationPrefRepo repository.NotificationPreferenceRepository
+	ReactionRepo         repository.ReactionRepository
+	MuteRepo             repository.MuteRepository
+	RoomBanRepo          repository.RoomBanRepository
+	SlowModeRepo         repository.SlowModeRepository
+	RoomInviteRepo       repository.RoomInviteRepository
+	SocketTicketRepo     repository.SocketTicketRepository
+	AnnouncementRepo     repository.AnnouncementRepository
+	MembershipLogRepo    repository.MembershipLogRepository
+	ExportLimitRepo      repository.ExportLimitRepository
+	WebhookRepo          repository.WebhookRepository
+	BotRepo              repository.BotRepository
+	IdentityRepo         repository.IdentityRepository
+	FeatureFlagRepo      repository.FeatureFlagRepository
+
 	WSRoomManager    *websocket.RoomManager
 	WSCore           *websocket.Core
 	NotificationCore *websocket.NotificationCore
 
-	MessageUC messageUseCase.MessageUseCase
-	RoomUC    roomUseCase.RoomUseCase
-	UserUC    userUseCase.UserUseCase
-	FileUC    fileUseCase.FileUseCase
+	MessageUC      messageUseCase.MessageUseCase
+	RoomUC         roomUseCase.RoomUseCase
+	UserUC         userUseCase.UserUseCase
+	FileUC         fileUseCase.FileUseCase
+	AdminUC        adminUseCase.AdminUseCase
+	StatsUC        statsUseCase.StatsUseCase
+	ExportUC       exportUseCase.ExportUseCase
+	ShortLinkUC    shortLinkUseCase.ShortLinkUseCase
+	NotificationUC notificationUseCase.NotificationUseCase
+	ReactionUC     reactionUseCase.ReactionUseCase
+	PrivacyUC      privacyUseCase.PrivacyUseCase
+	WebhookUC      webhookUseCase.WebhookUseCase
+	BotUC          botUseCase.BotUseCase
+	AuthUC         authUseCase.AuthUseCase // Nil unless OIDC login is enabled
 
 	MessageController          message.MessageController
 	RoomController             room.RoomController
 	WebsocketController        wsCtrl.WebSocketController
 	FilesController            file.FilesController
 	UserNotificationController wsCtrl.UserNotificationController
-
-	ETagStore middlewares.ETagStore
-	Storage   *storage.LocalStorage
-
-	FileCleanupJob   *jobs.FileCleanupJob
-	Profiler         *profiler.AdaptiveProfiler
-	DistributedCache *cache.DistributedCache
+	AdminController            admin.AdminController
+	StatsController            stats.StatsController
+	LogLevelController         loglevel.LogLevelController
+	ProfilesController         profiles.ProfilesController
+	ShortLinkController        shortlink.ShortLinkController
+	NotificationController     notification.NotificationController
+	UserController             user.UserController
+	WebhookController          webhookCtrl.WebhookController
+	BotController              bot.BotController
+	AuthController             authCtrl.AuthController
+
+	ETagStore          middlewares.ETagStore
+	RateLimitAllowlist *middlewares.RateLimitAllowlist
+	FeatureFlags       *middlewares.FeatureFlagStore
+	RateLimitTuning    *middlewares.RateLimitTuning
+	CorsOrigins        *middlewares.CorsOrigins
+	Maintenance        *maintenance.Mode
+	PushDispatcher     *push.Dispatcher
+	Moderation         *moderation.Pipeline
+	LinkPreviews       *linkpreview.Fetcher
+	Webhooks           *webhook.Dispatcher
+	RoomActivity       *activity.RoomTracker
+	VAPIDPublicKey     string
+	Storage            storage.Storage
+	URLSigner          *storage.URLSigner
+	MemberTokens       *security.MemberTokenSigner
+	OIDC               *oidc.Provider // Nil unless OIDC login is enabled
+	ImageWorkers       *workerpool.Pool
+	Scanner            scanner.Scanner
+
+	FileCleanupJob    *jobs.FileCleanupJob
+	MessageCleanupJob *jobs.MessageCleanupJob
+	RoomExpiryJob     *jobs.RoomExpiryJob
+	AuditRetentionJob *jobs.AuditRetentionJob
+	Profiler          *profiler.AdaptiveProfiler
+	DistributedCache  *cache.DistributedCache
+	CacheSnapshots    *cache.Snapshotter // Nil unless cache.snapshotPath is set
 
 	EventConsumer  *events.EventConsumer
 	EventPublisher *events.EventPublisher
-
-	ctx    context.Context
-	cancel context.CancelFunc
+	Broker         *broker.Broker // Nil unless events go through the embedded broker
+	BrokerClient   *broker.Client // Nil unless events go through a remote broker
+	BrokerServer   *broker.Server
+	NATSConn       *nats.Conn
+
+	// ctx is cancelled once the WebSockets are drained, jobsCancel stops the background jobs
+	// and jobs tracks them until they have returned
+	ctx        context.Context
+	cancel     context.CancelFunc
+	jobsCancel context.CancelFunc
+	jobs       sync.WaitGroup
 }
 
 func NewContainer(ctx context.Context) (*Container, error) {
@@ -73,11 +176,21 @@ func NewContainer(ctx context.Context) (*Container, error) {
 
 	c.Config = config.GetConfig()
 
-	loggerInstance, err := logger.NewDevelopmentLogger()
+	// Stack traces on errors are kept in every environment, as they always were
+	loggerInstance, err := logger.New(logger.Options{
+		Encoding:           c.Config.Logger.Encoding,
+		Level:              c.Config.Logger.Level,
+		Development:        true,
+		SamplingInitial:    c.Config.Logger.SamplingInitial,
+		SamplingThereafter: c.Config.Logger.SamplingThereafter,
+		SubsystemLevels:    c.Config.Logger.Levels,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error initializing logger: %w", err)
 	}
 	c.Logger = loggerInstance
+	websocket.SetLogger(c.Logger.Subsystem(logger.SubsystemWS))
+	events.SetLogger(c.Logger.Subsystem(logger.SubsystemEvents))
 
 	c.Logger.Info("Initializing Visper API dependencies")
 
@@ -99,14 +212,33 @@ func NewContainer(ctx context.Context) (*Container, error) {
 	c.initUseCases()
 	c.initMiddleware()
 	c.initControllers()
+	if c.Config.Server.WatchConfig {
+		c.Config.Watch(c.applyReloadedConfig)
+	}
 
-	wsCtx, cancel := context.WithCancel(ctx)
-	c.cancel = cancel
-	c.initBackgroundJobs(wsCtx)
+	jobsCtx, cancel := context.WithCancel(ctx)
+	c.jobsCancel = cancel
+	c.initBackgroundJobs(jobsCtx)
 
-	c.initProfile()
+	c.renderProfileReports()
 
 	c.Logger.Info("All dependencies initialized successfully")
 
 	return c, nil
 }
+
+// applyReloadedConfig puts the values that are safe to change while running into effect
+func (c *Container) applyReloadedConfig(cfg *config.Config) {
+	c.RateLimitTuning.Set(middlewares.RateLimitAlgorithm(cfg.RateLimit.Algorithm), cfg.RateLimit.BurstRatio)
+	c.CorsOrigins.Set(cfg.Cors.AllowOrigins)
+	if err := c.Logger.Configure(cfg.Logger.Level, cfg.Logger.Levels); err != nil {
+		c.Logger.Warn("failed to apply reloaded log levels", zap.Error(err))
+	}
+
+	c.Logger.Info("config reloaded",
+		zap.String("rateLimitAlgorithm", cfg.RateLimit.Algorithm),
+		zap.Float64("burstRatio", cfg.RateLimit.BurstRatio),
+		zap.String("corsOrigins", cfg.Cors.AllowOrigins),
+		zap.String("logLevel", cfg.Logger.Level),
+	)
+}
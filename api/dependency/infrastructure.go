@@ -1,6 +1,7 @@
 package dependency
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"io/fs"
@@ -11,33 +12,48 @@ import (
 	"time"
 
 	"github.com/hilthontt/visper/api/infrastructure/broker"
+	"github.com/hilthontt/visper/api/infrastructure/cache"
+	"github.com/hilthontt/visper/api/infrastructure/crypto"
 	"github.com/hilthontt/visper/api/infrastructure/events"
 	"github.com/hilthontt/visper/api/infrastructure/jobs"
+	"github.com/hilthontt/visper/api/infrastructure/linkpreview"
+	"github.com/hilthontt/visper/api/infrastructure/maintenance"
 	"github.com/hilthontt/visper/api/infrastructure/metrics"
 	"github.com/hilthontt/visper/api/infrastructure/metrics/exporters"
+	"github.com/hilthontt/visper/api/infrastructure/moderation"
+	"github.com/hilthontt/visper/api/infrastructure/oidc"
 	"github.com/hilthontt/visper/api/infrastructure/persistence/database"
 	"github.com/hilthontt/visper/api/infrastructure/persistence/migration"
 	"github.com/hilthontt/visper/api/infrastructure/profiler"
+	"github.com/hilthontt/visper/api/infrastructure/push"
+	"github.com/hilthontt/visper/api/infrastructure/scanner"
+	"github.com/hilthontt/visper/api/infrastructure/security"
 	"github.com/hilthontt/visper/api/infrastructure/storage"
+	"github.com/hilthontt/visper/api/infrastructure/workerpool"
+	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 )
 
+// imageQueueSize is how many uploads can wait for their thumbnails before new ones go without
+const imageQueueSize = 100
+
 func (c *Container) initInfrastructure() error {
 	c.initDatabase()
 
-	tracerProvider, err := exporters.InitJaegerExporter(c.Config)
+	tracerProvider, err := exporters.InitTracerProvider(c.Config)
 	if err != nil {
-		c.Logger.Error("failed to initialize Jaeger exporter", zap.Error(err))
+		c.Logger.Error("failed to initialize trace exporter", zap.Error(err))
 		// Use noop tracer provider as fallback
 		c.Logger.Warn("Using noop tracer provider as fallback")
 	} else {
 		c.TracerProvider = tracerProvider
-		c.Logger.Info("Jaeger exporter initialized successfully",
-			zap.String("endpoint", c.Config.Jaeger.Endpoint),
+		c.Logger.Info("Trace exporter initialized successfully",
+			zap.String("exporter", c.Config.Tracing.Exporter),
+			zap.String("sampler", c.Config.Tracing.Sampler),
 			zap.String("service", c.Config.Jaeger.ServiceName),
 		)
 
-		go exporters.SendTelemetryTrace(c.Config)
+		go exporters.SendTelemetryTrace(c.Config, tracerProvider)
 	}
 
 	meter := exporters.Prometheus(c.Config.Jaeger.ServiceName, c.Config.Jaeger.ServiceVersion)
@@ -60,32 +76,241 @@ func (c *Container) initInfrastructure() error {
 	c.MetricsManager.NewUpDownCounter("active_websocket_connections", "Number of active WebSocket connections")
 	c.MetricsManager.NewCounter("websocket_messages_sent", "Total number of WebSocket messages sent")
 	c.MetricsManager.NewCounter("websocket_messages_received", "Total number of WebSocket messages received")
+	c.MetricsManager.NewHistogram("websocket_ack_latency_seconds", "Time between sending a WebSocket event and its ack",
+		0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0)
+	c.MetricsManager.NewCounter("websocket_retransmits", "Total number of WebSocket events resent for lack of an ack")
+	c.MetricsManager.NewCounter("websocket_connections_reaped", "Total number of WebSocket connections closed for missing pongs")
+	c.MetricsManager.NewCounter("websocket_frames_throttled", "Total number of inbound WebSocket frames dropped by the per-connection limiter")
+	c.MetricsManager.NewCounter("websocket_auth_rejections", "Total number of WebSocket connections refused during authentication, by reason")
+	c.MetricsManager.NewCounter("websocket_frame_bans", "Total number of users temporarily banned from sending WebSocket frames")
+	c.MetricsManager.NewCounter("websocket_unhealthy_connections", "Total number of WebSocket connections closed for not acknowledging events")
+	c.MetricsManager.NewCounter("push_notifications_sent", "Total number of push notifications delivered")
+	c.MetricsManager.NewCounter("push_notifications_failed", "Total number of push notifications that failed to deliver")
+	c.MetricsManager.NewCounter("push_subscriptions_expired", "Total number of push subscriptions dropped by the push service")
+	c.MetricsManager.NewCounter("messages_flagged", "Total number of messages matched by a room content filter")
+	c.MetricsManager.NewCounter("rooms_reaped", "Total number of expired rooms deleted by the expiry job")
+	c.MetricsManager.NewCounter("repository_operations_total", "Total number of repository operations, by repository, backend, operation and error")
+	c.MetricsManager.NewHistogram("repository_operation_duration_seconds", "Repository operation duration in seconds",
+		0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0)
+	c.MetricsManager.NewGauge("redis_pool_hits", "Times a free connection was found in the Redis pool")
+	c.MetricsManager.NewGauge("redis_pool_misses", "Times no free connection was found in the Redis pool")
+	c.MetricsManager.NewGauge("redis_pool_timeouts", "Times waiting for a Redis pool connection timed out")
+	c.MetricsManager.NewGauge("redis_pool_total_conns", "Connections in the Redis pool")
+	c.MetricsManager.NewGauge("redis_pool_idle_conns", "Idle connections in the Redis pool")
+	c.MetricsManager.NewGauge("redis_pool_stale_conns", "Stale connections removed from the Redis pool")
+	c.MetricsManager.NewGauge("redis_up", "Whether the last Redis health check reached every node")
+	c.MetricsManager.NewGauge("redis_reconnects", "Times Redis became reachable again after a failed health check")
+	c.MetricsManager.NewGauge("redis_connections_opened", "Connections opened to Redis, including reconnects")
+	c.MetricsManager.NewCounter("room_messages_total", "Total number of messages sent, by room")
+	c.MetricsManager.NewHistogram("room_message_size_bytes", "Size of sent messages in bytes, by room",
+		16, 64, 128, 256, 512, 1024, 2048, 4096, 8192)
+	c.MetricsManager.NewGauge("cache_hits", "Cache lookups that found their key, by cache and layer")
+	c.MetricsManager.NewGauge("cache_misses", "Cache lookups that didn't find their key, by cache and layer")
+	c.MetricsManager.NewGauge("cache_evictions", "Items that left a cache, by cache and reason")
+	c.MetricsManager.NewGauge("cache_items", "Items held in a cache")
+	c.MetricsManager.NewGauge("cache_bytes", "Summed cost of the items held in a cache")
+	c.MetricsManager.NewGauge("room_active_connections", "WebSocket connections open to the room on this instance")
+	c.MetricsManager.NewGauge("room_peak_connections", "Most WebSocket connections the room has had open at once on this instance")
 
 	c.Logger.Info("Metrics initialized successfully")
 
-	storage, err := storage.NewLocalStorage()
+	c.initProfiler()
+	c.initPush()
+
+	c.Moderation = moderation.NewPipeline(moderation.NewWordlistFilter(moderation.DefaultWords))
+	c.LinkPreviews = linkpreview.NewFetcher()
+
+	c.Maintenance = maintenance.NewMode(c.Config.Maintenance.Enabled, c.Config.Maintenance.Message)
+	if c.Maintenance.IsEnabled() {
+		c.Logger.Warn("API starting in read-only maintenance mode")
+	}
+
+	signingSecret := c.Config.Files.SigningSecret
+	if signingSecret == "" {
+		signingSecret, err = crypto.GenerateKeyBase64()
+		if err != nil {
+			return fmt.Errorf("failed to generate file signing secret: %w", err)
+		}
+		c.Logger.Warn("files.signingSecret is not set, download links stop working on restart and across instances")
+	}
+	c.URLSigner = storage.NewURLSigner(signingSecret)
+
+	tokenKeys := c.Config.Auth.TokenKeys
+	if len(tokenKeys) == 0 {
+		secret, err := crypto.GenerateKeyBase64()
+		if err != nil {
+			return fmt.Errorf("failed to generate member token key: %w", err)
+		}
+		tokenKeys = []string{"generated:" + secret}
+		c.Logger.Warn("auth.tokenKeys is not set, members are signed out on restart and across instances")
+	}
+	c.MemberTokens, err = security.NewMemberTokenSigner(tokenKeys, c.Config.Auth.TokenTTL)
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid member token keys: %w", err)
+	}
+	if c.Config.Auth.AllowUserIDHeader {
+		c.Logger.Warn("auth.allowUserIDHeader is on, X-User-ID is trusted without a signature")
+	}
+	if c.Config.OIDC.Enabled {
+		c.OIDC = oidc.NewProvider(oidc.Config{
+			Issuer:       c.Config.OIDC.Issuer,
+			ClientID:     c.Config.OIDC.ClientID,
+			ClientSecret: c.Config.OIDC.ClientSecret,
+			RedirectURL:  c.Config.OIDC.RedirectURL,
+			Scopes:       c.Config.OIDC.Scopes,
+		})
+		c.Logger.Info("OIDC login enabled", zap.String("issuer", c.Config.OIDC.Issuer))
+	}
+	c.ImageWorkers = workerpool.New(c.Config.Files.ResizeWorkers, imageQueueSize, c.Logger)
+	c.initScanner()
+
+	return c.initStorage()
+}
+
+func (c *Container) initStorage() error {
+	switch c.Config.Storage.Backend {
+	case "s3":
+		s3 := c.Config.Storage.S3
+		s3Storage, err := storage.NewS3Storage(storage.S3Config{
+			Endpoint:             s3.Endpoint,
+			Region:               s3.Region,
+			Bucket:               s3.Bucket,
+			AccessKey:            s3.AccessKey,
+			SecretKey:            s3.SecretKey,
+			PathStyle:            s3.PathStyle,
+			ServerSideEncryption: s3.ServerSideEncryption,
+			KMSKeyID:             s3.KMSKeyID,
+		})
+		if err != nil {
+			return err
+		}
+		c.Storage = s3Storage
+		c.Logger.Info("Uploads stored in s3", zap.String("bucket", s3.Bucket))
+	default:
+		localStorage, err := storage.NewLocalStorage(c.URLSigner, c.getServerURL()+"/api/v1/d")
+		if err != nil {
+			return err
+		}
+		c.Storage = localStorage
 	}
-	c.Storage = storage
 
 	return nil
 }
 
+// initScanner leaves Scanner nil when scanning is turned off, uploads are then marked as skipped
+func (c *Container) initScanner() {
+	if c.Config.Scanner.Backend == "none" {
+		c.Logger.Warn("Upload scanning is disabled")
+		return
+	}
+
+	address := c.Config.Scanner.ClamAVAddress
+	if address == "" {
+		address = "localhost:3310"
+	}
+	c.Scanner = scanner.NewClamAV(address)
+	c.Logger.Info("Uploads are scanned with clamav", zap.String("address", address))
+}
+
 func (c *Container) initBackgroundJobs(ctx context.Context) {
 	c.FileCleanupJob = jobs.NewFileCleanupJob(c.FileUC, c.Logger, 6*time.Hour)
+	c.MessageCleanupJob = jobs.NewMessageCleanupJob(c.MessageUC, c.RoomRepo, c.Logger, 15*time.Minute)
+	c.RoomExpiryJob = jobs.NewRoomExpiryJob(c.RoomRepo, c.MessageRepo, c.FileUC, c.WSCore, c.EventPublisher, c.MetricsManager, c.Logger, c.roomExpiryInterval(), c.Config.RoomExpiry.WarnBefore)
+	c.AuditRetentionJob = jobs.NewAuditRetentionJob(c.AuditLogRepo, c.Logger, c.auditRetention(), c.auditCheckInterval())
+
+	c.jobs.Go(func() { c.Logger.WatchSignals(ctx) })
+	c.jobs.Go(func() { cache.WatchRedis(ctx, c.redisHealthCheckInterval(), c.Logger) })
+	if c.CacheSnapshots != nil {
+		// Saves a last snapshot when the jobs are stopped, after the server has drained
+		c.jobs.Go(func() { c.CacheSnapshots.Start(ctx) })
+	}
+
+	c.jobs.Go(func() {
+		// Wait for all dependencies to initialize
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return
+		}
 
-	go func() {
-		time.Sleep(2 * time.Second) // Wait for all dependencies to initialize
 		c.Logger.Info("Starting background jobs...")
+		c.ImageWorkers.Start(ctx)
+		c.jobs.Go(func() { c.MessageCleanupJob.Start(ctx) })
+		c.jobs.Go(func() { c.RoomExpiryJob.Start(ctx) })
+		c.jobs.Go(func() { c.AuditRetentionJob.Start(ctx) })
+		c.jobs.Go(func() { c.RateLimitAllowlist.Watch(ctx, 30*time.Second) })
+		c.jobs.Go(func() { c.FeatureFlags.Watch(ctx, 30*time.Second) })
+		c.jobs.Go(func() { c.Profiler.Start(ctx) })
 		c.FileCleanupJob.Start(ctx)
-	}()
+	})
 
 	c.Logger.Info("Background jobs initialized and started successfully")
 }
 
-func (c *Container) initProfile() {
-	profileDir := "/var/log/myapp/profiles"
+func (c *Container) redisHealthCheckInterval() time.Duration {
+	if c.Config.Redis.HealthCheckInterval > 0 {
+		return c.Config.Redis.HealthCheckInterval
+	}
+	return 15 * time.Second
+}
+
+// roomExpiryInterval defaults to a minute, the finest warning threshold worth configuring
+func (c *Container) roomExpiryInterval() time.Duration {
+	if c.Config.RoomExpiry.CheckInterval > 0 {
+		return c.Config.RoomExpiry.CheckInterval
+	}
+	return time.Minute
+}
+
+// auditRetention keeps audit entries for 90 days unless configured
+func (c *Container) auditRetention() time.Duration {
+	if c.Config.Audit.Retention > 0 {
+		return c.Config.Audit.Retention
+	}
+	return 90 * 24 * time.Hour
+}
+
+func (c *Container) auditCheckInterval() time.Duration {
+	if c.Config.Audit.CheckInterval > 0 {
+		return c.Config.Audit.CheckInterval
+	}
+	return time.Hour
+}
+
+func (c *Container) initProfiler() {
+	cfg := c.Config.Profiler
+	c.Profiler = profiler.NewAdaptiveProfiler(profiler.Options{
+		Dir:           c.profileDir(),
+		CheckInterval: durationOr(cfg.CheckInterval, 15*time.Second),
+		MinInterval:   durationOr(cfg.MinInterval, 10*time.Minute),
+		CPUDuration:   durationOr(cfg.CPUDuration, 30*time.Second),
+		Retain:        cmp.Or(cfg.Retain, 5),
+		Triggers: profiler.Triggers{
+			CPUPercent: cfg.CPUPercent,
+			HeapMB:     cfg.HeapMB,
+			P99Latency: time.Duration(cfg.P99LatencyMs * float64(time.Millisecond)),
+		},
+		LatencyHistogram: "http_request_duration_seconds",
+	}, c.MetricsManager, c.Logger)
+}
+
+func (c *Container) profileDir() string {
+	if c.Config.Profiler.Dir != "" {
+		return c.Config.Profiler.Dir
+	}
+	return "/var/log/myapp/profiles"
+}
+
+func durationOr(value, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// renderProfileReports turns the CPU profiles captured in the last day into SVG and text reports
+func (c *Container) renderProfileReports() {
+	profileDir := c.profileDir()
 	reportDir := "/var/log/myapp/reports"
 
 	// Create report directory
@@ -178,17 +403,42 @@ func (c *Container) initProfile() {
 			zap.String("profileDir", profileDir),
 			zap.String("reportDir", reportDir))
 	}
-
-	c.Profiler = profiler.NewAdaptiveProfiler(profileDir)
-	c.Profiler.Start(c.ctx)
 }
 
 func (c *Container) initBroker() error {
+	if c.Config.Events.Transport == "nats" {
+		return c.initNATSEvents()
+	}
+	if c.Config.Events.BrokerAddress != "" {
+		return c.initRemoteBroker()
+	}
+	// ListenAndServe refuses it too, checked here so the instance doesn't start without its broker server
+	if address := c.Config.Events.BrokerListen; address != "" && c.Config.Events.BrokerToken == "" && !broker.IsLoopback(address) {
+		return fmt.Errorf("%w: %s, set events.brokerToken", broker.ErrUnauthenticatedListen, address)
+	}
+
 	brokerInstance, err := broker.NewBroker("./data/broker")
 	if err != nil {
 		return err
 	}
 
+	if nodes := c.Config.Events.BrokerNodes; len(nodes) > 0 {
+		err := brokerInstance.EnableReplication(broker.ReplicationConfig{
+			NodeID:            c.Config.Events.BrokerNodeID,
+			Nodes:             nodes,
+			Token:             c.Config.Events.BrokerToken,
+			ReplicationFactor: c.Config.Events.BrokerReplicationFactor,
+			MinInSyncReplicas: c.Config.Events.BrokerMinInSync,
+		})
+		if err != nil {
+			return err
+		}
+		c.Logger.Info("Broker partitions are replicated",
+			zap.Int("node_id", c.Config.Events.BrokerNodeID),
+			zap.Int("nodes", len(nodes)),
+		)
+	}
+
 	eventPublisher, err := events.NewEventPublisher(brokerInstance, "visper-events")
 	if err != nil {
 		return err
@@ -199,16 +449,118 @@ func (c *Container) initBroker() error {
 		return nil
 	}
 
+	c.Broker = brokerInstance
 	c.EventConsumer = eventConsumer
 	c.EventPublisher = eventPublisher
 
+	if address := c.Config.Events.BrokerListen; address != "" {
+		c.BrokerServer = broker.NewServer(brokerInstance, c.Config.Events.BrokerToken)
+		go func() {
+			if err := c.BrokerServer.ListenAndServe(address); err != nil {
+				c.Logger.Error("Broker server stopped", zap.String("address", address), zap.Error(err))
+			}
+		}()
+		c.Logger.Info("Broker is served to other processes", zap.String("address", address))
+	}
+
+	return nil
+}
+
+func (c *Container) initRemoteBroker() error {
+	client, err := broker.Dial(c.Config.Events.BrokerAddress, c.Config.Events.BrokerToken)
+	if err != nil {
+		return err
+	}
+
+	eventPublisher, err := events.NewRemoteEventPublisher(client, "visper-events")
+	if err != nil {
+		return err
+	}
+
+	c.EventConsumer = events.NewRemoteEventConsumer(client, "visper-consumer-group", "visper-events", c.AuditLogRepo)
+	c.EventPublisher = eventPublisher
+	c.BrokerClient = client
+
+	c.Logger.Info("Events go through a remote broker", zap.String("address", c.Config.Events.BrokerAddress))
 	return nil
 }
 
+func (c *Container) initNATSEvents() error {
+	conn, err := c.natsConn()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	js, err := events.NewNATSJetStream(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	eventConsumer, err := events.NewNATSEventConsumer(ctx, js, "visper-audit", c.AuditLogRepo)
+	if err != nil {
+		return err
+	}
+
+	c.EventConsumer = eventConsumer
+	c.EventPublisher = events.NewNATSEventPublisher(js)
+
+	c.Logger.Info("Events are published over NATS JetStream")
+	return nil
+}
+
+// natsConn connects on first use, the event transport and the WebSocket bus share the connection
+func (c *Container) natsConn() (*nats.Conn, error) {
+	if c.NATSConn != nil {
+		return c.NATSConn, nil
+	}
+
+	conn, err := nats.Connect(c.Config.NATS.URL,
+		nats.Name("visper-api"),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to nats: %w", err)
+	}
+
+	c.NATSConn = conn
+	return conn, nil
+}
+
 func (c *Container) initDatabase() {
 	err := database.InitDb(c.Config)
 	if err != nil {
 		c.Logger.Fatal("Failed to initialize database", zap.Error(err))
 	}
-	migration.Up1()
+	if err := migration.Run(database.GetDb()); err != nil {
+		c.Logger.Fatal("Failed to migrate database", zap.Error(err))
+	}
+}
+
+func (c *Container) initPush() {
+	cfg := c.Config.Push
+	c.PushDispatcher = push.NewDispatcher()
+
+	if cfg.VAPIDPrivateKey != "" {
+		sender, err := push.NewWebPushSender(cfg.VAPIDPublicKey, cfg.VAPIDPrivateKey, cfg.VAPIDSubject)
+		if err != nil {
+			c.Logger.Error("failed to initialize web push, continuing without it", zap.Error(err))
+		} else {
+			c.PushDispatcher.Register(push.PlatformWebPush, sender)
+			c.VAPIDPublicKey = sender.PublicKey()
+			c.Logger.Info("Web push initialized successfully")
+		}
+	}
+
+	if cfg.FCMProjectID != "" && cfg.FCMCredentialsFile != "" {
+		sender, err := push.NewFCMSender(cfg.FCMProjectID, cfg.FCMCredentialsFile)
+		if err != nil {
+			c.Logger.Error("failed to initialize FCM, continuing without it", zap.Error(err))
+		} else {
+			c.PushDispatcher.Register(push.PlatformFCM, sender)
+			c.Logger.Info("FCM initialized successfully")
+		}
+	}
 }
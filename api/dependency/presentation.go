@@ -8,12 +8,22 @@ import (
 	sentrygin "github.com/getsentry/sentry-go/gin"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/hilthontt/visper/api/domain/model"
 	"github.com/hilthontt/visper/api/infrastructure/cache"
 	"github.com/hilthontt/visper/api/infrastructure/metrics"
-	"github.com/hilthontt/visper/api/infrastructure/persistence/database"
+	"github.com/hilthontt/visper/api/presentation/controllers/admin"
+	authCtrl "github.com/hilthontt/visper/api/presentation/controllers/auth"
+	"github.com/hilthontt/visper/api/presentation/controllers/bot"
 	"github.com/hilthontt/visper/api/presentation/controllers/file"
+	"github.com/hilthontt/visper/api/presentation/controllers/loglevel"
 	"github.com/hilthontt/visper/api/presentation/controllers/message"
+	"github.com/hilthontt/visper/api/presentation/controllers/notification"
+	"github.com/hilthontt/visper/api/presentation/controllers/profiles"
 	"github.com/hilthontt/visper/api/presentation/controllers/room"
+	"github.com/hilthontt/visper/api/presentation/controllers/shortlink"
+	"github.com/hilthontt/visper/api/presentation/controllers/stats"
+	"github.com/hilthontt/visper/api/presentation/controllers/user"
+	"github.com/hilthontt/visper/api/presentation/controllers/webhook"
 	wsCtrl "github.com/hilthontt/visper/api/presentation/controllers/websocket"
 	"github.com/hilthontt/visper/api/presentation/middlewares"
 	"github.com/hilthontt/visper/api/presentation/routes"
@@ -22,16 +32,37 @@ import (
 
 func (c *Container) initMiddleware() {
 	c.ETagStore = middlewares.NewInMemoryETagStore()
+	c.RateLimitAllowlist = middlewares.NewRateLimitAllowlist(c.RateLimitRepo, c.Logger)
+	c.FeatureFlags = middlewares.NewFeatureFlagStore(c.FeatureFlagRepo, c.Logger)
+	c.RateLimitTuning = middlewares.NewRateLimitTuning(middlewares.RateLimitAlgorithm(c.Config.RateLimit.Algorithm), c.Config.RateLimit.BurstRatio)
+	c.CorsOrigins = middlewares.NewCorsOrigins(c.Config.Cors.AllowOrigins)
 
 	c.Logger.Info("Middleware components initialized successfully")
 }
 
 func (c *Container) initControllers() {
-	c.MessageController = message.NewMessageController(c.MessageUC, c.RoomUC, c.WSRoomManager, c.WSCore)
-	c.RoomController = room.NewRoomController(c.RoomUC, c.UserUC, c.WSRoomManager, c.WSCore, c.Config)
-	c.WebsocketController = wsCtrl.NewWebSocketController(c.RoomUC, c.UserUC, c.WSRoomManager, c.WSCore)
-	c.FilesController = file.NewFilesController(c.FileUC, c.Storage)
+	c.MessageController = message.NewMessageController(c.MessageUC, c.RoomUC, c.NotificationUC, c.ReactionUC, c.WSRoomManager, c.WSCore)
+	c.RoomController = room.NewRoomController(c.RoomUC, c.UserUC, c.ExportUC, c.FileUC, c.WSRoomManager, c.WSCore, c.MemberTokens, c.Config)
+	c.WebsocketController = wsCtrl.NewWebSocketController(c.RoomUC, c.UserUC, c.WSRoomManager, c.WSCore, c.MetricsManager, c.Config.WebSocket.RequireAuthFrame)
+	c.FilesController = file.NewFilesController(c.FileUC, c.RoomUC, c.Storage, c.WSCore)
 	c.UserNotificationController = wsCtrl.NewUserNotificationController(c.UserUC, c.RoomUC, c.NotificationCore)
+	c.AdminController = admin.NewAdminController(c.AdminUC, c.WSCore, c.Maintenance, c.Broker, c.RateLimitAllowlist, c.FeatureFlags)
+	c.StatsController = stats.NewStatsController(c.StatsUC, c.WSCore)
+	c.LogLevelController = loglevel.NewLogLevelController(c.Logger)
+	c.ProfilesController = profiles.NewProfilesController(c.Profiler)
+	c.ShortLinkController = shortlink.NewShortLinkController(c.ShortLinkUC)
+	c.NotificationController = notification.NewNotificationController(c.NotificationUC, c.VAPIDPublicKey)
+	c.UserController = user.NewUserController(c.PrivacyUC, c.WSCore)
+	c.BotController = bot.NewBotController(c.BotUC, c.RoomUC, c.WSCore)
+	c.WebhookController = webhook.NewWebhookController(c.WebhookUC, c.MessageUC, c.WSCore, c.getServerURL())
+	if c.AuthUC != nil {
+		c.AuthController = authCtrl.NewAuthController(c.AuthUC, c.MemberTokens, c.Config.GetFrontEndURL())
+	}
+
+	// Messages sent over the room socket are held to the message sending limit
+	c.WSCore.EnableInboundSend(c.MessageController, func(ctx context.Context, userID string) (bool, time.Duration, error) {
+		return middlewares.AllowRequest(ctx, cache.GetRedis(), userID, c.rateLimitConfig(middlewares.MessageSendingRateLimiterConfig()))
+	})
 
 	c.Logger.Info("Controllers initialized successfully")
 }
@@ -49,6 +80,11 @@ func (c *Container) SetupRouter() *gin.Engine {
 	binding.Validator = new(middlewares.DefaultValidator)
 
 	router := gin.Default()
+	// Only the proxies in front of the API may name the client, anyone else could spoof
+	// the address the rate limits and logs go by
+	if err := router.SetTrustedProxies(c.Config.Server.TrustedProxies); err != nil {
+		c.Logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 
 	router.Use(sentrygin.New(sentrygin.Options{
 		Repanic:         true,
@@ -61,94 +97,164 @@ func (c *Container) SetupRouter() *gin.Engine {
 	}
 
 	router.Use(middlewares.GinLogger(c.Logger))
-	router.Use(middlewares.CorsMiddleware(c.Config))
+	router.Use(middlewares.CorsMiddleware(c.CorsOrigins))
+	router.Use(middlewares.LocaleMiddleware())
+	router.Use(middlewares.ErrorMiddleware(c.Logger))
 
 	router.GET("/health", c.healthCheckHandler)
 
+	routes.ShortLinkRedirectRoutes(&router.RouterGroup, c.ShortLinkController)
+
 	c.registerObservabilityRoutes(router)
 
 	c.registerAPIRoutes(router)
 
+	c.registerIncomingWebhookRoutes(router)
+
+	c.registerBotRoutes(router)
+
+	c.registerAdminRoutes(router)
+
 	c.Logger.Info("Router configured successfully")
 
 	return router
 }
 
+// v1 and v2 serve the same handlers, v2 is where breaking response changes go
 func (c *Container) registerAPIRoutes(router *gin.Engine) {
-	v1 := router.Group("/api/v1")
-	{
+	c.registerVersionedAPIRoutes(router.Group("/api/v1"), c.v1Policy())
+	c.registerVersionedAPIRoutes(router.Group("/api/v2"), middlewares.VersionPolicy{Version: middlewares.APIVersionV2})
+}
 
-		v1.Use(middlewares.RateLimiterMiddleware(cache.GetRedis(), c.Logger, middlewares.ModerateRateLimiterConfig()))
-		v1.Use(middlewares.ETagMiddleware(c.ETagStore))
-		v1.Use(middlewares.UserMiddleware(c.UserUC, c.Logger))
-
-		v1.Use(func(c *gin.Context) {
-			if hub := sentrygin.GetHubFromContext(c); hub != nil {
-				user, exists := middlewares.GetUserFromContext(c)
-				if !exists {
-					hub.Scope().SetUser(sentry.User{
-						ID:        user.ID,
-						Username:  user.Username,
-						IPAddress: c.ClientIP(),
-					})
-				}
-
-				hub.Scope().SetTag("user_type", "anonymous")
+func (c *Container) registerVersionedAPIRoutes(group *gin.RouterGroup, policy middlewares.VersionPolicy) {
+	group.Use(middlewares.APIVersionMiddleware(policy, c.MetricsManager))
+	group.Use(middlewares.MaintenanceMiddleware(c.Maintenance))
+	group.Use(c.rateLimiter(middlewares.ModerateRateLimiterConfig()))
+	group.Use(middlewares.ETagMiddleware(c.ETagStore))
+	group.Use(middlewares.UserMiddleware(c.UserUC, c.MemberTokens, c.Config.Auth.AllowUserIDHeader, c.Logger))
+	group.Use(middlewares.AuditMiddleware(c.EventPublisher, c.Logger))
+
+	group.Use(func(c *gin.Context) {
+		if hub := sentrygin.GetHubFromContext(c); hub != nil {
+			user, exists := middlewares.GetUserFromContext(c)
+			if !exists {
+				hub.Scope().SetUser(sentry.User{
+					ID:        user.ID,
+					Username:  user.Username,
+					IPAddress: c.ClientIP(),
+				})
 			}
-			c.Next()
-		})
 
-		routes.FilesRoute(v1, c.FilesController, c.Logger)
-		routes.MessageRoutes(v1, c.MessageController)
-		routes.RoomRoutes(v1, c.RoomController)
-		routes.WebsocketRoutes(v1, c.WebsocketController, c.UserNotificationController)
+			hub.Scope().SetTag("user_type", "anonymous")
+		}
+		c.Next()
+	})
+
+	idempotency := middlewares.IdempotencyMiddleware(cache.GetRedis(), c.Logger)
+
+	routes.FilesRoute(group, c.FilesController, c.rateLimiter(middlewares.StrictRateLimiterConfig()), middlewares.FeatureGate(c.FeatureFlags, model.FeatureUploads))
+	routes.MessageRoutes(group, c.MessageController, idempotency)
+	routes.RoomRoutes(group, c.RoomController, idempotency, middlewares.FeatureGate(c.FeatureFlags, model.FeatureRoomCreation))
+	routes.RoomStatsRoutes(group, c.StatsController)
+	routes.ShortLinkRoutes(group, c.ShortLinkController)
+	routes.NotificationRoutes(group, c.NotificationController)
+	routes.UserRoutes(group, c.UserController)
+	routes.WebhookRoutes(group, c.WebhookController)
+	routes.BotRoutes(group, c.BotController)
+	if c.AuthController != nil {
+		routes.AuthRoutes(group, c.AuthController)
 	}
+	routes.WebsocketRoutes(group, c.WebsocketController, c.UserNotificationController)
 }
 
-func (c *Container) healthCheckHandler(ctx *gin.Context) {
-	ctx.JSON(200, gin.H{
-		"status": "healthy",
-		"time":   time.Now().Format(time.RFC3339),
-	})
+func (c *Container) v1Policy() middlewares.VersionPolicy {
+	deprecatedAt, sunsetAt := c.Config.V1Lifecycle()
+	return middlewares.VersionPolicy{
+		Version:      middlewares.APIVersionV1,
+		DeprecatedAt: deprecatedAt,
+		SunsetAt:     sunsetAt,
+		Link:         c.Config.API.DeprecationLink,
+	}
 }
 
-func (c *Container) registerObservabilityRoutes(router *gin.Engine) {
-	metricsGroup := router.Group("/observability")
-	{
-		metrics.GetHandler(metricsGroup, c.MetricsManager)
-	}
+// rateLimitConfig counts a tier's requests with the configured algorithm, the allowlisted
+// skip it
+func (c *Container) rateLimitConfig(tier middlewares.RateLimiterConfig) middlewares.RateLimiterConfig {
+	config := tier
+	config.Tuning = c.RateLimitTuning
+	config.Exemptions = c.RateLimitAllowlist
+	return config
 }
 
-func (c *Container) Shutdown() error {
-	c.Logger.Info("Shutting down dependencies...")
+func (c *Container) rateLimiter(tier middlewares.RateLimiterConfig) gin.HandlerFunc {
+	return middlewares.RateLimiterMiddleware(cache.GetRedis(), c.Logger, c.rateLimitConfig(tier))
+}
 
-	if c.FileCleanupJob != nil {
-		c.FileCleanupJob.Stop()
-	}
+// Incoming webhooks are called by external systems, the token stands in for a user
+func (c *Container) registerIncomingWebhookRoutes(router *gin.Engine) {
+	hooks := router.Group("/api/v1/hooks")
+	{
+		hooks.Use(middlewares.APIVersionMiddleware(c.v1Policy(), c.MetricsManager))
+		hooks.Use(middlewares.MaintenanceMiddleware(c.Maintenance))
+		hooks.Use(middlewares.FeatureGate(c.FeatureFlags, model.FeatureWebhooks))
 
-	// Cancel WebSocket context
-	if c.cancel != nil {
-		c.cancel()
+		routes.IncomingWebhookRoutes(hooks, c.WebhookController)
 	}
+}
 
-	if c.TracerProvider != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := c.TracerProvider.Shutdown(ctx); err != nil {
-			c.Logger.Error("failed to shutdown tracer provider", zap.Error(err))
-		}
+// Bots skip the cookie based user middleware and get their own rate limit tiers
+func (c *Container) registerBotRoutes(router *gin.Engine) {
+	botGroup := router.Group("/api/v1/bot")
+	{
+		botGroup.Use(middlewares.APIVersionMiddleware(c.v1Policy(), c.MetricsManager))
+		botGroup.Use(middlewares.MaintenanceMiddleware(c.Maintenance))
+		botGroup.Use(middlewares.FeatureGate(c.FeatureFlags, model.FeatureBots))
+		botGroup.Use(middlewares.BotMiddleware(c.BotUC, c.Logger))
+		botGroup.Use(middlewares.AuditMiddleware(c.EventPublisher, c.Logger))
+		botGroup.Use(c.rateLimiter(middlewares.BotRateLimiterConfig()))
+
+		sendLimiter := c.rateLimiter(middlewares.BotMessageSendingRateLimiterConfig())
+		idempotency := middlewares.IdempotencyMiddleware(cache.GetRedis(), c.Logger)
+		routes.BotAPIRoutes(botGroup, c.BotController, c.MessageController, sendLimiter, idempotency)
 	}
+}
 
-	cache.CloseRedis()
-	c.DistributedCache.Close()
+// The admin API lives under /admin/v1, /api/v1/admin stays for the scripts written
+// against it before
+func (c *Container) registerAdminRoutes(router *gin.Engine) {
+	for _, prefix := range []string{"/admin/v1", "/api/v1/admin"} {
+		adminGroup := router.Group(prefix)
+		adminGroup.Use(middlewares.AdminMiddleware(c.Config, c.MemberTokens, c.AuthUC))
+		adminGroup.Use(middlewares.AdminAuditMiddleware(c.EventPublisher, c.Logger))
 
-	if err := c.Logger.Log.Sync(); err != nil {
-		c.Logger.Error("failed to sync logger", zap.Error(err))
+		routes.AdminRoutes(adminGroup, c.AdminController)
 	}
+}
 
-	c.Logger.Info("Dependencies shut down successfully")
+func (c *Container) healthCheckHandler(ctx *gin.Context) {
+	redisStatus := "up"
+	if !cache.RedisHealthy() {
+		redisStatus = "down"
+	}
 
-	database.CloseDb()
+	ctx.JSON(200, gin.H{
+		"status": "healthy",
+		"redis":  redisStatus,
+		"time":   time.Now().Format(time.RFC3339),
+	})
+}
 
-	return nil
+func (c *Container) registerObservabilityRoutes(router *gin.Engine) {
+	metricsGroup := router.Group("/observability")
+	{
+		metrics.GetHandler(metricsGroup, c.MetricsManager, cache.ReportPoolStats, c.DistributedCache.ReportStats("distributed"))
+		routes.StatsRoutes(metricsGroup, c.StatsController)
+		routes.LogLevelRoutes(metricsGroup, c.LogLevelController,
+			middlewares.AdminMiddleware(c.Config, c.MemberTokens, c.AuthUC),
+			middlewares.AdminAuditMiddleware(c.EventPublisher, c.Logger),
+		)
+		routes.ProfileRoutes(metricsGroup, c.ProfilesController,
+			middlewares.AdminMiddleware(c.Config, c.MemberTokens, c.AuthUC),
+		)
+	}
 }
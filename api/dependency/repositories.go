@@ -1,10 +1,14 @@
 package dependency
 
 import (
+	"time"
+
 	"github.com/hilthontt/visper/api/infrastructure/cache"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
 	"github.com/hilthontt/visper/api/infrastructure/persistence/repository"
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
 )
 
 const (
@@ -19,6 +23,8 @@ func (c *Container) initRepositories() {
 	redisClient := cache.GetRedis()
 	distributedCache := cache.NewDistributedCache(redisClient, CacheKeyPrefix, cache.DefaultOptions())
 	c.DistributedCache = distributedCache
+	c.initCacheSnapshots()
+	repoLogger := c.Logger.Subsystem(logger.SubsystemRepo)
 
 	// Create tracer for repositories with fallback to noop tracer
 	var tracer trace.Tracer
@@ -35,11 +41,56 @@ func (c *Container) initRepositories() {
 		// tracer = otel.GetTracerProvider().Tracer(RepoTracerName)
 	}
 
-	c.MessageRepo = repository.NewMessageRepository(distributedCache, tracer)
-	c.UserRepo = repository.NewUserRepository(distributedCache, tracer)
-	c.RoomRepo = repository.NewRoomRepository(distributedCache, c.UserRepo, tracer)
+	factory := repository.NewFactory(c.Config, distributedCache, tracer, c.MetricsManager, repoLogger.Log)
+	c.UserRepo = factory.UserRepository()
+	c.RoomRepo = factory.RoomRepository(c.UserRepo)
+	c.MessageRepo = factory.MessageRepository()
+	c.Logger.Info("Persistence backends selected",
+		zap.String("users", c.Config.Persistence.BackendFor(c.Config.Persistence.Users)),
+		zap.String("rooms", c.Config.Persistence.BackendFor(c.Config.Persistence.Rooms)),
+		zap.String("messages", c.Config.Persistence.BackendFor(c.Config.Persistence.Messages)),
+	)
+
 	c.FileRepo = repository.NewFileRepository(redisClient, c.RoomRepo)
-	c.AuditLogRepo = repository.NewAuditLogRepository(c.Config, c.Logger.Log)
+	c.AuditLogRepo = repository.NewAuditLogRepository(c.Config, repoLogger.Log)
+	c.BanRepo = repository.NewBanRepository(redisClient)
+	c.RateLimitRepo = repository.NewRateLimitRepository(redisClient)
+	c.StatsRepo = repository.NewStatsRepository(redisClient)
+	c.ShortLinkRepo = repository.NewShortLinkRepository(redisClient)
+	c.PushSubscriptionRepo = repository.NewPushSubscriptionRepository(redisClient)
+	c.NotificationPrefRepo = repository.NewNotificationPreferenceRepository(redisClient)
+	c.ReactionRepo = repository.NewReactionRepository(redisClient)
+	c.MuteRepo = repository.NewMuteRepository(redisClient)
+	c.RoomBanRepo = repository.NewRoomBanRepository(redisClient)
+	c.SlowModeRepo = repository.NewSlowModeRepository(redisClient)
+	c.RoomInviteRepo = repository.NewRoomInviteRepository(redisClient)
+	c.SocketTicketRepo = repository.NewSocketTicketRepository(redisClient)
+	c.AnnouncementRepo = repository.NewAnnouncementRepository(redisClient)
+	c.MembershipLogRepo = repository.NewMembershipLogRepository(redisClient)
+	c.ExportLimitRepo = repository.NewExportLimitRepository(redisClient)
+	c.WebhookRepo = repository.NewWebhookRepository(redisClient)
+	c.BotRepo = repository.NewBotRepository(redisClient)
+	c.IdentityRepo = repository.NewIdentityRepository(redisClient)
+	c.FeatureFlagRepo = repository.NewFeatureFlagRepository(redisClient)
 
 	c.Logger.Info("Repositories initialized successfully")
 }
+
+// initCacheSnapshots restores the local cache layer from its snapshot, a snapshot that can't
+// be read only costs a cold start
+func (c *Container) initCacheSnapshots() {
+	cfg := c.Config.Cache
+	if cfg.SnapshotPath == "" {
+		return
+	}
+
+	c.CacheSnapshots = cache.NewSnapshotter(c.DistributedCache.Local(), cache.SnapshotOptions{
+		Path:     cfg.SnapshotPath,
+		Interval: durationOr(cfg.SnapshotInterval, 5*time.Minute),
+		Compress: cfg.SnapshotCompress,
+		MaxTTL:   c.DistributedCache.LocalTTL(),
+	}, c.Logger)
+	if err := c.CacheSnapshots.Restore(); err != nil {
+		c.Logger.Warn("Starting with an empty cache", zap.Error(err))
+	}
+}
@@ -0,0 +1,123 @@
+package dependency
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hilthontt/visper/api/infrastructure/lifecycle"
+	"github.com/hilthontt/visper/api/infrastructure/persistence/database"
+	"go.uber.org/zap"
+)
+
+// ShutdownTimeout defaults to 30 seconds, long enough for clients to be sent away and the
+// jobs to notice they were cancelled
+func (c *Container) ShutdownTimeout() time.Duration {
+	if c.Config.Server.ShutdownTimeout > 0 {
+		return c.Config.Server.ShutdownTimeout
+	}
+	return 30 * time.Second
+}
+
+// Shutdown stops srv and every subsystem, each one only after what depends on it.
+// Background jobs stop before the events since they publish, and the stores close last.
+func (c *Container) Shutdown(ctx context.Context, srv *http.Server) error {
+	c.Logger.Info("Shutting down dependencies...")
+
+	coordinator := lifecycle.NewCoordinator(c.Logger)
+
+	// Hijacked WebSocket connections are not tracked by the server, they're drained next
+	coordinator.Add("http", srv.Shutdown)
+
+	coordinator.Add("websockets", func(ctx context.Context) error {
+		c.cancel()
+		select {
+		case <-c.WSCore.Done():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	coordinator.Add("webhooks", c.Webhooks.Shutdown)
+
+	coordinator.Add("jobs", func(ctx context.Context) error {
+		c.jobsCancel()
+		return waitContext(ctx, func() {
+			c.jobs.Wait()
+			c.ImageWorkers.Wait()
+		})
+	})
+
+	coordinator.Add("events", func(ctx context.Context) error {
+		if c.EventConsumer != nil {
+			if err := c.EventConsumer.Stop(ctx); err != nil {
+				return err
+			}
+		}
+		if c.BrokerServer != nil {
+			if err := c.BrokerServer.Close(); err != nil {
+				c.Logger.Error("failed to close broker server", zap.Error(err))
+			}
+		}
+		if c.BrokerClient != nil {
+			return c.BrokerClient.Close()
+		}
+		if c.Broker != nil {
+			return c.Broker.Close()
+		}
+		return nil
+	})
+
+	coordinator.Add("nats", func(ctx context.Context) error {
+		if c.NATSConn == nil {
+			return nil
+		}
+		defer c.NATSConn.Close()
+		return c.NATSConn.FlushWithContext(ctx)
+	})
+
+	// The distributed cache closes the shared Redis client with its local cache
+	coordinator.Add("redis", func(context.Context) error {
+		return c.DistributedCache.Close()
+	})
+
+	coordinator.Add("postgres", func(context.Context) error {
+		database.CloseDb()
+		return nil
+	})
+
+	coordinator.Add("tracing", func(ctx context.Context) error {
+		if c.TracerProvider == nil {
+			return nil
+		}
+		return c.TracerProvider.Shutdown(ctx)
+	})
+
+	err := coordinator.Shutdown(ctx)
+	if err != nil {
+		c.Logger.Error("Dependencies did not shut down cleanly", zap.Error(err))
+	} else {
+		c.Logger.Info("Dependencies shut down successfully")
+	}
+
+	_ = c.Logger.Log.Sync()
+
+	return err
+}
+
+// waitContext runs wait and returns once it does or ctx is done
+func waitContext(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
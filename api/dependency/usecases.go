@@ -3,22 +3,92 @@ package dependency
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	adminUseCase "github.com/hilthontt/visper/api/application/usecases/admin"
+	authUseCase "github.com/hilthontt/visper/api/application/usecases/auth"
+	botUseCase "github.com/hilthontt/visper/api/application/usecases/bot"
+	exportUseCase "github.com/hilthontt/visper/api/application/usecases/export"
 	fileUseCase "github.com/hilthontt/visper/api/application/usecases/file"
 	messageUseCase "github.com/hilthontt/visper/api/application/usecases/message"
+	notificationUseCase "github.com/hilthontt/visper/api/application/usecases/notification"
+	privacyUseCase "github.com/hilthontt/visper/api/application/usecases/privacy"
+	reactionUseCase "github.com/hilthontt/visper/api/application/usecases/reaction"
 	roomUseCase "github.com/hilthontt/visper/api/application/usecases/room"
+	shortLinkUseCase "github.com/hilthontt/visper/api/application/usecases/shortlink"
+	statsUseCase "github.com/hilthontt/visper/api/application/usecases/stats"
 	userUseCase "github.com/hilthontt/visper/api/application/usecases/user"
+	webhookUseCase "github.com/hilthontt/visper/api/application/usecases/webhook"
+	"github.com/hilthontt/visper/api/infrastructure/activity"
+	"github.com/hilthontt/visper/api/infrastructure/webhook"
 )
 
 func (c *Container) initUseCases() {
-	c.MessageUC = messageUseCase.NewMessageUseCase(c.MessageRepo, c.EventPublisher, c.Logger)
-	c.RoomUC = roomUseCase.NewRoomUseCase(c.RoomRepo, c.EventPublisher, c.Logger)
-	c.UserUC = userUseCase.NewUserUseCase(c.UserRepo, c.Logger)
-	c.FileUC = fileUseCase.NewFileUseCase(c.FileRepo, c.RoomRepo, c.Storage, c.getServerURL())
+	c.Webhooks = webhook.NewDispatcher(c.WebhookRepo, c.Logger)
+	c.RoomActivity = activity.NewRoomTracker(c.StatsRepo, c.MetricsManager, c.Logger)
+	c.WSCore.TrackActivity(c.RoomActivity)
+
+	c.MessageUC = messageUseCase.NewMessageUseCase(c.MessageRepo, c.RoomRepo, c.StatsRepo, c.MuteRepo, c.SlowModeRepo, c.AnnouncementRepo, c.Moderation, c.LinkPreviews, c.Webhooks, c.RoomActivity, c.EventPublisher, c.MetricsManager, c.Logger)
+	c.RoomUC = roomUseCase.NewRoomUseCase(c.RoomRepo, c.MuteRepo, c.RoomBanRepo, c.RoomInviteRepo, c.SocketTicketRepo, c.MembershipLogRepo, c.Webhooks, c.EventPublisher, c.Logger)
+	c.UserUC = userUseCase.NewUserUseCase(c.UserRepo, c.BanRepo, c.Logger)
+	c.FileUC = fileUseCase.NewFileUseCase(c.FileRepo, c.RoomRepo, c.StatsRepo, c.Storage, c.URLSigner, c.fileLinkTTL(), c.ImageWorkers, c.Scanner, c.getServerURL(), c.Config.Files.KeepImageMetadata, c.roomQuota())
+	c.AdminUC = adminUseCase.NewAdminUseCase(c.RoomRepo, c.MessageRepo, c.BanRepo, c.RateLimitRepo, c.UserRepo, c.IdentityRepo, c.BotRepo, c.FeatureFlagRepo, c.AuditLogRepo, c.Logger)
+	c.ExportUC = exportUseCase.NewExportUseCase(c.RoomRepo, c.MessageRepo, c.FileRepo, c.MembershipLogRepo, c.ExportLimitRepo, c.Storage, c.Logger)
+	c.ShortLinkUC = shortLinkUseCase.NewShortLinkUseCase(c.ShortLinkRepo, c.RoomRepo, c.Config.GetFrontEndURL(), c.getServerURL(), c.Logger)
+	c.NotificationUC = notificationUseCase.NewNotificationUseCase(
+		c.PushSubscriptionRepo,
+		c.NotificationPrefRepo,
+		c.RoomRepo,
+		c.PushDispatcher,
+		c.isUserOnline,
+		c.MetricsManager,
+		c.Logger,
+	)
+	c.ReactionUC = reactionUseCase.NewReactionUseCase(c.ReactionRepo, c.MessageRepo, c.RoomRepo, c.Logger)
+	c.StatsUC = statsUseCase.NewStatsUseCase(c.RoomRepo, c.StatsRepo, c.Logger)
+	c.PrivacyUC = privacyUseCase.NewPrivacyUseCase(
+		c.UserRepo,
+		c.RoomRepo,
+		c.MessageRepo,
+		c.FileRepo,
+		c.AnnouncementRepo,
+		c.BotRepo,
+		c.IdentityRepo,
+		c.Storage,
+		c.EventPublisher,
+		c.Logger,
+	)
+	c.WebhookUC = webhookUseCase.NewWebhookUseCase(c.WebhookRepo, c.RoomRepo, c.Logger)
+	c.BotUC = botUseCase.NewBotUseCase(c.BotRepo, c.Logger)
+	if c.OIDC != nil {
+		c.AuthUC = authUseCase.NewAuthUseCase(
+			c.OIDC,
+			authUseCase.AdminRules{Subjects: c.Config.OIDC.AdminSubjects, Groups: c.Config.OIDC.AdminGroups},
+			c.IdentityRepo,
+			c.UserRepo,
+			c.Logger,
+		)
+	}
 
 	c.Logger.Info("Use cases initialized successfully")
 }
 
+// fileLinkTTL is how long a signed download link works, 15 minutes unless configured
+func (c *Container) fileLinkTTL() time.Duration {
+	if c.Config.Files.LinkTTL > 0 {
+		return c.Config.Files.LinkTTL
+	}
+	return 15 * time.Minute
+}
+
+// roomQuota is the upload allowance per room in bytes, 100MB unless configured
+func (c *Container) roomQuota() int64 {
+	if c.Config.Files.RoomQuotaMB > 0 {
+		return c.Config.Files.RoomQuotaMB << 20
+	}
+	return 100 << 20
+}
+
 func (c *Container) getServerURL() string {
 	domain := c.Config.Server.Domain
 	port := c.Config.Server.ExternalPort
@@ -36,3 +106,8 @@ func (c *Container) getServerURL() string {
 
 	return fmt.Sprintf("%s://%s:%s", scheme, domain, port)
 }
+
+// A user counts as online when they're watching the room or have the notification stream open
+func (c *Container) isUserOnline(roomID, userID string) bool {
+	return c.WSCore.IsUserInRoom(roomID, userID) || c.NotificationCore.IsConnected(userID)
+}
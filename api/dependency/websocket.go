@@ -2,19 +2,75 @@ package dependency
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
 
+	"github.com/hilthontt/visper/api/infrastructure/cache"
 	"github.com/hilthontt/visper/api/infrastructure/websocket"
+	"go.uber.org/zap"
 )
 
 func (c *Container) initWebSocket() {
+	var bus websocket.Bus
+	switch c.Config.Cluster.Bus {
+	case "redis":
+		bus = websocket.NewRedisBus(cache.GetRedis())
+	case "nats":
+		conn, err := c.natsConn()
+		if err != nil {
+			c.Logger.Error("failed to connect the nats bus, running as a single instance", zap.Error(err))
+			break
+		}
+		bus = websocket.NewNATSBus(conn)
+	}
+	instanceID := c.instanceID()
+
 	c.WSRoomManager = websocket.NewRoomManager()
-	c.WSCore = websocket.NewCore(c.RoomRepo, c.MessageRepo)
+	c.WSCore = websocket.NewCore(c.MessageRepo, c.Maintenance, c.Config.Presence.IdleTimeout, c.heartbeat(), c.frameLimit(), c.MetricsManager, bus, instanceID)
 	c.NotificationCore = websocket.NewNotificationCore()
 
+	origins := websocket.NewOriginPolicy(c.Config.WebSocket.AllowedOrigins)
+	if !origins.Restricted() {
+		c.Logger.Warn("websocket origins are not restricted, set websocket.allowedOrigins in production")
+	}
+	c.WSRoomManager.RestrictOrigins(origins)
+	c.NotificationCore.RestrictOrigins(origins)
+
 	c.ctx, c.cancel = context.WithCancel(context.Background())
 
 	go c.WSCore.Run(c.ctx)
 	go c.NotificationCore.Run(c.ctx)
 
-	c.Logger.Info("WebSocket components initialized successfully")
+	c.Logger.Info("WebSocket components initialized successfully",
+		zap.String("instance_id", instanceID), zap.Bool("bus", bus != nil))
+}
+
+func (c *Container) heartbeat() websocket.Heartbeat {
+	return websocket.Heartbeat{
+		PingInterval:   c.Config.WebSocket.PingInterval,
+		MaxMissedPongs: c.Config.WebSocket.MaxMissedPongs,
+	}
+}
+
+// The suffix keeps two containers that share a hostname from ignoring each other's events
+func (c *Container) instanceID() string {
+	if c.Config.Cluster.InstanceID != "" {
+		return c.Config.Cluster.InstanceID
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "visper"
+	}
+	return fmt.Sprintf("%s-%s", hostname, rand.Text()[:8])
+}
+
+func (c *Container) frameLimit() websocket.FrameLimit {
+	return websocket.FrameLimit{
+		Rate:          c.Config.WebSocket.FrameRate,
+		Burst:         c.Config.WebSocket.FrameBurst,
+		MaxViolations: c.Config.WebSocket.MaxRateViolations,
+		BanDuration:   c.Config.WebSocket.RateBanDuration,
+	}
 }
@@ -0,0 +1,104 @@
+package apperror
+
+import "errors"
+
+// Kind groups errors by how the caller should react, presentation maps it onto a status code
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindGone
+	KindRateLimited
+	KindTooLarge
+	KindNotImplemented
+)
+
+// Error carries a stable machine readable code next to the message. The message stays the
+// English text the i18n catalog is keyed on, so Error() reads the same as before codes existed.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches on the code, so a reworded error still satisfies errors.Is against its sentinel
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage keeps the code but swaps the text, for errors that read differently per action
+func (e *Error) WithMessage(message string) *Error {
+	return &Error{Kind: e.Kind, Code: e.Code, Message: message}
+}
+
+// As finds the first *Error in err's chain
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// Codes are part of the API contract, clients switch on them. Never rename one, add a new code instead.
+var (
+	ErrInvalidInput = New(KindInvalid, "INVALID_REQUEST", "invalid request")
+	ErrUnauthorized = New(KindUnauthorized, "UNAUTHORIZED", "unauthorized")
+	ErrForbidden    = New(KindForbidden, "FORBIDDEN", "forbidden")
+	ErrInternal     = New(KindInternal, "INTERNAL_ERROR", "internal server error")
+
+	ErrRoomNotFound   = New(KindNotFound, "ROOM_NOT_FOUND", "room not found")
+	ErrRoomExpired    = New(KindNotFound, "ROOM_EXPIRED", "room has expired")
+	ErrRoomFull       = New(KindForbidden, "ROOM_FULL", "room is full")
+	ErrRoomReadOnly   = New(KindForbidden, "ROOM_READ_ONLY", "room is read-only")
+	ErrNotOwner       = New(KindForbidden, "NOT_ROOM_OWNER", "only the room owner can do this")
+	ErrNotMember      = New(KindForbidden, "NOT_ROOM_MEMBER", "user is not a member of this room")
+	ErrOwnerProtected = New(KindInvalid, "OWNER_PROTECTED", "this action cannot target the room owner")
+	ErrBannedFromRoom = New(KindForbidden, "BANNED_FROM_ROOM", "you are banned from this room")
+	ErrMuted          = New(KindForbidden, "MUTED", "you are muted in this room")
+	ErrStaleRoomKey   = New(KindConflict, "STALE_ROOM_KEY", "the room key has been rotated, fetch the new key")
+
+	ErrMessageNotFound      = New(KindNotFound, "MESSAGE_NOT_FOUND", "message not found")
+	ErrInvalidCiphertext    = New(KindInvalid, "INVALID_CIPHERTEXT", "encrypted message is not valid ciphertext")
+	ErrNotAuthor            = New(KindForbidden, "NOT_MESSAGE_AUTHOR", "you can only change your own messages")
+	ErrAnnouncementNotFound = New(KindNotFound, "ANNOUNCEMENT_NOT_FOUND", "announcement not found")
+	ErrReactionNotFound     = New(KindNotFound, "REACTION_NOT_FOUND", "reaction not found")
+	ErrReactionExists       = New(KindConflict, "REACTION_EXISTS", "reaction already exists")
+
+	ErrNotMuted  = New(KindNotFound, "NOT_MUTED", "user is not muted")
+	ErrNotBanned = New(KindNotFound, "NOT_BANNED", "user is not banned from this room")
+
+	ErrInviteNotFound    = New(KindNotFound, "INVITE_NOT_FOUND", "invite not found")
+	ErrInviteUnavailable = New(KindGone, "INVITE_UNAVAILABLE", "invite is no longer available")
+
+	ErrFileNotFound      = New(KindNotFound, "FILE_NOT_FOUND", "file not found")
+	ErrFileLinkInvalid   = New(KindForbidden, "FILE_LINK_INVALID", "file link is not valid")
+	ErrFileLinkExpired   = New(KindGone, "FILE_LINK_EXPIRED", "file link has expired")
+	ErrRoomStorageFull   = New(KindTooLarge, "ROOM_STORAGE_FULL", "room storage quota exceeded")
+	ErrFileTooLarge      = New(KindTooLarge, "FILE_TOO_LARGE", "file size exceeds maximum allowed size of 5MB")
+	ErrInvalidFileType   = New(KindInvalid, "INVALID_FILE_TYPE", "invalid file type, only images are allowed")
+	ErrShortLinkNotFound = New(KindNotFound, "SHORT_LINK_NOT_FOUND", "short link not found")
+
+	ErrWebhookNotFound   = New(KindNotFound, "WEBHOOK_NOT_FOUND", "webhook not found")
+	ErrBotNotFound       = New(KindNotFound, "BOT_NOT_FOUND", "bot not found")
+	ErrUserNotFound      = New(KindNotFound, "USER_NOT_FOUND", "user not found")
+	ErrIdentityNotFound  = New(KindNotFound, "IDENTITY_NOT_FOUND", "no account is linked to this user")
+	ErrExemptionNotFound = New(KindNotFound, "RATE_LIMIT_EXEMPTION_NOT_FOUND", "rate limit exemption not found")
+	ErrLimitReached      = New(KindConflict, "LIMIT_REACHED", "limit reached")
+
+	ErrPushPlatformUnsupported = New(KindNotImplemented, "PUSH_PLATFORM_UNSUPPORTED", "push platform is not supported")
+)
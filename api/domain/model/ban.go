@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+type Ban struct {
+	UserID    string    `json:"userId"`
+	Reason    string    `json:"reason"`
+	BannedAt  time.Time `json:"bannedAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+func (b Ban) IsPermanent() bool {
+	return b.ExpiresAt.IsZero()
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+type BotScope string
+
+const (
+	BotScopeSend BotScope = "send"
+	BotScopeRead BotScope = "read"
+)
+
+// Bot IDs are prefixed so they can never collide with, or be claimed as, a human user ID
+const BotIDPrefix = "bot_"
+
+func (s BotScope) IsValid() bool {
+	return s == BotScopeSend || s == BotScopeRead
+}
+
+func IsBotID(id string) bool {
+	return strings.HasPrefix(id, BotIDPrefix)
+}
+
+// Bot is an integration owned by a user, it authenticates with a long-lived token instead of a cookie
+type Bot struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	OwnerID   string     `json:"ownerId"`
+	Scopes    []BotScope `json:"scopes"`
+	TokenHash string     `json:"tokenHash"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (b *Bot) HasScope(scope BotScope) bool {
+	return slices.Contains(b.Scopes, scope)
+}
+
+// User is how the bot shows up as a room member and message author
+func (b *Bot) User() *User {
+	return &User{
+		ID:        b.ID,
+		Username:  b.Name,
+		IsBot:     true,
+		CreatedAt: b.CreatedAt,
+	}
+}
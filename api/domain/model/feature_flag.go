@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// FeatureFlag names a feature operators can switch off at runtime
+type FeatureFlag string
+
+const (
+	FeatureRoomCreation FeatureFlag = "room_creation"
+	FeatureUploads      FeatureFlag = "uploads"
+	FeatureBots         FeatureFlag = "bots"
+	FeatureWebhooks     FeatureFlag = "webhooks"
+)
+
+// FeatureFlags are the known flags, every one is on until an operator turns it off
+var FeatureFlags = []FeatureFlag{FeatureRoomCreation, FeatureUploads, FeatureBots, FeatureWebhooks}
+
+func (f FeatureFlag) IsValid() bool {
+	for _, flag := range FeatureFlags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// FeatureFlagState is a flag as an operator last left it
+type FeatureFlagState struct {
+	Name      FeatureFlag `json:"name"`
+	Enabled   bool        `json:"enabled"`
+	UpdatedBy string      `json:"updatedBy,omitempty"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
@@ -2,6 +2,16 @@ package model
 
 import "time"
 
+type ScanStatus string
+
+const (
+	ScanPending  ScanStatus = "pending"
+	ScanClean    ScanStatus = "clean"
+	ScanInfected ScanStatus = "infected" // The file is quarantined and no longer served
+	ScanFailed   ScanStatus = "failed"   // The scanner didn't answer, the file is served regardless
+	ScanSkipped  ScanStatus = "skipped"  // No scanner is configured
+)
+
 type File struct {
 	ID        string    `json:"id"`
 	RoomID    string    `json:"roomId"`
@@ -12,6 +22,27 @@ type File struct {
 	Path      string    `json:"path"`
 	URL       string    `json:"url"`
 	CreatedAt time.Time `json:"createdAt"`
+
+	// Variants maps a variant name to its storage key, filled in once the resize worker is done
+	Variants map[string]string `json:"variants,omitempty"`
+	// VariantURLs are signed links to the variants, set per response and never stored
+	VariantURLs map[string]string `json:"-"`
+
+	ScanStatus    ScanStatus `json:"scanStatus,omitempty"`
+	ScanSignature string     `json:"scanSignature,omitempty"`
+}
+
+func (f *File) IsQuarantined() bool {
+	return f.ScanStatus == ScanInfected
+}
+
+// StorageKeys is everything stored for the file, the original and its variants
+func (f *File) StorageKeys() []string {
+	keys := []string{f.Path}
+	for _, key := range f.Variants {
+		keys = append(keys, key)
+	}
+	return keys
 }
 
 func (f *File) IsImage() bool {
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Identity links an account at an OpenID Connect provider to a Visper user, signing in
+// with it on any device makes that user, and the rooms it owns, yours again
+type Identity struct {
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	UserID      string    `json:"userId"`
+	Email       string    `json:"email,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Admin       bool      `json:"admin"` // Settled again on every login
+	LinkedAt    time.Time `json:"linkedAt"`
+	LastLoginAt time.Time `json:"lastLoginAt"`
+}
+
+// LoginState is kept from sending the user to the provider until it redirects back, only
+// the browser that started the login can finish it
+type LoginState struct {
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"` // PKCE code verifier
+	UserID    string    `json:"userId"`
+	ReturnTo  string    `json:"returnTo"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
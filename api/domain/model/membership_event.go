@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+type MembershipAction string
+
+const (
+	MembershipJoined MembershipAction = "joined"
+	MembershipLeft   MembershipAction = "left"
+	MembershipKicked MembershipAction = "kicked"
+	MembershipBanned MembershipAction = "banned"
+)
+
+// MembershipEvent is kept alongside the message history so transcripts can show who came and went
+type MembershipEvent struct {
+	RoomID    string           `json:"roomId"`
+	UserID    string           `json:"userId"`
+	Username  string           `json:"username"`
+	Action    MembershipAction `json:"action"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
@@ -2,6 +2,12 @@ package model
 
 import "time"
 
+type MessageType string
+
+// MessageTypeAnnouncement is owner-only and pinned for as long as the room lives.
+// Regular messages leave Type empty.
+const MessageTypeAnnouncement MessageType = "announcement"
+
 type Message struct {
 	ID        string    `json:"id"`
 	RoomID    string    `json:"room_id"`
@@ -11,4 +17,32 @@ type Message struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 	Encrypted bool      `json:"encrypted"`
+	// The room key version Content was sealed with, only meaningful when Encrypted
+	KeyVersion int         `json:"key_version,omitempty"`
+	Type       MessageType `json:"type,omitempty"`
+	// Empty for top-level messages, replies always point at the thread root
+	ParentMessageID string `json:"parent_message_id,omitempty"`
+	// Only members resolved at send time, the sender is never included
+	Mentions []Mention `json:"mentions,omitempty"`
+	// Set when the room's content filter matched but was only told to flag
+	Flagged bool `json:"flagged,omitempty"`
+	// Attached after the message is sent, once the first link in it has been fetched
+	Preview *LinkPreview `json:"preview,omitempty"`
+}
+
+func (m Message) IsAnnouncement() bool {
+	return m.Type == MessageTypeAnnouncement
+}
+
+type Mention struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+	SiteName    string `json:"siteName,omitempty"`
 }
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+type Mute struct {
+	RoomID    string    `json:"roomId"`
+	UserID    string    `json:"userId"`
+	MutedBy   string    `json:"mutedBy"`
+	MutedAt   time.Time `json:"mutedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
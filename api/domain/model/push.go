@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+type PushSubscription struct {
+	ID       string `json:"id"`
+	UserID   string `json:"userId"`
+	Platform string `json:"platform"`
+
+	// Web Push
+	Endpoint string `json:"endpoint,omitempty"`
+	P256dh   string `json:"p256dh,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+
+	// FCM
+	Token string `json:"token,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type NotificationLevel string
+
+const (
+	NotificationLevelAll      NotificationLevel = "all"
+	NotificationLevelMentions NotificationLevel = "mentions"
+	NotificationLevelNone     NotificationLevel = "none"
+)
+
+func (l NotificationLevel) IsValid() bool {
+	switch l {
+	case NotificationLevelAll, NotificationLevelMentions, NotificationLevelNone:
+		return true
+	}
+	return false
+}
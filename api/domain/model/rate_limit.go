@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+type RateLimitBlock struct {
+	UserID    string        `json:"userId"`
+	Remaining time.Duration `json:"remaining"`
+}
+
+type RateLimitExemptionKind string
+
+const (
+	RateLimitExemptUser RateLimitExemptionKind = "user"
+	RateLimitExemptCIDR RateLimitExemptionKind = "cidr" // An address or a network
+	RateLimitExemptBot  RateLimitExemptionKind = "bot"  // The bot ID its token authenticates as
+)
+
+func (k RateLimitExemptionKind) IsValid() bool {
+	return k == RateLimitExemptUser || k == RateLimitExemptCIDR || k == RateLimitExemptBot
+}
+
+// RateLimitExemption lets the requests of a user, a network or a bot skip the rate limits
+type RateLimitExemption struct {
+	Kind      RateLimitExemptionKind `json:"kind"`
+	Value     string                 `json:"value"`
+	Note      string                 `json:"note,omitempty"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
@@ -14,6 +14,49 @@ type Room struct {
 	Expiry        time.Duration `json:"expiry"`
 	Members       []User        `json:"members"`
 	EncryptionKey string        `json:"encryption"`
+	Settings      RoomSettings  `json:"settings"`
+
+	// KeyVersion counts rotations of EncryptionKey, zero for rooms from before rotation existed.
+	// RetiredKeys stay readable to current members so history still decrypts.
+	KeyVersion  int       `json:"keyVersion,omitempty"`
+	RetiredKeys []RoomKey `json:"retiredKeys,omitempty"`
+}
+
+// RoomKey is an encryption key that was replaced, messages keep the KeyVersion they were sealed with
+type RoomKey struct {
+	Version   int       `json:"version"`
+	Key       string    `json:"key"`
+	RetiredAt time.Time `json:"retiredAt"`
+}
+
+// DefaultMessageRetention applies to rooms that haven't picked their own
+const DefaultMessageRetention = 7 * 24 * time.Hour
+
+type ModerationMode string
+
+const (
+	ModerationOff   ModerationMode = "off"
+	ModerationFlag  ModerationMode = "flag"
+	ModerationBlock ModerationMode = "block"
+)
+
+type RoomSettings struct {
+	Name  string `json:"name,omitempty"`
+	Topic string `json:"topic,omitempty"`
+
+	// Zero means no limit
+	MaxMembers      int `json:"maxMembers,omitempty"`
+	SlowModeSeconds int `json:"slowModeSeconds,omitempty"`
+
+	// Only the owner may post while set
+	ReadOnly bool `json:"readOnly"`
+
+	// Empty behaves like ModerationOff
+	Moderation ModerationMode `json:"moderation,omitempty"`
+
+	// Zero falls back to the server default, rooms created before settings existed have it unset
+	MessageRetention   time.Duration `json:"messageRetention"`
+	RetainUntilDeleted bool          `json:"retainUntilDeleted"`
 }
 
 func (r Room) IsMember(userID string) bool {
@@ -60,3 +103,39 @@ func (r Room) HasExpired() bool {
 func (r Room) MemberCount() int {
 	return len(r.Members)
 }
+
+// Retention reports false when messages are kept until the room is deleted
+func (s RoomSettings) Retention() (time.Duration, bool) {
+	if s.RetainUntilDeleted {
+		return 0, false
+	}
+
+	if s.MessageRetention > 0 {
+		return s.MessageRetention, true
+	}
+
+	return DefaultMessageRetention, true
+}
+
+// CanPost reports whether the user may send messages under the room's read-only setting
+// RotateKey retires the current key in favour of key
+func (r *Room) RotateKey(key string, now time.Time) {
+	r.RetiredKeys = append(r.RetiredKeys, RoomKey{Version: r.KeyVersion, Key: r.EncryptionKey, RetiredAt: now})
+	r.EncryptionKey = key
+	r.KeyVersion++
+}
+
+func (r Room) CanPost(userID string) bool {
+	return !r.Settings.ReadOnly || r.Owner.ID == userID
+}
+
+func (r Room) IsFull() bool {
+	return r.Settings.MaxMembers > 0 && len(r.Members) >= r.Settings.MaxMembers
+}
+
+func (s RoomSettings) ModerationMode() ModerationMode {
+	if s.Moderation == "" {
+		return ModerationOff
+	}
+	return s.Moderation
+}
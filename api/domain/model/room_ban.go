@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// RoomBan keeps a user out of a single room, unlike Ban which is platform wide
+type RoomBan struct {
+	RoomID    string    `json:"roomId"`
+	UserID    string    `json:"userId"`
+	TokenHash string    `json:"tokenHash,omitempty"`
+	Reason    string    `json:"reason"`
+	BannedBy  string    `json:"bannedBy"`
+	BannedAt  time.Time `json:"bannedAt"`
+}
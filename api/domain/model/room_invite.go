@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// RoomInvite admits its holder to a room without the join code, so it can be revoked on its own
+type RoomInvite struct {
+	Token     string    `json:"token"`
+	RoomID    string    `json:"roomId"`
+	CreatedBy string    `json:"createdBy"`
+	MaxUses   int       `json:"maxUses"` // 0 means unlimited
+	Uses      int       `json:"uses"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"` // zero lasts as long as the room
+}
+
+func (i *RoomInvite) IsExpired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && now.After(i.ExpiresAt)
+}
+
+func (i *RoomInvite) IsExhausted() bool {
+	return i.MaxUses > 0 && i.Uses >= i.MaxUses
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// RoomStats is one room's activity since it was created, for the owner's dashboard
+type RoomStats struct {
+	RoomID          string    `json:"roomId"`
+	Messages        int64     `json:"messages"`
+	MessageBytes    int64     `json:"messageBytes"`
+	PeakConcurrency int64     `json:"peakConcurrency"`
+	Members         int       `json:"members"`
+	GeneratedAt     time.Time `json:"generatedAt"`
+}
+
+func (s *RoomStats) AverageMessageSize() float64 {
+	if s.Messages == 0 {
+		return 0
+	}
+	return float64(s.MessageBytes) / float64(s.Messages)
+}
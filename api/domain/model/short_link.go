@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type ShortLink struct {
+	Code         string    `json:"code"`
+	RoomID       string    `json:"roomId"`
+	CreatedBy    string    `json:"createdBy"`
+	IncludeToken bool      `json:"includeToken"`
+	CreatedAt    time.Time `json:"createdAt"`
+	ExpiresAt    time.Time `json:"expiresAt,omitempty"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// SocketTicket lets one WebSocket attach to a room. It is handed out over an authenticated
+// request, is only good for a few seconds and is gone once used.
+type SocketTicket struct {
+	Token       string    `json:"token"`
+	RoomID      string    `json:"roomId"`
+	UserID      string    `json:"userId"`
+	MemberToken string    `json:"memberToken"` // the client that asked for it, see security.MemberToken
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
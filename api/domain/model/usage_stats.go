@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// UsageStats holds anonymized, instance-wide aggregates only
+type UsageStats struct {
+	ActiveRooms        int64     `json:"activeRooms"`
+	MessagesPerMinute  int64     `json:"messagesPerMinute"`
+	UploadBytesPerHour int64     `json:"uploadBytesPerHour"`
+	GeneratedAt        time.Time `json:"generatedAt"`
+}
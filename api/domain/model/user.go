@@ -6,5 +6,6 @@ type User struct {
 	ID        string    `json:"id"`
 	Username  string    `json:"username"`
 	IsGuest   bool      `json:"isGuest"`
+	IsBot     bool      `json:"isBot,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookMessageSent  WebhookEvent = "message.sent"
+	WebhookMemberJoined WebhookEvent = "member.joined"
+	WebhookMemberLeft   WebhookEvent = "member.left"
+	WebhookMemberKicked WebhookEvent = "member.kicked"
+	WebhookMemberBanned WebhookEvent = "member.banned"
+)
+
+var WebhookEvents = []WebhookEvent{
+	WebhookMessageSent,
+	WebhookMemberJoined,
+	WebhookMemberLeft,
+	WebhookMemberKicked,
+	WebhookMemberBanned,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	return slices.Contains(WebhookEvents, e)
+}
+
+// MembershipWebhookEvent maps a membership change onto the event webhooks subscribe to
+func MembershipWebhookEvent(action MembershipAction) WebhookEvent {
+	return WebhookEvent("member." + string(action))
+}
+
+// RoomWebhook receives signed POSTs for the room's events, the secret is only shown to the owner once
+type RoomWebhook struct {
+	ID        string         `json:"id"`
+	RoomID    string         `json:"roomId"`
+	URL       string         `json:"url"`
+	Secret    string         `json:"secret"`
+	Events    []WebhookEvent `json:"events"` // empty subscribes to everything
+	CreatedBy string         `json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+func (w *RoomWebhook) Subscribes(event WebhookEvent) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// WebhookDelivery records the outcome of one event after all of its attempts
+type WebhookDelivery struct {
+	ID         string       `json:"id"`
+	WebhookID  string       `json:"webhookId"`
+	RoomID     string       `json:"roomId"`
+	Event      WebhookEvent `json:"event"`
+	StatusCode int          `json:"statusCode,omitempty"`
+	Attempts   int          `json:"attempts"`
+	Success    bool         `json:"success"`
+	Error      string       `json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"createdAt"`
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+// AnnouncementRepository keeps pinned copies of announcements, so they outlive message retention
+type AnnouncementRepository interface {
+	Pin(ctx context.Context, message *model.Message, expiresAt time.Time) error
+	Unpin(ctx context.Context, roomID, messageID string) (bool, error)
+	GetAll(ctx context.Context, roomID string) ([]*model.Message, error)
+}
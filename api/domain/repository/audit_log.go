@@ -2,10 +2,25 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/hilthontt/visper/api/domain/model"
 )
 
+// AuditLogFilter narrows a listing, zero fields match everything
+type AuditLogFilter struct {
+	UserID    string
+	RoomID    string
+	EventType string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
 type AuditLogRepository interface {
 	CreateAuditLog(ctx context.Context, a model.AuditLog) (model.AuditLog, error)
+	// ListAuditLogs returns the newest entries first
+	ListAuditLogs(ctx context.Context, filter AuditLogFilter) ([]model.AuditLog, error)
+	// DeleteAuditLogsBefore removes entries older than before and reports how many went
+	DeleteAuditLogsBefore(ctx context.Context, before time.Time) (int64, error)
 }
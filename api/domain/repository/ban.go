@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type BanRepository interface {
+	Create(ctx context.Context, ban *model.Ban, duration time.Duration) error
+	GetByUserID(ctx context.Context, userID string) (*model.Ban, error)
+	GetAll(ctx context.Context) ([]*model.Ban, error)
+	Delete(ctx context.Context, userID string) error
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type BotRepository interface {
+	Create(ctx context.Context, bot *model.Bot) error
+	GetByID(ctx context.Context, id string) (*model.Bot, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (*model.Bot, error)
+	GetByOwner(ctx context.Context, ownerID string) ([]*model.Bot, error)
+	Delete(ctx context.Context, bot *model.Bot) error
+}
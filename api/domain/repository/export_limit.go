@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+type ExportLimitRepository interface {
+	// Acquire starts the user's export cooldown for the room and returns zero, or returns what is left of a running one
+	Acquire(ctx context.Context, roomID, userID string, interval time.Duration) (time.Duration, error)
+}
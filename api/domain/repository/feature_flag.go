@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type FeatureFlagRepository interface {
+	// GetAll returns the flags operators changed, the rest keep their default
+	GetAll(ctx context.Context) ([]*model.FeatureFlagState, error)
+	Save(ctx context.Context, state *model.FeatureFlagState) error
+}
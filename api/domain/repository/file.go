@@ -10,7 +10,13 @@ type FileRepository interface {
 	Create(ctx context.Context, file *model.File) error
 	GetByID(ctx context.Context, id string) (*model.File, error)
 	GetByRoomID(ctx context.Context, roomID string) ([]*model.File, error)
+	GetByUserID(ctx context.Context, userID string) ([]*model.File, error)
+	// Update overwrites a stored file, a file deleted in the meantime stays deleted
+	Update(ctx context.Context, file *model.File) error
 	Delete(ctx context.Context, id string) error
 	DeleteByRoomID(ctx context.Context, roomID string) error
+	// GetOrphanedFiles returns files whose room is gone or has expired
 	GetOrphanedFiles(ctx context.Context) ([]*model.File, error)
+	// GetRoomUsage is the total size in bytes of the room's files
+	GetRoomUsage(ctx context.Context, roomID string) (int64, error)
 }
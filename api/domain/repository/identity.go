@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type IdentityRepository interface {
+	// GetBySubject and GetByUserID return nil without an error when nothing is linked
+	GetBySubject(ctx context.Context, issuer, subject string) (*model.Identity, error)
+	GetByUserID(ctx context.Context, userID string) (*model.Identity, error)
+	Save(ctx context.Context, identity *model.Identity) error
+	Delete(ctx context.Context, identity *model.Identity) error
+	SaveLoginState(ctx context.Context, state *model.LoginState) error
+	// ConsumeLoginState returns nil once the state was used or expired
+	ConsumeLoginState(ctx context.Context, state string) (*model.LoginState, error)
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type MembershipLogRepository interface {
+	Append(ctx context.Context, event *model.MembershipEvent, expiresAt time.Time) error
+	// GetAll returns the events oldest first
+	GetAll(ctx context.Context, roomID string) ([]*model.MembershipEvent, error)
+	ExtendExpiry(ctx context.Context, roomID string, expiresAt time.Time) error
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type MuteRepository interface {
+	Create(ctx context.Context, mute *model.Mute, duration time.Duration) error
+	Get(ctx context.Context, roomID, userID string) (*model.Mute, error)
+	Delete(ctx context.Context, roomID, userID string) error
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type PushSubscriptionRepository interface {
+	Save(ctx context.Context, subscription *model.PushSubscription) error
+	GetByUserID(ctx context.Context, userID string) ([]*model.PushSubscription, error)
+	Delete(ctx context.Context, userID, subscriptionID string) error
+}
+
+type NotificationPreferenceRepository interface {
+	// Get returns an empty level when the user never set one for the room
+	Get(ctx context.Context, userID, roomID string) (model.NotificationLevel, error)
+	Set(ctx context.Context, userID, roomID string, level model.NotificationLevel) error
+}
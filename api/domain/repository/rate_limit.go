@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type RateLimitRepository interface {
+	GetBlocks(ctx context.Context) ([]*model.RateLimitBlock, error)
+	DeleteBlock(ctx context.Context, userID string) error
+	GetExemptions(ctx context.Context) ([]*model.RateLimitExemption, error)
+	SaveExemption(ctx context.Context, exemption *model.RateLimitExemption) error
+	DeleteExemption(ctx context.Context, kind model.RateLimitExemptionKind, value string) (bool, error)
+}
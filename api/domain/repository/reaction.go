@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+)
+
+type ReactionRepository interface {
+	// Add and Remove report whether anything changed, so duplicates are no-ops
+	Add(ctx context.Context, roomID, messageID, userID, emoji string) (bool, error)
+	Remove(ctx context.Context, roomID, messageID, userID, emoji string) (bool, error)
+	GetCounts(ctx context.Context, roomID, messageID string) (map[string]int, error)
+	GetCountsForMessages(ctx context.Context, roomID string, messageIDs []string) (map[string]map[string]int, error)
+	DeleteByMessage(ctx context.Context, roomID, messageID string) error
+}
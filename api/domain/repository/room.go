@@ -2,17 +2,33 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/hilthontt/visper/api/domain/model"
 )
 
+// Membership changes check and write in one atomic step, these report why one was refused
+var (
+	ErrRoomFull       = errors.New("room is full")
+	ErrNotRoomOwner   = errors.New("requester does not own the room")
+	ErrOwnerProtected = errors.New("the room owner cannot be removed")
+	ErrNotRoomMember  = errors.New("user is not a member of the room")
+)
+
 type RoomRepository interface {
 	Create(ctx context.Context, room *model.Room) error
 	GetByID(ctx context.Context, id string) (*model.Room, error)
+	// GetByJoinCode looks the room up through an index, not found is redis.Nil like GetByID
+	GetByJoinCode(ctx context.Context, joinCode string) (*model.Room, error)
 	GetAll(ctx context.Context) ([]*model.Room, error)
+	Count(ctx context.Context) (int64, error)
 	Delete(ctx context.Context, id string) error
+	// AddUser is a no-op for members and returns ErrRoomFull once MaxMembers is reached
 	AddUser(ctx context.Context, roomID string, user model.User) error
+	// RemoveUser returns ErrOwnerProtected for the owner, removing a non-member is a no-op
 	RemoveUser(ctx context.Context, roomID, userID string) error
+	// KickUser removes userID only while requesterID owns the room and userID is a member
+	KickUser(ctx context.Context, roomID, userID, requesterID string) error
 	GetUsers(ctx context.Context, roomID string) ([]string, error)
 	Update(ctx context.Context, room *model.Room) error
 }
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type RoomBanRepository interface {
+	Create(ctx context.Context, ban *model.RoomBan, expiresAt time.Time) error
+	Delete(ctx context.Context, roomID, userID string) (bool, error)
+	GetAll(ctx context.Context, roomID string) ([]*model.RoomBan, error)
+	IsBanned(ctx context.Context, roomID, userID, tokenHash string) (bool, error)
+	SetMemberToken(ctx context.Context, roomID, userID, tokenHash string, expiresAt time.Time) error
+	GetMemberToken(ctx context.Context, roomID, userID string) (string, error)
+	// ExtendExpiry moves the room's bans and member tokens to a later expiry
+	ExtendExpiry(ctx context.Context, roomID string, expiresAt time.Time) error
+}
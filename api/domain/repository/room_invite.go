@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type RoomInviteRepository interface {
+	Create(ctx context.Context, invite *model.RoomInvite) error
+	Get(ctx context.Context, token string) (*model.RoomInvite, error)
+	GetAll(ctx context.Context, roomID string) ([]*model.RoomInvite, error)
+	Delete(ctx context.Context, roomID, token string) (bool, error)
+	// Redeem counts a use and reports false once the usage limit is reached
+	Redeem(ctx context.Context, invite *model.RoomInvite) (bool, error)
+	Release(ctx context.Context, token string) error
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type ShortLinkRepository interface {
+	// Create returns false when the code is already taken
+	Create(ctx context.Context, link *model.ShortLink, ttl time.Duration) (bool, error)
+	GetByCode(ctx context.Context, code string) (*model.ShortLink, error)
+}
@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+type SlowModeRepository interface {
+	// Acquire starts the member's cooldown and returns zero, or returns what is left of a running one
+	Acquire(ctx context.Context, roomID, userID string, interval time.Duration) (time.Duration, error)
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type SocketTicketRepository interface {
+	Create(ctx context.Context, ticket *model.SocketTicket) error
+	// Consume returns the ticket and deletes it, nil when it doesn't exist or already expired
+	Consume(ctx context.Context, token string) (*model.SocketTicket, error)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type StatsRepository interface {
+	IncrementMessages(ctx context.Context) error
+	AddUploadBytes(ctx context.Context, bytes int64) error
+	GetMessageCount(ctx context.Context, window time.Duration) (int64, error)
+	GetUploadBytes(ctx context.Context, window time.Duration) (int64, error)
+
+	// Per room counters, kept for as long as the room sees activity
+	RecordRoomMessage(ctx context.Context, roomID string, size int) error
+	RecordRoomConcurrency(ctx context.Context, roomID string, connected int) error
+	GetRoomStats(ctx context.Context, roomID string) (*model.RoomStats, error)
+}
@@ -11,5 +11,6 @@ type UserRepository interface {
 	GetByID(ctx context.Context, id string) (*model.User, error)
 	GetByUsername(ctx context.Context, username string) (*model.User, error)
 	SetUsernameIndex(ctx context.Context, username, userID string) error
+	DeleteUsernameIndex(ctx context.Context, username, userID string) (bool, error)
 	Delete(ctx context.Context, id string) error
 }
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *model.RoomWebhook, expiresAt time.Time) error
+	Get(ctx context.Context, roomID, webhookID string) (*model.RoomWebhook, error)
+	GetAll(ctx context.Context, roomID string) ([]*model.RoomWebhook, error)
+	Delete(ctx context.Context, roomID, webhookID string) (bool, error)
+	Count(ctx context.Context, roomID string) (int64, error)
+
+	// AppendDelivery keeps the most recent deliveries per webhook, older ones are dropped
+	AppendDelivery(ctx context.Context, delivery *model.WebhookDelivery, expiresAt time.Time) error
+	GetDeliveries(ctx context.Context, roomID, webhookID string, limit int64) ([]*model.WebhookDelivery, error)
+
+	// The incoming token is stored hashed, setting a new one replaces the previous token
+	SetIncomingToken(ctx context.Context, roomID, tokenHash string, expiresAt time.Time) error
+	GetRoomByIncomingToken(ctx context.Context, tokenHash string) (string, error)
+	DeleteIncomingToken(ctx context.Context, roomID string) (bool, error)
+}
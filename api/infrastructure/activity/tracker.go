@@ -0,0 +1,93 @@
+package activity
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxLabeledRooms caps how many rooms get their own series. Room IDs are unbounded, so
+	// rooms seen after the cap is reached share the "other" label until the next restart.
+	maxLabeledRooms = 200
+	otherRoomsLabel = "other"
+
+	recordTimeout = 2 * time.Second
+)
+
+// RoomTracker records per room engagement, in Redis for the owner's stats endpoint and as
+// room labeled metrics for dashboards
+type RoomTracker struct {
+	stats   repository.StatsRepository
+	metrics metrics.Manager
+	logger  *logger.Logger
+
+	mu sync.Mutex
+	// peaks is this instance's highest concurrency for each labeled room, the key set
+	// doubles as the list of rooms that have a label
+	peaks map[string]int
+}
+
+func NewRoomTracker(stats repository.StatsRepository, metrics metrics.Manager, logger *logger.Logger) *RoomTracker {
+	return &RoomTracker{
+		stats:   stats,
+		metrics: metrics,
+		logger:  logger,
+		peaks:   make(map[string]int),
+	}
+}
+
+// RecordMessage counts a stored message and its size in bytes. A failed write is only
+// logged, the message itself was already saved.
+func (t *RoomTracker) RecordMessage(ctx context.Context, roomID string, size int) {
+	if err := t.stats.RecordRoomMessage(ctx, roomID, size); err != nil {
+		t.logger.Warn("failed to record room message stats", zap.Error(err), zap.String("roomID", roomID))
+	}
+
+	label, _ := t.label(roomID, 0)
+	t.metrics.IncrementCounter(ctx, "room_messages_total", "room", label)
+	t.metrics.RecordHistogram(ctx, "room_message_size_bytes", float64(size), "room", label)
+}
+
+// ConnectionsChanged implements websocket.ActivityRecorder. Gauges can't be summed across
+// rooms, so rooms past the cap only have their peak kept in Redis.
+func (t *RoomTracker) ConnectionsChanged(roomID string, connected int, joined bool) {
+	if label, peak := t.label(roomID, connected); label != otherRoomsLabel {
+		t.metrics.SetGauge("room_active_connections", float64(connected), "room", label)
+		t.metrics.SetGauge("room_peak_connections", float64(peak), "room", label)
+	}
+
+	// Leaving never raises the peak
+	if !joined {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+		defer cancel()
+
+		if err := t.stats.RecordRoomConcurrency(ctx, roomID, connected); err != nil {
+			t.logger.Warn("failed to record room concurrency", zap.Error(err), zap.String("roomID", roomID))
+		}
+	}()
+}
+
+// label returns the room's metric label and its peak after seeing connected
+func (t *RoomTracker) label(roomID string, connected int) (string, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	peak, ok := t.peaks[roomID]
+	if !ok && len(t.peaks) >= maxLabeledRooms {
+		return otherRoomsLabel, 0
+	}
+
+	peak = max(peak, connected)
+	t.peaks[roomID] = peak
+	return roomID, peak
+}
@@ -0,0 +1,208 @@
+package broker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// TopicInfo describes a topic for operators, Leader is the node leading a partition on a
+// replicated broker and 0 otherwise
+type TopicInfo struct {
+	Name       string
+	Partitions []PartitionInfo
+}
+
+type PartitionInfo struct {
+	ID        int
+	SizeBytes int64
+	Leader    int
+}
+
+// GroupInfo describes a consumer group. Members are only known for groups coordinated by
+// this broker's Server, offsets only for consumers running in this process.
+type GroupInfo struct {
+	ID       string
+	Strategy string
+	Members  []MemberInfo
+	Offsets  []OffsetInfo
+}
+
+type MemberInfo struct {
+	ID            string
+	Topics        []string
+	Partitions    map[string][]int
+	LastHeartbeat time.Time
+}
+
+// OffsetInfo estimates how far a group is behind, offsets are byte positions so the lag is in bytes
+type OffsetInfo struct {
+	Topic     string
+	Partition int
+	Committed int64
+	End       int64
+	Lag       int64
+}
+
+// DescribeTopics lists every topic and the size of its partitions, sorted by name
+func (b *Broker) DescribeTopics() []TopicInfo {
+	names := b.ListTopics()
+	sort.Strings(names)
+
+	replicator := b.getReplicator()
+
+	topics := make([]TopicInfo, 0, len(names))
+	for _, name := range names {
+		topic, err := b.GetTopic(name)
+		if err != nil {
+			continue
+		}
+
+		info := TopicInfo{Name: name, Partitions: make([]PartitionInfo, len(topic.partitions))}
+		for i, partition := range topic.partitions {
+			info.Partitions[i] = PartitionInfo{ID: partition.id, SizeBytes: partition.endOffset()}
+			if replicator != nil {
+				info.Partitions[i].Leader = replicator.leader(partition.id)
+			}
+		}
+		topics = append(topics, info)
+	}
+
+	return topics
+}
+
+// DescribeGroups lists the groups with members or committed offsets, sorted by ID
+func (b *Broker) DescribeGroups() []GroupInfo {
+	b.mu.RLock()
+	tracked := make([]*ConsumerGroup, 0, len(b.groups))
+	for _, group := range b.groups {
+		tracked = append(tracked, group)
+	}
+	committed := make(map[string]map[partitionKey]int64, len(b.committed))
+	for groupID, offsets := range b.committed {
+		committed[groupID] = make(map[partitionKey]int64, len(offsets))
+		for key, offset := range offsets {
+			committed[groupID][key] = offset
+		}
+	}
+	b.mu.RUnlock()
+
+	// Groups take the broker's lock while holding theirs, so they are described without it
+	groups := make(map[string]*GroupInfo, len(tracked))
+	for _, group := range tracked {
+		groups[group.groupID] = &GroupInfo{ID: group.groupID, Strategy: group.Strategy(), Members: group.describeMembers()}
+	}
+
+	for groupID, offsets := range committed {
+		info, exists := groups[groupID]
+		if !exists {
+			info = &GroupInfo{ID: groupID}
+			groups[groupID] = info
+		}
+
+		for key, offset := range offsets {
+			topic, err := b.GetTopic(key.topic)
+			if err != nil || key.partition >= len(topic.partitions) {
+				continue
+			}
+			end := topic.partitions[key.partition].endOffset()
+			info.Offsets = append(info.Offsets, OffsetInfo{
+				Topic:     key.topic,
+				Partition: key.partition,
+				Committed: offset,
+				End:       end,
+				Lag:       max(end-offset, 0),
+			})
+		}
+		sort.Slice(info.Offsets, func(i, j int) bool {
+			if info.Offsets[i].Topic != info.Offsets[j].Topic {
+				return info.Offsets[i].Topic < info.Offsets[j].Topic
+			}
+			return info.Offsets[i].Partition < info.Offsets[j].Partition
+		})
+	}
+
+	result := make([]GroupInfo, 0, len(groups))
+	for _, info := range groups {
+		result = append(result, *info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result
+}
+
+// DeleteTopic closes a topic's partitions and removes its files, messages scheduled for
+// it are dropped when due. On a replicated broker only this node's copy is deleted.
+func (b *Broker) DeleteTopic(name string) error {
+	tm := b.topicManager
+
+	tm.mu.Lock()
+	topic, exists := tm.topics[name]
+	if !exists {
+		tm.mu.Unlock()
+		return fmt.Errorf("topic %s does not exist", name)
+	}
+	delete(tm.topics, name)
+	tm.mu.Unlock()
+
+	for _, partition := range topic.partitions {
+		if err := partition.close(); err != nil {
+			return fmt.Errorf("failed to close partition %d: %w", partition.id, err)
+		}
+	}
+
+	b.mu.Lock()
+	for _, offsets := range b.committed {
+		for key := range offsets {
+			if key.topic == name {
+				delete(offsets, key)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	if err := os.RemoveAll(filepath.Join(tm.baseDir, name)); err != nil {
+		return fmt.Errorf("failed to remove topic files: %w", err)
+	}
+	return nil
+}
+
+// commit records where a group's consumer is in a partition
+func (b *Broker) commit(groupID, topic string, partition int, offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	offsets, exists := b.committed[groupID]
+	if !exists {
+		offsets = make(map[partitionKey]int64)
+		b.committed[groupID] = offsets
+	}
+	offsets[partitionKey{topic: topic, partition: partition}] = offset
+}
+
+func (b *Broker) trackGroup(group *ConsumerGroup) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.groups[group.groupID] = group
+}
+
+func (cg *ConsumerGroup) describeMembers() []MemberInfo {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	members := make([]MemberInfo, 0, len(cg.members))
+	for _, member := range cg.members {
+		members = append(members, MemberInfo{
+			ID:            member.id,
+			Topics:        member.topics,
+			Partitions:    member.partitions,
+			LastHeartbeat: member.lastHeartbeat,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+
+	return members
+}
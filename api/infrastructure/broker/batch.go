@@ -0,0 +1,142 @@
+package broker
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Batch format, the records are WriteMessageWithIntegrity's concatenated and compressed
+// together, so a batch costs one write and one checksum over the compressed bytes
+// ┌────────┬─────────────┬───────┬──────────┬─────────┐
+// │ Length │ Compression │ Count │ Checksum │ Records │
+// │ (8B)   │    (1B)     │ (4B)  │   (4B)   │  (var)  │
+// └────────┴─────────────┴───────┴──────────┴─────────┘
+const batchHeaderSize = 1 + 4 + 4
+
+// WriteBatchWithIntegrity writes msgs as one batch and returns the offset of the batch,
+// which every message in it shares. A partition holds either batches or single messages
+// written with WriteMessageWithIntegrity, the two don't mix.
+func (p *Partition) WriteBatchWithIntegrity(msgs []*Message, compression CompressionType) (int64, error) {
+	if len(msgs) == 0 {
+		return -1, fmt.Errorf("batch is empty")
+	}
+
+	records := GetBuffer()
+	defer PutBuffer(records)
+
+	for _, msg := range msgs {
+		if err := appendIntegrityRecord(records, msg); err != nil {
+			return -1, err
+		}
+	}
+
+	block, err := compressBlock(records.Bytes(), compression)
+	if err != nil {
+		return -1, err
+	}
+
+	frame := GetBuffer()
+	defer PutBuffer(frame)
+
+	var header [8 + batchHeaderSize]byte
+	binary.BigEndian.PutUint64(header[0:8], uint64(batchHeaderSize+len(block)))
+	header[8] = byte(compression)
+	binary.BigEndian.PutUint32(header[9:13], uint32(len(msgs)))
+	binary.BigEndian.PutUint32(header[13:17], crc32.ChecksumIEEE(block))
+	frame.Write(header[:])
+	frame.Write(block)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.appendLocked(frame.Bytes())
+}
+
+// ReadBatchWithIntegrity reads the batch at offset and returns its messages and the offset of the next batch
+func (p *Partition) ReadBatchWithIntegrity(offset int64) ([]*Message, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Seek to the offset
+	if _, err := p.file.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, fmt.Errorf("failed to seek to offset: %w", err)
+	}
+
+	// Read batch size
+	sizeBytes := make([]byte, 8)
+	if _, err := io.ReadFull(p.file, sizeBytes); err != nil {
+		return nil, offset, fmt.Errorf("failed to read batch size: %w", err)
+	}
+	totalSize := binary.BigEndian.Uint64(sizeBytes)
+	if totalSize < batchHeaderSize || totalSize > uint64(p.offset-offset) {
+		return nil, offset, fmt.Errorf("invalid batch size %d at offset %d", totalSize, offset)
+	}
+
+	batchBytes := make([]byte, totalSize)
+	if _, err := io.ReadFull(p.file, batchBytes); err != nil {
+		return nil, offset, fmt.Errorf("failed to read batch: %w", err)
+	}
+
+	compression := CompressionType(batchBytes[0])
+	count := binary.BigEndian.Uint32(batchBytes[1:5])
+	checksum := binary.BigEndian.Uint32(batchBytes[5:9])
+	block := batchBytes[batchHeaderSize:]
+
+	if crc32.ChecksumIEEE(block) != checksum {
+		return nil, offset, fmt.Errorf("batch checksum mismatch")
+	}
+
+	records, err := decompressBlock(block, compression)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	msgs := make([]*Message, 0, count)
+	for len(records) > 0 {
+		if len(records) < 8 {
+			return nil, offset, fmt.Errorf("truncated record in batch")
+		}
+		size := binary.BigEndian.Uint64(records[0:8])
+		if size > uint64(len(records)-8) {
+			return nil, offset, fmt.Errorf("record of %d bytes overruns the batch", size)
+		}
+
+		msg, err := parseIntegrityRecord(records[8 : 8+size])
+		if err != nil {
+			return nil, offset, err
+		}
+		msgs = append(msgs, msg)
+		records = records[8+size:]
+	}
+
+	if uint32(len(msgs)) != count {
+		return nil, offset, fmt.Errorf("batch holds %d messages, its header says %d", len(msgs), count)
+	}
+
+	// Calculate next offset
+	nextOffset := offset + int64(8) + int64(totalSize)
+
+	return msgs, nextOffset, nil
+}
+
+// compressBlock compresses a whole batch, uncompressed it returns data itself
+func compressBlock(data []byte, compression CompressionType) ([]byte, error) {
+	compressed, err := Compress(&Message{Value: data}, compression)
+	if err != nil {
+		return nil, err
+	}
+	return compressed.Value, nil
+}
+
+func decompressBlock(block []byte, compression CompressionType) ([]byte, error) {
+	msg, err := Decompress(&CompressedMessage{
+		Message:         Message{Value: block},
+		CompressionType: compression,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.Value, nil
+}
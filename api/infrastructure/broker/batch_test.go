@@ -0,0 +1,131 @@
+package broker
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var batchCompressions = []struct {
+	name        string
+	compression CompressionType
+}{
+	{"none", CompressionNone},
+	{"gzip", CompressionGzip},
+	{"snappy", CompressionSnappy},
+	{"zstd", CompressionZstd},
+}
+
+func newTestPartition(tb testing.TB) *Partition {
+	tb.Helper()
+
+	partition, err := createPartition(&Topic{name: "bench"}, 0, tb.TempDir())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = partition.close() })
+	return partition
+}
+
+// testMessages look like chat events, a JSON body of a few hundred bytes
+func testMessages(n int) []*Message {
+	msgs := make([]*Message, n)
+	for i := range msgs {
+		msgs[i] = &Message{
+			Key:       fmt.Appendf(nil, "room-%d", i%8),
+			Value:     fmt.Appendf(nil, `{"id":"%08d","type":"message.new","content":"%s"}`, i, bytes.Repeat([]byte("hello visper "), 16)),
+			Headers:   map[string]string{"content-type": "application/json"},
+			Timestamp: time.Unix(1_700_000_000+int64(i), 0),
+		}
+	}
+	return msgs
+}
+
+func TestWriteBatchWithIntegrityRoundTrip(t *testing.T) {
+	for _, c := range batchCompressions {
+		t.Run(c.name, func(t *testing.T) {
+			partition := newTestPartition(t)
+			msgs := testMessages(50)
+
+			offset, err := partition.WriteBatchWithIntegrity(msgs, c.compression)
+			if err != nil {
+				t.Fatalf("WriteBatchWithIntegrity() error = %v", err)
+			}
+
+			got, next, err := partition.ReadBatchWithIntegrity(offset)
+			if err != nil {
+				t.Fatalf("ReadBatchWithIntegrity() error = %v", err)
+			}
+			if next != partition.endOffset() {
+				t.Errorf("next offset = %d, want %d", next, partition.endOffset())
+			}
+			if len(got) != len(msgs) {
+				t.Fatalf("read %d messages, want %d", len(got), len(msgs))
+			}
+			for i := range msgs {
+				if !bytes.Equal(got[i].Key, msgs[i].Key) || !bytes.Equal(got[i].Value, msgs[i].Value) {
+					t.Errorf("message %d = %q, want %q", i, got[i].Value, msgs[i].Value)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPartitionWriteSingle is the baseline, one integrity-checked write per message
+func BenchmarkPartitionWriteSingle(b *testing.B) {
+	partition := newTestPartition(b)
+	msgs := testMessages(100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := partition.WriteMessageWithIntegrity(msgs[i%len(msgs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPartitionWriteBatch reports ns/msg so it compares with BenchmarkPartitionWriteSingle
+func BenchmarkPartitionWriteBatch(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		for _, c := range batchCompressions {
+			b.Run(fmt.Sprintf("size=%d/%s", size, c.name), func(b *testing.B) {
+				partition := newTestPartition(b)
+				msgs := testMessages(size)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for range b.N {
+					if _, err := partition.WriteBatchWithIntegrity(msgs, c.compression); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*size), "ns/msg")
+			})
+		}
+	}
+}
+
+func BenchmarkPartitionReadBatch(b *testing.B) {
+	for _, c := range batchCompressions {
+		b.Run(c.name, func(b *testing.B) {
+			partition := newTestPartition(b)
+			msgs := testMessages(100)
+
+			offset, err := partition.WriteBatchWithIntegrity(msgs, c.compression)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, _, err := partition.ReadBatchWithIntegrity(offset); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(msgs)), "ns/msg")
+		})
+	}
+}
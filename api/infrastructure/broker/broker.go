@@ -2,6 +2,7 @@ package broker
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,6 +12,10 @@ import (
 // Broker coordinates the message flow between producers and consumers
 type Broker struct {
 	topicManager *TopicManager
+	replicator   *replicator
+	scheduler    *scheduler
+	groups       map[string]*ConsumerGroup
+	committed    map[string]map[partitionKey]int64 // group -> partition -> offset
 	mu           sync.RWMutex
 }
 
@@ -29,6 +34,8 @@ func NewBroker(dataDir string) (*Broker, error) {
 	// Create broker
 	broker := &Broker{
 		topicManager: topicManager,
+		groups:       make(map[string]*ConsumerGroup),
+		committed:    make(map[string]map[partitionKey]int64),
 	}
 
 	// Load existing topics
@@ -36,6 +43,12 @@ func NewBroker(dataDir string) (*Broker, error) {
 		return nil, fmt.Errorf("failed to load topics: %w", err)
 	}
 
+	scheduler, err := newScheduler(broker, filepath.Join(dataDir, delayedLogName))
+	if err != nil {
+		return nil, err
+	}
+	broker.scheduler = scheduler
+
 	return broker, nil
 }
 
@@ -75,10 +88,30 @@ func (b *Broker) loadTopics() error {
 	return nil
 }
 
-// CreateTopic creates a new topic
+// CreateTopic creates a new topic, on every node when the broker is replicated
 func (b *Broker) CreateTopic(name string, numPartitions int) error {
-	_, err := b.topicManager.CreateTopic(name, numPartitions)
-	return err
+	return b.createTopic(name, numPartitions, true)
+}
+
+func (b *Broker) createTopic(name string, numPartitions int, forward bool) error {
+	topic, err := b.topicManager.CreateTopic(name, numPartitions)
+	if err != nil {
+		return err
+	}
+
+	if r := b.getReplicator(); r != nil {
+		r.follow(topic)
+		if forward {
+			r.forwardCreateTopic(name, numPartitions)
+		}
+	}
+	return nil
+}
+
+func (b *Broker) getReplicator() *replicator {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.replicator
 }
 
 // GetTopic gets a topic by name
@@ -107,15 +140,93 @@ func (b *Broker) ListTopics() []string {
 	return topics
 }
 
+// Close stops the delayed message scheduler and syncs and closes every partition, whatever
+// was produced before it is on disk once it returns. The broker can't be used afterwards.
+func (b *Broker) Close() error {
+	errs := []error{b.scheduler.close()}
+
+	b.topicManager.mu.RLock()
+	defer b.topicManager.mu.RUnlock()
+
+	for _, topic := range b.topicManager.topics {
+		for _, partition := range topic.partitions {
+			if err := partition.close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close %s partition %d: %w", topic.name, partition.id, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Fetch reads up to maxMessages from a partition starting at offset, it returns the offset to continue from.
+// On a replicated broker it stops at what every in-sync replica holds.
+func (b *Broker) Fetch(topicName string, partitionID int, offset int64, maxMessages int) ([]*ConsumerRecord, int64, error) {
+	return b.fetch(topicName, partitionID, offset, maxMessages, 0)
+}
+
+// fetch serves followerID, a node ID, or a consumer when it is 0
+func (b *Broker) fetch(topicName string, partitionID int, offset int64, maxMessages int, followerID int) ([]*ConsumerRecord, int64, error) {
+	topic, err := b.GetTopic(topicName)
+	if err != nil {
+		return nil, offset, err
+	}
+	if partitionID < 0 || partitionID >= len(topic.partitions) {
+		return nil, offset, fmt.Errorf("topic %s has no partition %d", topicName, partitionID)
+	}
+
+	partition := topic.partitions[partitionID]
+	end := partition.endOffset()
+
+	if r := b.getReplicator(); r != nil && r.isLeader(partitionID) {
+		if followerID > 0 {
+			r.recordFetch(topicName, partitionID, followerID, offset, end)
+		} else {
+			end = r.highWatermark(topicName, partitionID, end)
+		}
+	}
+
+	var records []*ConsumerRecord
+	for offset < end && len(records) < maxMessages {
+		msg, nextOffset, err := partition.readMessage(offset)
+		if err != nil {
+			return records, offset, fmt.Errorf("failed to read message: %w", err)
+		}
+
+		records = append(records, &ConsumerRecord{
+			Topic:     topicName,
+			Partition: partitionID,
+			Offset:    offset,
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Headers:   msg.Headers,
+			Timestamp: msg.Timestamp,
+		})
+		offset = nextOffset
+	}
+
+	return records, offset, nil
+}
+
 func serializeMessage(msg *Message) ([]byte, error) {
 	// Format matches your existing writeMessage:
 	// [size(8)][keySize(4)][timestamp(8)][key][value]
+	// or with headers, flagged in keySize:
+	// [size(8)][keySize(4)][timestamp(8)][headersSize(4)][headers][key][value]
+
+	if err := validateHeaders(msg.Headers); err != nil {
+		return nil, err
+	}
+	headers := encodeHeaders(msg.Headers)
 
 	keyLen := len(msg.Key)
 	valueLen := len(msg.Value)
 
 	// Header size: keySize(4) + timestamp(8) = 12 bytes
 	headerSize := 12
+	if headers != nil {
+		headerSize += 4 + len(headers)
+	}
 	totalSize := uint64(headerSize + keyLen + valueLen)
 
 	// Allocate buffer: size prefix + header + key + value
@@ -127,13 +238,24 @@ func serializeMessage(msg *Message) ([]byte, error) {
 	offset += 8
 
 	// Write key size (4 bytes)
-	binary.BigEndian.PutUint32(buf[offset:], uint32(keyLen))
+	keySize := uint32(keyLen)
+	if headers != nil {
+		keySize |= headersFlag
+	}
+	binary.BigEndian.PutUint32(buf[offset:], keySize)
 	offset += 4
 
 	// Write timestamp (8 bytes)
 	binary.BigEndian.PutUint64(buf[offset:], uint64(msg.Timestamp.UnixNano()))
 	offset += 8
 
+	// Write headers
+	if headers != nil {
+		binary.BigEndian.PutUint32(buf[offset:], uint32(len(headers)))
+		offset += 4
+		offset += copy(buf[offset:], headers)
+	}
+
 	// Write key
 	if keyLen > 0 {
 		copy(buf[offset:], msg.Key)
@@ -0,0 +1,196 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const clientTimeout = 10 * time.Second
+
+// Client talks to a broker Server. It keeps one connection, redialed on the next call
+// after a network error, and is safe for concurrent use.
+type Client struct {
+	address string
+	token   string // Sent first on every connection when set
+	dialer  net.Dialer
+	conn    net.Conn
+	mu      sync.Mutex
+}
+
+// Dial connects to the broker server at address, token has to match the server's
+func Dial(address, token string) (*Client, error) {
+	c := newClient(address, token)
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+
+	return c, nil
+}
+
+// newClient connects on the first call
+func newClient(address, token string) *Client {
+	return &Client{address: address, token: token, dialer: net.Dialer{Timeout: clientTimeout}}
+}
+
+// Produce mirrors Producer.Produce, it returns the partition and offset the message was written at
+func (c *Client) Produce(topicName string, msg *Message) (int, int64, error) {
+	return c.ProduceWithAcks(topicName, msg, AckLeader)
+}
+
+// ProduceWithAcks waits for the given acknowledgment level, AckAll returns once every
+// in-sync replica has the message
+func (c *Client) ProduceWithAcks(topicName string, msg *Message, acks AckMode) (int, int64, error) {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	var res ProduceResponse
+	err := c.call(opProduce, ProduceRequest{
+		Topic:     topicName,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   msg.Headers,
+		Timestamp: msg.Timestamp,
+		DeliverAt: deliverAt(msg),
+		Acks:      acks,
+	}, &res)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	return res.Partition, res.Offset, nil
+}
+
+// Fetch reads up to maxMessages from a partition, it returns the offset to fetch from next
+func (c *Client) Fetch(topicName string, partition int, offset int64, maxMessages int) ([]*ConsumerRecord, int64, error) {
+	var res FetchResponse
+	err := c.call(opFetch, FetchRequest{
+		Topic:       topicName,
+		Partition:   partition,
+		Offset:      offset,
+		MaxMessages: maxMessages,
+	}, &res)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	return res.Records, res.NextOffset, nil
+}
+
+func (c *Client) CreateTopic(name string, numPartitions int) error {
+	return c.call(opCreateTopic, CreateTopicRequest{Name: name, Partitions: numPartitions}, nil)
+}
+
+// JoinGroup returns the partitions assigned to the member, per topic
+func (c *Client) JoinGroup(groupID, memberID string, topics []string) (map[string][]int, error) {
+	return c.JoinGroupWithStrategy(groupID, memberID, topics, "")
+}
+
+// JoinGroupWithStrategy joins a group using the named assignment strategy, it fails when
+// the group already exists with another one
+func (c *Client) JoinGroupWithStrategy(groupID, memberID string, topics []string, strategy string) (map[string][]int, error) {
+	var res AssignmentResponse
+	req := JoinGroupRequest{Group: groupID, Member: memberID, Topics: topics, Strategy: strategy}
+	if err := c.call(opJoinGroup, req, &res); err != nil {
+		return nil, err
+	}
+	return res.Partitions, nil
+}
+
+// Heartbeat keeps the member in its group and returns its current assignment. It fails
+// once the member was dropped for missing heartbeats, the member has to join again.
+func (c *Client) Heartbeat(groupID, memberID string) (map[string][]int, error) {
+	var res AssignmentResponse
+	if err := c.call(opHeartbeat, GroupMemberRequest{Group: groupID, Member: memberID}, &res); err != nil {
+		return nil, err
+	}
+	return res.Partitions, nil
+}
+
+func (c *Client) LeaveGroup(groupID, memberID string) error {
+	return c.call(opLeaveGroup, GroupMemberRequest{Group: groupID, Member: memberID}, nil)
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// call sends one request and decodes its response into out, errors reported by the
+// server come back as plain errors with the server's message
+func (c *Client) call(op byte, req, out any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		conn, err := c.connect()
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+	}
+
+	status, body, err := roundTrip(c.conn, op, req)
+	if err != nil {
+		c.conn.Close()
+		c.conn = nil
+		return err
+	}
+
+	if status != statusOK {
+		var res errorResponse
+		if err := json.Unmarshal(body, &res); err != nil {
+			return fmt.Errorf("broker returned an unreadable error: %w", err)
+		}
+		return errors.New(res.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// connect dials the server and authenticates when the client has a token
+func (c *Client) connect() (net.Conn, error) {
+	conn, err := c.dialer.Dial("tcp", c.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach broker at %s: %w", c.address, err)
+	}
+	if c.token == "" {
+		return conn, nil
+	}
+
+	status, _, err := roundTrip(conn, opAuth, AuthRequest{Token: c.token})
+	if err == nil && status != statusOK {
+		err = errors.New("token rejected")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to authenticate with broker at %s: %w", c.address, err)
+	}
+	return conn, nil
+}
+
+func roundTrip(conn net.Conn, op byte, req any) (byte, []byte, error) {
+	_ = conn.SetDeadline(time.Now().Add(clientTimeout))
+
+	if err := writeFrame(conn, op, req); err != nil {
+		return 0, nil, err
+	}
+	return readFrame(conn)
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 )
 
 // CompressionType defines the compression algorithm used
@@ -21,6 +22,16 @@ const (
 
 	// CompressionSnappy uses snappy compression
 	CompressionSnappy CompressionType = 2
+
+	// CompressionZstd uses zstd compression
+	CompressionZstd CompressionType = 3
+)
+
+// Encoders and decoders are safe for concurrent EncodeAll and DecodeAll calls, they
+// only fail on invalid options
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
 )
 
 // CompressedMessage represents a message with compression metadata
@@ -58,6 +69,8 @@ func Compress(msg *Message, compressionType CompressionType) (*CompressedMessage
 		compMsg.Value = buf.Bytes()
 	case CompressionSnappy:
 		compMsg.Value = snappy.Encode(nil, msg.Value)
+	case CompressionZstd:
+		compMsg.Value = zstdEncoder.EncodeAll(msg.Value, nil)
 	default:
 		return nil, fmt.Errorf("unknown compression type: %d", compressionType)
 	}
@@ -100,6 +113,13 @@ func Decompress(compMsg *CompressedMessage) (*Message, error) {
 			return nil, fmt.Errorf("failed to decompress with snappy: %w", err)
 		}
 
+		msg.Value = value
+	case CompressionZstd:
+		value, err := zstdDecoder.DecodeAll(compMsg.Value, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress with zstd: %w", err)
+		}
+
 		msg.Value = value
 	default:
 		return nil, fmt.Errorf("unknown compression type: %d", compMsg.CompressionType)
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"sync"
 	"time"
 )
@@ -25,6 +24,7 @@ type ConsumerRecord struct {
 	Offset    int64
 	Key       []byte
 	Value     []byte
+	Headers   map[string]string
 	Timestamp time.Time
 }
 
@@ -113,6 +113,7 @@ func (c *Consumer) Poll(timeoutMs int) ([]*ConsumerRecord, error) {
 				Offset:    offset,
 				Key:       msg.Key,
 				Value:     msg.Value,
+				Headers:   msg.Headers,
 				Timestamp: msg.Timestamp,
 			}
 
@@ -153,9 +154,8 @@ func (c *Consumer) CommitOffsets() error {
 // commitOffset commits a single offset to storage
 func (c *Consumer) commitOffset(topic string, partition int, offset int64) error {
 	// In a real implementation, this would persist the offset
-	// to a file or database. For simplicity, we're just logging it.
-	log.Printf("Committed offset %d for topic %s, partition %d",
-		offset, topic, partition)
+	// to a file or database. For simplicity, the broker only keeps it in memory.
+	c.broker.commit(c.groupID, topic, partition, offset)
 	return nil
 }
 
@@ -7,16 +7,45 @@ import (
 	"time"
 )
 
+// Assignment strategies a group can be created with
+const (
+	StrategyRange             = "range"
+	StrategyRoundRobin        = "roundrobin"
+	StrategyCooperativeSticky = "cooperative-sticky"
+)
+
 // ConsumerGroup coordinates multiple consumers in a group
 type ConsumerGroup struct {
-	broker   *Broker
-	groupID  string
-	members  map[string]*GroupMember
-	topics   map[string][]int // topic -> partitions
-	mu       sync.Mutex
-	strategy PartitionAssignmentStrategy
+	broker       *Broker
+	groupID      string
+	members      map[string]*GroupMember
+	topics       map[string][]int // topic -> partitions
+	mu           sync.Mutex
+	strategy     PartitionAssignmentStrategy
+	strategyName string
+
+	// A cooperative group doesn't hand a partition to its new owner in the rebalance that
+	// takes it from the old one, it waits for the old owner to heartbeat after seeing the
+	// revocation so no partition is read by two members at once
+	cooperative bool
+	revoking    map[string]bool           // member -> whether it was told about its revocation
+	withheld    map[string]map[int]string // topic -> partition -> member giving it up
+
+	callbacks map[string]RebalanceCallback
+	events    []RebalanceEvent
 }
 
+// RebalanceEvent tells a member which partitions a rebalance took from it and gave it
+type RebalanceEvent struct {
+	Group    string
+	Member   string
+	Revoked  map[string][]int
+	Assigned map[string][]int
+}
+
+// RebalanceCallback is called after the group's lock is released, it may call back into the group
+type RebalanceCallback func(event RebalanceEvent)
+
 // GroupMember represents a member of a consumer group
 type GroupMember struct {
 	id            string
@@ -99,19 +128,187 @@ func RangeAssignmentStrategy(members map[string]*GroupMember, topics map[string]
 	return assignments
 }
 
+// RoundRobinAssignmentStrategy deals every topic's partitions out one at a time to the
+// members in turn, skipping members not subscribed to the topic
+func RoundRobinAssignmentStrategy(members map[string]*GroupMember, topics map[string][]int) map[string]map[string][]int {
+	assignments, topicMembers := emptyAssignments(members, topics)
+
+	memberIDs := make([]string, 0, len(members))
+	for memberID := range members {
+		memberIDs = append(memberIDs, memberID)
+	}
+	sort.Strings(memberIDs)
+
+	topicNames := make([]string, 0, len(topics))
+	for topic := range topics {
+		topicNames = append(topicNames, topic)
+	}
+	sort.Strings(topicNames)
+
+	next := 0
+	for _, topic := range topicNames {
+		subscribed := make(map[string]bool, len(topicMembers[topic]))
+		for _, memberID := range topicMembers[topic] {
+			subscribed[memberID] = true
+		}
+		if len(subscribed) == 0 {
+			continue
+		}
+
+		for _, partition := range topics[topic] {
+			for !subscribed[memberIDs[next%len(memberIDs)]] {
+				next++
+			}
+			memberID := memberIDs[next%len(memberIDs)]
+			assignments[memberID][topic] = append(assignments[memberID][topic], partition)
+			next++
+		}
+	}
+
+	return assignments
+}
+
+// StickyAssignmentStrategy balances partitions like the range strategy but lets members
+// keep the partitions they already have, so a rebalance moves as few as possible
+func StickyAssignmentStrategy(members map[string]*GroupMember, topics map[string][]int) map[string]map[string][]int {
+	assignments, topicMembers := emptyAssignments(members, topics)
+
+	for topic, partitions := range topics {
+		interestedMembers := topicMembers[topic]
+		if len(interestedMembers) == 0 {
+			continue
+		}
+
+		quota := len(partitions) / len(interestedMembers)
+		extra := len(partitions) % len(interestedMembers)
+
+		exists := make(map[int]bool, len(partitions))
+		for _, partition := range partitions {
+			exists[partition] = true
+		}
+
+		// Members keep up to their quota of what they own, the first ones over it keep one more
+		claimed := make(map[int]bool, len(partitions))
+		owned := make(map[string][]int, len(interestedMembers))
+		for _, memberID := range interestedMembers {
+			current := append([]int(nil), members[memberID].partitions[topic]...)
+			sort.Ints(current)
+			for _, partition := range current {
+				if exists[partition] && !claimed[partition] {
+					claimed[partition] = true
+					owned[memberID] = append(owned[memberID], partition)
+				}
+			}
+		}
+		for _, memberID := range interestedMembers {
+			keep := min(len(owned[memberID]), quota)
+			if len(owned[memberID]) > quota && extra > 0 {
+				keep++
+				extra--
+			}
+			for _, partition := range owned[memberID][keep:] {
+				delete(claimed, partition)
+			}
+			assignments[memberID][topic] = append(assignments[memberID][topic], owned[memberID][:keep]...)
+		}
+
+		// The rest goes to whoever has the fewest
+		for _, partition := range partitions {
+			if claimed[partition] {
+				continue
+			}
+			target := interestedMembers[0]
+			for _, memberID := range interestedMembers[1:] {
+				if len(assignments[memberID][topic]) < len(assignments[target][topic]) {
+					target = memberID
+				}
+			}
+			assignments[target][topic] = append(assignments[target][topic], partition)
+		}
+
+		for _, memberID := range interestedMembers {
+			sort.Ints(assignments[memberID][topic])
+		}
+	}
+
+	return assignments
+}
+
+// emptyAssignments prepares an empty assignment per member and lists, sorted, the members
+// subscribed to each topic
+func emptyAssignments(members map[string]*GroupMember, topics map[string][]int) (map[string]map[string][]int, map[string][]string) {
+	assignments := make(map[string]map[string][]int)
+	topicMembers := make(map[string][]string)
+
+	for memberID, member := range members {
+		assignments[memberID] = make(map[string][]int)
+		for _, topic := range member.topics {
+			assignments[memberID][topic] = []int{}
+			if _, exists := topics[topic]; exists {
+				topicMembers[topic] = append(topicMembers[topic], memberID)
+			}
+		}
+	}
+
+	for _, memberIDs := range topicMembers {
+		sort.Strings(memberIDs)
+	}
+
+	return assignments, topicMembers
+}
+
 // NewConsumerGroup creates a new consumer group
 func NewConsumerGroup(broker *Broker, groupID string) *ConsumerGroup {
-	return &ConsumerGroup{
-		broker:   broker,
-		groupID:  groupID,
-		members:  make(map[string]*GroupMember),
-		topics:   make(map[string][]int),
-		strategy: RangeAssignmentStrategy,
+	group, _ := NewConsumerGroupWithStrategy(broker, groupID, StrategyRange)
+	return group
+}
+
+// NewConsumerGroupWithStrategy creates a consumer group assigning partitions with the named strategy
+func NewConsumerGroupWithStrategy(broker *Broker, groupID, strategy string) (*ConsumerGroup, error) {
+	group := &ConsumerGroup{
+		broker:       broker,
+		groupID:      groupID,
+		members:      make(map[string]*GroupMember),
+		topics:       make(map[string][]int),
+		strategyName: strategy,
+		revoking:     make(map[string]bool),
+		withheld:     make(map[string]map[int]string),
+		callbacks:    make(map[string]RebalanceCallback),
+	}
+
+	switch strategy {
+	case StrategyRange:
+		group.strategy = RangeAssignmentStrategy
+	case StrategyRoundRobin:
+		group.strategy = RoundRobinAssignmentStrategy
+	case StrategyCooperativeSticky:
+		group.strategy = StickyAssignmentStrategy
+		group.cooperative = true
+	default:
+		return nil, fmt.Errorf("unknown assignment strategy %q", strategy)
 	}
+
+	broker.trackGroup(group)
+	return group, nil
+}
+
+// Strategy returns the name of the group's assignment strategy
+func (cg *ConsumerGroup) Strategy() string {
+	return cg.strategyName
+}
+
+// OnRebalance registers a callback for the rebalances that change a member's partitions,
+// it is dropped when the member leaves the group
+func (cg *ConsumerGroup) OnRebalance(memberID string, callback RebalanceCallback) {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	cg.callbacks[memberID] = callback
 }
 
 // Join adds a consumer to the group
 func (cg *ConsumerGroup) Join(memberID string, topics []string) (map[string][]int, error) {
+	defer cg.notify()
 	cg.mu.Lock()
 	defer cg.mu.Unlock()
 
@@ -150,18 +347,20 @@ func (cg *ConsumerGroup) Join(memberID string, topics []string) (map[string][]in
 	}
 
 	// Rebalance group
-	assignments := cg.rebalance()
+	cg.rebalance()
+	cg.seen(memberID)
 
-	return assignments[memberID], nil
+	return cg.members[memberID].partitions, nil
 }
 
 // Leave removes a consumer from the group
 func (cg *ConsumerGroup) Leave(memberID string) error {
+	defer cg.notify()
 	cg.mu.Lock()
 	defer cg.mu.Unlock()
 
 	// Remove member
-	delete(cg.members, memberID)
+	cg.remove(memberID)
 
 	// Rebalance group
 	cg.rebalance()
@@ -171,6 +370,7 @@ func (cg *ConsumerGroup) Leave(memberID string) error {
 
 // Heartbeat updates a member's last heartbeat time
 func (cg *ConsumerGroup) Heartbeat(memberID string) error {
+	defer cg.notify()
 	cg.mu.Lock()
 	defer cg.mu.Unlock()
 
@@ -183,11 +383,33 @@ func (cg *ConsumerGroup) Heartbeat(memberID string) error {
 	// Update heartbeat
 	member.lastHeartbeat = time.Now()
 
+	// A member heartbeating after it was told about its revocation stopped reading
+	// those partitions, they can go to their new owners
+	if cg.revoking[memberID] {
+		cg.release(memberID)
+		cg.rebalance()
+	}
+
 	return nil
 }
 
+// Assignment returns the partitions currently assigned to a member
+func (cg *ConsumerGroup) Assignment(memberID string) (map[string][]int, error) {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	member, exists := cg.members[memberID]
+	if !exists {
+		return nil, fmt.Errorf("member %s does not exist", memberID)
+	}
+	cg.seen(memberID)
+
+	return member.partitions, nil
+}
+
 // CheckHeartbeats removes members that haven't sent a heartbeat recently
 func (cg *ConsumerGroup) CheckHeartbeats() {
+	defer cg.notify()
 	cg.mu.Lock()
 	defer cg.mu.Unlock()
 
@@ -203,7 +425,7 @@ func (cg *ConsumerGroup) CheckHeartbeats() {
 
 	// Remove expired members
 	for _, memberID := range expiredMembers {
-		delete(cg.members, memberID)
+		cg.remove(memberID)
 	}
 
 	// Rebalance if any members were removed
@@ -212,11 +434,15 @@ func (cg *ConsumerGroup) CheckHeartbeats() {
 	}
 }
 
-// rebalance reassigns partitions to consumers
-func (cg *ConsumerGroup) rebalance() map[string]map[string][]int {
+// rebalance reassigns partitions to consumers, cg.mu is held
+func (cg *ConsumerGroup) rebalance() {
 	// Assign partitions using strategy
 	assignments := cg.strategy(cg.members, cg.topics)
 
+	if cg.cooperative {
+		cg.withholdMoves(assignments)
+	}
+
 	// Update member assignments
 	for memberID, topicPartitions := range assignments {
 		member, exists := cg.members[memberID]
@@ -224,8 +450,130 @@ func (cg *ConsumerGroup) rebalance() map[string]map[string][]int {
 			continue
 		}
 
+		revoked := partitionsDiff(member.partitions, topicPartitions)
+		assigned := partitionsDiff(topicPartitions, member.partitions)
+		if _, listening := cg.callbacks[memberID]; listening && (len(revoked) > 0 || len(assigned) > 0) {
+			cg.events = append(cg.events, RebalanceEvent{Group: cg.groupID, Member: memberID, Revoked: revoked, Assigned: assigned})
+		}
+
 		member.partitions = topicPartitions
 	}
+}
 
-	return assignments
+// withholdMoves leaves the partitions moving between two members of the group unassigned
+// until their current owner confirms it let go of them
+func (cg *ConsumerGroup) withholdMoves(assignments map[string]map[string][]int) {
+	// Partitions still being given up keep their old owner
+	owners := make(map[string]map[int]string)
+	for topic, partitions := range cg.withheld {
+		owners[topic] = make(map[int]string)
+		for partition, memberID := range partitions {
+			owners[topic][partition] = memberID
+		}
+	}
+	for memberID, member := range cg.members {
+		for topic, partitions := range member.partitions {
+			if owners[topic] == nil {
+				owners[topic] = make(map[int]string)
+			}
+			for _, partition := range partitions {
+				owners[topic][partition] = memberID
+			}
+		}
+	}
+
+	for memberID, topicPartitions := range assignments {
+		for topic, partitions := range topicPartitions {
+			kept := partitions[:0]
+			for _, partition := range partitions {
+				owner, owned := owners[topic][partition]
+				if owned && owner != memberID {
+					if cg.withheld[topic] == nil {
+						cg.withheld[topic] = make(map[int]string)
+					}
+					if _, known := cg.withheld[topic][partition]; !known {
+						// The owner has to see this revocation too before confirming
+						cg.withheld[topic][partition] = owner
+						cg.revoking[owner] = false
+					}
+					continue
+				}
+				kept = append(kept, partition)
+			}
+			topicPartitions[topic] = kept
+		}
+	}
+}
+
+// seen marks a member as told about its revocation once it fetched its assignment, cg.mu is held
+func (cg *ConsumerGroup) seen(memberID string) {
+	if _, revoking := cg.revoking[memberID]; revoking {
+		cg.revoking[memberID] = true
+	}
+}
+
+// remove drops a member, the partitions it was giving up need no confirmation anymore, cg.mu is held
+func (cg *ConsumerGroup) remove(memberID string) {
+	member, exists := cg.members[memberID]
+	if !exists {
+		return
+	}
+
+	if _, listening := cg.callbacks[memberID]; listening && len(member.partitions) > 0 {
+		cg.events = append(cg.events, RebalanceEvent{Group: cg.groupID, Member: memberID, Revoked: member.partitions})
+	}
+
+	delete(cg.members, memberID)
+	delete(cg.callbacks, memberID)
+	cg.release(memberID)
+}
+
+// release frees the partitions a member was giving up, cg.mu is held
+func (cg *ConsumerGroup) release(memberID string) {
+	delete(cg.revoking, memberID)
+	for topic, partitions := range cg.withheld {
+		for partition, owner := range partitions {
+			if owner == memberID {
+				delete(partitions, partition)
+			}
+		}
+		if len(partitions) == 0 {
+			delete(cg.withheld, topic)
+		}
+	}
+}
+
+// notify runs the callbacks of the rebalances since the last call, outside the group's lock
+func (cg *ConsumerGroup) notify() {
+	cg.mu.Lock()
+	events := cg.events
+	cg.events = nil
+	callbacks := make(map[string]RebalanceCallback, len(events))
+	for _, event := range events {
+		callbacks[event.Member] = cg.callbacks[event.Member]
+	}
+	cg.mu.Unlock()
+
+	for _, event := range events {
+		if callback := callbacks[event.Member]; callback != nil {
+			callback(event)
+		}
+	}
+}
+
+// partitionsDiff returns the partitions in a missing from b
+func partitionsDiff(a, b map[string][]int) map[string][]int {
+	diff := make(map[string][]int)
+	for topic, partitions := range a {
+		in := make(map[int]bool, len(b[topic]))
+		for _, partition := range b[topic] {
+			in[partition] = true
+		}
+		for _, partition := range partitions {
+			if !in[partition] {
+				diff[topic] = append(diff[topic], partition)
+			}
+		}
+	}
+	return diff
 }
@@ -0,0 +1,101 @@
+package broker
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// A key size with headersFlag set says headers follow the timestamp, as a 4 byte length
+// and the encoded headers. Records written before headers existed never set it.
+const headersFlag = 1 << 31
+
+// encodeHeaders writes the headers sorted by name, each as a 2 byte name length, the name,
+// a 4 byte value length and the value. It returns nil for no headers.
+func encodeHeaders(headers map[string]string) []byte {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data []byte
+	for _, name := range names {
+		data = binary.BigEndian.AppendUint16(data, uint16(len(name)))
+		data = append(data, name...)
+		data = binary.BigEndian.AppendUint32(data, uint32(len(headers[name])))
+		data = append(data, headers[name]...)
+	}
+	return data
+}
+
+func decodeHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("truncated header name")
+		}
+		nameLen := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if len(data) < nameLen+4 {
+			return nil, fmt.Errorf("truncated header name")
+		}
+		name := string(data[:nameLen])
+		data = data[nameLen:]
+
+		valueLen := int(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		if len(data) < valueLen {
+			return nil, fmt.Errorf("truncated header %s", name)
+		}
+		headers[name] = string(data[:valueLen])
+		data = data[valueLen:]
+	}
+	return headers, nil
+}
+
+func validateHeaders(headers map[string]string) error {
+	for name := range headers {
+		if name == "" || len(name) > 0xFFFF {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	return nil
+}
+
+// splitHeaders reads the headers at the start of data when flagged in keySize, it
+// returns the key size without the flag and what follows the headers
+func splitHeaders(keySize uint32, data []byte) (uint32, map[string]string, []byte, error) {
+	if keySize&headersFlag == 0 {
+		return keySize, nil, data, nil
+	}
+	keySize &^= headersFlag
+
+	if len(data) < 4 {
+		return 0, nil, nil, fmt.Errorf("truncated headers")
+	}
+	headersLen := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	if uint64(len(data)) < uint64(headersLen) {
+		return 0, nil, nil, fmt.Errorf("truncated headers")
+	}
+
+	headers, err := decodeHeaders(data[:headersLen])
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return keySize, headers, data[headersLen:], nil
+}
+
+// messageSize is the number of bytes writeMessage appends for msg
+func messageSize(msg *Message) int64 {
+	size := int64(8 + 12 + len(msg.Key) + len(msg.Value))
+	if headers := encodeHeaders(msg.Headers); headers != nil {
+		size += int64(4 + len(headers))
+	}
+	return size
+}
@@ -0,0 +1,90 @@
+package broker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// writeCorruptRecord appends a record whose length prefix is size, followed by a few bytes of body,
+// and returns where it starts
+func writeCorruptRecord(t *testing.T, sp *SegmentedPartition, size uint64) int64 {
+	t.Helper()
+
+	segment := sp.activeSegment
+	position := segment.baseOffset + segment.size
+
+	record := binary.BigEndian.AppendUint64(nil, size)
+	record = append(record, bytes.Repeat([]byte{0xAB}, 16)...)
+	if _, err := segment.file.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	segment.size += int64(len(record))
+	return position
+}
+
+func writeTestMessages(t *testing.T, sp *SegmentedPartition, msgs []*Message) int64 {
+	t.Helper()
+
+	var end int64
+	for _, msg := range msgs {
+		offset, err := sp.WriteMessage(msg)
+		if err != nil {
+			t.Fatalf("WriteMessage() error = %v", err)
+		}
+		_, end, err = sp.ReadMessage(offset)
+		if err != nil {
+			t.Fatalf("ReadMessage() error = %v", err)
+		}
+	}
+	return end
+}
+
+func TestSegmentedPartitionRecoversFromCorruptLength(t *testing.T) {
+	for _, size := range []uint64{math.MaxInt64, math.MaxInt64 - 4, math.MaxUint64} {
+		dir := t.TempDir()
+		topic := &Topic{name: "corrupt"}
+
+		sp, err := NewSegmentedPartition(topic, 0, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs := testMessages(3)
+		validEnd := writeTestMessages(t, sp, msgs)
+		writeCorruptRecord(t, sp, size)
+
+		reopened, err := NewSegmentedPartition(topic, 0, dir)
+		if err != nil {
+			t.Fatalf("length %d: reopening error = %v", size, err)
+		}
+		if got := reopened.activeSegment.size; got != validEnd {
+			t.Errorf("length %d: segment is %d bytes after recovery, want %d", size, got, validEnd)
+		}
+
+		var offset int64
+		for i, want := range msgs {
+			got, next, err := reopened.ReadMessage(offset)
+			if err != nil {
+				t.Fatalf("length %d: reading message %d error = %v", size, i, err)
+			}
+			if !bytes.Equal(got.Value, want.Value) {
+				t.Errorf("length %d: message %d = %q, want %q", size, i, got.Value, want.Value)
+			}
+			offset = next
+		}
+	}
+}
+
+func TestSegmentedPartitionReadRejectsCorruptLength(t *testing.T) {
+	sp, err := NewSegmentedPartition(&Topic{name: "corrupt"}, 0, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestMessages(t, sp, testMessages(1))
+	offset := writeCorruptRecord(t, sp, math.MaxInt64)
+
+	if _, _, err := sp.ReadMessage(offset); err == nil {
+		t.Error("ReadMessage() of a record claiming math.MaxInt64 bytes succeeded")
+	}
+}
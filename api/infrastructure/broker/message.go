@@ -13,7 +13,9 @@ import (
 type Message struct {
 	Key       []byte
 	Value     []byte
+	Headers   map[string]string
 	Timestamp time.Time
+	DeliverAt time.Time // Holds the message back from consumers until then, zero delivers it right away
 }
 
 // MessageHeader contains metadata about a message
@@ -24,52 +26,23 @@ type MessageHeader struct {
 
 // writeMessage writes a message to the partition file
 func (p *Partition) writeMessage(msg *Message) (int64, error) {
+	msgBytes, err := serializeMessage(msg)
+	if err != nil {
+		return -1, err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	// Record the current offset before writing
 	currentOffset := p.offset
 
-	// Calculate header size and total size
-	header := MessageHeader{
-		KeySize:   uint32(len(msg.Key)),
-		Timestamp: msg.Timestamp.UnixNano(),
-	}
-
-	// Serialize header
-	headerBytes := make([]byte, 12) // 4 + 8 bytes
-	binary.BigEndian.PutUint32(headerBytes[0:4], header.KeySize)
-	binary.BigEndian.PutUint64(headerBytes[4:12], uint64(header.Timestamp))
-
-	// Calculate total message size
-	totalSize := uint64(len(headerBytes) + len(msg.Key) + len(msg.Value))
-
-	// Write length prefix
-	sizeBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(sizeBytes, totalSize)
-	if _, err := p.file.Write(sizeBytes); err != nil {
-		return -1, fmt.Errorf("failed to write message size: %w", err)
-	}
-
-	// Write header
-	if _, err := p.file.Write(headerBytes); err != nil {
-		return -1, fmt.Errorf("failed to write message header: %w", err)
-	}
-
-	// Write key (if present)
-	if len(msg.Key) > 0 {
-		if _, err := p.file.Write(msg.Key); err != nil {
-			return -1, fmt.Errorf("failed to write message key: %w", err)
-		}
-	}
-
-	// Write value
-	if _, err := p.file.Write(msg.Value); err != nil {
-		return -1, fmt.Errorf("failed to write message value: %w", err)
+	if _, err := p.file.Write(msgBytes); err != nil {
+		return -1, fmt.Errorf("failed to write message: %w", err)
 	}
 
 	// Update partition offset
-	p.offset += int64(8 + totalSize)
+	p.offset += int64(len(msgBytes))
 
 	// Schedule sync if needed
 	if time.Since(p.lastSync) >= p.syncEvery {
@@ -103,22 +76,34 @@ func (p *Partition) readMessage(offset int64) (*Message, int64, error) {
 		return nil, offset, fmt.Errorf("failed to read message: %w", err)
 	}
 
+	if len(msgBytes) < 12 {
+		return nil, offset, fmt.Errorf("message too short: %d bytes", len(msgBytes))
+	}
+
 	// Parse header
 	keySize := binary.BigEndian.Uint32(msgBytes[0:4])
 	timestamp := binary.BigEndian.Uint64(msgBytes[4:12])
 
+	keySize, headers, rest, err := splitHeaders(keySize, msgBytes[12:])
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to read headers: %w", err)
+	}
+	if uint64(keySize) > uint64(len(rest)) {
+		return nil, offset, fmt.Errorf("key of %d bytes overruns the message", keySize)
+	}
+
 	// Extract key and value
-	headerSize := 12
 	var key []byte
 	if keySize > 0 {
-		key = msgBytes[headerSize : headerSize+int(keySize)]
+		key = rest[:keySize]
 	}
-	value := msgBytes[headerSize+int(keySize):]
+	value := rest[keySize:]
 
 	// Create message
 	msg := &Message{
 		Key:       key,
 		Value:     value,
+		Headers:   headers,
 		Timestamp: time.Unix(0, int64(timestamp)),
 	}
 
@@ -174,8 +159,14 @@ func serializeMessageParts(msg *Message) ([]byte, []byte, error) {
 	keyLen := len(msg.Key)
 	valueLen := len(msg.Value)
 
-	// Header: [headerLength(4)][keySize(4)][timestamp(8)]
-	headerSize := 4 + 4 + 8
+	if err := validateHeaders(msg.Headers); err != nil {
+		return nil, nil, err
+	}
+
+	// Header: [headerLength(4)][keySize(4)][timestamp(8)][headers], the headers
+	// encoded by encodeHeaders
+	headers := encodeHeaders(msg.Headers)
+	headerSize := 4 + 4 + 8 + len(headers)
 	headerBytes := make([]byte, headerSize)
 	offset := 0
 
@@ -189,6 +180,9 @@ func serializeMessageParts(msg *Message) ([]byte, []byte, error) {
 
 	// Write timestamp
 	binary.BigEndian.PutUint64(headerBytes[offset:], uint64(msg.Timestamp.UnixNano()))
+	offset += 8
+
+	copy(headerBytes[offset:], headers)
 
 	// Payload: [key][value]
 	payloadBytes := make([]byte, keyLen+valueLen)
@@ -215,6 +209,15 @@ func deserializeMessage(headerBytes, payloadBytes []byte) (*Message, error) {
 	keySize := binary.BigEndian.Uint32(headerBytes[4:8])
 	timestamp := binary.BigEndian.Uint64(headerBytes[8:16])
 
+	var headers map[string]string
+	if len(headerBytes) > 16 {
+		decoded, err := decodeHeaders(headerBytes[16:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read headers: %w", err)
+		}
+		headers = decoded
+	}
+
 	// Validate payload size
 	if uint32(len(payloadBytes)) < keySize {
 		return nil, fmt.Errorf("payload too short for key: expected at least %d bytes, got %d", keySize, len(payloadBytes))
@@ -233,6 +236,7 @@ func deserializeMessage(headerBytes, payloadBytes []byte) (*Message, error) {
 	msg := &Message{
 		Key:       key,
 		Value:     value,
+		Headers:   headers,
 		Timestamp: time.Unix(0, int64(timestamp)),
 	}
 
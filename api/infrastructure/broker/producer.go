@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"math/rand/v2"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,7 +19,7 @@ const (
 	// AckLeader means the leader must acknowledge
 	AckLeader AckMode = 1
 
-	// AckAll means all replicas must acknowledge (not implemented in this version)
+	// AckAll means every in-sync replica must have the message
 	AckAll AckMode = -1
 )
 
@@ -39,7 +40,7 @@ type ProduceResult struct {
 // Producer publishes messages to topics
 type Producer struct {
 	broker      *Broker
-	acks        int // 0=no ack, 1=leader ack
+	acks        int // 0=no ack, 1=leader ack, -1=all in-sync replicas
 	partitioner PartitionStrategy
 }
 
@@ -60,12 +61,75 @@ func DefaultPartitioner(key []byte, numPartitions int) int {
 	return int(h.Sum32() % uint32(numPartitions))
 }
 
+// NewKeyPartitioner hashes keys with murmur2, like Kafka's default partitioner, so
+// messages sharing a key land on the same partition in order. Keyless messages are
+// spread round-robin.
+func NewKeyPartitioner() PartitionStrategy {
+	var next atomic.Uint32
+
+	return func(key []byte, numPartitions int) int {
+		if numPartitions <= 0 {
+			return 0
+		}
+		if len(key) == 0 {
+			return int((next.Add(1) - 1) % uint32(numPartitions))
+		}
+		return int(murmur2(key)&0x7fffffff) % numPartitions
+	}
+}
+
+// murmur2 is the 32 bit MurmurHash2 with Kafka's seed
+func murmur2(data []byte) uint32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	length := len(data)
+	h := seed ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+
+		h *= m
+		h ^= k
+	}
+
+	tail := length &^ 3
+	switch length % 4 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+
+	return h
+}
+
 // NewProducer creates a new producer
 func NewProducer(broker *Broker, acks int) *Producer {
+	return NewProducerWithPartitioner(broker, acks, NewKeyPartitioner())
+}
+
+// NewProducerWithPartitioner creates a producer choosing partitions with partitioner
+func NewProducerWithPartitioner(broker *Broker, acks int, partitioner PartitionStrategy) *Producer {
 	return &Producer{
 		broker:      broker,
 		acks:        acks,
-		partitioner: DefaultPartitioner,
+		partitioner: partitioner,
 	}
 }
 
@@ -85,23 +149,67 @@ func (p *Producer) Produce(tropicName string, msg *Message) (int, int64, error)
 	numPartitions := len(topic.partitions)
 	partitionID := p.partitioner(msg.Key, numPartitions)
 
+	offset, err := p.ProduceTo(tropicName, partitionID, msg)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	return partitionID, offset, nil
+}
+
+// ProduceTo sends a message to a given partition of the topic. On a replicated broker
+// it is forwarded to the partition's leader when this node doesn't lead it. A message
+// with a DeliverAt in the future is scheduled instead and gets offset -1.
+func (p *Producer) ProduceTo(topicName string, partitionID int, msg *Message) (int64, error) {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	topic, err := p.broker.GetTopic(topicName)
+	if err != nil {
+		return -1, fmt.Errorf("failed to get topic: %w", err)
+	}
+	if partitionID < 0 || partitionID >= len(topic.partitions) {
+		return -1, fmt.Errorf("topic %s has no partition %d", topicName, partitionID)
+	}
+
+	r := p.broker.getReplicator()
+	if r != nil && !r.isLeader(partitionID) {
+		return r.forwardProduce(topicName, partitionID, msg, AckMode(p.acks))
+	}
+
+	if msg.DeliverAt.After(time.Now()) {
+		if err := p.broker.scheduler.schedule(topicName, partitionID, msg); err != nil {
+			return -1, fmt.Errorf("failed to schedule message: %w", err)
+		}
+		return -1, nil
+	}
+
 	// Get partition
 	partition := topic.partitions[partitionID]
 
 	// Write message to partition
 	offset, err := partition.writeMessage(msg)
 	if err != nil {
-		return -1, -1, fmt.Errorf("failed to write message: %w", err)
+		return -1, fmt.Errorf("failed to write message: %w", err)
 	}
 
-	if p.acks > 0 {
+	if AckMode(p.acks) != AckNone {
 		partition.mu.Lock()
 		partition.file.Sync()
 		partition.lastSync = time.Now()
 		partition.mu.Unlock()
 	}
 
-	return partitionID, offset, nil
+	if AckMode(p.acks) == AckAll && r != nil {
+		// The follower holds the message once it fetches from past the message's end
+		end := offset + messageSize(msg)
+		if err := r.waitInSync(topicName, partitionID, end); err != nil {
+			return offset, err
+		}
+	}
+
+	return offset, nil
 }
 
 // ProduceAsync sends a message asynchronously
@@ -0,0 +1,141 @@
+package broker
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Frames on the wire are a 4 byte big endian length followed by that many bytes: a code,
+// the operation for requests and the status for responses, then a JSON body. A connection
+// carries one request at a time, each answered by exactly one response. A server started
+// with a token expects opAuth as the first frame and drops the connection otherwise.
+const maxFrameSize = 16 << 20
+
+const (
+	opProduce byte = iota + 1
+	opFetch
+	opCreateTopic
+	opJoinGroup
+	opHeartbeat
+	opLeaveGroup
+	opAuth
+)
+
+const (
+	statusOK byte = iota
+	statusError
+)
+
+type AuthRequest struct {
+	Token string `json:"token"`
+}
+
+// ProduceRequest leaves Partition out to let the broker partition by key
+type ProduceRequest struct {
+	Topic     string            `json:"topic"`
+	Partition *int              `json:"partition,omitempty"`
+	Key       []byte            `json:"key,omitempty"`
+	Value     []byte            `json:"value"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	DeliverAt *time.Time        `json:"deliver_at,omitempty"`
+	Acks      AckMode           `json:"acks"`
+}
+
+// deliverAt leaves the field out for messages delivered right away
+func deliverAt(msg *Message) *time.Time {
+	if msg.DeliverAt.IsZero() {
+		return nil
+	}
+	return &msg.DeliverAt
+}
+
+type ProduceResponse struct {
+	Partition int   `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+// FetchRequest is sent by consumers and, with FollowerID set, by the partition's followers.
+// Consumers only see what every in-sync replica holds.
+type FetchRequest struct {
+	Topic       string `json:"topic"`
+	Partition   int    `json:"partition"`
+	Offset      int64  `json:"offset"`
+	MaxMessages int    `json:"max_messages"`
+	FollowerID  int    `json:"follower_id,omitempty"`
+}
+
+// FetchResponse carries the offset to fetch from next, it equals the request's when nothing was read
+type FetchResponse struct {
+	Records    []*ConsumerRecord `json:"records"`
+	NextOffset int64             `json:"next_offset"`
+}
+
+// CreateTopicRequest is Forwarded when a node passes a topic created on it to the other nodes
+type CreateTopicRequest struct {
+	Name       string `json:"name"`
+	Partitions int    `json:"partitions"`
+	Forwarded  bool   `json:"forwarded,omitempty"`
+}
+
+// JoinGroupRequest picks the group's assignment strategy when it creates the group, range by default
+type JoinGroupRequest struct {
+	Group    string   `json:"group"`
+	Member   string   `json:"member"`
+	Topics   []string `json:"topics"`
+	Strategy string   `json:"strategy,omitempty"`
+}
+
+type GroupMemberRequest struct {
+	Group  string `json:"group"`
+	Member string `json:"member"`
+}
+
+// AssignmentResponse answers joins and heartbeats, a rebalance shows up in the next heartbeat
+type AssignmentResponse struct {
+	Partitions map[string][]int `json:"partitions"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeFrame(w io.Writer, code byte, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode frame: %w", err)
+	}
+	if len(payload)+1 > maxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the %d byte limit", len(payload)+1, maxFrameSize)
+	}
+
+	frame := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(1+len(payload)))
+	frame[4] = code
+	copy(frame[5:], payload)
+
+	_, err = w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(size[:])
+	if length == 0 || length > maxFrameSize {
+		return 0, nil, fmt.Errorf("invalid frame length %d", length)
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return 0, nil, err
+	}
+
+	return frame[0], frame[1:], nil
+}
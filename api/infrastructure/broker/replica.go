@@ -1,162 +1,341 @@
 package broker
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-// ReplicatedPartition represents a partition with replication
-type ReplicatedPartition struct {
-	partition *Partition
-	replicaID int
-	isLeader  bool
-	replicas  []*PartitionReplica
-	replicaCh chan *Message
-	done      chan struct{}
+var (
+	ErrNotEnoughReplicas  = errors.New("not enough in-sync replicas")
+	ErrReplicationTimeout = errors.New("timed out waiting for in-sync replicas")
+)
+
+const (
+	replicaFetchBatch   = 500
+	replicaIdleWait     = 200 * time.Millisecond
+	replicaRetryWait    = time.Second
+	defaultMaxLag       = 10 * time.Second
+	defaultAckTimeout   = 5 * time.Second
+	replicaCheckBackoff = 100 * time.Millisecond
+)
+
+// ReplicationConfig describes the nodes of a replicated broker. Each partition is kept on
+// ReplicationFactor nodes, picked from Nodes starting at the partition's index, the first
+// of them leads it. Every node runs a Server and lists the nodes in the same order.
+type ReplicationConfig struct {
+	NodeID            int      // This node's 1-based position in Nodes
+	Nodes             []string // Server address of every node
+	Token             string   // Presented to the other nodes' servers
+	ReplicationFactor int
+	MinInSyncReplicas int           // acks=all fails while fewer replicas, the leader included, are in sync
+	MaxReplicaLag     time.Duration // A follower that hasn't caught up for this long leaves the in-sync set
+	AckTimeout        time.Duration // How long acks=all waits for the in-sync followers
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
 }
 
-// PartitionReplica represents a remote partition replica
-type PartitionReplica struct {
-	id         int
-	client     *ReplicaClient
-	lastOffset int64
+// followerState is what the leader knows of a follower: everything below offset is on it
+type followerState struct {
+	offset     int64
+	caughtUpAt time.Time
 }
 
-// ReplicaClient handles communication with a replica
-type ReplicaClient struct {
-	address string
-	client  *http.Client
+// replicator runs on every node of a replicated broker. As leader it tracks how far each
+// follower has fetched, as follower it copies the leader's log byte for byte so offsets match.
+type replicator struct {
+	broker  *Broker
+	cfg     ReplicationConfig
+	clients map[int]*Client
+
+	mu        sync.Mutex
+	changed   *sync.Cond
+	followers map[partitionKey]map[int]*followerState
+	following map[partitionKey]bool
 }
 
-// NewReplicatedPartition creates a new replicated partition
-func NewReplicatedPartition(partition *Partition, replicaID int, isLeader bool, replicaAddresses []string) *ReplicatedPartition {
-	rp := &ReplicatedPartition{
-		partition: partition,
-		replicaID: replicaID,
-		isLeader:  isLeader,
-		replicaCh: make(chan *Message, 1000),
-		done:      make(chan struct{}),
+// EnableReplication turns the broker into a node of a replicated cluster. Produce requests
+// for partitions led by another node are forwarded to it, topics are created on every node.
+func (b *Broker) EnableReplication(cfg ReplicationConfig) error {
+	if cfg.NodeID < 1 || cfg.NodeID > len(cfg.Nodes) {
+		return fmt.Errorf("node ID %d is not a position in the %d nodes", cfg.NodeID, len(cfg.Nodes))
+	}
+	if cfg.ReplicationFactor < 1 {
+		cfg.ReplicationFactor = 1
+	}
+	if cfg.ReplicationFactor > len(cfg.Nodes) {
+		return fmt.Errorf("replication factor %d exceeds the %d nodes", cfg.ReplicationFactor, len(cfg.Nodes))
+	}
+	if cfg.MinInSyncReplicas < 1 {
+		cfg.MinInSyncReplicas = 1
+	}
+	if cfg.MaxReplicaLag <= 0 {
+		cfg.MaxReplicaLag = defaultMaxLag
+	}
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = defaultAckTimeout
+	}
+
+	r := &replicator{
+		broker:    b,
+		cfg:       cfg,
+		clients:   make(map[int]*Client),
+		followers: make(map[partitionKey]map[int]*followerState),
+		following: make(map[partitionKey]bool),
 	}
+	r.changed = sync.NewCond(&r.mu)
 
-	// Create replica clients
-	for id, address := range replicaAddresses {
-		// Skip self
-		if id == replicaID {
-			continue
+	for i, address := range cfg.Nodes {
+		if i+1 != cfg.NodeID {
+			r.clients[i+1] = newClient(address, cfg.Token)
 		}
+	}
 
-		client := &ReplicaClient{
-			address: address,
-			client:  &http.Client{Timeout: 5 * time.Second},
-		}
+	b.mu.Lock()
+	b.replicator = r
+	b.mu.Unlock()
 
-		replica := &PartitionReplica{
-			id:     id,
-			client: client,
+	for _, name := range b.ListTopics() {
+		topic, err := b.GetTopic(name)
+		if err != nil {
+			continue
 		}
-
-		rp.replicas = append(rp.replicas, replica)
+		r.follow(topic)
 	}
 
-	// Start replication if leader
-	if isLeader {
-		go rp.replicationLoop()
+	return nil
+}
+
+// replicas lists the node IDs holding a partition, leader first
+func (r *replicator) replicas(partition int) []int {
+	nodes := len(r.cfg.Nodes)
+	ids := make([]int, r.cfg.ReplicationFactor)
+	for i := range ids {
+		ids[i] = (partition+i)%nodes + 1
 	}
+	return ids
+}
 
-	return rp
+func (r *replicator) leader(partition int) int {
+	return r.replicas(partition)[0]
 }
 
-// Write writes a message to the partition and replicates if needed
-func (rp *ReplicatedPartition) Write(msg *Message) (int64, error) {
-	// Write to local partition
-	offset, err := rp.partition.writeMessage(msg)
-	if err != nil {
-		return -1, err
+func (r *replicator) isLeader(partition int) bool {
+	return r.leader(partition) == r.cfg.NodeID
+}
+
+// recordFetch notes that a follower asked for offset, so it holds everything before it
+func (r *replicator) recordFetch(topic string, partition, followerID int, offset, leaderEnd int64) {
+	key := partitionKey{topic: topic, partition: partition}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states, ok := r.followers[key]
+	if !ok {
+		states = make(map[int]*followerState)
+		r.followers[key] = states
+	}
+	state, ok := states[followerID]
+	if !ok {
+		state = &followerState{}
+		states[followerID] = state
+	}
+
+	state.offset = offset
+	if offset >= leaderEnd {
+		state.caughtUpAt = time.Now()
 	}
+	r.changed.Broadcast()
+}
 
-	// Replicate if leader
-	if rp.isLeader {
-		rp.replicaCh <- msg
+// inSync returns the followers of a partition that caught up recently enough, r.mu is held
+func (r *replicator) inSync(key partitionKey) []*followerState {
+	var states []*followerState
+	for _, state := range r.followers[key] {
+		if !state.caughtUpAt.IsZero() && time.Since(state.caughtUpAt) <= r.cfg.MaxReplicaLag {
+			states = append(states, state)
+		}
 	}
+	return states
+}
+
+// highWatermark is the end of what every in-sync replica holds, consumers don't read past it
+func (r *replicator) highWatermark(topic string, partition int, leaderEnd int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	return offset, nil
+	watermark := leaderEnd
+	for _, state := range r.inSync(partitionKey{topic: topic, partition: partition}) {
+		if state.offset < watermark {
+			watermark = state.offset
+		}
+	}
+	return watermark
 }
 
-// replicationLoop sends messages to replicas
-func (rp *ReplicatedPartition) replicationLoop() {
+// waitInSync blocks until every in-sync follower holds the log up to end
+func (r *replicator) waitInSync(topic string, partition int, end int64) error {
+	key := partitionKey{topic: topic, partition: partition}
+	deadline := time.Now().Add(r.cfg.AckTimeout)
+
+	// Followers fall out of the in-sync set without fetching, so wake up now and then to notice
+	ticker := time.NewTicker(replicaCheckBackoff)
+	defer ticker.Stop()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				r.mu.Lock()
+				r.changed.Broadcast()
+				r.mu.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	for {
-		select {
-		case msg := <-rp.replicaCh:
-			// Send to all replicas
-			for _, replica := range rp.replicas {
-				go rp.sendToReplica(replica, msg)
+		inSync := r.inSync(key)
+		if 1+len(inSync) < r.cfg.MinInSyncReplicas {
+			return fmt.Errorf("%w: %d of %d", ErrNotEnoughReplicas, 1+len(inSync), r.cfg.MinInSyncReplicas)
+		}
+
+		replicated := true
+		for _, state := range inSync {
+			if state.offset < end {
+				replicated = false
+				break
 			}
-		case <-rp.done:
-			return
 		}
+		if replicated {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return ErrReplicationTimeout
+		}
+		r.changed.Wait()
 	}
 }
 
-// sendToReplica sends a message to a specific replica
-func (rp *ReplicatedPartition) sendToReplica(replica *PartitionReplica, msg *Message) {
-	// Serialize message
-	msgBytes, err := serializeMessage(msg)
-	if err != nil {
-		log.Printf("Failed to serialize message for replica %d: %v", replica.id, err)
-		return
-	}
+// forwardProduce hands a message for a partition this node doesn't lead to its leader
+func (r *replicator) forwardProduce(topic string, partition int, msg *Message, acks AckMode) (int64, error) {
+	leader := r.leader(partition)
 
-	// Create request
-	url := fmt.Sprintf("http://%s/replicate", replica.client.address)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(msgBytes))
+	var res ProduceResponse
+	err := r.clients[leader].call(opProduce, ProduceRequest{
+		Topic:     topic,
+		Partition: &partition,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   msg.Headers,
+		Timestamp: msg.Timestamp,
+		DeliverAt: deliverAt(msg),
+		Acks:      acks,
+	}, &res)
 	if err != nil {
-		log.Printf("Failed to create request for replica %d: %v", replica.id, err)
-		return
+		return -1, fmt.Errorf("failed to forward to leader node %d: %w", leader, err)
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("X-Replica-ID", strconv.Itoa(rp.replicaID))
-	req.Header.Set("X-Topic", rp.partition.topic.name)
-	req.Header.Set("X-Partition", strconv.Itoa(rp.partition.id))
+	return res.Offset, nil
+}
 
-	// Send request
-	resp, err := replica.client.client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send message to replica %d: %v", replica.id, err)
-		return
+// forwardCreateTopic creates the topic on the other nodes, a node that is down picks
+// the topic up when it is created again or from its data directory
+func (r *replicator) forwardCreateTopic(name string, numPartitions int) {
+	for id, client := range r.clients {
+		err := client.call(opCreateTopic, CreateTopicRequest{Name: name, Partitions: numPartitions, Forwarded: true}, nil)
+		if err != nil && !strings.Contains(err.Error(), "already exists") {
+			log.Printf("Failed to create topic %s on node %d: %v", name, id, err)
+		}
 	}
-	defer resp.Body.Close()
+}
 
-	// Check response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Failed to replicate to %d: %s - %s", replica.id, resp.Status, body)
-		return
-	}
+// follow starts copying the partitions of topic this node follows
+func (r *replicator) follow(topic *Topic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	// Parse response for new offset
-	var result struct {
-		Offset int64 `json:"offset"`
-	}
+	for _, partition := range topic.partitions {
+		key := partitionKey{topic: topic.name, partition: partition.id}
+		if r.following[key] || r.isLeader(partition.id) {
+			continue
+		}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("Failed to parse replica %d response: %v", replica.id, err)
-		return
+		for _, id := range r.replicas(partition.id)[1:] {
+			if id == r.cfg.NodeID {
+				r.following[key] = true
+				go r.fetchLoop(partition)
+				break
+			}
+		}
 	}
-
-	// Update last offset
-	replica.lastOffset = result.Offset
 }
 
-// Close stops replication
-func (rp *ReplicatedPartition) Close() error {
-	close(rp.done)
-	return nil
+// fetchLoop pulls from the leader starting at the local log end. Fetching is also how the
+// leader learns this follower's position, so an idle follower keeps fetching.
+func (r *replicator) fetchLoop(partition *Partition) {
+	leader := r.leader(partition.id)
+	topic := partition.topic.name
+
+	// A connection of its own, forwarded acks=all produces hold the shared one until this fetches
+	client := newClient(r.cfg.Nodes[leader-1], r.cfg.Token)
+
+	for {
+		select {
+		case <-partition.closed:
+			return
+		default:
+		}
+
+		end := partition.endOffset()
+
+		var res FetchResponse
+		err := client.call(opFetch, FetchRequest{
+			Topic:       topic,
+			Partition:   partition.id,
+			Offset:      end,
+			MaxMessages: replicaFetchBatch,
+			FollowerID:  r.cfg.NodeID,
+		}, &res)
+		if err != nil {
+			log.Printf("Replica of %s/%d failed to fetch from node %d: %v", topic, partition.id, leader, err)
+			time.Sleep(replicaRetryWait)
+			continue
+		}
+
+		for _, record := range res.Records {
+			offset, err := partition.writeMessage(&Message{
+				Key:       record.Key,
+				Value:     record.Value,
+				Headers:   record.Headers,
+				Timestamp: record.Timestamp,
+			})
+			if err != nil {
+				log.Printf("Replica of %s/%d failed to write: %v", topic, partition.id, err)
+				break
+			}
+			if offset != record.Offset {
+				// Fetching on from a misaligned offset would read the leader's log mid-record
+				log.Printf("Replica of %s/%d diverged from the leader at offset %d (local %d), it stops replicating", topic, partition.id, record.Offset, offset)
+				return
+			}
+		}
+
+		if len(res.Records) == 0 {
+			time.Sleep(replicaIdleWait)
+		}
+	}
 }
@@ -0,0 +1,347 @@
+package broker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Messages with a DeliverAt in the future wait in a hashed timing wheel and are written to
+// their partition once due, so consumers never see them early. Until then they are kept in
+// delayed.log, which a restarted broker replays.
+const (
+	wheelTick       = 100 * time.Millisecond
+	wheelSlots      = 600 // One turn of the wheel is a minute
+	deliveryRetry   = time.Second
+	delayedLogName  = "delayed.log"
+	recordScheduled = 1
+	recordDelivered = 2
+)
+
+// Delayed log records
+// ┌────────┬──────┬───────────────────────────────────────────────────────────┐
+// │ Length │ Kind │ Scheduled: DeliverAt(8) Partition(4) TopicLen(2) Topic     │
+// │ (8B)   │ (1B) │            KeySize(4) Timestamp(8) [Headers] Key Value     │
+// │        │      │ Delivered: ID(8), the offset of the scheduled record       │
+// └────────┴──────┴───────────────────────────────────────────────────────────┘
+
+type delayedMessage struct {
+	id        int64
+	topic     string
+	partition int
+	msg       *Message
+	rounds    int // Turns of the wheel left before it is due
+}
+
+type scheduler struct {
+	broker  *Broker
+	file    *os.File
+	offset  int64
+	pending int
+	slots   [wheelSlots][]*delayedMessage
+	current int
+	done    chan struct{}
+	mu      sync.Mutex
+}
+
+func newScheduler(broker *Broker, path string) (*scheduler, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open delayed log: %w", err)
+	}
+
+	s := &scheduler{broker: broker, file: file, done: make(chan struct{})}
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+// load puts the messages not delivered before the last shutdown back on the wheel
+func (s *scheduler) load() error {
+	reader := bufio.NewReader(s.file)
+	pending := make(map[int64]*delayedMessage)
+	var order []int64
+
+	var offset int64
+	for {
+		sizeBytes := make([]byte, 8)
+		if _, err := io.ReadFull(reader, sizeBytes); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			// A torn write at the end, what follows it was never acknowledged
+			log.Printf("Delayed log is truncated at offset %d: %v", offset, err)
+			break
+		}
+		size := binary.BigEndian.Uint64(sizeBytes)
+
+		record := make([]byte, size)
+		if _, err := io.ReadFull(reader, record); err != nil {
+			log.Printf("Delayed log is truncated at offset %d: %v", offset, err)
+			break
+		}
+
+		switch record[0] {
+		case recordScheduled:
+			delayed, err := decodeDelayed(record[1:])
+			if err != nil {
+				return fmt.Errorf("failed to read delayed message at offset %d: %w", offset, err)
+			}
+			delayed.id = offset
+			pending[offset] = delayed
+			order = append(order, offset)
+		case recordDelivered:
+			delete(pending, int64(binary.BigEndian.Uint64(record[1:9])))
+		}
+
+		offset += 8 + int64(size)
+	}
+
+	if len(pending) == 0 {
+		return s.reset()
+	}
+
+	s.offset = offset
+	for _, id := range order {
+		if delayed, ok := pending[id]; ok {
+			s.add(delayed)
+			s.pending++
+		}
+	}
+	return nil
+}
+
+// schedule keeps msg until its DeliverAt and then writes it to the partition
+func (s *scheduler) schedule(topic string, partition int, msg *Message) error {
+	if len(topic) > 0xFFFF {
+		return fmt.Errorf("topic name of %d bytes is too long", len(topic))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.offset
+	if err := s.appendRecord(encodeDelayed(topic, partition, msg)); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync delayed log: %w", err)
+	}
+
+	s.add(&delayedMessage{id: id, topic: topic, partition: partition, msg: msg})
+	s.pending++
+	return nil
+}
+
+// add places a message in the slot it is due in, s.mu is held
+func (s *scheduler) add(delayed *delayedMessage) {
+	ticks := int((time.Until(delayed.msg.DeliverAt) + wheelTick - 1) / wheelTick)
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	delayed.rounds = (ticks - 1) / wheelSlots
+	slot := (s.current + ticks) % wheelSlots
+	s.slots[slot] = append(s.slots[slot], delayed)
+}
+
+func (s *scheduler) run() {
+	ticker := time.NewTicker(wheelTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+
+		s.mu.Lock()
+		s.current = (s.current + 1) % wheelSlots
+		entries := s.slots[s.current]
+		s.slots[s.current] = nil
+
+		var due []*delayedMessage
+		for _, delayed := range entries {
+			if delayed.rounds > 0 {
+				delayed.rounds--
+				s.slots[s.current] = append(s.slots[s.current], delayed)
+				continue
+			}
+			due = append(due, delayed)
+		}
+		s.mu.Unlock()
+
+		for _, delayed := range due {
+			s.deliver(delayed)
+		}
+	}
+}
+
+func (s *scheduler) deliver(delayed *delayedMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The ticker may run slightly ahead of the clock
+	if time.Now().Before(delayed.msg.DeliverAt) {
+		s.add(delayed)
+		return
+	}
+
+	msg := &Message{Key: delayed.msg.Key, Value: delayed.msg.Value, Headers: delayed.msg.Headers, Timestamp: delayed.msg.Timestamp}
+	if _, err := NewProducer(s.broker, int(AckLeader)).ProduceTo(delayed.topic, delayed.partition, msg); err != nil {
+		if _, topicErr := s.broker.GetTopic(delayed.topic); topicErr == nil {
+			log.Printf("Failed to deliver delayed message to %s/%d, retrying: %v", delayed.topic, delayed.partition, err)
+			delayed.msg.DeliverAt = time.Now().Add(deliveryRetry)
+			s.add(delayed)
+			return
+		}
+		log.Printf("Dropped delayed message for %s, the topic no longer exists", delayed.topic)
+	}
+
+	record := make([]byte, 9)
+	record[0] = recordDelivered
+	binary.BigEndian.PutUint64(record[1:], uint64(delayed.id))
+	if err := s.appendRecord(record); err != nil {
+		// It gets delivered again after a restart
+		log.Printf("Failed to record delivery of delayed message %d: %v", delayed.id, err)
+	}
+
+	s.pending--
+	if s.pending == 0 {
+		if err := s.reset(); err != nil {
+			log.Printf("Failed to truncate delayed log: %v", err)
+		}
+	}
+}
+
+// close stops the wheel and closes the delayed log, pending messages are delivered after a restart
+func (s *scheduler) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	close(s.done)
+
+	s.file.Sync()
+	return s.file.Close()
+}
+
+// appendRecord writes a length prefixed record, s.mu is held
+func (s *scheduler) appendRecord(record []byte) error {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(record)))
+	buf.Write(size[:])
+	buf.Write(record)
+
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write delayed log: %w", err)
+	}
+	s.offset += int64(buf.Len())
+	return nil
+}
+
+// reset empties the log once nothing is pending
+func (s *scheduler) reset() error {
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	s.offset = 0
+	return nil
+}
+
+// encodeDelayed flags headers in the key size like serializeMessage
+func encodeDelayed(topic string, partition int, msg *Message) []byte {
+	headers := encodeHeaders(msg.Headers)
+	headersSize := 0
+	if headers != nil {
+		headersSize = 4 + len(headers)
+	}
+
+	record := make([]byte, 1+8+4+2+len(topic)+4+8+headersSize+len(msg.Key)+len(msg.Value))
+	record[0] = recordScheduled
+	pos := 1
+
+	binary.BigEndian.PutUint64(record[pos:], uint64(msg.DeliverAt.UnixNano()))
+	pos += 8
+	binary.BigEndian.PutUint32(record[pos:], uint32(partition))
+	pos += 4
+	binary.BigEndian.PutUint16(record[pos:], uint16(len(topic)))
+	pos += 2
+	pos += copy(record[pos:], topic)
+	keySize := uint32(len(msg.Key))
+	if headers != nil {
+		keySize |= headersFlag
+	}
+	binary.BigEndian.PutUint32(record[pos:], keySize)
+	pos += 4
+	binary.BigEndian.PutUint64(record[pos:], uint64(msg.Timestamp.UnixNano()))
+	pos += 8
+	if headers != nil {
+		binary.BigEndian.PutUint32(record[pos:], uint32(len(headers)))
+		pos += 4
+		pos += copy(record[pos:], headers)
+	}
+	pos += copy(record[pos:], msg.Key)
+	copy(record[pos:], msg.Value)
+
+	return record
+}
+
+func decodeDelayed(data []byte) (*delayedMessage, error) {
+	if len(data) < 14 {
+		return nil, fmt.Errorf("record too short: %d bytes", len(data))
+	}
+
+	deliverAt := int64(binary.BigEndian.Uint64(data[0:8]))
+	partition := int(binary.BigEndian.Uint32(data[8:12]))
+	topicLen := int(binary.BigEndian.Uint16(data[12:14]))
+	data = data[14:]
+	if len(data) < topicLen+12 {
+		return nil, fmt.Errorf("record too short for its topic")
+	}
+	topic := string(data[:topicLen])
+	data = data[topicLen:]
+
+	flaggedKeySize := binary.BigEndian.Uint32(data[0:4])
+	timestamp := int64(binary.BigEndian.Uint64(data[4:12]))
+
+	rawKeySize, headers, data, err := splitHeaders(flaggedKeySize, data[12:])
+	if err != nil {
+		return nil, err
+	}
+	keySize := int(rawKeySize)
+	if len(data) < keySize {
+		return nil, fmt.Errorf("record too short for its key")
+	}
+
+	msg := &Message{
+		Value:     data[keySize:],
+		Headers:   headers,
+		Timestamp: time.Unix(0, timestamp),
+		DeliverAt: time.Unix(0, deliverAt),
+	}
+	if keySize > 0 {
+		msg.Key = data[:keySize]
+	}
+
+	return &delayedMessage{topic: topic, partition: partition, msg: msg}, nil
+}
@@ -0,0 +1,322 @@
+package broker
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	heartbeatCheckInterval = 10 * time.Second
+	serverIdleTimeout      = 2 * time.Minute
+	maxFetchMessages       = 500
+)
+
+// ErrUnauthenticatedListen is returned for a non-loopback address on a server without a token
+var ErrUnauthenticatedListen = errors.New("broker server needs a token to listen beyond loopback")
+
+// Server exposes a broker over TCP so other processes can produce, fetch and join
+// consumer groups, see protocol.go for the framing
+type Server struct {
+	broker      *Broker
+	token       string            // Required from every connection when set
+	partitioner PartitionStrategy // Shared so keyless messages keep rotating across requests
+	groups      map[string]*ConsumerGroup
+	mu          sync.Mutex
+
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer with an empty token accepts any connection, ListenAndServe then only binds loopback
+func NewServer(broker *Broker, token string) *Server {
+	return &Server{
+		broker:      broker,
+		token:       token,
+		partitioner: NewKeyPartitioner(),
+		groups:      make(map[string]*ConsumerGroup),
+		conns:       make(map[net.Conn]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// ListenAndServe blocks until Close is called or the listener fails
+func (s *Server) ListenAndServe(address string) error {
+	if s.token == "" && !IsLoopback(address) {
+		return fmt.Errorf("%w: %s", ErrUnauthenticatedListen, address)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	go s.heartbeatLoop()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting connections and closes the open ones
+func (s *Server) Close() error {
+	close(s.done)
+
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	if !s.authenticate(conn) {
+		return
+	}
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(serverIdleTimeout))
+		op, body, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+
+		response, err := s.handle(op, body)
+		if err != nil {
+			err = writeFrame(conn, statusError, errorResponse{Error: err.Error()})
+		} else {
+			err = writeFrame(conn, statusOK, response)
+		}
+		if err != nil {
+			log.Printf("Broker server failed to reply to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// authenticate reads the opAuth frame a server with a token expects first
+func (s *Server) authenticate(conn net.Conn) bool {
+	if s.token == "" {
+		return true
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(serverIdleTimeout))
+	op, body, err := readFrame(conn)
+	if err != nil {
+		return false
+	}
+
+	var req AuthRequest
+	if op != opAuth || json.Unmarshal(body, &req) != nil ||
+		subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.token)) != 1 {
+		log.Printf("Broker server rejected an unauthenticated connection from %s", conn.RemoteAddr())
+		_ = writeFrame(conn, statusError, errorResponse{Error: "authentication failed"})
+		return false
+	}
+
+	return writeFrame(conn, statusOK, struct{}{}) == nil
+}
+
+// IsLoopback reports whether address only accepts connections from this host, an empty
+// host listens on every interface
+func IsLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) handle(op byte, body []byte) (any, error) {
+	switch op {
+	case opProduce:
+		var req ProduceRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		msg := &Message{
+			Key:       req.Key,
+			Value:     req.Value,
+			Headers:   req.Headers,
+			Timestamp: req.Timestamp,
+		}
+		if req.DeliverAt != nil {
+			msg.DeliverAt = *req.DeliverAt
+		}
+		producer := NewProducerWithPartitioner(s.broker, int(req.Acks), s.partitioner)
+		if req.Partition != nil {
+			offset, err := producer.ProduceTo(req.Topic, *req.Partition, msg)
+			if err != nil {
+				return nil, err
+			}
+			return ProduceResponse{Partition: *req.Partition, Offset: offset}, nil
+		}
+		partition, offset, err := producer.Produce(req.Topic, msg)
+		if err != nil {
+			return nil, err
+		}
+		return ProduceResponse{Partition: partition, Offset: offset}, nil
+
+	case opFetch:
+		var req FetchRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		if req.MaxMessages <= 0 || req.MaxMessages > maxFetchMessages {
+			req.MaxMessages = maxFetchMessages
+		}
+		records, next, err := s.broker.fetch(req.Topic, req.Partition, req.Offset, req.MaxMessages, req.FollowerID)
+		if err != nil && len(records) == 0 {
+			return nil, err
+		}
+		return FetchResponse{Records: records, NextOffset: next}, nil
+
+	case opCreateTopic:
+		var req CreateTopicRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		return struct{}{}, s.broker.createTopic(req.Name, req.Partitions, !req.Forwarded)
+
+	case opJoinGroup:
+		var req JoinGroupRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		group, err := s.groupWithStrategy(req.Group, req.Strategy)
+		if err != nil {
+			return nil, err
+		}
+		partitions, err := group.Join(req.Member, req.Topics)
+		if err != nil {
+			return nil, err
+		}
+		return AssignmentResponse{Partitions: partitions}, nil
+
+	case opHeartbeat:
+		var req GroupMemberRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		group := s.group(req.Group)
+		if err := group.Heartbeat(req.Member); err != nil {
+			return nil, err
+		}
+		partitions, err := group.Assignment(req.Member)
+		if err != nil {
+			return nil, err
+		}
+		return AssignmentResponse{Partitions: partitions}, nil
+
+	case opLeaveGroup:
+		var req GroupMemberRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		return struct{}{}, s.group(req.Group).Leave(req.Member)
+
+	default:
+		return nil, errors.New("unknown operation")
+	}
+}
+
+func (s *Server) group(groupID string) *ConsumerGroup {
+	group, _ := s.groupWithStrategy(groupID, "")
+	return group
+}
+
+// groupWithStrategy creates the group with strategy, or checks an existing group uses it
+func (s *Server) groupWithStrategy(groupID, strategy string) (*ConsumerGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, exists := s.groups[groupID]
+	if !exists {
+		if strategy == "" {
+			strategy = StrategyRange
+		}
+		created, err := NewConsumerGroupWithStrategy(s.broker, groupID, strategy)
+		if err != nil {
+			return nil, err
+		}
+		s.groups[groupID] = created
+		return created, nil
+	}
+
+	if strategy != "" && strategy != group.Strategy() {
+		return nil, fmt.Errorf("group %s uses the %s strategy", groupID, group.Strategy())
+	}
+	return group, nil
+}
+
+// heartbeatLoop drops members of every group that stopped sending heartbeats
+func (s *Server) heartbeatLoop() {
+	ticker := time.NewTicker(heartbeatCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			groups := make([]*ConsumerGroup, 0, len(s.groups))
+			for _, group := range s.groups {
+				groups = append(groups, group)
+			}
+			s.mu.Unlock()
+
+			for _, group := range groups {
+				group.CheckHeartbeats()
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package broker
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// startTestServer serves a fresh broker on a loopback port and returns its address
+func startTestServer(t *testing.T, token string) string {
+	t.Helper()
+
+	b, err := NewBroker(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(b, token)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return listener.Addr().String()
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	address := startTestServer(t, "secret")
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"matching token", "secret", true},
+		{"wrong token", "guess", false},
+		{"no token", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient(address, tt.token)
+			t.Cleanup(func() { _ = client.Close() })
+
+			err := client.CreateTopic("orders", 1)
+			if tt.ok && err != nil {
+				t.Fatalf("CreateTopic() error = %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("CreateTopic() succeeded without the server's token")
+			}
+		})
+	}
+}
+
+func TestDialRejectsWrongToken(t *testing.T) {
+	address := startTestServer(t, "secret")
+
+	if _, err := Dial(address, "guess"); err == nil {
+		t.Fatal("Dial() with the wrong token succeeded")
+	}
+	client, err := Dial(address, "secret")
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	_ = client.Close()
+}
+
+func TestListenAndServeRefusesPublicAddressWithoutToken(t *testing.T) {
+	b, err := NewBroker(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+
+	for _, address := range []string{":0", "0.0.0.0:0", "[::]:0"} {
+		err := NewServer(b, "").ListenAndServe(address)
+		if !errors.Is(err, ErrUnauthenticatedListen) {
+			t.Errorf("ListenAndServe(%q) error = %v, want %v", address, err, ErrUnauthenticatedListen)
+		}
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:9092": true,
+		"[::1]:9092":     true,
+		"localhost:9092": true,
+		":9092":          false,
+		"0.0.0.0:9092":   false,
+		"10.0.0.4:9092":  false,
+		"api-1:9092":     false,
+		"localhost":      false,
+	}
+
+	for address, want := range tests {
+		if got := IsLoopback(address); got != want {
+			t.Errorf("IsLoopback(%q) = %v, want %v", address, got, want)
+		}
+	}
+}
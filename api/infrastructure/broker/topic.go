@@ -2,6 +2,7 @@ package broker
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"hash/crc32"
@@ -36,6 +37,7 @@ type Partition struct {
 	offset    int64
 	lastSync  time.Time
 	syncEvery time.Duration
+	closed    chan struct{}
 }
 
 // CreateTopic creates a new topic with the specified number of partitions
@@ -94,6 +96,7 @@ func createPartition(topic *Topic, id int, topicDir string) (*Partition, error)
 		file:      file,
 		offset:    info.Size(),
 		syncEvery: 50 * time.Millisecond,
+		closed:    make(chan struct{}),
 	}
 
 	// Start background sync goroutine
@@ -102,12 +105,25 @@ func createPartition(topic *Topic, id int, topicDir string) (*Partition, error)
 	return partition, nil
 }
 
+// endOffset is where the next message will be written
+func (p *Partition) endOffset() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.offset
+}
+
 // syncLoop periodically syncs partition data to disk
 func (p *Partition) syncLoop() {
 	ticker := time.NewTicker(p.syncEvery)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.closed:
+			return
+		}
+
 		p.mu.Lock()
 
 		if time.Since(p.lastSync) >= p.syncEvery {
@@ -119,6 +135,22 @@ func (p *Partition) syncLoop() {
 	}
 }
 
+// close syncs and closes the partition file, its background work stops
+func (p *Partition) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.closed:
+		return nil
+	default:
+	}
+	close(p.closed)
+
+	p.file.Sync()
+	return p.file.Close()
+}
+
 // RecoverPartition recovers a partition after a crash
 func RecoverPartition(topicDir string, partitionID int) (*Partition, error) {
 	filePath := filepath.Join(topicDir, fmt.Sprintf("partition-%d.log", partitionID))
@@ -185,6 +217,7 @@ func RecoverPartition(topicDir string, partitionID int) (*Partition, error) {
 		file:      file,
 		offset:    offset,
 		syncEvery: 50 * time.Millisecond,
+		closed:    make(chan struct{}),
 	}
 
 	// Start background sync goroutine
@@ -201,57 +234,53 @@ func RecoverPartition(topicDir string, partitionID int) (*Partition, error) {
 
 // writeMessageWithIntegrity writes a message with checksum
 func (p *Partition) WriteMessageWithIntegrity(msg *Message) (int64, error) {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	if err := appendIntegrityRecord(buf, msg); err != nil {
+		return -1, err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Record current offset
-	currentOffset := p.offset
+	return p.appendLocked(buf.Bytes())
+}
 
+// appendIntegrityRecord serializes a message with its checksums onto buf
+func appendIntegrityRecord(buf *bytes.Buffer, msg *Message) error {
 	// Serialize message header and payload
 	headerBytes, payloadBytes, err := serializeMessageParts(msg)
 	if err != nil {
-		return -1, err
+		return err
 	}
 
-	// Calculate checksums
-	headerChecksum := crc32.ChecksumIEEE(headerBytes)
-	payloadChecksum := crc32.ChecksumIEEE(payloadBytes)
-
 	// Calculate total message size
 	totalSize := uint64(len(headerBytes) + 4 + len(payloadBytes) + 4) // header + header checksum + payload + payload checksum
 
-	// Write length prefix
-	sizeBytes := make([]byte, 8)
-	binary.BigEndian.PutUint64(sizeBytes, totalSize)
-	if _, err := p.file.Write(sizeBytes); err != nil {
-		return -1, fmt.Errorf("failed to write message size: %w", err)
-	}
+	var scratch [8]byte
+	binary.BigEndian.PutUint64(scratch[:], totalSize)
+	buf.Write(scratch[:])
 
-	// Write header
-	if _, err := p.file.Write(headerBytes); err != nil {
-		return -1, fmt.Errorf("failed to write message header: %w", err)
-	}
+	buf.Write(headerBytes)
+	binary.BigEndian.PutUint32(scratch[:4], crc32.ChecksumIEEE(headerBytes))
+	buf.Write(scratch[:4])
 
-	// Write header checksum
-	checksumBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(checksumBytes, headerChecksum)
-	if _, err := p.file.Write(checksumBytes); err != nil {
-		return -1, fmt.Errorf("failed to write header checksum: %w", err)
-	}
+	buf.Write(payloadBytes)
+	binary.BigEndian.PutUint32(scratch[:4], crc32.ChecksumIEEE(payloadBytes))
+	buf.Write(scratch[:4])
 
-	// Write payload
-	if _, err := p.file.Write(payloadBytes); err != nil {
-		return -1, fmt.Errorf("failed to write message payload: %w", err)
-	}
+	return nil
+}
 
-	// Write payload checksum
-	binary.BigEndian.PutUint32(checksumBytes, payloadChecksum)
-	if _, err := p.file.Write(checksumBytes); err != nil {
-		return -1, fmt.Errorf("failed to write payload checksum: %w", err)
-	}
+// appendLocked writes data in one call and returns the offset it starts at, p.mu is held
+func (p *Partition) appendLocked(data []byte) (int64, error) {
+	currentOffset := p.offset
 
-	// Update partition offset
-	p.offset += int64(8 + totalSize)
+	if _, err := p.file.Write(data); err != nil {
+		return -1, fmt.Errorf("failed to write to partition: %w", err)
+	}
+	p.offset += int64(len(data))
 
 	// Schedule sync if needed
 	if time.Since(p.lastSync) >= p.syncEvery {
@@ -285,8 +314,29 @@ func (p *Partition) ReadMessageWithIntegrity(offset int64) (*Message, int64, err
 		return nil, offset, fmt.Errorf("failed to read message: %w", err)
 	}
 
+	msg, err := parseIntegrityRecord(msgBytes)
+	if err != nil {
+		return nil, offset, err
+	}
+
+	// Calculate next offset
+	nextOffset := offset + int64(8) + int64(totalSize)
+
+	return msg, nextOffset, nil
+}
+
+// parseIntegrityRecord verifies and decodes a record written by appendIntegrityRecord,
+// without its length prefix
+func parseIntegrityRecord(msgBytes []byte) (*Message, error) {
+	if len(msgBytes) < 8 {
+		return nil, fmt.Errorf("record too short: %d bytes", len(msgBytes))
+	}
+
 	// Extract header length (first 4 bytes of message)
 	headerLength := binary.BigEndian.Uint32(msgBytes[0:4])
+	if uint64(headerLength)+8 > uint64(len(msgBytes)) {
+		return nil, fmt.Errorf("header length %d exceeds the %d byte record", headerLength, len(msgBytes))
+	}
 
 	// Extract parts
 	headerBytes := msgBytes[0:headerLength]
@@ -297,22 +347,19 @@ func (p *Partition) ReadMessageWithIntegrity(offset int64) (*Message, int64, err
 	// Verify checksums
 	headerChecksum := binary.BigEndian.Uint32(headerChecksumBytes)
 	if calculatedHeaderChecksum := crc32.ChecksumIEEE(headerBytes); calculatedHeaderChecksum != headerChecksum {
-		return nil, offset, fmt.Errorf("header checksum mismatch")
+		return nil, fmt.Errorf("header checksum mismatch")
 	}
 
 	payloadChecksum := binary.BigEndian.Uint32(payloadChecksumBytes)
 	if calculatedPayloadChecksum := crc32.ChecksumIEEE(payloadBytes); calculatedPayloadChecksum != payloadChecksum {
-		return nil, offset, fmt.Errorf("payload checksum mismatch")
+		return nil, fmt.Errorf("payload checksum mismatch")
 	}
 
 	// Parse message
 	msg, err := deserializeMessage(headerBytes, payloadBytes)
 	if err != nil {
-		return nil, offset, fmt.Errorf("failed to deserialize message: %w", err)
+		return nil, fmt.Errorf("failed to deserialize message: %w", err)
 	}
 
-	// Calculate next offset
-	nextOffset := offset + int64(8) + int64(totalSize)
-
-	return msg, nextOffset, nil
+	return msg, nil
 }
@@ -5,7 +5,10 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 type EvictionPolicy int
@@ -19,13 +22,14 @@ const (
 	FIFO
 )
 
-// Item represents a cache item with value and expiration time
+// Item represents a cache item with value and expiration time, it is what SaveToFile writes
 type Item struct {
 	Value       any
 	Expiration  int64
 	Created     time.Time
 	LastAccess  time.Time
 	AccessCount int
+	Cost        int64
 }
 
 // IsExpired returns true if the item has expired
@@ -36,23 +40,98 @@ func (item Item) IsExpired() bool {
 	return time.Now().UnixNano() > item.Expiration
 }
 
-// Cache represents an in-memory cache
+// entry is an item as the cache holds it. Only the access bookkeeping changes once it is
+// stored, and that is atomic so Get gets by with a shard's read lock.
+type entry struct {
+	value      any
+	expiration int64
+	// freshUntil is set when GetOrLoad may serve the value stale until expiration
+	freshUntil  int64
+	created     int64
+	cost        int64
+	lastAccess  atomic.Int64
+	accessCount atomic.Int64
+}
+
+func newEntry(value any, cost, expiration, created, lastAccess, accessCount int64) *entry {
+	e := &entry{value: value, cost: cost, expiration: expiration, created: created}
+	e.lastAccess.Store(lastAccess)
+	e.accessCount.Store(accessCount)
+	return e
+}
+
+func (e *entry) expired(now int64) bool {
+	return e.expiration > 0 && now > e.expiration
+}
+
+func (e *entry) stale(now int64) bool {
+	return e.freshUntil > 0 && now > e.freshUntil
+}
+
+// rank orders entries for eviction, the lowest goes first
+func (e *entry) rank(policy EvictionPolicy) int64 {
+	switch policy {
+	case LFU:
+		return e.accessCount.Load()
+	case FIFO:
+		return e.created
+	default:
+		return e.lastAccess.Load()
+	}
+}
+
+func (e *entry) item() Item {
+	return Item{
+		Value:       e.value,
+		Expiration:  e.expiration,
+		Created:     time.Unix(0, e.created),
+		LastAccess:  time.Unix(0, e.lastAccess.Load()),
+		AccessCount: int(e.accessCount.Load()),
+		Cost:        e.cost,
+	}
+}
+
+type shard struct {
+	mu    sync.RWMutex
+	items map[string]*entry
+}
+
+// Cache represents an in-memory cache. Keys are spread over shards by hash, each with its
+// own lock, so callers working on different keys rarely wait on each other.
 type Cache struct {
-	items           map[string]Item
-	mu              sync.RWMutex
+	shards          []*shard
+	count           atomic.Int64
+	bytes           atomic.Int64
 	cleanupInterval time.Duration
 	maxItems        int
+	maxBytes        int64
 	evictionPolicy  EvictionPolicy
 	stopCleanup     chan bool
+	closeOnce       sync.Once
 	onEvicted       func(string, any)
-	stats           Stats
+	onEviction      func(string, any, EvictionReason)
+	evictionQueue   chan eviction
+	loadJitter      float64
+	staleFor        time.Duration
+	loads           singleflight.Group
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	expired   atomic.Int64
+	removed   atomic.Int64
+	sets      atomic.Int64
 }
 
 type Stats struct {
 	Hits       int64
 	Misses     int64
-	Evictions  int64
+	Evictions  int64 // Made room for another item
+	Expired    int64
+	Removed    int64 // Through Delete
 	TotalItems int64
+	// Bytes is the summed cost of the items held
+	Bytes int64
 }
 
 // Options configures the cache
@@ -60,7 +139,21 @@ type Options struct {
 	CleanupInterval time.Duration
 	MaxItems        int
 	EvictionPolicy  EvictionPolicy
-	OnEvicted       func(string, any)
+	// OnEvicted and OnEviction are told about every item that expires, is evicted or is
+	// deleted, not ones replaced or flushed. They run outside the cache's locks, in order
+	// unless they fall behind, so a slow one doesn't hold anything up.
+	OnEvicted  func(string, any)
+	OnEviction func(string, any, EvictionReason)
+	// MaxBytes bounds the summed cost of the items, see SetWithCost for what an item costs
+	MaxBytes int64
+	// Shards defaults to DefaultShards, one makes the cache a single locked map
+	Shards int
+	// LoadJitter shortens each GetOrLoad TTL by a random share of up to this fraction, so
+	// keys loaded together don't all expire together
+	LoadJitter float64
+	// StaleWhileRevalidate lets GetOrLoad serve a value for this long past its TTL while it
+	// is reloaded in the background. Get treats such a value as expired.
+	StaleWhileRevalidate time.Duration
 }
 
 // DefaultOptions returns the default cache options
@@ -70,26 +163,54 @@ func DefaultOptions() Options {
 		MaxItems:        0, // No limit
 		EvictionPolicy:  LRU,
 		OnEvicted:       nil,
+		Shards:          DefaultShards,
 	}
 }
 
 // NewCache creates a new cache with the given options
 func NewCache(options Options) *Cache {
+	shardCount := options.Shards
+	if shardCount <= 0 {
+		shardCount = DefaultShards
+	}
+
 	cache := &Cache{
-		items:           make(map[string]Item),
+		shards:          make([]*shard, shardCount),
 		cleanupInterval: options.CleanupInterval,
 		maxItems:        options.MaxItems,
+		maxBytes:        options.MaxBytes,
 		evictionPolicy:  options.EvictionPolicy,
 		stopCleanup:     make(chan bool),
 		onEvicted:       options.OnEvicted,
+		onEviction:      options.OnEviction,
+		loadJitter:      options.LoadJitter,
+		staleFor:        options.StaleWhileRevalidate,
+	}
+	for i := range cache.shards {
+		cache.shards[i] = &shard{items: make(map[string]*entry)}
 	}
 
 	// Start the cleanup goroutine
 	go cache.startCleanupTimer()
 
+	if cache.onEvicted != nil || cache.onEviction != nil {
+		cache.evictionQueue = make(chan eviction, evictionQueueSize)
+		go cache.runEvictionCallbacks()
+	}
+
 	return cache
 }
 
+// shardFor hashes the key with FNV-1a, inlined so a lookup doesn't allocate a hasher
+func (c *Cache) shardFor(key string) *shard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return c.shards[hash%uint32(len(c.shards))]
+}
+
 // startCleanupTimer starts the timer for cleanup
 func (c *Cache) startCleanupTimer() {
 	ticker := time.NewTicker(c.cleanupInterval)
@@ -105,221 +226,289 @@ func (c *Cache) startCleanupTimer() {
 	}
 }
 
-// cleanup removes expired items from the cache
+// cleanup removes expired items from the cache, a shard at a time
 func (c *Cache) cleanup() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	now := time.Now().UnixNano()
-	for key, item := range c.items {
-		if item.Expiration > 0 && now > item.Expiration {
-			delete(c.items, key)
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for key, e := range s.items {
+			if e.expired(now) {
+				c.deleteItem(s, key, EvictedExpired)
+			}
 		}
+		s.mu.Unlock()
 	}
 }
 
-// evict removes items according to the eviction policy
-func (c *Cache) evict() {
-	if c.maxItems <= 0 || len(c.items) < c.maxItems {
-		return
-	}
+// makeRoom evicts items according to the eviction policy until one more item, if newItem,
+// and bytes more cost fit. The victims are picked across every shard, so the policy holds
+// for the cache as a whole, and keep is never picked since it is about to be replaced.
+func (c *Cache) makeRoom(keep string, newItem bool, bytes int64) {
+	for {
+		itemsFull := newItem && c.maxItems > 0 && c.count.Load() >= int64(c.maxItems)
+		bytesFull := c.maxBytes > 0 && bytes > 0 && c.bytes.Load()+bytes > c.maxBytes
+		if !itemsFull && !bytesFull {
+			return
+		}
 
-	var keyToEvict string
-	var oldestTime time.Time
-	var lowestCount int
+		s, key, victim := c.pickVictim(keep)
+		if victim == nil {
+			return
+		}
 
-	switch c.evictionPolicy {
-	case LRU:
-		for k, item := range c.items {
-			if keyToEvict == "" || item.LastAccess.Before(oldestTime) {
-				keyToEvict = k
-				oldestTime = item.LastAccess
-			}
+		s.mu.Lock()
+		// Someone else may have replaced or removed it since it was picked
+		if s.items[key] == victim {
+			c.deleteItem(s, key, EvictedCapacity)
 		}
-	case LFU:
-		for k, item := range c.items {
-			if keyToEvict == "" || item.AccessCount < lowestCount {
-				keyToEvict = k
-				lowestCount = item.AccessCount
+		s.mu.Unlock()
+	}
+}
+
+func (c *Cache) pickVictim(keep string) (*shard, string, *entry) {
+	var (
+		victimShard *shard
+		victimKey   string
+		victim      *entry
+		lowest      int64
+	)
+
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for key, e := range s.items {
+			if key == keep {
+				continue
 			}
-		}
-	case FIFO:
-		for k, item := range c.items {
-			if keyToEvict == "" || item.Created.Before(oldestTime) {
-				keyToEvict = k
-				oldestTime = item.Created
+			if rank := e.rank(c.evictionPolicy); victim == nil || rank < lowest {
+				victimShard, victimKey, victim, lowest = s, key, e, rank
 			}
 		}
+		s.mu.RUnlock()
 	}
 
-	if keyToEvict != "" {
-		c.deleteItem(keyToEvict)
-		c.stats.Evictions++
-	}
+	return victimShard, victimKey, victim
 }
 
-// deleteItem removes an item and calls the onEvicted callback if set
-func (c *Cache) deleteItem(key string) {
-	if c.onEvicted != nil {
-		if item, found := c.items[key]; found {
-			c.onEvicted(key, item.Value)
-			releaseItem(&item)
-		}
+// deleteItem removes an item and queues the eviction callbacks, the shard must be locked
+func (c *Cache) deleteItem(s *shard, key string, reason EvictionReason) {
+	e, found := s.items[key]
+	if !found {
+		return
 	}
 
-	delete(c.items, key)
+	c.removeLocked(s, key)
+	c.evicted(key, e.value, reason)
 }
 
-// Set adds an item to the cache with an expiration time
+// removeLocked removes an item without telling onEvicted, the shard must be locked
+func (c *Cache) removeLocked(s *shard, key string) {
+	if e, found := s.items[key]; found {
+		delete(s.items, key)
+		c.count.Add(-1)
+		c.bytes.Add(-e.cost)
+	}
+}
+
+// Set adds an item to the cache with an expiration time, costing what costOf says
 func (c *Cache) Set(key string, value any, expiration time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.SetWithCost(key, value, costOf(value), expiration)
+}
 
-	// Check if we need to evict an item
-	_, exists := c.items[key]
-	if c.maxItems > 0 && len(c.items) >= c.maxItems && !exists {
-		c.evict()
-	}
+// SetWithCost adds an item that counts cost towards MaxBytes. An item costing more than
+// MaxBytes on its own is not kept, and neither is the value it would have replaced.
+func (c *Cache) SetWithCost(key string, value any, cost int64, expiration time.Duration) {
+	now := time.Now()
 
 	var exp int64
 	if expiration > 0 {
-		exp = time.Now().Add(expiration).UnixNano()
+		exp = now.Add(expiration).UnixNano()
+	}
+	c.store(key, newEntry(value, max(cost, 0), exp, now.UnixNano(), now.UnixNano(), 0))
+}
+
+// store puts e under key, making room for it first when the cache is full
+func (c *Cache) store(key string, e *entry) {
+	s := c.shardFor(key)
+
+	if c.maxBytes > 0 && e.cost > c.maxBytes {
+		s.mu.Lock()
+		c.removeLocked(s, key)
+		s.mu.Unlock()
+		return
 	}
 
-	c.items[key] = Item{
-		Value:      value,
-		Expiration: exp,
+	// Check if we need to evict, before taking the lock since eviction looks at every shard
+	if c.maxItems > 0 || c.maxBytes > 0 {
+		prev := c.peek(s, key)
+		if prev == nil {
+			c.makeRoom(key, true, e.cost)
+		} else {
+			c.makeRoom(key, false, e.cost-prev.cost)
+		}
 	}
 
-	c.stats.TotalItems++
+	s.mu.Lock()
+	if prev, exists := s.items[key]; exists {
+		c.bytes.Add(-prev.cost)
+	} else {
+		c.count.Add(1)
+	}
+	s.items[key] = e
+	c.bytes.Add(e.cost)
+	s.mu.Unlock()
+
+	c.sets.Add(1)
 }
 
-// Get retrieves an item from the cache
-func (c *Cache) Get(key string) (interface{}, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Cache) peek(s *shard, key string) *entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.items[key]
+}
 
-	item, found := c.items[key]
+// Get retrieves an item from the cache
+func (c *Cache) Get(key string) (any, bool) {
+	e, found := c.lookup(key)
 	if !found {
-		c.stats.Misses++
 		return nil, false
 	}
+	return e.value, true
+}
 
-	// Check if the item has expired
-	if item.IsExpired() {
-		c.deleteItem(key)
-		c.stats.Misses++
-		return nil, false
+func (c *Cache) GetWithExpiration(key string) (any, time.Time, bool) {
+	e, found := c.lookup(key)
+	if !found {
+		return nil, time.Time{}, false
 	}
 
-	// Update access stats
-	item.LastAccess = time.Now()
-	item.AccessCount++
-	c.items[key] = item
-
-	c.stats.Hits++
+	var expiration time.Time
+	if e.expiration > 0 {
+		expiration = time.Unix(0, e.expiration)
+	}
 
-	return item.Value, true
+	return e.value, expiration, true
 }
 
-func (c *Cache) GetWithExpiration(key string) (any, time.Time, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// lookup finds an unexpired entry and counts the access, only an expired one takes the write lock
+func (c *Cache) lookup(key string) (*entry, bool) {
+	s := c.shardFor(key)
+	now := time.Now().UnixNano()
 
-	item, found := c.items[key]
-	if !found {
-		c.stats.Misses++
-		return nil, time.Time{}, false
-	}
+	s.mu.RLock()
+	e, found := s.items[key]
+	s.mu.RUnlock()
 
-	// Check if the item has expired
-	if item.IsExpired() {
-		c.deleteItem(key)
-		c.stats.Misses++
-		return nil, time.Time{}, false
+	if !found {
+		c.misses.Add(1)
+		return nil, false
 	}
 
-	item.LastAccess = time.Now()
-	item.AccessCount++
-	c.items[key] = item
+	if e.expired(now) {
+		s.mu.Lock()
+		if s.items[key] == e {
+			c.deleteItem(s, key, EvictedExpired)
+		}
+		s.mu.Unlock()
 
-	c.stats.Hits++
+		c.misses.Add(1)
+		return nil, false
+	}
 
-	var expiration time.Time
-	if item.Expiration > 0 {
-		expiration = time.Unix(0, item.Expiration)
+	// Kept around for GetOrLoad to serve while it reloads
+	if e.stale(now) {
+		c.misses.Add(1)
+		return nil, false
 	}
 
-	return item.Value, expiration, true
+	e.lastAccess.Store(now)
+	e.accessCount.Add(1)
+	c.hits.Add(1)
+
+	return e, true
 }
 
 // Delete removes an item from the cache
 func (c *Cache) Delete(key string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	s := c.shardFor(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	c.deleteItem(key)
+	c.deleteItem(s, key, EvictedManual)
 }
 
 // Flush removes all items from the cache
 func (c *Cache) Flush() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for _, e := range s.items {
+			c.count.Add(-1)
+			c.bytes.Add(-e.cost)
+		}
+		s.items = make(map[string]*entry)
+		s.mu.Unlock()
+	}
 
-	c.items = make(map[string]Item)
-	c.stats = Stats{}
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.evictions.Store(0)
+	c.expired.Store(0)
+	c.removed.Store(0)
+	c.sets.Store(0)
 }
 
-// Close stops the cleanup goroutine
+// Close stops the cleanup goroutine, eviction callbacks still queued are dropped
 func (c *Cache) Close() {
-	c.stopCleanup <- true
+	c.closeOnce.Do(func() {
+		close(c.stopCleanup)
+	})
 }
 
 // Count returns the number of items in the cache
 func (c *Cache) Count() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	return len(c.items)
+	return int(c.count.Load())
 }
 
 // GetStats returns the cache statistics
 func (c *Cache) GetStats() Stats {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	return c.stats
+	return Stats{
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Evictions:  c.evictions.Load(),
+		Expired:    c.expired.Load(),
+		Removed:    c.removed.Load(),
+		TotalItems: c.sets.Load(),
+		Bytes:      c.bytes.Load(),
+	}
 }
 
-// SaveToFile saves the cache to a file
+// SaveToFile saves the cache to a file, see SaveSnapshot
 func (c *Cache) SaveToFile(filename string) error {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	return c.saveToWriter(file)
+	return c.SaveSnapshot(filename, false)
 }
 
-// LoadFromFile loads the cache from a file
+// LoadFromFile loads the cache from a file written by SaveToFile or SaveSnapshot
 func (c *Cache) LoadFromFile(filename string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return c.loadFromFile(filename, 0)
+}
 
+// loadFromFile loads filename, capping what is left of each item's TTL at maxTTL when it is set
+func (c *Cache) loadFromFile(filename string, maxTTL time.Duration) error {
 	file, err := os.Open(filename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	return c.loadFromReader(file)
+	r, err := decompressed(file)
+	if err != nil {
+		return err
+	}
+	return c.loadFromReader(r, maxTTL)
 }
 
-// saveToWriter encodes the cache to a writer
+// saveToWriter encodes the cache to a writer, each shard is only locked while it is copied
 func (c *Cache) saveToWriter(w io.Writer) error {
 	enc := gob.NewEncoder(w)
 
@@ -327,17 +516,22 @@ func (c *Cache) saveToWriter(w io.Writer) error {
 	now := time.Now().UnixNano()
 	items := make(map[string]Item)
 
-	for k, v := range c.items {
-		if v.Expiration == 0 || v.Expiration > now {
-			items[k] = v
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for k, e := range s.items {
+			if !e.expired(now) {
+				items[k] = e.item()
+			}
 		}
+		s.mu.RUnlock()
 	}
 
 	return enc.Encode(items)
 }
 
-// loadFromReader decodes the cache from a reader
-func (c *Cache) loadFromReader(r io.Reader) error {
+// loadFromReader decodes the cache from a reader. With a maxTTL, items that never expire or
+// expire later than that get maxTTL from now instead.
+func (c *Cache) loadFromReader(r io.Reader, maxTTL time.Duration) error {
 	dec := gob.NewDecoder(r)
 	items := make(map[string]Item)
 
@@ -345,12 +539,28 @@ func (c *Cache) loadFromReader(r io.Reader) error {
 		return err
 	}
 
+	var latest int64
+	if maxTTL > 0 {
+		latest = time.Now().Add(maxTTL).UnixNano()
+	}
+
 	// Only load unexpired items
-	now := time.Now().UnixNano()
 	for k, v := range items {
-		if v.Expiration == 0 || v.Expiration > now {
-			c.items[k] = v
+		if v.IsExpired() {
+			continue
+		}
+
+		expiration := v.Expiration
+		if latest > 0 && (expiration == 0 || expiration > latest) {
+			expiration = latest
+		}
+
+		// Snapshots from before costs were kept have none
+		cost := v.Cost
+		if cost == 0 {
+			cost = costOf(v.Value)
 		}
+		c.store(k, newEntry(v.Value, cost, expiration, v.Created.UnixNano(), v.LastAccess.UnixNano(), int64(v.AccessCount)))
 	}
 
 	return nil
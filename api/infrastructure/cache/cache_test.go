@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+)
+
+func TestSnapshotterRestoreCapsTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	saved := NewCache(DefaultOptions())
+	defer saved.Close()
+	saved.Set("forever", "value", 0)
+	saved.Set("long", "value", time.Hour)
+	saved.Set("short", "value", time.Second)
+	if err := saved.SaveSnapshot(path, true); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+
+	restored := NewCache(DefaultOptions())
+	defer restored.Close()
+	log, err := logger.NewDevelopmentLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSnapshotter(restored, SnapshotOptions{Path: path, MaxTTL: time.Minute}, log).Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	latest := time.Now().Add(time.Minute)
+	for _, key := range []string{"forever", "long", "short"} {
+		_, expiration, ok := restored.GetWithExpiration(key)
+		if !ok {
+			t.Fatalf("%s wasn't restored", key)
+		}
+		if expiration.IsZero() || expiration.After(latest) {
+			t.Errorf("%s expires at %v, want no later than %v", key, expiration, latest)
+		}
+	}
+}
+
+// benchmarkShardCounts compares one locked map against the default spread
+var benchmarkShardCounts = []int{1, DefaultShards}
+
+const benchmarkKeys = 1 << 14
+
+func benchmarkKeySet() []string {
+	keys := make([]string, benchmarkKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("room:%d", i)
+	}
+	return keys
+}
+
+func newBenchmarkCache(b *testing.B, shards, maxItems int, keys []string) *Cache {
+	b.Helper()
+
+	c := NewCache(Options{
+		CleanupInterval: time.Minute,
+		MaxItems:        maxItems,
+		EvictionPolicy:  LRU,
+		Shards:          shards,
+	})
+	b.Cleanup(c.Close)
+
+	for _, key := range keys {
+		c.Set(key, key, time.Hour)
+	}
+	return c
+}
+
+// runParallel hands every goroutine its own random walk over keys, read decides per operation
+// whether it is a Get
+func runParallel(b *testing.B, c *Cache, keys []string, read func(*rand.Rand) bool) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		for pb.Next() {
+			key := keys[rng.IntN(len(keys))]
+			if read(rng) {
+				c.Get(key)
+			} else {
+				c.Set(key, key, time.Hour)
+			}
+		}
+	})
+}
+
+func BenchmarkCacheGet(b *testing.B) {
+	keys := benchmarkKeySet()
+	for _, shards := range benchmarkShardCounts {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := newBenchmarkCache(b, shards, 0, keys)
+			runParallel(b, c, keys, func(*rand.Rand) bool { return true })
+		})
+	}
+}
+
+func BenchmarkCacheSet(b *testing.B) {
+	keys := benchmarkKeySet()
+	for _, shards := range benchmarkShardCounts {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := newBenchmarkCache(b, shards, 0, nil)
+			runParallel(b, c, keys, func(*rand.Rand) bool { return false })
+		})
+	}
+}
+
+// BenchmarkCacheMixed is the read-heavy load the room and user caches see, nine Gets to a Set
+func BenchmarkCacheMixed(b *testing.B) {
+	keys := benchmarkKeySet()
+	for _, shards := range benchmarkShardCounts {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := newBenchmarkCache(b, shards, 0, keys)
+			runParallel(b, c, keys, func(rng *rand.Rand) bool { return rng.IntN(10) != 0 })
+		})
+	}
+}
+
+// BenchmarkCacheSetEvicting keeps the cache full, so every new key picks a victim across the shards
+func BenchmarkCacheSetEvicting(b *testing.B) {
+	keys := benchmarkKeySet()
+	for _, shards := range benchmarkShardCounts {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := newBenchmarkCache(b, shards, len(keys)/4, keys[:len(keys)/4])
+			runParallel(b, c, keys, func(*rand.Rand) bool { return false })
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package cache
+
+// Sizer is implemented by values that know roughly how many bytes they hold, for MaxBytes
+type Sizer interface {
+	Size() int64
+}
+
+// costOf is what an item counts towards MaxBytes when Set doesn't say. Values other than
+// a Sizer, bytes or a string cost nothing and are only bounded by MaxItems.
+func costOf(value any) int64 {
+	switch v := value.(type) {
+	case Sizer:
+		return v.Size()
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	default:
+		return 0
+	}
+}
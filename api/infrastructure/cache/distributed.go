@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,14 +12,17 @@ import (
 // DistributedCache combines local and Redis caching
 type DistributedCache struct {
 	local       *Cache
-	redis       *redis.Client
+	redis       redis.UniversalClient
 	keyPrefix   string
 	localTTL    time.Duration
 	redisKeyTTL time.Duration
+
+	redisHits   atomic.Int64
+	redisMisses atomic.Int64
 }
 
 // NewDistributedCache creates a new distributed cache
-func NewDistributedCache(redisClient *redis.Client, keyPrefix string, localOptions Options) *DistributedCache {
+func NewDistributedCache(redisClient redis.UniversalClient, keyPrefix string, localOptions Options) *DistributedCache {
 	return &DistributedCache{
 		local:       NewCache(localOptions),
 		redis:       redisClient,
@@ -30,8 +34,10 @@ func NewDistributedCache(redisClient *redis.Client, keyPrefix string, localOptio
 
 // Set adds an item to both local and Redis caches
 func (dc *DistributedCache) Set(key string, value any, ttl time.Duration) error {
+	// A key kept in Redis without expiry still goes stale locally once another instance
+	// writes it, so the local copy never outlives localTTL
 	localTTL := ttl
-	if ttl > dc.localTTL {
+	if ttl <= 0 || ttl > dc.localTTL {
 		localTTL = dc.localTTL
 	}
 	dc.local.Set(key, value, localTTL)
@@ -67,10 +73,12 @@ func (dc *DistributedCache) Get(key string, valuePtr any) (bool, error) {
 	data, err := dc.redis.Get(ctx, redisKey).Bytes()
 	if err != nil {
 		if err == redis.Nil {
+			dc.redisMisses.Add(1)
 			return false, nil
 		}
 		return false, err
 	}
+	dc.redisHits.Add(1)
 
 	// Unmarshal the data
 	if err := json.Unmarshal(data, valuePtr); err != nil {
@@ -83,6 +91,34 @@ func (dc *DistributedCache) Get(key string, valuePtr any) (bool, error) {
 	return true, nil
 }
 
+// SetRemote stores an item in Redis only, for records other instances change and a local
+// copy would serve stale
+func (dc *DistributedCache) SetRemote(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	redisKey := dc.keyPrefix + key
+	return dc.redis.Set(ctx, redisKey, data, ttl).Err()
+}
+
+// GetRemote reads an item straight from Redis, skipping and not filling the local cache
+func (dc *DistributedCache) GetRemote(ctx context.Context, key string, valuePtr any) (bool, error) {
+	redisKey := dc.keyPrefix + key
+	data, err := dc.redis.Get(ctx, redisKey).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			dc.redisMisses.Add(1)
+			return false, nil
+		}
+		return false, err
+	}
+	dc.redisHits.Add(1)
+
+	return true, json.Unmarshal(data, valuePtr)
+}
+
 // Delete removes an item from both caches
 func (dc *DistributedCache) Delete(key string) error {
 	// Delete from local cache
@@ -101,15 +137,18 @@ func (dc *DistributedCache) Flush() error {
 
 	// Flush Redis keys with our prefix
 	ctx := context.Background()
-	iter := dc.redis.Scan(ctx, 0, dc.keyPrefix+"*", 100).Iterator()
+	keys, err := ScanKeys(ctx, dc.redis, dc.keyPrefix+"*")
+	if err != nil {
+		return err
+	}
 
-	for iter.Next(ctx) {
-		if err := dc.redis.Del(ctx, iter.Val()).Err(); err != nil {
+	for _, key := range keys {
+		if err := dc.redis.Del(ctx, key).Err(); err != nil {
 			return err
 		}
 	}
 
-	return iter.Err()
+	return nil
 }
 
 // ZAdd adds a member to a sorted set
@@ -172,6 +211,46 @@ func (dc *DistributedCache) SMembers(ctx context.Context, key string) ([]string,
 	return dc.redis.SMembers(ctx, redisKey).Result()
 }
 
+// SCard returns the number of members in a set
+func (dc *DistributedCache) SCard(ctx context.Context, key string) (int64, error) {
+	redisKey := dc.keyPrefix + key
+	return dc.redis.SCard(ctx, redisKey).Result()
+}
+
+// HSet sets a field of a hash
+func (dc *DistributedCache) HSet(ctx context.Context, key, field string, value any) error {
+	redisKey := dc.keyPrefix + key
+	return dc.redis.HSet(ctx, redisKey, field, value).Err()
+}
+
+// HGet returns a field of a hash, redis.Nil when it isn't set
+func (dc *DistributedCache) HGet(ctx context.Context, key, field string) (string, error) {
+	redisKey := dc.keyPrefix + key
+	return dc.redis.HGet(ctx, redisKey, field).Result()
+}
+
+// HDel removes fields from a hash
+func (dc *DistributedCache) HDel(ctx context.Context, key string, fields ...string) error {
+	redisKey := dc.keyPrefix + key
+	return dc.redis.HDel(ctx, redisKey, fields...).Err()
+}
+
+// HashTag tags s the way HashTag does for this cache's client, keys sharing a tag can go
+// into one RunScript
+func (dc *DistributedCache) HashTag(s string) string {
+	return HashTag(dc.redis, s)
+}
+
+// RunScript runs a Lua script with the keys prefixed, scripts must only touch the keys they
+// are given and on a cluster those keys must share a hash tag
+func (dc *DistributedCache) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...any) *redis.Cmd {
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = dc.keyPrefix + key
+	}
+	return script.Run(ctx, dc.redis, redisKeys, args...)
+}
+
 // Pipeline returns a Redis pipeline for batch operations
 func (dc *DistributedCache) Pipeline() redis.Pipeliner {
 	return dc.redis.Pipeline()
@@ -188,6 +267,17 @@ func (dc *DistributedCache) GetRedisKey(key string) string {
 	return dc.keyPrefix + key
 }
 
+// Local is the in-process layer, the part a Snapshotter can keep across restarts
+func (dc *DistributedCache) Local() *Cache {
+	return dc.local
+}
+
+// LocalTTL is the longest the local layer keeps a value, restore a snapshot of it with this
+// as its MaxTTL
+func (dc *DistributedCache) LocalTTL() time.Duration {
+	return dc.localTTL
+}
+
 // Close closes both caches
 func (dc *DistributedCache) Close() error {
 	dc.local.Close()
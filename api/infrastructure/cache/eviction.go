@@ -0,0 +1,63 @@
+package cache
+
+// EvictionReason is why an item left the cache
+type EvictionReason string
+
+const (
+	EvictedExpired  EvictionReason = "expired"
+	EvictedCapacity EvictionReason = "capacity"
+	EvictedManual   EvictionReason = "manual"
+)
+
+// evictionQueueSize is how many callbacks can wait before each gets a goroutine of its own
+const evictionQueueSize = 1024
+
+type eviction struct {
+	key    string
+	value  any
+	reason EvictionReason
+}
+
+// evicted counts an item leaving and queues the callbacks. It is called with a shard
+// locked, so it never waits on them.
+func (c *Cache) evicted(key string, value any, reason EvictionReason) {
+	switch reason {
+	case EvictedExpired:
+		c.expired.Add(1)
+	case EvictedCapacity:
+		c.evictions.Add(1)
+	default:
+		c.removed.Add(1)
+	}
+
+	if c.evictionQueue == nil {
+		return
+	}
+
+	ev := eviction{key: key, value: value, reason: reason}
+	select {
+	case c.evictionQueue <- ev:
+	default:
+		go c.runCallbacks(ev)
+	}
+}
+
+func (c *Cache) runEvictionCallbacks() {
+	for {
+		select {
+		case ev := <-c.evictionQueue:
+			c.runCallbacks(ev)
+		case <-c.stopCleanup:
+			return
+		}
+	}
+}
+
+func (c *Cache) runCallbacks(ev eviction) {
+	if c.onEvicted != nil {
+		c.onEvicted(ev.key, ev.value)
+	}
+	if c.onEviction != nil {
+		c.onEviction(ev.key, ev.value, ev.reason)
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// GetOrLoad returns the cached value, or calls load and caches what it returns for ttl.
+// Concurrent callers missing the same key share a single load, and a failed load caches
+// nothing. With StaleWhileRevalidate set, a value past its TTL is still returned while one
+// background load replaces it.
+func (c *Cache) GetOrLoad(key string, ttl time.Duration, load func() (any, error)) (any, error) {
+	now := time.Now().UnixNano()
+	s := c.shardFor(key)
+
+	s.mu.RLock()
+	e, found := s.items[key]
+	s.mu.RUnlock()
+
+	if found && !e.expired(now) {
+		e.lastAccess.Store(now)
+		e.accessCount.Add(1)
+		c.hits.Add(1)
+
+		if e.stale(now) {
+			// The channel is buffered, nobody has to read the result
+			c.loads.DoChan(key, c.loader(key, ttl, load))
+		}
+		return e.value, nil
+	}
+
+	c.misses.Add(1)
+	value, err, _ := c.loads.Do(key, c.loader(key, ttl, load))
+	return value, err
+}
+
+func (c *Cache) loader(key string, ttl time.Duration, load func() (any, error)) func() (any, error) {
+	return func() (any, error) {
+		// A load that finished just before this one started is as good as sharing it
+		if value, found := c.fresh(key); found {
+			return value, nil
+		}
+
+		value, err := load()
+		if err != nil {
+			return nil, err
+		}
+
+		c.storeLoaded(key, value, ttl)
+		return value, nil
+	}
+}
+
+func (c *Cache) fresh(key string) (any, bool) {
+	now := time.Now().UnixNano()
+	s := c.shardFor(key)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, found := s.items[key]
+	if !found || e.expired(now) || e.stale(now) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// storeLoaded applies the jitter to ttl, and keeps the value around past it when stale
+// values may be served
+func (c *Cache) storeLoaded(key string, value any, ttl time.Duration) {
+	now := time.Now()
+	if ttl > 0 && c.loadJitter > 0 {
+		ttl -= time.Duration(rand.Float64() * c.loadJitter * float64(ttl))
+	}
+
+	var expiration, freshUntil int64
+	if ttl > 0 {
+		expiration = now.Add(ttl).UnixNano()
+		if c.staleFor > 0 {
+			freshUntil = expiration
+			expiration += int64(c.staleFor)
+		}
+	}
+
+	e := newEntry(value, costOf(value), expiration, now.UnixNano(), now.UnixNano(), 0)
+	e.freshUntil = freshUntil
+	c.store(key, e)
+}
@@ -0,0 +1,32 @@
+package cache
+
+import "github.com/hilthontt/visper/api/infrastructure/metrics"
+
+// ReportStats copies the cache's stats into the cache_* gauges labelled with name. Like the
+// pool stats, hits, misses and evictions keep growing until the cache is flushed.
+func (c *Cache) ReportStats(name string) metrics.Collector {
+	return func(m metrics.Manager) {
+		c.report(m, name)
+	}
+}
+
+func (c *Cache) report(m metrics.Manager, name string) {
+	stats := c.GetStats()
+	m.SetGauge("cache_hits", float64(stats.Hits), "cache", name, "layer", "local")
+	m.SetGauge("cache_misses", float64(stats.Misses), "cache", name, "layer", "local")
+	m.SetGauge("cache_evictions", float64(stats.Expired), "cache", name, "reason", string(EvictedExpired))
+	m.SetGauge("cache_evictions", float64(stats.Evictions), "cache", name, "reason", string(EvictedCapacity))
+	m.SetGauge("cache_evictions", float64(stats.Removed), "cache", name, "reason", string(EvictedManual))
+	m.SetGauge("cache_items", float64(c.Count()), "cache", name)
+	m.SetGauge("cache_bytes", float64(stats.Bytes), "cache", name)
+}
+
+// ReportStats reports the local cache like Cache.ReportStats, and the Redis lookups it
+// missed as the redis layer
+func (dc *DistributedCache) ReportStats(name string) metrics.Collector {
+	return func(m metrics.Manager) {
+		dc.local.report(m, name)
+		m.SetGauge("cache_hits", float64(dc.redisHits.Load()), "cache", name, "layer", "redis")
+		m.SetGauge("cache_misses", float64(dc.redisMisses.Load()), "cache", name, "layer", "redis")
+	}
+}
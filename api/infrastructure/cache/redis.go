@@ -3,38 +3,210 @@ package cache
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hilthontt/visper/api/infrastructure/config"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
-var redisClient *redis.Client
+// Redis deployments InitRedis can talk to, see config.RedisConfig
+const (
+	RedisStandalone = "standalone"
+	RedisCluster    = "cluster"
+	RedisSentinel   = "sentinel"
+)
 
-func InitRedis(cfg *config.Config) error {
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:         fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
-		Password:     cfg.Redis.Password,
-		DB:           0,
-		DialTimeout:  cfg.Redis.DialTimeout * time.Second,
-		ReadTimeout:  cfg.Redis.ReadTimeout * time.Second,
-		WriteTimeout: cfg.Redis.WriteTimeout * time.Second,
-		PoolSize:     cfg.Redis.PoolSize,
-		PoolTimeout:  cfg.Redis.PoolTimeout,
-	})
+var redisClient redis.UniversalClient
 
-	_, err := redisClient.Ping(context.Background()).Result()
+// Kept by the health check and the connection callback, ReportPoolStats publishes them
+var (
+	redisUp          atomic.Bool
+	redisReconnects  atomic.Int64
+	redisConnections atomic.Int64
+)
+
+func InitRedis(cfg *config.Config) error {
+	client, err := newRedisClient(cfg.Redis)
 	if err != nil {
 		return err
 	}
+	redisClient = client
+
+	if err := pingRedis(context.Background(), redisClient); err != nil {
+		return err
+	}
+	redisUp.Store(true)
 
 	return nil
 }
 
-func GetRedis() *redis.Client {
+// newRedisClient builds the client for the configured mode. The timeouts are durations
+// already, "5s" in the config file.
+func newRedisClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	onConnect := func(ctx context.Context, cn *redis.Conn) error {
+		redisConnections.Add(1)
+		return nil
+	}
+
+	switch cfg.Mode {
+	case "", RedisStandalone:
+		return redis.NewClient(&redis.Options{
+			Addr:         fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
+			Password:     cfg.Password,
+			DB:           0,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolSize:     cfg.PoolSize,
+			PoolTimeout:  cfg.PoolTimeout,
+			OnConnect:    onConnect,
+		}), nil
+	case RedisCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.Addrs,
+			Password:     cfg.Password,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolSize:     cfg.PoolSize,
+			PoolTimeout:  cfg.PoolTimeout,
+			OnConnect:    onConnect,
+		}), nil
+	case RedisSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Password:         cfg.Password,
+			DB:               0,
+			DialTimeout:      cfg.DialTimeout,
+			ReadTimeout:      cfg.ReadTimeout,
+			WriteTimeout:     cfg.WriteTimeout,
+			PoolSize:         cfg.PoolSize,
+			PoolTimeout:      cfg.PoolTimeout,
+			OnConnect:        onConnect,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
+}
+
+// pingRedis reaches every shard of a cluster, one unreachable shard fails its slots
+func pingRedis(ctx context.Context, client redis.UniversalClient) error {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
+			return shard.Ping(ctx).Err()
+		})
+	}
+	return client.Ping(ctx).Err()
+}
+
+func GetRedis() redis.UniversalClient {
 	return redisClient
 }
 
 func CloseRedis() {
 	redisClient.Close()
 }
+
+// RedisHealthy is what the last health check found
+func RedisHealthy() bool {
+	return redisUp.Load()
+}
+
+// WatchRedis pings Redis every interval until ctx is done. The client reconnects by itself,
+// this notices when it couldn't and when it did again.
+func WatchRedis(ctx context.Context, interval time.Duration, logger *logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			err := pingRedis(checkCtx, redisClient)
+			cancel()
+
+			wasUp := redisUp.Swap(err == nil)
+			switch {
+			case err != nil && wasUp:
+				logger.Error("Redis health check failed", zap.Error(err))
+			case err == nil && !wasUp:
+				redisReconnects.Add(1)
+				logger.Info("Redis reachable again")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// HashTag wraps s in braces on a cluster, so keys built around the same tag share a slot
+// and can go into one script or multi-key command. Elsewhere s is returned as it is.
+func HashTag(client redis.UniversalClient, s string) string {
+	if _, ok := client.(*redis.ClusterClient); !ok || strings.Contains(s, "{") {
+		return s
+	}
+	return "{" + s + "}"
+}
+
+// ScanKeys finds the keys matching pattern, on a cluster across every master
+func ScanKeys(ctx context.Context, client redis.UniversalClient, pattern string) ([]string, error) {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, client, pattern)
+	}
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		found, err := scanNode(ctx, master, pattern)
+		mu.Lock()
+		keys = append(keys, found...)
+		mu.Unlock()
+		return err
+	})
+	return keys, err
+}
+
+func scanNode(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// ReportPoolStats copies the shared client's pool counters into the redis_pool_* gauges,
+// hits, misses and timeouts keep growing since the client was created. The health check's
+// findings go into redis_up, redis_reconnects and redis_connections_opened.
+func ReportPoolStats(m metrics.Manager) {
+	if redisClient == nil {
+		return
+	}
+
+	stats := redisClient.PoolStats()
+	m.SetGauge("redis_pool_hits", float64(stats.Hits))
+	m.SetGauge("redis_pool_misses", float64(stats.Misses))
+	m.SetGauge("redis_pool_timeouts", float64(stats.Timeouts))
+	m.SetGauge("redis_pool_total_conns", float64(stats.TotalConns))
+	m.SetGauge("redis_pool_idle_conns", float64(stats.IdleConns))
+	m.SetGauge("redis_pool_stale_conns", float64(stats.StaleConns))
+
+	up := 0.0
+	if redisUp.Load() {
+		up = 1
+	}
+	m.SetGauge("redis_up", up)
+	m.SetGauge("redis_reconnects", float64(redisReconnects.Load()))
+	m.SetGauge("redis_connections_opened", float64(redisConnections.Load()))
+}
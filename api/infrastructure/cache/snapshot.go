@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// SaveSnapshot writes the cache to a temporary file next to filename and renames it over
+// filename once it is complete, so a crash mid-write leaves the previous snapshot intact.
+// A compressed snapshot is gzipped, LoadFromFile reads either kind.
+func (c *Cache) SaveSnapshot(filename string, compress bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+"-*")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot file: %w", err)
+	}
+	// Only does anything when the rename didn't happen
+	defer os.Remove(tmp.Name())
+
+	if err := c.writeSnapshot(tmp, compress); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("error replacing snapshot: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) writeSnapshot(w io.Writer, compress bool) error {
+	buffered := bufio.NewWriter(w)
+	if !compress {
+		if err := c.saveToWriter(buffered); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	}
+
+	zw := gzip.NewWriter(buffered)
+	if err := c.saveToWriter(zw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// decompressed unwraps a gzipped snapshot, anything else is read as it is
+func decompressed(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// Too short to be either kind, the decoder reports it
+		return buffered, nil
+	}
+	return gzip.NewReader(buffered)
+}
+
+// SnapshotOptions configures a Snapshotter
+type SnapshotOptions struct {
+	Path     string
+	Interval time.Duration
+	Compress bool
+	// MaxTTL caps how long a restored item is kept, one saved without an expiry included.
+	// Zero restores items as they were saved.
+	MaxTTL time.Duration
+}
+
+// Snapshotter keeps a snapshot of a cache on disk so a restart doesn't begin with it cold
+type Snapshotter struct {
+	cache  *Cache
+	opts   SnapshotOptions
+	logger *logger.Logger
+}
+
+func NewSnapshotter(cache *Cache, opts SnapshotOptions, logger *logger.Logger) *Snapshotter {
+	return &Snapshotter{
+		cache:  cache,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Restore loads the snapshot into the cache, having none yet isn't an error
+func (s *Snapshotter) Restore() error {
+	err := s.cache.loadFromFile(s.opts.Path, s.opts.MaxTTL)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error loading cache snapshot: %w", err)
+	}
+
+	s.logger.Info("Cache restored from snapshot",
+		zap.String("path", s.opts.Path),
+		zap.Int("items", s.cache.Count()),
+	)
+	return nil
+}
+
+// Save writes a snapshot now
+func (s *Snapshotter) Save() error {
+	return s.cache.SaveSnapshot(s.opts.Path, s.opts.Compress)
+}
+
+// Start saves a snapshot every Interval until ctx is done, and a last one then
+func (s *Snapshotter) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				s.logger.Error("Failed to snapshot cache", zap.String("path", s.opts.Path), zap.Error(err))
+			}
+		case <-ctx.Done():
+			if err := s.Save(); err != nil {
+				s.logger.Error("Failed to snapshot cache on shutdown", zap.String("path", s.opts.Path), zap.Error(err))
+				return
+			}
+			s.logger.Info("Cache snapshot saved", zap.String("path", s.opts.Path))
+			return
+		}
+	}
+}
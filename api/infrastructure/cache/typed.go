@@ -0,0 +1,105 @@
+package cache
+
+import "time"
+
+// TypedCache holds values of a single type so callers get a T back instead of asserting on
+// Get. It sits on a Cache, which stays available through Untyped.
+type TypedCache[T any] struct {
+	cache *Cache
+}
+
+// NewTypedCache creates a new cache of T with the given options
+func NewTypedCache[T any](options Options) *TypedCache[T] {
+	return &TypedCache[T]{cache: NewCache(options)}
+}
+
+// Typed views an existing cache as holding T, values of another type read as misses
+func Typed[T any](cache *Cache) *TypedCache[T] {
+	return &TypedCache[T]{cache: cache}
+}
+
+// Untyped is the Cache underneath, for code still written against it
+func (tc *TypedCache[T]) Untyped() *Cache {
+	return tc.cache
+}
+
+// Set adds an item to the cache with an expiration time
+func (tc *TypedCache[T]) Set(key string, value T, expiration time.Duration) {
+	tc.cache.Set(key, value, expiration)
+}
+
+// SetWithCost adds an item that counts cost towards MaxBytes
+func (tc *TypedCache[T]) SetWithCost(key string, value T, cost int64, expiration time.Duration) {
+	tc.cache.SetWithCost(key, value, cost, expiration)
+}
+
+// Get retrieves an item from the cache
+func (tc *TypedCache[T]) Get(key string) (T, bool) {
+	value, found := tc.cache.Get(key)
+	return typedValue[T](value, found)
+}
+
+func (tc *TypedCache[T]) GetWithExpiration(key string) (T, time.Time, bool) {
+	value, expiration, found := tc.cache.GetWithExpiration(key)
+	typed, ok := typedValue[T](value, found)
+	if !ok {
+		return typed, time.Time{}, false
+	}
+	return typed, expiration, true
+}
+
+// GetOrLoad returns the cached value, or calls load and caches what it returns, see
+// Cache.GetOrLoad
+func (tc *TypedCache[T]) GetOrLoad(key string, expiration time.Duration, load func() (T, error)) (T, error) {
+	value, err := tc.cache.GetOrLoad(key, expiration, func() (any, error) {
+		return load()
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	typed, ok := value.(T)
+	if !ok {
+		// Something else was put under key through Untyped
+		if typed, err = load(); err == nil {
+			tc.Set(key, typed, expiration)
+		}
+	}
+	return typed, err
+}
+
+// Delete removes an item from the cache
+func (tc *TypedCache[T]) Delete(key string) {
+	tc.cache.Delete(key)
+}
+
+// Flush removes all items from the cache
+func (tc *TypedCache[T]) Flush() {
+	tc.cache.Flush()
+}
+
+// Count returns the number of items in the cache
+func (tc *TypedCache[T]) Count() int {
+	return tc.cache.Count()
+}
+
+// GetStats returns the cache statistics
+func (tc *TypedCache[T]) GetStats() Stats {
+	return tc.cache.GetStats()
+}
+
+// Close stops the cleanup goroutine
+func (tc *TypedCache[T]) Close() {
+	tc.cache.Close()
+}
+
+// typedValue treats a value of the wrong type, put there through Untyped, as a miss
+func typedValue[T any](value any, found bool) (T, bool) {
+	if !found {
+		var zero T
+		return zero, false
+	}
+	typed, ok := value.(T)
+	return typed, ok
+}
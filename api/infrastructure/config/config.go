@@ -4,21 +4,48 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Postgres PostgresConfig
-	Redis    RedisConfig
-	Cors     CorsConfig
-	Logger   LoggerConfig
-	Jaeger   JaegerConfig
-	Sentry   SentryConfig
+	Server      ServerConfig
+	Postgres    PostgresConfig
+	Redis       RedisConfig
+	Cors        CorsConfig
+	Logger      LoggerConfig
+	Jaeger      JaegerConfig
+	Tracing     TracingConfig
+	Sentry      SentryConfig
+	Admin       AdminConfig
+	Auth        AuthConfig
+	OIDC        OIDCConfig
+	Maintenance MaintenanceConfig
+	Push        PushConfig
+	Presence    PresenceConfig
+	API         APIConfig
+	RateLimit   RateLimitConfig
+	Cluster     ClusterConfig
+	Events      EventsConfig
+	NATS        NATSConfig
+	WebSocket   WebSocketConfig
+	Files       FilesConfig
+	Storage     StorageConfig
+	Scanner     ScannerConfig
+	Persistence PersistenceConfig
+	RoomExpiry  RoomExpiryConfig
+	Audit       AuditConfig
+	Profiler    ProfilerConfig
+	Cache       CacheConfig
+
+	// source is the file the config was read from, Watch reloads it
+	source *viper.Viper
 }
 
 type ServerConfig struct {
@@ -27,13 +54,26 @@ type ServerConfig struct {
 	RunMode      string
 	Domain       string
 	FrontEndURL  string
+	// TrustedProxies are the CIDRs or addresses whose X-Forwarded-For and X-Real-IP name
+	// the client, without any the client is always the peer
+	TrustedProxies []string
+	// WatchConfig reloads the config file when it changes, see Config.Watch for what applies
+	WatchConfig bool
+	// ShutdownTimeout bounds draining connections and stopping every subsystem on SIGTERM
+	ShutdownTimeout time.Duration
 }
 
+// Encoding is json or console. Sampling keeps the first SamplingInitial entries with the
+// same message each second and then every SamplingThereafter-th, zero turns it off.
+// Levels gives the ws, repo and events subsystems their own level as "name=level".
 type LoggerConfig struct {
-	FilePath string
-	Encoding string
-	Level    string
-	Logger   string
+	FilePath           string
+	Encoding           string
+	Level              string
+	Logger             string
+	SamplingInitial    int
+	SamplingThereafter int
+	Levels             []string
 }
 
 type PostgresConfig struct {
@@ -59,6 +99,15 @@ type RedisConfig struct {
 	IdleCheckFrequency time.Duration
 	PoolSize           int
 	PoolTimeout        time.Duration
+
+	// Mode is standalone, cluster or sentinel. Host and Port are the standalone server,
+	// cluster nodes or sentinels are listed in Addrs as host:port.
+	Mode             string
+	Addrs            []string
+	MasterName       string // The sentinel-managed master to follow
+	SentinelPassword string
+	// HealthCheckInterval is how often every node is pinged, 15s unless set
+	HealthCheckInterval time.Duration
 }
 
 type CorsConfig struct {
@@ -71,12 +120,231 @@ type JaegerConfig struct {
 	Endpoint       string
 }
 
+// TracingConfig picks where spans go and how many are kept, the service name and version
+// still come from JaegerConfig
+type TracingConfig struct {
+	// Exporter is jaeger, otlp-grpc, otlp-http or none
+	Exporter string
+	// Endpoint is the OTLP collector, host:port for otlp-grpc and a base URL for otlp-http
+	Endpoint string
+	// Insecure turns off TLS to an otlp-grpc collector
+	Insecure bool
+	// Headers are "key=value" pairs sent with every export, e.g. the collector's API key
+	Headers []string
+	// Sampler is always, never, ratio or ratelimited. Child spans follow their parent's decision.
+	Sampler          string
+	SampleRatio      float64
+	SamplesPerSecond float64
+	// ResourceAttributes are "key=value" pairs added to every span, e.g. deployment.environment=prod
+	ResourceAttributes []string
+}
+
 type SentryConfig struct {
 	Dsn            string
 	Debug          bool
 	SendDefaultPII bool
 }
 
+type AdminConfig struct {
+	Token string
+}
+
+// Each push platform is only enabled when its credentials are set
+type PushConfig struct {
+	VAPIDPublicKey     string
+	VAPIDPrivateKey    string
+	VAPIDSubject       string
+	FCMProjectID       string
+	FCMCredentialsFile string
+}
+
+// A connection is reaped once MaxMissedPongs pings in a row go unanswered
+type WebSocketConfig struct {
+	PingInterval   time.Duration
+	MaxMissedPongs int
+
+	// Inbound frames per connection, bursts past the limit too often earn a ban
+	FrameRate         float64
+	FrameBurst        int
+	MaxRateViolations int
+	RateBanDuration   time.Duration
+
+	// Browser origins allowed to open sockets, empty allows any
+	AllowedOrigins []string
+	// RequireAuthFrame makes every room socket open with a ticket from POST /rooms/:id/ws-ticket
+	RequireAuthFrame bool
+}
+
+// Member tokens are signed with the first of TokenKeys, "<id>:<secret>", and accepted from
+// any of them. A key is rotated by listing a new one first, tokens of the old one are
+// replaced as they come in and it can be dropped after TokenTTL. Every instance needs the
+// same keys, without any a random one is made at startup.
+// AllowUserIDHeader trusts a bare X-User-ID for clients that don't send tokens yet. Anyone
+// can claim any user with it, so it is off unless asked for and never leads to a token.
+type AuthConfig struct {
+	TokenKeys         []string
+	TokenTTL          time.Duration
+	AllowUserIDHeader bool
+}
+
+// Enabled adds signing in with an OpenID Connect provider next to the anonymous guests.
+// RedirectURL is this API's /api/v1/auth/oidc/callback as registered with the provider.
+// Accounts whose subject is in AdminSubjects, or with a group claim in AdminGroups, may
+// use the admin API while signed in.
+type OIDCConfig struct {
+	Enabled       bool
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	AdminSubjects []string
+	AdminGroups   []string
+}
+
+// Download links are signed with SigningSecret and stop working LinkTTL after they were handed out.
+// Every instance needs the same secret, an empty one is replaced by a random one at startup.
+type FilesConfig struct {
+	SigningSecret string
+	LinkTTL       time.Duration
+	ResizeWorkers int // Goroutines rendering thumbnails, defaults to 2
+	// KeepImageMetadata stores JPEGs as uploaded, EXIF and its GPS coordinates included
+	KeepImageMetadata bool
+	RoomQuotaMB       int64 // Total upload size allowed per room, defaults to 100
+}
+
+// Backend is "local" (the default) or "s3", local disk only works while a single node serves uploads
+type StorageConfig struct {
+	Backend string
+	S3      S3StorageConfig
+}
+
+// Backend picks where rooms, messages and users are kept: "redis" (the default) or "postgres".
+// Rooms, Messages and Users override it for one entity. Everything else stays in Redis either way.
+type PersistenceConfig struct {
+	Backend  string
+	Rooms    string
+	Messages string
+	Users    string
+}
+
+// BackendFor resolves the backend of one entity, the override wins over Backend
+func (p PersistenceConfig) BackendFor(override string) string {
+	if override != "" {
+		return override
+	}
+	if p.Backend != "" {
+		return p.Backend
+	}
+	return "redis"
+}
+
+// Backend is "clamav" (the default) or "none" to serve uploads unscanned
+type ScannerConfig struct {
+	Backend       string
+	ClamAVAddress string // clamd's TCP socket, defaults to localhost:3310
+}
+
+// Endpoint must be reachable by clients, downloads are presigned links straight to the bucket.
+// ServerSideEncryption is "", "AES256" or "aws:kms".
+type S3StorageConfig struct {
+	Endpoint             string
+	Region               string
+	Bucket               string
+	AccessKey            string
+	SecretKey            string
+	PathStyle            bool // MinIO needs path style addressing
+	ServerSideEncryption string
+	KMSKeyID             string
+}
+
+// Members get a warning as the room's remaining time passes each of WarnBefore.
+// CheckInterval also sets how late a warning or the deletion of an expired room can be.
+type RoomExpiryConfig struct {
+	CheckInterval time.Duration
+	WarnBefore    []time.Duration
+}
+
+// Audit entries older than Retention are deleted, every CheckInterval. They default to 90 days and an hour.
+type AuditConfig struct {
+	Retention     time.Duration
+	CheckInterval time.Duration
+}
+
+// The profiler checks the triggers every CheckInterval and captures a CPU, heap and goroutine
+// profile when one fires, at most once per MinInterval, keeping the newest Retain captures
+// in Dir. A trigger with a zero threshold is off, P99LatencyMs is over HTTP requests.
+type ProfilerConfig struct {
+	Dir           string
+	CheckInterval time.Duration
+	MinInterval   time.Duration
+	CPUDuration   time.Duration
+	Retain        int
+	CPUPercent    float64
+	HeapMB        float64
+	P99LatencyMs  float64
+}
+
+// CacheConfig snapshots the distributed cache's local layer, Redis persists its own data.
+// Rooms hold their encryption keys, so the snapshot is as sensitive as Redis.
+type CacheConfig struct {
+	SnapshotPath     string // Empty turns snapshots off, one found here at startup is loaded
+	SnapshotInterval time.Duration
+	SnapshotCompress bool
+}
+
+type PresenceConfig struct {
+	IdleTimeout time.Duration // Connected members with no activity for this long show as away
+}
+
+// Dates are RFC 3339, leaving one empty leaves its header off
+type APIConfig struct {
+	V1DeprecatedAt  string
+	V1SunsetAt      string
+	DeprecationLink string // Migration guide sent with the deprecation headers
+}
+
+// Algorithm is "sliding_window", the default, "token_bucket" or "leaky_bucket". BurstRatio
+// sizes the buckets as a share of each limit: the token bucket lets that many requests
+// through at once, the leaky bucket queues them and sends them on at the limit's pace.
+type RateLimitConfig struct {
+	Algorithm  string
+	BurstRatio float64
+}
+
+// Bus is "redis" or "nats" to share room events between instances, empty runs a single instance
+type ClusterConfig struct {
+	Bus        string
+	InstanceID string // Defaults to the hostname plus a random suffix
+}
+
+// Transport is "broker" for the embedded broker or "nats" for JetStream. With the broker,
+// BrokerAddress points at a broker server in another process instead of running one here,
+// and BrokerListen exposes the embedded one to other processes. Listing BrokerNodes, the
+// BrokerListen address of every instance in the same order, replicates the broker's
+// partitions over them. BrokerToken is required from every connection to the broker
+// server, and by every server this instance connects to; without it BrokerListen has to
+// be a loopback address.
+type EventsConfig struct {
+	Transport               string
+	BrokerAddress           string
+	BrokerListen            string
+	BrokerToken             string
+	BrokerNodeID            int // 1-based position of this instance in BrokerNodes
+	BrokerNodes             []string
+	BrokerReplicationFactor int
+	BrokerMinInSync         int
+}
+
+type NATSConfig struct {
+	URL string
+}
+
+type MaintenanceConfig struct {
+	Enabled bool
+	Message string
+}
+
 func GetConfig() *Config {
 	cfgPath := getConfigPath(os.Getenv("APP_ENV"))
 	v, err := LoadConfig(cfgPath, "yml")
@@ -88,19 +356,54 @@ func GetConfig() *Config {
 	if err != nil {
 		log.Fatalf("Error in parse config %v", err)
 	}
+	cfg.applyEnvOverrides()
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 
+	return cfg
+}
+
+// applyEnvOverrides reads the variables that predate the VISPER_ prefix, they win over it
+func (c *Config) applyEnvOverrides() {
 	if envPort := os.Getenv("PORT"); envPort != "" {
-		cfg.Server.ExternalPort = envPort
-		log.Printf("Set external port from environment -> %s", cfg.Server.ExternalPort)
+		c.Server.ExternalPort = envPort
+		log.Printf("Set external port from environment -> %s", c.Server.ExternalPort)
 	} else {
-		log.Printf("Using external port from config -> %s", cfg.Server.ExternalPort)
+		log.Printf("Using external port from config -> %s", c.Server.ExternalPort)
 	}
 
-	if err := cfg.Validate(); err != nil {
-		log.Fatalf("Invalid configuration: %v", err)
+	if envAdminToken := os.Getenv("ADMIN_TOKEN"); envAdminToken != "" {
+		c.Admin.Token = envAdminToken
+		log.Printf("Set admin token from environment")
 	}
 
-	return cfg
+	if envSigningSecret := os.Getenv("FILE_SIGNING_SECRET"); envSigningSecret != "" {
+		c.Files.SigningSecret = envSigningSecret
+		log.Printf("Set file signing secret from environment")
+	}
+
+	if envTokenKeys := os.Getenv("MEMBER_TOKEN_KEYS"); envTokenKeys != "" {
+		c.Auth.TokenKeys = strings.Split(envTokenKeys, ",")
+		log.Printf("Set member token keys from environment")
+	}
+
+	if envClientSecret := os.Getenv("OIDC_CLIENT_SECRET"); envClientSecret != "" {
+		c.OIDC.ClientSecret = envClientSecret
+		log.Printf("Set OIDC client secret from environment")
+	}
+
+	if envBrokerToken := os.Getenv("BROKER_TOKEN"); envBrokerToken != "" {
+		c.Events.BrokerToken = envBrokerToken
+		log.Printf("Set broker token from environment")
+	}
+
+	if envAccessKey := os.Getenv("S3_ACCESS_KEY"); envAccessKey != "" {
+		c.Storage.S3.AccessKey = envAccessKey
+		c.Storage.S3.SecretKey = os.Getenv("S3_SECRET_KEY")
+		log.Printf("Set s3 credentials from environment")
+	}
 }
 
 func ParseConfig(v *viper.Viper) (*Config, error) {
@@ -110,6 +413,7 @@ func ParseConfig(v *viper.Viper) (*Config, error) {
 		log.Printf("Unable to parse config: %v", err)
 		return nil, err
 	}
+	cfg.source = v
 	return &cfg, nil
 }
 
@@ -130,7 +434,12 @@ func LoadConfig(filename string, fileType string) (*viper.Viper, error) {
 		v.AddConfigPath(filepath.Join(wd, "infrastructure", "config"))
 	}
 
+	// Every key can be set from the environment, VISPER_ then its path in capitals with
+	// underscores: redis.host is VISPER_REDIS_HOST. Lists are comma separated.
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
+	bindEnv(v, reflect.TypeOf(Config{}), "")
 
 	err := v.ReadInConfig()
 	if err != nil {
@@ -146,6 +455,24 @@ func LoadConfig(filename string, fileType string) (*viper.Viper, error) {
 	return v, nil
 }
 
+// bindEnv binds every key of t, so keys the file leaves out are still read from the
+// environment when unmarshalling
+func bindEnv(v *viper.Viper, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key := prefix + strings.ToLower(field.Name)
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			bindEnv(v, field.Type, key+".")
+			continue
+		}
+		_ = v.BindEnv(key)
+	}
+}
+
 func getConfigPath(env string) string {
 	switch env {
 	case "docker":
@@ -157,36 +484,191 @@ func getConfigPath(env string) string {
 	}
 }
 
-// Validate checks if the configuration is valid
+// Validate checks if the configuration is valid, reporting every problem at once
 func (c *Config) Validate() error {
+	var errs []error
+
 	if c.Server.InternalPort == "" {
-		return errors.New("server.internalPort is required")
+		errs = append(errs, errors.New("server.internalPort is required"))
 	}
 	if c.Server.ExternalPort == "" {
-		return errors.New("server.externalPort is required")
+		errs = append(errs, errors.New("server.externalPort is required"))
 	}
 	if c.Server.Domain == "" {
-		return errors.New("server.domain is required")
+		errs = append(errs, errors.New("server.domain is required"))
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trustedProxies %q is not an address or CIDR", proxy))
+		}
 	}
 
 	if c.Postgres.Host == "" {
-		return errors.New("postgres.host is required")
+		errs = append(errs, errors.New("postgres.host is required"))
 	}
 	if c.Postgres.Port == "" {
-		return errors.New("postgres.port is required")
+		errs = append(errs, errors.New("postgres.port is required"))
 	}
 	if c.Postgres.DbName == "" {
-		return errors.New("postgres.dbName is required")
+		errs = append(errs, errors.New("postgres.dbName is required"))
+	}
+
+	switch c.Redis.Mode {
+	case "", "standalone":
+		if c.Redis.Host == "" {
+			errs = append(errs, errors.New("redis.host is required"))
+		}
+		if c.Redis.Port == "" {
+			errs = append(errs, errors.New("redis.port is required"))
+		}
+	case "cluster":
+		if len(c.Redis.Addrs) == 0 {
+			errs = append(errs, errors.New("redis.addrs is required in cluster mode"))
+		}
+	case "sentinel":
+		if len(c.Redis.Addrs) == 0 || c.Redis.MasterName == "" {
+			errs = append(errs, errors.New("redis.addrs and redis.masterName are required in sentinel mode"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("redis.mode %q is not one of standalone, cluster or sentinel", c.Redis.Mode))
 	}
 
-	if c.Redis.Host == "" {
-		return errors.New("redis.host is required")
+	if c.OIDC.Enabled {
+		if c.OIDC.Issuer == "" || c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "" {
+			errs = append(errs, errors.New("oidc.issuer, oidc.clientID and oidc.redirectURL are required when oidc is enabled"))
+		}
 	}
-	if c.Redis.Port == "" {
-		return errors.New("redis.port is required")
+
+	switch c.Logger.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logger.level %q is not one of debug, info, warn or error", c.Logger.Level))
 	}
 
-	return nil
+	switch c.RateLimit.Algorithm {
+	case "", "sliding_window", "token_bucket", "leaky_bucket":
+	default:
+		errs = append(errs, fmt.Errorf("rateLimit.algorithm %q is not supported", c.RateLimit.Algorithm))
+	}
+	if c.RateLimit.BurstRatio < 0 {
+		errs = append(errs, errors.New("rateLimit.burstRatio must not be negative"))
+	}
+
+	if c.Cluster.Bus != "" && c.Cluster.Bus != "redis" && c.Cluster.Bus != "nats" {
+		errs = append(errs, fmt.Errorf("cluster.bus %q is not supported", c.Cluster.Bus))
+	}
+	if c.Events.Transport != "" && c.Events.Transport != "broker" && c.Events.Transport != "nats" {
+		errs = append(errs, fmt.Errorf("events.transport %q is not supported", c.Events.Transport))
+	}
+	if len(c.Events.BrokerNodes) > 0 {
+		if c.Events.BrokerListen == "" {
+			errs = append(errs, errors.New("events.brokerListen is required when events.brokerNodes is set"))
+		}
+		if c.Events.BrokerNodeID < 1 || c.Events.BrokerNodeID > len(c.Events.BrokerNodes) {
+			errs = append(errs, fmt.Errorf("events.brokerNodeId must be between 1 and %d", len(c.Events.BrokerNodes)))
+		}
+	}
+	if (c.Cluster.Bus == "nats" || c.Events.Transport == "nats") && c.NATS.URL == "" {
+		errs = append(errs, errors.New("nats.url is required when nats is used"))
+	}
+
+	switch c.Storage.Backend {
+	case "", "local":
+	case "s3":
+		if c.Storage.S3.Endpoint == "" || c.Storage.S3.Bucket == "" {
+			errs = append(errs, errors.New("storage.s3.endpoint and storage.s3.bucket are required when storage.backend is s3"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend %q is not supported", c.Storage.Backend))
+	}
+
+	for name, backend := range map[string]string{
+		"backend":  c.Persistence.Backend,
+		"rooms":    c.Persistence.Rooms,
+		"messages": c.Persistence.Messages,
+		"users":    c.Persistence.Users,
+	} {
+		if backend != "" && backend != "redis" && backend != "postgres" {
+			errs = append(errs, fmt.Errorf("persistence.%s %q is not supported", name, backend))
+		}
+	}
+
+	if c.Scanner.Backend != "" && c.Scanner.Backend != "clamav" && c.Scanner.Backend != "none" {
+		errs = append(errs, fmt.Errorf("scanner.backend %q is not supported", c.Scanner.Backend))
+	}
+
+	for _, threshold := range c.RoomExpiry.WarnBefore {
+		if threshold <= 0 {
+			errs = append(errs, fmt.Errorf("roomExpiry.warnBefore %s must be positive", threshold))
+		}
+	}
+
+	switch c.Logger.Encoding {
+	case "", "json", "console":
+	default:
+		errs = append(errs, fmt.Errorf("logger.encoding %q is not supported", c.Logger.Encoding))
+	}
+	if c.Logger.SamplingInitial < 0 || c.Logger.SamplingThereafter < 0 {
+		errs = append(errs, errors.New("logger sampling must not be negative"))
+	}
+	for _, pair := range c.Logger.Levels {
+		if name, _, ok := strings.Cut(pair, "="); !ok || name == "" {
+			errs = append(errs, fmt.Errorf("logger.levels entry %q should be name=level", pair))
+		}
+	}
+
+	if c.Audit.Retention < 0 {
+		errs = append(errs, fmt.Errorf("audit.retention %s must not be negative", c.Audit.Retention))
+	}
+
+	if c.Profiler.Retain < 0 {
+		errs = append(errs, errors.New("profiler.retain must not be negative"))
+	}
+	if c.Profiler.CPUPercent < 0 || c.Profiler.HeapMB < 0 || c.Profiler.P99LatencyMs < 0 {
+		errs = append(errs, errors.New("profiler thresholds must not be negative"))
+	}
+
+	if c.Cache.SnapshotInterval < 0 {
+		errs = append(errs, fmt.Errorf("cache.snapshotInterval %s must not be negative", c.Cache.SnapshotInterval))
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "jaeger", "otlp-grpc", "otlp-http", "none":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q is not supported", c.Tracing.Exporter))
+	}
+	switch c.Tracing.Sampler {
+	case "", "always", "never":
+	case "ratio":
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			errs = append(errs, errors.New("tracing.sampleRatio must be between 0 and 1"))
+		}
+	case "ratelimited":
+		if c.Tracing.SamplesPerSecond <= 0 {
+			errs = append(errs, errors.New("tracing.samplesPerSecond must be positive when tracing.sampler is ratelimited"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.sampler %q is not supported", c.Tracing.Sampler))
+	}
+	for name, pairs := range map[string][]string{
+		"headers":            c.Tracing.Headers,
+		"resourceAttributes": c.Tracing.ResourceAttributes,
+	} {
+		for _, pair := range pairs {
+			if key, _, ok := strings.Cut(pair, "="); !ok || key == "" {
+				errs = append(errs, fmt.Errorf("tracing.%s %q is not a key=value pair", name, pair))
+			}
+		}
+	}
+
+	if _, err := parseOptionalTime(c.API.V1DeprecatedAt); err != nil {
+		errs = append(errs, fmt.Errorf("api.v1DeprecatedAt: %w", err))
+	}
+	if _, err := parseOptionalTime(c.API.V1SunsetAt); err != nil {
+		errs = append(errs, fmt.Errorf("api.v1SunsetAt: %w", err))
+	}
+
+	return errors.Join(errs...)
 }
 
 func (c *Config) IsDevelopment() bool {
@@ -220,3 +702,17 @@ func (c *Config) GetServerAddress() string {
 func (c *Config) GetFrontEndURL() string {
 	return c.Server.FrontEndURL
 }
+
+// V1Lifecycle returns when v1 was deprecated and when it goes away, zero when not scheduled
+func (c *Config) V1Lifecycle() (deprecatedAt, sunsetAt time.Time) {
+	deprecatedAt, _ = parseOptionalTime(c.API.V1DeprecatedAt)
+	sunsetAt, _ = parseOptionalTime(c.API.V1SunsetAt)
+	return deprecatedAt, sunsetAt
+}
+
+func parseOptionalTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
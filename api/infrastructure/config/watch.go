@@ -0,0 +1,70 @@
+package config
+
+import (
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const envPrefix = "VISPER"
+
+// reloadable are the sections Watch hands over, everything else only changes on restart
+var reloadable = map[string]bool{
+	"RateLimit": true,
+	"Cors":      true,
+	"Logger":    true,
+}
+
+// Watch reloads the config file whenever it's written and calls onReload with the new
+// config. Only the rate limits, the CORS origins and the log level are meant to be
+// applied from it, changes to other sections are logged as needing a restart. A file
+// that no longer validates is skipped, the running config stays.
+func (c *Config) Watch(onReload func(*Config)) {
+	if c.source == nil {
+		return
+	}
+
+	var mu sync.Mutex
+	current := c
+	c.source.OnConfigChange(func(event fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		reloaded, err := ParseConfig(c.source)
+		if err != nil {
+			log.Printf("Config reload of %s skipped: %v", event.Name, err)
+			return
+		}
+		reloaded.applyEnvOverrides()
+		if err := reloaded.Validate(); err != nil {
+			log.Printf("Config reload of %s skipped, invalid configuration:\n%v", event.Name, err)
+			return
+		}
+
+		if sections := current.restartRequired(reloaded); len(sections) > 0 {
+			log.Printf("Config sections %v changed, they apply on restart", sections)
+		}
+		current = reloaded
+		onReload(reloaded)
+	})
+	c.source.WatchConfig()
+}
+
+// restartRequired names the sections other than the reloadable ones that differ
+func (c *Config) restartRequired(other *Config) []string {
+	var sections []string
+
+	current, next := reflect.ValueOf(*c), reflect.ValueOf(*other)
+	for i := 0; i < current.NumField(); i++ {
+		field := current.Type().Field(i)
+		if !field.IsExported() || reloadable[field.Name] {
+			continue
+		}
+		if !reflect.DeepEqual(current.Field(i).Interface(), next.Field(i).Interface()) {
+			sections = append(sections, field.Name)
+		}
+	}
+	return sections
+}
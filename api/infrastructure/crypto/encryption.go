@@ -31,7 +31,7 @@ func GenerateKey() ([]byte, error) {
 func GenerateKeyBase64() (string, error) {
 	key, err := GenerateKey()
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	return base64.StdEncoding.EncodeToString(key), nil
@@ -91,6 +91,13 @@ func Decrypt(ciphertextB64 string, keyBytes []byte) (string, error) {
 	return string(decrypted), nil
 }
 
+// IsCiphertext checks content has the shape Encrypt produces without needing the key,
+// so a client can't flag plaintext as encrypted to slip past moderation
+func IsCiphertext(content string) bool {
+	ciphertext, err := base64.StdEncoding.DecodeString(content)
+	return err == nil && len(ciphertext) >= NonceSize+secretbox.Overhead
+}
+
 // EncryptWithKeyB64 encrypts using a base64-encoded key
 func EncryptWithKeyB64(plaintext, keyB64 string) (string, error) {
 	keyBytes, err := base64.StdEncoding.DecodeString(keyB64)
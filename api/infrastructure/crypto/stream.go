@@ -0,0 +1,246 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	SaltSize = 16
+
+	// streamChunkSize is the plaintext size sealed per secretbox frame
+	streamChunkSize = 64 * 1024
+)
+
+var ErrStreamClosed = errors.New("encrypt stream already closed")
+
+// DeriveKey stretches a user passphrase into a secretbox key
+func DeriveKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, 1, 64*1024, 4, KeySize)
+}
+
+func GenerateSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// A stream is a random nonce prefix followed by length-prefixed secretbox frames,
+// [prefix][uint32 length][box]... Each frame's nonce is the prefix, a big-endian frame
+// counter and a last-frame flag, so frames can't be reordered, dropped or cut off without
+// failing to open. The stream always ends with a last frame, even an empty one.
+const (
+	streamPrefixSize  = 16
+	streamCounterSize = NonceSize - streamPrefixSize - 1
+
+	// maxStreamFrame bounds what a reader allocates for one frame
+	maxStreamFrame = streamChunkSize + secretbox.Overhead
+)
+
+var (
+	ErrStreamTruncated = errors.New("encrypted stream is truncated")
+	ErrStreamTooLong   = errors.New("encrypted stream has too many frames")
+)
+
+// streamNonce builds the nonce for frame counter of the stream with prefix
+func streamNonce(prefix *[streamPrefixSize]byte, counter uint64, last bool) ([NonceSize]byte, error) {
+	var nonce [NonceSize]byte
+	if counter >= 1<<(8*streamCounterSize) {
+		return nonce, ErrStreamTooLong
+	}
+
+	copy(nonce[:], prefix[:])
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], counter)
+	copy(nonce[streamPrefixSize:], c[8-streamCounterSize:])
+	if last {
+		nonce[NonceSize-1] = 1
+	}
+	return nonce, nil
+}
+
+// StreamWriter seals everything written to it as a stream of secretbox frames
+type StreamWriter struct {
+	w       io.Writer
+	key     [KeySize]byte
+	prefix  [streamPrefixSize]byte
+	counter uint64
+	started bool
+	buf     []byte
+	closed  bool
+}
+
+func NewStreamWriter(w io.Writer, keyBytes []byte) (*StreamWriter, error) {
+	if len(keyBytes) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	sw := &StreamWriter{
+		w:   w,
+		buf: make([]byte, 0, streamChunkSize),
+	}
+	copy(sw.key[:], keyBytes)
+	if _, err := rand.Read(sw.prefix[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return sw, nil
+}
+
+func (sw *StreamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, ErrStreamClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data arrives, Close has to be able to
+		// mark it as the last frame
+		if len(sw.buf) == streamChunkSize {
+			if err := sw.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := min(streamChunkSize-len(sw.buf), len(p))
+		sw.buf = append(sw.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close seals the last frame, it does not close the underlying writer
+func (sw *StreamWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+
+	return sw.flush(true)
+}
+
+func (sw *StreamWriter) flush(last bool) error {
+	nonce, err := streamNonce(&sw.prefix, sw.counter, last)
+	if err != nil {
+		return err
+	}
+
+	if !sw.started {
+		if _, err := sw.w.Write(sw.prefix[:]); err != nil {
+			return err
+		}
+		sw.started = true
+	}
+
+	frame := secretbox.Seal(nil, sw.buf, &nonce, &sw.key)
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(frame)))
+
+	if _, err := sw.w.Write(length[:]); err != nil {
+		return err
+	}
+	if _, err := sw.w.Write(frame); err != nil {
+		return err
+	}
+
+	sw.counter++
+	sw.buf = sw.buf[:0]
+	return nil
+}
+
+// StreamReader opens a stream written by StreamWriter. Reads fail with ErrDecryptionFailed
+// on a tampered or reordered frame and ErrStreamTruncated if the last frame is missing.
+type StreamReader struct {
+	r       io.Reader
+	key     [KeySize]byte
+	prefix  [streamPrefixSize]byte
+	counter uint64
+	started bool
+	buf     []byte
+	done    bool
+}
+
+func NewStreamReader(r io.Reader, keyBytes []byte) (*StreamReader, error) {
+	if len(keyBytes) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	sr := &StreamReader{r: r}
+	copy(sr.key[:], keyBytes)
+
+	return sr, nil
+}
+
+func (sr *StreamReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.done {
+			return 0, io.EOF
+		}
+		if err := sr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+func (sr *StreamReader) next() error {
+	if !sr.started {
+		if _, err := io.ReadFull(sr.r, sr.prefix[:]); err != nil {
+			return truncated(err)
+		}
+		sr.started = true
+	}
+
+	var length [4]byte
+	if _, err := io.ReadFull(sr.r, length[:]); err != nil {
+		return truncated(err)
+	}
+
+	size := binary.BigEndian.Uint32(length[:])
+	if size < secretbox.Overhead || size > maxStreamFrame {
+		return ErrInvalidCiphertext
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(sr.r, frame); err != nil {
+		return truncated(err)
+	}
+
+	// Try the frame as a middle one first, then as the last
+	for _, last := range []bool{false, true} {
+		nonce, err := streamNonce(&sr.prefix, sr.counter, last)
+		if err != nil {
+			return err
+		}
+
+		if plain, ok := secretbox.Open(nil, frame, &nonce, &sr.key); ok {
+			sr.counter++
+			sr.buf = plain
+			sr.done = last
+			return nil
+		}
+	}
+
+	return ErrDecryptionFailed
+}
+
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrStreamTruncated
+	}
+	return err
+}
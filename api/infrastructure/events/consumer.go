@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/hilthontt/visper/api/domain/model"
@@ -15,12 +14,42 @@ import (
 
 // EventConsumer consumes and processes events
 type EventConsumer struct {
-	consumer           *broker.Consumer
+	source             eventSource
 	handlers           map[EventType]EventHandler
 	stopCh             chan struct{}
+	done               chan struct{} // Closed when Start returns
 	auditLogRepository repository.AuditLogRepository
 }
 
+// eventSource is the transport events are read from. done is called once per event after it
+// was handled, with false when it should be delivered again.
+type eventSource interface {
+	fetch() ([]sourcedEvent, error)
+}
+
+type sourcedEvent struct {
+	value []byte
+	done  func(handled bool)
+}
+
+type brokerSource struct {
+	consumer *broker.Consumer
+}
+
+// The broker commits offsets on poll, so there is nothing to redeliver
+func (s *brokerSource) fetch() ([]sourcedEvent, error) {
+	records, err := s.consumer.Poll(100) // 100ms timeout
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]sourcedEvent, len(records))
+	for i, record := range records {
+		events[i] = sourcedEvent{value: record.Value, done: func(bool) {}}
+	}
+	return events, nil
+}
+
 // EventHandler is a function that handles a specific event type
 type EventHandler func(event *Event) error
 
@@ -33,10 +62,15 @@ func NewEventConsumer(brokerInstance *broker.Broker, groupID, topic string, audi
 		return nil, fmt.Errorf("failed to subscribe to topic: %w", err)
 	}
 
+	return newEventConsumer(&brokerSource{consumer: consumer}, auditLogRepository), nil
+}
+
+func newEventConsumer(source eventSource, auditLogRepository repository.AuditLogRepository) *EventConsumer {
 	ec := &EventConsumer{
-		consumer:           consumer,
+		source:             source,
 		handlers:           make(map[EventType]EventHandler),
 		stopCh:             make(chan struct{}),
+		done:               make(chan struct{}),
 		auditLogRepository: auditLogRepository,
 	}
 
@@ -46,8 +80,12 @@ func NewEventConsumer(brokerInstance *broker.Broker, groupID, topic string, audi
 	ec.RegisterHandler(EventMessageSent, ec.handleMessageSent)
 	ec.RegisterHandler(EventRoomExpired, ec.handleRoomExpired)
 	ec.RegisterHandler(EventUserLeft, ec.handleUserLeft)
+	ec.RegisterHandler(EventUserPurged, ec.handleUserPurged)
+	ec.RegisterHandler(EventMessageFlagged, ec.handleMessageFlagged)
+	ec.RegisterHandler(EventAdminAction, ec.handleAdminAction)
+	ec.RegisterHandler(EventAPIAction, ec.handleAPIAction)
 
-	return ec, nil
+	return ec
 }
 
 // RegisterHandler registers a handler for a specific event type
@@ -57,27 +95,30 @@ func (ec *EventConsumer) RegisterHandler(eventType EventType, handler EventHandl
 
 // Start starts consuming events
 func (ec *EventConsumer) Start() {
-	log.Println("Event consumer started")
+	defer close(ec.done)
+	log.Info("Event consumer started")
 
 	for {
 		select {
 		case <-ec.stopCh:
-			log.Println("Event consumer stopped")
+			log.Info("Event consumer stopped")
 			return
 		default:
 			// Poll for new messages
-			records, err := ec.consumer.Poll(100) // 100ms timeout
+			records, err := ec.source.fetch()
 			if err != nil {
-				log.Printf("Error polling messages: %v", err)
+				log.Errorf("Error polling messages: %v", err)
 				time.Sleep(1 * time.Second)
 				continue
 			}
 
 			// Process each record
 			for _, record := range records {
-				if err := ec.processRecord(record); err != nil {
-					log.Printf("Error processing record: %v", err)
+				audited, err := ec.processRecord(record.value)
+				if err != nil {
+					log.Errorf("Error processing record: %v", err)
 				}
+				record.done(audited)
 			}
 
 			// Small delay if no messages
@@ -88,42 +129,61 @@ func (ec *EventConsumer) Start() {
 	}
 }
 
-// Stop stops the consumer
-func (ec *EventConsumer) Stop() {
+// Stop stops the consumer and waits until the records already fetched are processed,
+// or until ctx is done
+func (ec *EventConsumer) Stop(ctx context.Context) error {
 	close(ec.stopCh)
+
+	select {
+	case <-ec.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// processRecord processes a single consumer record
-func (ec *EventConsumer) processRecord(record *broker.ConsumerRecord) error {
-	var event Event
-	if err := json.Unmarshal(record.Value, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal event: %w", err)
+// processRecord processes a single consumer record. It reports false when the audit log
+// could not be written, a durable transport then redelivers the event.
+func (ec *EventConsumer) processRecord(value []byte) (bool, error) {
+	// Redelivering a malformed or invalid event would never succeed
+	envelope, err := decodeEnvelope(value)
+	if err != nil {
+		return true, err
+	}
+	if err := schemas.upcast(envelope); err != nil {
+		return true, err
 	}
+	if err := schemas.validate(envelope); err != nil {
+		return true, err
+	}
+
+	event := envelope.event()
 
 	handler, exists := ec.handlers[event.Type]
 	if !exists {
-		log.Printf("No handler registered for event type: %s", event.Type)
-		return nil
+		log.Warnf("No handler registered for event type: %s", event.Type)
+		return true, nil
 	}
 
-	handlerErr := handler(&event)
-	if err := ec.writeAuditLog(&event, handlerErr); err != nil {
-		log.Printf("Failed to write audit log for event %s: %v", event.ID, err)
+	handlerErr := handler(event)
+	if err := ec.writeAuditLog(envelope.Context(), event, handlerErr); err != nil {
+		log.Errorf("Failed to write audit log for event %s: %v", event.ID, err)
+		return false, handlerErr
 	}
 
-	return handlerErr
+	return true, handlerErr
 }
 
 func (ec *EventConsumer) handleRoomCreated(event *Event) error {
 	expiresIn := event.Data["expires_in_seconds"]
-	log.Printf("Room created: %s by user %s (expires in %.0f seconds)",
+	log.Infof("Room created: %s by user %s (expires in %.0f seconds)",
 		event.RoomID, event.UserID, expiresIn)
 
 	return nil
 }
 
 func (ec *EventConsumer) handleRoomJoined(event *Event) error {
-	log.Printf("User %s joined room %s", event.UserID, event.RoomID)
+	log.Infof("User %s joined room %s", event.UserID, event.RoomID)
 
 	return nil
 }
@@ -132,7 +192,7 @@ func (ec *EventConsumer) handleMessageSent(event *Event) error {
 	messageID := event.Data["message_id"]
 	messageSize := event.Data["message_size"]
 
-	log.Printf("Message sent in room %s by user %s (id: %s, size: %v bytes)",
+	log.Infof("Message sent in room %s by user %s (id: %s, size: %v bytes)",
 		event.RoomID, event.UserID, messageID, messageSize)
 
 	return nil
@@ -140,18 +200,44 @@ func (ec *EventConsumer) handleMessageSent(event *Event) error {
 
 func (ec *EventConsumer) handleRoomExpired(event *Event) error {
 	messageCount := event.Data["message_count"]
-	log.Printf("Room expired: %s (total messages: %v)", event.RoomID, messageCount)
+	log.Infof("Room expired: %s (total messages: %v)", event.RoomID, messageCount)
 
 	return nil
 }
 
 func (ec *EventConsumer) handleUserLeft(event *Event) error {
-	log.Printf("User %s left room %s", event.UserID, event.RoomID)
+	log.Infof("User %s left room %s", event.UserID, event.RoomID)
+
+	return nil
+}
+
+func (ec *EventConsumer) handleMessageFlagged(event *Event) error {
+	log.Infof("Message flagged in room %s by user %s (id: %v, mode: %v, terms: %v)",
+		event.RoomID, event.UserID, event.Data["message_id"], event.Data["mode"], event.Data["terms"])
+
+	return nil
+}
+
+func (ec *EventConsumer) handleUserPurged(event *Event) error {
+	log.Infof("User %s purged their data (messages: %v, files: %v, rooms deleted: %v)",
+		event.UserID, event.Data["messages_deleted"], event.Data["files_deleted"], event.Data["rooms_deleted"])
+
+	return nil
+}
+
+func (ec *EventConsumer) handleAdminAction(event *Event) error {
+	log.Infof("Admin %s: %v (status: %v)", event.UserID, event.Data["action"], event.Data["status"])
+
+	return nil
+}
+
+func (ec *EventConsumer) handleAPIAction(event *Event) error {
+	log.Infof("API %s by %q: %v (outcome: %v)", event.Data["action"], event.UserID, event.Data["entity_id"], event.Data["outcome"])
 
 	return nil
 }
 
-func (ec *EventConsumer) writeAuditLog(event *Event, handlerErr error) error {
+func (ec *EventConsumer) writeAuditLog(ctx context.Context, event *Event, handlerErr error) error {
 	payload, err := json.Marshal(event.Data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event data: %w", err)
@@ -174,7 +260,7 @@ func (ec *EventConsumer) writeAuditLog(event *Event, handlerErr error) error {
 		entry.ErrorMessage = sql.NullString{Valid: true, String: handlerErr.Error()}
 	}
 
-	_, err = ec.auditLogRepository.CreateAuditLog(context.Background(), entry)
+	_, err = ec.auditLogRepository.CreateAuditLog(ctx, entry)
 	if err != nil {
 		return err
 	}
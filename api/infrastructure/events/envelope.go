@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Envelope is the wire format of an event. Version is the version of the event type's
+// schema the data was written with, consumers upcast older versions before handling them.
+type Envelope struct {
+	ID         string    `json:"id"`
+	Type       EventType `json:"type"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// Trace is the W3C trace context (traceparent, tracestate) of the request that published the event
+	Trace  map[string]string `json:"trace,omitempty"`
+	UserID string            `json:"user_id,omitempty"`
+	RoomID string            `json:"room_id,omitempty"`
+	Data   map[string]any    `json:"data,omitempty"`
+}
+
+var traceContext = propagation.TraceContext{}
+
+func newEnvelope(ctx context.Context, event *Event, version int) *Envelope {
+	trace := propagation.MapCarrier{}
+	traceContext.Inject(ctx, trace)
+	if len(trace) == 0 {
+		trace = nil
+	}
+
+	return &Envelope{
+		ID:         event.ID,
+		Type:       event.Type,
+		Version:    version,
+		OccurredAt: event.Timestamp,
+		Trace:      trace,
+		UserID:     event.UserID,
+		RoomID:     event.RoomID,
+		Data:       event.Data,
+	}
+}
+
+// decodeEnvelope also reads events published before envelopes, they had no version
+// and a timestamp instead of occurred_at, their data is version 1 of each schema
+func decodeEnvelope(value []byte) (*Envelope, error) {
+	var raw struct {
+		Envelope
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(value, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	envelope := raw.Envelope
+	if envelope.Version == 0 {
+		envelope.Version = 1
+		envelope.OccurredAt = raw.Timestamp
+	}
+
+	return &envelope, nil
+}
+
+// Context carries the publisher's trace so work done for the event joins its trace
+func (e *Envelope) Context() context.Context {
+	return traceContext.Extract(context.Background(), propagation.MapCarrier(e.Trace))
+}
+
+func (e *Envelope) event() *Event {
+	return &Event{
+		ID:        e.ID,
+		Type:      e.Type,
+		Timestamp: e.OccurredAt,
+		UserID:    e.UserID,
+		RoomID:    e.RoomID,
+		Data:      e.Data,
+	}
+}
@@ -6,12 +6,16 @@ import "time"
 type EventType string
 
 const (
-	EventRoomCreated EventType = "room.created"
-	EventRoomJoined  EventType = "room.joined"
-	EventMessageSent EventType = "message.sent"
-	EventRoomExpired EventType = "room.expired"
-	EventUserLeft    EventType = "user.left"
-	EventRoomDeleted EventType = "room.deleted"
+	EventRoomCreated    EventType = "room.created"
+	EventRoomJoined     EventType = "room.joined"
+	EventMessageSent    EventType = "message.sent"
+	EventRoomExpired    EventType = "room.expired"
+	EventUserLeft       EventType = "user.left"
+	EventRoomDeleted    EventType = "room.deleted"
+	EventUserPurged     EventType = "user.purged"
+	EventMessageFlagged EventType = "message.flagged"
+	EventAdminAction    EventType = "admin.action"
+	EventAPIAction      EventType = "api.action"
 )
 
 // Event represents a Visper application event
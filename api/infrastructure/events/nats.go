@@ -0,0 +1,108 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsStreamName  = "VISPER_EVENTS"
+	natsSubject     = "visper.events"
+	natsMaxDeliver  = 5
+	natsFetchBatch  = 50
+	natsFetchMaxAge = 7 * 24 * time.Hour
+)
+
+// NewNATSJetStream opens JetStream on the connection and makes sure the event stream exists
+func NewNATSJetStream(ctx context.Context, conn *nats.Conn) (jetstream.JetStream, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open jetstream: %w", err)
+	}
+
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     natsStreamName,
+		Subjects: []string{natsSubject},
+		MaxAge:   natsFetchMaxAge,
+		Storage:  jetstream.FileStorage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream %s: %w", natsStreamName, err)
+	}
+
+	return js, nil
+}
+
+type natsSink struct {
+	js jetstream.JetStream
+}
+
+// Events are published to JetStream so they survive until the audit consumer acks them
+func (s *natsSink) send(key, value []byte, _ time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg := nats.NewMsg(natsSubject)
+	msg.Data = value
+	msg.Header.Set("Visper-Room-ID", string(key))
+
+	_, err := s.js.PublishMsg(ctx, msg)
+	return err
+}
+
+// NewNATSEventPublisher publishes events to the JetStream stream created by NewNATSJetStream
+func NewNATSEventPublisher(js jetstream.JetStream) *EventPublisher {
+	return &EventPublisher{sink: &natsSink{js: js}}
+}
+
+type natsSource struct {
+	consumer jetstream.Consumer
+}
+
+func (s *natsSource) fetch() ([]sourcedEvent, error) {
+	batch, err := s.consumer.Fetch(natsFetchBatch, jetstream.FetchMaxWait(time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	var events []sourcedEvent
+	for msg := range batch.Messages() {
+		events = append(events, sourcedEvent{
+			value: msg.Data(),
+			done: func(handled bool) {
+				if handled {
+					_ = msg.Ack()
+					return
+				}
+				_ = msg.Nak()
+			},
+		})
+	}
+
+	if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+		return events, err
+	}
+	return events, nil
+}
+
+// NewNATSEventConsumer reads through a durable JetStream consumer, an event is only acked once its
+// audit log entry is written, so a crash or a database outage redelivers it instead of losing it
+func NewNATSEventConsumer(ctx context.Context, js jetstream.JetStream, durable string, auditLogRepository repository.AuditLogRepository) (*EventConsumer, error) {
+	consumer, err := js.CreateOrUpdateConsumer(ctx, natsStreamName, jetstream.ConsumerConfig{
+		Durable:    durable,
+		AckPolicy:  jetstream.AckExplicitPolicy,
+		AckWait:    30 * time.Second,
+		MaxDeliver: natsMaxDeliver,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer %s: %w", durable, err)
+	}
+
+	return newEventConsumer(&natsSource{consumer: consumer}, auditLogRepository), nil
+}
@@ -1,19 +1,41 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/hilthontt/visper/api/infrastructure/broker"
 )
 
-// EventPublisher publishes Visper events to the broker
+// EventPublisher publishes Visper events to the configured transport
 type EventPublisher struct {
+	sink eventSink
+}
+
+// eventSink is the transport an encoded event is written to, keyed by room so a room's events stay in order
+type eventSink interface {
+	send(key, value []byte, timestamp time.Time) error
+}
+
+type brokerSink struct {
 	producer *broker.Producer
 	topic    string
 }
 
+func (s *brokerSink) send(key, value []byte, timestamp time.Time) error {
+	_, _, err := s.producer.Produce(s.topic, &broker.Message{
+		Key:       key,
+		Value:     value,
+		Timestamp: timestamp,
+	})
+	return err
+}
+
 // NewEventPublisher creates a new event publisher
 func NewEventPublisher(brokerInstance *broker.Broker, topic string) (*EventPublisher, error) {
 	// Create topic if it doesn't exist
@@ -28,31 +50,34 @@ func NewEventPublisher(brokerInstance *broker.Broker, topic string) (*EventPubli
 	producer := broker.NewProducer(brokerInstance, 1)
 
 	return &EventPublisher{
-		producer: producer,
-		topic:    topic,
+		sink: &brokerSink{producer: producer, topic: topic},
 	}, nil
 }
 
-// Publish publishes an event to the broker
-func (ep *EventPublisher) Publish(event *Event) error {
+// Publish validates the event against its schema and publishes it in a versioned envelope,
+// the trace in ctx travels with it
+func (ep *EventPublisher) Publish(ctx context.Context, event *Event) error {
 	// Set timestamp if not set
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
 	}
 
-	eventJSON, err := json.Marshal(event)
+	version, err := schemas.version(event.Type)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return err
 	}
 
-	msg := &broker.Message{
-		Key:       []byte(event.RoomID),
-		Value:     eventJSON,
-		Timestamp: event.Timestamp,
+	envelope := newEnvelope(ctx, event, version)
+	if err := schemas.validate(envelope); err != nil {
+		return err
 	}
 
-	_, _, err = ep.producer.Produce(ep.topic, msg)
+	eventJSON, err := json.Marshal(envelope)
 	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if err := ep.sink.send([]byte(event.RoomID), eventJSON, event.Timestamp); err != nil {
 		return fmt.Errorf("failed to produce event: %w", err)
 	}
 
@@ -60,7 +85,7 @@ func (ep *EventPublisher) Publish(event *Event) error {
 }
 
 // PublishRoomCreated publishes a room created event
-func (ep *EventPublisher) PublishRoomCreated(roomID, userID string, expiresIn time.Duration) error {
+func (ep *EventPublisher) PublishRoomCreated(ctx context.Context, roomID, userID string, expiresIn time.Duration) error {
 	event := &Event{
 		ID:     generateEventID(),
 		Type:   EventRoomCreated,
@@ -70,22 +95,22 @@ func (ep *EventPublisher) PublishRoomCreated(roomID, userID string, expiresIn ti
 			"expires_in_seconds": expiresIn.Seconds(),
 		},
 	}
-	return ep.Publish(event)
+	return ep.Publish(ctx, event)
 }
 
 // PublishRoomJoined publishes a room joined event
-func (ep *EventPublisher) PublishRoomJoined(roomID, userID string) error {
+func (ep *EventPublisher) PublishRoomJoined(ctx context.Context, roomID, userID string) error {
 	event := &Event{
 		ID:     generateEventID(),
 		Type:   EventRoomJoined,
 		UserID: userID,
 		RoomID: roomID,
 	}
-	return ep.Publish(event)
+	return ep.Publish(ctx, event)
 }
 
 // PublishMessageSent publishes a message sent event
-func (ep *EventPublisher) PublishMessageSent(roomID, userID, messageID string, messageSize int) error {
+func (ep *EventPublisher) PublishMessageSent(ctx context.Context, roomID, userID, messageID string, messageSize int) error {
 	event := &Event{
 		ID:     generateEventID(),
 		Type:   EventMessageSent,
@@ -96,11 +121,27 @@ func (ep *EventPublisher) PublishMessageSent(roomID, userID, messageID string, m
 			"message_size": messageSize,
 		},
 	}
-	return ep.Publish(event)
+	return ep.Publish(ctx, event)
+}
+
+// PublishMessageFlagged publishes a message flagged event, blocked messages carry an empty message ID
+func (ep *EventPublisher) PublishMessageFlagged(ctx context.Context, roomID, userID, messageID, mode string, terms []string) error {
+	event := &Event{
+		ID:     generateEventID(),
+		Type:   EventMessageFlagged,
+		UserID: userID,
+		RoomID: roomID,
+		Data: map[string]any{
+			"message_id": messageID,
+			"mode":       mode,
+			"terms":      terms,
+		},
+	}
+	return ep.Publish(ctx, event)
 }
 
 // PublishRoomExpired publishes a room expired event
-func (ep *EventPublisher) PublishRoomExpired(roomID string, messageCount int) error {
+func (ep *EventPublisher) PublishRoomExpired(ctx context.Context, roomID string, messageCount int) error {
 	event := &Event{
 		ID:     generateEventID(),
 		Type:   EventRoomExpired,
@@ -109,21 +150,59 @@ func (ep *EventPublisher) PublishRoomExpired(roomID string, messageCount int) er
 			"message_count": messageCount,
 		},
 	}
-	return ep.Publish(event)
+	return ep.Publish(ctx, event)
 }
 
 // PublishUserLeft publishes a user left event
-func (ep *EventPublisher) PublishUserLeft(roomID, userID string) error {
+func (ep *EventPublisher) PublishUserLeft(ctx context.Context, roomID, userID string) error {
 	event := &Event{
 		ID:     generateEventID(),
 		Type:   EventUserLeft,
 		UserID: userID,
 		RoomID: roomID,
 	}
-	return ep.Publish(event)
+	return ep.Publish(ctx, event)
+}
+
+// PublishUserPurged publishes a user purged event with the deletion counts
+func (ep *EventPublisher) PublishUserPurged(ctx context.Context, userID string, data map[string]any) error {
+	event := &Event{
+		ID:     generateEventID(),
+		Type:   EventUserPurged,
+		UserID: userID,
+		Data:   data,
+	}
+	return ep.Publish(ctx, event)
+}
+
+// PublishAdminAction publishes an operator's action through the admin API, actor is the
+// signed in admin or "admin-token"
+func (ep *EventPublisher) PublishAdminAction(ctx context.Context, actor, roomID string, data map[string]any) error {
+	event := &Event{
+		ID:     generateEventID(),
+		Type:   EventAdminAction,
+		UserID: actor,
+		RoomID: roomID,
+		Data:   data,
+	}
+	return ep.Publish(ctx, event)
+}
+
+// PublishAPIAction publishes a change made through the public API, actor is the user or
+// "bot:" and the bot's ID, and empty when the caller wasn't identified
+func (ep *EventPublisher) PublishAPIAction(ctx context.Context, actor, roomID string, data map[string]any) error {
+	event := &Event{
+		ID:     generateEventID(),
+		Type:   EventAPIAction,
+		UserID: actor,
+		RoomID: roomID,
+		Data:   data,
+	}
+	return ep.Publish(ctx, event)
 }
 
-// generateEventID generates a unique event ID
+// generateEventID generates a unique event ID. The audit log drops a repeated ID as a
+// redelivery, so it has to stay unique under concurrent requests, and fit its 36 characters.
 func generateEventID() string {
-	return fmt.Sprintf("evt_%d", time.Now().UnixNano())
+	return "evt_" + strings.ReplaceAll(uuid.NewString(), "-", "")
 }
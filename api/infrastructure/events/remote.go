@@ -0,0 +1,116 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/broker"
+)
+
+const (
+	remoteFetchBatch        = 100
+	remoteHeartbeatInterval = 10 * time.Second
+)
+
+type remoteSink struct {
+	client *broker.Client
+	topic  string
+}
+
+func (s *remoteSink) send(key, value []byte, timestamp time.Time) error {
+	_, _, err := s.client.Produce(s.topic, &broker.Message{
+		Key:       key,
+		Value:     value,
+		Timestamp: timestamp,
+	})
+	return err
+}
+
+// NewRemoteEventPublisher publishes to a broker running in another process
+func NewRemoteEventPublisher(client *broker.Client, topic string) (*EventPublisher, error) {
+	if err := client.CreateTopic(topic, 3); err != nil && !strings.Contains(err.Error(), "already exists") {
+		return nil, fmt.Errorf("failed to create topic: %w", err)
+	}
+
+	return &EventPublisher{sink: &remoteSink{client: client, topic: topic}}, nil
+}
+
+// remoteSource reads the partitions its group assigned it. Like the embedded consumer it
+// keeps offsets in memory, so a restarted instance reads its partitions from the start.
+type remoteSource struct {
+	client        *broker.Client
+	groupID       string
+	memberID      string
+	topic         string
+	partitions    []int
+	offsets       map[int]int64
+	joined        bool
+	lastHeartbeat time.Time
+}
+
+func (s *remoteSource) fetch() ([]sourcedEvent, error) {
+	if err := s.keepAssignment(); err != nil {
+		return nil, err
+	}
+
+	var events []sourcedEvent
+	for _, partition := range s.partitions {
+		records, next, err := s.client.Fetch(s.topic, partition, s.offsets[partition], remoteFetchBatch)
+		if err != nil {
+			return events, err
+		}
+		s.offsets[partition] = next
+
+		for _, record := range records {
+			events = append(events, sourcedEvent{value: record.Value, done: func(bool) {}})
+		}
+	}
+	return events, nil
+}
+
+// keepAssignment joins the group on first use and after being dropped from it,
+// heartbeats pick up partitions moved by a rebalance
+func (s *remoteSource) keepAssignment() error {
+	var (
+		assignment map[string][]int
+		err        error
+	)
+
+	switch {
+	case !s.joined:
+		assignment, err = s.client.JoinGroup(s.groupID, s.memberID, []string{s.topic})
+	case time.Since(s.lastHeartbeat) >= remoteHeartbeatInterval:
+		assignment, err = s.client.Heartbeat(s.groupID, s.memberID)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		s.joined = false
+		return err
+	}
+
+	s.joined = true
+	s.lastHeartbeat = time.Now()
+	s.partitions = assignment[s.topic]
+	return nil
+}
+
+// NewRemoteEventConsumer joins groupID on a broker running in another process, the
+// group spreads the topic's partitions over every instance consuming it
+func NewRemoteEventConsumer(client *broker.Client, groupID, topic string, auditLogRepository repository.AuditLogRepository) *EventConsumer {
+	hostname, _ := os.Hostname()
+
+	source := &remoteSource{
+		client:   client,
+		groupID:  groupID,
+		memberID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		topic:    topic,
+		offsets:  make(map[int]int64),
+	}
+
+	return newEventConsumer(source, auditLogRepository)
+}
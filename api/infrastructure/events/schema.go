@@ -0,0 +1,164 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	ErrUnknownEventType = errors.New("unknown event type")
+	ErrInvalidEvent     = errors.New("event does not match its schema")
+)
+
+type fieldKind int
+
+const (
+	fieldString fieldKind = iota
+	fieldNumber
+	fieldBool
+	fieldList
+)
+
+// Upcaster rewrites the data of one schema version into the next
+type Upcaster func(data map[string]any) map[string]any
+
+// Schema is the current version of an event type's data. Fields lists the data fields
+// every event of the type carries, others are allowed so adding one doesn't need a new version.
+type Schema struct {
+	Version int
+	Fields  map[string]fieldKind
+	// Upcasters[v] turns version v data into version v+1, one is needed for every version below Version
+	Upcasters map[int]Upcaster
+}
+
+// schemaRegistry is shared by the publisher and the consumer, a schema change bumps
+// Version and registers an upcaster from the previous one
+type schemaRegistry struct {
+	schemas map[EventType]Schema
+}
+
+var schemas = newSchemaRegistry()
+
+func newSchemaRegistry() *schemaRegistry {
+	r := &schemaRegistry{schemas: make(map[EventType]Schema)}
+
+	r.register(EventRoomCreated, Schema{Version: 1, Fields: map[string]fieldKind{
+		"expires_in_seconds": fieldNumber,
+	}})
+	r.register(EventRoomJoined, Schema{Version: 1})
+	r.register(EventMessageSent, Schema{Version: 1, Fields: map[string]fieldKind{
+		"message_id":   fieldString,
+		"message_size": fieldNumber,
+	}})
+	r.register(EventMessageFlagged, Schema{Version: 1, Fields: map[string]fieldKind{
+		"message_id": fieldString,
+		"mode":       fieldString,
+		"terms":      fieldList,
+	}})
+	r.register(EventRoomExpired, Schema{Version: 1, Fields: map[string]fieldKind{
+		"message_count": fieldNumber,
+	}})
+	r.register(EventUserLeft, Schema{Version: 1})
+	r.register(EventRoomDeleted, Schema{Version: 1})
+	r.register(EventUserPurged, Schema{Version: 1, Fields: map[string]fieldKind{
+		"messages_deleted": fieldNumber,
+		"files_deleted":    fieldNumber,
+		"rooms_deleted":    fieldNumber,
+	}})
+	r.register(EventAdminAction, Schema{Version: 1, Fields: map[string]fieldKind{
+		"action": fieldString,
+		"status": fieldNumber,
+	}})
+	r.register(EventAPIAction, Schema{Version: 1, Fields: map[string]fieldKind{
+		"action":  fieldString,
+		"status":  fieldNumber,
+		"outcome": fieldString,
+		"ip":      fieldString,
+	}})
+
+	return r
+}
+
+func (r *schemaRegistry) register(eventType EventType, schema Schema) {
+	for v := 1; v < schema.Version; v++ {
+		if schema.Upcasters[v] == nil {
+			panic(fmt.Sprintf("events: %s version %d has no upcaster to version %d", eventType, v, v+1))
+		}
+	}
+	r.schemas[eventType] = schema
+}
+
+func (r *schemaRegistry) version(eventType EventType) (int, error) {
+	schema, ok := r.schemas[eventType]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+	}
+	return schema.Version, nil
+}
+
+// upcast brings the envelope to the current version of its schema. Versions newer than
+// the registry knows are left alone, they come from an instance already running a later
+// release and only ever add fields.
+func (r *schemaRegistry) upcast(envelope *Envelope) error {
+	schema, ok := r.schemas[envelope.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownEventType, envelope.Type)
+	}
+
+	for envelope.Version < schema.Version {
+		upcaster, ok := schema.Upcasters[envelope.Version]
+		if !ok {
+			return fmt.Errorf("%w: %s has no upcaster from version %d", ErrInvalidEvent, envelope.Type, envelope.Version)
+		}
+		envelope.Data = upcaster(envelope.Data)
+		envelope.Version++
+	}
+
+	return nil
+}
+
+func (r *schemaRegistry) validate(envelope *Envelope) error {
+	schema, ok := r.schemas[envelope.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownEventType, envelope.Type)
+	}
+
+	if envelope.ID == "" || envelope.OccurredAt.IsZero() {
+		return fmt.Errorf("%w: %s is missing its id or occurred_at", ErrInvalidEvent, envelope.Type)
+	}
+
+	for name, kind := range schema.Fields {
+		value, ok := envelope.Data[name]
+		if !ok {
+			return fmt.Errorf("%w: %s is missing %s", ErrInvalidEvent, envelope.Type, name)
+		}
+		if !kind.matches(value) {
+			return fmt.Errorf("%w: %s field %s has the wrong type", ErrInvalidEvent, envelope.Type, name)
+		}
+	}
+
+	return nil
+}
+
+// matches accepts the Go values published and what encoding/json decodes them back into
+func (k fieldKind) matches(value any) bool {
+	if value == nil {
+		return k == fieldList
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String:
+		return k == fieldString
+	case reflect.Bool:
+		return k == fieldBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return k == fieldNumber
+	case reflect.Slice, reflect.Array:
+		return k == fieldList
+	default:
+		return false
+	}
+}
@@ -0,0 +1,145 @@
+package i18n
+
+var germanCatalog = Catalog{
+	"room ID is required":               "Raum-ID ist erforderlich",
+	"user not found in context":         "Benutzer im Kontext nicht gefunden",
+	"failed to set authentication":      "Authentifizierung konnte nicht gesetzt werden",
+	"user ID is required":               "Benutzer-ID ist erforderlich",
+	"you are not a member of this room": "du bist kein Mitglied dieses Raums",
+	"room not found":                    "Raum nicht gefunden",
+	"message ID is required":            "Nachrichten-ID ist erforderlich",
+	"file path is required":             "Dateipfad ist erforderlich",
+	"file not found":                    "Datei nicht gefunden",
+	"user to kick not found":            "zu entfernender Benutzer nicht gefunden",
+	"timestamp parameter is required":   "Parameter timestamp ist erforderlich",
+	"room authentication required":      "Raum-Authentifizierung erforderlich",
+	"invalid timestamp format, use RFC3339 (e.g., 2024-01-01T12:00:00Z)": "ungültiges Zeitstempelformat, verwende RFC3339 (z. B. 2024-01-01T12:00:00Z)",
+	"file is required":                                                     "Datei ist erforderlich",
+	"file ID is required":                                                  "Datei-ID ist erforderlich",
+	"failed to stat file":                                                  "Dateiinformationen konnten nicht gelesen werden",
+	"failed to open file":                                                  "Datei konnte nicht geöffnet werden",
+	"Failed to get usage stats":                                            "Nutzungsstatistiken konnten nicht abgerufen werden",
+	"invalid secure token":                                                 "ungültiges Sicherheitstoken",
+	"join code cannot be empty":                                            "Beitrittscode darf nicht leer sein",
+	"message ID cannot be empty":                                           "Nachrichten-ID darf nicht leer sein",
+	"message cannot be empty":                                              "Nachricht darf nicht leer sein",
+	"message cannot contain only whitespace":                               "Nachricht darf nicht nur aus Leerzeichen bestehen",
+	"only the file uploader or room owner can delete files":                "nur der Hochladende oder der Raumbesitzer kann Dateien löschen",
+	"only the room owner can delete the room":                              "nur der Raumbesitzer kann den Raum löschen",
+	"only the room owner can export the room":                              "nur der Raumbesitzer kann den Raum exportieren",
+	"only the room owner can kick members":                                 "nur der Raumbesitzer kann Mitglieder entfernen",
+	"only the room owner can update the room":                              "nur der Raumbesitzer kann den Raum bearbeiten",
+	"passphrase is required for this room":                                 "für diesen Raum ist eine Passphrase erforderlich",
+	"room ID cannot be empty":                                              "Raum-ID darf nicht leer sein",
+	"room has expired":                                                     "der Raum ist abgelaufen",
+	"room owner cannot be kicked, delete the room instead":                 "der Raumbesitzer kann nicht entfernt werden, lösche stattdessen den Raum",
+	"room owner cannot leave, delete the room instead":                     "der Raumbesitzer kann den Raum nicht verlassen, lösche ihn stattdessen",
+	"secure token cannot be empty":                                         "Sicherheitstoken darf nicht leer sein",
+	"unauthorized: you can only delete your own messages":                  "nicht autorisiert: du kannst nur deine eigenen Nachrichten löschen",
+	"unauthorized: you can only edit your own messages":                    "nicht autorisiert: du kannst nur deine eigenen Nachrichten bearbeiten",
+	"user ID cannot be empty":                                              "Benutzer-ID darf nicht leer sein",
+	"user is not a member of this room":                                    "der Benutzer ist kein Mitglied dieses Raums",
+	"username can only contain letters, numbers, underscores, and hyphens": "der Benutzername darf nur Buchstaben, Zahlen, Unterstriche und Bindestriche enthalten",
+	"username cannot be empty":                                             "Benutzername darf nicht leer sein",
+	"username must be at least 3 characters long":                          "der Benutzername muss mindestens 3 Zeichen lang sein",
+	"username must be at most 20 characters long":                          "der Benutzername darf höchstens 20 Zeichen lang sein",
+	"username must start with a letter or number":                          "der Benutzername muss mit einem Buchstaben oder einer Zahl beginnen",
+	"authentication required - please provide a member token":              "Authentifizierung erforderlich - bitte ein Mitglieds-Token angeben",
+	"failed to upgrade connection":                                         "Verbindung konnte nicht aktualisiert werden",
+	"authentication required":                                              "Authentifizierung erforderlich",
+	"join_code and secure_code query parameters are required":              "die Parameter join_code und secure_code sind erforderlich",
+	"user_id is required in request body":                                  "user_id ist im Anfragekörper erforderlich",
+	"invalid join code or secure code":                                     "ungültiger Beitrittscode oder Sicherheitscode",
+	"failed to process user":                                               "Benutzer konnte nicht verarbeitet werden",
+	"Admin API is disabled, no admin token configured":                     "Die Admin-API ist deaktiviert, kein Admin-Token konfiguriert",
+	"A valid admin token is required":                                      "Ein gültiges Admin-Token ist erforderlich",
+	"Failed to initialize user session":                                    "Benutzersitzung konnte nicht initialisiert werden",
+	"This account has been banned":                                         "Dieses Konto wurde gesperrt",
+	"Too many requests. You have been temporarily blocked.":                "Zu viele Anfragen. Du wurdest vorübergehend blockiert.",
+	"Visper is undergoing maintenance and is currently read-only. Please try again shortly.": "Der Dienst befindet sich im schreibgeschützten Wartungsmodus, bitte versuche es später erneut",
+	"only the room owner can share the secure token":                                         "nur der Raumbesitzer kann das Sicherheitstoken teilen",
+	"short link not found":                                               "Kurzlink nicht gefunden",
+	"web push is not configured":                                         "Web-Push ist nicht konfiguriert",
+	"too many push subscriptions":                                        "zu viele Push-Abonnements",
+	"push platform is not supported":                                     "Push-Plattform wird nicht unterstützt",
+	"invalid notification level":                                         "ungültige Benachrichtigungsstufe",
+	"push endpoint must be an https URL of a known push service":         "der Push-Endpunkt muss eine HTTPS-URL eines bekannten Push-Dienstes sein",
+	"emoji cannot be empty":                                              "Emoji darf nicht leer sein",
+	"emoji is too long":                                                  "Emoji ist zu lang",
+	"emoji cannot contain whitespace":                                    "Emoji darf keine Leerzeichen enthalten",
+	"reaction already exists":                                            "Reaktion existiert bereits",
+	"reaction not found":                                                 "Reaktion nicht gefunden",
+	"message has too many different reactions":                           "Nachricht hat zu viele verschiedene Reaktionen",
+	"room ID and message ID are required":                                "Raum-ID und Nachrichten-ID sind erforderlich",
+	"parent message not found":                                           "Übergeordnete Nachricht nicht gefunden",
+	"only the room owner can mute members":                               "Nur der Raumbesitzer kann Mitglieder stummschalten",
+	"room owner cannot be muted":                                         "Der Raumbesitzer kann nicht stummgeschaltet werden",
+	"user is not muted":                                                  "Benutzer ist nicht stummgeschaltet",
+	"you are muted in this room":                                         "Du bist in diesem Raum stummgeschaltet",
+	"user to mute not found":                                             "Stummzuschaltender Benutzer nicht gefunden",
+	"mute duration must be positive":                                     "Die Stummschaltdauer muss positiv sein",
+	"you are banned from this room":                                      "Du bist aus diesem Raum verbannt",
+	"only the room owner can ban members":                                "Nur der Raumbesitzer kann Mitglieder verbannen",
+	"room owner cannot be banned":                                        "Der Raumbesitzer kann nicht verbannt werden",
+	"user is not banned from this room":                                  "Benutzer ist nicht aus diesem Raum verbannt",
+	"message retention must be between 1 hour and 30 days":               "Die Aufbewahrung von Nachrichten muss zwischen 1 Stunde und 30 Tagen liegen",
+	"room is full":                                                       "Der Raum ist voll",
+	"room is read-only":                                                  "Der Raum ist schreibgeschützt",
+	"slow mode is enabled, please wait before sending another message":   "der langsame Modus ist aktiviert, bitte warte, bevor du eine weitere Nachricht sendest",
+	"invalid invite limits":                                              "ungültige Einladungsgrenzen",
+	"only the room owner can manage invites":                             "nur der Raumbesitzer kann Einladungen verwalten",
+	"invite not found":                                                   "Einladung nicht gefunden",
+	"invite has expired":                                                 "die Einladung ist abgelaufen",
+	"invite has reached its usage limit":                                 "die Einladung hat ihr Nutzungslimit erreicht",
+	"room ID and invite token are required":                              "Raum-ID und Einladungstoken sind erforderlich",
+	"invite token is required":                                           "Einladungstoken ist erforderlich",
+	"only the room owner can post announcements":                         "nur der Raumbesitzer kann Ankündigungen veröffentlichen",
+	"announcement not found":                                             "Ankündigung nicht gefunden",
+	"failed to purge user data":                                          "Benutzerdaten konnten nicht gelöscht werden",
+	"the room was exported recently, please wait before exporting again": "der Raum wurde kürzlich exportiert, bitte warte, bevor du ihn erneut exportierst",
+	"format must be one of json, txt or html":                            "format muss json, txt oder html sein",
+	"message was blocked by the room's content filter":                   "die Nachricht wurde vom Inhaltsfilter des Raums blockiert",
+	"only the room owner can manage webhooks":                            "nur der Raumbesitzer kann Webhooks verwalten",
+	"webhook not found":                                                  "Webhook nicht gefunden",
+	"incoming webhook not found":                                         "eingehender Webhook nicht gefunden",
+	"invalid webhook url":                                                "ungültige Webhook-URL",
+	"unknown webhook event":                                              "unbekanntes Webhook-Ereignis",
+	"room has too many webhooks":                                         "der Raum hat zu viele Webhooks",
+	"invalid webhook token":                                              "ungültiges Webhook-Token",
+	"invalid limit":                                                      "ungültiges Limit",
+	"bot token is required":                                              "Bot-Token ist erforderlich",
+	"invalid bot token":                                                  "ungültiges Bot-Token",
+	"bot token does not have the required scope":                         "das Bot-Token hat nicht den erforderlichen Bereich",
+	"bots cannot register other bots":                                    "Bots können keine anderen Bots registrieren",
+	"bot name must be between 3 and 50 characters":                       "der Bot-Name muss zwischen 3 und 50 Zeichen lang sein",
+	"a bot needs at least one scope":                                     "ein Bot benötigt mindestens einen Bereich",
+	"unknown bot scope":                                                  "unbekannter Bot-Bereich",
+	"too many bots":                                                      "zu viele Bots",
+	"bot not found":                                                      "Bot nicht gefunden",
+	"only the room owner can add bots":                                   "nur der Raumbesitzer kann Bots hinzufügen",
+	"idempotency key is too long":                                        "der Idempotenzschlüssel ist zu lang",
+	"failed to read request body":                                        "Anfragetext konnte nicht gelesen werden",
+	"a request with this idempotency key is still in progress":           "eine Anfrage mit diesem Idempotenzschlüssel wird noch bearbeitet",
+	"idempotency key was already used for a different request":           "der Idempotenzschlüssel wurde bereits für eine andere Anfrage verwendet",
+	"internal server error":                                              "Interner Serverfehler",
+	"only the room owner can do this":                                    "Nur der Raumbesitzer kann das tun",
+	"you can only change your own messages":                              "Du kannst nur deine eigenen Nachrichten ändern",
+	"invite is no longer available":                                      "Die Einladung ist nicht mehr verfügbar",
+	"limit reached":                                                      "Limit erreicht",
+	"forbidden":                                                          "Verboten",
+	"unauthorized":                                                       "Nicht autorisiert",
+	"invalid request":                                                    "Ungültige Anfrage",
+	"this action cannot target the room owner":                           "Diese Aktion kann nicht auf den Raumbesitzer angewendet werden",
+	"this API version is no longer available":                            "Diese API-Version ist nicht mehr verfügbar",
+	"origin not allowed":                                                 "Herkunft nicht erlaubt",
+	"invalid or expired socket ticket":                                   "Ungültiges oder abgelaufenes Socket-Ticket",
+	"this client did not join the room":                                  "Dieser Client ist dem Raum nicht beigetreten",
+	"the room key has been rotated, fetch the new key":                   "Der Raumschlüssel wurde erneuert, bitte den neuen Schlüssel abrufen",
+	"encrypted message is not valid ciphertext":                          "Die verschlüsselte Nachricht ist kein gültiger Geheimtext",
+	"only the room owner can rotate the room key":                        "Nur der Raumbesitzer kann den Raumschlüssel erneuern",
+	"file link is not valid":                                             "Der Dateilink ist ungültig",
+	"file link has expired":                                              "Der Dateilink ist abgelaufen",
+	"file contains data that is not part of the image":                   "Die Datei enthält Daten, die nicht zum Bild gehören",
+	"room storage quota exceeded":                                        "Das Speicherkontingent des Raums ist aufgebraucht",
+	"This feature is currently turned off":                               "Diese Funktion ist derzeit deaktiviert",
+}
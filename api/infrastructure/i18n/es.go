@@ -0,0 +1,145 @@
+package i18n
+
+var spanishCatalog = Catalog{
+	"room ID is required":               "el ID de la sala es obligatorio",
+	"user not found in context":         "usuario no encontrado en el contexto",
+	"failed to set authentication":      "no se pudo configurar la autenticación",
+	"user ID is required":               "el ID de usuario es obligatorio",
+	"you are not a member of this room": "no eres miembro de esta sala",
+	"room not found":                    "sala no encontrada",
+	"message ID is required":            "el ID del mensaje es obligatorio",
+	"file path is required":             "la ruta del archivo es obligatoria",
+	"file not found":                    "archivo no encontrado",
+	"user to kick not found":            "usuario a expulsar no encontrado",
+	"timestamp parameter is required":   "el parámetro timestamp es obligatorio",
+	"room authentication required":      "se requiere autenticación de la sala",
+	"invalid timestamp format, use RFC3339 (e.g., 2024-01-01T12:00:00Z)": "formato de marca de tiempo no válido, usa RFC3339 (p. ej., 2024-01-01T12:00:00Z)",
+	"file is required":                                                     "el archivo es obligatorio",
+	"file ID is required":                                                  "el ID del archivo es obligatorio",
+	"failed to stat file":                                                  "no se pudo leer la información del archivo",
+	"failed to open file":                                                  "no se pudo abrir el archivo",
+	"Failed to get usage stats":                                            "No se pudieron obtener las estadísticas de uso",
+	"invalid secure token":                                                 "token de seguridad no válido",
+	"join code cannot be empty":                                            "el código de acceso no puede estar vacío",
+	"message ID cannot be empty":                                           "el ID del mensaje no puede estar vacío",
+	"message cannot be empty":                                              "el mensaje no puede estar vacío",
+	"message cannot contain only whitespace":                               "el mensaje no puede contener solo espacios",
+	"only the file uploader or room owner can delete files":                "solo quien subió el archivo o el propietario de la sala puede eliminar archivos",
+	"only the room owner can delete the room":                              "solo el propietario de la sala puede eliminarla",
+	"only the room owner can export the room":                              "solo el propietario de la sala puede exportarla",
+	"only the room owner can kick members":                                 "solo el propietario de la sala puede expulsar miembros",
+	"only the room owner can update the room":                              "solo el propietario de la sala puede actualizarla",
+	"passphrase is required for this room":                                 "se requiere una frase de contraseña para esta sala",
+	"room ID cannot be empty":                                              "el ID de la sala no puede estar vacío",
+	"room has expired":                                                     "la sala ha expirado",
+	"room owner cannot be kicked, delete the room instead":                 "el propietario no puede ser expulsado, elimina la sala en su lugar",
+	"room owner cannot leave, delete the room instead":                     "el propietario no puede salir, elimina la sala en su lugar",
+	"secure token cannot be empty":                                         "el token de seguridad no puede estar vacío",
+	"unauthorized: you can only delete your own messages":                  "no autorizado: solo puedes eliminar tus propios mensajes",
+	"unauthorized: you can only edit your own messages":                    "no autorizado: solo puedes editar tus propios mensajes",
+	"user ID cannot be empty":                                              "el ID de usuario no puede estar vacío",
+	"user is not a member of this room":                                    "el usuario no es miembro de esta sala",
+	"username can only contain letters, numbers, underscores, and hyphens": "el nombre de usuario solo puede contener letras, números, guiones bajos y guiones",
+	"username cannot be empty":                                             "el nombre de usuario no puede estar vacío",
+	"username must be at least 3 characters long":                          "el nombre de usuario debe tener al menos 3 caracteres",
+	"username must be at most 20 characters long":                          "el nombre de usuario debe tener como máximo 20 caracteres",
+	"username must start with a letter or number":                          "el nombre de usuario debe empezar con una letra o un número",
+	"authentication required - please provide a member token":              "se requiere autenticación: proporciona un token de miembro",
+	"failed to upgrade connection":                                         "no se pudo actualizar la conexión",
+	"authentication required":                                              "se requiere autenticación",
+	"join_code and secure_code query parameters are required":              "los parámetros join_code y secure_code son obligatorios",
+	"user_id is required in request body":                                  "user_id es obligatorio en el cuerpo de la solicitud",
+	"invalid join code or secure code":                                     "código de acceso o código de seguridad no válido",
+	"failed to process user":                                               "no se pudo procesar el usuario",
+	"Admin API is disabled, no admin token configured":                     "La API de administración está desactivada, no hay token configurado",
+	"A valid admin token is required":                                      "Se requiere un token de administración válido",
+	"Failed to initialize user session":                                    "No se pudo iniciar la sesión de usuario",
+	"This account has been banned":                                         "Esta cuenta ha sido bloqueada",
+	"Too many requests. You have been temporarily blocked.":                "Demasiadas solicitudes. Has sido bloqueado temporalmente.",
+	"Visper is undergoing maintenance and is currently read-only. Please try again shortly.": "El servicio está en mantenimiento de solo lectura, inténtalo más tarde",
+	"only the room owner can share the secure token":                                         "solo el propietario de la sala puede compartir el token de seguridad",
+	"short link not found":                                               "enlace corto no encontrado",
+	"web push is not configured":                                         "web push no está configurado",
+	"too many push subscriptions":                                        "demasiadas suscripciones push",
+	"push platform is not supported":                                     "plataforma push no compatible",
+	"invalid notification level":                                         "nivel de notificación no válido",
+	"push endpoint must be an https URL of a known push service":         "el endpoint push debe ser una URL https de un servicio push conocido",
+	"emoji cannot be empty":                                              "el emoji no puede estar vacío",
+	"emoji is too long":                                                  "el emoji es demasiado largo",
+	"emoji cannot contain whitespace":                                    "el emoji no puede contener espacios",
+	"reaction already exists":                                            "la reacción ya existe",
+	"reaction not found":                                                 "reacción no encontrada",
+	"message has too many different reactions":                           "el mensaje tiene demasiadas reacciones diferentes",
+	"room ID and message ID are required":                                "se requieren el ID de la sala y el ID del mensaje",
+	"parent message not found":                                           "mensaje principal no encontrado",
+	"only the room owner can mute members":                               "solo el propietario de la sala puede silenciar a los miembros",
+	"room owner cannot be muted":                                         "el propietario de la sala no puede ser silenciado",
+	"user is not muted":                                                  "el usuario no está silenciado",
+	"you are muted in this room":                                         "estás silenciado en esta sala",
+	"user to mute not found":                                             "usuario a silenciar no encontrado",
+	"mute duration must be positive":                                     "la duración del silencio debe ser positiva",
+	"you are banned from this room":                                      "estás vetado de esta sala",
+	"only the room owner can ban members":                                "solo el propietario de la sala puede vetar a los miembros",
+	"room owner cannot be banned":                                        "el propietario de la sala no puede ser vetado",
+	"user is not banned from this room":                                  "el usuario no está vetado de esta sala",
+	"message retention must be between 1 hour and 30 days":               "la retención de mensajes debe estar entre 1 hora y 30 días",
+	"room is full":                                                       "la sala está llena",
+	"room is read-only":                                                  "la sala es de solo lectura",
+	"slow mode is enabled, please wait before sending another message":   "el modo lento está activado, espera antes de enviar otro mensaje",
+	"invalid invite limits":                                              "límites de invitación no válidos",
+	"only the room owner can manage invites":                             "solo el propietario de la sala puede gestionar las invitaciones",
+	"invite not found":                                                   "invitación no encontrada",
+	"invite has expired":                                                 "la invitación ha caducado",
+	"invite has reached its usage limit":                                 "la invitación ha alcanzado su límite de usos",
+	"room ID and invite token are required":                              "se requieren el ID de la sala y el token de invitación",
+	"invite token is required":                                           "se requiere el token de invitación",
+	"only the room owner can post announcements":                         "solo el propietario de la sala puede publicar anuncios",
+	"announcement not found":                                             "anuncio no encontrado",
+	"failed to purge user data":                                          "no se pudieron eliminar los datos del usuario",
+	"the room was exported recently, please wait before exporting again": "la sala se exportó hace poco, espera antes de volver a exportarla",
+	"format must be one of json, txt or html":                            "el formato debe ser json, txt o html",
+	"message was blocked by the room's content filter":                   "el mensaje fue bloqueado por el filtro de contenido de la sala",
+	"only the room owner can manage webhooks":                            "solo el propietario de la sala puede gestionar webhooks",
+	"webhook not found":                                                  "webhook no encontrado",
+	"incoming webhook not found":                                         "webhook entrante no encontrado",
+	"invalid webhook url":                                                "URL de webhook no válida",
+	"unknown webhook event":                                              "evento de webhook desconocido",
+	"room has too many webhooks":                                         "la sala tiene demasiados webhooks",
+	"invalid webhook token":                                              "token de webhook no válido",
+	"invalid limit":                                                      "límite no válido",
+	"bot token is required":                                              "se requiere el token del bot",
+	"invalid bot token":                                                  "token de bot no válido",
+	"bot token does not have the required scope":                         "el token del bot no tiene el permiso requerido",
+	"bots cannot register other bots":                                    "los bots no pueden registrar otros bots",
+	"bot name must be between 3 and 50 characters":                       "el nombre del bot debe tener entre 3 y 50 caracteres",
+	"a bot needs at least one scope":                                     "un bot necesita al menos un permiso",
+	"unknown bot scope":                                                  "permiso de bot desconocido",
+	"too many bots":                                                      "demasiados bots",
+	"bot not found":                                                      "bot no encontrado",
+	"only the room owner can add bots":                                   "solo el propietario de la sala puede añadir bots",
+	"idempotency key is too long":                                        "la clave de idempotencia es demasiado larga",
+	"failed to read request body":                                        "no se pudo leer el cuerpo de la solicitud",
+	"a request with this idempotency key is still in progress":           "una solicitud con esta clave de idempotencia todavía está en curso",
+	"idempotency key was already used for a different request":           "la clave de idempotencia ya se usó para otra solicitud",
+	"internal server error":                                              "Error interno del servidor",
+	"only the room owner can do this":                                    "Solo el propietario de la sala puede hacer esto",
+	"you can only change your own messages":                              "Solo puedes modificar tus propios mensajes",
+	"invite is no longer available":                                      "La invitación ya no está disponible",
+	"limit reached":                                                      "Límite alcanzado",
+	"forbidden":                                                          "Prohibido",
+	"unauthorized":                                                       "No autorizado",
+	"invalid request":                                                    "Solicitud no válida",
+	"this action cannot target the room owner":                           "Esta acción no puede aplicarse al propietario de la sala",
+	"this API version is no longer available":                            "Esta versión de la API ya no está disponible",
+	"origin not allowed":                                                 "Origen no permitido",
+	"invalid or expired socket ticket":                                   "Ticket de socket no válido o caducado",
+	"this client did not join the room":                                  "Este cliente no se unió a la sala",
+	"the room key has been rotated, fetch the new key":                   "La clave de la sala ha sido renovada, obtén la nueva clave",
+	"encrypted message is not valid ciphertext":                          "El mensaje cifrado no es un texto cifrado válido",
+	"only the room owner can rotate the room key":                        "Solo el propietario de la sala puede renovar la clave de la sala",
+	"file link is not valid":                                             "El enlace del archivo no es válido",
+	"file link has expired":                                              "El enlace del archivo ha caducado",
+	"file contains data that is not part of the image":                   "El archivo contiene datos que no forman parte de la imagen",
+	"room storage quota exceeded":                                        "Se ha superado la cuota de almacenamiento de la sala",
+	"This feature is currently turned off":                               "Esta función está desactivada en este momento",
+}
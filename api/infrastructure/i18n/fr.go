@@ -0,0 +1,145 @@
+package i18n
+
+var frenchCatalog = Catalog{
+	"room ID is required":               "l'identifiant du salon est requis",
+	"user not found in context":         "utilisateur introuvable dans le contexte",
+	"failed to set authentication":      "échec de la configuration de l'authentification",
+	"user ID is required":               "l'identifiant utilisateur est requis",
+	"you are not a member of this room": "vous n'êtes pas membre de ce salon",
+	"room not found":                    "salon introuvable",
+	"message ID is required":            "l'identifiant du message est requis",
+	"file path is required":             "le chemin du fichier est requis",
+	"file not found":                    "fichier introuvable",
+	"user to kick not found":            "utilisateur à expulser introuvable",
+	"timestamp parameter is required":   "le paramètre timestamp est requis",
+	"room authentication required":      "authentification du salon requise",
+	"invalid timestamp format, use RFC3339 (e.g., 2024-01-01T12:00:00Z)": "format d'horodatage invalide, utilisez RFC3339 (ex. 2024-01-01T12:00:00Z)",
+	"file is required":                                                     "un fichier est requis",
+	"file ID is required":                                                  "l'identifiant du fichier est requis",
+	"failed to stat file":                                                  "impossible de lire les informations du fichier",
+	"failed to open file":                                                  "impossible d'ouvrir le fichier",
+	"Failed to get usage stats":                                            "Impossible de récupérer les statistiques d'utilisation",
+	"invalid secure token":                                                 "jeton de sécurité invalide",
+	"join code cannot be empty":                                            "le code d'accès ne peut pas être vide",
+	"message ID cannot be empty":                                           "l'identifiant du message ne peut pas être vide",
+	"message cannot be empty":                                              "le message ne peut pas être vide",
+	"message cannot contain only whitespace":                               "le message ne peut pas contenir uniquement des espaces",
+	"only the file uploader or room owner can delete files":                "seul l'auteur de l'envoi ou le propriétaire du salon peut supprimer des fichiers",
+	"only the room owner can delete the room":                              "seul le propriétaire du salon peut le supprimer",
+	"only the room owner can export the room":                              "seul le propriétaire du salon peut l'exporter",
+	"only the room owner can kick members":                                 "seul le propriétaire du salon peut expulser des membres",
+	"only the room owner can update the room":                              "seul le propriétaire du salon peut le modifier",
+	"passphrase is required for this room":                                 "une phrase secrète est requise pour ce salon",
+	"room ID cannot be empty":                                              "l'identifiant du salon ne peut pas être vide",
+	"room has expired":                                                     "le salon a expiré",
+	"room owner cannot be kicked, delete the room instead":                 "le propriétaire ne peut pas être expulsé, supprimez plutôt le salon",
+	"room owner cannot leave, delete the room instead":                     "le propriétaire ne peut pas quitter le salon, supprimez-le plutôt",
+	"secure token cannot be empty":                                         "le jeton de sécurité ne peut pas être vide",
+	"unauthorized: you can only delete your own messages":                  "non autorisé : vous ne pouvez supprimer que vos propres messages",
+	"unauthorized: you can only edit your own messages":                    "non autorisé : vous ne pouvez modifier que vos propres messages",
+	"user ID cannot be empty":                                              "l'identifiant utilisateur ne peut pas être vide",
+	"user is not a member of this room":                                    "l'utilisateur n'est pas membre de ce salon",
+	"username can only contain letters, numbers, underscores, and hyphens": "le nom d'utilisateur ne peut contenir que des lettres, chiffres, tirets bas et tirets",
+	"username cannot be empty":                                             "le nom d'utilisateur ne peut pas être vide",
+	"username must be at least 3 characters long":                          "le nom d'utilisateur doit comporter au moins 3 caractères",
+	"username must be at most 20 characters long":                          "le nom d'utilisateur doit comporter au plus 20 caractères",
+	"username must start with a letter or number":                          "le nom d'utilisateur doit commencer par une lettre ou un chiffre",
+	"authentication required - please provide a member token":              "authentification requise - fournissez un jeton de membre",
+	"failed to upgrade connection":                                         "échec de la mise à niveau de la connexion",
+	"authentication required":                                              "authentification requise",
+	"join_code and secure_code query parameters are required":              "les paramètres join_code et secure_code sont requis",
+	"user_id is required in request body":                                  "user_id est requis dans le corps de la requête",
+	"invalid join code or secure code":                                     "code d'accès ou code de sécurité invalide",
+	"failed to process user":                                               "impossible de traiter l'utilisateur",
+	"Admin API is disabled, no admin token configured":                     "L'API d'administration est désactivée, aucun jeton configuré",
+	"A valid admin token is required":                                      "Un jeton d'administration valide est requis",
+	"Failed to initialize user session":                                    "Impossible d'initialiser la session utilisateur",
+	"This account has been banned":                                         "Ce compte a été banni",
+	"Too many requests. You have been temporarily blocked.":                "Trop de requêtes. Vous avez été temporairement bloqué.",
+	"Visper is undergoing maintenance and is currently read-only. Please try again shortly.": "Le service est en maintenance en lecture seule, réessayez plus tard",
+	"only the room owner can share the secure token":                                         "seul le propriétaire du salon peut partager le jeton de sécurité",
+	"short link not found":                                               "lien court introuvable",
+	"web push is not configured":                                         "les notifications web push ne sont pas configurées",
+	"too many push subscriptions":                                        "trop d'abonnements push",
+	"push platform is not supported":                                     "plateforme push non prise en charge",
+	"invalid notification level":                                         "niveau de notification invalide",
+	"push endpoint must be an https URL of a known push service":         "le point de terminaison push doit être une URL https d'un service push connu",
+	"emoji cannot be empty":                                              "l’emoji ne peut pas être vide",
+	"emoji is too long":                                                  "l’emoji est trop long",
+	"emoji cannot contain whitespace":                                    "l’emoji ne peut pas contenir d’espaces",
+	"reaction already exists":                                            "la réaction existe déjà",
+	"reaction not found":                                                 "réaction introuvable",
+	"message has too many different reactions":                           "le message a trop de réactions différentes",
+	"room ID and message ID are required":                                "l’identifiant du salon et du message sont requis",
+	"parent message not found":                                           "message parent introuvable",
+	"only the room owner can mute members":                               "seul le propriétaire du salon peut rendre muets les membres",
+	"room owner cannot be muted":                                         "le propriétaire du salon ne peut pas être rendu muet",
+	"user is not muted":                                                  "l’utilisateur n’est pas muet",
+	"you are muted in this room":                                         "vous êtes muet dans ce salon",
+	"user to mute not found":                                             "utilisateur à rendre muet introuvable",
+	"mute duration must be positive":                                     "la durée de la sourdine doit être positive",
+	"you are banned from this room":                                      "vous êtes banni de ce salon",
+	"only the room owner can ban members":                                "seul le propriétaire du salon peut bannir des membres",
+	"room owner cannot be banned":                                        "le propriétaire du salon ne peut pas être banni",
+	"user is not banned from this room":                                  "l’utilisateur n’est pas banni de ce salon",
+	"message retention must be between 1 hour and 30 days":               "la conservation des messages doit être comprise entre 1 heure et 30 jours",
+	"room is full":                                                       "le salon est complet",
+	"room is read-only":                                                  "le salon est en lecture seule",
+	"slow mode is enabled, please wait before sending another message":   "le mode lent est activé, veuillez patienter avant d'envoyer un autre message",
+	"invalid invite limits":                                              "limites d'invitation invalides",
+	"only the room owner can manage invites":                             "seul le propriétaire du salon peut gérer les invitations",
+	"invite not found":                                                   "invitation introuvable",
+	"invite has expired":                                                 "l'invitation a expiré",
+	"invite has reached its usage limit":                                 "l'invitation a atteint sa limite d'utilisation",
+	"room ID and invite token are required":                              "l'ID du salon et le jeton d'invitation sont requis",
+	"invite token is required":                                           "le jeton d'invitation est requis",
+	"only the room owner can post announcements":                         "seul le propriétaire du salon peut publier des annonces",
+	"announcement not found":                                             "annonce introuvable",
+	"failed to purge user data":                                          "échec de la suppression des données utilisateur",
+	"the room was exported recently, please wait before exporting again": "le salon a été exporté récemment, veuillez patienter avant de l'exporter à nouveau",
+	"format must be one of json, txt or html":                            "le format doit être json, txt ou html",
+	"message was blocked by the room's content filter":                   "le message a été bloqué par le filtre de contenu du salon",
+	"only the room owner can manage webhooks":                            "seul le propriétaire du salon peut gérer les webhooks",
+	"webhook not found":                                                  "webhook introuvable",
+	"incoming webhook not found":                                         "webhook entrant introuvable",
+	"invalid webhook url":                                                "URL de webhook invalide",
+	"unknown webhook event":                                              "événement de webhook inconnu",
+	"room has too many webhooks":                                         "le salon a trop de webhooks",
+	"invalid webhook token":                                              "jeton de webhook invalide",
+	"invalid limit":                                                      "limite invalide",
+	"bot token is required":                                              "le jeton du bot est requis",
+	"invalid bot token":                                                  "jeton de bot invalide",
+	"bot token does not have the required scope":                         "le jeton du bot n'a pas la portée requise",
+	"bots cannot register other bots":                                    "les bots ne peuvent pas enregistrer d'autres bots",
+	"bot name must be between 3 and 50 characters":                       "le nom du bot doit contenir entre 3 et 50 caractères",
+	"a bot needs at least one scope":                                     "un bot a besoin d'au moins une portée",
+	"unknown bot scope":                                                  "portée de bot inconnue",
+	"too many bots":                                                      "trop de bots",
+	"bot not found":                                                      "bot introuvable",
+	"only the room owner can add bots":                                   "seul le propriétaire du salon peut ajouter des bots",
+	"idempotency key is too long":                                        "la clé d'idempotence est trop longue",
+	"failed to read request body":                                        "impossible de lire le corps de la requête",
+	"a request with this idempotency key is still in progress":           "une requête avec cette clé d'idempotence est encore en cours",
+	"idempotency key was already used for a different request":           "la clé d'idempotence a déjà été utilisée pour une autre requête",
+	"internal server error":                                              "Erreur interne du serveur",
+	"only the room owner can do this":                                    "Seul le propriétaire du salon peut faire cela",
+	"you can only change your own messages":                              "Vous ne pouvez modifier que vos propres messages",
+	"invite is no longer available":                                      "L'invitation n'est plus disponible",
+	"limit reached":                                                      "Limite atteinte",
+	"forbidden":                                                          "Interdit",
+	"unauthorized":                                                       "Non autorisé",
+	"invalid request":                                                    "Requête invalide",
+	"this action cannot target the room owner":                           "Cette action ne peut pas viser le propriétaire du salon",
+	"this API version is no longer available":                            "Cette version de l'API n'est plus disponible",
+	"origin not allowed":                                                 "Origine non autorisée",
+	"invalid or expired socket ticket":                                   "Ticket de socket invalide ou expiré",
+	"this client did not join the room":                                  "Ce client n'a pas rejoint le salon",
+	"the room key has been rotated, fetch the new key":                   "La clé du salon a été renouvelée, récupérez la nouvelle clé",
+	"encrypted message is not valid ciphertext":                          "Le message chiffré n'est pas un texte chiffré valide",
+	"only the room owner can rotate the room key":                        "Seul le propriétaire du salon peut renouveler la clé du salon",
+	"file link is not valid":                                             "Le lien du fichier n'est pas valide",
+	"file link has expired":                                              "Le lien du fichier a expiré",
+	"file contains data that is not part of the image":                   "Le fichier contient des données qui ne font pas partie de l'image",
+	"room storage quota exceeded":                                        "Le quota de stockage du salon est dépassé",
+	"This feature is currently turned off":                               "Cette fonctionnalité est actuellement désactivée",
+}
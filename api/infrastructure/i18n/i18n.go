@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"golang.org/x/text/language"
+)
+
+// Catalogs are keyed by the English message so untranslated or dynamic
+// strings fall back to the original text unchanged
+type Catalog map[string]string
+
+var DefaultLanguage = language.English
+
+// First entry is the matcher's fallback
+var supported = []language.Tag{
+	DefaultLanguage,
+	language.French,
+	language.Spanish,
+	language.German,
+}
+
+var catalogs = map[language.Tag]Catalog{
+	language.French:  frenchCatalog,
+	language.Spanish: spanishCatalog,
+	language.German:  germanCatalog,
+}
+
+var matcher = language.NewMatcher(supported)
+
+// ParseAcceptLanguage picks the best supported language for an Accept-Language header
+func ParseAcceptLanguage(header string) language.Tag {
+	if header == "" {
+		return DefaultLanguage
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 {
+		return DefaultLanguage
+	}
+
+	_, index, _ := matcher.Match(tags...)
+	return supported[index]
+}
+
+func Translate(lang language.Tag, message string) string {
+	catalog, ok := catalogs[lang]
+	if !ok {
+		return message
+	}
+
+	if translated, ok := catalog[message]; ok {
+		return translated
+	}
+	return message
+}
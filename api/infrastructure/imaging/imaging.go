@@ -0,0 +1,163 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// maxPixels refuses images that decode to more than this, a small file can still expand to gigabytes
+const maxPixels = 40_000_000
+
+var ErrUnsupportedImage = errors.New("image format can't be resized")
+
+// Variant is a downscaled copy whose longest side is at most MaxSide
+type Variant struct {
+	Name    string
+	MaxSide int
+}
+
+var (
+	Thumbnail = Variant{Name: "thumbnail", MaxSide: 200}
+	Medium    = Variant{Name: "medium", MaxSide: 1024}
+
+	// Variants are generated for every uploaded image
+	Variants = []Variant{Thumbnail, Medium}
+)
+
+// Rendition is an encoded variant ready to store
+type Rendition struct {
+	Variant     Variant
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// Render decodes src once and encodes every variant smaller than the original. JPEGs stay JPEG,
+// everything else becomes PNG to keep transparency. Formats the standard library can't decode
+// (WebP, BMP) return ErrUnsupportedImage and are served as originals only.
+func Render(src io.Reader, variants []Variant) ([]Rendition, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image is %dx%d, too large to resize", config.Width, config.Height)
+	}
+
+	img, err := decode(format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var renditions []Rendition
+	for _, variant := range variants {
+		width, height, ok := fit(config.Width, config.Height, variant.MaxSide)
+		if !ok {
+			continue
+		}
+
+		rendition := Rendition{Variant: variant}
+		var buf bytes.Buffer
+		resized := Resize(img, width, height)
+
+		if format == "jpeg" {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 82})
+			rendition.ContentType, rendition.Ext = "image/jpeg", ".jpg"
+		} else {
+			err = png.Encode(&buf, resized)
+			rendition.ContentType, rendition.Ext = "image/png", ".png"
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", variant.Name, err)
+		}
+
+		rendition.Data = buf.Bytes()
+		renditions = append(renditions, rendition)
+	}
+
+	return renditions, nil
+}
+
+// Resize downscales with an area average, every source pixel counts towards exactly one target
+// pixel, which keeps thumbnails free of the aliasing nearest neighbour leaves behind
+func Resize(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := range height {
+		y0 := y * srcH / height
+		y1 := max((y+1)*srcH/height, y0+1)
+
+		for x := range width {
+			x0 := x * srcW / width
+			x1 := max((x+1)*srcW/width, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// fit scales width and height so the longest side is maxSide, ok is false when the image already fits
+func fit(width, height, maxSide int) (int, int, bool) {
+	if width <= maxSide && height <= maxSide {
+		return 0, 0, false
+	}
+
+	if width >= height {
+		return maxSide, max(height*maxSide/width, 1), true
+	}
+	return max(width*maxSide/height, 1), maxSide, true
+}
+
+func decode(format string, data []byte) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case "jpeg":
+		return jpeg.Decode(r)
+	case "png":
+		return png.Decode(r)
+	case "gif":
+		// Only the first frame, a thumbnail doesn't animate
+		return gif.Decode(r)
+	default:
+		return nil, ErrUnsupportedImage
+	}
+}
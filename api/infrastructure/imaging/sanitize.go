@@ -0,0 +1,239 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrUnrecognizedImage = errors.New("invalid file type, only images are allowed")
+	ErrPolyglot          = errors.New("file contains data that is not part of the image")
+)
+
+var (
+	jpegMagic = []byte{0xFF, 0xD8, 0xFF}
+	pngMagic  = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+	exifHeader = []byte("Exif\x00\x00")
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+	// Browsers sniff these out of a file served with the wrong type, an image has no business carrying them
+	activeContent = [][]byte{
+		[]byte("<script"), []byte("<html"), []byte("<svg"), []byte("<iframe"), []byte("<?php"), []byte("<!doctype"),
+	}
+)
+
+// Sniff identifies an image by its magic bytes, the extension and Content-Type the client sent don't count
+func Sniff(data []byte) (contentType, ext string, ok bool) {
+	switch {
+	case bytes.HasPrefix(data, jpegMagic):
+		return "image/jpeg", ".jpg", true
+	case bytes.HasPrefix(data, pngMagic):
+		return "image/png", ".png", true
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "image/gif", ".gif", true
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return "image/webp", ".webp", true
+	case len(data) >= 14 && bytes.Equal(data[:2], []byte("BM")):
+		return "image/bmp", ".bmp", true
+	}
+	return "", "", false
+}
+
+// Sanitize checks that data is nothing but one image of contentType and returns what should be stored.
+// Anything trailing the image is refused, as is markup hidden in it. JPEGs lose their EXIF and XMP
+// segments, which carry GPS coordinates and camera serials, unless keepMetadata is set.
+func Sanitize(data []byte, contentType string, keepMetadata bool) ([]byte, error) {
+	var (
+		clean []byte
+		err   error
+	)
+
+	switch contentType {
+	case "image/jpeg":
+		clean, err = sanitizeJPEG(data, keepMetadata)
+	case "image/png":
+		clean, err = checkTrailing(data, pngLength(data))
+	case "image/webp":
+		clean, err = checkTrailing(data, riffLength(data))
+	case "image/bmp":
+		clean, err = checkTrailing(data, int(binary.LittleEndian.Uint32(data[2:6])))
+	case "image/gif":
+		clean, err = checkTrailing(data, gifLength(data))
+	default:
+		return nil, ErrUnrecognizedImage
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lower := bytes.ToLower(clean)
+	for _, marker := range activeContent {
+		if bytes.Contains(lower, marker) {
+			return nil, ErrPolyglot
+		}
+	}
+
+	return clean, nil
+}
+
+// sanitizeJPEG walks the marker segments, dropping metadata ones, and stops at the end of image
+func sanitizeJPEG(data []byte, keepMetadata bool) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xFF {
+			return nil, ErrUnrecognizedImage
+		}
+		// Markers may be padded with any number of 0xFF bytes
+		for i < len(data) && data[i] == 0xFF {
+			i++
+		}
+		if i >= len(data) {
+			return nil, ErrUnrecognizedImage
+		}
+		marker := data[i]
+		i++
+
+		switch {
+		case marker == 0xD9:
+			out = append(out, 0xFF, 0xD9)
+			return out, trailingJPEG(data[i:])
+		case marker >= 0xD0 && marker <= 0xD7, marker == 0x01:
+			out = append(out, 0xFF, marker)
+			continue
+		}
+
+		if i+2 > len(data) {
+			return nil, ErrUnrecognizedImage
+		}
+		length := int(binary.BigEndian.Uint16(data[i:]))
+		if length < 2 || i+length > len(data) {
+			return nil, ErrUnrecognizedImage
+		}
+		segment := data[i : i+length]
+		i += length
+
+		if !keepMetadata && marker == 0xE1 && (bytes.HasPrefix(segment[2:], exifHeader) || bytes.HasPrefix(segment[2:], xmpHeader)) {
+			continue
+		}
+		out = append(out, 0xFF, marker)
+		out = append(out, segment...)
+
+		if marker == 0xDA {
+			// Entropy coded data runs until the next marker, 0xFF00 is an escaped byte and RSTn sit inside it
+			start := i
+			for i+1 < len(data) && (data[i] != 0xFF || data[i+1] == 0x00 || (data[i+1] >= 0xD0 && data[i+1] <= 0xD7)) {
+				i++
+			}
+			out = append(out, data[start:i]...)
+		}
+	}
+
+	return nil, ErrUnrecognizedImage
+}
+
+// trailingJPEG allows the extra frames of a multi picture JPEG and zero padding, those are dropped
+func trailingJPEG(rest []byte) error {
+	if len(rest) == 0 || bytes.HasPrefix(rest, jpegMagic) || allZero(rest) {
+		return nil
+	}
+	return ErrPolyglot
+}
+
+// pngLength is where the IEND chunk ends, -1 when the chunk layout is broken
+func pngLength(data []byte) int {
+	i := len(pngMagic)
+	for i+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunk := string(data[i+4 : i+8])
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return -1
+		}
+		if chunk == "IEND" {
+			return end
+		}
+		i = end
+	}
+	return -1
+}
+
+func riffLength(data []byte) int {
+	return int(binary.LittleEndian.Uint32(data[4:8])) + 8
+}
+
+// gifLength walks the blocks up to the trailer, -1 when the layout is broken
+func gifLength(data []byte) int {
+	if len(data) < 13 {
+		return -1
+	}
+
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << ((flags & 0x07) + 1)
+	}
+
+	skipSubBlocks := func() bool {
+		for i < len(data) {
+			size := int(data[i])
+			i++
+			if size == 0 {
+				return true
+			}
+			i += size
+		}
+		return false
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x3B:
+			return i + 1
+		case 0x21: // Extension, label then sub-blocks
+			i += 2
+			if !skipSubBlocks() {
+				return -1
+			}
+		case 0x2C: // Image descriptor, optional local color table, LZW code size then sub-blocks
+			if i+10 > len(data) {
+				return -1
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << ((flags & 0x07) + 1)
+			}
+			i++
+			if !skipSubBlocks() {
+				return -1
+			}
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// checkTrailing cuts data at end, refusing files that don't parse or carry more than zero padding past it
+func checkTrailing(data []byte, end int) ([]byte, error) {
+	if end <= 0 || end > len(data) {
+		return nil, ErrUnrecognizedImage
+	}
+	if !allZero(data[end:]) {
+		return nil, ErrPolyglot
+	}
+	return data[:end], nil
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,208 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := range 8 {
+		for y := range 8 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 32), G: uint8(y * 32), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withAPP1 puts an APP1 segment carrying payload right after the JPEG's SOI marker
+func withAPP1(data, payload []byte) []byte {
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// withPNGChunk puts a chunk right before IEND, the CRC isn't checked by Sanitize
+func withPNGChunk(data []byte, typ string, payload []byte) []byte {
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], typ)
+	chunk = append(chunk, payload...)
+	chunk = append(chunk, 0, 0, 0, 0)
+
+	iend := len(data) - 12
+	out := append([]byte{}, data[:iend]...)
+	out = append(out, chunk...)
+	return append(out, data[iend:]...)
+}
+
+func webpFile(payload []byte) []byte {
+	out := []byte("RIFF\x00\x00\x00\x00WEBP")
+	out = append(out, payload...)
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+func bmpFile(size int) []byte {
+	out := make([]byte, size)
+	copy(out, "BM")
+	binary.LittleEndian.PutUint32(out[2:], uint32(size))
+	return out
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		ext         string
+		ok          bool
+	}{
+		{"jpeg", encodeJPEG(t), "image/jpeg", ".jpg", true},
+		{"png", encodePNG(t), "image/png", ".png", true},
+		{"gif", encodeGIF(t), "image/gif", ".gif", true},
+		{"webp", webpFile([]byte("VP8 ")), "image/webp", ".webp", true},
+		{"bmp", bmpFile(64), "image/bmp", ".bmp", true},
+		{"html", []byte("<html><script>alert(1)</script></html>"), "", "", false},
+		{"zip", []byte("PK\x03\x04rest of the archive"), "", "", false},
+		{"riff that is not webp", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), "", "", false},
+		{"truncated png magic", encodePNG(t)[:4], "", "", false},
+		{"empty", nil, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, ext, ok := Sniff(tt.data)
+			if contentType != tt.contentType || ext != tt.ext || ok != tt.ok {
+				t.Errorf("Sniff() = %q, %q, %v, want %q, %q, %v", contentType, ext, ok, tt.contentType, tt.ext, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSanitizeStripsJPEGMetadata(t *testing.T) {
+	exif := append([]byte("Exif\x00\x00"), []byte("GPS 48.8584 N 2.2945 E")...)
+	xmp := append([]byte("http://ns.adobe.com/xap/1.0/\x00"), []byte("<x:xmpmeta/>")...)
+	original := encodeJPEG(t)
+	tagged := withAPP1(withAPP1(original, exif), xmp)
+
+	clean, err := Sanitize(tagged, "image/jpeg", false)
+	if err != nil {
+		t.Fatalf("Sanitize() error = %v", err)
+	}
+	if bytes.Contains(clean, []byte("Exif")) || bytes.Contains(clean, []byte("GPS")) || bytes.Contains(clean, []byte("ns.adobe.com")) {
+		t.Error("metadata survived sanitizing")
+	}
+	if !bytes.Equal(clean, original) {
+		t.Errorf("sanitized JPEG is %d bytes, want the %d of the untagged original", len(clean), len(original))
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(clean)); err != nil {
+		t.Errorf("sanitized JPEG doesn't decode: %v", err)
+	}
+}
+
+func TestSanitizeKeepsJPEGMetadata(t *testing.T) {
+	exif := append([]byte("Exif\x00\x00"), []byte("GPS 48.8584 N 2.2945 E")...)
+	tagged := withAPP1(encodeJPEG(t), exif)
+
+	clean, err := Sanitize(tagged, "image/jpeg", true)
+	if err != nil {
+		t.Fatalf("Sanitize() error = %v", err)
+	}
+	if !bytes.Equal(clean, tagged) {
+		t.Error("keepMetadata changed the JPEG")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	pngData := encodePNG(t)
+	jpegData := encodeJPEG(t)
+	gifData := encodeGIF(t)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		want        []byte
+		err         error
+	}{
+		{"png", pngData, "image/png", pngData, nil},
+		{"jpeg", jpegData, "image/jpeg", jpegData, nil},
+		{"gif", gifData, "image/gif", gifData, nil},
+		{"webp", webpFile([]byte("VP8 \x00\x00\x00\x00")), "image/webp", webpFile([]byte("VP8 \x00\x00\x00\x00")), nil},
+		{"bmp", bmpFile(64), "image/bmp", bmpFile(64), nil},
+		{"zero padding is cut", append(bytes.Clone(pngData), 0, 0, 0, 0), "image/png", pngData, nil},
+		{"multi picture jpeg", append(bytes.Clone(jpegData), jpegData...), "image/jpeg", jpegData, nil},
+
+		{"html after png", append(bytes.Clone(pngData), "<html><script>alert(1)</script>"...), "image/png", nil, ErrPolyglot},
+		{"zip after jpeg", append(bytes.Clone(jpegData), "PK\x03\x04archive"...), "image/jpeg", nil, ErrPolyglot},
+		{"php after gif", append(bytes.Clone(gifData), "<?php system($_GET['c']); ?>"...), "image/gif", nil, ErrPolyglot},
+		{"data past the webp riff", append(webpFile([]byte("VP8 ")), "trailing"...), "image/webp", nil, ErrPolyglot},
+		{"data past the bmp size", append(bmpFile(64), "trailing"...), "image/bmp", nil, ErrPolyglot},
+		{"script in a png text chunk", withPNGChunk(pngData, "tEXt", []byte("Comment\x00<script>alert(1)</script>")), "image/png", nil, ErrPolyglot},
+		{"svg in a jpeg comment", withAPP1(jpegData, []byte("<svg onload=alert(1)>")), "image/jpeg", nil, ErrPolyglot},
+
+		{"truncated png", pngData[:len(pngData)-12], "image/png", nil, ErrUnrecognizedImage},
+		{"truncated jpeg", jpegData[:len(jpegData)/2], "image/jpeg", nil, ErrUnrecognizedImage},
+		{"webp longer than the file", webpFile(nil)[:8], "image/webp", nil, ErrUnrecognizedImage},
+		{"unsupported type", pngData, "image/svg+xml", nil, ErrUnrecognizedImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sanitize(tt.data, tt.contentType, false)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Sanitize() error = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && !bytes.Equal(got, tt.want) {
+				t.Errorf("Sanitize() returned %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSanitizeFindsMarkupInAnyCase(t *testing.T) {
+	data := withPNGChunk(encodePNG(t), "tEXt", []byte("Comment\x00<ScRiPt src=//evil>"))
+
+	if _, err := Sanitize(data, "image/png", false); !errors.Is(err, ErrPolyglot) {
+		t.Errorf("Sanitize() error = %v, want %v", err, ErrPolyglot)
+	}
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// AuditRetentionJob deletes audit log entries once they're older than the retention period
+type AuditRetentionJob struct {
+	auditLogRepository repository.AuditLogRepository
+	logger             *logger.Logger
+	retention          time.Duration
+	interval           time.Duration
+	stopChan           chan struct{}
+}
+
+func NewAuditRetentionJob(
+	auditLogRepository repository.AuditLogRepository,
+	logger *logger.Logger,
+	retention time.Duration,
+	interval time.Duration,
+) *AuditRetentionJob {
+	return &AuditRetentionJob{
+		auditLogRepository: auditLogRepository,
+		logger:             logger,
+		retention:          retention,
+		interval:           interval,
+		stopChan:           make(chan struct{}),
+	}
+}
+
+func (j *AuditRetentionJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	j.logger.Info("Audit retention job started",
+		zap.Duration("retention", j.retention),
+		zap.Duration("interval", j.interval),
+	)
+
+	j.runCleanup(ctx)
+
+	for {
+		select {
+		case <-ticker.C:
+			j.runCleanup(ctx)
+		case <-j.stopChan:
+			j.logger.Info("Audit retention job stopped")
+			return
+		case <-ctx.Done():
+			j.logger.Info("Audit retention job context cancelled")
+			return
+		}
+	}
+}
+
+func (j *AuditRetentionJob) Stop() {
+	close(j.stopChan)
+}
+
+func (j *AuditRetentionJob) runCleanup(ctx context.Context) {
+	startTime := time.Now()
+
+	deleted, err := j.auditLogRepository.DeleteAuditLogsBefore(ctx, startTime.Add(-j.retention))
+	if err != nil {
+		j.logger.Error("Audit retention job failed",
+			zap.Error(err),
+			zap.Duration("duration", time.Since(startTime)),
+		)
+		return
+	}
+
+	j.logger.Debug("Audit retention job completed",
+		zap.Int64("deleted", deleted),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/application/usecases/message"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// MessageCleanupJob applies each room's retention setting. It runs far more often than
+// the file job since the shortest retention a room can pick is an hour.
+type MessageCleanupJob struct {
+	messageUseCase message.MessageUseCase
+	roomRepository repository.RoomRepository
+	logger         *logger.Logger
+	interval       time.Duration
+	stopChan       chan struct{}
+}
+
+func NewMessageCleanupJob(
+	messageUseCase message.MessageUseCase,
+	roomRepository repository.RoomRepository,
+	logger *logger.Logger,
+	interval time.Duration,
+) *MessageCleanupJob {
+	return &MessageCleanupJob{
+		messageUseCase: messageUseCase,
+		roomRepository: roomRepository,
+		logger:         logger,
+		interval:       interval,
+		stopChan:       make(chan struct{}),
+	}
+}
+
+func (j *MessageCleanupJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	j.logger.Info("Message cleanup job started",
+		zap.Duration("interval", j.interval),
+	)
+
+	j.runCleanup(ctx)
+
+	for {
+		select {
+		case <-ticker.C:
+			j.runCleanup(ctx)
+		case <-j.stopChan:
+			j.logger.Info("Message cleanup job stopped")
+			return
+		case <-ctx.Done():
+			j.logger.Info("Message cleanup job context cancelled")
+			return
+		}
+	}
+}
+
+func (j *MessageCleanupJob) Stop() {
+	close(j.stopChan)
+}
+
+func (j *MessageCleanupJob) runCleanup(ctx context.Context) {
+	startTime := time.Now()
+
+	rooms, err := j.roomRepository.GetAll(ctx)
+	if err != nil {
+		j.logger.Error("Message cleanup job failed to list rooms", zap.Error(err))
+		return
+	}
+
+	roomIDs := make([]string, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	if err := j.messageUseCase.CleanupAllOldMessages(ctx, roomIDs); err != nil {
+		j.logger.Error("Message cleanup job failed",
+			zap.Error(err),
+			zap.Duration("duration", time.Since(startTime)),
+		)
+		return
+	}
+
+	j.logger.Debug("Message cleanup job completed",
+		zap.Int("rooms", len(roomIDs)),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+}
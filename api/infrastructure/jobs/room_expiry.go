@@ -0,0 +1,197 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilthontt/visper/api/application/usecases/file"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/events"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
+	"github.com/hilthontt/visper/api/infrastructure/websocket"
+	"go.uber.org/zap"
+)
+
+// RoomExpiryJob deletes rooms whose expiry has passed. Without it an expired room is
+// only removed when somebody happens to fetch it, its messages and files stay behind.
+// Rooms about to expire get a warning at each of the warnBefore thresholds.
+type RoomExpiryJob struct {
+	roomRepository    repository.RoomRepository
+	messageRepository repository.MessageRepository
+	fileUseCase       file.FileUseCase
+	wsCore            *websocket.Core
+	eventPublisher    *events.EventPublisher
+	metrics           metrics.Manager
+	logger            *logger.Logger
+	interval          time.Duration
+	warnBefore        []time.Duration
+	stopChan          chan struct{}
+
+	// warned holds the smallest threshold each room was last warned at, only the job's goroutine touches it
+	warned map[string]time.Duration
+}
+
+func NewRoomExpiryJob(
+	roomRepository repository.RoomRepository,
+	messageRepository repository.MessageRepository,
+	fileUseCase file.FileUseCase,
+	wsCore *websocket.Core,
+	eventPublisher *events.EventPublisher,
+	metrics metrics.Manager,
+	logger *logger.Logger,
+	interval time.Duration,
+	warnBefore []time.Duration,
+) *RoomExpiryJob {
+	return &RoomExpiryJob{
+		roomRepository:    roomRepository,
+		messageRepository: messageRepository,
+		fileUseCase:       fileUseCase,
+		wsCore:            wsCore,
+		eventPublisher:    eventPublisher,
+		metrics:           metrics,
+		logger:            logger,
+		interval:          interval,
+		warnBefore:        warnBefore,
+		stopChan:          make(chan struct{}),
+		warned:            make(map[string]time.Duration),
+	}
+}
+
+func (j *RoomExpiryJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	j.logger.Info("Room expiry job started",
+		zap.Duration("interval", j.interval),
+	)
+
+	j.runReap(ctx)
+
+	for {
+		select {
+		case <-ticker.C:
+			j.runReap(ctx)
+		case <-j.stopChan:
+			j.logger.Info("Room expiry job stopped")
+			return
+		case <-ctx.Done():
+			j.logger.Info("Room expiry job context cancelled")
+			return
+		}
+	}
+}
+
+func (j *RoomExpiryJob) Stop() {
+	close(j.stopChan)
+}
+
+func (j *RoomExpiryJob) runReap(ctx context.Context) {
+	startTime := time.Now()
+
+	rooms, err := j.roomRepository.GetAll(ctx)
+	if err != nil {
+		j.logger.Error("Room expiry job failed to list rooms", zap.Error(err))
+		return
+	}
+
+	reaped := 0
+	seen := make(map[string]bool, len(rooms))
+	for _, room := range rooms {
+		seen[room.ID] = true
+
+		if !room.HasExpired() {
+			j.warn(room)
+			continue
+		}
+
+		if err := j.reap(ctx, room); err != nil {
+			j.logger.Error("Room expiry job failed to delete room",
+				zap.String("roomID", room.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		reaped++
+	}
+
+	for roomID := range j.warned {
+		if !seen[roomID] {
+			delete(j.warned, roomID)
+		}
+	}
+
+	j.logger.Debug("Room expiry job completed",
+		zap.Int("rooms", len(rooms)),
+		zap.Int("reaped", reaped),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+}
+
+// warn sends one warning per threshold crossed. A room extended past its thresholds is
+// forgotten so it gets warned again as the new expiry comes up.
+func (j *RoomExpiryJob) warn(room *model.Room) {
+	if room.Expiry <= 0 {
+		return
+	}
+
+	expiresAt := room.CreatedAt.Add(room.Expiry)
+	remaining := time.Until(expiresAt)
+
+	var threshold time.Duration
+	for _, t := range j.warnBefore {
+		if remaining <= t && (threshold == 0 || t < threshold) {
+			threshold = t
+		}
+	}
+
+	if threshold == 0 {
+		delete(j.warned, room.ID)
+		return
+	}
+
+	if last, ok := j.warned[room.ID]; ok && last <= threshold {
+		return
+	}
+
+	j.warned[room.ID] = threshold
+	j.wsCore.Broadcast() <- websocket.NewRoomExpiringSoon(room.ID, expiresAt)
+}
+
+// reap removes the room last, a failure part way leaves it listed so the next run retries
+func (j *RoomExpiryJob) reap(ctx context.Context, room *model.Room) error {
+	messageCount, err := j.messageRepository.Count(ctx, room.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := j.messageRepository.DeleteOldMessages(ctx, room.ID, time.Now()); err != nil {
+		return err
+	}
+
+	if err := j.fileUseCase.DeleteRoomFiles(ctx, room.ID); err != nil {
+		return err
+	}
+
+	if err := j.roomRepository.Delete(ctx, room.ID); err != nil {
+		return err
+	}
+
+	delete(j.warned, room.ID)
+	j.metrics.IncrementCounter(ctx, "rooms_reaped")
+	j.wsCore.Broadcast() <- websocket.NewRoomExpired(room.ID, room.CreatedAt.Add(room.Expiry))
+
+	if err := j.eventPublisher.PublishRoomExpired(ctx, room.ID, int(messageCount)); err != nil {
+		j.logger.Warn("Failed to publish room expired event",
+			zap.String("roomID", room.ID),
+			zap.Error(err),
+		)
+	}
+
+	j.logger.Info("Expired room deleted",
+		zap.String("roomID", room.ID),
+		zap.Int64("messages", messageCount),
+	)
+	return nil
+}
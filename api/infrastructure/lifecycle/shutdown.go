@@ -0,0 +1,77 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// Coordinator stops the application one stage at a time, in the order the stages were added,
+// so a subsystem is only torn down once nothing that depends on it is still running.
+// All stages share one deadline. A stage that overruns it is abandoned, the ones after it
+// are still started so connections get closed but nothing waits on them anymore.
+type Coordinator struct {
+	stages []stage
+	logger *logger.Logger
+}
+
+type stage struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func NewCoordinator(logger *logger.Logger) *Coordinator {
+	return &Coordinator{logger: logger}
+}
+
+// Add appends a stage, stop should return once its subsystem is done or ctx is
+func (c *Coordinator) Add(name string, stop func(ctx context.Context) error) {
+	c.stages = append(c.stages, stage{name: name, stop: stop})
+}
+
+// Shutdown runs every stage and returns their errors joined
+func (c *Coordinator) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, s := range c.stages {
+		start := time.Now()
+		if err := c.run(ctx, s); err != nil {
+			c.logger.Error("shutdown stage failed",
+				zap.String("stage", s.name),
+				zap.Duration("duration", time.Since(start)),
+				zap.Error(err),
+			)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+
+		c.logger.Info("shutdown stage finished",
+			zap.String("stage", s.name),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Coordinator) run(ctx context.Context, s stage) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panicked: %v", r)
+			}
+		}()
+		done <- s.stop(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
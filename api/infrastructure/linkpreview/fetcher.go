@@ -0,0 +1,161 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+)
+
+const (
+	fetchTimeout = 5 * time.Second
+	maxRedirects = 3
+
+	// Open Graph tags live in <head>, there's no reason to read past it on large pages
+	maxBodySize = 512 << 10
+
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+
+	userAgent = "VisperBot/1.0 (+link preview)"
+)
+
+var (
+	ErrUnsupportedURL = errors.New("url is not eligible for a preview")
+	ErrNoMetadata     = errors.New("page has no preview metadata")
+)
+
+// Fetcher pulls Open Graph metadata from public web pages
+type Fetcher struct {
+	client *http.Client
+}
+
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:   fetchTimeout,
+			Transport: security.NewOutboundTransport(fetchTimeout),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return validateURL(req.URL)
+			},
+		},
+	}
+}
+
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*model.LinkPreview, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrUnsupportedURL
+	}
+	if err := validateURL(target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNoMetadata
+	}
+
+	meta := parseMetadata(io.LimitReader(resp.Body, maxBodySize))
+
+	preview := &model.LinkPreview{
+		URL:         target.String(),
+		Title:       truncate(firstNonEmpty(meta["og:title"], meta["twitter:title"], meta["title"]), maxTitleLength),
+		Description: truncate(firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength),
+		SiteName:    truncate(meta["og:site_name"], maxTitleLength),
+		ImageURL:    resolveImage(resp.Request.URL, firstNonEmpty(meta["og:image"], meta["twitter:image"])),
+	}
+
+	if preview.Title == "" && preview.Description == "" {
+		return nil, ErrNoMetadata
+	}
+
+	return preview, nil
+}
+
+// validateURL only lets plain http(s) on default ports through, the address itself is checked at dial time
+func validateURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrUnsupportedURL
+	}
+	if u.User != nil || u.Hostname() == "" {
+		return ErrUnsupportedURL
+	}
+
+	switch u.Port() {
+	case "", "80", "443":
+	default:
+		return ErrUnsupportedURL
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !security.IsPublicIP(ip) {
+		return security.ErrBlockedAddress
+	}
+	return nil
+}
+
+// Images are rendered by clients, so only absolute http(s) URLs are passed along
+func resolveImage(base *url.URL, image string) string {
+	if image == "" {
+		return ""
+	}
+
+	ref, err := url.Parse(image)
+	if err != nil {
+		return ""
+	}
+
+	resolved := base.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ""
+	}
+	return resolved.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func truncate(value string, limit int) string {
+	value = strings.Join(strings.Fields(value), " ")
+
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,78 @@
+package linkpreview
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// FirstURL returns the first http(s) link in the text, trailing punctuation from the sentence is dropped
+func FirstURL(text string) string {
+	match := urlPattern.FindString(text)
+	return strings.TrimRight(match, ".,;:!?)]}")
+}
+
+// parseMetadata collects <meta> properties and the <title> until the head is over
+func parseMetadata(r io.Reader) map[string]string {
+	meta := make(map[string]string)
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return meta
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Title:
+				inTitle = true
+			case atom.Meta:
+				readMeta(token, meta)
+			case atom.Body:
+				return meta
+			}
+
+		case html.TextToken:
+			if inTitle {
+				if _, ok := meta["title"]; !ok {
+					meta["title"] = strings.TrimSpace(string(tokenizer.Text()))
+				}
+			}
+
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				return meta
+			}
+		}
+	}
+}
+
+func readMeta(token html.Token, meta map[string]string) {
+	var key, content string
+	for _, attr := range token.Attr {
+		switch strings.ToLower(attr.Key) {
+		case "property", "name":
+			key = strings.ToLower(strings.TrimSpace(attr.Val))
+		case "content":
+			content = strings.TrimSpace(attr.Val)
+		}
+	}
+
+	// The first tag wins, pages sometimes repeat og:image for alternates
+	if key != "" && content != "" {
+		if _, ok := meta[key]; !ok {
+			meta[key] = content
+		}
+	}
+}
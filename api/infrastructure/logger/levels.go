@@ -0,0 +1,232 @@
+package logger
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Subsystems that can be given a level of their own, until they are they follow the root level
+const (
+	SubsystemWS     = "ws"
+	SubsystemRepo   = "repo"
+	SubsystemEvents = "events"
+)
+
+var Subsystems = []string{SubsystemWS, SubsystemRepo, SubsystemEvents}
+
+// levels is shared by the root logger and every subsystem logger made from it
+type levels struct {
+	root       zap.AtomicLevel
+	subsystems map[string]*subsystemLevel
+	core       zapcore.Core
+	options    []zap.Option
+
+	mu sync.Mutex
+	// Configure's arguments, Reset goes back to them
+	configuredLevel      string
+	configuredSubsystems []string
+	// The root level before ToggleDebug raised it to debug
+	beforeDebug *zapcore.Level
+}
+
+func newLevels(root zap.AtomicLevel, core zapcore.Core, options []zap.Option) *levels {
+	subsystems := make(map[string]*subsystemLevel, len(Subsystems))
+	for _, name := range Subsystems {
+		subsystems[name] = &subsystemLevel{root: root, own: zap.NewAtomicLevel()}
+	}
+	return &levels{root: root, subsystems: subsystems, core: core, options: options}
+}
+
+type subsystemLevel struct {
+	root zap.AtomicLevel
+	own  zap.AtomicLevel
+	set  atomic.Bool
+}
+
+func (s *subsystemLevel) Enabled(level zapcore.Level) bool {
+	return s.Level().Enabled(level)
+}
+
+func (s *subsystemLevel) Level() zapcore.Level {
+	if s.set.Load() {
+		return s.own.Level()
+	}
+	return s.root.Level()
+}
+
+// levelCore puts a level on top of a core that lets everything through
+type levelCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.enabler.Enabled(level)
+}
+
+func (c *levelCore) Level() zapcore.Level {
+	return zapcore.LevelOf(c.enabler)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), enabler: c.enabler}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabler.Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// Subsystem returns a logger named after the subsystem that logs at the subsystem's level.
+// An unknown name gets the root level.
+func (l *Logger) Subsystem(name string) *Logger {
+	var enabler zapcore.LevelEnabler = l.levels.root
+	if sub, ok := l.levels.subsystems[name]; ok {
+		enabler = sub
+	}
+
+	log := zap.New(&levelCore{Core: l.levels.core, enabler: enabler}, l.levels.options...).Named(name)
+	return &Logger{Log: log, levels: l.levels}
+}
+
+// Configure sets the root level and the subsystem levels as "name=level" pairs, subsystems
+// left out follow the root level again. Nothing changes unless every level is valid.
+func (l *Logger) Configure(level string, subsystemLevels []string) error {
+	own, err := parseSubsystemLevels(subsystemLevels)
+	if err != nil {
+		return err
+	}
+	if level != "" {
+		if _, err := zapcore.ParseLevel(level); err != nil {
+			return err
+		}
+	}
+
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	_ = l.setLevel(level)
+	for name, sub := range l.levels.subsystems {
+		parsed, ok := own[name]
+		if ok {
+			sub.own.SetLevel(parsed)
+		}
+		sub.set.Store(ok)
+	}
+
+	l.levels.configuredLevel = level
+	l.levels.configuredSubsystems = subsystemLevels
+	l.levels.beforeDebug = nil
+	return nil
+}
+
+// Reset goes back to the levels last given to Configure
+func (l *Logger) Reset() error {
+	l.levels.mu.Lock()
+	level, subsystems := l.levels.configuredLevel, l.levels.configuredSubsystems
+	l.levels.mu.Unlock()
+
+	return l.Configure(level, subsystems)
+}
+
+// SetLevel changes the lowest level logged, an empty level leaves it as it is
+func (l *Logger) SetLevel(level string) error {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	return l.setLevel(level)
+}
+
+func (l *Logger) setLevel(level string) error {
+	if level == "" {
+		return nil
+	}
+
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.levels.root.SetLevel(parsed)
+	l.levels.beforeDebug = nil
+	return nil
+}
+
+// SetSubsystemLevel gives a subsystem its own level, an empty level has it follow the root again
+func (l *Logger) SetSubsystemLevel(name, level string) error {
+	sub, ok := l.levels.subsystems[name]
+	if !ok {
+		return fmt.Errorf("unknown log subsystem %q", name)
+	}
+
+	if level == "" {
+		sub.set.Store(false)
+		return nil
+	}
+
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	sub.own.SetLevel(parsed)
+	sub.set.Store(true)
+	return nil
+}
+
+// Levels reports the root level and every subsystem's own, empty for those following the root
+func (l *Logger) Levels() (string, map[string]string) {
+	subsystems := make(map[string]string, len(l.levels.subsystems))
+	for name, sub := range l.levels.subsystems {
+		if sub.set.Load() {
+			subsystems[name] = sub.own.Level().String()
+		} else {
+			subsystems[name] = ""
+		}
+	}
+	return l.levels.root.Level().String(), subsystems
+}
+
+// ToggleDebug switches the root level to debug, or back to what it was before. It reports
+// the level now in effect.
+func (l *Logger) ToggleDebug() string {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	if l.levels.beforeDebug != nil {
+		l.levels.root.SetLevel(*l.levels.beforeDebug)
+		l.levels.beforeDebug = nil
+	} else {
+		previous := l.levels.root.Level()
+		l.levels.root.SetLevel(zapcore.DebugLevel)
+		l.levels.beforeDebug = &previous
+	}
+	return l.levels.root.Level().String()
+}
+
+func parseSubsystemLevels(pairs []string) (map[string]zapcore.Level, error) {
+	parsed := make(map[string]zapcore.Level, len(pairs))
+	for _, pair := range pairs {
+		name, level, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("subsystem level %q should be name=level", pair)
+		}
+		if !slices.Contains(Subsystems, name) {
+			return nil, fmt.Errorf("unknown log subsystem %q", name)
+		}
+
+		lvl, err := zapcore.ParseLevel(strings.TrimSpace(level))
+		if err != nil {
+			return nil, fmt.Errorf("subsystem %s: %w", name, err)
+		}
+		parsed[name] = lvl
+	}
+	return parsed, nil
+}
@@ -3,6 +3,7 @@ package logger
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
@@ -13,10 +14,23 @@ import (
 )
 
 type Logger struct {
-	Log *zap.Logger
+	Log    *zap.Logger
+	levels *levels
 }
 
-func NewLogger() (*Logger, error) {
+// Options picks how logs are written. Sampling keeps the first SamplingInitial entries
+// with the same message each second and then every SamplingThereafter-th, zero turns it off.
+type Options struct {
+	Encoding           string // json, the default, or console
+	Level              string // info when empty
+	Development        bool   // Debug by default and stack traces from error up
+	SamplingInitial    int
+	SamplingThereafter int
+	// SubsystemLevels gives subsystems a level of their own as "name=level"
+	SubsystemLevels []string
+}
+
+func New(opts Options) (*Logger, error) {
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "timestamp",
 		LevelKey:       "level",
@@ -32,47 +46,45 @@ func NewLogger() (*Logger, error) {
 		EncodeName:     zapcore.FullNameEncoder,
 	}
 
-	core := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		zapcore.AddSync(os.Stdout),
-		zap.NewAtomicLevelAt(zap.InfoLevel),
-	)
-
-	logger := zap.New(core)
+	var encoder zapcore.Encoder
+	switch opts.Encoding {
+	case "", "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("unknown log encoding %q", opts.Encoding)
+	}
 
-	return &Logger{
-		Log: logger,
-	}, nil
-}
+	root := zap.NewAtomicLevelAt(zap.InfoLevel)
+	var zapOptions []zap.Option
+	if opts.Development {
+		root.SetLevel(zap.DebugLevel)
+		zapOptions = append(zapOptions, zap.AddStacktrace(zap.ErrorLevel))
+	}
 
-// NewDevelopmentLogger creates a logger for development with more debug information
-func NewDevelopmentLogger() (*Logger, error) {
-	encoderConfig := zapcore.EncoderConfig{
-		TimeKey:        "timestamp",
-		LevelKey:       "level",
-		NameKey:        "logger",
-		CallerKey:      "caller",
-		MessageKey:     "msg",
-		StacktraceKey:  "stacktrace",
-		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.CapitalLevelEncoder,
-		EncodeTime:     zapcore.ISO8601TimeEncoder,
-		EncodeDuration: zapcore.StringDurationEncoder,
-		EncodeCaller:   zapcore.FullCallerEncoder,
-		EncodeName:     zapcore.FullNameEncoder,
+	// The core itself lets everything through, the root and every subsystem put their own level on top
+	var core zapcore.Core = zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), zapcore.DebugLevel)
+	if opts.SamplingInitial > 0 && opts.SamplingThereafter > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, opts.SamplingInitial, opts.SamplingThereafter)
 	}
 
-	core := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		zapcore.AddSync(os.Stdout),
-		zap.NewAtomicLevelAt(zap.DebugLevel),
-	)
+	l := &Logger{levels: newLevels(root, core, zapOptions)}
+	l.Log = zap.New(&levelCore{Core: core, enabler: root}, zapOptions...)
 
-	logger := zap.New(core, zap.AddStacktrace(zap.ErrorLevel))
+	if err := l.Configure(opts.Level, opts.SubsystemLevels); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
 
-	return &Logger{
-		Log: logger,
-	}, nil
+func NewLogger() (*Logger, error) {
+	return New(Options{})
+}
+
+// NewDevelopmentLogger creates a logger for development with more debug information
+func NewDevelopmentLogger() (*Logger, error) {
+	return New(Options{Development: true})
 }
 
 func (l *Logger) Info(msg string, fields ...zap.Field) {
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package logger
+
+import "context"
+
+// WatchSignals does nothing where there is no SIGUSR1 or SIGUSR2, the API still changes levels
+func (l *Logger) WatchSignals(ctx context.Context) {
+	<-ctx.Done()
+}
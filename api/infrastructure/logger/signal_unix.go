@@ -0,0 +1,39 @@
+//go:build unix
+
+package logger
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// WatchSignals lets an operator change levels without the API: SIGUSR1 toggles debug
+// logging, SIGUSR2 goes back to the configured levels
+func (l *Logger) WatchSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			if sig == syscall.SIGUSR1 {
+				l.Log.Warn("log level toggled by signal", zap.String("level", l.ToggleDebug()))
+				continue
+			}
+
+			if err := l.Reset(); err != nil {
+				l.Log.Error("failed to reset log levels", zap.Error(err))
+				continue
+			}
+			level, _ := l.Levels()
+			l.Log.Warn("log levels reset by signal", zap.String("level", level))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package maintenance
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultMessage = "Visper is undergoing maintenance and is currently read-only. Please try again shortly."
+
+// Mode holds the read-only maintenance flag. It is seeded from config at startup
+// and can be flipped at runtime through the admin API.
+type Mode struct {
+	mu        sync.RWMutex
+	enabled   bool
+	message   string
+	changedAt time.Time
+}
+
+type State struct {
+	Enabled   bool      `json:"enabled"`
+	Message   string    `json:"message"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+func NewMode(enabled bool, message string) *Mode {
+	if message == "" {
+		message = DefaultMessage
+	}
+
+	return &Mode{
+		enabled:   enabled,
+		message:   message,
+		changedAt: time.Now(),
+	}
+}
+
+func (m *Mode) IsEnabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.enabled
+}
+
+func (m *Mode) Message() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.message
+}
+
+// Set toggles maintenance mode. An empty message keeps the current one.
+func (m *Mode) Set(enabled bool, message string) State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.enabled = enabled
+	if message != "" {
+		m.message = message
+	}
+	m.changedAt = time.Now()
+
+	return State{
+		Enabled:   m.enabled,
+		Message:   m.message,
+		ChangedAt: m.changedAt,
+	}
+}
+
+func (m *Mode) State() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return State{
+		Enabled:   m.enabled,
+		Message:   m.message,
+		ChangedAt: m.changedAt,
+	}
+}
@@ -0,0 +1,73 @@
+package exporters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// OTLPProtocol is how spans reach the collector
+type OTLPProtocol string
+
+const (
+	OTLPGRPC OTLPProtocol = "grpc"
+	OTLPHTTP OTLPProtocol = "http"
+)
+
+const (
+	defaultOTLPGRPCEndpoint = "localhost:4317"
+	defaultOTLPHTTPEndpoint = "http://localhost:4318"
+	otlpTracesPath          = "/v1/traces"
+	otlpExportTimeout       = 10 * time.Second
+)
+
+// NewOTLPExporter sends spans to an OpenTelemetry collector at endpoint, host:port for gRPC
+// and a base URL for HTTP. insecure only applies to gRPC, over HTTP the URL's scheme
+// decides. Exports are gzipped and retried by the exporter.
+func NewOTLPExporter(ctx context.Context, protocol OTLPProtocol, endpoint string, insecure bool, headers map[string]string) (sdktrace.SpanExporter, error) {
+	switch protocol {
+	case OTLPGRPC:
+		if endpoint == "" {
+			endpoint = defaultOTLPGRPCEndpoint
+		}
+		if strings.Contains(endpoint, "://") {
+			return nil, fmt.Errorf("otlp grpc endpoint %q should be host:port", endpoint)
+		}
+
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(endpoint),
+			otlptracegrpc.WithHeaders(headers),
+			otlptracegrpc.WithCompressor("gzip"),
+			otlptracegrpc.WithTimeout(otlpExportTimeout),
+		}
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case OTLPHTTP:
+		if endpoint == "" {
+			endpoint = defaultOTLPHTTPEndpoint
+		}
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			return nil, fmt.Errorf("otlp http endpoint %q should be a URL", endpoint)
+		}
+		url := strings.TrimSuffix(endpoint, "/")
+		if !strings.HasSuffix(url, otlpTracesPath) {
+			url += otlpTracesPath
+		}
+
+		return otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(url),
+			otlptracehttp.WithHeaders(headers),
+			otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+			otlptracehttp.WithTimeout(otlpExportTimeout),
+		)
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol %q", protocol)
+	}
+}
@@ -0,0 +1,80 @@
+package exporters
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hilthontt/visper/api/infrastructure/config"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newSampler decides for root spans only, the rest of a trace follows its root so traces
+// are either kept whole or dropped whole
+func newSampler(cfg config.TracingConfig) (sdktrace.Sampler, error) {
+	var root sdktrace.Sampler
+	switch cfg.Sampler {
+	case "", "always":
+		root = sdktrace.AlwaysSample()
+	case "never":
+		root = sdktrace.NeverSample()
+	case "ratio":
+		root = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	case "ratelimited":
+		root = NewRateLimitedSampler(cfg.SamplesPerSecond)
+	default:
+		return nil, fmt.Errorf("unknown sampler %q", cfg.Sampler)
+	}
+
+	return sdktrace.ParentBased(root), nil
+}
+
+// RateLimitedSampler keeps at most perSecond traces a second, with bursts up to a second's
+// worth but at least one. Unlike a ratio it caps the cost of tracing during a traffic spike.
+type RateLimitedSampler struct {
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	mu        sync.Mutex
+}
+
+func NewRateLimitedSampler(perSecond float64) *RateLimitedSampler {
+	return &RateLimitedSampler{
+		perSecond: perSecond,
+		burst:     max(perSecond, 1),
+		tokens:    max(perSecond, 1),
+		last:      time.Now(),
+	}
+}
+
+func (s *RateLimitedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if s.take(time.Now()) {
+		decision = sdktrace.RecordAndSample
+	}
+
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *RateLimitedSampler) Description() string {
+	return fmt.Sprintf("RateLimitedSampler{%g}", s.perSecond)
+}
+
+func (s *RateLimitedSampler) take(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = min(s.burst, s.tokens+now.Sub(s.last).Seconds()*s.perSecond)
+	s.last = now
+
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
@@ -2,7 +2,9 @@ package exporters
 
 import (
 	"context"
+	"os"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,43 +26,38 @@ const (
 
 var tracer trace.Tracer
 
-func InitJaegerExporter(config *config.Config) (*sdktrace.TracerProvider, error) {
+// InitTracerProvider sets up the configured exporter and sampler and makes the provider global
+func InitTracerProvider(config *config.Config) (*sdktrace.TracerProvider, error) {
 	if config.Jaeger.ServiceName == "" {
 		config.Jaeger.ServiceName = defaultAppName
 	}
 	if config.Jaeger.ServiceVersion == "" {
 		config.Jaeger.ServiceVersion = "unknown"
 	}
-	if config.Jaeger.Endpoint == "" {
-		config.Jaeger.Endpoint = defaultJaegerEndpoint
+
+	exp, err := newSpanExporter(config)
+	if err != nil {
+		return nil, err
 	}
 
-	exp, err := jaeger.New(
-		jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(config.Jaeger.Endpoint)),
-	)
+	sampler, err := newSampler(config.Tracing)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(config.Jaeger.ServiceName),
-			semconv.ServiceVersion(config.Jaeger.ServiceVersion),
-			attribute.String("go.version", runtime.Version()),
-			attribute.String("os", runtime.GOOS),
-			attribute.String("arch", runtime.GOARCH),
-		),
-	)
+	res, err := newResource(config)
 	if err != nil {
 		return nil, err
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sampler),
 		sdktrace.WithResource(res),
-	)
+	}
+	if exp != nil {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
 
 	otel.SetTracerProvider(tp)
 	tracer = tp.Tracer(tracerName)
@@ -68,17 +65,60 @@ func InitJaegerExporter(config *config.Config) (*sdktrace.TracerProvider, error)
 	return tp, nil
 }
 
-func SendTelemetryTrace(config *config.Config) {
-	tp, err := InitJaegerExporter(config)
-	if err != nil {
-		return
+// newSpanExporter returns nil when tracing.exporter is none, spans are then sampled but go nowhere
+func newSpanExporter(config *config.Config) (sdktrace.SpanExporter, error) {
+	switch config.Tracing.Exporter {
+	case "none":
+		return nil, nil
+	case "otlp-grpc":
+		return NewOTLPExporter(context.Background(), OTLPGRPC, config.Tracing.Endpoint, config.Tracing.Insecure, parsePairs(config.Tracing.Headers))
+	case "otlp-http":
+		return NewOTLPExporter(context.Background(), OTLPHTTP, config.Tracing.Endpoint, config.Tracing.Insecure, parsePairs(config.Tracing.Headers))
+	default:
+		if config.Jaeger.Endpoint == "" {
+			config.Jaeger.Endpoint = defaultJaegerEndpoint
+		}
+		return jaeger.New(
+			jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(config.Jaeger.Endpoint)),
+		)
+	}
+}
+
+func newResource(config *config.Config) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(config.Jaeger.ServiceName),
+		semconv.ServiceVersion(config.Jaeger.ServiceVersion),
+		attribute.String("go.version", runtime.Version()),
+		attribute.String("os", runtime.GOOS),
+		attribute.String("arch", runtime.GOARCH),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		attrs = append(attrs, semconv.HostName(hostname))
+	}
+	// Configured attributes come last so they can override the ones above
+	for key, value := range parsePairs(config.Tracing.ResourceAttributes) {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+
+	return resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, attrs...),
+	)
+}
+
+// parsePairs reads "key=value" entries, config validation already rejected malformed ones
+func parsePairs(pairs []string) map[string]string {
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
 	}
-	defer func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		_ = tp.Shutdown(ctx)
-	}()
+	return values
+}
 
+// SendTelemetryTrace records a startup span on tp, which has to come from InitTracerProvider
+func SendTelemetryTrace(config *config.Config, tp *sdktrace.TracerProvider) {
 	ctx := context.Background()
 	now := time.Now().UTC()
 
@@ -86,7 +126,6 @@ func SendTelemetryTrace(config *config.Config) {
 		trace.WithTimestamp(now),
 		trace.WithSpanKind(trace.SpanKindInternal),
 	)
-	defer span.End()
 
 	span.SetAttributes(
 		attribute.String("event.id", uuid.NewString()),
@@ -99,6 +138,8 @@ func SendTelemetryTrace(config *config.Config) {
 		attribute.String("startup.time", now.Format(time.RFC3339)),
 		attribute.Int("raw.data.size", 0),
 	)
+	// Ended before the flush, an open span isn't exported
+	span.End()
 
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
@@ -8,8 +8,11 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func GetHandler(router *gin.RouterGroup, m Manager) {
-	router.GET("/metrics", systemMetricsMiddleware(m), gin.WrapH(promhttp.Handler()))
+// Collector sets gauges that are only worth reading when metrics are scraped
+type Collector func(m Manager)
+
+func GetHandler(router *gin.RouterGroup, m Manager, collectors ...Collector) {
+	router.GET("/metrics", systemMetricsMiddleware(m, collectors), gin.WrapH(promhttp.Handler()))
 
 	pprofGroup := router.Group("/debug/pprof")
 	{
@@ -27,7 +30,7 @@ func GetHandler(router *gin.RouterGroup, m Manager) {
 	}
 }
 
-func systemMetricsMiddleware(m Manager) gin.HandlerFunc {
+func systemMetricsMiddleware(m Manager, collectors []Collector) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var stats runtime.MemStats
 		runtime.ReadMemStats(&stats)
@@ -38,6 +41,10 @@ func systemMetricsMiddleware(m Manager) gin.HandlerFunc {
 		m.SetGauge("app_go_numGC", float64(stats.NumGC))
 		m.SetGauge("app_go_sys", float64(stats.Sys))
 
+		for _, collect := range collectors {
+			collect(m)
+		}
+
 		ctx.Next()
 	}
 }
@@ -21,12 +21,18 @@ type Manager interface {
 	DeltaUpDownCounter(ctx context.Context, name string, value float64, labels ...string)
 	RecordHistogram(ctx context.Context, name string, value float64, labels ...string)
 	SetGauge(name string, value float64, labels ...string)
+
+	// HistogramSnapshot reads back what a histogram recorded since startup
+	HistogramSnapshot(name string) (HistogramSnapshot, bool)
 }
 
 type metricsManager struct {
 	meter  metric.Meter
 	store  Store
 	logger *logger.Logger
+
+	talliesMu sync.RWMutex
+	tallies   map[string]*histogramTally
 }
 
 // Developer Note: float64Gauge is used instead of metric.Float64ObservableGauge because we need a synchronous gauge metric
@@ -39,9 +45,10 @@ type float64Gauge struct {
 
 func NewMetricsManager(meter metric.Meter, logger *logger.Logger) Manager {
 	return &metricsManager{
-		meter:  meter,
-		store:  newOtelStore(),
-		logger: logger,
+		meter:   meter,
+		store:   newOtelStore(),
+		logger:  logger,
+		tallies: make(map[string]*histogramTally),
 	}
 }
 
@@ -125,7 +132,12 @@ func (m *metricsManager) NewHistogram(name, desc string, buckets ...float64) {
 	err = m.store.setHistogram(name, histogram)
 	if err != nil {
 		m.logger.Error("set-histogram", zap.Error(err))
+		return
 	}
+
+	m.talliesMu.Lock()
+	m.tallies[name] = newHistogramTally(buckets)
+	m.talliesMu.Unlock()
 }
 
 // callbackFunc implements the callback function for the underlying asynchronous gauge
@@ -206,6 +218,24 @@ func (m *metricsManager) RecordHistogram(ctx context.Context, name string, value
 	}
 
 	histogram.Record(ctx, value, metric.WithAttributes(m.getAttributes(name, labels...)...))
+
+	m.talliesMu.RLock()
+	tally := m.tallies[name]
+	m.talliesMu.RUnlock()
+	if tally != nil {
+		tally.observe(value)
+	}
+}
+
+func (m *metricsManager) HistogramSnapshot(name string) (HistogramSnapshot, bool) {
+	m.talliesMu.RLock()
+	tally, ok := m.tallies[name]
+	m.talliesMu.RUnlock()
+
+	if !ok {
+		return HistogramSnapshot{}, false
+	}
+	return tally.snapshot(), true
 }
 
 // SetGauge gets the value and sets the metric to the specified value.
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// HistogramSnapshot is how many values a histogram recorded in each bucket, over every
+// label set. Counts has one entry more than Bounds, for values above the last bound.
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+}
+
+// Since is what was recorded between prev and s, prev may be empty
+func (s HistogramSnapshot) Since(prev HistogramSnapshot) HistogramSnapshot {
+	delta := HistogramSnapshot{Bounds: s.Bounds, Counts: make([]uint64, len(s.Counts))}
+	for i, count := range s.Counts {
+		if i < len(prev.Counts) && prev.Counts[i] <= count {
+			count -= prev.Counts[i]
+		}
+		delta.Counts[i] = count
+	}
+	return delta
+}
+
+func (s HistogramSnapshot) Total() uint64 {
+	var total uint64
+	for _, count := range s.Counts {
+		total += count
+	}
+	return total
+}
+
+// Quantile estimates the q-quantile the way Prometheus' histogram_quantile does, by
+// interpolating within the bucket it falls in. Past the last bound it reports that bound.
+func (s HistogramSnapshot) Quantile(q float64) float64 {
+	total := s.Total()
+	if total == 0 || len(s.Bounds) == 0 {
+		return 0
+	}
+
+	rank := q * float64(total)
+	var below uint64
+	for i, count := range s.Counts {
+		if float64(below+count) < rank || count == 0 {
+			below += count
+			continue
+		}
+		if i == len(s.Bounds) {
+			break
+		}
+
+		lower := 0.0
+		if i > 0 {
+			lower = s.Bounds[i-1]
+		}
+		return lower + (s.Bounds[i]-lower)*(rank-float64(below))/float64(count)
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
+
+// histogramTally counts a histogram's values locally, the exporter keeps its own counts
+// but they can't be read back
+type histogramTally struct {
+	bounds []float64
+	counts []atomic.Uint64
+}
+
+func newHistogramTally(bounds []float64) *histogramTally {
+	return &histogramTally{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+// observe puts value in the first bucket whose bound is at least value, like the exporter
+func (t *histogramTally) observe(value float64) {
+	t.counts[sort.SearchFloat64s(t.bounds, value)].Add(1)
+}
+
+func (t *histogramTally) snapshot() HistogramSnapshot {
+	counts := make([]uint64, len(t.counts))
+	for i := range t.counts {
+		counts[i] = t.counts[i].Load()
+	}
+	return HistogramSnapshot{Bounds: t.bounds, Counts: counts}
+}
@@ -0,0 +1,61 @@
+package moderation
+
+import (
+	"context"
+	"slices"
+)
+
+// Verdict is what a filter thinks of a message, Terms lists whatever it matched on
+type Verdict struct {
+	Flagged bool
+	Terms   []string
+}
+
+type Filter interface {
+	Check(ctx context.Context, content string) (Verdict, error)
+}
+
+// Pipeline runs every registered filter and flags the message if any of them does
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{
+		filters: filters,
+	}
+}
+
+func (p *Pipeline) Register(filter Filter) {
+	p.filters = append(p.filters, filter)
+}
+
+// Check keeps going past a failing filter so one broken backend doesn't hide the others' matches,
+// the first error is still returned alongside the combined verdict
+func (p *Pipeline) Check(ctx context.Context, content string) (Verdict, error) {
+	var (
+		verdict  Verdict
+		firstErr error
+	)
+
+	for _, filter := range p.filters {
+		result, err := filter.Check(ctx, content)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if result.Flagged {
+			verdict.Flagged = true
+			for _, term := range result.Terms {
+				if !slices.Contains(verdict.Terms, term) {
+					verdict.Terms = append(verdict.Terms, term)
+				}
+			}
+		}
+	}
+
+	return verdict, firstErr
+}
@@ -0,0 +1,84 @@
+package moderation
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// DefaultWords is deliberately short, operators with stricter needs should register their own filter
+var DefaultWords = []string{
+	"asshole",
+	"bastard",
+	"bitch",
+	"bullshit",
+	"cunt",
+	"dick",
+	"fag",
+	"faggot",
+	"fuck",
+	"fucker",
+	"fucking",
+	"motherfucker",
+	"nigger",
+	"retard",
+	"shit",
+	"slut",
+	"whore",
+}
+
+// Undo the usual character swaps before matching
+var leetReplacer = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+	"@", "a",
+	"$", "s",
+)
+
+// WordlistFilter matches whole words, so "class" doesn't trip on "ass"
+type WordlistFilter struct {
+	words map[string]struct{}
+}
+
+func NewWordlistFilter(words []string) *WordlistFilter {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			set[word] = struct{}{}
+		}
+	}
+
+	return &WordlistFilter{
+		words: set,
+	}
+}
+
+func (f *WordlistFilter) Check(_ context.Context, content string) (Verdict, error) {
+	var verdict Verdict
+
+	normalized := leetReplacer.Replace(strings.ToLower(content))
+	tokens := strings.FieldsFunc(normalized, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]struct{})
+	for _, token := range tokens {
+		if _, ok := f.words[token]; !ok {
+			continue
+		}
+		if _, ok := seen[token]; ok {
+			continue
+		}
+
+		seen[token] = struct{}{}
+		verdict.Flagged = true
+		verdict.Terms = append(verdict.Terms, token)
+	}
+
+	return verdict, nil
+}
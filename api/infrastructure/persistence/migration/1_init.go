@@ -1,28 +1,20 @@
 package migration
 
 import (
-	"log"
-
 	"github.com/hilthontt/visper/api/domain/model"
-	"github.com/hilthontt/visper/api/infrastructure/persistence/database"
 	"gorm.io/gorm"
 )
 
-func Up1() {
-	database := database.GetDb()
-	createTables(database)
-}
-
-func createTables(database *gorm.DB) {
+// up1 predates the runner, deployments that ran it already have the table and skip it
+func up1(tx *gorm.DB) error {
 	tables := []any{}
 
-	tables = addNewTable(database, model.AuditLog{}, tables)
-
-	err := database.Migrator().CreateTable(tables...)
-	if err != nil {
-		log.Printf("Error migrating: %w\n", err)
+	tables = addNewTable(tx, model.AuditLog{}, tables)
+	if len(tables) == 0 {
+		return nil
 	}
-	log.Println("Tables Created")
+
+	return tx.Migrator().CreateTable(tables...)
 }
 
 func addNewTable(database *gorm.DB, model any, tables []any) []any {
@@ -0,0 +1,65 @@
+package migration
+
+import "gorm.io/gorm"
+
+// up2 adds the tables behind persistence.backend: postgres. Nested values the API never
+// filters on (settings, mentions, previews) are kept as JSONB rather than spread over tables.
+func up2(tx *gorm.DB) error {
+	statements := []string{
+		`CREATE TABLE users (
+			id         TEXT PRIMARY KEY,
+			username   TEXT NOT NULL,
+			is_guest   BOOLEAN NOT NULL DEFAULT false,
+			is_bot     BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)`,
+		`CREATE TABLE usernames (
+			username TEXT PRIMARY KEY,
+			user_id  TEXT NOT NULL
+		)`,
+		`CREATE TABLE rooms (
+			id             TEXT PRIMARY KEY,
+			join_code      TEXT NOT NULL,
+			secure_code    TEXT NOT NULL,
+			owner          JSONB NOT NULL,
+			created_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+			expiry         BIGINT NOT NULL DEFAULT 0,
+			encryption_key TEXT NOT NULL,
+			key_version    INTEGER NOT NULL DEFAULT 0,
+			retired_keys   JSONB,
+			settings       JSONB NOT NULL
+		)`,
+		`CREATE INDEX rooms_join_code_idx ON rooms (join_code)`,
+		`CREATE TABLE room_members (
+			room_id   TEXT NOT NULL REFERENCES rooms (id) ON DELETE CASCADE,
+			user_id   TEXT NOT NULL,
+			joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+			PRIMARY KEY (room_id, user_id)
+		)`,
+		`CREATE TABLE messages (
+			id                TEXT PRIMARY KEY,
+			room_id           TEXT NOT NULL,
+			user_id           TEXT NOT NULL,
+			username          TEXT NOT NULL,
+			content           TEXT NOT NULL,
+			created_at        TIMESTAMP WITH TIME ZONE NOT NULL,
+			updated_at        TIMESTAMP WITH TIME ZONE,
+			encrypted         BOOLEAN NOT NULL DEFAULT false,
+			key_version       INTEGER NOT NULL DEFAULT 0,
+			type              TEXT NOT NULL DEFAULT '',
+			parent_message_id TEXT NOT NULL DEFAULT '',
+			mentions          JSONB,
+			flagged           BOOLEAN NOT NULL DEFAULT false,
+			preview           JSONB
+		)`,
+		`CREATE INDEX messages_room_created_idx ON messages (room_id, created_at)`,
+		`CREATE INDEX messages_room_parent_idx ON messages (room_id, parent_message_id) WHERE parent_message_id <> ''`,
+	}
+
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
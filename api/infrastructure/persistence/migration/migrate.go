@@ -0,0 +1,76 @@
+package migration
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// advisoryLockKey serializes instances starting at the same time, any constant unique to Visper works
+const advisoryLockKey = 7_411_203
+
+// Migration runs once per database, in Version order, inside its own transaction.
+// Never edit one that has shipped, add a new one instead.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+var migrations = []Migration{
+	{Version: 1, Name: "audit_logs", Up: up1},
+	{Version: 2, Name: "rooms_messages_users", Up: up2},
+}
+
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:TEXT;not null"`
+	AppliedAt time.Time `gorm:"type:TIMESTAMP with time zone;not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Run applies every migration the database hasn't seen yet
+func Run(db *gorm.DB) error {
+	if err := db.Migrator().AutoMigrate(&schemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		applied := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Released on commit, a second instance waits here and then sees the version as applied
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", advisoryLockKey).Error; err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&schemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+
+			applied = true
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+
+		if applied {
+			log.Printf("Applied migration %d (%s)", m.Version, m.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type announcementRepository struct {
+	client redis.UniversalClient
+}
+
+func NewAnnouncementRepository(client redis.UniversalClient) repository.AnnouncementRepository {
+	return &announcementRepository{
+		client: client,
+	}
+}
+
+// Pin overwrites any earlier copy, so it also refreshes an edited announcement
+func (r *announcementRepository) Pin(ctx context.Context, message *model.Message, expiresAt time.Time) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	key := roomAnnouncementsKey(message.RoomID)
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, message.ID, data)
+	if !expiresAt.IsZero() {
+		pipe.ExpireAt(ctx, key, expiresAt)
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *announcementRepository) Unpin(ctx context.Context, roomID, messageID string) (bool, error) {
+	removed, err := r.client.HDel(ctx, roomAnnouncementsKey(roomID), messageID).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// GetAll returns the announcements oldest first, matching message history
+func (r *announcementRepository) GetAll(ctx context.Context, roomID string) ([]*model.Message, error) {
+	entries, err := r.client.HGetAll(ctx, roomAnnouncementsKey(roomID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get announcements: %w", err)
+	}
+
+	announcements := make([]*model.Message, 0, len(entries))
+	for _, data := range entries {
+		var message model.Message
+		if err := json.Unmarshal([]byte(data), &message); err != nil {
+			continue
+		}
+		announcements = append(announcements, &message)
+	}
+
+	sort.Slice(announcements, func(i, j int) bool {
+		return announcements[i].CreatedAt.Before(announcements[j].CreatedAt)
+	})
+
+	return announcements, nil
+}
+
+func roomAnnouncementsKey(roomID string) string {
+	return fmt.Sprintf("room:%s:announcements", roomID)
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const banKeyPrefix = "ban:user:"
+
+type banRepository struct {
+	client redis.UniversalClient
+}
+
+func NewBanRepository(client redis.UniversalClient) repository.BanRepository {
+	return &banRepository{
+		client: client,
+	}
+}
+
+func (r *banRepository) Create(ctx context.Context, ban *model.Ban, duration time.Duration) error {
+	ban.BannedAt = time.Now()
+	if duration > 0 {
+		ban.ExpiresAt = ban.BannedAt.Add(duration)
+	}
+
+	data, err := json.Marshal(ban)
+	if err != nil {
+		return err
+	}
+
+	key := banKeyPrefix + ban.UserID
+	return r.client.Set(ctx, key, data, duration).Err()
+}
+
+func (r *banRepository) GetByUserID(ctx context.Context, userID string) (*model.Ban, error) {
+	key := banKeyPrefix + userID
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var ban model.Ban
+	if err := json.Unmarshal(data, &ban); err != nil {
+		return nil, err
+	}
+
+	return &ban, nil
+}
+
+func (r *banRepository) GetAll(ctx context.Context) ([]*model.Ban, error) {
+	bans := make([]*model.Ban, 0)
+
+	keys, err := cache.ScanKeys(ctx, r.client, banKeyPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan bans: %w", err)
+	}
+
+	for _, key := range keys {
+		userID := strings.TrimPrefix(key, banKeyPrefix)
+		ban, err := r.GetByUserID(ctx, userID)
+		if err != nil {
+			continue // Ban might have expired in the meantime
+		}
+		bans = append(bans, ban)
+	}
+
+	return bans, nil
+}
+
+func (r *banRepository) Delete(ctx context.Context, userID string) error {
+	key := banKeyPrefix + userID
+	return r.client.Del(ctx, key).Err()
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type botRepository struct {
+	client redis.UniversalClient
+}
+
+func NewBotRepository(client redis.UniversalClient) repository.BotRepository {
+	return &botRepository{
+		client: client,
+	}
+}
+
+// Create stores the bot without an expiry, tokens live until the owner revokes them
+func (r *botRepository) Create(ctx context.Context, bot *model.Bot) error {
+	bot.CreatedAt = time.Now()
+
+	data, err := json.Marshal(bot)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, botKey(bot.ID), data, 0)
+	pipe.Set(ctx, botTokenKey(bot.TokenHash), bot.ID, 0)
+	pipe.SAdd(ctx, ownerBotsKey(bot.OwnerID), bot.ID)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *botRepository) GetByID(ctx context.Context, id string) (*model.Bot, error) {
+	data, err := r.client.Get(ctx, botKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var bot model.Bot
+	if err := json.Unmarshal(data, &bot); err != nil {
+		return nil, err
+	}
+
+	return &bot, nil
+}
+
+func (r *botRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.Bot, error) {
+	id, err := r.client.Get(ctx, botTokenKey(tokenHash)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+func (r *botRepository) GetByOwner(ctx context.Context, ownerID string) ([]*model.Bot, error) {
+	ids, err := r.client.SMembers(ctx, ownerBotsKey(ownerID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bots: %w", err)
+	}
+
+	bots := make([]*model.Bot, 0, len(ids))
+	for _, id := range ids {
+		bot, err := r.GetByID(ctx, id)
+		if err != nil || bot == nil {
+			continue
+		}
+		bots = append(bots, bot)
+	}
+
+	return bots, nil
+}
+
+func (r *botRepository) Delete(ctx context.Context, bot *model.Bot) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, botKey(bot.ID), botTokenKey(bot.TokenHash))
+	pipe.SRem(ctx, ownerBotsKey(bot.OwnerID), bot.ID)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func botKey(id string) string {
+	return fmt.Sprintf("bot:%s", id)
+}
+
+func botTokenKey(tokenHash string) string {
+	return fmt.Sprintf("bot:token:%s", tokenHash)
+}
+
+func ownerBotsKey(ownerID string) string {
+	return fmt.Sprintf("user:%s:bots", ownerID)
+}
@@ -0,0 +1,431 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/cache"
+	"github.com/hilthontt/visper/api/infrastructure/config"
+	"github.com/hilthontt/visper/api/infrastructure/persistence/database"
+	"github.com/hilthontt/visper/api/infrastructure/persistence/migration"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// The compliance suite holds every persistence backend to the same contract, the use cases
+// switch between them through the Factory without knowing which one they got
+
+type repositoryBackend struct {
+	users    repository.UserRepository
+	rooms    repository.RoomRepository
+	messages repository.MessageRepository
+}
+
+func TestRedisRepositoryCompliance(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	distributed := cache.NewDistributedCache(client, "visper:", cache.DefaultOptions())
+	tracer := noop.NewTracerProvider().Tracer("compliance")
+
+	users := NewUserRepository(distributed, tracer)
+	runRepositoryCompliance(t, repositoryBackend{
+		users:    users,
+		rooms:    NewRoomRepository(distributed, users, tracer),
+		messages: NewMessageRepository(distributed, tracer),
+	})
+}
+
+// TestPostgresRepositoryCompliance needs a database to migrate, it runs when
+// VISPER_TEST_POSTGRES_HOST is set. Every record it writes has a fresh ID, so it can
+// share a database with earlier runs.
+func TestPostgresRepositoryCompliance(t *testing.T) {
+	host := os.Getenv("VISPER_TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Skip("VISPER_TEST_POSTGRES_HOST is not set")
+	}
+
+	cfg := &config.Config{Postgres: config.PostgresConfig{
+		Host:            host,
+		Port:            envOr("VISPER_TEST_POSTGRES_PORT", "5432"),
+		User:            envOr("VISPER_TEST_POSTGRES_USER", "postgres"),
+		Password:        os.Getenv("VISPER_TEST_POSTGRES_PASSWORD"),
+		DbName:          envOr("VISPER_TEST_POSTGRES_DB", "visper_test"),
+		SSLMode:         envOr("VISPER_TEST_POSTGRES_SSLMODE", "disable"),
+		MaxIdleConns:    2,
+		MaxOpenConns:    5,
+		ConnMaxLifetime: 5,
+	}}
+	if err := database.InitDb(cfg); err != nil {
+		t.Fatalf("failed to connect to postgres: %v", err)
+	}
+	t.Cleanup(database.CloseDb)
+
+	if err := migration.Run(database.GetDb()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	logger := zap.NewNop()
+	users := NewPostgresUserRepository(cfg, logger)
+	runRepositoryCompliance(t, repositoryBackend{
+		users:    users,
+		rooms:    NewPostgresRoomRepository(cfg, users, logger),
+		messages: NewPostgresMessageRepository(cfg, logger),
+	})
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func runRepositoryCompliance(t *testing.T, backend repositoryBackend) {
+	t.Run("users", func(t *testing.T) { testUserCompliance(t, backend.users) })
+	t.Run("rooms", func(t *testing.T) { testRoomCompliance(t, backend.users, backend.rooms) })
+	t.Run("messages", func(t *testing.T) { testMessageCompliance(t, backend.messages) })
+}
+
+func newTestUser(t *testing.T, users repository.UserRepository, username string) *model.User {
+	t.Helper()
+
+	user := &model.User{ID: uuid.NewString(), Username: username, IsGuest: true}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return user
+}
+
+func testUserCompliance(t *testing.T, users repository.UserRepository) {
+	ctx := context.Background()
+	user := newTestUser(t, users, "alice")
+	if user.CreatedAt.IsZero() {
+		t.Error("Create() left CreatedAt unset")
+	}
+
+	got, err := users.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.ID != user.ID || got.Username != user.Username || got.IsGuest != user.IsGuest || got.IsBot != user.IsBot {
+		t.Errorf("GetByID() = %+v, want %+v", got, user)
+	}
+
+	if _, err := users.GetByID(ctx, uuid.NewString()); !errors.Is(err, redis.Nil) {
+		t.Errorf("GetByID() of a missing user error = %v, want redis.Nil", err)
+	}
+
+	username := "alice-" + uuid.NewString()
+	if err := users.SetUsernameIndex(ctx, username, user.ID); err != nil {
+		t.Fatalf("SetUsernameIndex() error = %v", err)
+	}
+	if got, err := users.GetByUsername(ctx, username); err != nil || got.ID != user.ID {
+		t.Errorf("GetByUsername() = %v, %v, want user %s", got, err, user.ID)
+	}
+	if _, err := users.GetByUsername(ctx, "nobody-"+uuid.NewString()); err == nil {
+		t.Error("GetByUsername() of a missing name returned no error")
+	}
+
+	// Only the user the name points at may release it
+	if deleted, err := users.DeleteUsernameIndex(ctx, username, uuid.NewString()); err != nil || deleted {
+		t.Errorf("DeleteUsernameIndex() by another user = %v, %v, want false, nil", deleted, err)
+	}
+	if _, err := users.GetByUsername(ctx, username); err != nil {
+		t.Errorf("name released by another user: %v", err)
+	}
+	if deleted, err := users.DeleteUsernameIndex(ctx, username, user.ID); err != nil || !deleted {
+		t.Errorf("DeleteUsernameIndex() = %v, %v, want true, nil", deleted, err)
+	}
+	if _, err := users.GetByUsername(ctx, username); err == nil {
+		t.Error("GetByUsername() still finds a released name")
+	}
+
+	if err := users.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := users.GetByID(ctx, user.ID); !errors.Is(err, redis.Nil) {
+		t.Errorf("GetByID() after Delete() error = %v, want redis.Nil", err)
+	}
+}
+
+func testRoomCompliance(t *testing.T, users repository.UserRepository, rooms repository.RoomRepository) {
+	ctx := context.Background()
+	owner := newTestUser(t, users, "owner")
+	member := newTestUser(t, users, "member")
+	latecomer := newTestUser(t, users, "latecomer")
+
+	room := &model.Room{
+		ID:            uuid.NewString(),
+		JoinCode:      uuid.NewString()[:8],
+		SecureCode:    "secure",
+		Owner:         *owner,
+		EncryptionKey: "key",
+		Settings:      model.RoomSettings{Name: "compliance", MaxMembers: 2},
+	}
+	if err := rooms.Create(ctx, room); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := rooms.GetByID(ctx, room.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.JoinCode != room.JoinCode || got.Owner.ID != owner.ID || got.EncryptionKey != room.EncryptionKey || got.Settings != room.Settings {
+		t.Errorf("GetByID() = %+v, want %+v", got, room)
+	}
+	if _, err := rooms.GetByID(ctx, uuid.NewString()); !errors.Is(err, redis.Nil) {
+		t.Errorf("GetByID() of a missing room error = %v, want redis.Nil", err)
+	}
+	if got, err := rooms.GetByJoinCode(ctx, room.JoinCode); err != nil || got.ID != room.ID {
+		t.Errorf("GetByJoinCode() = %v, %v, want room %s", got, err, room.ID)
+	}
+	if _, err := rooms.GetByJoinCode(ctx, "missing-"+uuid.NewString()); !errors.Is(err, redis.Nil) {
+		t.Errorf("GetByJoinCode() of a missing code error = %v, want redis.Nil", err)
+	}
+
+	// Membership
+	for _, user := range []*model.User{owner, member, member} {
+		if err := rooms.AddUser(ctx, room.ID, *user); err != nil {
+			t.Fatalf("AddUser(%s) error = %v", user.Username, err)
+		}
+	}
+	if err := rooms.AddUser(ctx, room.ID, *latecomer); !errors.Is(err, repository.ErrRoomFull) {
+		t.Errorf("AddUser() past MaxMembers error = %v, want ErrRoomFull", err)
+	}
+	if err := rooms.AddUser(ctx, uuid.NewString(), *member); err == nil {
+		t.Error("AddUser() to a missing room returned no error")
+	}
+	assertRoomMembers(t, rooms, room.ID, owner.ID, member.ID)
+
+	removals := []struct {
+		name        string
+		userID      string
+		requesterID string
+		err         error
+	}{
+		{"kick by a member", owner.ID, member.ID, repository.ErrNotRoomOwner},
+		{"kick the owner", owner.ID, owner.ID, repository.ErrOwnerProtected},
+		{"remove the owner", owner.ID, "", repository.ErrOwnerProtected},
+		{"kick a non-member", latecomer.ID, owner.ID, repository.ErrNotRoomMember},
+		{"remove a non-member", latecomer.ID, "", nil},
+	}
+	for _, tt := range removals {
+		var err error
+		if tt.requesterID == "" {
+			err = rooms.RemoveUser(ctx, room.ID, tt.userID)
+		} else {
+			err = rooms.KickUser(ctx, room.ID, tt.userID, tt.requesterID)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	assertRoomMembers(t, rooms, room.ID, owner.ID, member.ID)
+
+	if err := rooms.KickUser(ctx, room.ID, member.ID, owner.ID); err != nil {
+		t.Fatalf("KickUser() error = %v", err)
+	}
+	assertRoomMembers(t, rooms, room.ID, owner.ID)
+
+	// Updates, including a new join code
+	oldCode := room.JoinCode
+	room.JoinCode = uuid.NewString()[:8]
+	room.Settings.Name = "renamed"
+	if err := rooms.Update(ctx, room); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, err := rooms.GetByID(ctx, room.ID); err != nil || got.Settings.Name != "renamed" {
+		t.Errorf("GetByID() after Update() = %v, %v, want the new name", got, err)
+	}
+	if got, err := rooms.GetByJoinCode(ctx, room.JoinCode); err != nil || got.ID != room.ID {
+		t.Errorf("GetByJoinCode() of the new code = %v, %v, want room %s", got, err, room.ID)
+	}
+	if _, err := rooms.GetByJoinCode(ctx, oldCode); !errors.Is(err, redis.Nil) {
+		t.Errorf("GetByJoinCode() of the old code error = %v, want redis.Nil", err)
+	}
+	if err := rooms.Update(ctx, &model.Room{ID: uuid.NewString(), Owner: *owner}); err == nil {
+		t.Error("Update() of a missing room returned no error")
+	}
+
+	// Listing
+	if count, err := rooms.Count(ctx); err != nil || count < 1 {
+		t.Errorf("Count() = %d, %v, want at least 1", count, err)
+	}
+	all, err := rooms.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if !slices.ContainsFunc(all, func(r *model.Room) bool { return r.ID == room.ID }) {
+		t.Error("GetAll() is missing the room")
+	}
+
+	if err := rooms.Delete(ctx, room.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := rooms.GetByID(ctx, room.ID); !errors.Is(err, redis.Nil) {
+		t.Errorf("GetByID() after Delete() error = %v, want redis.Nil", err)
+	}
+	if _, err := rooms.GetByJoinCode(ctx, room.JoinCode); !errors.Is(err, redis.Nil) {
+		t.Errorf("GetByJoinCode() after Delete() error = %v, want redis.Nil", err)
+	}
+}
+
+// assertRoomMembers compares as sets, only Postgres keeps members in the order they joined
+func assertRoomMembers(t *testing.T, rooms repository.RoomRepository, roomID string, want ...string) {
+	t.Helper()
+	ctx := context.Background()
+	slices.Sort(want)
+
+	userIDs, err := rooms.GetUsers(ctx, roomID)
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+	slices.Sort(userIDs)
+	if !slices.Equal(userIDs, want) {
+		t.Errorf("GetUsers() = %v, want %v", userIDs, want)
+	}
+
+	room, err := rooms.GetByID(ctx, roomID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	memberIDs := make([]string, len(room.Members))
+	for i, member := range room.Members {
+		memberIDs[i] = member.ID
+	}
+	slices.Sort(memberIDs)
+	if !slices.Equal(memberIDs, want) {
+		t.Errorf("GetByID() members = %v, want %v", memberIDs, want)
+	}
+}
+
+func testMessageCompliance(t *testing.T, messages repository.MessageRepository) {
+	ctx := context.Background()
+	roomID := uuid.NewString()
+	alice, bob := uuid.NewString(), uuid.NewString()
+	before := time.Now().Add(-time.Hour)
+
+	create := func(userID, content, parentID string) *model.Message {
+		t.Helper()
+		message := &model.Message{
+			ID:              uuid.NewString(),
+			RoomID:          roomID,
+			UserID:          userID,
+			Username:        "user",
+			Content:         content,
+			ParentMessageID: parentID,
+		}
+		if err := messages.Create(ctx, message); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return message
+	}
+	count := func(want int64) {
+		t.Helper()
+		if got, err := messages.Count(ctx, roomID); err != nil || got != want {
+			t.Errorf("Count() = %d, %v, want %d", got, err, want)
+		}
+	}
+
+	root := create(alice, "root", "")
+	other := create(bob, "other", "")
+	create(alice, "first reply", root.ID)
+	create(bob, "second reply", root.ID)
+	count(4)
+
+	got, err := messages.GetByID(ctx, roomID, root.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.Content != "root" || got.UserID != alice || got.CreatedAt.IsZero() {
+		t.Errorf("GetByID() = %+v, want %+v", got, root)
+	}
+	if _, err := messages.GetByID(ctx, roomID, uuid.NewString()); !errors.Is(err, redis.Nil) {
+		t.Errorf("GetByID() of a missing message error = %v, want redis.Nil", err)
+	}
+
+	pages := []struct {
+		name  string
+		fetch func() ([]*model.Message, error)
+		want  int
+	}{
+		{"GetByRoom without a limit", func() ([]*model.Message, error) { return messages.GetByRoom(ctx, roomID, 0) }, 4},
+		{"GetByRoom with a limit", func() ([]*model.Message, error) { return messages.GetByRoom(ctx, roomID, 2) }, 2},
+		{"GetByRoomAfter an hour ago", func() ([]*model.Message, error) { return messages.GetByRoomAfter(ctx, roomID, before, 0) }, 4},
+		{"GetByRoomAfter with a limit", func() ([]*model.Message, error) { return messages.GetByRoomAfter(ctx, roomID, before, 3) }, 3},
+		{"GetByRoomAfter in an hour", func() ([]*model.Message, error) {
+			return messages.GetByRoomAfter(ctx, roomID, time.Now().Add(time.Hour), 0)
+		}, 0},
+	}
+	for _, tt := range pages {
+		page, err := tt.fetch()
+		if err != nil || len(page) != tt.want {
+			t.Errorf("%s: %d messages, %v, want %d", tt.name, len(page), err, tt.want)
+		}
+	}
+
+	replies, total, err := messages.GetReplies(ctx, roomID, root.ID, 1, 10)
+	if err != nil || total != 2 || len(replies) != 1 {
+		t.Errorf("GetReplies() = %d of %d, %v, want 1 of 2", len(replies), total, err)
+	}
+
+	// Edits
+	root.Content = "edited"
+	if err := messages.Update(ctx, root); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	preview := &model.LinkPreview{URL: "https://example.com", Title: "Example"}
+	if err := messages.SetPreview(ctx, roomID, root.ID, preview); err != nil {
+		t.Fatalf("SetPreview() error = %v", err)
+	}
+	got, err = messages.GetByID(ctx, roomID, root.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.Content != "edited" || got.UpdatedAt.IsZero() || got.Preview == nil || got.Preview.URL != preview.URL {
+		t.Errorf("GetByID() after edits = %+v", got)
+	}
+	count(4)
+
+	missing := &model.Message{ID: uuid.NewString(), RoomID: roomID}
+	if err := messages.Update(ctx, missing); !errors.Is(err, redis.Nil) {
+		t.Errorf("Update() of a missing message error = %v, want redis.Nil", err)
+	}
+	if err := messages.SetPreview(ctx, roomID, missing.ID, preview); !errors.Is(err, redis.Nil) {
+		t.Errorf("SetPreview() of a missing message error = %v, want redis.Nil", err)
+	}
+	if err := messages.Delete(ctx, roomID, missing.ID); !errors.Is(err, redis.Nil) {
+		t.Errorf("Delete() of a missing message error = %v, want redis.Nil", err)
+	}
+
+	// Removal
+	if err := messages.Delete(ctx, roomID, other.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	count(3)
+
+	if removed, err := messages.DeleteByUser(ctx, roomID, alice); err != nil || removed != 2 {
+		t.Errorf("DeleteByUser() = %d, %v, want 2", removed, err)
+	}
+	count(1)
+
+	if err := messages.DeleteOldMessages(ctx, roomID, before); err != nil {
+		t.Fatalf("DeleteOldMessages() error = %v", err)
+	}
+	count(1)
+	if err := messages.DeleteOldMessages(ctx, roomID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("DeleteOldMessages() error = %v", err)
+	}
+	count(0)
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type exportLimitRepository struct {
+	client redis.UniversalClient
+}
+
+func NewExportLimitRepository(client redis.UniversalClient) repository.ExportLimitRepository {
+	return &exportLimitRepository{
+		client: client,
+	}
+}
+
+func (r *exportLimitRepository) Acquire(ctx context.Context, roomID, userID string, interval time.Duration) (time.Duration, error) {
+	key := fmt.Sprintf("room:%s:export:%s", roomID, userID)
+
+	acquired, err := r.client.SetNX(ctx, key, 1, interval).Result()
+	if err != nil {
+		return 0, err
+	}
+	if acquired {
+		return 0, nil
+	}
+
+	remaining, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// The key expired between the two calls, let the export through
+	if remaining <= 0 {
+		return 0, nil
+	}
+
+	return remaining, nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"encoding/gob"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/cache"
+	"github.com/hilthontt/visper/api/infrastructure/config"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// The cache's local layer holds users and rooms, its snapshots can only encode named types
+func init() {
+	gob.Register(&model.User{})
+	gob.Register(&model.Room{})
+}
+
+// Factory builds the repositories whose backend is chosen per entity by persistence in the
+// config. Backends are validated with the config, an unknown one never reaches the factory.
+// The repositories it returns report their operations through metrics.
+type Factory struct {
+	cfg       *config.Config
+	cache     *cache.DistributedCache
+	tracer    trace.Tracer
+	metrics   metrics.Manager
+	zapLogger *zap.Logger
+}
+
+func NewFactory(cfg *config.Config, cache *cache.DistributedCache, tracer trace.Tracer, metrics metrics.Manager, zapLogger *zap.Logger) *Factory {
+	return &Factory{
+		cfg:       cfg,
+		cache:     cache,
+		tracer:    tracer,
+		metrics:   metrics,
+		zapLogger: zapLogger,
+	}
+}
+
+func (f *Factory) UserRepository() repository.UserRepository {
+	backend := f.backend(f.cfg.Persistence.Users)
+	if backend == "postgres" {
+		return newInstrumentedUserRepository(NewPostgresUserRepository(f.cfg, f.zapLogger), f.metrics, backend)
+	}
+	return newInstrumentedUserRepository(NewUserRepository(f.cache, f.tracer), f.metrics, backend)
+}
+
+// RoomRepository reads members through users, pass the repository UserRepository returned
+func (f *Factory) RoomRepository(users repository.UserRepository) repository.RoomRepository {
+	backend := f.backend(f.cfg.Persistence.Rooms)
+	if backend == "postgres" {
+		return newInstrumentedRoomRepository(NewPostgresRoomRepository(f.cfg, users, f.zapLogger), f.metrics, backend)
+	}
+	return newInstrumentedRoomRepository(NewRoomRepository(f.cache, users, f.tracer), f.metrics, backend)
+}
+
+func (f *Factory) MessageRepository() repository.MessageRepository {
+	backend := f.backend(f.cfg.Persistence.Messages)
+	if backend == "postgres" {
+		return newInstrumentedMessageRepository(NewPostgresMessageRepository(f.cfg, f.zapLogger), f.metrics, backend)
+	}
+	return newInstrumentedMessageRepository(NewMessageRepository(f.cache, f.tracer), f.metrics, backend)
+}
+
+func (f *Factory) backend(override string) string {
+	return f.cfg.Persistence.BackendFor(override)
+}
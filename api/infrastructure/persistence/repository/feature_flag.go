@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// featureFlagsKey is a hash of the flag states by name
+const featureFlagsKey = "feature:flags"
+
+type featureFlagRepository struct {
+	client redis.UniversalClient
+}
+
+func NewFeatureFlagRepository(client redis.UniversalClient) repository.FeatureFlagRepository {
+	return &featureFlagRepository{
+		client: client,
+	}
+}
+
+func (r *featureFlagRepository) GetAll(ctx context.Context) ([]*model.FeatureFlagState, error) {
+	entries, err := r.client.HGetAll(ctx, featureFlagsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feature flags: %w", err)
+	}
+
+	states := make([]*model.FeatureFlagState, 0, len(entries))
+	for _, data := range entries {
+		var state model.FeatureFlagState
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			continue
+		}
+		states = append(states, &state)
+	}
+
+	return states, nil
+}
+
+func (r *featureFlagRepository) Save(ctx context.Context, state *model.FeatureFlagState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal feature flag: %w", err)
+	}
+
+	if err := r.client.HSet(ctx, featureFlagsKey, string(state.Name), data).Err(); err != nil {
+		return fmt.Errorf("failed to save feature flag: %w", err)
+	}
+	return nil
+}
@@ -12,11 +12,11 @@ import (
 )
 
 type fileRepository struct {
-	client         *redis.Client
+	client         redis.UniversalClient
 	roomRepository repository.RoomRepository
 }
 
-func NewFileRepository(client *redis.Client, roomRepository repository.RoomRepository) repository.FileRepository {
+func NewFileRepository(client redis.UniversalClient, roomRepository repository.RoomRepository) repository.FileRepository {
 	return &fileRepository{
 		client:         client,
 		roomRepository: roomRepository,
@@ -40,6 +40,10 @@ func (r *fileRepository) Create(ctx context.Context, file *model.File) error {
 		return err
 	}
 
+	if err := r.addRoomUsage(ctx, file.RoomID, file.Size); err != nil {
+		return err
+	}
+
 	return r.client.SAdd(ctx, "files", file.ID).Err()
 }
 
@@ -77,6 +81,36 @@ func (r *fileRepository) GetByRoomID(ctx context.Context, roomID string) ([]*mod
 	return files, nil
 }
 
+func (r *fileRepository) GetByUserID(ctx context.Context, userID string) ([]*model.File, error) {
+	fileIDs, err := r.client.SMembers(ctx, "files").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*model.File, 0)
+	for _, id := range fileIDs {
+		file, err := r.GetByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		if file.UserID == userID {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+func (r *fileRepository) Update(ctx context.Context, file *model.File) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("file:%s", file.ID)
+	return r.client.SetXX(ctx, key, data, 0).Err()
+}
+
 func (r *fileRepository) Delete(ctx context.Context, id string) error {
 	file, err := r.GetByID(ctx, id)
 	if err != nil {
@@ -92,6 +126,10 @@ func (r *fileRepository) Delete(ctx context.Context, id string) error {
 		return err
 	}
 
+	if err := r.addRoomUsage(ctx, file.RoomID, -file.Size); err != nil {
+		return err
+	}
+
 	key := fmt.Sprintf("file:%s", id)
 	return r.client.Del(ctx, key).Err()
 }
@@ -110,7 +148,51 @@ func (r *fileRepository) DeleteByRoomID(ctx context.Context, roomID string) erro
 		}
 	}
 
-	return r.client.Del(ctx, roomFileKey).Err()
+	return r.client.Del(ctx, roomFileKey, roomUsageKey(roomID)).Err()
+}
+
+// GetRoomUsage is the total size of the room's files. Rooms from before usage was tracked
+// get their counter seeded from the stored files on first use.
+func (r *fileRepository) GetRoomUsage(ctx context.Context, roomID string) (int64, error) {
+	used, err := r.client.Get(ctx, roomUsageKey(roomID)).Int64()
+	if err == nil {
+		return used, nil
+	}
+	if err != redis.Nil {
+		return 0, err
+	}
+
+	if err := r.seedRoomUsage(ctx, roomID); err != nil {
+		return 0, err
+	}
+	return r.client.Get(ctx, roomUsageKey(roomID)).Int64()
+}
+
+func (r *fileRepository) addRoomUsage(ctx context.Context, roomID string, delta int64) error {
+	exists, err := r.client.Exists(ctx, roomUsageKey(roomID)).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		// Seeding counts the file that was just added or is about to go, no delta needed
+		return r.seedRoomUsage(ctx, roomID)
+	}
+
+	return r.client.IncrBy(ctx, roomUsageKey(roomID), delta).Err()
+}
+
+func (r *fileRepository) seedRoomUsage(ctx context.Context, roomID string) error {
+	files, err := r.GetByRoomID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+
+	return r.client.SetNX(ctx, roomUsageKey(roomID), total, 0).Err()
 }
 
 func (r *fileRepository) GetOrphanedFiles(ctx context.Context) ([]*model.File, error) {
@@ -126,13 +208,16 @@ func (r *fileRepository) GetOrphanedFiles(ctx context.Context) ([]*model.File, e
 			continue
 		}
 
-		// Check if room still exists
-		_, err = r.roomRepository.GetByID(ctx, file.RoomID)
-		if err != nil {
-			// Room doesn't exist, file is orphaned
+		// Files outlive neither their room nor its expiry
+		room, err := r.roomRepository.GetByID(ctx, file.RoomID)
+		if err != nil || room.HasExpired() {
 			orphanedFiles = append(orphanedFiles, file)
 		}
 	}
 
 	return orphanedFiles, nil
 }
+
+func roomUsageKey(roomID string) string {
+	return fmt.Sprintf("room:%s:files:bytes", roomID)
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type identityRepository struct {
+	client redis.UniversalClient
+}
+
+func NewIdentityRepository(client redis.UniversalClient) repository.IdentityRepository {
+	return &identityRepository{
+		client: client,
+	}
+}
+
+func (r *identityRepository) GetBySubject(ctx context.Context, issuer, subject string) (*model.Identity, error) {
+	data, err := r.client.Get(ctx, identityKey(issuer, subject)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var identity model.Identity
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *identityRepository) GetByUserID(ctx context.Context, userID string) (*model.Identity, error) {
+	key, err := r.client.Get(ctx, userIdentityKey(userID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var identity model.Identity
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// Save stores the identity without an expiry, it lasts until it's unlinked
+func (r *identityRepository) Save(ctx context.Context, identity *model.Identity) error {
+	data, err := json.Marshal(identity)
+	if err != nil {
+		return err
+	}
+
+	key := identityKey(identity.Issuer, identity.Subject)
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, key, data, 0)
+	pipe.Set(ctx, userIdentityKey(identity.UserID), key, 0)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *identityRepository) Delete(ctx context.Context, identity *model.Identity) error {
+	return r.client.Del(ctx, identityKey(identity.Issuer, identity.Subject), userIdentityKey(identity.UserID)).Err()
+}
+
+func (r *identityRepository) SaveLoginState(ctx context.Context, state *model.LoginState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, loginStateKey(state.State), data, time.Until(state.ExpiresAt)).Err()
+}
+
+// ConsumeLoginState uses GETDEL so a state can't be replayed
+func (r *identityRepository) ConsumeLoginState(ctx context.Context, state string) (*model.LoginState, error) {
+	data, err := r.client.GetDel(ctx, loginStateKey(state)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var loginState model.LoginState
+	if err := json.Unmarshal(data, &loginState); err != nil {
+		return nil, err
+	}
+
+	return &loginState, nil
+}
+
+func identityKey(issuer, subject string) string {
+	return fmt.Sprintf("identity:%s:%s", issuer, subject)
+}
+
+func userIdentityKey(userID string) string {
+	return fmt.Sprintf("user:%s:identity", userID)
+}
+
+func loginStateKey(state string) string {
+	return fmt.Sprintf("oidc:state:%s", state)
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// The instrumented repositories wrap whichever backend the factory picked, every call counts
+// towards repository_operations_total and repository_operation_duration_seconds
+const (
+	operationsMetric = "repository_operations_total"
+	durationMetric   = "repository_operation_duration_seconds"
+)
+
+type operationRecorder struct {
+	metrics    metrics.Manager
+	repository string
+	backend    string
+}
+
+// record labels by outcome rather than by error message, so the series stay bounded
+func (r operationRecorder) record(ctx context.Context, operation string, start time.Time, err error) {
+	r.metrics.IncrementCounter(ctx, operationsMetric,
+		"repository", r.repository, "backend", r.backend, "operation", operation, "error", errorLabel(err))
+	r.metrics.RecordHistogram(ctx, durationMetric, time.Since(start).Seconds(),
+		"repository", r.repository, "backend", r.backend, "operation", operation)
+}
+
+func errorLabel(err error) string {
+	switch {
+	case err == nil:
+		return "none"
+	case errors.Is(err, redis.Nil), errors.Is(err, gorm.ErrRecordNotFound):
+		return "not_found"
+	case errors.Is(err, repository.ErrRoomFull), errors.Is(err, repository.ErrNotRoomOwner),
+		errors.Is(err, repository.ErrOwnerProtected), errors.Is(err, repository.ErrNotRoomMember):
+		return "rejected"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "internal"
+	}
+}
+
+type instrumentedUserRepository struct {
+	next repository.UserRepository
+	rec  operationRecorder
+}
+
+func newInstrumentedUserRepository(next repository.UserRepository, m metrics.Manager, backend string) repository.UserRepository {
+	return &instrumentedUserRepository{next: next, rec: operationRecorder{metrics: m, repository: "user", backend: backend}}
+}
+
+func (r *instrumentedUserRepository) Create(ctx context.Context, user *model.User) error {
+	start := time.Now()
+	err := r.next.Create(ctx, user)
+	r.rec.record(ctx, "Create", start, err)
+	return err
+}
+
+func (r *instrumentedUserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
+	start := time.Now()
+	result, err := r.next.GetByID(ctx, id)
+	r.rec.record(ctx, "GetByID", start, err)
+	return result, err
+}
+
+func (r *instrumentedUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	start := time.Now()
+	result, err := r.next.GetByUsername(ctx, username)
+	r.rec.record(ctx, "GetByUsername", start, err)
+	return result, err
+}
+
+func (r *instrumentedUserRepository) SetUsernameIndex(ctx context.Context, username, userID string) error {
+	start := time.Now()
+	err := r.next.SetUsernameIndex(ctx, username, userID)
+	r.rec.record(ctx, "SetUsernameIndex", start, err)
+	return err
+}
+
+func (r *instrumentedUserRepository) DeleteUsernameIndex(ctx context.Context, username, userID string) (bool, error) {
+	start := time.Now()
+	result, err := r.next.DeleteUsernameIndex(ctx, username, userID)
+	r.rec.record(ctx, "DeleteUsernameIndex", start, err)
+	return result, err
+}
+
+func (r *instrumentedUserRepository) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := r.next.Delete(ctx, id)
+	r.rec.record(ctx, "Delete", start, err)
+	return err
+}
+
+type instrumentedRoomRepository struct {
+	next repository.RoomRepository
+	rec  operationRecorder
+}
+
+func newInstrumentedRoomRepository(next repository.RoomRepository, m metrics.Manager, backend string) repository.RoomRepository {
+	return &instrumentedRoomRepository{next: next, rec: operationRecorder{metrics: m, repository: "room", backend: backend}}
+}
+
+func (r *instrumentedRoomRepository) Create(ctx context.Context, room *model.Room) error {
+	start := time.Now()
+	err := r.next.Create(ctx, room)
+	r.rec.record(ctx, "Create", start, err)
+	return err
+}
+
+func (r *instrumentedRoomRepository) GetByID(ctx context.Context, id string) (*model.Room, error) {
+	start := time.Now()
+	result, err := r.next.GetByID(ctx, id)
+	r.rec.record(ctx, "GetByID", start, err)
+	return result, err
+}
+
+func (r *instrumentedRoomRepository) GetByJoinCode(ctx context.Context, joinCode string) (*model.Room, error) {
+	start := time.Now()
+	result, err := r.next.GetByJoinCode(ctx, joinCode)
+	r.rec.record(ctx, "GetByJoinCode", start, err)
+	return result, err
+}
+
+func (r *instrumentedRoomRepository) GetAll(ctx context.Context) ([]*model.Room, error) {
+	start := time.Now()
+	result, err := r.next.GetAll(ctx)
+	r.rec.record(ctx, "GetAll", start, err)
+	return result, err
+}
+
+func (r *instrumentedRoomRepository) Count(ctx context.Context) (int64, error) {
+	start := time.Now()
+	result, err := r.next.Count(ctx)
+	r.rec.record(ctx, "Count", start, err)
+	return result, err
+}
+
+func (r *instrumentedRoomRepository) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := r.next.Delete(ctx, id)
+	r.rec.record(ctx, "Delete", start, err)
+	return err
+}
+
+func (r *instrumentedRoomRepository) AddUser(ctx context.Context, roomID string, user model.User) error {
+	start := time.Now()
+	err := r.next.AddUser(ctx, roomID, user)
+	r.rec.record(ctx, "AddUser", start, err)
+	return err
+}
+
+func (r *instrumentedRoomRepository) RemoveUser(ctx context.Context, roomID, userID string) error {
+	start := time.Now()
+	err := r.next.RemoveUser(ctx, roomID, userID)
+	r.rec.record(ctx, "RemoveUser", start, err)
+	return err
+}
+
+func (r *instrumentedRoomRepository) KickUser(ctx context.Context, roomID, userID, requesterID string) error {
+	start := time.Now()
+	err := r.next.KickUser(ctx, roomID, userID, requesterID)
+	r.rec.record(ctx, "KickUser", start, err)
+	return err
+}
+
+func (r *instrumentedRoomRepository) GetUsers(ctx context.Context, roomID string) ([]string, error) {
+	start := time.Now()
+	result, err := r.next.GetUsers(ctx, roomID)
+	r.rec.record(ctx, "GetUsers", start, err)
+	return result, err
+}
+
+func (r *instrumentedRoomRepository) Update(ctx context.Context, room *model.Room) error {
+	start := time.Now()
+	err := r.next.Update(ctx, room)
+	r.rec.record(ctx, "Update", start, err)
+	return err
+}
+
+type instrumentedMessageRepository struct {
+	next repository.MessageRepository
+	rec  operationRecorder
+}
+
+func newInstrumentedMessageRepository(next repository.MessageRepository, m metrics.Manager, backend string) repository.MessageRepository {
+	return &instrumentedMessageRepository{next: next, rec: operationRecorder{metrics: m, repository: "message", backend: backend}}
+}
+
+func (r *instrumentedMessageRepository) GetByID(ctx context.Context, roomID, messageID string) (*model.Message, error) {
+	start := time.Now()
+	result, err := r.next.GetByID(ctx, roomID, messageID)
+	r.rec.record(ctx, "GetByID", start, err)
+	return result, err
+}
+
+func (r *instrumentedMessageRepository) Update(ctx context.Context, message *model.Message) error {
+	start := time.Now()
+	err := r.next.Update(ctx, message)
+	r.rec.record(ctx, "Update", start, err)
+	return err
+}
+
+func (r *instrumentedMessageRepository) SetPreview(ctx context.Context, roomID, messageID string, preview *model.LinkPreview) error {
+	start := time.Now()
+	err := r.next.SetPreview(ctx, roomID, messageID, preview)
+	r.rec.record(ctx, "SetPreview", start, err)
+	return err
+}
+
+func (r *instrumentedMessageRepository) Delete(ctx context.Context, roomID, messageID string) error {
+	start := time.Now()
+	err := r.next.Delete(ctx, roomID, messageID)
+	r.rec.record(ctx, "Delete", start, err)
+	return err
+}
+
+func (r *instrumentedMessageRepository) Create(ctx context.Context, message *model.Message) error {
+	start := time.Now()
+	err := r.next.Create(ctx, message)
+	r.rec.record(ctx, "Create", start, err)
+	return err
+}
+
+func (r *instrumentedMessageRepository) GetByRoom(ctx context.Context, roomID string, limit int64) ([]*model.Message, error) {
+	start := time.Now()
+	result, err := r.next.GetByRoom(ctx, roomID, limit)
+	r.rec.record(ctx, "GetByRoom", start, err)
+	return result, err
+}
+
+func (r *instrumentedMessageRepository) GetByRoomAfter(ctx context.Context, roomID string, after time.Time, limit int64) ([]*model.Message, error) {
+	start := time.Now()
+	result, err := r.next.GetByRoomAfter(ctx, roomID, after, limit)
+	r.rec.record(ctx, "GetByRoomAfter", start, err)
+	return result, err
+}
+
+func (r *instrumentedMessageRepository) DeleteOldMessages(ctx context.Context, roomID string, before time.Time) error {
+	start := time.Now()
+	err := r.next.DeleteOldMessages(ctx, roomID, before)
+	r.rec.record(ctx, "DeleteOldMessages", start, err)
+	return err
+}
+
+func (r *instrumentedMessageRepository) Count(ctx context.Context, roomID string) (int64, error) {
+	start := time.Now()
+	result, err := r.next.Count(ctx, roomID)
+	r.rec.record(ctx, "Count", start, err)
+	return result, err
+}
+
+func (r *instrumentedMessageRepository) GetReplies(ctx context.Context, roomID, parentMessageID string, offset, limit int64) ([]*model.Message, int64, error) {
+	start := time.Now()
+	result, total, err := r.next.GetReplies(ctx, roomID, parentMessageID, offset, limit)
+	r.rec.record(ctx, "GetReplies", start, err)
+	return result, total, err
+}
+
+func (r *instrumentedMessageRepository) DeleteByUser(ctx context.Context, roomID, userID string) (int, error) {
+	start := time.Now()
+	result, err := r.next.DeleteByUser(ctx, roomID, userID)
+	r.rec.record(ctx, "DeleteByUser", start, err)
+	return result, err
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// Busy rooms only keep their most recent membership changes
+const maxMembershipEvents = 1000
+
+type membershipLogRepository struct {
+	client redis.UniversalClient
+}
+
+func NewMembershipLogRepository(client redis.UniversalClient) repository.MembershipLogRepository {
+	return &membershipLogRepository{
+		client: client,
+	}
+}
+
+func (r *membershipLogRepository) Append(ctx context.Context, event *model.MembershipEvent, expiresAt time.Time) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	key := roomMembershipLogKey(event.RoomID)
+
+	pipe := r.client.TxPipeline()
+	pipe.RPush(ctx, key, data)
+	pipe.LTrim(ctx, key, -maxMembershipEvents, -1)
+	if !expiresAt.IsZero() {
+		pipe.ExpireAt(ctx, key, expiresAt)
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *membershipLogRepository) GetAll(ctx context.Context, roomID string) ([]*model.MembershipEvent, error) {
+	entries, err := r.client.LRange(ctx, roomMembershipLogKey(roomID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get membership log: %w", err)
+	}
+
+	events := make([]*model.MembershipEvent, 0, len(entries))
+	for _, data := range entries {
+		var event model.MembershipEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
+
+func (r *membershipLogRepository) ExtendExpiry(ctx context.Context, roomID string, expiresAt time.Time) error {
+	return r.client.ExpireAt(ctx, roomMembershipLogKey(roomID), expiresAt).Err()
+}
+
+func roomMembershipLogKey(roomID string) string {
+	return fmt.Sprintf("room:%s:membership", roomID)
+}
@@ -116,6 +116,33 @@ func (r *messageRepository) Update(ctx context.Context, message *model.Message)
 		attribute.String("message.user_id", message.UserID),
 	)
 
+	message.UpdatedAt = time.Now()
+	return r.replace(ctx, span, message)
+}
+
+// SetPreview leaves UpdatedAt alone, a preview arriving shouldn't mark the message as edited
+func (r *messageRepository) SetPreview(ctx context.Context, roomID, messageID string, preview *model.LinkPreview) error {
+	ctx, span := r.tracer.Start(ctx, "messageRepository.SetPreview")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("message.id", messageID),
+		attribute.String("room.id", roomID),
+	)
+
+	message, err := r.GetByID(ctx, roomID, messageID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to get message")
+		return err
+	}
+
+	message.Preview = preview
+	return r.replace(ctx, span, message)
+}
+
+// replace swaps the stored copy of the message for the given one, keeping its position in the history
+func (r *messageRepository) replace(ctx context.Context, span trace.Span, message *model.Message) error {
 	key := fmt.Sprintf("room:%s:messages", message.RoomID)
 
 	oldMessage, err := r.GetByID(ctx, message.RoomID, message.ID)
@@ -132,7 +159,6 @@ func (r *messageRepository) Update(ctx context.Context, message *model.Message)
 		return fmt.Errorf("failed to marshal old message: %w", err)
 	}
 
-	message.UpdatedAt = time.Now()
 	message.CreatedAt = oldMessage.CreatedAt
 
 	newData, err := json.Marshal(message)
@@ -342,3 +368,100 @@ func (r *messageRepository) Count(ctx context.Context, roomID string) (int64, er
 	span.SetStatus(codes.Ok, "message count retrieved successfully")
 	return count, nil
 }
+
+// GetReplies returns a page of a thread in chronological order along with the total reply count
+func (r *messageRepository) GetReplies(ctx context.Context, roomID, parentMessageID string, offset, limit int64) ([]*model.Message, int64, error) {
+	ctx, span := r.tracer.Start(ctx, "messageRepository.GetReplies")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("room.id", roomID),
+		attribute.String("message.parent_id", parentMessageID),
+		attribute.Int64("query.offset", offset),
+		attribute.Int64("query.limit", limit),
+	)
+
+	key := fmt.Sprintf("room:%s:messages", roomID)
+
+	results, err := r.cache.ZRange(ctx, key, 0, -1)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to get messages from sorted set")
+		return nil, 0, err
+	}
+
+	span.SetAttributes(attribute.Int("messages.scanned_count", len(results)))
+
+	replies := make([]*model.Message, 0)
+	var total int64
+
+	for _, data := range results {
+		var msg model.Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			continue
+		}
+		if msg.ParentMessageID != parentMessageID {
+			continue
+		}
+
+		if total >= offset && int64(len(replies)) < limit {
+			replies = append(replies, &msg)
+		}
+		total++
+	}
+
+	span.SetAttributes(
+		attribute.Int("replies.fetched_count", len(replies)),
+		attribute.Int64("replies.total", total),
+	)
+
+	span.SetStatus(codes.Ok, "replies retrieved successfully")
+	return replies, total, nil
+}
+
+// DeleteByUser removes every message the user authored in the room and returns how many were removed
+func (r *messageRepository) DeleteByUser(ctx context.Context, roomID, userID string) (int, error) {
+	ctx, span := r.tracer.Start(ctx, "messageRepository.DeleteByUser")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("room.id", roomID),
+		attribute.String("message.user_id", userID),
+	)
+
+	key := fmt.Sprintf("room:%s:messages", roomID)
+
+	results, err := r.cache.ZRange(ctx, key, 0, -1)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to get messages from sorted set")
+		return 0, err
+	}
+
+	members := make([]interface{}, 0)
+	for _, data := range results {
+		var msg model.Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			continue
+		}
+		if msg.UserID == userID {
+			members = append(members, data)
+		}
+	}
+
+	span.SetAttributes(attribute.Int("messages.deleted_count", len(members)))
+
+	if len(members) == 0 {
+		span.SetStatus(codes.Ok, "no messages to delete")
+		return 0, nil
+	}
+
+	if err := r.cache.ZRem(ctx, key, members...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to remove messages from sorted set")
+		return 0, fmt.Errorf("failed to delete messages: %w", err)
+	}
+
+	span.SetStatus(codes.Ok, "user messages deleted successfully")
+	return len(members), nil
+}
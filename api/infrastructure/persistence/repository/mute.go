@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type muteRepository struct {
+	client redis.UniversalClient
+}
+
+func NewMuteRepository(client redis.UniversalClient) repository.MuteRepository {
+	return &muteRepository{
+		client: client,
+	}
+}
+
+// Mutes always carry a TTL, Redis lifts them on its own once it passes
+func (r *muteRepository) Create(ctx context.Context, mute *model.Mute, duration time.Duration) error {
+	mute.MutedAt = time.Now()
+	mute.ExpiresAt = mute.MutedAt.Add(duration)
+
+	data, err := json.Marshal(mute)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, muteKey(mute.RoomID, mute.UserID), data, duration).Err()
+}
+
+func (r *muteRepository) Get(ctx context.Context, roomID, userID string) (*model.Mute, error) {
+	data, err := r.client.Get(ctx, muteKey(roomID, userID)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var mute model.Mute
+	if err := json.Unmarshal(data, &mute); err != nil {
+		return nil, err
+	}
+
+	return &mute, nil
+}
+
+func (r *muteRepository) Delete(ctx context.Context, roomID, userID string) error {
+	return r.client.Del(ctx, muteKey(roomID, userID)).Err()
+}
+
+func muteKey(roomID, userID string) string {
+	return fmt.Sprintf("room:%s:mute:%s", roomID, userID)
+}
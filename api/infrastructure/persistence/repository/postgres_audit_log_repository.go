@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/hilthontt/visper/api/domain/model"
 	"github.com/hilthontt/visper/api/domain/repository"
@@ -36,3 +37,44 @@ func (r *PostgresAuditLogRepository) CreateAuditLog(ctx context.Context, a model
 
 	return a, nil
 }
+
+func (r *PostgresAuditLogRepository) ListAuditLogs(ctx context.Context, filter repository.AuditLogFilter) ([]model.AuditLog, error) {
+	query := r.database.WithContext(ctx).Model(&model.AuditLog{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.RoomID != "" {
+		query = query.Where("room_id = ?", filter.RoomID)
+	}
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var entries []model.AuditLog
+	if err := query.Order("created_at DESC, id DESC").Find(&entries).Error; err != nil {
+		r.logger.Error(ctx, err.Error())
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *PostgresAuditLogRepository) DeleteAuditLogsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.database.WithContext(ctx).
+		Where("created_at < ?", before).
+		Delete(&model.AuditLog{})
+
+	if result.Error != nil {
+		r.logger.Error(ctx, result.Error.Error())
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
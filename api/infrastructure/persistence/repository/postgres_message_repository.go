@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/config"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type messageRecord struct {
+	ID              string `gorm:"primaryKey"`
+	RoomID          string
+	UserID          string
+	Username        string
+	Content         string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time `gorm:"autoUpdateTime:false"` // Only an edit sets it
+	Encrypted       bool
+	KeyVersion      int
+	Type            model.MessageType
+	ParentMessageID string
+	Mentions        []model.Mention `gorm:"serializer:json"`
+	Flagged         bool
+	Preview         *model.LinkPreview `gorm:"serializer:json"`
+}
+
+func (messageRecord) TableName() string {
+	return "messages"
+}
+
+func newMessageRecord(message *model.Message) *messageRecord {
+	return &messageRecord{
+		ID:              message.ID,
+		RoomID:          message.RoomID,
+		UserID:          message.UserID,
+		Username:        message.Username,
+		Content:         message.Content,
+		CreatedAt:       message.CreatedAt,
+		UpdatedAt:       message.UpdatedAt,
+		Encrypted:       message.Encrypted,
+		KeyVersion:      message.KeyVersion,
+		Type:            message.Type,
+		ParentMessageID: message.ParentMessageID,
+		Mentions:        message.Mentions,
+		Flagged:         message.Flagged,
+		Preview:         message.Preview,
+	}
+}
+
+func (m *messageRecord) toModel() *model.Message {
+	return &model.Message{
+		ID:              m.ID,
+		RoomID:          m.RoomID,
+		UserID:          m.UserID,
+		Username:        m.Username,
+		Content:         m.Content,
+		CreatedAt:       m.CreatedAt,
+		UpdatedAt:       m.UpdatedAt,
+		Encrypted:       m.Encrypted,
+		KeyVersion:      m.KeyVersion,
+		Type:            m.Type,
+		ParentMessageID: m.ParentMessageID,
+		Mentions:        m.Mentions,
+		Flagged:         m.Flagged,
+		Preview:         m.Preview,
+	}
+}
+
+func toMessages(records []messageRecord) []*model.Message {
+	messages := make([]*model.Message, len(records))
+	for i := range records {
+		messages[i] = records[i].toModel()
+	}
+	return messages
+}
+
+// PostgresMessageRepository reports a missing message as redis.Nil like the Redis repository does
+type PostgresMessageRepository struct {
+	*BaseRepository[messageRecord]
+}
+
+func NewPostgresMessageRepository(cfg *config.Config, zapLogger *zap.Logger) repository.MessageRepository {
+	return &PostgresMessageRepository{
+		BaseRepository: NewBaseRepository[messageRecord](cfg, nil, zapLogger),
+	}
+}
+
+func (r *PostgresMessageRepository) GetByID(ctx context.Context, roomID, messageID string) (*model.Message, error) {
+	var record messageRecord
+	err := r.database.WithContext(ctx).
+		Where("room_id = ? AND id = ?", roomID, messageID).
+		First(&record).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, redis.Nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record.toModel(), nil
+}
+
+func (r *PostgresMessageRepository) Create(ctx context.Context, message *model.Message) error {
+	message.CreatedAt = time.Now()
+
+	if err := r.database.WithContext(ctx).Create(newMessageRecord(message)).Error; err != nil {
+		r.logger.Error(ctx, err.Error())
+		return err
+	}
+	return nil
+}
+
+// Update replaces the message but keeps where it sits in the history
+func (r *PostgresMessageRepository) Update(ctx context.Context, message *model.Message) error {
+	message.UpdatedAt = time.Now()
+
+	result := r.database.WithContext(ctx).
+		Model(&messageRecord{}).
+		Where("room_id = ? AND id = ?", message.RoomID, message.ID).
+		Select("*").
+		Omit("id", "room_id", "created_at").
+		Updates(newMessageRecord(message))
+	if result.Error != nil {
+		r.logger.Error(ctx, result.Error.Error())
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return redis.Nil
+	}
+	return nil
+}
+
+// SetPreview leaves UpdatedAt alone, a preview arriving shouldn't mark the message as edited
+func (r *PostgresMessageRepository) SetPreview(ctx context.Context, roomID, messageID string, preview *model.LinkPreview) error {
+	result := r.database.WithContext(ctx).
+		Model(&messageRecord{}).
+		Where("room_id = ? AND id = ?", roomID, messageID).
+		Select("preview").
+		Updates(&messageRecord{Preview: preview})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return redis.Nil
+	}
+	return nil
+}
+
+func (r *PostgresMessageRepository) Delete(ctx context.Context, roomID, messageID string) error {
+	result := r.database.WithContext(ctx).
+		Where("room_id = ? AND id = ?", roomID, messageID).
+		Delete(&messageRecord{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return redis.Nil
+	}
+	return nil
+}
+
+// GetByRoom returns the latest limit messages, oldest first
+func (r *PostgresMessageRepository) GetByRoom(ctx context.Context, roomID string, limit int64) ([]*model.Message, error) {
+	var records []messageRecord
+	err := r.database.WithContext(ctx).
+		Where("room_id = ?", roomID).
+		Order("created_at DESC").
+		Limit(limitOrAll(limit)).
+		Find(&records).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	messages := toMessages(records)
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func (r *PostgresMessageRepository) GetByRoomAfter(ctx context.Context, roomID string, after time.Time, limit int64) ([]*model.Message, error) {
+	var records []messageRecord
+	err := r.database.WithContext(ctx).
+		Where("room_id = ? AND created_at >= ?", roomID, after).
+		Order("created_at").
+		Limit(limitOrAll(limit)).
+		Find(&records).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return toMessages(records), nil
+}
+
+func (r *PostgresMessageRepository) DeleteOldMessages(ctx context.Context, roomID string, before time.Time) error {
+	return r.database.WithContext(ctx).
+		Where("room_id = ? AND created_at <= ?", roomID, before).
+		Delete(&messageRecord{}).
+		Error
+}
+
+func (r *PostgresMessageRepository) Count(ctx context.Context, roomID string) (int64, error) {
+	var count int64
+	err := r.database.WithContext(ctx).Model(&messageRecord{}).Where("room_id = ?", roomID).Count(&count).Error
+	return count, err
+}
+
+// GetReplies returns a page of a thread in chronological order along with the total reply count
+func (r *PostgresMessageRepository) GetReplies(ctx context.Context, roomID, parentMessageID string, offset, limit int64) ([]*model.Message, int64, error) {
+	// A fresh chain for each query, gorm statements can't be reused once executed
+	thread := func() *gorm.DB {
+		return r.database.WithContext(ctx).
+			Model(&messageRecord{}).
+			Where("room_id = ? AND parent_message_id = ?", roomID, parentMessageID)
+	}
+
+	var total int64
+	if err := thread().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var records []messageRecord
+	err := thread().
+		Order("created_at").
+		Offset(int(offset)).
+		Limit(int(limit)).
+		Find(&records).
+		Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return toMessages(records), total, nil
+}
+
+// DeleteByUser removes every message the user authored in the room and returns how many were removed
+func (r *PostgresMessageRepository) DeleteByUser(ctx context.Context, roomID, userID string) (int, error) {
+	result := r.database.WithContext(ctx).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Delete(&messageRecord{})
+	return int(result.RowsAffected), result.Error
+}
+
+// limitOrAll reads a limit of 0 as everything, like the Redis repository does. gorm drops
+// the limit for -1 only, 0 would return nothing.
+func limitOrAll(limit int64) int {
+	if limit <= 0 {
+		return -1
+	}
+	return int(limit)
+}
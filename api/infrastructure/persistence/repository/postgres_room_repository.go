@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/config"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type roomRecord struct {
+	ID            string `gorm:"primaryKey"`
+	JoinCode      string
+	SecureCode    string
+	Owner         model.User `gorm:"serializer:json"`
+	CreatedAt     time.Time
+	Expiry        time.Duration
+	EncryptionKey string
+	KeyVersion    int
+	RetiredKeys   []model.RoomKey    `gorm:"serializer:json"`
+	Settings      model.RoomSettings `gorm:"serializer:json"`
+}
+
+func (roomRecord) TableName() string {
+	return "rooms"
+}
+
+func newRoomRecord(room *model.Room) *roomRecord {
+	return &roomRecord{
+		ID:            room.ID,
+		JoinCode:      room.JoinCode,
+		SecureCode:    room.SecureCode,
+		Owner:         room.Owner,
+		CreatedAt:     room.CreatedAt,
+		Expiry:        room.Expiry,
+		EncryptionKey: room.EncryptionKey,
+		KeyVersion:    room.KeyVersion,
+		RetiredKeys:   room.RetiredKeys,
+		Settings:      room.Settings,
+	}
+}
+
+func (r *roomRecord) toModel(members []model.User) *model.Room {
+	return &model.Room{
+		ID:            r.ID,
+		JoinCode:      r.JoinCode,
+		SecureCode:    r.SecureCode,
+		Owner:         r.Owner,
+		CreatedAt:     r.CreatedAt,
+		Expiry:        r.Expiry,
+		Members:       members,
+		EncryptionKey: r.EncryptionKey,
+		Settings:      r.Settings,
+		KeyVersion:    r.KeyVersion,
+		RetiredKeys:   r.RetiredKeys,
+	}
+}
+
+type roomMemberRecord struct {
+	RoomID   string `gorm:"primaryKey"`
+	UserID   string `gorm:"primaryKey"`
+	JoinedAt time.Time
+}
+
+func (roomMemberRecord) TableName() string {
+	return "room_members"
+}
+
+// memberRow is a member joined with their user, members whose user is gone drop out of the join
+type memberRow struct {
+	RoomID string
+	userRecord
+}
+
+// PostgresRoomRepository keeps the member list in room_members, like the Redis repository
+// it ignores Members on Create and Update, AddUser and RemoveUser manage them
+type PostgresRoomRepository struct {
+	*BaseRepository[roomRecord]
+	userRepository repository.UserRepository
+}
+
+// NewPostgresRoomRepository resolves members through userRepository, unless users are kept
+// in Postgres too and a join does it in one query
+func NewPostgresRoomRepository(cfg *config.Config, userRepository repository.UserRepository, zapLogger *zap.Logger) repository.RoomRepository {
+	return &PostgresRoomRepository{
+		BaseRepository: NewBaseRepository[roomRecord](cfg, nil, zapLogger),
+		userRepository: userRepository,
+	}
+}
+
+func (r *PostgresRoomRepository) Create(ctx context.Context, room *model.Room) error {
+	room.CreatedAt = time.Now()
+
+	if err := r.database.WithContext(ctx).Create(newRoomRecord(room)).Error; err != nil {
+		r.logger.Error(ctx, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresRoomRepository) GetByID(ctx context.Context, id string) (*model.Room, error) {
+	var record roomRecord
+	err := r.database.WithContext(ctx).Where("id = ?", id).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, redis.Nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := r.members(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return record.toModel(members[id]), nil
+}
+
+func (r *PostgresRoomRepository) GetByJoinCode(ctx context.Context, joinCode string) (*model.Room, error) {
+	var record roomRecord
+	err := r.database.WithContext(ctx).Where("join_code = ?", joinCode).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, redis.Nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := r.members(ctx, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	return record.toModel(members[record.ID]), nil
+}
+
+func (r *PostgresRoomRepository) GetAll(ctx context.Context) ([]*model.Room, error) {
+	var records []roomRecord
+	if err := r.database.WithContext(ctx).Order("created_at").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+
+	members, err := r.members(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make([]*model.Room, len(records))
+	for i := range records {
+		rooms[i] = records[i].toModel(members[records[i].ID])
+	}
+	return rooms, nil
+}
+
+// members loads the members of every given room in one query, in the order they joined
+func (r *PostgresRoomRepository) members(ctx context.Context, roomIDs ...string) (map[string][]model.User, error) {
+	members := make(map[string][]model.User, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return members, nil
+	}
+
+	if _, joinable := r.userRepository.(*PostgresUserRepository); !joinable {
+		return r.lookupMembers(ctx, roomIDs, members)
+	}
+
+	var rows []memberRow
+	err := r.database.WithContext(ctx).
+		Table("room_members").
+		Select("room_members.room_id, users.id, users.username, users.is_guest, users.is_bot, users.created_at").
+		Joins("JOIN users ON users.id = room_members.user_id").
+		Where("room_members.room_id IN ?", roomIDs).
+		Order("room_members.joined_at").
+		Scan(&rows).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		members[row.RoomID] = append(members[row.RoomID], *row.toModel())
+	}
+	return members, nil
+}
+
+// lookupMembers skips members whose user is gone, the same as the join does
+func (r *PostgresRoomRepository) lookupMembers(ctx context.Context, roomIDs []string, members map[string][]model.User) (map[string][]model.User, error) {
+	var records []roomMemberRecord
+	err := r.database.WithContext(ctx).
+		Where("room_id IN ?", roomIDs).
+		Order("joined_at").
+		Find(&records).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		user, err := r.userRepository.GetByID(ctx, record.UserID)
+		if err != nil {
+			continue
+		}
+		members[record.RoomID] = append(members[record.RoomID], *user)
+	}
+	return members, nil
+}
+
+func (r *PostgresRoomRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.database.WithContext(ctx).Model(&roomRecord{}).Count(&count).Error
+	return count, err
+}
+
+// Delete takes the room's members with it, messages are cleaned up by the retention job
+func (r *PostgresRoomRepository) Delete(ctx context.Context, id string) error {
+	return r.database.WithContext(ctx).Delete(&roomRecord{}, "id = ?", id).Error
+}
+
+// AddUser locks the room row, so concurrent joins can't overshoot MaxMembers
+func (r *PostgresRoomRepository) AddUser(ctx context.Context, roomID string, user model.User) error {
+	return r.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		room, err := lockRoom(tx, roomID)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&roomMemberRecord{}).Where("room_id = ?", roomID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		var isMember int64
+		if err := tx.Model(&roomMemberRecord{}).Where("room_id = ? AND user_id = ?", roomID, user.ID).Count(&isMember).Error; err != nil {
+			return err
+		}
+		if isMember > 0 {
+			return nil
+		}
+
+		if room.Settings.MaxMembers > 0 && count >= int64(room.Settings.MaxMembers) {
+			return repository.ErrRoomFull
+		}
+
+		return tx.Create(&roomMemberRecord{RoomID: roomID, UserID: user.ID, JoinedAt: time.Now()}).Error
+	})
+}
+
+func (r *PostgresRoomRepository) RemoveUser(ctx context.Context, roomID, userID string) error {
+	return r.removeMember(ctx, roomID, userID, "")
+}
+
+func (r *PostgresRoomRepository) KickUser(ctx context.Context, roomID, userID, requesterID string) error {
+	return r.removeMember(ctx, roomID, userID, requesterID)
+}
+
+// removeMember checks ownership with the room row locked, an empty requesterID skips the
+// owner check and makes removing a non-member a no-op
+func (r *PostgresRoomRepository) removeMember(ctx context.Context, roomID, userID, requesterID string) error {
+	return r.database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		room, err := lockRoom(tx, roomID)
+		if err != nil {
+			return err
+		}
+
+		if requesterID != "" && requesterID != room.Owner.ID {
+			return repository.ErrNotRoomOwner
+		}
+		if userID == room.Owner.ID {
+			return repository.ErrOwnerProtected
+		}
+
+		result := tx.Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&roomMemberRecord{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 && requesterID != "" {
+			return repository.ErrNotRoomMember
+		}
+		return nil
+	})
+}
+
+func (r *PostgresRoomRepository) GetUsers(ctx context.Context, roomID string) ([]string, error) {
+	var userIDs []string
+	err := r.database.WithContext(ctx).
+		Model(&roomMemberRecord{}).
+		Where("room_id = ?", roomID).
+		Order("joined_at").
+		Pluck("user_id", &userIDs).
+		Error
+	return userIDs, err
+}
+
+func (r *PostgresRoomRepository) Update(ctx context.Context, room *model.Room) error {
+	result := r.database.WithContext(ctx).
+		Model(&roomRecord{ID: room.ID}).
+		Select("*").
+		Omit("id").
+		Updates(newRoomRecord(room))
+	if result.Error != nil {
+		r.logger.Error(ctx, result.Error.Error())
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("room with id %s does not exist", room.ID)
+	}
+	return nil
+}
+
+func lockRoom(tx *gorm.DB, roomID string) (*roomRecord, error) {
+	var room roomRecord
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", roomID).First(&room).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("room not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
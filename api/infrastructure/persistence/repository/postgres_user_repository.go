@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/config"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type userRecord struct {
+	ID        string `gorm:"primaryKey"`
+	Username  string
+	IsGuest   bool
+	IsBot     bool
+	CreatedAt time.Time
+}
+
+func (userRecord) TableName() string {
+	return "users"
+}
+
+func newUserRecord(user *model.User) *userRecord {
+	return &userRecord{
+		ID:        user.ID,
+		Username:  user.Username,
+		IsGuest:   user.IsGuest,
+		IsBot:     user.IsBot,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func (u *userRecord) toModel() *model.User {
+	return &model.User{
+		ID:        u.ID,
+		Username:  u.Username,
+		IsGuest:   u.IsGuest,
+		IsBot:     u.IsBot,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+type usernameRecord struct {
+	Username string `gorm:"primaryKey"`
+	UserID   string
+}
+
+func (usernameRecord) TableName() string {
+	return "usernames"
+}
+
+// PostgresUserRepository reports a missing user as redis.Nil like the Redis repository does,
+// the use cases check for it
+type PostgresUserRepository struct {
+	*BaseRepository[userRecord]
+}
+
+func NewPostgresUserRepository(cfg *config.Config, zapLogger *zap.Logger) repository.UserRepository {
+	return &PostgresUserRepository{
+		BaseRepository: NewBaseRepository[userRecord](cfg, nil, zapLogger),
+	}
+}
+
+func (r *PostgresUserRepository) Create(ctx context.Context, user *model.User) error {
+	user.CreatedAt = time.Now()
+
+	err := r.database.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(newUserRecord(user)).
+		Error
+	if err != nil {
+		r.logger.Error(ctx, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresUserRepository) Delete(ctx context.Context, id string) error {
+	return r.database.WithContext(ctx).Delete(&userRecord{}, "id = ?", id).Error
+}
+
+func (r *PostgresUserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
+	var record userRecord
+	err := r.database.WithContext(ctx).Where("id = ?", id).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, redis.Nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record.toModel(), nil
+}
+
+func (r *PostgresUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	var record userRecord
+	err := r.database.WithContext(ctx).
+		Joins("JOIN usernames ON usernames.user_id = users.id").
+		Where("usernames.username = ?", username).
+		First(&record).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record.toModel(), nil
+}
+
+func (r *PostgresUserRepository) SetUsernameIndex(ctx context.Context, username, userID string) error {
+	return r.database.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "username"}},
+			DoUpdates: clause.AssignmentColumns([]string{"user_id"}),
+		}).
+		Create(&usernameRecord{Username: username, UserID: userID}).
+		Error
+}
+
+// DeleteUsernameIndex only drops the index while it still points at userID
+func (r *PostgresUserRepository) DeleteUsernameIndex(ctx context.Context, username, userID string) (bool, error) {
+	result := r.database.WithContext(ctx).
+		Where("username = ? AND user_id = ?", username, userID).
+		Delete(&usernameRecord{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	pushSubscriptionsKeyPrefix = "push:subscriptions:"
+	notificationPrefsKeyPrefix = "push:preferences:"
+)
+
+type pushSubscriptionRepository struct {
+	client redis.UniversalClient
+}
+
+func NewPushSubscriptionRepository(client redis.UniversalClient) repository.PushSubscriptionRepository {
+	return &pushSubscriptionRepository{
+		client: client,
+	}
+}
+
+// Save upserts by subscription ID, so re-registering the same device is idempotent
+func (r *pushSubscriptionRepository) Save(ctx context.Context, subscription *model.PushSubscription) error {
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return err
+	}
+
+	key := pushSubscriptionsKeyPrefix + subscription.UserID
+	return r.client.HSet(ctx, key, subscription.ID, data).Err()
+}
+
+func (r *pushSubscriptionRepository) GetByUserID(ctx context.Context, userID string) ([]*model.PushSubscription, error) {
+	key := pushSubscriptionsKeyPrefix + userID
+	values, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions := make([]*model.PushSubscription, 0, len(values))
+	for _, data := range values {
+		var subscription model.PushSubscription
+		if err := json.Unmarshal([]byte(data), &subscription); err != nil {
+			continue
+		}
+		subscriptions = append(subscriptions, &subscription)
+	}
+
+	return subscriptions, nil
+}
+
+func (r *pushSubscriptionRepository) Delete(ctx context.Context, userID, subscriptionID string) error {
+	key := pushSubscriptionsKeyPrefix + userID
+	return r.client.HDel(ctx, key, subscriptionID).Err()
+}
+
+type notificationPreferenceRepository struct {
+	client redis.UniversalClient
+}
+
+func NewNotificationPreferenceRepository(client redis.UniversalClient) repository.NotificationPreferenceRepository {
+	return &notificationPreferenceRepository{
+		client: client,
+	}
+}
+
+func (r *notificationPreferenceRepository) Get(ctx context.Context, userID, roomID string) (model.NotificationLevel, error) {
+	key := notificationPrefsKeyPrefix + userID
+	level, err := r.client.HGet(ctx, key, roomID).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return model.NotificationLevel(level), nil
+}
+
+func (r *notificationPreferenceRepository) Set(ctx context.Context, userID, roomID string, level model.NotificationLevel) error {
+	key := notificationPrefsKeyPrefix + userID
+	return r.client.HSet(ctx, key, roomID, string(level)).Err()
+}
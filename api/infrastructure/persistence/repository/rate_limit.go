@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// Must stay in sync with the keys written by middlewares.RateLimiterMiddleware
+const rateLimitBlockKeyPrefix = "ratelimit:block:"
+
+// rateLimitExemptionsKey is a hash of the exemptions by kind and value
+const rateLimitExemptionsKey = "ratelimit:exemptions"
+
+type rateLimitRepository struct {
+	client redis.UniversalClient
+}
+
+func NewRateLimitRepository(client redis.UniversalClient) repository.RateLimitRepository {
+	return &rateLimitRepository{
+		client: client,
+	}
+}
+
+func (r *rateLimitRepository) GetBlocks(ctx context.Context) ([]*model.RateLimitBlock, error) {
+	blocks := make([]*model.RateLimitBlock, 0)
+
+	keys, err := cache.ScanKeys(ctx, r.client, rateLimitBlockKeyPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan rate limit blocks: %w", err)
+	}
+
+	for _, key := range keys {
+		ttl, err := r.client.TTL(ctx, key).Result()
+		if err != nil || ttl <= 0 {
+			continue // Block expired while scanning
+		}
+
+		blocks = append(blocks, &model.RateLimitBlock{
+			UserID:    strings.Trim(strings.TrimPrefix(key, rateLimitBlockKeyPrefix), "{}"),
+			Remaining: ttl,
+		})
+	}
+
+	return blocks, nil
+}
+
+func (r *rateLimitRepository) DeleteBlock(ctx context.Context, userID string) error {
+	key := rateLimitBlockKeyPrefix + cache.HashTag(r.client, userID)
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *rateLimitRepository) GetExemptions(ctx context.Context) ([]*model.RateLimitExemption, error) {
+	entries, err := r.client.HGetAll(ctx, rateLimitExemptionsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit exemptions: %w", err)
+	}
+
+	exemptions := make([]*model.RateLimitExemption, 0, len(entries))
+	for _, data := range entries {
+		var exemption model.RateLimitExemption
+		if err := json.Unmarshal([]byte(data), &exemption); err != nil {
+			continue
+		}
+		exemptions = append(exemptions, &exemption)
+	}
+
+	sort.Slice(exemptions, func(i, j int) bool {
+		return exemptions[i].CreatedAt.Before(exemptions[j].CreatedAt)
+	})
+
+	return exemptions, nil
+}
+
+func (r *rateLimitRepository) SaveExemption(ctx context.Context, exemption *model.RateLimitExemption) error {
+	data, err := json.Marshal(exemption)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rate limit exemption: %w", err)
+	}
+
+	return r.client.HSet(ctx, rateLimitExemptionsKey, rateLimitExemptionField(exemption.Kind, exemption.Value), data).Err()
+}
+
+func (r *rateLimitRepository) DeleteExemption(ctx context.Context, kind model.RateLimitExemptionKind, value string) (bool, error) {
+	removed, err := r.client.HDel(ctx, rateLimitExemptionsKey, rateLimitExemptionField(kind, value)).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+func rateLimitExemptionField(kind model.RateLimitExemptionKind, value string) string {
+	return fmt.Sprintf("%s:%s", kind, value)
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Reactions go away with the messages they belong to
+	reactionTTL = 7 * 24 * time.Hour
+
+	// Hash fields are userID|emoji, user IDs are UUIDs so the separator can't collide
+	reactionFieldSeparator = "|"
+)
+
+type reactionRepository struct {
+	client redis.UniversalClient
+}
+
+func NewReactionRepository(client redis.UniversalClient) repository.ReactionRepository {
+	return &reactionRepository{
+		client: client,
+	}
+}
+
+func (r *reactionRepository) Add(ctx context.Context, roomID, messageID, userID, emoji string) (bool, error) {
+	key := reactionKey(roomID, messageID)
+
+	pipe := r.client.TxPipeline()
+	added := pipe.HSetNX(ctx, key, reactionField(userID, emoji), time.Now().Unix())
+	pipe.Expire(ctx, key, reactionTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return added.Val(), nil
+}
+
+func (r *reactionRepository) Remove(ctx context.Context, roomID, messageID, userID, emoji string) (bool, error) {
+	removed, err := r.client.HDel(ctx, reactionKey(roomID, messageID), reactionField(userID, emoji)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return removed > 0, nil
+}
+
+func (r *reactionRepository) GetCounts(ctx context.Context, roomID, messageID string) (map[string]int, error) {
+	fields, err := r.client.HKeys(ctx, reactionKey(roomID, messageID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return countReactions(fields), nil
+}
+
+func (r *reactionRepository) GetCountsForMessages(ctx context.Context, roomID string, messageIDs []string) (map[string]map[string]int, error) {
+	counts := make(map[string]map[string]int, len(messageIDs))
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make(map[string]*redis.StringSliceCmd, len(messageIDs))
+	for _, messageID := range messageIDs {
+		cmds[messageID] = pipe.HKeys(ctx, reactionKey(roomID, messageID))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for messageID, cmd := range cmds {
+		if fields := cmd.Val(); len(fields) > 0 {
+			counts[messageID] = countReactions(fields)
+		}
+	}
+
+	return counts, nil
+}
+
+func (r *reactionRepository) DeleteByMessage(ctx context.Context, roomID, messageID string) error {
+	return r.client.Del(ctx, reactionKey(roomID, messageID)).Err()
+}
+
+func reactionKey(roomID, messageID string) string {
+	return fmt.Sprintf("room:%s:message:%s:reactions", roomID, messageID)
+}
+
+func reactionField(userID, emoji string) string {
+	return userID + reactionFieldSeparator + emoji
+}
+
+func countReactions(fields []string) map[string]int {
+	counts := make(map[string]int)
+	for _, field := range fields {
+		_, emoji, ok := strings.Cut(field, reactionFieldSeparator)
+		if !ok {
+			continue
+		}
+		counts[emoji]++
+	}
+	return counts
+}
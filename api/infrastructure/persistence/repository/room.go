@@ -14,6 +14,51 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	// joinCodeIndexKey maps join codes to room IDs
+	joinCodeIndexKey = "rooms:joincodes"
+	// joinCodeIndexReadyKey is set once rooms from before the index have been added to it
+	joinCodeIndexReadyKey = "rooms:joincodes:ready"
+)
+
+// Results of the membership scripts, negative ones map to an error in membershipError
+const (
+	membershipUnchanged      = 0
+	membershipChanged        = 1
+	membershipRoomNotFound   = -1
+	membershipRoomFull       = -2
+	membershipNotOwner       = -3
+	membershipOwnerProtected = -4
+	membershipNotMember      = -5
+)
+
+// KEYS: room, room users. ARGV: user ID.
+var addMemberScript = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if not data then return -1 end
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then return 0 end
+local room = cjson.decode(data)
+local max = tonumber(room.settings and room.settings.maxMembers) or 0
+if max > 0 and redis.call('SCARD', KEYS[2]) >= max then return -2 end
+redis.call('SADD', KEYS[2], ARGV[1])
+return 1
+`)
+
+// KEYS: room, room users. ARGV: user ID, requester ID (empty skips the owner check and
+// makes removing a non-member a no-op).
+var removeMemberScript = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if not data then return -1 end
+local owner = cjson.decode(data).owner.id
+if ARGV[2] ~= '' and ARGV[2] ~= owner then return -3 end
+if ARGV[1] == owner then return -4 end
+if redis.call('SREM', KEYS[2], ARGV[1]) == 1 then return 1 end
+if ARGV[2] ~= '' then return -5 end
+return 0
+`)
+
+// Room records hold the room keys and settings, they are read from Redis every time so a
+// rotation or update on another instance is seen right away
 type roomRepository struct {
 	cache          *cache.DistributedCache
 	userRepository repository.UserRepository
@@ -42,50 +87,21 @@ func (r *roomRepository) AddUser(ctx context.Context, roomID string, user model.
 		attribute.String("user.username", user.Username),
 	)
 
-	// Use cache for set operations
-	userKey := fmt.Sprintf("room:%s:users", roomID)
-	if err := r.cache.SAdd(ctx, userKey, user.ID); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to add user to room set")
-		return err
-	}
-
-	// Get room from cache
-	roomKey := fmt.Sprintf("room:%s", roomID)
-	var room model.Room
-	found, err := r.cache.Get(roomKey, &room)
+	// Members are only kept in the set, GetByID fills Members from it
+	keys := []string{r.roomKey(roomID), r.roomUsersKey(roomID)}
+	result, err := r.cache.RunScript(ctx, addMemberScript, keys, user.ID).Int()
 	if err != nil {
 		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to get room from cache")
+		span.SetStatus(codes.Error, "failed to add user to room set")
 		return err
 	}
-	if !found {
-		span.SetAttributes(attribute.Bool("room.found", false))
-		span.SetStatus(codes.Error, "room not found")
-		return fmt.Errorf("room not found")
-	}
-
-	span.SetAttributes(
-		attribute.Bool("room.found", true),
-	)
-
-	// Check if user already exists in members
-	if room.IsMember(user.ID) {
-		span.SetAttributes(attribute.Bool("user.already_member", true))
-		span.SetStatus(codes.Ok, "user already a member")
-		return nil
-	}
 
-	span.SetAttributes(attribute.Bool("user.already_member", false))
-	room.Members = append(room.Members, user)
-
-	// Update cache
-	if err := r.cache.Set(roomKey, &room, 0); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to update room in cache")
+	if err := membershipError(result); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
 
+	span.SetAttributes(attribute.Bool("user.already_member", result == membershipUnchanged))
 	span.SetStatus(codes.Ok, "user added to room successfully")
 	return nil
 }
@@ -101,8 +117,8 @@ func (r *roomRepository) Create(ctx context.Context, room *model.Room) error {
 
 	room.CreatedAt = time.Now()
 
-	key := fmt.Sprintf("room:%s", room.ID)
-	if err := r.cache.Set(key, room, 0); err != nil {
+	key := r.roomKey(room.ID)
+	if err := r.cache.SetRemote(ctx, key, room, 0); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to create room in cache")
 		return err
@@ -115,6 +131,12 @@ func (r *roomRepository) Create(ctx context.Context, room *model.Room) error {
 		return err
 	}
 
+	if err := r.cache.HSet(ctx, joinCodeIndexKey, room.JoinCode, room.ID); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to index join code")
+		return err
+	}
+
 	span.SetStatus(codes.Ok, "room created successfully")
 	return nil
 }
@@ -125,7 +147,17 @@ func (r *roomRepository) Delete(ctx context.Context, id string) error {
 
 	span.SetAttributes(attribute.String("room.id", id))
 
-	key := fmt.Sprintf("room:%s", id)
+	key := r.roomKey(id)
+
+	var room model.Room
+	if found, err := r.cache.GetRemote(ctx, key, &room); err == nil && found {
+		if err := r.unindexJoinCode(ctx, room.JoinCode, id); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to remove join code from index")
+			return err
+		}
+	}
+
 	if err := r.cache.Delete(key); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to delete room from cache")
@@ -142,6 +174,22 @@ func (r *roomRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+func (r *roomRepository) Count(ctx context.Context) (int64, error) {
+	ctx, span := r.tracer.Start(ctx, "roomRepository.Count")
+	defer span.End()
+
+	count, err := r.cache.SCard(ctx, "rooms")
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to count rooms set")
+		return 0, err
+	}
+
+	span.SetAttributes(attribute.Int64("rooms.total_count", count))
+	span.SetStatus(codes.Ok, "rooms counted successfully")
+	return count, nil
+}
+
 func (r *roomRepository) GetAll(ctx context.Context) ([]*model.Room, error) {
 	ctx, span := r.tracer.Start(ctx, "roomRepository.GetAll")
 	defer span.End()
@@ -187,10 +235,10 @@ func (r *roomRepository) GetByID(ctx context.Context, id string) (*model.Room, e
 
 	span.SetAttributes(attribute.String("room.id", id))
 
-	key := fmt.Sprintf("room:%s", id)
+	key := r.roomKey(id)
 	var room model.Room
 
-	found, err := r.cache.Get(key, &room)
+	found, err := r.cache.GetRemote(ctx, key, &room)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to get room from cache")
@@ -243,7 +291,7 @@ func (r *roomRepository) GetUsers(ctx context.Context, roomID string) ([]string,
 
 	span.SetAttributes(attribute.String("room.id", roomID))
 
-	key := fmt.Sprintf("room:%s:users", roomID)
+	key := r.roomUsersKey(roomID)
 	userIDs, err := r.cache.SMembers(ctx, key)
 	if err != nil {
 		span.RecordError(err)
@@ -265,46 +313,35 @@ func (r *roomRepository) RemoveUser(ctx context.Context, roomID string, userID s
 		attribute.String("user.id", userID),
 	)
 
-	userKey := fmt.Sprintf("room:%s:users", roomID)
-	if err := r.cache.SRem(ctx, userKey, userID); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to remove user from room set")
-		return err
-	}
-
-	roomKey := fmt.Sprintf("room:%s", roomID)
-	var room model.Room
+	return r.removeMember(ctx, span, roomID, userID, "")
+}
 
-	found, err := r.cache.Get(roomKey, &room)
-	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to get room from cache")
-		return err
-	}
-	if !found {
-		span.SetAttributes(attribute.Bool("room.found", false))
-		span.SetStatus(codes.Error, "room not found")
-		return fmt.Errorf("room not found")
-	}
+func (r *roomRepository) KickUser(ctx context.Context, roomID, userID, requesterID string) error {
+	ctx, span := r.tracer.Start(ctx, "roomRepository.KickUser")
+	defer span.End()
 
 	span.SetAttributes(
-		attribute.Bool("room.found", true),
+		attribute.String("room.id", roomID),
+		attribute.String("user.id", userID),
+		attribute.String("requester.id", requesterID),
 	)
 
-	userFound := false
-	for i, member := range room.Members {
-		if member.ID == userID {
-			room.Members = append(room.Members[:i], room.Members[i+1:]...)
-			userFound = true
-			break
-		}
-	}
-
-	span.SetAttributes(attribute.Bool("user.found_in_members", userFound))
+	return r.removeMember(ctx, span, roomID, userID, requesterID)
+}
 
-	if err := r.cache.Set(roomKey, &room, 0); err != nil {
+// removeMember checks ownership against the stored room in the same script that removes the
+// member, so a concurrent join, kick or update can't slip in between
+func (r *roomRepository) removeMember(ctx context.Context, span trace.Span, roomID, userID, requesterID string) error {
+	keys := []string{r.roomKey(roomID), r.roomUsersKey(roomID)}
+	result, err := r.cache.RunScript(ctx, removeMemberScript, keys, userID, requesterID).Int()
+	if err != nil {
 		span.RecordError(err)
-		span.SetStatus(codes.Error, "failed to update room in cache")
+		span.SetStatus(codes.Error, "failed to remove user from room set")
+		return err
+	}
+
+	if err := membershipError(result); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
 
@@ -321,11 +358,11 @@ func (r *roomRepository) Update(ctx context.Context, room *model.Room) error {
 		attribute.Int("room.members_count", len(room.Members)),
 	)
 
-	key := fmt.Sprintf("room:%s", room.ID)
+	key := r.roomKey(room.ID)
 
 	// Check if room exists in cache
 	var existingRoom model.Room
-	found, err := r.cache.Get(key, &existingRoom)
+	found, err := r.cache.GetRemote(ctx, key, &existingRoom)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to get existing room from cache")
@@ -339,12 +376,131 @@ func (r *roomRepository) Update(ctx context.Context, room *model.Room) error {
 
 	span.SetAttributes(attribute.Bool("room.exists", true))
 
-	if err := r.cache.Set(key, room, 0); err != nil {
+	if err := r.cache.SetRemote(ctx, key, room, 0); err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "failed to update room in cache")
 		return err
 	}
 
+	if existingRoom.JoinCode != room.JoinCode {
+		if err := r.unindexJoinCode(ctx, existingRoom.JoinCode, room.ID); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to remove old join code from index")
+			return err
+		}
+		if err := r.cache.HSet(ctx, joinCodeIndexKey, room.JoinCode, room.ID); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to index join code")
+			return err
+		}
+	}
+
 	span.SetStatus(codes.Ok, "room updated successfully")
 	return nil
 }
+
+func (r *roomRepository) GetByJoinCode(ctx context.Context, joinCode string) (*model.Room, error) {
+	ctx, span := r.tracer.Start(ctx, "roomRepository.GetByJoinCode")
+	defer span.End()
+
+	roomID, err := r.cache.HGet(ctx, joinCodeIndexKey, joinCode)
+	if err == redis.Nil {
+		roomID, err = r.backfillJoinCodes(ctx, joinCode)
+	}
+	if err != nil {
+		if err != redis.Nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to look up join code")
+		}
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("room.id", roomID))
+
+	room, err := r.GetByID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The index is only cleaned up on Update and Delete, never trust it over the room itself
+	if room.JoinCode != joinCode {
+		return nil, redis.Nil
+	}
+
+	span.SetStatus(codes.Ok, "room retrieved by join code successfully")
+	return room, nil
+}
+
+// backfillJoinCodes indexes the rooms created before the index existed. It scans every room
+// once per deployment, after that a miss means the code doesn't exist.
+func (r *roomRepository) backfillJoinCodes(ctx context.Context, joinCode string) (string, error) {
+	var ready bool
+	if found, err := r.cache.Get(joinCodeIndexReadyKey, &ready); err != nil {
+		return "", err
+	} else if found {
+		return "", redis.Nil
+	}
+
+	rooms, err := r.GetAll(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	roomID := ""
+	for _, room := range rooms {
+		if err := r.cache.HSet(ctx, joinCodeIndexKey, room.JoinCode, room.ID); err != nil {
+			return "", err
+		}
+		if room.JoinCode == joinCode {
+			roomID = room.ID
+		}
+	}
+
+	if err := r.cache.Set(joinCodeIndexReadyKey, true, 0); err != nil {
+		return "", err
+	}
+
+	if roomID == "" {
+		return "", redis.Nil
+	}
+	return roomID, nil
+}
+
+// unindexJoinCode leaves the code alone when it was handed to another room since
+func (r *roomRepository) unindexJoinCode(ctx context.Context, joinCode, roomID string) error {
+	indexedID, err := r.cache.HGet(ctx, joinCodeIndexKey, joinCode)
+	if err == redis.Nil || (err == nil && indexedID != roomID) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.cache.HDel(ctx, joinCodeIndexKey, joinCode)
+}
+
+func membershipError(result int) error {
+	switch result {
+	case membershipRoomNotFound:
+		return fmt.Errorf("room not found")
+	case membershipRoomFull:
+		return repository.ErrRoomFull
+	case membershipNotOwner:
+		return repository.ErrNotRoomOwner
+	case membershipOwnerProtected:
+		return repository.ErrOwnerProtected
+	case membershipNotMember:
+		return repository.ErrNotRoomMember
+	default:
+		return nil
+	}
+}
+
+// roomKey and roomUsersKey tag the room ID, so on a cluster a room and its member set share
+// a slot and the membership scripts can take both while rooms still spread across the cluster
+func (r *roomRepository) roomKey(roomID string) string {
+	return "room:" + r.cache.HashTag(roomID)
+}
+
+func (r *roomRepository) roomUsersKey(roomID string) string {
+	return r.roomKey(roomID) + ":users"
+}
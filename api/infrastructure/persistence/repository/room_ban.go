@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type roomBanRepository struct {
+	client redis.UniversalClient
+}
+
+func NewRoomBanRepository(client redis.UniversalClient) repository.RoomBanRepository {
+	return &roomBanRepository{
+		client: client,
+	}
+}
+
+// Create stores the ban by user ID and, when known, by member token hash.
+// Keys expire with the room since a ban means nothing once it is gone.
+func (r *roomBanRepository) Create(ctx context.Context, ban *model.RoomBan, expiresAt time.Time) error {
+	ban.BannedAt = time.Now()
+
+	data, err := json.Marshal(ban)
+	if err != nil {
+		return err
+	}
+
+	bansKey := roomBansKey(ban.RoomID)
+	tokensKey := roomBannedTokensKey(ban.RoomID)
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, bansKey, ban.UserID, data)
+	if ban.TokenHash != "" {
+		pipe.SAdd(ctx, tokensKey, ban.TokenHash)
+	}
+	if !expiresAt.IsZero() {
+		pipe.ExpireAt(ctx, bansKey, expiresAt)
+		pipe.ExpireAt(ctx, tokensKey, expiresAt)
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *roomBanRepository) Delete(ctx context.Context, roomID, userID string) (bool, error) {
+	bansKey := roomBansKey(roomID)
+
+	data, err := r.client.HGet(ctx, bansKey, userID).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var ban model.RoomBan
+	if err := json.Unmarshal(data, &ban); err != nil {
+		return false, err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HDel(ctx, bansKey, userID)
+	if ban.TokenHash != "" {
+		pipe.SRem(ctx, roomBannedTokensKey(roomID), ban.TokenHash)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *roomBanRepository) GetAll(ctx context.Context, roomID string) ([]*model.RoomBan, error) {
+	entries, err := r.client.HGetAll(ctx, roomBansKey(roomID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room bans: %w", err)
+	}
+
+	bans := make([]*model.RoomBan, 0, len(entries))
+	for _, data := range entries {
+		var ban model.RoomBan
+		if err := json.Unmarshal([]byte(data), &ban); err != nil {
+			continue
+		}
+		bans = append(bans, &ban)
+	}
+
+	return bans, nil
+}
+
+func (r *roomBanRepository) IsBanned(ctx context.Context, roomID, userID, tokenHash string) (bool, error) {
+	pipe := r.client.Pipeline()
+	byUser := pipe.HExists(ctx, roomBansKey(roomID), userID)
+	var byToken *redis.BoolCmd
+	if tokenHash != "" {
+		byToken = pipe.SIsMember(ctx, roomBannedTokensKey(roomID), tokenHash)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, err
+	}
+
+	if byUser.Val() {
+		return true, nil
+	}
+
+	return byToken != nil && byToken.Val(), nil
+}
+
+func (r *roomBanRepository) SetMemberToken(ctx context.Context, roomID, userID, tokenHash string, expiresAt time.Time) error {
+	key := roomMemberTokensKey(roomID)
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, userID, tokenHash)
+	if !expiresAt.IsZero() {
+		pipe.ExpireAt(ctx, key, expiresAt)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *roomBanRepository) GetMemberToken(ctx context.Context, roomID, userID string) (string, error) {
+	token, err := r.client.HGet(ctx, roomMemberTokensKey(roomID), userID).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return token, err
+}
+
+// ExtendExpiry is a no-op for keys that don't exist yet, they pick up the expiry when written
+func (r *roomBanRepository) ExtendExpiry(ctx context.Context, roomID string, expiresAt time.Time) error {
+	pipe := r.client.TxPipeline()
+	pipe.ExpireAt(ctx, roomBansKey(roomID), expiresAt)
+	pipe.ExpireAt(ctx, roomBannedTokensKey(roomID), expiresAt)
+	pipe.ExpireAt(ctx, roomMemberTokensKey(roomID), expiresAt)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func roomBansKey(roomID string) string {
+	return fmt.Sprintf("room:%s:bans", roomID)
+}
+
+func roomBannedTokensKey(roomID string) string {
+	return fmt.Sprintf("room:%s:banned_tokens", roomID)
+}
+
+func roomMemberTokensKey(roomID string) string {
+	return fmt.Sprintf("room:%s:member_tokens", roomID)
+}
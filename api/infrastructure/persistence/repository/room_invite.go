@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type roomInviteRepository struct {
+	client redis.UniversalClient
+}
+
+func NewRoomInviteRepository(client redis.UniversalClient) repository.RoomInviteRepository {
+	return &roomInviteRepository{
+		client: client,
+	}
+}
+
+// Create stores the invite and its use counter separately so redeeming
+// never has to rewrite the JSON. Both keys expire with the invite.
+func (r *roomInviteRepository) Create(ctx context.Context, invite *model.RoomInvite) error {
+	invite.CreatedAt = time.Now()
+
+	data, err := json.Marshal(invite)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if !invite.ExpiresAt.IsZero() {
+		ttl = time.Until(invite.ExpiresAt)
+	}
+
+	indexKey := roomInvitesKey(invite.RoomID)
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, inviteKey(invite.Token), data, ttl)
+	pipe.Set(ctx, inviteUsesKey(invite.Token), invite.Uses, ttl)
+	pipe.SAdd(ctx, indexKey, invite.Token)
+	if invite.ExpiresAt.IsZero() {
+		pipe.Persist(ctx, indexKey)
+	} else {
+		// The index only needs to outlive its longest invite, -1 means one already never expires
+		if current, err := r.client.TTL(ctx, indexKey).Result(); err != nil || (current != -1 && current < ttl) {
+			pipe.Expire(ctx, indexKey, ttl)
+		}
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *roomInviteRepository) Get(ctx context.Context, token string) (*model.RoomInvite, error) {
+	pipe := r.client.Pipeline()
+	dataCmd := pipe.Get(ctx, inviteKey(token))
+	usesCmd := pipe.Get(ctx, inviteUsesKey(token))
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	data, err := dataCmd.Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var invite model.RoomInvite
+	if err := json.Unmarshal(data, &invite); err != nil {
+		return nil, err
+	}
+
+	if uses, err := usesCmd.Int(); err == nil {
+		invite.Uses = uses
+	}
+
+	return &invite, nil
+}
+
+func (r *roomInviteRepository) GetAll(ctx context.Context, roomID string) ([]*model.RoomInvite, error) {
+	tokens, err := r.client.SMembers(ctx, roomInvitesKey(roomID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room invites: %w", err)
+	}
+
+	invites := make([]*model.RoomInvite, 0, len(tokens))
+	for _, token := range tokens {
+		invite, err := r.Get(ctx, token)
+		if err != nil {
+			continue
+		}
+		if invite == nil {
+			// Expired on its own, drop it from the index
+			r.client.SRem(ctx, roomInvitesKey(roomID), token)
+			continue
+		}
+		invites = append(invites, invite)
+	}
+
+	return invites, nil
+}
+
+func (r *roomInviteRepository) Delete(ctx context.Context, roomID, token string) (bool, error) {
+	removed, err := r.client.SRem(ctx, roomInvitesKey(roomID), token).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.client.Del(ctx, inviteKey(token), inviteUsesKey(token)).Err(); err != nil {
+		return false, err
+	}
+
+	return removed > 0, nil
+}
+
+func (r *roomInviteRepository) Redeem(ctx context.Context, invite *model.RoomInvite) (bool, error) {
+	uses, err := r.client.Incr(ctx, inviteUsesKey(invite.Token)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if invite.MaxUses > 0 && uses > int64(invite.MaxUses) {
+		if err := r.client.Decr(ctx, inviteUsesKey(invite.Token)).Err(); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	invite.Uses = int(uses)
+	return true, nil
+}
+
+func (r *roomInviteRepository) Release(ctx context.Context, token string) error {
+	return r.client.Decr(ctx, inviteUsesKey(token)).Err()
+}
+
+func inviteKey(token string) string {
+	return fmt.Sprintf("invite:%s", token)
+}
+
+func inviteUsesKey(token string) string {
+	return fmt.Sprintf("invite:%s:uses", token)
+}
+
+func roomInvitesKey(roomID string) string {
+	return fmt.Sprintf("room:%s:invites", roomID)
+}
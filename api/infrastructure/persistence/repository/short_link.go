@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const shortLinkKeyPrefix = "shortlink:"
+
+type shortLinkRepository struct {
+	client redis.UniversalClient
+}
+
+func NewShortLinkRepository(client redis.UniversalClient) repository.ShortLinkRepository {
+	return &shortLinkRepository{
+		client: client,
+	}
+}
+
+func (r *shortLinkRepository) Create(ctx context.Context, link *model.ShortLink, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return false, err
+	}
+
+	key := shortLinkKeyPrefix + link.Code
+	return r.client.SetNX(ctx, key, data, ttl).Result()
+}
+
+func (r *shortLinkRepository) GetByCode(ctx context.Context, code string) (*model.ShortLink, error) {
+	key := shortLinkKeyPrefix + code
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var link model.ShortLink
+	if err := json.Unmarshal(data, &link); err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
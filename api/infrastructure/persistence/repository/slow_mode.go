@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type slowModeRepository struct {
+	client redis.UniversalClient
+}
+
+func NewSlowModeRepository(client redis.UniversalClient) repository.SlowModeRepository {
+	return &slowModeRepository{
+		client: client,
+	}
+}
+
+func (r *slowModeRepository) Acquire(ctx context.Context, roomID, userID string, interval time.Duration) (time.Duration, error) {
+	key := fmt.Sprintf("room:%s:slowmode:%s", roomID, userID)
+
+	acquired, err := r.client.SetNX(ctx, key, 1, interval).Result()
+	if err != nil {
+		return 0, err
+	}
+	if acquired {
+		return 0, nil
+	}
+
+	remaining, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// The key expired between the two calls, let the message through
+	if remaining <= 0 {
+		return 0, nil
+	}
+
+	return remaining, nil
+}
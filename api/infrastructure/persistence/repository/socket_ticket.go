@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type socketTicketRepository struct {
+	client redis.UniversalClient
+}
+
+func NewSocketTicketRepository(client redis.UniversalClient) repository.SocketTicketRepository {
+	return &socketTicketRepository{
+		client: client,
+	}
+}
+
+func (r *socketTicketRepository) Create(ctx context.Context, ticket *model.SocketTicket) error {
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, socketTicketKey(ticket.Token), data, time.Until(ticket.ExpiresAt)).Err()
+}
+
+// Consume uses GETDEL so two sockets racing on the same ticket can't both get it
+func (r *socketTicketRepository) Consume(ctx context.Context, token string) (*model.SocketTicket, error) {
+	data, err := r.client.GetDel(ctx, socketTicketKey(token)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ticket model.SocketTicket
+	if err := json.Unmarshal(data, &ticket); err != nil {
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+func socketTicketKey(token string) string {
+	return fmt.Sprintf("ws:ticket:%s", token)
+}
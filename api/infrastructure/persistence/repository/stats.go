@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	statsMessagesKeyPrefix    = "stats:messages:"
+	statsUploadBytesKeyPrefix = "stats:upload_bytes:"
+
+	// Counters are bucketed per minute and kept a little longer than the largest window we report
+	statsBucketSize = time.Minute
+	statsBucketTTL  = 2 * time.Hour
+
+	// A room's counters live in one hash, refreshed on every write so they outlast any room
+	// that is still in use and go away on their own once it's gone
+	statsRoomKeyPrefix = "stats:room:"
+	statsRoomTTL       = 30 * 24 * time.Hour
+
+	statsRoomMessages     = "messages"
+	statsRoomMessageBytes = "message_bytes"
+	statsRoomPeak         = "peak_concurrency"
+)
+
+// recordPeakScript only ever raises the peak, so instances reporting concurrently can't lower it
+var recordPeakScript = redis.NewScript(`
+local current = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+if tonumber(ARGV[2]) > current then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 0
+`)
+
+type statsRepository struct {
+	client redis.UniversalClient
+}
+
+func NewStatsRepository(client redis.UniversalClient) repository.StatsRepository {
+	return &statsRepository{
+		client: client,
+	}
+}
+
+func (r *statsRepository) IncrementMessages(ctx context.Context) error {
+	return r.incrBy(ctx, statsMessagesKeyPrefix, 1)
+}
+
+func (r *statsRepository) AddUploadBytes(ctx context.Context, bytes int64) error {
+	return r.incrBy(ctx, statsUploadBytesKeyPrefix, bytes)
+}
+
+func (r *statsRepository) GetMessageCount(ctx context.Context, window time.Duration) (int64, error) {
+	return r.sumWindow(ctx, statsMessagesKeyPrefix, window)
+}
+
+func (r *statsRepository) GetUploadBytes(ctx context.Context, window time.Duration) (int64, error) {
+	return r.sumWindow(ctx, statsUploadBytesKeyPrefix, window)
+}
+
+func (r *statsRepository) RecordRoomMessage(ctx context.Context, roomID string, size int) error {
+	key := statsRoomKeyPrefix + roomID
+
+	pipe := r.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, statsRoomMessages, 1)
+	pipe.HIncrBy(ctx, key, statsRoomMessageBytes, int64(size))
+	pipe.Expire(ctx, key, statsRoomTTL)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *statsRepository) RecordRoomConcurrency(ctx context.Context, roomID string, connected int) error {
+	key := statsRoomKeyPrefix + roomID
+	return recordPeakScript.Run(ctx, r.client, []string{key}, statsRoomPeak, connected, statsRoomTTL.Milliseconds()).Err()
+}
+
+func (r *statsRepository) GetRoomStats(ctx context.Context, roomID string) (*model.RoomStats, error) {
+	values, err := r.client.HGetAll(ctx, statsRoomKeyPrefix+roomID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read room stats: %w", err)
+	}
+
+	// A room nobody has written in yet has no hash, which reads as all zeroes
+	stats := &model.RoomStats{RoomID: roomID, GeneratedAt: time.Now()}
+	stats.Messages, _ = strconv.ParseInt(values[statsRoomMessages], 10, 64)
+	stats.MessageBytes, _ = strconv.ParseInt(values[statsRoomMessageBytes], 10, 64)
+	stats.PeakConcurrency, _ = strconv.ParseInt(values[statsRoomPeak], 10, 64)
+	return stats, nil
+}
+
+func (r *statsRepository) incrBy(ctx context.Context, prefix string, value int64) error {
+	key := statsBucketKey(cache.HashTag(r.client, prefix), time.Now())
+
+	pipe := r.client.TxPipeline()
+	pipe.IncrBy(ctx, key, value)
+	pipe.Expire(ctx, key, statsBucketTTL)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// sumWindow adds up the complete buckets in the window, the current partial minute is left out
+func (r *statsRepository) sumWindow(ctx context.Context, prefix string, window time.Duration) (int64, error) {
+	buckets := int(window / statsBucketSize)
+	if buckets < 1 {
+		buckets = 1
+	}
+
+	// MGet needs every bucket in one slot on a cluster
+	prefix = cache.HashTag(r.client, prefix)
+	now := time.Now()
+	keys := make([]string, 0, buckets)
+	for i := 1; i <= buckets; i++ {
+		keys = append(keys, statsBucketKey(prefix, now.Add(-time.Duration(i)*statsBucketSize)))
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read stats counters: %w", err)
+	}
+
+	var total int64
+	for _, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			continue // Bucket has no activity
+		}
+
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			continue
+		}
+		total += n
+	}
+
+	return total, nil
+}
+
+func statsBucketKey(prefix string, t time.Time) string {
+	return prefix + strconv.FormatInt(t.Truncate(statsBucketSize).Unix(), 10)
+}
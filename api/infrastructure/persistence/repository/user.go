@@ -155,3 +155,40 @@ func (r *userRepository) SetUsernameIndex(ctx context.Context, username string,
 	span.SetStatus(codes.Ok, "username index set successfully")
 	return nil
 }
+
+// DeleteUsernameIndex only drops the index while it still points at userID, so a name
+// that was already claimed by someone else is left alone
+func (r *userRepository) DeleteUsernameIndex(ctx context.Context, username, userID string) (bool, error) {
+	ctx, span := r.tracer.Start(ctx, "userRepository.DeleteUsernameIndex")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.username", username),
+		attribute.String("user.id", userID),
+	)
+
+	key := fmt.Sprintf("user:username:%s", username)
+	var indexedID string
+
+	found, err := r.cache.Get(key, &indexedID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to get username index from cache")
+		return false, err
+	}
+
+	if !found || indexedID != userID {
+		span.SetAttributes(attribute.Bool("username.index.owned", false))
+		span.SetStatus(codes.Ok, "username index not owned by user")
+		return false, nil
+	}
+
+	if err := r.cache.Delete(key); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to delete username index")
+		return false, err
+	}
+
+	span.SetStatus(codes.Ok, "username index deleted successfully")
+	return true, nil
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// Owners only need recent deliveries to debug an endpoint
+const maxWebhookDeliveries = 50
+
+type webhookRepository struct {
+	client redis.UniversalClient
+}
+
+func NewWebhookRepository(client redis.UniversalClient) repository.WebhookRepository {
+	return &webhookRepository{
+		client: client,
+	}
+}
+
+func (r *webhookRepository) Create(ctx context.Context, webhook *model.RoomWebhook, expiresAt time.Time) error {
+	webhook.CreatedAt = time.Now()
+
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+
+	key := roomWebhooksKey(webhook.RoomID)
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, webhook.ID, data)
+	if !expiresAt.IsZero() {
+		pipe.ExpireAt(ctx, key, expiresAt)
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *webhookRepository) Get(ctx context.Context, roomID, webhookID string) (*model.RoomWebhook, error) {
+	data, err := r.client.HGet(ctx, roomWebhooksKey(roomID), webhookID).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var webhook model.RoomWebhook
+	if err := json.Unmarshal(data, &webhook); err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (r *webhookRepository) GetAll(ctx context.Context, roomID string) ([]*model.RoomWebhook, error) {
+	entries, err := r.client.HGetAll(ctx, roomWebhooksKey(roomID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room webhooks: %w", err)
+	}
+
+	webhooks := make([]*model.RoomWebhook, 0, len(entries))
+	for _, data := range entries {
+		var webhook model.RoomWebhook
+		if err := json.Unmarshal([]byte(data), &webhook); err != nil {
+			continue
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, nil
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, roomID, webhookID string) (bool, error) {
+	removed, err := r.client.HDel(ctx, roomWebhooksKey(roomID), webhookID).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.client.Del(ctx, webhookDeliveriesKey(roomID, webhookID)).Err(); err != nil {
+		return false, err
+	}
+
+	return removed > 0, nil
+}
+
+func (r *webhookRepository) Count(ctx context.Context, roomID string) (int64, error) {
+	return r.client.HLen(ctx, roomWebhooksKey(roomID)).Result()
+}
+
+func (r *webhookRepository) AppendDelivery(ctx context.Context, delivery *model.WebhookDelivery, expiresAt time.Time) error {
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	key := webhookDeliveriesKey(delivery.RoomID, delivery.WebhookID)
+
+	pipe := r.client.TxPipeline()
+	pipe.LPush(ctx, key, data)
+	pipe.LTrim(ctx, key, 0, maxWebhookDeliveries-1)
+	if !expiresAt.IsZero() {
+		pipe.ExpireAt(ctx, key, expiresAt)
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetDeliveries returns the newest deliveries first
+func (r *webhookRepository) GetDeliveries(ctx context.Context, roomID, webhookID string, limit int64) ([]*model.WebhookDelivery, error) {
+	if limit <= 0 || limit > maxWebhookDeliveries {
+		limit = maxWebhookDeliveries
+	}
+
+	entries, err := r.client.LRange(ctx, webhookDeliveriesKey(roomID, webhookID), 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+
+	deliveries := make([]*model.WebhookDelivery, 0, len(entries))
+	for _, data := range entries {
+		var delivery model.WebhookDelivery
+		if err := json.Unmarshal([]byte(data), &delivery); err != nil {
+			continue
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, nil
+}
+
+// SetIncomingToken keeps a reverse entry on the room so the old token can be dropped on rotation
+func (r *webhookRepository) SetIncomingToken(ctx context.Context, roomID, tokenHash string, expiresAt time.Time) error {
+	previous, err := r.client.Get(ctx, roomIncomingWebhookKey(roomID)).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if !expiresAt.IsZero() {
+		ttl = time.Until(expiresAt)
+	}
+
+	pipe := r.client.TxPipeline()
+	if previous != "" {
+		pipe.Del(ctx, incomingWebhookKey(previous))
+	}
+	pipe.Set(ctx, incomingWebhookKey(tokenHash), roomID, ttl)
+	pipe.Set(ctx, roomIncomingWebhookKey(roomID), tokenHash, ttl)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *webhookRepository) GetRoomByIncomingToken(ctx context.Context, tokenHash string) (string, error) {
+	roomID, err := r.client.Get(ctx, incomingWebhookKey(tokenHash)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return roomID, err
+}
+
+func (r *webhookRepository) DeleteIncomingToken(ctx context.Context, roomID string) (bool, error) {
+	tokenHash, err := r.client.GetDel(ctx, roomIncomingWebhookKey(roomID)).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.client.Del(ctx, incomingWebhookKey(tokenHash)).Err(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func roomWebhooksKey(roomID string) string {
+	return fmt.Sprintf("room:%s:webhooks", roomID)
+}
+
+func webhookDeliveriesKey(roomID, webhookID string) string {
+	return fmt.Sprintf("room:%s:webhook:%s:deliveries", roomID, webhookID)
+}
+
+func roomIncomingWebhookKey(roomID string) string {
+	return fmt.Sprintf("room:%s:webhook:incoming", roomID)
+}
+
+func incomingWebhookKey(tokenHash string) string {
+	return fmt.Sprintf("webhook:incoming:%s", tokenHash)
+}
@@ -0,0 +1,155 @@
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// captureIDLayout names a capture's directory, so the names sort oldest first
+const captureIDLayout = "20060102-150405"
+
+const captureMetaFile = "capture.json"
+
+var ErrCaptureNotFound = errors.New("profile capture not found")
+
+// Capture is one set of profiles and the trigger that caused it
+type Capture struct {
+	ID        string
+	Reason    string
+	CreatedAt time.Time
+	Profiles  []Profile
+}
+
+type Profile struct {
+	Name string
+	Size int64
+}
+
+type captureMeta struct {
+	ID        string    `json:"id"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func writeCaptureMeta(dir string, meta captureMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, captureMetaFile), data, 0644); err != nil {
+		return fmt.Errorf("error writing capture metadata: %w", err)
+	}
+	return nil
+}
+
+// Captures lists the retained captures newest first, including ones from before a restart
+func (p *AdaptiveProfiler) Captures() ([]Capture, error) {
+	ids, err := p.captureIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	captures := make([]Capture, 0, len(ids))
+	for _, id := range slices.Backward(ids) {
+		capture, err := p.readCapture(id)
+		if err != nil {
+			// Still being written, or pruned since the directory was read
+			continue
+		}
+		captures = append(captures, capture)
+	}
+	return captures, nil
+}
+
+// ProfilePath is where a capture's profile is on disk, only names from the listing resolve
+func (p *AdaptiveProfiler) ProfilePath(id, name string) (string, error) {
+	if id != filepath.Base(id) || name != filepath.Base(name) || !strings.HasSuffix(name, ".pprof") {
+		return "", ErrCaptureNotFound
+	}
+
+	path := filepath.Join(p.opts.Dir, id, name)
+	if _, err := os.Stat(filepath.Join(p.opts.Dir, id, captureMetaFile)); err != nil {
+		return "", ErrCaptureNotFound
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", ErrCaptureNotFound
+	}
+	return path, nil
+}
+
+func (p *AdaptiveProfiler) readCapture(id string) (Capture, error) {
+	dir := filepath.Join(p.opts.Dir, id)
+	data, err := os.ReadFile(filepath.Join(dir, captureMetaFile))
+	if err != nil {
+		return Capture{}, err
+	}
+	var meta captureMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Capture{}, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Capture{}, err
+	}
+
+	capture := Capture{ID: id, Reason: meta.Reason, CreatedAt: meta.CreatedAt}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pprof") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		capture.Profiles = append(capture.Profiles, Profile{Name: entry.Name(), Size: info.Size()})
+	}
+	return capture, nil
+}
+
+// captureIDs are the capture directories oldest first, finished or not
+func (p *AdaptiveProfiler) captureIDs() ([]string, error) {
+	entries, err := os.ReadDir(p.opts.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if _, err := time.Parse(captureIDLayout, entry.Name()); entry.IsDir() && err == nil {
+			ids = append(ids, entry.Name())
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// prune keeps the newest Retain captures, one cut short by a crash goes like any other
+func (p *AdaptiveProfiler) prune() {
+	if p.opts.Retain <= 0 {
+		return
+	}
+	ids, err := p.captureIDs()
+	if err != nil {
+		p.logger.Error("Failed to list profile captures", zap.Error(err))
+		return
+	}
+
+	for len(ids) > p.opts.Retain {
+		if err := os.RemoveAll(filepath.Join(p.opts.Dir, ids[0])); err != nil {
+			p.logger.Error("Failed to remove profile capture", zap.String("capture", ids[0]), zap.Error(err))
+		}
+		ids = ids[1:]
+	}
+}
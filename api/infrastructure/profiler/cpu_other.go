@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package profiler
+
+import "time"
+
+// processCPUTime can't be read here, the CPU trigger never fires
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
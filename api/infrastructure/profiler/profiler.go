@@ -2,178 +2,231 @@ package profiler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
-	"sync"
+	"strings"
 	"time"
+
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
+	"go.uber.org/zap"
 )
 
+// minLatencySamples keeps a handful of slow requests on a quiet server from counting as a p99
+const minLatencySamples = 20
+
+// Triggers are the thresholds that start a capture, a zero one is off
+type Triggers struct {
+	CPUPercent float64       // Process CPU time over the last check, as a share of GOMAXPROCS
+	HeapMB     float64       // Heap in use
+	P99Latency time.Duration // Of LatencyHistogram over the last check
+}
+
+type Options struct {
+	Dir              string
+	CheckInterval    time.Duration
+	MinInterval      time.Duration
+	CPUDuration      time.Duration
+	Retain           int
+	Triggers         Triggers
+	LatencyHistogram string
+}
+
+// AdaptiveProfiler captures a CPU, heap and goroutine profile when a trigger fires, so there
+// is something to look at after a spike nobody was watching
 type AdaptiveProfiler struct {
-	// Configuration
-	profileDir      string
-	cpuThreshold    float64 // CPU threshold to trigger profiling (0-1)
-	memThreshold    float64 // Memory threshold (0-1)
-	minInterval     time.Duration
-	profileDuration time.Duration
-
-	// State
-	lastProfile time.Time
-	mutex       sync.Mutex
-	isRunning   bool
-
-	// CPU tracking
-	lastCPUTime  time.Time
-	lastCPUUsage float64
+	opts    Options
+	metrics metrics.Manager
+	logger  *logger.Logger
+
+	// Only touched by Start's loop
+	lastCapture time.Time
+	lastCPU     time.Duration
+	lastCPUAt   time.Time
+	lastLatency metrics.HistogramSnapshot
 }
 
-func NewAdaptiveProfiler(profileDir string) *AdaptiveProfiler {
+func NewAdaptiveProfiler(opts Options, metrics metrics.Manager, logger *logger.Logger) *AdaptiveProfiler {
 	return &AdaptiveProfiler{
-		profileDir:      profileDir,
-		cpuThreshold:    0.70, // Start profiling at 70% CPU
-		memThreshold:    0.80, // Start profiling at 80% memory
-		minInterval:     10 * time.Minute,
-		profileDuration: 30 * time.Second,
-		lastProfile:     time.Time{},
-		lastCPUTime:     time.Now(),
+		opts:    opts,
+		metrics: metrics,
+		logger:  logger,
 	}
 }
 
+// Start checks the triggers until ctx is done, a capture blocks the checks while it runs
 func (p *AdaptiveProfiler) Start(ctx context.Context) {
-	go p.monitor(ctx)
-}
+	t := p.opts.Triggers
+	if t.CPUPercent <= 0 && t.HeapMB <= 0 && t.P99Latency <= 0 {
+		p.logger.Info("Adaptive profiler has no triggers, not starting")
+		return
+	}
 
-func (p *AdaptiveProfiler) monitor(ctx context.Context) {
-	ticker := time.NewTicker(15 * time.Second)
+	ticker := time.NewTicker(p.opts.CheckInterval)
 	defer ticker.Stop()
 
+	p.logger.Info("Adaptive profiler started",
+		zap.String("dir", p.opts.Dir),
+		zap.Float64("cpuPercent", t.CPUPercent),
+		zap.Float64("heapMB", t.HeapMB),
+		zap.Duration("p99Latency", t.P99Latency),
+	)
+
+	// The first check measures from here rather than from process start
+	p.sampleCPU()
+	p.sampleLatency()
+
 	for {
 		select {
 		case <-ticker.C:
-			p.checkAndProfile()
+			reason, fired := p.check()
+			if !fired || time.Since(p.lastCapture) < p.opts.MinInterval {
+				continue
+			}
+
+			p.logger.Warn("Profiler trigger fired, capturing profiles", zap.String("reason", reason))
+			p.lastCapture = time.Now()
+			if err := p.capture(ctx, reason); err != nil {
+				p.logger.Error("Failed to capture profiles", zap.Error(err))
+			}
+			p.prune()
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (p *AdaptiveProfiler) checkAndProfile() {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
+// check samples every trigger on each tick so each one covers a single interval, and
+// reports the ones over their threshold
+func (p *AdaptiveProfiler) check() (string, bool) {
+	t := p.opts.Triggers
+	var reasons []string
 
-	if p.isRunning {
-		return
+	if cpu, ok := p.sampleCPU(); ok && t.CPUPercent > 0 && cpu > t.CPUPercent {
+		reasons = append(reasons, fmt.Sprintf("cpu %.1f%% over %g%%", cpu, t.CPUPercent))
 	}
 
-	// Check if we're within minimum interval
-	if time.Since(p.lastProfile) < p.minInterval {
-		return
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if heap := float64(stats.HeapAlloc) / (1 << 20); t.HeapMB > 0 && heap > t.HeapMB {
+		reasons = append(reasons, fmt.Sprintf("heap %.0fMB over %gMB", heap, t.HeapMB))
 	}
 
-	// Check memory usage
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-	memUsage := float64(m.Alloc) / float64(m.Sys)
-
-	// Check CPU usage
-	cpuUsage := p.getCPUUsage()
-
-	// If thresholds are exceeded, profile
-	if cpuUsage > p.cpuThreshold || memUsage > p.memThreshold {
-		fmt.Printf("Thresholds exceeded - CPU: %.2f%%, Mem: %.2f%% - Starting profiling\n",
-			cpuUsage*100, memUsage*100)
-		p.isRunning = true
-		go p.captureProfiles()
+	if p99, ok := p.sampleLatency(); ok && t.P99Latency > 0 && p99 > t.P99Latency {
+		reasons = append(reasons, fmt.Sprintf("p99 latency %s over %s", p99.Round(time.Millisecond), t.P99Latency))
 	}
+
+	return strings.Join(reasons, ", "), len(reasons) > 0
 }
 
-func (p *AdaptiveProfiler) getCPUUsage() float64 {
-	// Sample goroutine count as a proxy for CPU activity
-	// This is a simplified approach that works across platforms
-	numGoroutines := float64(runtime.NumGoroutine())
-	numCPU := float64(runtime.NumCPU())
+// sampleCPU is the process' CPU use since the previous sample, 100% is every GOMAXPROCS busy
+func (p *AdaptiveProfiler) sampleCPU() (float64, bool) {
+	used, ok := processCPUTime()
+	if !ok {
+		return 0, false
+	}
+	now := time.Now()
 
-	// Calculate a normalized usage based on goroutines per CPU
-	// This isn't perfect but gives us a relative measure
-	usage := numGoroutines / (numCPU * 10) // Assume 10 goroutines per CPU is "normal"
+	prevUsed, prevAt := p.lastCPU, p.lastCPUAt
+	p.lastCPU, p.lastCPUAt = used, now
+	if prevAt.IsZero() {
+		return 0, false
+	}
 
-	// Cap at 1.0
-	if usage > 1.0 {
-		usage = 1.0
+	wall := now.Sub(prevAt) * time.Duration(runtime.GOMAXPROCS(0))
+	if wall <= 0 {
+		return 0, false
 	}
+	return float64(used-prevUsed) / float64(wall) * 100, true
+}
 
-	// Smooth the value with exponential moving average
-	now := time.Now()
-	timeDelta := now.Sub(p.lastCPUTime).Seconds()
-	if timeDelta > 0 {
-		alpha := 0.3 // Smoothing factor
-		p.lastCPUUsage = alpha*usage + (1-alpha)*p.lastCPUUsage
-		p.lastCPUTime = now
+// sampleLatency is the p99 of the requests since the previous sample
+func (p *AdaptiveProfiler) sampleLatency() (time.Duration, bool) {
+	if p.opts.LatencyHistogram == "" {
+		return 0, false
+	}
+	snapshot, ok := p.metrics.HistogramSnapshot(p.opts.LatencyHistogram)
+	if !ok {
+		return 0, false
 	}
 
-	return p.lastCPUUsage
+	delta := snapshot.Since(p.lastLatency)
+	p.lastLatency = snapshot
+	if delta.Total() < minLatencySamples {
+		return 0, false
+	}
+	return time.Duration(delta.Quantile(0.99) * float64(time.Second)), true
 }
 
-func (p *AdaptiveProfiler) captureProfiles() {
-	timestamp := time.Now().Format("20060102-150405")
+// capture writes each profile into its own directory, the metadata goes last so a capture
+// cut short never shows up in the listing
+func (p *AdaptiveProfiler) capture(ctx context.Context, reason string) error {
+	id := time.Now().UTC().Format(captureIDLayout)
+	dir := filepath.Join(p.opts.Dir, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating capture directory: %w", err)
+	}
 
-	// Ensure profile directory exists
-	if err := os.MkdirAll(p.profileDir, 0755); err != nil {
-		fmt.Printf("Error creating profile directory: %v\n", err)
-		p.mutex.Lock()
-		p.isRunning = false
-		p.mutex.Unlock()
-		return
+	var errs []error
+	if err := p.writeCPUProfile(ctx, filepath.Join(dir, "cpu-"+id+".pprof")); err != nil {
+		errs = append(errs, err)
+	}
+	runtime.GC()
+	if err := writeProfile("heap", filepath.Join(dir, "heap-"+id+".pprof")); err != nil {
+		errs = append(errs, err)
+	}
+	if err := writeProfile("goroutine", filepath.Join(dir, "goroutine-"+id+".pprof")); err != nil {
+		errs = append(errs, err)
+	}
+	if err := writeCaptureMeta(dir, captureMeta{ID: id, Reason: reason, CreatedAt: time.Now().UTC()}); err != nil {
+		errs = append(errs, err)
 	}
 
-	// Capture CPU profile
-	cpuFile, err := os.Create(fmt.Sprintf("%s/cpu-%s.pprof", p.profileDir, timestamp))
-	if err != nil {
-		fmt.Printf("Error creating CPU profile: %v\n", err)
-	} else {
-		runtime.GC() // Run GC before profiling
-		if err := pprof.StartCPUProfile(cpuFile); err != nil {
-			fmt.Printf("Error starting CPU profile: %v\n", err)
-		} else {
-			time.Sleep(p.profileDuration) // Profile for N seconds
-			pprof.StopCPUProfile()
-		}
-		cpuFile.Close()
-		fmt.Printf("CPU profile saved: cpu-%s.pprof\n", timestamp)
+	if err := errors.Join(errs...); err != nil {
+		return err
 	}
+	p.logger.Info("Profiles captured", zap.String("capture", id), zap.String("dir", dir))
+	return nil
+}
 
-	// Capture memory profile
-	memFile, err := os.Create(fmt.Sprintf("%s/mem-%s.pprof", p.profileDir, timestamp))
+// writeCPUProfile stops early on shutdown, what was sampled until then is still kept
+func (p *AdaptiveProfiler) writeCPUProfile(ctx context.Context, path string) error {
+	f, err := os.Create(path)
 	if err != nil {
-		fmt.Printf("Error creating memory profile: %v\n", err)
-	} else {
-		runtime.GC() // Run GC before profiling
-		if err := pprof.WriteHeapProfile(memFile); err != nil {
-			fmt.Printf("Error writing memory profile: %v\n", err)
-		}
-		memFile.Close()
-		fmt.Printf("Memory profile saved: mem-%s.pprof\n", timestamp)
+		return fmt.Errorf("error creating cpu profile: %w", err)
+	}
+	defer f.Close()
+
+	// Fails when /debug/pprof/profile is already recording one
+	if err := pprof.StartCPUProfile(f); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("error starting cpu profile: %w", err)
+	}
+
+	timer := time.NewTimer(p.opts.CPUDuration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
 	}
 
-	// Capture goroutine profile
-	goroutineFile, err := os.Create(fmt.Sprintf("%s/goroutine-%s.pprof", p.profileDir, timestamp))
+	pprof.StopCPUProfile()
+	return nil
+}
+
+func writeProfile(name, path string) error {
+	f, err := os.Create(path)
 	if err != nil {
-		fmt.Printf("Error creating goroutine profile: %v\n", err)
-	} else {
-		profile := pprof.Lookup("goroutine")
-		if profile != nil {
-			if err := profile.WriteTo(goroutineFile, 0); err != nil {
-				fmt.Printf("Error writing goroutine profile: %v\n", err)
-			}
-		}
-		goroutineFile.Close()
-		fmt.Printf("Goroutine profile saved: goroutine-%s.pprof\n", timestamp)
+		return fmt.Errorf("error creating %s profile: %w", name, err)
 	}
+	defer f.Close()
 
-	// Mark profiling complete
-	p.mutex.Lock()
-	p.lastProfile = time.Now()
-	p.isRunning = false
-	p.mutex.Unlock()
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		return fmt.Errorf("error writing %s profile: %w", name, err)
+	}
+	return nil
 }
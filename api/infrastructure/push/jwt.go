@@ -0,0 +1,23 @@
+package push
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+func jwtSigningInput(alg string, claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"typ": "JWT",
+		"alg": alg,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload), nil
+}
@@ -0,0 +1,65 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+)
+
+const (
+	PlatformWebPush = "webpush"
+	PlatformFCM     = "fcm"
+
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	// ErrSubscriptionGone means the push service no longer knows the subscription and it should be dropped
+	ErrSubscriptionGone = errors.New("push subscription is no longer valid")
+	ErrNotConfigured    = errors.New("push platform is not configured")
+)
+
+type Notification struct {
+	Title  string            `json:"title"`
+	Body   string            `json:"body"`
+	RoomID string            `json:"roomId"`
+	Data   map[string]string `json:"data,omitempty"`
+}
+
+type Sender interface {
+	Send(ctx context.Context, subscription *model.PushSubscription, notification *Notification) error
+}
+
+// Dispatcher routes a notification to the sender for the subscription's platform
+type Dispatcher struct {
+	senders map[string]Sender
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		senders: make(map[string]Sender),
+	}
+}
+
+func (d *Dispatcher) Register(platform string, sender Sender) {
+	d.senders[platform] = sender
+}
+
+func (d *Dispatcher) Supports(platform string) bool {
+	_, ok := d.senders[platform]
+	return ok
+}
+
+func (d *Dispatcher) Send(ctx context.Context, subscription *model.PushSubscription, notification *Notification) error {
+	sender, ok := d.senders[subscription.Platform]
+	if !ok {
+		return ErrNotConfigured
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return sender.Send(ctx, subscription, notification)
+}
@@ -0,0 +1,277 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+	"golang.org/x/crypto/hkdf"
+)
+
+// webPushHosts are the push services browsers hand out endpoints of, a subscription can
+// only point at one of them or a subdomain
+var webPushHosts = []string{
+	"fcm.googleapis.com",
+	"android.googleapis.com",
+	"push.services.mozilla.com",
+	"notify.windows.com",
+	"push.apple.com",
+}
+
+var ErrUnknownPushService = errors.New("push endpoint is not a known push service")
+
+// ValidateWebPushEndpoint accepts https URLs of the known push services, the endpoint comes
+// from the client and is posted to by the server
+func ValidateWebPushEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Port() != "" {
+		return ErrUnknownPushService
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, known := range webPushHosts {
+		if host == known || strings.HasSuffix(host, "."+known) {
+			return nil
+		}
+	}
+	return ErrUnknownPushService
+}
+
+const (
+	webPushTTL        = 24 * time.Hour
+	webPushRecordSize = 4096
+	vapidTokenExpiry  = 12 * time.Hour
+)
+
+// WebPushSender delivers RFC 8030 push messages, encrypted per RFC 8291 and signed with VAPID (RFC 8292)
+type WebPushSender struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  string // base64url, uncompressed point
+	subject    string
+	client     *http.Client
+}
+
+// NewWebPushSender takes the VAPID key pair as raw base64url strings, the format most tooling generates
+func NewWebPushSender(publicKey, privateKey, subject string) (*WebPushSender, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	derived := base64.RawURLEncoding.EncodeToString(pub)
+	if publicKey != "" && publicKey != derived {
+		return nil, fmt.Errorf("VAPID public key does not match the private key")
+	}
+
+	return &WebPushSender{
+		privateKey: key,
+		publicKey:  derived,
+		subject:    subject,
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: security.NewOutboundTransport(requestTimeout),
+			// A push service answers, it never sends us elsewhere
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+func (s *WebPushSender) PublicKey() string {
+	return s.publicKey
+}
+
+func (s *WebPushSender) Send(ctx context.Context, subscription *model.PushSubscription, notification *Notification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	body, err := encryptPayload(payload, subscription.P256dh, subscription.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt push payload: %w", err)
+	}
+
+	// Subscriptions stored before the endpoints were checked are still around
+	if err := ValidateWebPushEndpoint(subscription.Endpoint); err != nil {
+		return fmt.Errorf("%w: %w", ErrSubscriptionGone, err)
+	}
+	endpoint, err := url.Parse(subscription.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid push endpoint: %w", err)
+	}
+
+	token, err := s.vapidToken(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(webPushTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, s.publicKey))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("push service returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *WebPushSender) vapidToken(audience string) (string, error) {
+	claims := map[string]any{
+		"aud": audience,
+		"exp": time.Now().Add(vapidTokenExpiry).Unix(),
+		"sub": s.subject,
+	}
+
+	signingInput, err := jwtSigningInput("ES256", claims)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256([]byte(signingInput))
+	r, ss, err := ecdsa.Sign(rand.Reader, s.privateKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS wants the raw r || s form, not ASN.1
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	ss.FillBytes(sig[32:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// encryptPayload implements the aes128gcm content coding from RFC 8188 with the key derivation of RFC 8291
+func encryptPayload(plaintext []byte, p256dh, auth string) ([]byte, error) {
+	uaPublicBytes, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+
+	authSecret, err := decodeBase64URL(auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicBytes...)
+	keyInfo = append(keyInfo, asPublic...)
+
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, authSecret, keyInfo), ikm); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+
+	cek := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Single record, 0x02 marks it as the last one
+	padded := append(plaintext, 0x02)
+	if len(padded)+gcm.Overhead() > webPushRecordSize {
+		return nil, fmt.Errorf("push payload too large")
+	}
+
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	return gcm.Seal(header, nonce, padded, nil), nil
+}
+
+// Browsers hand out keys in either padded or unpadded base64url
+func decodeBase64URL(value string) ([]byte, error) {
+	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return base64.URLEncoding.DecodeString(value)
+}
@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	clamavChunkSize = 64 << 10
+	clamavTimeout   = 30 * time.Second
+)
+
+// ClamAV streams files to clamd with the INSTREAM command, clamd's StreamMaxLength
+// has to be at least the upload size limit
+type ClamAV struct {
+	address string
+	dialer  net.Dialer
+}
+
+func NewClamAV(address string) *ClamAV {
+	return &ClamAV{address: address}
+}
+
+func (c *ClamAV) Scan(ctx context.Context, content io.Reader) (*Verdict, error) {
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(clamavTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	// The z prefix means commands and replies are terminated by a null byte
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	chunk := make([]byte, clamavChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := content.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, werr := conn.Write(size); werr != nil {
+				return nil, werr
+			}
+			if _, werr := conn.Write(chunk[:n]); werr != nil {
+				return nil, werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A zero length chunk ends the stream
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+
+	return parseClamAVReply(string(bytes.TrimRight(reply, "\x00")))
+}
+
+// Replies look like "stream: OK", "stream: Eicar-Signature FOUND" or "INSTREAM size limit exceeded. ERROR"
+func parseClamAVReply(reply string) (*Verdict, error) {
+	reply = strings.TrimSpace(reply)
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return &Verdict{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return &Verdict{Infected: true, Signature: signature}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
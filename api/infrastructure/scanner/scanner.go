@@ -0,0 +1,18 @@
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+// Verdict is what a scanner made of a file, Signature names the match when Infected
+type Verdict struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner inspects uploaded content for malware. An error means no verdict was reached,
+// it never means the file is infected.
+type Scanner interface {
+	Scan(ctx context.Context, content io.Reader) (*Verdict, error)
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/hilthontt/visper/api/domain/model"
 )
 
@@ -15,7 +14,6 @@ const (
 	roomAuthCookie   = "visper_room_auth"
 	roomAuthJSCookie = "visper_room_auth_js"
 
-	userIDLifetime   = 30 * 24 * time.Hour // 30 days
 	roomAuthLifetime = 10 * 24 * time.Hour // 10 days
 )
 
@@ -48,73 +46,66 @@ func encodeToBase64(data any) (string, error) {
 	return base64.StdEncoding.EncodeToString(jsonData), nil
 }
 
-func decodeFromBase64(encoded string, target any) error {
-	decoded, err := base64.StdEncoding.DecodeString(encoded)
-	if err != nil {
-		return err
-	}
-
-	return json.Unmarshal(decoded, target)
-}
-
-func GetOrCreateUserID(w http.ResponseWriter, r *http.Request) string {
-	if userID := GetUserID(r); userID != "" {
-		return userID
-	}
-
-	newUserID := uuid.NewString()
-	SetUserID(w, newUserID)
-	return newUserID
-}
+// MemberTokenHeader carries the member token of clients that don't keep cookies, the
+// API sends it back whenever it issues a new one
+const MemberTokenHeader = "X-Member-Token"
 
-func GetUserID(r *http.Request) string {
-	// Check header first (for API/WebSocket clients)
-	if headerUserID := r.Header.Get("X-User-ID"); headerUserID != "" {
-		return headerUserID
+// GetMemberToken returns the token of the request, from the header or the cookie
+func GetMemberToken(r *http.Request) string {
+	if token := r.Header.Get(MemberTokenHeader); token != "" {
+		return token
 	}
 
-	// Fall back to cookie
 	cookie, err := r.Cookie(userIDCookie)
 	if err != nil {
 		return ""
 	}
-
-	decoded, err := base64.StdEncoding.DecodeString(cookie.Value)
-	if err != nil {
-		return ""
-	}
-
-	return string(decoded)
+	return cookie.Value
 }
 
-func SetUserID(w http.ResponseWriter, userID string) {
-	encoded := base64.StdEncoding.EncodeToString([]byte(userID))
+// SetMemberToken stores a token issued for every room in the user ID cookie
+func SetMemberToken(w http.ResponseWriter, token string, lifetime time.Duration) {
+	setSecureCookie(w, cookieConfig{
+		name:     userIDCookie,
+		value:    token,
+		path:     "/",
+		httpOnly: true,
+		maxAge:   int(lifetime.Seconds()),
+	})
+}
 
+// ClearMemberToken removes the member token cookie
+func ClearMemberToken(w http.ResponseWriter) {
 	setSecureCookie(w, cookieConfig{
 		name:     userIDCookie,
-		value:    encoded,
+		value:    "",
 		path:     "/",
 		httpOnly: true,
-		maxAge:   int(userIDLifetime.Seconds()),
+		maxAge:   -1,
 	})
 }
 
 // Room Authentication
 
-// SetRoomAuth sets authentication cookies for a room
-func SetRoomAuth(w http.ResponseWriter, user *model.User, roomID string) error {
+// SetRoomAuth sets authentication cookies for a room. The HttpOnly one holds a token
+// signed for the room, the other the user for client-side state only.
+func SetRoomAuth(w http.ResponseWriter, signer *MemberTokenSigner, user *model.User, roomID string) error {
+	token, err := signer.Issue(user.ID, roomID)
+	if err != nil {
+		return err
+	}
 	encoded, err := encodeToBase64(user)
 	if err != nil {
 		return err
 	}
 
 	roomPath := getRoomPath(roomID)
-	maxAge := int(roomAuthLifetime.Seconds())
+	maxAge := int(min(roomAuthLifetime, signer.TTL()).Seconds())
 
 	// HttpOnly cookie for server-side auth
 	setSecureCookie(w, cookieConfig{
 		name:     roomAuthCookie,
-		value:    encoded,
+		value:    token,
 		path:     roomPath,
 		httpOnly: true,
 		maxAge:   maxAge,
@@ -152,18 +143,13 @@ func ClearRoomAuth(w http.ResponseWriter, roomID string) {
 	})
 }
 
-func GetRoomAuth(r *http.Request) (*model.User, error) {
+// GetRoomAuthToken returns the room token of the request, verifying it is up to the caller
+func GetRoomAuthToken(r *http.Request) string {
 	cookie, err := r.Cookie(roomAuthCookie)
 	if err != nil {
-		return nil, err
-	}
-
-	var user model.User
-	if err := decodeFromBase64(cookie.Value, &user); err != nil {
-		return nil, err
+		return ""
 	}
-
-	return &user, nil
+	return cookie.Value
 }
 
 func getRoomPath(roomID string) string {
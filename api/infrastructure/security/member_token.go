@@ -0,0 +1,13 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// MemberToken fingerprints a client within a room so a ban survives a fresh user ID.
+// Only the hash is ever stored, scoped to the room so it can't be correlated across rooms.
+func MemberToken(roomID, clientIP, userAgent string) string {
+	sum := sha256.Sum256([]byte(roomID + "|" + clientIP + "|" + userAgent))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,162 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Member tokens are "v1.<key ID>.<claims>.<signature>", the claims base64url JSON and the
+// signature the base64url HMAC-SHA256 of everything before it
+const (
+	memberTokenVersion    = "v1"
+	defaultMemberTokenTTL = 30 * 24 * time.Hour
+	minMemberTokenSecret  = 16
+)
+
+var (
+	ErrInvalidMemberToken = errors.New("invalid member token")
+	ErrMemberTokenExpired = errors.New("member token has expired")
+)
+
+// MemberClaims say who a member token was issued to. A token without a room is good in
+// every room, one with a room only there.
+type MemberClaims struct {
+	UserID    string `json:"uid"`
+	RoomID    string `json:"rid,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	KeyID     string `json:"-"` // The key that signed the token
+}
+
+type memberTokenKey struct {
+	id     string
+	secret []byte
+}
+
+// MemberTokenSigner signs member tokens with its first key and accepts those of any of its
+// keys, so a key is rotated by listing a new one first and dropping the old one once the
+// tokens it signed expired
+type MemberTokenSigner struct {
+	keys []memberTokenKey
+	ttl  time.Duration
+}
+
+// NewMemberTokenSigner takes keys as "<id>:<secret>", a zero ttl defaults to 30 days
+func NewMemberTokenSigner(keys []string, ttl time.Duration) (*MemberTokenSigner, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one member token key is required")
+	}
+	if ttl <= 0 {
+		ttl = defaultMemberTokenTTL
+	}
+
+	signer := &MemberTokenSigner{ttl: ttl}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		id, secret, ok := strings.Cut(key, ":")
+		if !ok || id == "" || strings.Contains(id, ".") {
+			return nil, fmt.Errorf("member token key %q is not \"<id>:<secret>\"", id)
+		}
+		if len(secret) < minMemberTokenSecret {
+			return nil, fmt.Errorf("member token key %q needs a secret of at least %d characters", id, minMemberTokenSecret)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("member token key %q is listed twice", id)
+		}
+		seen[id] = true
+		signer.keys = append(signer.keys, memberTokenKey{id: id, secret: []byte(secret)})
+	}
+
+	return signer, nil
+}
+
+// Issue signs a token for the user, scoped to roomID unless it's empty
+func (s *MemberTokenSigner) Issue(userID, roomID string) (string, error) {
+	now := time.Now()
+	payload, err := json.Marshal(MemberClaims{
+		UserID:    userID,
+		RoomID:    roomID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	key := s.keys[0]
+	unsigned := memberTokenVersion + "." + key.id + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(key.secret, unsigned), nil
+}
+
+// Verify checks the token's signature and expiry and returns its claims
+func (s *MemberTokenSigner) Verify(token string) (*MemberClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || parts[0] != memberTokenVersion {
+		return nil, ErrInvalidMemberToken
+	}
+
+	key, ok := s.key(parts[1])
+	if !ok {
+		return nil, ErrInvalidMemberToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrInvalidMemberToken
+	}
+	expected, _ := base64.RawURLEncoding.DecodeString(sign(key.secret, strings.Join(parts[:3], ".")))
+	if !hmac.Equal(signature, expected) {
+		return nil, ErrInvalidMemberToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidMemberToken
+	}
+	var claims MemberClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.UserID == "" {
+		return nil, ErrInvalidMemberToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrMemberTokenExpired
+	}
+
+	claims.KeyID = key.id
+	return &claims, nil
+}
+
+// NeedsRefresh reports whether a verified token should be replaced, because an older key
+// signed it or it's past half its lifetime
+func (s *MemberTokenSigner) NeedsRefresh(claims *MemberClaims) bool {
+	if claims.KeyID != s.keys[0].id {
+		return true
+	}
+	halfLife := (claims.ExpiresAt - claims.IssuedAt) / 2
+	return time.Now().Unix() >= claims.IssuedAt+halfLife
+}
+
+// TTL is how long the tokens issued now stay valid
+func (s *MemberTokenSigner) TTL() time.Duration {
+	return s.ttl
+}
+
+func (s *MemberTokenSigner) key(id string) (memberTokenKey, bool) {
+	for _, key := range s.keys {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return memberTokenKey{}, false
+}
+
+func sign(secret []byte, unsigned string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
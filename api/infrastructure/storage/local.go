@@ -1,14 +1,15 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/google/uuid"
+	"time"
 )
 
 const (
@@ -17,12 +18,17 @@ const (
 )
 
 type LocalStorage struct {
-	basePath string
+	basePath    string
+	signer      *URLSigner
+	downloadURL string
 }
 
-func NewLocalStorage() (*LocalStorage, error) {
+// NewLocalStorage keeps files on disk, downloadURL is the API route that serves them
+func NewLocalStorage(signer *URLSigner, downloadURL string) (*LocalStorage, error) {
 	storage := &LocalStorage{
-		basePath: UploadsBasePath,
+		basePath:    UploadsBasePath,
+		signer:      signer,
+		downloadURL: downloadURL,
 	}
 
 	if err := os.MkdirAll(storage.basePath, 0755); err != nil {
@@ -32,50 +38,51 @@ func NewLocalStorage() (*LocalStorage, error) {
 	return storage, nil
 }
 
-func (s *LocalStorage) SaveFile(file *multipart.FileHeader, roomID string) (string, string, error) {
-	if file.Size > MaxFileSize {
-		return "", "", fmt.Errorf("file size exceeds maximum allowed size of 5MB")
-	}
-
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	detectedType := extensionToMIME(ext)
-	if detectedType == "" {
-		return "", "", fmt.Errorf("invalid file type, only images are allowed")
+func (s *LocalStorage) Put(_ context.Context, key string, body io.Reader, _ int64, _ string) error {
+	fullPath := filepath.Join(s.basePath, key)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create room directory: %w", err)
 	}
 
-	src, err := file.Open()
+	dst, err := os.Create(fullPath)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to open uploaded file: %w", err)
+		return fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer src.Close()
-
-	fileID := uuid.NewString()
-	filename := fileID + ext
+	defer dst.Close()
 
-	roomPath := filepath.Join(s.basePath, roomID)
-	if err := os.MkdirAll(roomPath, 0755); err != nil {
-		return "", "", fmt.Errorf("failed to create room directory: %w", err)
+	if _, err := io.Copy(dst, body); err != nil {
+		_ = os.Remove(fullPath)
+		return err
 	}
 
-	filePath := filepath.Join(roomPath, filename)
+	return nil
+}
 
-	dst, err := os.Create(filePath)
+func (s *LocalStorage) Get(_ context.Context, key string) (*Object, error) {
+	f, err := os.Open(filepath.Join(s.basePath, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create destination file: %w", err)
+		return nil, err
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, src); err != nil {
-		return "", "", fmt.Errorf("failed to save file: %w", err)
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
 	}
 
-	relativePath := filepath.Join(roomID, filename)
+	contentType := extensionToMIME(strings.ToLower(filepath.Ext(key)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
 
-	return relativePath, fileID, nil
+	return &Object{Body: f, Size: info.Size(), ContentType: contentType}, nil
 }
 
-func (s *LocalStorage) DeleteFile(filePath string) error {
-	fullPath := filepath.Join(s.basePath, filePath)
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	fullPath := filepath.Join(s.basePath, key)
 
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
 		return nil
@@ -84,6 +91,12 @@ func (s *LocalStorage) DeleteFile(filePath string) error {
 	return os.Remove(fullPath)
 }
 
+// SignedURL points at the API's download route, which checks the signature before serving the file
+func (s *LocalStorage) SignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	expiresAt := time.Now().Add(ttl).Unix()
+	return fmt.Sprintf("%s/%s?expires=%d&sig=%s", s.downloadURL, key, expiresAt, s.signer.Sign(key, expiresAt)), nil
+}
+
 func (s *LocalStorage) DeleteRoomFiles(roomID string) error {
 	roomPath := filepath.Join(s.basePath, roomID)
 
@@ -94,12 +107,6 @@ func (s *LocalStorage) DeleteRoomFiles(roomID string) error {
 	return os.RemoveAll(roomPath)
 }
 
-func (s *LocalStorage) FileExists(relativePath string) bool {
-	fullPath := filepath.Join(s.basePath, relativePath)
-	_, err := os.Stat(fullPath)
-	return err == nil
-}
-
 func (s *LocalStorage) isValidImageType(contentType string) bool {
 	validTypes := map[string]bool{
 		"image/jpeg": true,
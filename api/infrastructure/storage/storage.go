@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/infrastructure/imaging"
+)
+
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	ErrFileTooLarge   = errors.New("file size exceeds maximum allowed size of 5MB")
+)
+
+// Storage keeps uploaded files. Keys are "<roomID>/<fileID><ext>", the same on every backend,
+// so the path stored with a file keeps working when the backend changes.
+type Storage interface {
+	// Put streams body into key, size must be exact
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get returns ErrObjectNotFound when nothing is stored under key
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete is a no-op for keys that don't exist
+	Delete(ctx context.Context, key string) error
+	// SignedURL is a link anyone can fetch key with until ttl runs out
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// RoomSweeper is implemented by backends that can find files left behind by deleted rooms,
+// object stores leave that to bucket lifecycle rules
+type RoomSweeper interface {
+	GetAllRoomDirectories() ([]string, error)
+	DeleteRoomFiles(roomID string) error
+}
+
+// Object is an open stored file, the caller closes Body
+type Object struct {
+	Body        io.ReadCloser
+	Size        int64
+	ContentType string
+}
+
+// SavedUpload describes what SaveUpload stored, type and size come from the sanitized content
+type SavedUpload struct {
+	Key         string
+	FileID      string
+	ContentType string
+	Size        int64
+}
+
+// SaveUpload identifies the upload by its content, sanitizes it and stores it. The client's
+// file name and Content-Type are ignored, a PNG named photo.jpg is stored as a PNG.
+func SaveUpload(ctx context.Context, s Storage, file *multipart.FileHeader, roomID string, keepMetadata bool) (*SavedUpload, error) {
+	if file.Size > MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	// Uploads are capped at 5MB, holding one in memory is cheaper than sniffing in two passes
+	data, err := io.ReadAll(io.LimitReader(src, MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	if len(data) > MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	contentType, ext, ok := imaging.Sniff(data)
+	if !ok {
+		return nil, imaging.ErrUnrecognizedImage
+	}
+
+	data, err = imaging.Sanitize(data, contentType, keepMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	fileID := uuid.NewString()
+	key := path.Join(roomID, fileID+ext)
+
+	if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return &SavedUpload{Key: key, FileID: fileID, ContentType: contentType, Size: int64(len(data))}, nil
+}
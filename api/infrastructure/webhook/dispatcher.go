@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+	"go.uber.org/zap"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	maxAttempts    = 4
+	initialBackoff = time.Second
+
+	SignatureHeader = "X-Visper-Signature"
+	TimestampHeader = "X-Visper-Timestamp"
+	EventHeader     = "X-Visper-Event"
+	DeliveryHeader  = "X-Visper-Delivery"
+
+	userAgent = "VisperWebhook/1.0"
+)
+
+// Payload is the JSON body POSTed to every webhook
+type Payload struct {
+	ID        string             `json:"id"`
+	Event     model.WebhookEvent `json:"event"`
+	RoomID    string             `json:"roomId"`
+	Timestamp time.Time          `json:"timestamp"`
+	Data      any                `json:"data"`
+}
+
+// Dispatcher fans room events out to the owner's webhooks
+type Dispatcher struct {
+	repository repository.WebhookRepository
+	client     *http.Client
+	logger     *logger.Logger
+
+	// ctx is cancelled by Shutdown, deliveries stop retrying then
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewDispatcher(repository repository.WebhookRepository, logger *logger.Logger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		ctx:        ctx,
+		cancel:     cancel,
+		repository: repository,
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: security.NewOutboundTransport(requestTimeout),
+			// A redirect would resend the signed body somewhere the owner didn't register
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger,
+	}
+}
+
+// Dispatch delivers the event to every subscribed webhook in the background
+func (d *Dispatcher) Dispatch(room *model.Room, event model.WebhookEvent, data any) {
+	if d == nil || room == nil || d.ctx.Err() != nil {
+		return
+	}
+
+	d.wg.Go(func() {
+		ctx := d.ctx
+
+		webhooks, err := d.repository.GetAll(ctx, room.ID)
+		if err != nil {
+			d.logger.Warn("failed to load room webhooks", zap.Error(err), zap.String("roomID", room.ID))
+			return
+		}
+
+		for _, webhook := range webhooks {
+			if !webhook.Subscribes(event) {
+				continue
+			}
+			d.wg.Go(func() { d.deliver(ctx, room, webhook, event, data) })
+		}
+	})
+}
+
+// Shutdown stops retrying and waits for the deliveries in flight to record how far they got
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, room *model.Room, webhook *model.RoomWebhook, event model.WebhookEvent, data any) {
+	payload := Payload{
+		ID:        uuid.NewString(),
+		Event:     event,
+		RoomID:    room.ID,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		d.logger.Error("failed to marshal webhook payload", zap.Error(err), zap.String("webhookID", webhook.ID))
+		return
+	}
+
+	delivery := &model.WebhookDelivery{
+		ID:        payload.ID,
+		WebhookID: webhook.ID,
+		RoomID:    room.ID,
+		Event:     event,
+	}
+
+	backoff := initialBackoff
+attempts:
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		delivery.Attempts = attempt
+
+		status, err := d.post(ctx, webhook, payload, body)
+		delivery.StatusCode = status
+		if err == nil {
+			delivery.Success = true
+			delivery.Error = ""
+			break
+		}
+		delivery.Error = err.Error()
+
+		// The endpoint understood us and said no, retrying won't change its mind
+		if !retryable(status) || attempt == maxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			// Shutting down, the delivery is recorded with the attempts made so far
+			break attempts
+		}
+		backoff *= 2
+	}
+
+	var expiresAt time.Time
+	if room.Expiry > 0 {
+		expiresAt = room.CreatedAt.Add(room.Expiry)
+	}
+
+	if err := d.repository.AppendDelivery(context.WithoutCancel(ctx), delivery, expiresAt); err != nil {
+		d.logger.Warn("failed to record webhook delivery", zap.Error(err), zap.String("webhookID", webhook.ID))
+	}
+
+	if !delivery.Success {
+		d.logger.Warn("webhook delivery failed",
+			zap.String("roomID", room.ID),
+			zap.String("webhookID", webhook.ID),
+			zap.String("event", string(event)),
+			zap.Int("attempts", delivery.Attempts),
+			zap.String("error", delivery.Error),
+		)
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, webhook *model.RoomWebhook, payload Payload, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(payload.Timestamp.Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, string(payload.Event))
+	req.Header.Set(DeliveryHeader, payload.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign is the hex HMAC-SHA256 of "<timestamp>.<body>", receivers recompute it with the
+// shared secret and should reject stale timestamps to stop replays
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Network errors and server side failures are worth another try, other 4xx aren't
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
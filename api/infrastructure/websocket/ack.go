@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	AckFrame = "ack"
+
+	// ackTimeout is how long an event waits for its ack before it is sent again
+	ackTimeout = 5 * time.Second
+	// maxRetransmits is how often an event is resent before the connection counts as unhealthy
+	maxRetransmits = 3
+)
+
+// AckPayload is what a client sends back, acks are cumulative so one frame covers every event up to Seq
+type AckPayload struct {
+	Type string `json:"type"`
+	Seq  uint64 `json:"seq"`
+}
+
+type pendingEvent struct {
+	msg         *WSMessage
+	firstSentAt time.Time
+	lastSentAt  time.Time
+	attempts    int
+}
+
+// ackTracker holds the sequenced events a client has been sent but not acknowledged yet.
+// The writer adds and retransmits, the reader acks, hence the lock.
+type ackTracker struct {
+	mu      sync.Mutex
+	pending map[uint64]*pendingEvent
+}
+
+func newAckTracker() *ackTracker {
+	return &ackTracker{pending: make(map[uint64]*pendingEvent)}
+}
+
+func (t *ackTracker) sent(msg *WSMessage, now time.Time) {
+	if msg.Seq == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.pending[msg.Seq]; ok {
+		return
+	}
+	t.pending[msg.Seq] = &pendingEvent{msg: msg, firstSentAt: now, lastSentAt: now, attempts: 1}
+}
+
+// ack clears everything up to seq and returns how long each of those events took to be acknowledged
+func (t *ackTracker) ack(seq uint64, now time.Time) []time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var latencies []time.Duration
+	for s, event := range t.pending {
+		if s <= seq {
+			latencies = append(latencies, now.Sub(event.firstSentAt))
+			delete(t.pending, s)
+		}
+	}
+	return latencies
+}
+
+// due returns the events to send again, unhealthy is set once an event ran out of retransmits
+func (t *ackTracker) due(now time.Time) (resend []*WSMessage, unhealthy bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, event := range t.pending {
+		if now.Sub(event.lastSentAt) < ackTimeout {
+			continue
+		}
+		if event.attempts > maxRetransmits {
+			return nil, true
+		}
+
+		event.attempts++
+		event.lastSentAt = now
+		resend = append(resend, event.msg)
+	}
+
+	slices.SortFunc(resend, func(a, b *WSMessage) int { return cmp.Compare(a.Seq, b.Seq) })
+	return resend, false
+}
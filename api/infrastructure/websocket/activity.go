@@ -0,0 +1,27 @@
+package websocket
+
+// ActivityRecorder hears how many connections a room has on this instance after every join
+// and leave. It's called from Run, so it must not block.
+type ActivityRecorder interface {
+	ConnectionsChanged(roomID string, connected int, joined bool)
+}
+
+// TrackActivity reports room concurrency to recorder, set before Run starts
+func (c *Core) TrackActivity(recorder ActivityRecorder) {
+	c.activity = recorder
+}
+
+func (c *Core) reportConnections(roomID string, joined bool) {
+	if c.activity == nil {
+		return
+	}
+
+	connected, _, _ := c.roomMgr.GetRoomStats(roomID)
+	c.activity.ConnectionsChanged(roomID, connected, joined)
+}
+
+// RoomConnectionCount is how many sockets this instance holds for the room
+func (c *Core) RoomConnectionCount(roomID string) int {
+	connected, _, _ := c.roomMgr.GetRoomStats(roomID)
+	return connected
+}
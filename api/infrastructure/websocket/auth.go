@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	AuthFrameType = "auth"
+
+	// authTimeout is how long a new connection has to send its auth frame
+	authTimeout = 10 * time.Second
+	// maxAuthFrameSize is plenty for a ticket, an unauthenticated peer can't make the server buffer more
+	maxAuthFrameSize = 4 << 10
+)
+
+var ErrAuthFrameMissing = errors.New("expected an auth frame")
+
+// AuthFrame has to be the first frame on a connection that authenticates with a ticket
+type AuthFrame struct {
+	Type   string `json:"type"`
+	Ticket string `json:"ticket"`
+}
+
+// ReadAuthFrame waits for the handshake frame, before the client is registered or anything is read as chat
+func ReadAuthFrame(conn *websocket.Conn) (AuthFrame, error) {
+	conn.SetReadLimit(maxAuthFrameSize)
+	_ = conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		return AuthFrame{}, fmt.Errorf("failed to read auth frame: %w", err)
+	}
+
+	var frame AuthFrame
+	if err := json.Unmarshal(raw, &frame); err != nil || frame.Type != AuthFrameType {
+		return AuthFrame{}, ErrAuthFrameMissing
+	}
+
+	return frame, nil
+}
+
+// Reject tells the client why in an error event, then closes with a policy violation
+func Reject(conn *websocket.Conn, msg *WSMessage) {
+	_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+	_ = conn.WriteJSON(msg)
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "authentication failed"))
+	_ = conn.Close()
+}
+
+func NewAuthenticationError(roomID, message string) *WSMessage {
+	return &WSMessage{
+		Type:   AuthenticationError,
+		RoomID: roomID,
+		Data: FrameErrorPayload{
+			Code:    "AUTH_FAILED",
+			Message: message,
+		},
+	}
+}
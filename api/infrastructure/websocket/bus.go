@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// busChannel is the Pub/Sub channel every instance publishes room events on
+const busChannel = "ws:events"
+
+// Bus carries room events between API instances, so a broadcast reaches clients
+// no matter which instance they are connected to
+type Bus interface {
+	Publish(ctx context.Context, envelope BusEnvelope) error
+	// Subscribe delivers every envelope until ctx is done
+	Subscribe(ctx context.Context, handler func(BusEnvelope)) error
+}
+
+// BusEnvelope wraps an event with the instance that produced it. TargetUserID is repeated
+// here because WSMessage keeps it out of the JSON clients see.
+type BusEnvelope struct {
+	InstanceID   string          `json:"instanceId"`
+	Priority     bool            `json:"priority,omitempty"`
+	TargetUserID string          `json:"targetUserId,omitempty"`
+	Message      json.RawMessage `json:"message"`
+}
+
+func newEnvelope(instanceID string, msg *WSMessage, priority bool) (BusEnvelope, error) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return BusEnvelope{}, err
+	}
+
+	return BusEnvelope{
+		InstanceID:   instanceID,
+		Priority:     priority,
+		TargetUserID: msg.TargetUserID,
+		Message:      raw,
+	}, nil
+}
+
+func (e BusEnvelope) decode() (*WSMessage, error) {
+	var msg WSMessage
+	if err := json.Unmarshal(e.Message, &msg); err != nil {
+		return nil, err
+	}
+
+	// Sequence numbers are handed out per instance, the receiving one stamps its own
+	msg.Seq = 0
+	msg.Epoch = ""
+	msg.TargetUserID = e.TargetUserID
+	return &msg, nil
+}
+
+type redisBus struct {
+	client redis.UniversalClient
+}
+
+func NewRedisBus(client redis.UniversalClient) Bus {
+	return &redisBus{client: client}
+}
+
+func (b *redisBus) Publish(ctx context.Context, envelope BusEnvelope) error {
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bus envelope: %w", err)
+	}
+
+	return b.client.Publish(ctx, busChannel, payload).Err()
+}
+
+func (b *redisBus) Subscribe(ctx context.Context, handler func(BusEnvelope)) error {
+	pubsub := b.client.Subscribe(ctx, busChannel)
+	defer pubsub.Close()
+
+	// Wait for the subscription to be confirmed so a broken connection fails loudly
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", busChannel, err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-messages:
+			if !ok {
+				return nil
+			}
+
+			var envelope BusEnvelope
+			if err := json.Unmarshal([]byte(m.Payload), &envelope); err != nil {
+				log.Warnf("dropping malformed bus envelope: %v", err)
+				continue
+			}
+			handler(envelope)
+		}
+	}
+}
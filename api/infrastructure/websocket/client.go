@@ -1,20 +1,47 @@
 package websocket
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// maxFrameSize caps a frame from a registered client, a message with its mentions fits well within it
+const maxFrameSize = 32 << 10
+
 type Client struct {
-	conn     *connWrapper
+	conn *connWrapper
+	// Message is never closed, closed tells the writer and every sender the client is gone
 	Message  chan *WSMessage
 	ID       string `json:"id"`
 	RoomID   string `json:"roomId"`
 	Username string `json:"username"`
 
+	// LastSeq is the last event the client saw before reconnecting, zero on a fresh connection
+	LastSeq uint64 `json:"-"`
+	// LastEpoch is the epoch LastSeq was numbered in
+	LastEpoch string `json:"-"`
+
+	// Core fills replay while registering and closes ready once it's done, the writer
+	// sends the replay before anything live so the gap arrives in order
+	replay []*WSMessage
+	ready  chan struct{}
+
+	// acks is nil unless the client asked for acknowledged delivery
+	acks *ackTracker
+
+	// frames is only touched by the reader, set up in ReadMessage
+	frames *frameBucket
+
+	// missedPongs counts pings sent since the last pong, dead is set once it passes the heartbeat limit
+	missedPongs atomic.Int32
+	dead        atomic.Bool
+
 	// Protection against double-close and race conditions
 	closeOnce sync.Once
 	closed    chan struct{} // signals when client is closed
@@ -28,20 +55,60 @@ func NewClient(conn *websocket.Conn, id, roomID, username string) *Client {
 		ID:       id,
 		RoomID:   roomID,
 		Username: username,
+		ready:    make(chan struct{}),
 		closed:   make(chan struct{}),
 	}
 }
 
+// EnableAcks makes the client acknowledge sequenced events, unacked ones are retransmitted.
+// It has to be called before the client is registered.
+func (c *Client) EnableAcks() {
+	c.acks = newAckTracker()
+}
+
 func (c *Client) Close() {
 	c.closeOnce.Do(func() {
 		close(c.closed)
 		c.mu.Lock()
 		_ = c.conn.Close()
 		c.mu.Unlock()
-		close(c.Message)
 	})
 }
 
+// send queues a reply for the writer without blocking, it is dropped when the buffer is full.
+// It reports false once the client is closed.
+func (c *Client) send(msg *WSMessage) bool {
+	if c.IsClosed() {
+		return false
+	}
+
+	select {
+	case c.Message <- msg:
+	default:
+	}
+	return true
+}
+
+// GoAway closes the connection with a going away frame, the client reconnects with
+// last_seq, possibly to another instance, and the replay catches it up
+func (c *Client) GoAway() {
+	if c.IsClosed() {
+		return
+	}
+
+	c.mu.Lock()
+	_ = c.conn.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+		time.Now().Add(time.Second))
+	c.mu.Unlock()
+	c.Close()
+}
+
+// IsDead reports whether the connection was reaped for missing pongs
+func (c *Client) IsDead() bool {
+	return c.dead.Load()
+}
+
 func (c *Client) IsClosed() bool {
 	select {
 	case <-c.closed:
@@ -53,14 +120,24 @@ func (c *Client) IsClosed() bool {
 
 func (c *Client) ReadMessage(core *Core) {
 	defer func() {
-		core.Unregister() <- c
+		select {
+		case core.Unregister() <- c:
+		case <-core.shutdown:
+		}
 		c.Close()
 	}()
 
-	_ = c.conn.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.frames = newFrameBucket(core.frameLimit, time.Now())
+
+	// Frames over the limit close the connection before they are buffered
+	c.conn.conn.SetReadLimit(maxFrameSize)
+
+	readTimeout := core.heartbeat.readTimeout()
+	_ = c.conn.conn.SetReadDeadline(time.Now().Add(readTimeout))
 
 	c.conn.conn.SetPongHandler(func(string) error {
-		_ = c.conn.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.missedPongs.Store(0)
+		_ = c.conn.conn.SetReadDeadline(time.Now().Add(readTimeout))
 		return nil
 	})
 
@@ -73,8 +150,10 @@ func (c *Client) ReadMessage(core *Core) {
 
 		_, raw, err := c.conn.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("ws read error (client %s): %v", c.ID, err)
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Warnf("frame over %d bytes from client %s, closing", maxFrameSize, c.ID)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Debugf("ws read error (client %s): %v", c.ID, err)
 			}
 			return
 		}
@@ -83,80 +162,110 @@ func (c *Client) ReadMessage(core *Core) {
 			continue
 		}
 
-		if len(raw) > 32768 { // 32KB max message size
-			log.Printf("message too large from client %s: %d bytes", c.ID, len(raw))
+		if c.acks != nil && c.handleAck(core, raw) {
 			continue
 		}
 
-		now := time.Now().Format(time.RFC3339)
-
-		payload := struct {
-			Content   string `json:"content"`
-			Username  string `json:"username"`
-			Timestamp string `json:"timestamp"`
-			UserID    string `json:"userId"`
-			Encrypted bool   `json:"encrypted"`
-		}{
-			Content:   string(raw),
-			Username:  c.Username,
-			Timestamp: now,
-			UserID:    c.ID,
-			Encrypted: false,
+		if allowed, reply := core.limitFrame(c, time.Now()); !allowed {
+			if reply != nil && !c.send(reply) {
+				return
+			}
+			continue
 		}
 
-		msg := &WSMessage{
-			Type:   MessageReceived,
-			RoomID: c.RoomID,
-			Data:   payload,
+		core.TouchPresence(c.RoomID, c.ID)
+
+		if core.IsReadOnly() {
+			if !c.send(NewMaintenanceError(c.RoomID, core.maintenance.Message())) {
+				return
+			}
+			continue
 		}
 
-		select {
-		case core.Broadcast() <- msg:
-		case <-c.closed:
+		// Plain text frames, as older SDKs write them, go through the same checks as send_message
+		frame, ok := parseSendFrame(raw)
+		if !ok {
+			frame = plainTextFrame(raw)
+		}
+		if !c.send(core.handleSendFrame(c, frame)) {
 			return
 		}
 	}
 }
 
-func (c *Client) WriteMessage() {
+func (c *Client) WriteMessage(core *Core) {
 	defer c.Close()
 
+	select {
+	case <-c.ready:
+	case <-c.closed:
+		return
+	}
+
+	for _, msg := range c.replay {
+		if err := c.writeTracked(msg); err != nil {
+			log.Warnf("ws replay write error (client %s): %v", c.ID, err)
+			return
+		}
+	}
+	c.replay = nil
+
 	// Ping ticker to keep connection alive
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(core.heartbeat.PingInterval)
 	defer ticker.Stop()
 
+	// Left nil without acks, a nil channel never fires
+	var ackCheck <-chan time.Time
+	if c.acks != nil {
+		ackTicker := time.NewTicker(ackTimeout / 2)
+		defer ackTicker.Stop()
+		ackCheck = ackTicker.C
+	}
+
 	for {
 		select {
-		case msg, ok := <-c.Message:
-			if !ok {
-				// Channel closed, connection shutting down
+		case msg := <-c.Message:
+			if err := c.writeTracked(msg); err != nil {
+				log.Warnf("ws write error (client %s): %v", c.ID, err)
+				return
+			}
+
+		case now := <-ackCheck:
+			resend, unhealthy := c.acks.due(now)
+			if unhealthy {
+				// The client reconnects with last_seq and the replay picks up what it missed
+				log.Warnf("client %s stopped acknowledging events, closing connection", c.ID)
+				core.metrics.IncrementCounter(context.Background(), "websocket_unhealthy_connections")
 				c.mu.Lock()
-				_ = c.conn.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				_ = c.conn.conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "ack timeout"))
 				c.mu.Unlock()
 				return
 			}
 
-			// Set write deadline
-			_ = c.conn.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-
-			c.mu.Lock()
-			err := c.conn.WriteJSON(msg)
-			c.mu.Unlock()
+			for _, msg := range resend {
+				core.metrics.IncrementCounter(context.Background(), "websocket_retransmits")
+				if err := c.writeJSON(msg); err != nil {
+					log.Warnf("ws retransmit error (client %s): %v", c.ID, err)
+					return
+				}
+			}
 
-			if err != nil {
-				log.Printf("ws write error (client %s): %v", c.ID, err)
+		case <-ticker.C:
+			if int(c.missedPongs.Add(1)) > core.heartbeat.MaxMissedPongs {
+				// Core sees the flag when the reader unregisters the client and tells the room
+				log.Infof("client %s missed %d pongs, reaping connection", c.ID, core.heartbeat.MaxMissedPongs)
+				c.dead.Store(true)
 				return
 			}
 
-		case <-ticker.C:
-			// Send ping
 			c.mu.Lock()
 			_ = c.conn.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			err := c.conn.conn.WriteMessage(websocket.PingMessage, nil)
 			c.mu.Unlock()
 
 			if err != nil {
-				log.Printf("ping error (client %s): %v", c.ID, err)
+				log.Warnf("ping error (client %s): %v", c.ID, err)
 				return
 			}
 
@@ -165,3 +274,35 @@ func (c *Client) WriteMessage() {
 		}
 	}
 }
+
+// writeTracked sends the event and starts waiting for its ack when the client uses them
+func (c *Client) writeTracked(msg *WSMessage) error {
+	if err := c.writeJSON(msg); err != nil {
+		return err
+	}
+	if c.acks != nil {
+		c.acks.sent(msg, time.Now())
+	}
+	return nil
+}
+
+// handleAck reports whether the frame was an ack, anything else is treated as a chat message
+func (c *Client) handleAck(core *Core, raw []byte) bool {
+	var frame AckPayload
+	if json.Unmarshal(raw, &frame) != nil || frame.Type != AckFrame {
+		return false
+	}
+
+	for _, latency := range c.acks.ack(frame.Seq, time.Now()) {
+		core.metrics.RecordHistogram(context.Background(), "websocket_ack_latency_seconds", latency.Seconds())
+	}
+	return true
+}
+
+func (c *Client) writeJSON(msg *WSMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_ = c.conn.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.conn.WriteJSON(msg)
+}
@@ -1,9 +1,24 @@
 package websocket
 
+import (
+	"math"
+	"time"
+)
+
 type WSMessage struct {
 	Type   string `json:"type"`
 	RoomID string `json:"roomId"`
 	Data   any    `json:"data"`
+
+	// Seq orders the room's events, clients send the last one they saw as last_seq when reconnecting.
+	// Events that aren't replayed, like presence and direct error replies, carry none.
+	Seq uint64 `json:"seq,omitempty"`
+	// Epoch names the numbering Seq belongs to, each instance has its own. Clients send it
+	// back as last_epoch, a last_seq from another epoch can't be replayed.
+	Epoch string `json:"epoch,omitempty"`
+
+	// TargetUserID limits delivery to one member's connections, targeted events stay out of history
+	TargetUserID string `json:"-"`
 }
 
 type MessagePayload struct {
@@ -13,6 +28,72 @@ type MessagePayload struct {
 	Username  string `json:"username"`
 	Timestamp string `json:"timestamp"`
 	Encrypted bool   `json:"encrypted"`
+	// Room key version the content was sealed with
+	KeyVersion int `json:"keyVersion,omitempty"`
+
+	ParentMessageID string `json:"parentMessageId,omitempty"`
+	ReplyCount      int64  `json:"replyCount,omitempty"`
+}
+
+type AnnouncementPayload struct {
+	ID        string `json:"id"`
+	Content   string `json:"content"`
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	Timestamp string `json:"timestamp"`
+	Encrypted bool   `json:"encrypted"`
+	Pinned    bool   `json:"pinned"`
+}
+
+type MentionPayload struct {
+	MessageID       string `json:"messageId"`
+	UserID          string `json:"userId"`
+	Username        string `json:"username"`
+	MentionedUserID string `json:"mentionedUserId"`
+	Content         string `json:"content"`
+	Timestamp       string `json:"timestamp"`
+	Encrypted       bool   `json:"encrypted"`
+}
+
+type MessageFlaggedPayload struct {
+	MessageID string `json:"messageId,omitempty"` // empty when the message was blocked
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	Content   string `json:"content"`
+	Blocked   bool   `json:"blocked"`
+	Timestamp string `json:"timestamp"`
+}
+
+type FileQuarantinedPayload struct {
+	FileID    string `json:"fileId"`
+	Filename  string `json:"filename"`
+	UserID    string `json:"userId"` // the uploader
+	Signature string `json:"signature"`
+}
+
+type UploadProgressPayload struct {
+	Percent       int   `json:"percent"`
+	BytesReceived int64 `json:"bytesReceived"`
+	TotalBytes    int64 `json:"totalBytes"`
+}
+
+type LinkPreviewPayload struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+	SiteName    string `json:"siteName,omitempty"`
+}
+
+type MessagePreviewPayload struct {
+	MessageID string             `json:"messageId"`
+	Preview   LinkPreviewPayload `json:"preview"`
+}
+
+// ReplayTruncatedPayload tells a reconnecting client its gap could not be replayed,
+// it should refetch over REST and carry on from LastSeq in the event's epoch
+type ReplayTruncatedPayload struct {
+	LastSeq uint64 `json:"lastSeq"`
 }
 
 type MessageUpdatedPayload struct {
@@ -29,38 +110,207 @@ type MessageDeletedPayload struct {
 	Timestamp string `json:"timestamp"`
 }
 
+type ReactionPayload struct {
+	MessageID string         `json:"messageId"`
+	UserID    string         `json:"userId"`
+	Emoji     string         `json:"emoji"`
+	Counts    map[string]int `json:"counts"`
+}
+
+type PresenceChangedPayload struct {
+	UserID       string `json:"userId"`
+	Username     string `json:"username"`
+	Status       string `json:"status"`
+	LastActiveAt string `json:"lastActiveAt"`
+}
+
 type MemberPayload struct {
 	UserID   string `json:"userId"`
 	Username string `json:"username"`
 	JoinedAt string `json:"joinedAt,omitempty"`
 }
 
+type MemberMutedPayload struct {
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
 type RoomDeletedPayload struct {
 	RoomID string `json:"roomid"`
 }
 
+type RoomExpiredPayload struct {
+	RoomID    string `json:"roomId"`
+	ExpiredAt string `json:"expiredAt"`
+}
+
+// RoomExpiryPayload is sent with expiring soon warnings and when the owner extends the room
+type RoomExpiryPayload struct {
+	ExpiresAt   string `json:"expiresAt"`
+	SecondsLeft int64  `json:"secondsLeft"`
+}
+
 type RoomUpdatedPayload struct {
 	RoomID   string `json:"roomId"`
 	JoinCode string `json:"joinCode"`
 }
 
+// RoomKeyRotatedPayload never carries the key, a removed member may still be listening
+type RoomKeyRotatedPayload struct {
+	KeyVersion int    `json:"keyVersion"`
+	Reason     string `json:"reason"`
+}
+
+type RoomSettingsPayload struct {
+	Name            string `json:"name,omitempty"`
+	Topic           string `json:"topic,omitempty"`
+	MaxMembers      int    `json:"maxMembers"`
+	SlowModeSeconds int    `json:"slowModeSeconds"`
+	ReadOnly        bool   `json:"readOnly"`
+	Moderation      string `json:"moderation"`
+}
+
+// SystemBroadcastPayload is an operator's notice, Level is info, warning or critical
+type SystemBroadcastPayload struct {
+	Message string `json:"message"`
+	Level   string `json:"level"`
+	SentAt  string `json:"sentAt"`
+}
+
+type ErrorPayload struct {
+	Message string `json:"message"`
+}
+
+// SendAckPayload confirms a send_message frame was stored
+type SendAckPayload struct {
+	RequestID string `json:"requestId,omitempty"`
+	MessageID string `json:"messageId"`
+	Timestamp string `json:"timestamp"`
+}
+
+// FrameErrorPayload answers a frame the server rejected, Code matches the REST error codes
+type FrameErrorPayload struct {
+	RequestID  string `json:"requestId,omitempty"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retryAfter,omitempty"` // seconds
+}
+
+type SlowModePayload struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retryAfter"` // seconds
+}
+
 type ErrorKickedPayload struct {
 	UserID   string `json:"userId"`
 	Username string `json:"username"`
 	Reason   string `json:"reason"`
 }
 
-func NewMessageReceived(roomID, msgID, content, userID, username, timestamp string, encrypted bool) *WSMessage {
+func NewMessageReceived(roomID, msgID, content, userID, username, timestamp string, encrypted bool, keyVersion int) *WSMessage {
+	return &WSMessage{
+		Type:   MessageReceived,
+		RoomID: roomID,
+		Data: MessagePayload{
+			ID:         msgID,
+			Content:    content,
+			UserID:     userID,
+			Username:   username,
+			Timestamp:  timestamp,
+			Encrypted:  encrypted,
+			KeyVersion: keyVersion,
+		},
+	}
+}
+
+// NewReplyReceived carries the thread root and its updated reply count so clients can nest the reply
+func NewReplyReceived(roomID, msgID, parentMsgID, content, userID, username, timestamp string, encrypted bool, keyVersion int, replyCount int64) *WSMessage {
 	return &WSMessage{
 		Type:   MessageReceived,
 		RoomID: roomID,
 		Data: MessagePayload{
+			ID:              msgID,
+			Content:         content,
+			UserID:          userID,
+			Username:        username,
+			Timestamp:       timestamp,
+			Encrypted:       encrypted,
+			KeyVersion:      keyVersion,
+			ParentMessageID: parentMsgID,
+			ReplyCount:      replyCount,
+		},
+	}
+}
+
+func NewAnnouncement(roomID, msgID, content, userID, username, timestamp string, encrypted, pinned bool) *WSMessage {
+	return &WSMessage{
+		Type:   Announcement,
+		RoomID: roomID,
+		Data: AnnouncementPayload{
 			ID:        msgID,
 			Content:   content,
 			UserID:    userID,
 			Username:  username,
 			Timestamp: timestamp,
 			Encrypted: encrypted,
+			Pinned:    pinned,
+		},
+	}
+}
+
+func NewMentioned(roomID, mentionedUserID, msgID, content, userID, username, timestamp string, encrypted bool) *WSMessage {
+	return &WSMessage{
+		Type:         Mentioned,
+		RoomID:       roomID,
+		TargetUserID: mentionedUserID,
+		Data: MentionPayload{
+			MessageID:       msgID,
+			UserID:          userID,
+			Username:        username,
+			MentionedUserID: mentionedUserID,
+			Content:         content,
+			Timestamp:       timestamp,
+			Encrypted:       encrypted,
+		},
+	}
+}
+
+func NewMessageFlagged(roomID, ownerID string, payload MessageFlaggedPayload) *WSMessage {
+	return &WSMessage{
+		Type:         MessageFlagged,
+		RoomID:       roomID,
+		TargetUserID: ownerID,
+		Data:         payload,
+	}
+}
+
+func NewFileQuarantined(roomID, ownerID string, payload FileQuarantinedPayload) *WSMessage {
+	return &WSMessage{
+		Type:         FileQuarantined,
+		RoomID:       roomID,
+		TargetUserID: ownerID,
+		Data:         payload,
+	}
+}
+
+func NewUploadProgress(roomID, userID string, payload UploadProgressPayload) *WSMessage {
+	return &WSMessage{
+		Type:         UploadProgress,
+		RoomID:       roomID,
+		TargetUserID: userID,
+		Data:         payload,
+	}
+}
+
+func NewMessagePreviewReady(roomID, msgID string, preview LinkPreviewPayload) *WSMessage {
+	return &WSMessage{
+		Type:   MessagePreviewReady,
+		RoomID: roomID,
+		Data: MessagePreviewPayload{
+			MessageID: msgID,
+			Preview:   preview,
 		},
 	}
 }
@@ -91,6 +341,45 @@ func NewMessageDeleted(roomID, msgID, timestamp string) *WSMessage {
 	}
 }
 
+func NewReactionAdded(roomID, msgID, userID, emoji string, counts map[string]int) *WSMessage {
+	return &WSMessage{
+		Type:   ReactionAdded,
+		RoomID: roomID,
+		Data: ReactionPayload{
+			MessageID: msgID,
+			UserID:    userID,
+			Emoji:     emoji,
+			Counts:    counts,
+		},
+	}
+}
+
+func NewReactionRemoved(roomID, msgID, userID, emoji string, counts map[string]int) *WSMessage {
+	return &WSMessage{
+		Type:   ReactionRemoved,
+		RoomID: roomID,
+		Data: ReactionPayload{
+			MessageID: msgID,
+			UserID:    userID,
+			Emoji:     emoji,
+			Counts:    counts,
+		},
+	}
+}
+
+func NewPresenceChanged(roomID string, entry PresenceEntry) *WSMessage {
+	return &WSMessage{
+		Type:   PresenceChanged,
+		RoomID: roomID,
+		Data: PresenceChangedPayload{
+			UserID:       entry.UserID,
+			Username:     entry.Username,
+			Status:       string(entry.Status),
+			LastActiveAt: entry.LastActiveAt.Format(time.RFC3339),
+		},
+	}
+}
+
 func NewMemberJoined(roomID string, member MemberPayload) *WSMessage {
 	return &WSMessage{
 		Type:   MemberJoined,
@@ -99,6 +388,17 @@ func NewMemberJoined(roomID string, member MemberPayload) *WSMessage {
 	}
 }
 
+func NewMemberDisconnected(roomID, userID, username string) *WSMessage {
+	return &WSMessage{
+		Type:   MemberDisconnected,
+		RoomID: roomID,
+		Data: MemberPayload{
+			UserID:   userID,
+			Username: username,
+		},
+	}
+}
+
 func NewMemberLeft(roomID, userID, username string) *WSMessage {
 	return &WSMessage{
 		Type:   MemberLeft,
@@ -110,6 +410,29 @@ func NewMemberLeft(roomID, userID, username string) *WSMessage {
 	}
 }
 
+func NewMemberMuted(roomID, userID, username, expiresAt string) *WSMessage {
+	return &WSMessage{
+		Type:   MemberMuted,
+		RoomID: roomID,
+		Data: MemberMutedPayload{
+			UserID:    userID,
+			Username:  username,
+			ExpiresAt: expiresAt,
+		},
+	}
+}
+
+func NewMemberUnmuted(roomID, userID, username string) *WSMessage {
+	return &WSMessage{
+		Type:   MemberUnmuted,
+		RoomID: roomID,
+		Data: MemberMutedPayload{
+			UserID:   userID,
+			Username: username,
+		},
+	}
+}
+
 func NewRoomDeleted(roomID string) *WSMessage {
 	return &WSMessage{
 		Type:   RoomDeleted,
@@ -120,6 +443,51 @@ func NewRoomDeleted(roomID string) *WSMessage {
 	}
 }
 
+func NewRoomExpired(roomID string, expiredAt time.Time) *WSMessage {
+	return &WSMessage{
+		Type:   RoomExpired,
+		RoomID: roomID,
+		Data: RoomExpiredPayload{
+			RoomID:    roomID,
+			ExpiredAt: expiredAt.Format(time.RFC3339),
+		},
+	}
+}
+
+func NewRoomExpiringSoon(roomID string, expiresAt time.Time) *WSMessage {
+	return &WSMessage{
+		Type:   RoomExpiringSoon,
+		RoomID: roomID,
+		Data:   newRoomExpiryPayload(expiresAt),
+	}
+}
+
+func NewRoomExtended(roomID string, expiresAt time.Time) *WSMessage {
+	return &WSMessage{
+		Type:   RoomExtended,
+		RoomID: roomID,
+		Data:   newRoomExpiryPayload(expiresAt),
+	}
+}
+
+func newRoomExpiryPayload(expiresAt time.Time) RoomExpiryPayload {
+	return RoomExpiryPayload{
+		ExpiresAt:   expiresAt.Format(time.RFC3339),
+		SecondsLeft: int64(math.Max(0, time.Until(expiresAt).Seconds())),
+	}
+}
+
+func NewReplayTruncated(roomID, epoch string, lastSeq uint64) *WSMessage {
+	return &WSMessage{
+		Type:   ReplayTruncated,
+		RoomID: roomID,
+		Epoch:  epoch,
+		Data: ReplayTruncatedPayload{
+			LastSeq: lastSeq,
+		},
+	}
+}
+
 func NewRoomUpdated(roomID, joinCode string) *WSMessage {
 	return &WSMessage{
 		Type:   RoomUpdated,
@@ -131,6 +499,37 @@ func NewRoomUpdated(roomID, joinCode string) *WSMessage {
 	}
 }
 
+func NewRoomKeyRotated(roomID string, keyVersion int, reason string) *WSMessage {
+	return &WSMessage{
+		Type:   RoomKeyRotated,
+		RoomID: roomID,
+		Data: RoomKeyRotatedPayload{
+			KeyVersion: keyVersion,
+			Reason:     reason,
+		},
+	}
+}
+
+func NewRoomSettingsUpdated(roomID string, settings RoomSettingsPayload) *WSMessage {
+	return &WSMessage{
+		Type:   RoomSettingsUpdated,
+		RoomID: roomID,
+		Data:   settings,
+	}
+}
+
+func NewSystemBroadcast(roomID, message, level string, sentAt time.Time) *WSMessage {
+	return &WSMessage{
+		Type:   SystemBroadcast,
+		RoomID: roomID,
+		Data: SystemBroadcastPayload{
+			Message: message,
+			Level:   level,
+			SentAt:  sentAt.Format(time.RFC3339),
+		},
+	}
+}
+
 func NewErrorKicked(roomID, kickedUserID, kickedUsername, reason string) *WSMessage {
 	return &WSMessage{
 		Type:   Kicked,
@@ -142,3 +541,45 @@ func NewErrorKicked(roomID, kickedUserID, kickedUsername, reason string) *WSMess
 		},
 	}
 }
+
+func NewMaintenanceError(roomID, message string) *WSMessage {
+	return &WSMessage{
+		Type:   MaintenanceMode,
+		RoomID: roomID,
+		Data: ErrorPayload{
+			Message: message,
+		},
+	}
+}
+
+func NewMutedError(roomID, message string) *WSMessage {
+	return &WSMessage{
+		Type:   Muted,
+		RoomID: roomID,
+		Data: ErrorPayload{
+			Message: message,
+		},
+	}
+}
+
+func NewReadOnlyError(roomID, message string) *WSMessage {
+	return &WSMessage{
+		Type:   ReadOnly,
+		RoomID: roomID,
+		Data: ErrorPayload{
+			Message: message,
+		},
+	}
+}
+
+func NewSlowModeError(roomID string, retryAfter time.Duration) *WSMessage {
+	return &WSMessage{
+		Type:   SlowMode,
+		RoomID: roomID,
+		Data: SlowModePayload{
+			Code:       "SLOW_MODE",
+			Message:    "slow mode is enabled, please wait before sending another message",
+			RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+		},
+	}
+}
@@ -2,45 +2,115 @@ package websocket
 
 import (
 	"context"
-	"log"
 	"sync"
 	"time"
 
 	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/maintenance"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
 )
 
+// busOutboundSize bounds how many events can wait to be published before new ones are dropped
+const busOutboundSize = 256
+
+// roomChannelSize bounds how far a single busy room can fall behind before events are dropped
+const roomChannelSize = 64
+
+// roomChannel fans events out to the clients of one room on its own goroutine,
+// so a large room never holds up delivery to the others
+type roomChannel struct {
+	messages chan queuedMessage
+	clients  map[string]struct{}
+}
+
 type Core struct {
-	roomMgr           *RoomManager
+	roomMgr *RoomManager
+	// Only touched from Run, no locking needed
+	rooms             map[string]*roomChannel
 	register          chan *Client
 	unregister        chan *Client
 	broadcast         chan *WSMessage
-	roomRepository    repository.RoomRepository
 	messageRepository repository.MessageRepository
+	maintenance       *maintenance.Mode
+	presence          *PresenceTracker
+	replay            *ReplayBuffer
+	metrics           metrics.Manager
+	heartbeat         Heartbeat
+	frameLimit        FrameLimit
+	frameBans         *frameBans
+	inbound           InboundHandler
+	sendLimiter       SendLimiter
+	activity          ActivityRecorder
+
+	// bus is nil on a single instance. Events from other instances come in through remote,
+	// our own go out through outbound so a slow bus never holds up Run.
+	bus        Bus
+	instanceID string
+	remote     chan *WSMessage
+	outbound   chan BusEnvelope
 
 	shutdown chan struct{}
+	done     chan struct{} // Closed once Run has returned and its goroutines are finished
 	wg       sync.WaitGroup
 	once     sync.Once
 }
 
-func NewCore(roomRepository repository.RoomRepository, messageRepository repository.MessageRepository) *Core {
+func NewCore(
+	messageRepository repository.MessageRepository,
+	maintenance *maintenance.Mode,
+	presenceIdleTimeout time.Duration,
+	heartbeat Heartbeat,
+	frameLimit FrameLimit,
+	metrics metrics.Manager,
+	bus Bus,
+	instanceID string,
+) *Core {
 	return &Core{
 		roomMgr:           NewRoomManager(),
+		rooms:             make(map[string]*roomChannel),
 		register:          make(chan *Client),
 		unregister:        make(chan *Client),
 		broadcast:         make(chan *WSMessage, 256),
-		roomRepository:    roomRepository,
 		messageRepository: messageRepository,
+		maintenance:       maintenance,
+		presence:          NewPresenceTracker(presenceIdleTimeout),
+		replay:            NewReplayBuffer(replayBufferSize),
+		metrics:           metrics,
+		heartbeat:         heartbeat.withDefaults(),
+		frameLimit:        frameLimit.withDefaults(),
+		frameBans:         newFrameBans(),
+		bus:               bus,
+		instanceID:        instanceID,
+		remote:            make(chan *WSMessage, 256),
+		outbound:          make(chan BusEnvelope, busOutboundSize),
 		shutdown:          make(chan struct{}),
+		done:              make(chan struct{}),
 	}
 }
 
 func (c *Core) Run(ctx context.Context) {
+	defer close(c.done)
 	defer c.wg.Wait() // Wait for all goroutines to finish
+	defer c.closeRooms()
+
+	// Stops the bus subscriber when Shutdown ends Run rather than ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Sweeping a few times per timeout keeps away transitions reasonably prompt
+	presenceTicker := time.NewTicker(c.presence.IdleTimeout() / 4)
+	defer presenceTicker.Stop()
+
+	if c.bus != nil {
+		c.wg.Add(2)
+		go c.runBusSubscriber(ctx)
+		go c.runBusPublisher(ctx)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Core shutting down...")
+			log.Info("Core shutting down...")
 			c.Shutdown()
 			return
 
@@ -48,26 +118,144 @@ func (c *Core) Run(ctx context.Context) {
 			return
 
 		case cl := <-c.register:
+			// The replay is taken before the client joins the room channel, so every
+			// later event reaches it live and nothing falls between the two
+			replayed := c.prepareReplay(cl)
+
 			c.roomMgr.AddClient(cl)
+			c.joinRoomChannel(cl)
+			c.reportConnections(cl.RoomID, true)
+			if entry, changed := c.presence.Connect(cl.RoomID, cl.ID, cl.Username); changed {
+				c.emit(NewPresenceChanged(cl.RoomID, entry))
+			}
 
-			// Load persisted history with proper error handling
-			c.wg.Add(1)
-			go func(client *Client) {
-				defer c.wg.Done()
-				c.loadHistory(client)
-			}(cl)
+			if !replayed {
+				// Load persisted history with proper error handling
+				c.wg.Add(1)
+				go func(client *Client) {
+					defer c.wg.Done()
+					c.loadHistory(client)
+				}(cl)
+			}
 
 		case cl := <-c.unregister:
+			if cl.IsDead() {
+				c.metrics.IncrementCounter(ctx, "websocket_connections_reaped")
+				c.emit(NewMemberDisconnected(cl.RoomID, cl.ID, cl.Username))
+			}
+			if entry, changed := c.presence.Disconnect(cl.RoomID, cl.ID); changed {
+				c.emit(NewPresenceChanged(cl.RoomID, entry))
+			}
 			c.roomMgr.RemoveClient(cl)
+			c.leaveRoomChannel(cl)
+			c.reportConnections(cl.RoomID, false)
 
 		case msg := <-c.broadcast:
-			if err := c.roomMgr.BroadcastToRoom(msg); err != nil {
-				log.Printf("broadcast error: %v", err)
+			c.emit(msg)
+
+		case msg := <-c.remote:
+			c.dispatch(msg)
+
+		case now := <-presenceTicker.C:
+			c.replay.Sweep(now)
+			for roomID, entries := range c.presence.Sweep(now) {
+				for _, entry := range entries {
+					c.emit(NewPresenceChanged(roomID, entry))
+				}
 			}
 		}
 	}
 }
 
+func (c *Core) joinRoomChannel(cl *Client) {
+	room, ok := c.rooms[cl.RoomID]
+	if !ok {
+		room = &roomChannel{
+			messages: make(chan queuedMessage, roomChannelSize),
+			clients:  make(map[string]struct{}),
+		}
+		c.rooms[cl.RoomID] = room
+
+		c.wg.Add(1)
+		go c.runRoomChannel(cl.RoomID, room)
+	}
+
+	room.clients[cl.ID] = struct{}{}
+}
+
+func (c *Core) leaveRoomChannel(cl *Client) {
+	room, ok := c.rooms[cl.RoomID]
+	if !ok {
+		return
+	}
+
+	delete(room.clients, cl.ID)
+	if len(room.clients) == 0 {
+		close(room.messages)
+		delete(c.rooms, cl.RoomID)
+	}
+}
+
+// prepareReplay hands a reconnecting client the events it missed and reports whether that covered
+// the gap. Fresh connections and gaps that are no longer buffered fall back to the persisted history.
+func (c *Core) prepareReplay(cl *Client) bool {
+	defer close(cl.ready)
+
+	if cl.LastSeq == 0 {
+		return false
+	}
+
+	events, complete := c.replay.Since(cl.RoomID, cl.ID, cl.LastEpoch, cl.LastSeq)
+	if !complete {
+		cl.replay = []*WSMessage{NewReplayTruncated(cl.RoomID, c.replay.Epoch(), c.replay.LastSeq(cl.RoomID))}
+		return false
+	}
+
+	cl.replay = events
+	return true
+}
+
+// emit delivers an event that started on this instance and shares it with the others
+func (c *Core) emit(msg *WSMessage) {
+	c.dispatch(msg)
+	c.publish(msg, false)
+}
+
+// dispatch records the event for replay and hands it to its room's channel,
+// rooms with nobody connected are still recorded so a reconnect can catch up
+func (c *Core) dispatch(msg *WSMessage) {
+	c.replay.Record(msg)
+
+	room, ok := c.rooms[msg.RoomID]
+	if !ok {
+		return
+	}
+
+	queued := newQueuedMessage(msg)
+	select {
+	case room.messages <- queued:
+	default:
+		log.Warnf("room %s channel full, dropping %s event", msg.RoomID, msg.Type)
+		queued.dropped("room channel full")
+	}
+}
+
+func (c *Core) runRoomChannel(roomID string, room *roomChannel) {
+	defer c.wg.Done()
+
+	for queued := range room.messages {
+		queued.dequeued()
+		err := c.roomMgr.BroadcastToRoom(queued.msg)
+		if err == ErrRoomNotFound {
+			err = nil
+		}
+		if err != nil {
+			log.Errorf("broadcast error in room %s: %v", roomID, err)
+		}
+		queued.delivered(err)
+	}
+}
+
 func (c *Core) loadHistory(cl *Client) {
 	if cl.IsClosed() {
 		return
@@ -79,7 +267,7 @@ func (c *Core) loadHistory(cl *Client) {
 	limit := int64(50)
 	messages, err := c.messageRepository.GetByRoom(ctx, cl.RoomID, limit)
 	if err != nil {
-		log.Printf("failed to load history for room %s: %v", cl.RoomID, err)
+		log.Errorf("failed to load history for room %s: %v", cl.RoomID, err)
 		return
 	}
 
@@ -111,7 +299,7 @@ func (c *Core) loadHistory(cl *Client) {
 		select {
 		case cl.Message <- hist:
 		case <-time.After(5 * time.Second):
-			log.Printf("timeout sending history to client %s", cl.ID)
+			log.Warnf("timeout sending history to client %s", cl.ID)
 			return
 		case <-cl.closed:
 			return
@@ -119,6 +307,30 @@ func (c *Core) loadHistory(cl *Client) {
 	}
 }
 
+// IsReadOnly reports whether inbound client messages should be rejected
+func (c *Core) IsReadOnly() bool {
+	return c.maintenance != nil && c.maintenance.IsEnabled()
+}
+
+func (c *Core) ClientCount() int {
+	return c.roomMgr.ClientCount()
+}
+
+func (c *Core) IsUserInRoom(roomID, userID string) bool {
+	return c.roomMgr.HasClient(roomID, userID)
+}
+
+// TouchPresence marks the user as active, for activity that doesn't arrive over the socket
+func (c *Core) TouchPresence(roomID, userID string) {
+	if entry, changed := c.presence.Touch(roomID, userID); changed {
+		c.broadcast <- NewPresenceChanged(roomID, entry)
+	}
+}
+
+func (c *Core) RoomPresence(roomID string) map[string]PresenceEntry {
+	return c.presence.Room(roomID)
+}
+
 func (c *Core) Register() chan<- *Client {
 	return c.register
 }
@@ -131,14 +343,149 @@ func (c *Core) Broadcast() chan<- *WSMessage {
 	return c.broadcast
 }
 
+// BroadcastPriority delivers straight to the room's clients instead of queueing behind
+// regular traffic, so it is never dropped because the room channel is full
+func (c *Core) BroadcastPriority(msg *WSMessage) {
+	c.broadcastPriorityLocal(msg)
+	c.publish(msg, true)
+}
+
+func (c *Core) broadcastPriorityLocal(msg *WSMessage) {
+	c.replay.Record(msg)
+
+	if err := c.roomMgr.BroadcastToRoom(msg); err != nil && err != ErrRoomNotFound {
+		log.Errorf("priority broadcast error in room %s: %v", msg.RoomID, err)
+	}
+}
+
+// Done is closed once Run has returned, every client has been sent away and the
+// events still queued for the bus have been published
+func (c *Core) Done() <-chan struct{} {
+	return c.done
+}
+
+// Shutdown tells Run to stop, Run sends every client away on its way out. The register,
+// unregister and broadcast channels stay open so late senders don't panic, nothing reads
+// them anymore.
 func (c *Core) Shutdown() {
 	c.once.Do(func() {
 		close(c.shutdown)
+	})
+}
 
-		close(c.register)
-		close(c.unregister)
-		close(c.broadcast)
+// closeRooms ends every room channel and sends every client away. Only Run calls it, on its
+// way out, since the rooms are only touched from Run.
+func (c *Core) closeRooms() {
+	for roomID, room := range c.rooms {
+		close(room.messages)
+		delete(c.rooms, roomID)
+	}
 
-		c.roomMgr.DisconnectAll()
-	})
+	c.roomMgr.DisconnectAll()
+}
+
+func (c *Core) publish(msg *WSMessage, priority bool) {
+	if c.bus == nil {
+		return
+	}
+
+	envelope, err := newEnvelope(c.instanceID, msg, priority)
+	if err != nil {
+		log.Errorf("failed to encode %s event for the bus: %v", msg.Type, err)
+		return
+	}
+
+	select {
+	case c.outbound <- envelope:
+	default:
+		log.Warnf("bus outbound queue full, %s event for room %s stays on this instance", msg.Type, msg.RoomID)
+	}
+}
+
+func (c *Core) runBusPublisher(ctx context.Context) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.flushOutbound(context.WithoutCancel(ctx))
+			return
+		case <-c.shutdown:
+			c.flushOutbound(context.WithoutCancel(ctx))
+			return
+		case envelope := <-c.outbound:
+			pubCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			if err := c.bus.Publish(pubCtx, envelope); err != nil {
+				log.Errorf("failed to publish event to the bus: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// flushOutbound publishes what is still queued when the core stops, other instances would
+// otherwise miss the last events of this one
+func (c *Core) flushOutbound(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	for {
+		select {
+		case envelope := <-c.outbound:
+			if err := c.bus.Publish(ctx, envelope); err != nil {
+				log.Errorf("failed to flush %d events to the bus: %v", len(c.outbound)+1, err)
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// runBusSubscriber feeds events from other instances into Run, resubscribing after a lost connection
+func (c *Core) runBusSubscriber(ctx context.Context) {
+	defer c.wg.Done()
+
+	backoff := time.Second
+	for {
+		err := c.bus.Subscribe(ctx, c.receiveFromBus)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warnf("bus subscription failed, retrying in %s: %v", backoff, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.shutdown:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (c *Core) receiveFromBus(envelope BusEnvelope) {
+	// Our own events were delivered locally before they were published
+	if envelope.InstanceID == c.instanceID {
+		return
+	}
+
+	msg, err := envelope.decode()
+	if err != nil {
+		log.Warnf("dropping undecodable bus event: %v", err)
+		return
+	}
+
+	if envelope.Priority {
+		c.broadcastPriorityLocal(msg)
+		return
+	}
+
+	select {
+	case c.remote <- msg:
+	case <-c.shutdown:
+	}
 }
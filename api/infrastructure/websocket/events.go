@@ -4,17 +4,58 @@ const (
 	MemberJoined = "member.joined"
 	MemberLeft   = "member.left"
 	MemberList   = "member.list"
+	// The connection stopped answering pings and was closed, the user is still a member
+	MemberDisconnected = "member.disconnected"
+
+	MemberMuted   = "member.muted"
+	MemberUnmuted = "member.unmuted"
 
 	MessageReceived = "message.received"
 	MessageDeleted  = "message.deleted"
 	MessageUpdated  = "message.updated"
+	// Only delivered to the mentioned member
+	Mentioned = "message.mentioned"
+	// Sent ahead of the room queue, see Core.BroadcastPriority
+	Announcement = "message.announcement"
+	// Only delivered to the room owner
+	MessageFlagged = "message.flagged"
+	// Only delivered to the sender, confirms a send_message frame
+	MessageSendAck = "message.send_ack"
+	// Follows message.received once the link in the message has been fetched
+	MessagePreviewReady = "message.preview_ready"
+
+	// Only delivered to the room owner, the upload's links stop working
+	FileQuarantined = "file.quarantined"
+	// Only delivered to the uploader while a large upload is still coming in
+	UploadProgress = "file.upload_progress"
+
+	ReactionAdded   = "reaction.added"
+	ReactionRemoved = "reaction.removed"
+
+	PresenceChanged = "presence.changed"
+
+	// Sent instead of the missed events when a reconnect's gap is no longer buffered
+	ReplayTruncated = "replay.truncated"
 
 	ErrorEvent          = "error"
 	AuthenticationError = "error.auth"
 	JoinFailed          = "error.join"
 	RateLimited         = "error.rate_limited"
 	Kicked              = "error.kicked"
+	MaintenanceMode     = "error.maintenance"
+	Muted               = "error.muted"
+	ReadOnly            = "error.read_only"
+	SlowMode            = "error.slow_mode"
+
+	RoomDeleted         = "room.deleted"
+	RoomExpired         = "room.expired"
+	RoomExpiringSoon    = "room.expiring_soon"
+	RoomExtended        = "room.extended"
+	RoomUpdated         = "room.updated"
+	RoomSettingsUpdated = "room.settings_updated"
+	// The room key changed, members fetch the new one from GET /rooms/:id/keys
+	RoomKeyRotated = "room.key_rotated"
 
-	RoomDeleted = "room.deleted"
-	RoomUpdated = "room.updated"
+	// An operator's notice, sent to every room at once
+	SystemBroadcast = "system.broadcast"
 )
@@ -0,0 +1,157 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FrameLimit caps how fast a single connection may send frames. Repeat offenders are
+// banned for a while, keyed by user so reconnecting doesn't lift it.
+type FrameLimit struct {
+	Rate          float64 // frames per second the bucket refills with
+	Burst         int
+	MaxViolations int // throttled bursts within violationWindow before a ban
+	BanDuration   time.Duration
+}
+
+// violationWindow is how long a throttled burst counts towards a ban
+const violationWindow = time.Minute
+
+func DefaultFrameLimit() FrameLimit {
+	return FrameLimit{
+		Rate:          5,
+		Burst:         10,
+		MaxViolations: 3,
+		BanDuration:   5 * time.Minute,
+	}
+}
+
+// withDefaults fills in whatever the config left unset
+func (l FrameLimit) withDefaults() FrameLimit {
+	defaults := DefaultFrameLimit()
+	if l.Rate <= 0 {
+		l.Rate = defaults.Rate
+	}
+	if l.Burst <= 0 {
+		l.Burst = defaults.Burst
+	}
+	if l.MaxViolations <= 0 {
+		l.MaxViolations = defaults.MaxViolations
+	}
+	if l.BanDuration <= 0 {
+		l.BanDuration = defaults.BanDuration
+	}
+	return l
+}
+
+// frameBucket is a token bucket owned by the client's reader, so it needs no lock
+type frameBucket struct {
+	tokens     float64
+	refilledAt time.Time
+
+	// throttled is set while frames are being dropped, the client is told once per burst
+	throttled      bool
+	violations     int
+	firstViolation time.Time
+}
+
+func newFrameBucket(limit FrameLimit, now time.Time) *frameBucket {
+	return &frameBucket{tokens: float64(limit.Burst), refilledAt: now}
+}
+
+// take spends a token. notify is set on the first frame of a throttled burst, banned once
+// that burst pushes the connection over MaxViolations.
+func (b *frameBucket) take(limit FrameLimit, now time.Time) (allowed, notify, banned bool) {
+	b.tokens = min(float64(limit.Burst), b.tokens+now.Sub(b.refilledAt).Seconds()*limit.Rate)
+	b.refilledAt = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.throttled = false
+		return true, false, false
+	}
+
+	if b.throttled {
+		return false, false, false
+	}
+	b.throttled = true
+
+	if now.Sub(b.firstViolation) > violationWindow {
+		b.violations = 0
+		b.firstViolation = now
+	}
+	b.violations++
+
+	if b.violations >= limit.MaxViolations {
+		b.violations = 0
+		return false, true, true
+	}
+	return false, true, false
+}
+
+// retryAfter is how long until the bucket holds a whole token again
+func (b *frameBucket) retryAfter(limit FrameLimit) time.Duration {
+	return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+// frameBans outlives connections so a banned user can't reconnect their way out of it
+type frameBans struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newFrameBans() *frameBans {
+	return &frameBans{until: make(map[string]time.Time)}
+}
+
+func (b *frameBans) ban(userID string, until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.until[userID] = until
+}
+
+// remaining is how much of the user's ban is left, zero when they aren't banned
+func (b *frameBans) remaining(userID string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	until, ok := b.until[userID]
+	if !ok {
+		return 0
+	}
+	if !now.Before(until) {
+		delete(b.until, userID)
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// limitFrame runs an inbound frame past the connection's bucket and the user's ban.
+// reply is set when the client should hear about it, once per throttled burst.
+func (c *Core) limitFrame(cl *Client, now time.Time) (allowed bool, reply *WSMessage) {
+	if left := c.frameBans.remaining(cl.ID, now); left > 0 {
+		if cl.frames.throttled {
+			return false, nil
+		}
+		cl.frames.throttled = true
+		return false, NewFrameBannedError(cl.RoomID, left)
+	}
+
+	allowed, notify, banned := cl.frames.take(c.frameLimit, now)
+	if allowed {
+		return true, nil
+	}
+
+	c.metrics.IncrementCounter(context.Background(), "websocket_frames_throttled")
+	if banned {
+		c.frameBans.ban(cl.ID, now.Add(c.frameLimit.BanDuration))
+		c.metrics.IncrementCounter(context.Background(), "websocket_frame_bans")
+		log.Infof("banning client %s in room %s from sending for %v", cl.ID, cl.RoomID, c.frameLimit.BanDuration)
+		return false, NewFrameBannedError(cl.RoomID, c.frameLimit.BanDuration)
+	}
+	if notify {
+		return false, NewRateLimitedError(cl.RoomID, "", cl.frames.retryAfter(c.frameLimit))
+	}
+	return false, nil
+}
@@ -0,0 +1,34 @@
+package websocket
+
+import "time"
+
+// Heartbeat controls how the server pings clients and when a silent connection is given up on
+type Heartbeat struct {
+	PingInterval   time.Duration
+	MaxMissedPongs int
+}
+
+func DefaultHeartbeat() Heartbeat {
+	return Heartbeat{
+		PingInterval:   30 * time.Second,
+		MaxMissedPongs: 2,
+	}
+}
+
+// withDefaults fills in whatever the config left unset
+func (h Heartbeat) withDefaults() Heartbeat {
+	defaults := DefaultHeartbeat()
+	if h.PingInterval <= 0 {
+		h.PingInterval = defaults.PingInterval
+	}
+	if h.MaxMissedPongs <= 0 {
+		h.MaxMissedPongs = defaults.MaxMissedPongs
+	}
+	return h
+}
+
+// readTimeout is a backstop for connections that stop reading altogether,
+// the missed pong count normally catches them first
+func (h Heartbeat) readTimeout() time.Duration {
+	return h.PingInterval * time.Duration(h.MaxMissedPongs+1)
+}
@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+const SendMessageFrameType = "send_message"
+
+// SendMessageFrame is a chat message written to the socket instead of POSTed.
+// RequestID is the client's own reference, it comes back on the ack or error frame.
+type SendMessageFrame struct {
+	Type            string   `json:"type"`
+	RequestID       string   `json:"requestId,omitempty"`
+	Content         string   `json:"content"`
+	Encrypted       bool     `json:"encrypted,omitempty"`
+	KeyVersion      int      `json:"keyVersion,omitempty"`
+	ParentMessageID string   `json:"parentMessageId,omitempty"`
+	Mentions        []string `json:"mentions,omitempty"`
+}
+
+// InboundHandler stores and fans out a message sent over the socket, returning the ack or error frame
+// for the sender. The message controller implements it so both send paths behave the same.
+type InboundHandler interface {
+	SendFromSocket(ctx context.Context, roomID, userID, username string, frame SendMessageFrame) *WSMessage
+}
+
+// SendLimiter reports whether the user may send another message, and if not, when to try again.
+// An error is only logged, the answer still stands.
+type SendLimiter func(ctx context.Context, userID string) (allowed bool, retryAfter time.Duration, err error)
+
+// EnableInboundSend turns on send_message frames. The handler lives in presentation,
+// which is built after Core, hence a setter rather than a constructor argument.
+func (c *Core) EnableInboundSend(handler InboundHandler, limiter SendLimiter) {
+	c.inbound = handler
+	c.sendLimiter = limiter
+}
+
+func parseSendFrame(raw []byte) (SendMessageFrame, bool) {
+	var frame SendMessageFrame
+	if json.Unmarshal(raw, &frame) != nil || frame.Type != SendMessageFrameType {
+		return SendMessageFrame{}, false
+	}
+	return frame, true
+}
+
+// plainTextFrame is a frame that isn't a send_message taken as the content of one
+func plainTextFrame(raw []byte) SendMessageFrame {
+	return SendMessageFrame{Type: SendMessageFrameType, Content: string(raw)}
+}
+
+func (c *Core) handleSendFrame(cl *Client, frame SendMessageFrame) *WSMessage {
+	if c.inbound == nil {
+		return NewSendFailed(cl.RoomID, frame.RequestID, "UNSUPPORTED", "sending over the socket is not enabled", 0)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if c.sendLimiter != nil {
+		allowed, retryAfter, err := c.sendLimiter(ctx, cl.ID)
+		if err != nil {
+			// The limiter still decided, without the counts of the other instances
+			log.Warnf("send rate limit for client %s checked locally: %v", cl.ID, err)
+		}
+		if !allowed {
+			return NewRateLimitedError(cl.RoomID, frame.RequestID, retryAfter)
+		}
+	}
+
+	return c.inbound.SendFromSocket(ctx, cl.RoomID, cl.ID, cl.Username, frame)
+}
+
+func NewMessageSendAck(roomID, requestID, messageID, timestamp string) *WSMessage {
+	return &WSMessage{
+		Type:   MessageSendAck,
+		RoomID: roomID,
+		Data: SendAckPayload{
+			RequestID: requestID,
+			MessageID: messageID,
+			Timestamp: timestamp,
+		},
+	}
+}
+
+func NewSendFailed(roomID, requestID, code, message string, retryAfter time.Duration) *WSMessage {
+	return &WSMessage{
+		Type:   ErrorEvent,
+		RoomID: roomID,
+		Data: FrameErrorPayload{
+			RequestID:  requestID,
+			Code:       code,
+			Message:    message,
+			RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+		},
+	}
+}
+
+func NewRateLimitedError(roomID, requestID string, retryAfter time.Duration) *WSMessage {
+	return &WSMessage{
+		Type:   RateLimited,
+		RoomID: roomID,
+		Data: FrameErrorPayload{
+			RequestID:  requestID,
+			Code:       "RATE_LIMITED",
+			Message:    "you are sending messages too quickly",
+			RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+		},
+	}
+}
+
+func NewFrameBannedError(roomID string, retryAfter time.Duration) *WSMessage {
+	return &WSMessage{
+		Type:   RateLimited,
+		RoomID: roomID,
+		Data: FrameErrorPayload{
+			Code:       "RATE_LIMITED",
+			Message:    "you have been temporarily blocked from sending for flooding the room",
+			RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+		},
+	}
+}
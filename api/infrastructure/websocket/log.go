@@ -0,0 +1,14 @@
+package websocket
+
+import (
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// log writes to stderr until SetLogger hands over the ws subsystem logger
+var log = zap.Must(zap.NewProduction()).Sugar()
+
+// SetLogger is called once at startup, before anything in the package runs
+func SetLogger(l *logger.Logger) {
+	log = l.Log.Sugar()
+}
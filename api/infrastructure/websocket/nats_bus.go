@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsBusSubject is plain NATS, room events are live traffic and a missed one is covered by replay
+const natsBusSubject = "visper.ws.events"
+
+type natsBus struct {
+	conn *nats.Conn
+}
+
+func NewNATSBus(conn *nats.Conn) Bus {
+	return &natsBus{conn: conn}
+}
+
+func (b *natsBus) Publish(_ context.Context, envelope BusEnvelope) error {
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bus envelope: %w", err)
+	}
+
+	return b.conn.Publish(natsBusSubject, payload)
+}
+
+func (b *natsBus) Subscribe(ctx context.Context, handler func(BusEnvelope)) error {
+	messages := make(chan *nats.Msg, 256)
+	sub, err := b.conn.ChanSubscribe(natsBusSubject, messages)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", natsBusSubject, err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m := <-messages:
+			var envelope BusEnvelope
+			if err := json.Unmarshal(m.Data, &envelope); err != nil {
+				log.Warnf("dropping malformed bus envelope: %v", err)
+				continue
+			}
+			handler(envelope)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package websocket
 
 import (
-	"log"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -61,7 +60,7 @@ func (c *NotificationClient) ReadMessage(core *NotificationCore) {
 		_, _, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("Notification WebSocket error for user %s: %v", c.UserID, err)
+				log.Debugf("Notification WebSocket error for user %s: %v", c.UserID, err)
 			}
 			break
 		}
@@ -86,7 +85,7 @@ func (c *NotificationClient) WriteMessage() {
 			}
 
 			if err := c.conn.WriteJSON(message); err != nil {
-				log.Printf("Failed to write notification to user %s: %v", c.UserID, err)
+				log.Warnf("Failed to write notification to user %s: %v", c.UserID, err)
 				return
 			}
 
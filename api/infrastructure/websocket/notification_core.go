@@ -2,7 +2,6 @@ package websocket
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"sync"
 
@@ -38,7 +37,7 @@ func (nc *NotificationCore) Run(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("NotificationCore shutting down...")
+			log.Info("NotificationCore shutting down...")
 			return
 		case client := <-nc.register:
 			nc.mu.Lock()
@@ -47,7 +46,7 @@ func (nc *NotificationCore) Run(ctx context.Context) {
 			}
 			nc.clients[client.UserID] = client
 			nc.mu.Unlock()
-			log.Printf("User %s registered for notifications (total: %d)", client.UserID, len(nc.clients))
+			log.Debugf("User %s registered for notifications (total: %d)", client.UserID, len(nc.clients))
 
 		case client := <-nc.unregister:
 			nc.mu.Lock()
@@ -56,11 +55,19 @@ func (nc *NotificationCore) Run(ctx context.Context) {
 				close(client.send)
 			}
 			nc.mu.Unlock()
-			log.Printf("User %s unregistered from notifications (total: %d)", client.UserID, len(nc.clients))
+			log.Debugf("User %s unregistered from notifications (total: %d)", client.UserID, len(nc.clients))
 		}
 	}
 }
 
+func (nc *NotificationCore) IsConnected(userID string) bool {
+	nc.mu.RLock()
+	defer nc.mu.RUnlock()
+
+	_, exists := nc.clients[userID]
+	return exists
+}
+
 func (nc *NotificationCore) NotifyUser(userID string, message *NotificationMessage) {
 	nc.mu.RLock()
 	client, exists := nc.clients[userID]
@@ -69,15 +76,20 @@ func (nc *NotificationCore) NotifyUser(userID string, message *NotificationMessa
 	if exists {
 		select {
 		case client.send <- message:
-			log.Printf("Notification sent to user %s", userID)
+			log.Debugf("Notification sent to user %s", userID)
 		default:
-			log.Printf("Failed to send notification to user %s: channel full", userID)
+			log.Warnf("Failed to send notification to user %s: channel full", userID)
 		}
 	} else {
-		log.Printf("User %s not connected to notification stream", userID)
+		log.Debugf("User %s not connected to notification stream", userID)
 	}
 }
 
+// RestrictOrigins applies the same origin policy as room sockets, call it before serving connections
+func (nc *NotificationCore) RestrictOrigins(policy *OriginPolicy) {
+	nc.upgrader.CheckOrigin = policy.Allows
+}
+
 func (nc *NotificationCore) Upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
 	return nc.upgrader.Upgrade(w, r, nil)
 }
@@ -97,8 +109,8 @@ func (nc *NotificationCore) cleanup() {
 	for userID, client := range nc.clients {
 		close(client.send)
 		client.conn.Close()
-		log.Printf("Closed notification connection for user %s", userID)
+		log.Debugf("Closed notification connection for user %s", userID)
 	}
 	nc.clients = make(map[string]*NotificationClient)
-	log.Println("NotificationCore cleanup completed")
+	log.Info("NotificationCore cleanup completed")
 }
@@ -0,0 +1,42 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+)
+
+// OriginPolicy decides which browser origins may open a socket. Browsers always send Origin
+// on a WebSocket handshake, so a missing one is a native client and is let through.
+type OriginPolicy struct {
+	allowed map[string]bool
+	any     bool
+}
+
+// NewOriginPolicy allows the listed origins, an empty list or "*" allows every origin
+func NewOriginPolicy(allowed []string) *OriginPolicy {
+	policy := &OriginPolicy{allowed: make(map[string]bool), any: len(allowed) == 0}
+	for _, origin := range allowed {
+		if origin == "*" {
+			policy.any = true
+		}
+		policy.allowed[normalizeOrigin(origin)] = true
+	}
+	return policy
+}
+
+func (p *OriginPolicy) Allows(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.any {
+		return true
+	}
+	return p.allowed[normalizeOrigin(origin)]
+}
+
+// Restricted reports whether the policy turns anything away
+func (p *OriginPolicy) Restricted() bool {
+	return !p.any
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimRight(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
@@ -0,0 +1,153 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultPresenceIdleTimeout = 5 * time.Minute
+
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceAway    PresenceStatus = "away"
+	PresenceOffline PresenceStatus = "offline"
+)
+
+type PresenceEntry struct {
+	UserID       string
+	Username     string
+	Status       PresenceStatus
+	LastActiveAt time.Time
+}
+
+type presenceEntry struct {
+	PresenceEntry
+	connections int
+}
+
+// PresenceTracker derives member status from open connections and their last activity.
+// Users without a tracked entry are offline.
+type PresenceTracker struct {
+	idleTimeout time.Duration
+	rooms       map[string]map[string]*presenceEntry
+	mu          sync.RWMutex
+}
+
+func NewPresenceTracker(idleTimeout time.Duration) *PresenceTracker {
+	if idleTimeout <= 0 {
+		idleTimeout = defaultPresenceIdleTimeout
+	}
+
+	return &PresenceTracker{
+		idleTimeout: idleTimeout,
+		rooms:       make(map[string]map[string]*presenceEntry),
+	}
+}
+
+// Connect returns the new entry when the user was not already online
+func (p *PresenceTracker) Connect(roomID, userID, username string) (PresenceEntry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	members, ok := p.rooms[roomID]
+	if !ok {
+		members = make(map[string]*presenceEntry)
+		p.rooms[roomID] = members
+	}
+
+	entry, ok := members[userID]
+	if !ok {
+		entry = &presenceEntry{PresenceEntry: PresenceEntry{UserID: userID, Username: username}}
+		members[userID] = entry
+	}
+
+	entry.connections++
+	entry.LastActiveAt = time.Now()
+
+	changed := entry.Status != PresenceOnline
+	entry.Status = PresenceOnline
+	return entry.PresenceEntry, changed
+}
+
+// Disconnect returns the offline entry once the user's last connection is gone
+func (p *PresenceTracker) Disconnect(roomID, userID string) (PresenceEntry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	members, ok := p.rooms[roomID]
+	if !ok {
+		return PresenceEntry{}, false
+	}
+
+	entry, ok := members[userID]
+	if !ok {
+		return PresenceEntry{}, false
+	}
+
+	entry.connections--
+	if entry.connections > 0 {
+		return entry.PresenceEntry, false
+	}
+
+	delete(members, userID)
+	if len(members) == 0 {
+		delete(p.rooms, roomID)
+	}
+
+	entry.Status = PresenceOffline
+	return entry.PresenceEntry, true
+}
+
+// Touch records activity and returns the entry when it brings an away user back online
+func (p *PresenceTracker) Touch(roomID, userID string) (PresenceEntry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.rooms[roomID][userID]
+	if !ok {
+		return PresenceEntry{}, false
+	}
+
+	entry.LastActiveAt = time.Now()
+	if entry.Status == PresenceOnline {
+		return entry.PresenceEntry, false
+	}
+
+	entry.Status = PresenceOnline
+	return entry.PresenceEntry, true
+}
+
+// Sweep marks idle users as away and returns the entries that changed, keyed by room
+func (p *PresenceTracker) Sweep(now time.Time) map[string][]PresenceEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changes := make(map[string][]PresenceEntry)
+	for roomID, members := range p.rooms {
+		for _, entry := range members {
+			if entry.Status == PresenceOnline && now.Sub(entry.LastActiveAt) >= p.idleTimeout {
+				entry.Status = PresenceAway
+				changes[roomID] = append(changes[roomID], entry.PresenceEntry)
+			}
+		}
+	}
+
+	return changes
+}
+
+func (p *PresenceTracker) Room(roomID string) map[string]PresenceEntry {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	members := make(map[string]PresenceEntry, len(p.rooms[roomID]))
+	for userID, entry := range p.rooms[roomID] {
+		members[userID] = entry.PresenceEntry
+	}
+	return members
+}
+
+func (p *PresenceTracker) IdleTimeout() time.Duration {
+	return p.idleTimeout
+}
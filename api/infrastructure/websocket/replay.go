@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// replayBufferSize is how many events a reconnecting client can catch up on per room
+	replayBufferSize = 256
+	// replayRetention drops a room's buffer once nothing has happened in it for this long
+	replayRetention = 15 * time.Minute
+)
+
+type replayRoom struct {
+	events    []*WSMessage // ring, event seq lives at (seq-1) % size
+	seq       uint64
+	updatedAt time.Time
+}
+
+// ReplayBuffer numbers every room event and keeps the most recent ones, so a client that
+// reconnects with the last sequence it saw gets the gap instead of silently missing it.
+// Sequence numbers only mean something to the buffer that handed them out, so events also
+// carry the buffer's epoch and a client resuming from another instance or a restart is
+// told its gap was truncated.
+type ReplayBuffer struct {
+	mu    sync.Mutex
+	rooms map[string]*replayRoom
+	size  int
+	epoch string
+}
+
+func NewReplayBuffer(size int) *ReplayBuffer {
+	return &ReplayBuffer{
+		rooms: make(map[string]*replayRoom),
+		size:  size,
+		epoch: rand.Text()[:16],
+	}
+}
+
+// Epoch names this buffer's sequence numbering
+func (b *ReplayBuffer) Epoch() string {
+	return b.epoch
+}
+
+// Record stamps the event with the buffer's epoch and the room's next sequence number and keeps it for replay.
+// Presence is left out, a reconnecting client gets a fresh picture from the live events anyway.
+func (b *ReplayBuffer) Record(msg *WSMessage) {
+	if msg.Type == PresenceChanged {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	room, ok := b.rooms[msg.RoomID]
+	if !ok {
+		room = &replayRoom{events: make([]*WSMessage, 0, b.size)}
+		b.rooms[msg.RoomID] = room
+	}
+
+	room.seq++
+	room.updatedAt = time.Now()
+	msg.Seq = room.seq
+	msg.Epoch = b.epoch
+
+	if len(room.events) < b.size {
+		room.events = append(room.events, msg)
+		return
+	}
+	room.events[(room.seq-1)%uint64(b.size)] = msg
+}
+
+// Since returns the events after lastSeq of epoch that userID may see. complete is false when
+// epoch isn't this buffer's, or part of the gap was already evicted.
+func (b *ReplayBuffer) Since(roomID, userID, epoch string, lastSeq uint64) (events []*WSMessage, complete bool) {
+	if epoch != b.epoch {
+		return nil, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	room, ok := b.rooms[roomID]
+	if !ok {
+		return nil, lastSeq == 0
+	}
+	if lastSeq > room.seq {
+		return nil, false
+	}
+
+	oldest := room.seq - uint64(len(room.events)) + 1
+	if lastSeq+1 < oldest {
+		return nil, false
+	}
+
+	for seq := lastSeq + 1; seq <= room.seq; seq++ {
+		msg := room.events[(seq-1)%uint64(b.size)]
+		if msg.TargetUserID != "" && msg.TargetUserID != userID {
+			continue
+		}
+		events = append(events, msg)
+	}
+
+	return events, true
+}
+
+// LastSeq is the sequence number of the room's newest event, zero when nothing was recorded
+func (b *ReplayBuffer) LastSeq(roomID string) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if room, ok := b.rooms[roomID]; ok {
+		return room.seq
+	}
+	return 0
+}
+
+// Sweep forgets rooms that have been quiet for longer than the retention window
+func (b *ReplayBuffer) Sweep(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for roomID, room := range b.rooms {
+		if now.Sub(room.updatedAt) > replayRetention {
+			delete(b.rooms, roomID)
+		}
+	}
+}
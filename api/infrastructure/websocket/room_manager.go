@@ -2,7 +2,6 @@ package websocket
 
 import (
 	"errors"
-	"log"
 	"net/http"
 	"sync"
 
@@ -12,14 +11,6 @@ import (
 var (
 	ErrRoomNotFound   = errors.New("room not found")
 	ErrClientNotFound = errors.New("client not found")
-
-	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-	}
 )
 
 type WSRoom struct {
@@ -32,18 +23,36 @@ type WSRoom struct {
 }
 
 type RoomManager struct {
-	rooms map[string]*WSRoom
-	mu    sync.RWMutex
+	rooms    map[string]*WSRoom
+	origins  *OriginPolicy
+	upgrader websocket.Upgrader
+	mu       sync.RWMutex
 }
 
 func NewRoomManager() *RoomManager {
-	return &RoomManager{
-		rooms: make(map[string]*WSRoom),
+	rm := &RoomManager{
+		rooms:   make(map[string]*WSRoom),
+		origins: NewOriginPolicy(nil),
 	}
+	rm.upgrader = websocket.Upgrader{
+		CheckOrigin:     rm.AllowsOrigin,
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+	return rm
+}
+
+// RestrictOrigins replaces the origin policy, call it before serving connections
+func (rm *RoomManager) RestrictOrigins(policy *OriginPolicy) {
+	rm.origins = policy
+}
+
+func (rm *RoomManager) AllowsOrigin(r *http.Request) bool {
+	return rm.origins.Allows(r)
 }
 
 func (rm *RoomManager) Upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := rm.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -111,6 +120,32 @@ func (rm *RoomManager) GetRoom(roomID string) (*WSRoom, bool) {
 	return r, ok
 }
 
+func (rm *RoomManager) ClientCount() int {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	count := 0
+	for _, room := range rm.rooms {
+		room.mu.RLock()
+		count += len(room.Clients)
+		room.mu.RUnlock()
+	}
+	return count
+}
+
+func (rm *RoomManager) HasClient(roomID, clientID string) bool {
+	room, ok := rm.GetRoom(roomID)
+	if !ok {
+		return false
+	}
+
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	_, exists := room.Clients[clientID]
+	return exists
+}
+
 func (rm *RoomManager) BroadcastToRoom(msg *WSMessage) error {
 	rm.mu.RLock()
 	room, ok := rm.rooms[msg.RoomID]
@@ -120,19 +155,24 @@ func (rm *RoomManager) BroadcastToRoom(msg *WSMessage) error {
 		return ErrRoomNotFound
 	}
 
-	room.mu.Lock()
-	room.History = append(room.History, msg)
+	if msg.TargetUserID == "" {
+		room.mu.Lock()
+		room.History = append(room.History, msg)
 
-	// Limit history size to prevent memory issues
-	if len(room.History) > 1000 {
-		room.History = room.History[len(room.History)-1000:]
+		// Limit history size to prevent memory issues
+		if len(room.History) > 1000 {
+			room.History = room.History[len(room.History)-1000:]
+		}
+		room.mu.Unlock()
 	}
-	room.mu.Unlock()
 
 	// Create snapshot of clients to avoid holding lock during broadcast
 	room.mu.RLock()
 	clients := make([]*Client, 0, len(room.Clients))
 	for _, cl := range room.Clients {
+		if msg.TargetUserID != "" && cl.ID != msg.TargetUserID {
+			continue
+		}
 		clients = append(clients, cl)
 	}
 	room.mu.RUnlock()
@@ -146,13 +186,14 @@ func (rm *RoomManager) BroadcastToRoom(msg *WSMessage) error {
 		case cl.Message <- msg:
 		default:
 			// Client buffer full – drop message and log
-			log.Printf("client %s buffer full, dropping message", cl.ID)
+			log.Warnf("client %s buffer full, dropping message", cl.ID)
 		}
 	}
 
 	return nil
 }
 
+// DisconnectAll sends every client away, it's only used when the server shuts down
 func (rm *RoomManager) DisconnectAll() {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
@@ -160,7 +201,7 @@ func (rm *RoomManager) DisconnectAll() {
 	for _, room := range rm.rooms {
 		room.mu.Lock()
 		for _, cl := range room.Clients {
-			cl.Close()
+			cl.GoAway()
 		}
 		room.mu.Unlock()
 	}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The global provider is used so the core doesn't depend on how tracing was set up,
+// it is a no-op until one is configured
+var tracer = otel.Tracer("github.com/hilthontt/visper/api/websocket")
+
+// queuedMessage is an event waiting in a room channel, span covers it from being
+// dispatched to being handed to every client
+type queuedMessage struct {
+	msg      *WSMessage
+	queuedAt time.Time
+	span     trace.Span
+}
+
+func newQueuedMessage(msg *WSMessage) queuedMessage {
+	now := time.Now()
+	_, span := tracer.Start(context.Background(), "websocket.broadcast",
+		trace.WithTimestamp(now),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("websocket.room_id", msg.RoomID),
+			attribute.String("websocket.event_type", msg.Type),
+			attribute.Int64("websocket.seq", int64(msg.Seq)),
+		),
+	)
+
+	return queuedMessage{msg: msg, queuedAt: now, span: span}
+}
+
+// dequeued marks the room goroutine picking the event up, the gap is time spent behind
+// the room's earlier events
+func (q queuedMessage) dequeued() {
+	q.span.AddEvent("dequeued", trace.WithAttributes(
+		attribute.Float64("websocket.queue_latency_ms", msSince(q.queuedAt)),
+	))
+}
+
+func (q queuedMessage) delivered(err error) {
+	q.span.AddEvent("delivered", trace.WithAttributes(
+		attribute.Float64("websocket.broadcast_latency_ms", msSince(q.queuedAt)),
+	))
+	if err != nil {
+		q.span.RecordError(err)
+		q.span.SetStatus(codes.Error, err.Error())
+	}
+	q.span.End()
+}
+
+func (q queuedMessage) dropped(reason string) {
+	q.span.AddEvent("dropped", trace.WithAttributes(attribute.String("websocket.drop_reason", reason)))
+	q.span.SetStatus(codes.Error, reason)
+	q.span.End()
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
@@ -0,0 +1,84 @@
+package workerpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// taskTimeout bounds a single task so a stuck one can't hold a worker forever
+const taskTimeout = time.Minute
+
+type task struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Pool runs background tasks on a fixed number of goroutines. Submit never blocks,
+// work is dropped once the queue is full so a burst of uploads can't pile up memory.
+type Pool struct {
+	tasks   chan task
+	workers int
+	logger  *logger.Logger
+	wg      sync.WaitGroup
+}
+
+func New(workers, queueSize int, logger *logger.Logger) *Pool {
+	return &Pool{
+		tasks:   make(chan task, queueSize),
+		workers: max(workers, 1),
+		logger:  logger,
+	}
+}
+
+// Start launches the workers, they stop once ctx is done. Tasks submitted before Start wait in the queue.
+func (p *Pool) Start(ctx context.Context) {
+	for range p.workers {
+		p.wg.Go(func() { p.work(ctx) })
+	}
+}
+
+// Wait blocks until the workers have stopped, the tasks they were running included
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+// Submit queues the task and reports whether there was room for it
+func (p *Pool) Submit(name string, run func(ctx context.Context) error) bool {
+	select {
+	case p.tasks <- task{name: name, run: run}:
+		return true
+	default:
+		p.logger.Warn("worker pool queue full, dropping task", zap.String("task", name))
+		return false
+	}
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-p.tasks:
+			p.run(ctx, t)
+		}
+	}
+}
+
+func (p *Pool) run(ctx context.Context, t task) {
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.logger.Error("worker pool task panicked", zap.String("task", t.name), zap.Any("panic", r))
+		}
+	}()
+
+	if err := t.run(ctx); err != nil {
+		p.logger.Warn("worker pool task failed", zap.String("task", t.name), zap.Error(err))
+	}
+}
@@ -0,0 +1,357 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/admin"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/broker"
+	"github.com/hilthontt/visper/api/infrastructure/maintenance"
+	"github.com/hilthontt/visper/api/infrastructure/websocket"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+type AdminController interface {
+	ListRooms(ctx *gin.Context)
+	ForceDeleteRoom(ctx *gin.Context)
+	GetUser(ctx *gin.Context)
+	Broadcast(ctx *gin.Context)
+	BanUser(ctx *gin.Context)
+	UnbanUser(ctx *gin.Context)
+	ListBans(ctx *gin.Context)
+	ListRateLimitBlocks(ctx *gin.Context)
+	ClearRateLimitBlock(ctx *gin.Context)
+	ListRateLimitExemptions(ctx *gin.Context)
+	AddRateLimitExemption(ctx *gin.Context)
+	RemoveRateLimitExemption(ctx *gin.Context)
+	ListFeatureFlags(ctx *gin.Context)
+	SetFeatureFlag(ctx *gin.Context)
+	GetMaintenance(ctx *gin.Context)
+	SetMaintenance(ctx *gin.Context)
+	ListTopics(ctx *gin.Context)
+	CreateTopic(ctx *gin.Context)
+	DeleteTopic(ctx *gin.Context)
+	ListConsumerGroups(ctx *gin.Context)
+	ListAuditLogs(ctx *gin.Context)
+}
+
+type adminController struct {
+	usecase     admin.AdminUseCase
+	wsCore      *websocket.Core
+	maintenance *maintenance.Mode
+	broker      *broker.Broker
+	allowlist   *middlewares.RateLimitAllowlist
+	flags       *middlewares.FeatureFlagStore
+}
+
+// NewAdminController takes a nil broker when events don't go through the embedded one
+func NewAdminController(
+	usecase admin.AdminUseCase,
+	wsCore *websocket.Core,
+	maintenance *maintenance.Mode,
+	broker *broker.Broker,
+	allowlist *middlewares.RateLimitAllowlist,
+	flags *middlewares.FeatureFlagStore,
+) AdminController {
+	return &adminController{
+		usecase:     usecase,
+		wsCore:      wsCore,
+		maintenance: maintenance,
+		broker:      broker,
+		allowlist:   allowlist,
+		flags:       flags,
+	}
+}
+
+func (c *adminController) ListRooms(ctx *gin.Context) {
+	summaries, err := c.usecase.ListRooms(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "fetch_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	rooms := make([]RoomSummaryResponse, len(summaries))
+	for i, summary := range summaries {
+		expiresAt := summary.Room.CreatedAt.Add(summary.Room.Expiry)
+		if summary.Room.Expiry == 0 {
+			expiresAt = time.Time{}
+		}
+
+		rooms[i] = RoomSummaryResponse{
+			ID:           summary.Room.ID,
+			JoinCode:     summary.Room.JoinCode,
+			OwnerID:      summary.Room.Owner.ID,
+			CreatedAt:    summary.Room.CreatedAt,
+			ExpiresAt:    expiresAt,
+			MemberCount:  summary.MemberCount,
+			MessageCount: summary.MessageCount,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, RoomsResponse{
+		Rooms: rooms,
+		Count: len(rooms),
+	})
+}
+
+func (c *adminController) ForceDeleteRoom(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	if err := c.usecase.ForceDeleteRoom(ctx.Request.Context(), roomID); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewRoomDeleted(roomID)
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "room force deleted successfully",
+	})
+}
+
+func (c *adminController) GetUser(ctx *gin.Context) {
+	info, err := c.usecase.GetUserInfo(ctx.Request.Context(), ctx.Param("userId"))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := UserInfoResponse{
+		ID:         info.User.ID,
+		Username:   info.User.Username,
+		IsGuest:    info.User.IsGuest,
+		CreatedAt:  info.User.CreatedAt,
+		OwnedRooms: info.OwnedRooms,
+		MemberOf:   info.MemberOf,
+		BotCount:   info.BotCount,
+	}
+	if info.Ban != nil {
+		ban := toBanResponse(info.Ban)
+		response.Ban = &ban
+	}
+	if info.Identity != nil {
+		response.Identity = &IdentityRef{
+			Issuer:      info.Identity.Issuer,
+			Subject:     info.Identity.Subject,
+			Email:       info.Identity.Email,
+			Admin:       info.Identity.Admin,
+			LastLoginAt: info.Identity.LastLoginAt,
+		}
+	}
+	// Only connections to this instance are seen
+	for _, roomID := range append(info.OwnedRooms, info.MemberOf...) {
+		if c.wsCore.IsUserInRoom(roomID, info.User.ID) {
+			response.Online = true
+			break
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// Broadcast sends an operator's notice to every room, ahead of their regular traffic
+func (c *adminController) Broadcast(ctx *gin.Context) {
+	var req BroadcastRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+	if req.Level == "" {
+		req.Level = "info"
+	}
+
+	roomIDs, err := c.usecase.ListRoomIDs(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "fetch_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	sentAt := time.Now()
+	for _, roomID := range roomIDs {
+		c.wsCore.BroadcastPriority(websocket.NewSystemBroadcast(roomID, req.Message, req.Level, sentAt))
+	}
+
+	ctx.JSON(http.StatusOK, BroadcastResponse{
+		Level: req.Level,
+		Rooms: len(roomIDs),
+	})
+}
+
+func (c *adminController) BanUser(ctx *gin.Context) {
+	userID := ctx.Param("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "user ID is required"),
+		})
+		return
+	}
+
+	var req BanUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	duration := time.Duration(req.DurationHrs) * time.Hour
+
+	ban, err := c.usecase.BanUser(ctx.Request.Context(), userID, req.Reason, duration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "ban_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, toBanResponse(ban))
+}
+
+func (c *adminController) UnbanUser(ctx *gin.Context) {
+	userID := ctx.Param("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "user ID is required"),
+		})
+		return
+	}
+
+	if err := c.usecase.UnbanUser(ctx.Request.Context(), userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "unban_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "user unbanned successfully",
+	})
+}
+
+func (c *adminController) ListBans(ctx *gin.Context) {
+	bans, err := c.usecase.ListBans(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "fetch_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	response := make([]BanResponse, len(bans))
+	for i, ban := range bans {
+		response[i] = toBanResponse(ban)
+	}
+
+	ctx.JSON(http.StatusOK, BansResponse{
+		Bans:  response,
+		Count: len(response),
+	})
+}
+
+func (c *adminController) ListRateLimitBlocks(ctx *gin.Context) {
+	blocks, err := c.usecase.ListRateLimitBlocks(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "fetch_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	response := make([]RateLimitBlockResponse, len(blocks))
+	for i, block := range blocks {
+		response[i] = RateLimitBlockResponse{
+			UserID:           block.UserID,
+			RemainingSeconds: int(block.Remaining.Seconds()),
+		}
+	}
+
+	ctx.JSON(http.StatusOK, RateLimitBlocksResponse{
+		Blocks: response,
+		Count:  len(response),
+	})
+}
+
+func (c *adminController) ClearRateLimitBlock(ctx *gin.Context) {
+	userID := ctx.Param("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "user ID is required"),
+		})
+		return
+	}
+
+	if err := c.usecase.ClearRateLimitBlock(ctx.Request.Context(), userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "clear_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "rate limit block cleared successfully",
+	})
+}
+
+func (c *adminController) GetMaintenance(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.maintenance.State())
+}
+
+func (c *adminController) SetMaintenance(ctx *gin.Context) {
+	var req SetMaintenanceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	state := c.maintenance.Set(*req.Enabled, req.Message)
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "maintenance mode updated successfully",
+		Data:    state,
+	})
+}
+
+func toBanResponse(ban *model.Ban) BanResponse {
+	response := BanResponse{
+		UserID:   ban.UserID,
+		Reason:   ban.Reason,
+		BannedAt: ban.BannedAt,
+	}
+
+	if !ban.IsPermanent() {
+		expiresAt := ban.ExpiresAt
+		response.ExpiresAt = &expiresAt
+	}
+
+	return response
+}
@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+// ListAuditLogs filters by ?user_id=, room_id=, type= and an RFC 3339 since/until range
+func (c *adminController) ListAuditLogs(ctx *gin.Context) {
+	filter := repository.AuditLogFilter{
+		UserID:    ctx.Query("user_id"),
+		RoomID:    ctx.Query("room_id"),
+		EventType: ctx.Query("type"),
+	}
+
+	var err error
+	if filter.Since, err = parseQueryTime(ctx, "since"); err != nil {
+		invalidAuditQuery(ctx, "invalid since, expected an RFC 3339 time")
+		return
+	}
+	if filter.Until, err = parseQueryTime(ctx, "until"); err != nil {
+		invalidAuditQuery(ctx, "invalid until, expected an RFC 3339 time")
+		return
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			invalidAuditQuery(ctx, "invalid limit")
+			return
+		}
+	}
+
+	entries, err := c.usecase.ListAuditLogs(ctx.Request.Context(), filter)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	logs := make([]AuditLogResponse, len(entries))
+	for i, entry := range entries {
+		logs[i] = toAuditLogResponse(entry)
+	}
+
+	ctx.JSON(http.StatusOK, AuditLogsResponse{
+		Logs:  logs,
+		Count: len(logs),
+	})
+}
+
+func invalidAuditQuery(ctx *gin.Context, message string) {
+	ctx.JSON(http.StatusBadRequest, ErrorResponse{
+		Error:   "invalid_request",
+		Message: middlewares.Localize(ctx, message),
+	})
+}
+
+func parseQueryTime(ctx *gin.Context, key string) (time.Time, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func toAuditLogResponse(entry model.AuditLog) AuditLogResponse {
+	response := AuditLogResponse{
+		EventID:   entry.EventID,
+		EventType: entry.EventType,
+		UserID:    entry.UserID,
+		RoomID:    entry.RoomID.String,
+		Payload:   json.RawMessage(entry.Payload),
+		Success:   entry.Success,
+		Error:     entry.ErrorMessage.String,
+		CreatedAt: entry.CreatedAt,
+	}
+	if len(response.Payload) == 0 {
+		response.Payload = json.RawMessage("{}")
+	}
+	return response
+}
@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/broker"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+// brokerAvailable answers for the broker endpoints when events don't go through an
+// embedded broker, a remote broker or JetStream have their own tooling
+func (c *adminController) brokerAvailable(ctx *gin.Context) bool {
+	if c.broker != nil {
+		return true
+	}
+
+	ctx.JSON(http.StatusServiceUnavailable, ErrorResponse{
+		Error:   "broker_unavailable",
+		Message: middlewares.Localize(ctx, "this instance doesn't run the embedded broker"),
+	})
+	return false
+}
+
+func (c *adminController) ListTopics(ctx *gin.Context) {
+	if !c.brokerAvailable(ctx) {
+		return
+	}
+
+	topics := c.broker.DescribeTopics()
+
+	response := make([]TopicResponse, len(topics))
+	for i, topic := range topics {
+		response[i] = TopicResponse{
+			Name:       topic.Name,
+			Partitions: make([]PartitionResponse, len(topic.Partitions)),
+		}
+		for j, partition := range topic.Partitions {
+			response[i].Partitions[j] = PartitionResponse{
+				ID:        partition.ID,
+				SizeBytes: partition.SizeBytes,
+				Leader:    partition.Leader,
+			}
+			response[i].SizeBytes += partition.SizeBytes
+		}
+	}
+
+	ctx.JSON(http.StatusOK, TopicsResponse{
+		Topics: response,
+		Count:  len(response),
+	})
+}
+
+func (c *adminController) CreateTopic(ctx *gin.Context) {
+	if !c.brokerAvailable(ctx) {
+		return
+	}
+
+	var req CreateTopicRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	if err := c.broker.CreateTopic(req.Name, req.Partitions); err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "already exists") {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, ErrorResponse{
+			Error:   "create_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, SuccessResponse{
+		Message: "topic created successfully",
+	})
+}
+
+func (c *adminController) DeleteTopic(ctx *gin.Context) {
+	if !c.brokerAvailable(ctx) {
+		return
+	}
+
+	name := ctx.Param("name")
+	if err := c.broker.DeleteTopic(name); err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "does not exist") {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, ErrorResponse{
+			Error:   "delete_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "topic deleted successfully",
+	})
+}
+
+func (c *adminController) ListConsumerGroups(ctx *gin.Context) {
+	if !c.brokerAvailable(ctx) {
+		return
+	}
+
+	groups := c.broker.DescribeGroups()
+
+	response := make([]ConsumerGroupResponse, len(groups))
+	for i, group := range groups {
+		response[i] = toConsumerGroupResponse(group)
+	}
+
+	ctx.JSON(http.StatusOK, ConsumerGroupsResponse{
+		Groups: response,
+		Count:  len(response),
+	})
+}
+
+func toConsumerGroupResponse(group broker.GroupInfo) ConsumerGroupResponse {
+	response := ConsumerGroupResponse{
+		ID:       group.ID,
+		Strategy: group.Strategy,
+		Members:  make([]GroupMemberResponse, len(group.Members)),
+		Offsets:  make([]GroupOffsetResponse, len(group.Offsets)),
+	}
+
+	for i, member := range group.Members {
+		response.Members[i] = GroupMemberResponse{
+			ID:            member.ID,
+			Topics:        member.Topics,
+			Partitions:    member.Partitions,
+			LastHeartbeat: member.LastHeartbeat,
+		}
+	}
+
+	for i, offset := range group.Offsets {
+		response.Offsets[i] = GroupOffsetResponse{
+			Topic:     offset.Topic,
+			Partition: offset.Partition,
+			Committed: offset.Committed,
+			End:       offset.End,
+			LagBytes:  offset.Lag,
+		}
+		response.LagBytes += offset.Lag
+	}
+
+	return response
+}
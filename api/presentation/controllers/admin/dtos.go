@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type BanUserRequest struct {
+	Reason      string `json:"reason" binding:"omitempty,max=200"`
+	DurationHrs int    `json:"duration_hours" binding:"omitempty,min=0,max=8760"` // 0 means permanent
+}
+
+type SetMaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Message string `json:"message" binding:"omitempty,max=500"`
+}
+
+type RoomSummaryResponse struct {
+	ID           string    `json:"id"`
+	JoinCode     string    `json:"join_code"`
+	OwnerID      string    `json:"owner_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MemberCount  int       `json:"member_count"`
+	MessageCount int64     `json:"message_count"`
+}
+
+type RoomsResponse struct {
+	Rooms []RoomSummaryResponse `json:"rooms"`
+	Count int                   `json:"count"`
+}
+
+type BanResponse struct {
+	UserID    string     `json:"user_id"`
+	Reason    string     `json:"reason,omitempty"`
+	BannedAt  time.Time  `json:"banned_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type BansResponse struct {
+	Bans  []BanResponse `json:"bans"`
+	Count int           `json:"count"`
+}
+
+type RateLimitBlockResponse struct {
+	UserID           string `json:"user_id"`
+	RemainingSeconds int    `json:"remaining_seconds"`
+}
+
+type RateLimitBlocksResponse struct {
+	Blocks []RateLimitBlockResponse `json:"blocks"`
+	Count  int                      `json:"count"`
+}
+
+type AddRateLimitExemptionRequest struct {
+	Kind  string `json:"kind" binding:"required,oneof=user cidr bot"`
+	Value string `json:"value" binding:"required,max=200"`
+	Note  string `json:"note" binding:"omitempty,max=200"`
+}
+
+type RateLimitExemptionResponse struct {
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RateLimitExemptionsResponse struct {
+	Exemptions []RateLimitExemptionResponse `json:"exemptions"`
+	Count      int                          `json:"count"`
+}
+
+type UserInfoResponse struct {
+	ID         string       `json:"id"`
+	Username   string       `json:"username"`
+	IsGuest    bool         `json:"is_guest"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Online     bool         `json:"online"`
+	Ban        *BanResponse `json:"ban,omitempty"`
+	Identity   *IdentityRef `json:"identity,omitempty"`
+	OwnedRooms []string     `json:"owned_rooms"`
+	MemberOf   []string     `json:"member_of"`
+	BotCount   int          `json:"bot_count"`
+}
+
+// IdentityRef is the account linked to a user
+type IdentityRef struct {
+	Issuer      string    `json:"issuer"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email,omitempty"`
+	Admin       bool      `json:"admin"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+type BroadcastRequest struct {
+	Message string `json:"message" binding:"required,max=1000"`
+	Level   string `json:"level" binding:"omitempty,oneof=info warning critical"` // info when empty
+}
+
+type BroadcastResponse struct {
+	Level string `json:"level"`
+	Rooms int    `json:"rooms"`
+}
+
+type SetFeatureFlagRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+type FeatureFlagResponse struct {
+	Name      string     `json:"name"`
+	Enabled   bool       `json:"enabled"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type FeatureFlagsResponse struct {
+	Flags []FeatureFlagResponse `json:"flags"`
+	Count int                   `json:"count"`
+}
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+}
+
+type SuccessResponse struct {
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+type CreateTopicRequest struct {
+	Name       string `json:"name" binding:"required,max=100,excludesall=/\\."`
+	Partitions int    `json:"partitions" binding:"required,min=1,max=64"`
+}
+
+type PartitionResponse struct {
+	ID        int   `json:"id"`
+	SizeBytes int64 `json:"size_bytes"`
+	Leader    int   `json:"leader,omitempty"`
+}
+
+type TopicResponse struct {
+	Name       string              `json:"name"`
+	Partitions []PartitionResponse `json:"partitions"`
+	SizeBytes  int64               `json:"size_bytes"`
+}
+
+type TopicsResponse struct {
+	Topics []TopicResponse `json:"topics"`
+	Count  int             `json:"count"`
+}
+
+type GroupMemberResponse struct {
+	ID            string           `json:"id"`
+	Topics        []string         `json:"topics"`
+	Partitions    map[string][]int `json:"partitions"`
+	LastHeartbeat time.Time        `json:"last_heartbeat"`
+}
+
+type GroupOffsetResponse struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Committed int64  `json:"committed"`
+	End       int64  `json:"end"`
+	LagBytes  int64  `json:"lag_bytes"`
+}
+
+type ConsumerGroupResponse struct {
+	ID       string                `json:"id"`
+	Strategy string                `json:"strategy,omitempty"`
+	Members  []GroupMemberResponse `json:"members"`
+	Offsets  []GroupOffsetResponse `json:"offsets"`
+	LagBytes int64                 `json:"lag_bytes"`
+}
+
+type ConsumerGroupsResponse struct {
+	Groups []ConsumerGroupResponse `json:"groups"`
+	Count  int                     `json:"count"`
+}
+
+type AuditLogResponse struct {
+	EventID   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	UserID    string          `json:"user_id"`
+	RoomID    string          `json:"room_id,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	Success   bool            `json:"success"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type AuditLogsResponse struct {
+	Logs  []AuditLogResponse `json:"logs"`
+	Count int                `json:"count"`
+}
@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+func (c *adminController) ListFeatureFlags(ctx *gin.Context) {
+	states, err := c.usecase.ListFeatureFlags(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "fetch_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	flags := make([]FeatureFlagResponse, len(states))
+	for i, state := range states {
+		flags[i] = toFeatureFlagResponse(state)
+	}
+
+	ctx.JSON(http.StatusOK, FeatureFlagsResponse{
+		Flags: flags,
+		Count: len(flags),
+	})
+}
+
+func (c *adminController) SetFeatureFlag(ctx *gin.Context) {
+	var req SetFeatureFlagRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	flag := model.FeatureFlag(ctx.Param("name"))
+	state, err := c.usecase.SetFeatureFlag(ctx.Request.Context(), flag, *req.Enabled, middlewares.GetAdminActor(ctx))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	c.refreshFlags(ctx)
+
+	ctx.JSON(http.StatusOK, toFeatureFlagResponse(state))
+}
+
+// refreshFlags applies a toggle on this instance right away, the others pick it up on
+// their next refresh
+func (c *adminController) refreshFlags(ctx *gin.Context) {
+	if c.flags != nil {
+		c.flags.Refresh(ctx.Request.Context())
+	}
+}
+
+func toFeatureFlagResponse(state *model.FeatureFlagState) FeatureFlagResponse {
+	response := FeatureFlagResponse{
+		Name:      string(state.Name),
+		Enabled:   state.Enabled,
+		UpdatedBy: state.UpdatedBy,
+	}
+	if !state.UpdatedAt.IsZero() {
+		updatedAt := state.UpdatedAt
+		response.UpdatedAt = &updatedAt
+	}
+	return response
+}
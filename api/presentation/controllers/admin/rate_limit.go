@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+func (c *adminController) ListRateLimitExemptions(ctx *gin.Context) {
+	exemptions, err := c.usecase.ListRateLimitExemptions(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "fetch_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	response := make([]RateLimitExemptionResponse, len(exemptions))
+	for i, exemption := range exemptions {
+		response[i] = toRateLimitExemptionResponse(exemption)
+	}
+
+	ctx.JSON(http.StatusOK, RateLimitExemptionsResponse{
+		Exemptions: response,
+		Count:      len(response),
+	})
+}
+
+func (c *adminController) AddRateLimitExemption(ctx *gin.Context) {
+	var req AddRateLimitExemptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	exemption, err := c.usecase.AddRateLimitExemption(ctx.Request.Context(), model.RateLimitExemptionKind(req.Kind), req.Value, req.Note)
+	if err != nil {
+		ctx.JSON(exemptionErrorStatus(err), ErrorResponse{
+			Error:   "add_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+	c.refreshAllowlist(ctx)
+
+	ctx.JSON(http.StatusCreated, toRateLimitExemptionResponse(exemption))
+}
+
+func (c *adminController) RemoveRateLimitExemption(ctx *gin.Context) {
+	kind := model.RateLimitExemptionKind(ctx.Query("kind"))
+	if err := c.usecase.RemoveRateLimitExemption(ctx.Request.Context(), kind, ctx.Query("value")); err != nil {
+		ctx.JSON(exemptionErrorStatus(err), ErrorResponse{
+			Error:   "remove_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+	c.refreshAllowlist(ctx)
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "rate limit exemption removed successfully",
+	})
+}
+
+// refreshAllowlist applies a change on this instance right away, the others pick it up
+// on their next refresh. So does this one when loading fails now.
+func (c *adminController) refreshAllowlist(ctx *gin.Context) {
+	if c.allowlist != nil {
+		c.allowlist.Refresh(ctx.Request.Context())
+	}
+}
+
+func exemptionErrorStatus(err error) int {
+	if appErr, ok := apperror.As(err); ok {
+		return middlewares.HTTPStatus(appErr.Kind)
+	}
+	return http.StatusInternalServerError
+}
+
+func toRateLimitExemptionResponse(exemption *model.RateLimitExemption) RateLimitExemptionResponse {
+	return RateLimitExemptionResponse{
+		Kind:      string(exemption.Kind),
+		Value:     exemption.Value,
+		Note:      exemption.Note,
+		CreatedAt: exemption.CreatedAt,
+	}
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/auth"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+type AuthController interface {
+	Login(ctx *gin.Context)
+	Callback(ctx *gin.Context)
+	Me(ctx *gin.Context)
+	Unlink(ctx *gin.Context)
+	Logout(ctx *gin.Context)
+}
+
+type authController struct {
+	usecase      auth.AuthUseCase
+	memberTokens *security.MemberTokenSigner
+	frontEndURL  string
+}
+
+func NewAuthController(usecase auth.AuthUseCase, memberTokens *security.MemberTokenSigner, frontEndURL string) AuthController {
+	return &authController{
+		usecase:      usecase,
+		memberTokens: memberTokens,
+		frontEndURL:  strings.TrimSuffix(frontEndURL, "/"),
+	}
+}
+
+// Login sends the browser to the provider, return_to is the front end path to come back to
+func (c *authController) Login(ctx *gin.Context) {
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	authURL, err := c.usecase.StartLogin(ctx.Request.Context(), user.ID, returnPath(ctx.Query("return_to")))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback is where the provider sends the browser back to, it signs the browser in as
+// the linked user and returns to the front end
+func (c *authController) Callback(ctx *gin.Context) {
+	if providerError := ctx.Query("error"); providerError != "" {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "login_failed",
+			Message: middlewares.Localize(ctx, "sign in was cancelled or refused"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	result, err := c.usecase.CompleteLogin(ctx.Request.Context(), user.ID, ctx.Query("state"), ctx.Query("code"))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	token, err := c.memberTokens.Issue(result.User.ID, "")
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	security.SetMemberToken(ctx.Writer, token, c.memberTokens.TTL())
+	ctx.Header(security.MemberTokenHeader, token)
+
+	ctx.Redirect(http.StatusFound, c.frontEndURL+result.ReturnTo)
+}
+
+func (c *authController) Me(ctx *gin.Context) {
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	identity, err := c.usecase.GetIdentity(ctx.Request.Context(), user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, toIdentityResponse(user, identity))
+}
+
+func (c *authController) Unlink(ctx *gin.Context) {
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if err := c.usecase.Unlink(ctx.Request.Context(), user.ID); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "account unlinked successfully",
+	})
+}
+
+// Logout forgets the member token, the next request starts out as a new guest
+func (c *authController) Logout(ctx *gin.Context) {
+	security.ClearMemberToken(ctx.Writer)
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "signed out successfully",
+	})
+}
+
+// returnPath keeps return_to a path on the front end, so the login can't be used to send
+// someone elsewhere
+func returnPath(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, "\\") {
+		return "/"
+	}
+	return returnTo
+}
+
+func toIdentityResponse(user *model.User, identity *model.Identity) IdentityResponse {
+	return IdentityResponse{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Issuer:      identity.Issuer,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		Name:        identity.Name,
+		Admin:       identity.Admin,
+		LinkedAt:    identity.LinkedAt,
+		LastLoginAt: identity.LastLoginAt,
+	}
+}
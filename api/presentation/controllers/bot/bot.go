@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/bot"
+	"github.com/hilthontt/visper/api/application/usecases/room"
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/websocket"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+type BotController interface {
+	RegisterBot(ctx *gin.Context)
+	ListBots(ctx *gin.Context)
+	RevokeBot(ctx *gin.Context)
+	AddToRoom(ctx *gin.Context)
+	Me(ctx *gin.Context)
+}
+
+type botController struct {
+	usecase     bot.BotUseCase
+	roomUsecase room.RoomUseCase
+	wsCore      *websocket.Core
+}
+
+func NewBotController(usecase bot.BotUseCase, roomUsecase room.RoomUseCase, wsCore *websocket.Core) BotController {
+	return &botController{
+		usecase:     usecase,
+		roomUsecase: roomUsecase,
+		wsCore:      wsCore,
+	}
+}
+
+func (c *botController) RegisterBot(ctx *gin.Context) {
+	var req RegisterBotRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	scopes := make([]model.BotScope, len(req.Scopes))
+	for i, scope := range req.Scopes {
+		scopes[i] = model.BotScope(scope)
+	}
+
+	registered, token, err := c.usecase.Register(ctx.Request.Context(), user.ID, req.Name, scopes)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := toBotResponse(registered)
+	response.Token = token
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (c *botController) ListBots(ctx *gin.Context) {
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	bots, err := c.usecase.List(ctx.Request.Context(), user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	responses := make([]BotResponse, len(bots))
+	for i, b := range bots {
+		responses[i] = toBotResponse(b)
+	}
+
+	ctx.JSON(http.StatusOK, BotsResponse{
+		Bots:  responses,
+		Count: len(responses),
+	})
+}
+
+func (c *botController) RevokeBot(ctx *gin.Context) {
+	botID := ctx.Param("botId")
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if err := c.usecase.Revoke(ctx.Request.Context(), user.ID, botID); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "bot revoked successfully",
+	})
+}
+
+// AddToRoom lets a room owner bring one of their bots in, bots can't join on their own
+func (c *botController) AddToRoom(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	botID := ctx.Param("botId")
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	b, err := c.usecase.Get(ctx.Request.Context(), user.ID, botID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	r, err := c.roomUsecase.GetByID(ctx.Request.Context(), roomID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	if r.Owner.ID != user.ID {
+		_ = ctx.Error(apperror.ErrNotOwner.WithMessage("only the room owner can add bots"))
+		return
+	}
+
+	botUser := b.User()
+	if err := c.roomUsecase.JoinRoom(ctx.Request.Context(), roomID, *botUser, ""); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewMemberJoined(roomID, websocket.MemberPayload{
+		UserID:   botUser.ID,
+		Username: botUser.Username,
+		JoinedAt: time.Now().Format(time.RFC3339),
+	})
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "bot added to room successfully",
+		Data:    toBotResponse(b),
+	})
+}
+
+func (c *botController) Me(ctx *gin.Context) {
+	b, exists := middlewares.GetBotFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "bot token is required"),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, toBotResponse(b))
+}
+
+func toBotResponse(b *model.Bot) BotResponse {
+	scopes := make([]string, len(b.Scopes))
+	for i, scope := range b.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	return BotResponse{
+		ID:        b.ID,
+		Name:      b.Name,
+		Scopes:    scopes,
+		CreatedAt: b.CreatedAt,
+	}
+}
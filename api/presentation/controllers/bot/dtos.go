@@ -0,0 +1,31 @@
+package bot
+
+import "time"
+
+type RegisterBotRequest struct {
+	Name   string   `json:"name" binding:"required,min=3,max=50"`
+	Scopes []string `json:"scopes" binding:"required,min=1,max=2,dive,oneof=send read"`
+}
+
+type BotResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Token     string    `json:"token,omitempty"` // only returned when the bot is registered
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BotsResponse struct {
+	Bots  []BotResponse `json:"bots"`
+	Count int           `json:"count"`
+}
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+}
+
+type SuccessResponse struct {
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
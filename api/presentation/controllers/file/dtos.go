@@ -24,4 +24,8 @@ type FileResponse struct {
 	URL       string       `json:"url"`
 	CreatedAt time.Time    `json:"createdAt"`
 	Uploader  UserResponse `json:"uploader"`
+	// Variants maps "thumbnail" and "medium" to signed links, missing until the resize worker is done
+	Variants map[string]string `json:"variants,omitempty"`
+	// ScanStatus is pending, clean, infected, failed or skipped. Infected files have no URL.
+	ScanStatus string `json:"scanStatus,omitempty"`
 }
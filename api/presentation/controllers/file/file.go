@@ -1,18 +1,25 @@
 package file
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hilthontt/visper/api/application/usecases/file"
+	"github.com/hilthontt/visper/api/application/usecases/room"
 	"github.com/hilthontt/visper/api/infrastructure/storage"
+	"github.com/hilthontt/visper/api/infrastructure/websocket"
 	"github.com/hilthontt/visper/api/presentation/middlewares"
 )
 
+// scanTimeout bounds a scan after the upload response went out, clamd gives up after 30s itself
+const scanTimeout = time.Minute
+
 type FilesController interface {
 	Upload(ctx *gin.Context)
 	Down(ctx *gin.Context)
@@ -22,14 +29,18 @@ type FilesController interface {
 }
 
 type filesController struct {
-	fileUseCase  file.FileUseCase
-	localStorage *storage.LocalStorage
+	fileUseCase file.FileUseCase
+	roomUseCase room.RoomUseCase
+	storage     storage.Storage
+	wsCore      *websocket.Core
 }
 
-func NewFilesController(fileUseCase file.FileUseCase, localStorage *storage.LocalStorage) FilesController {
+func NewFilesController(fileUseCase file.FileUseCase, roomUseCase room.RoomUseCase, fileStorage storage.Storage, wsCore *websocket.Core) FilesController {
 	return &filesController{
-		fileUseCase:  fileUseCase,
-		localStorage: localStorage,
+		fileUseCase: fileUseCase,
+		roomUseCase: roomUseCase,
+		storage:     fileStorage,
+		wsCore:      wsCore,
 	}
 }
 
@@ -38,7 +49,7 @@ func (c *filesController) Upload(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -47,47 +58,33 @@ func (c *filesController) Upload(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
+	if size := ctx.Request.ContentLength; size >= progressMinSize {
+		ctx.Request.Body = newProgressReader(ctx.Request.Body, size, func(percent int, read, total int64) {
+			c.wsCore.Broadcast() <- websocket.NewUploadProgress(roomID, user.ID, websocket.UploadProgressPayload{
+				Percent:       percent,
+				BytesReceived: read,
+				TotalBytes:    total,
+			})
+		})
+	}
+
 	fileHeader, err := ctx.FormFile("file")
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "file is required",
+			Message: middlewares.Localize(ctx, "file is required"),
 		})
 		return
 	}
 
 	file, err := c.fileUseCase.UploadFile(ctx.Request.Context(), fileHeader, roomID, user.ID)
 	if err != nil {
-		status := http.StatusInternalServerError
-		errorCode := "upload_failed"
-
-		switch err.Error() {
-		case "room not found":
-			status = http.StatusNotFound
-			errorCode = "not_found"
-		case "room has expired":
-			status = http.StatusGone
-			errorCode = "expired"
-		case "user is not a member of this room":
-			status = http.StatusForbidden
-			errorCode = "forbidden"
-		case "file size exceeds maximum allowed size of 5MB":
-			status = http.StatusRequestEntityTooLarge
-			errorCode = "file_too_large"
-		case "invalid file type, only images are allowed":
-			status = http.StatusBadRequest
-			errorCode = "invalid_file_type"
-		}
-
-		ctx.JSON(status, ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -102,6 +99,33 @@ func (c *filesController) Upload(ctx *gin.Context) {
 			ID:       user.ID,
 			Username: user.Username,
 		},
+		Variants:   file.VariantURLs,
+		ScanStatus: string(file.ScanStatus),
+	})
+
+	go c.scanUpload(file.ID)
+}
+
+// scanUpload runs once the uploader has their response, the file is served as pending until then
+func (c *filesController) scanUpload(fileID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+	defer cancel()
+
+	file, err := c.fileUseCase.ScanFile(ctx, fileID)
+	if err != nil || !file.IsQuarantined() {
+		return
+	}
+
+	room, err := c.roomUseCase.GetByID(ctx, file.RoomID)
+	if err != nil {
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewFileQuarantined(file.RoomID, room.Owner.ID, websocket.FileQuarantinedPayload{
+		FileID:    file.ID,
+		Filename:  file.Filename,
+		UserID:    file.UserID,
+		Signature: file.ScanSignature,
 	})
 }
 
@@ -110,7 +134,7 @@ func (c *filesController) Proxy(ctx *gin.Context) {
 	if filePath == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "file path is required",
+			Message: middlewares.Localize(ctx, "file path is required"),
 		})
 		return
 	}
@@ -120,66 +144,33 @@ func (c *filesController) Proxy(ctx *gin.Context) {
 		filePath = filePath[1:]
 	}
 
-	if !c.localStorage.FileExists(filePath) {
-		ctx.JSON(http.StatusNotFound, ErrorResponse{
-			Error:   "not_found",
-			Message: "file not found",
-		})
+	expires := ctx.Query("expires")
+	if err := c.fileUseCase.VerifyLink(filePath, expires, ctx.Query("sig")); err != nil {
+		_ = ctx.Error(err)
 		return
 	}
 
-	fullPath := c.localStorage.GetFilePath(filePath)
+	filename := filepath.Base(filePath)
+	etag := fmt.Sprintf(`"%s"`, filename)
 
-	ext := strings.ToLower(filepath.Ext(filePath))
-	mimeTypes := map[string]string{
-		".jpg":  "image/jpeg",
-		".jpeg": "image/jpeg",
-		".png":  "image/png",
-		".gif":  "image/gif",
-		".webp": "image/webp",
-		".bmp":  "image/bmp",
-	}
+	// Shared caches would keep serving the file after the link expired
+	ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", linkMaxAge(expires)))
+	ctx.Header("ETag", etag)
 
-	mimeType, ok := mimeTypes[ext]
-	if !ok {
-		mimeType = "application/octet-stream"
-	}
-
-	f, err := os.Open(fullPath)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "read_error",
-			Message: "failed to open file",
-		})
+	if match := ctx.GetHeader("If-None-Match"); match != "" && match == etag {
+		ctx.Status(http.StatusNotModified)
 		return
 	}
-	defer f.Close()
 
-	info, err := f.Stat()
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "read_error",
-			Message: "failed to stat file",
-		})
+	object, ok := c.openFile(ctx, filePath)
+	if !ok {
 		return
 	}
+	defer object.Body.Close()
 
-	filename := filepath.Base(filePath)
-	ctx.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
-	ctx.Header("Content-Type", mimeType)
-	ctx.Header("Content-Length", fmt.Sprintf("%d", info.Size()))
-
-	ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
-	ctx.Header("ETag", fmt.Sprintf(`"%s"`, filename))
-
-	if match := ctx.GetHeader("If-None-Match"); match != "" {
-		if match == fmt.Sprintf(`"%s"`, filename) {
-			ctx.Status(http.StatusNotModified)
-			return
-		}
-	}
-
-	ctx.DataFromReader(http.StatusOK, info.Size(), mimeType, f, nil)
+	ctx.DataFromReader(http.StatusOK, object.Size, object.ContentType, object.Body, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`inline; filename="%s"`, filename),
+	})
 }
 
 func (c *filesController) Down(ctx *gin.Context) {
@@ -187,7 +178,7 @@ func (c *filesController) Down(ctx *gin.Context) {
 	if filePath == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "file path is required",
+			Message: middlewares.Localize(ctx, "file path is required"),
 		})
 		return
 	}
@@ -196,17 +187,41 @@ func (c *filesController) Down(ctx *gin.Context) {
 		filePath = filePath[1:]
 	}
 
-	if !c.localStorage.FileExists(filePath) {
+	if err := c.fileUseCase.VerifyLink(filePath, ctx.Query("expires"), ctx.Query("sig")); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	object, ok := c.openFile(ctx, filePath)
+	if !ok {
+		return
+	}
+	defer object.Body.Close()
+
+	ctx.DataFromReader(http.StatusOK, object.Size, object.ContentType, object.Body, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(filePath)),
+	})
+}
+
+// openFile writes the error response itself when the file can't be opened
+func (c *filesController) openFile(ctx *gin.Context, filePath string) (*storage.Object, bool) {
+	object, err := c.storage.Get(ctx.Request.Context(), filePath)
+	if errors.Is(err, storage.ErrObjectNotFound) {
 		ctx.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
-			Message: "file not found",
+			Message: middlewares.Localize(ctx, "file not found"),
 		})
-		return
+		return nil, false
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "read_error",
+			Message: middlewares.Localize(ctx, "failed to open file"),
+		})
+		return nil, false
 	}
 
-	fullPath := c.localStorage.GetFilePath(filePath)
-
-	ctx.File(fullPath)
+	return object, true
 }
 
 func (c *filesController) DeleteFile(ctx *gin.Context) {
@@ -214,7 +229,7 @@ func (c *filesController) DeleteFile(ctx *gin.Context) {
 	if fileID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "file ID is required",
+			Message: middlewares.Localize(ctx, "file ID is required"),
 		})
 		return
 	}
@@ -223,31 +238,13 @@ func (c *filesController) DeleteFile(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
 	if err := c.fileUseCase.DeleteFile(ctx.Request.Context(), fileID, user.ID); err != nil {
-		status := http.StatusInternalServerError
-		errorCode := "delete_failed"
-
-		switch err.Error() {
-		case "file not found":
-			status = http.StatusNotFound
-			errorCode = "not_found"
-		case "room not found":
-			status = http.StatusNotFound
-			errorCode = "not_found"
-		case "only the file uploader or room owner can delete files":
-			status = http.StatusForbidden
-			errorCode = "forbidden"
-		}
-
-		ctx.JSON(status, ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -261,30 +258,23 @@ func (c *filesController) GetRoomFiles(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
 
-	_, exists := middlewares.GetUserFromContext(ctx)
+	user, exists := middlewares.GetUserFromContext(ctx)
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
-	files, err := c.fileUseCase.GetRoomFiles(ctx.Request.Context(), roomID)
+	files, err := c.fileUseCase.GetRoomFiles(ctx.Request.Context(), roomID, user.ID)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "room not found" {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "fetch_failed",
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -301,8 +291,16 @@ func (c *filesController) GetRoomFiles(ctx *gin.Context) {
 				ID:       file.UserID,
 				Username: "", // TODO: add usernames
 			},
+			Variants:   file.VariantURLs,
+			ScanStatus: string(file.ScanStatus),
 		}
 	}
 
 	ctx.JSON(http.StatusOK, response)
 }
+
+// linkMaxAge is how many seconds the verified link has left
+func linkMaxAge(expires string) int64 {
+	expiresAt, _ := strconv.ParseInt(expires, 10, 64)
+	return max(expiresAt-time.Now().Unix(), 0)
+}
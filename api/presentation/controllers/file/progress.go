@@ -0,0 +1,40 @@
+package file
+
+import "io"
+
+const (
+	// progressMinSize keeps small uploads quiet, they finish before a progress bar would show
+	progressMinSize = 1 << 20
+	progressStep    = 5 // percent between two events
+)
+
+// progressReader counts the request body as the multipart parser pulls it in and calls report
+// every progressStep percent. The body includes the multipart framing, so it runs a little ahead
+// of the file itself.
+type progressReader struct {
+	body     io.ReadCloser
+	total    int64
+	read     int64
+	reported int
+	report   func(percent int, read, total int64)
+}
+
+func newProgressReader(body io.ReadCloser, total int64, report func(percent int, read, total int64)) *progressReader {
+	return &progressReader{body: body, total: total, reported: -1, report: report}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.read += int64(n)
+
+	percent := int(min(r.read*100/r.total, 100))
+	if percent >= r.reported+progressStep || (percent == 100 && r.reported < 100) {
+		r.reported = percent
+		r.report(percent, r.read, r.total)
+	}
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	return r.body.Close()
+}
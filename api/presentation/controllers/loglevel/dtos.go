@@ -0,0 +1,19 @@
+package loglevel
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// SetLogLevelRequest changes the root level, or a subsystem's when one is named. An empty
+// level for a subsystem has it follow the root level again.
+type SetLogLevelRequest struct {
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem" binding:"omitempty,oneof=ws repo events"`
+}
+
+// LogLevelsResponse lists a subsystem with an empty level when it follows the root level
+type LogLevelsResponse struct {
+	Level      string            `json:"level"`
+	Subsystems map[string]string `json:"subsystems"`
+}
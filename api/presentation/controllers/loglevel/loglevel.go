@@ -0,0 +1,73 @@
+package loglevel
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+	"go.uber.org/zap"
+)
+
+type LogLevelController interface {
+	GetLevels(ctx *gin.Context)
+	SetLevel(ctx *gin.Context)
+}
+
+type logLevelController struct {
+	logger *logger.Logger
+}
+
+func NewLogLevelController(logger *logger.Logger) LogLevelController {
+	return &logLevelController{
+		logger: logger,
+	}
+}
+
+func (c *logLevelController) GetLevels(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.levels())
+}
+
+// SetLevel only lasts until the process restarts or the config is reloaded
+func (c *logLevelController) SetLevel(ctx *gin.Context) {
+	var req SetLogLevelRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	var err error
+	switch {
+	case req.Subsystem != "":
+		err = c.logger.SetSubsystemLevel(req.Subsystem, req.Level)
+	case req.Level == "":
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "level is required"),
+		})
+		return
+	default:
+		err = c.logger.SetLevel(req.Level)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_level",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	c.logger.Warn("log level changed", zap.String("subsystem", req.Subsystem), zap.String("level", req.Level))
+	ctx.JSON(http.StatusOK, c.levels())
+}
+
+func (c *logLevelController) levels() LogLevelsResponse {
+	level, subsystems := c.logger.Levels()
+	return LogLevelsResponse{
+		Level:      level,
+		Subsystems: subsystems,
+	}
+}
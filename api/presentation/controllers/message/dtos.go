@@ -3,6 +3,15 @@ package message
 import "time"
 
 type SendMessageRequest struct {
+	Content         string `json:"content" binding:"required,max=1000"`
+	Encrypted       bool   `json:"encrypted"`
+	KeyVersion      int    `json:"key_version"` // version of the room key the content is sealed with
+	ParentMessageID string `json:"parent_message_id"`
+	// Usernames, only read for encrypted messages since the server parses plaintext itself
+	Mentions []string `json:"mentions" binding:"omitempty,max=20,dive,max=50"`
+}
+
+type AnnouncementRequest struct {
 	Content   string `json:"content" binding:"required,max=1000"`
 	Encrypted bool   `json:"encrypted"`
 }
@@ -12,14 +21,43 @@ type UpdateMessageRequest struct {
 	Encrypted bool   `json:"encrypted"`
 }
 
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required,max=64"`
+}
+
+type ReactionsResponse struct {
+	MessageID string         `json:"message_id"`
+	Reactions map[string]int `json:"reactions"`
+}
+
 type MessageResponse struct {
-	ID        string    `json:"id"`
-	RoomID    string    `json:"room_id"`
-	UserID    string    `json:"user_id"`
-	Username  string    `json:"username"`
-	Content   string    `json:"content"`
-	Encrypted bool      `json:"encrypted"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string         `json:"id"`
+	RoomID     string         `json:"room_id"`
+	UserID     string         `json:"user_id"`
+	Username   string         `json:"username"`
+	Content    string         `json:"content"`
+	Encrypted  bool           `json:"encrypted"`
+	KeyVersion int            `json:"key_version,omitempty"`
+	Type       string         `json:"type,omitempty"` // "announcement", empty for regular messages
+	CreatedAt  time.Time      `json:"created_at"`
+	Reactions  map[string]int `json:"reactions,omitempty"` // emoji -> count
+
+	ParentMessageID string               `json:"parent_message_id,omitempty"`
+	Mentions        []MentionResponse    `json:"mentions,omitempty"`
+	Preview         *LinkPreviewResponse `json:"preview,omitempty"`
+}
+
+type LinkPreviewResponse struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+type MentionResponse struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
 }
 
 type MessagesResponse struct {
@@ -28,14 +66,30 @@ type MessagesResponse struct {
 	RoomID   string            `json:"room_id"`
 }
 
+type AnnouncementsResponse struct {
+	RoomID        string            `json:"room_id"`
+	Announcements []MessageResponse `json:"announcements"`
+	Count         int               `json:"count"`
+}
+
+type RepliesResponse struct {
+	ParentMessageID string            `json:"parent_message_id"`
+	Replies         []MessageResponse `json:"replies"`
+	Count           int               `json:"count"`
+	Total           int64             `json:"total"`
+	Offset          int64             `json:"offset"`
+	Limit           int64             `json:"limit"`
+}
+
 type MessageCountResponse struct {
 	RoomID string `json:"room_id"`
 	Count  int64  `json:"count"`
 }
 
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error      string `json:"error"`
+	Message    string `json:"message,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"` // seconds, set for SLOW_MODE
 }
 
 type MessageUpdatedResponse struct {
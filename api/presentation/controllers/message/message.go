@@ -1,18 +1,28 @@
 package message
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hilthontt/visper/api/application/usecases/message"
+	"github.com/hilthontt/visper/api/application/usecases/notification"
+	"github.com/hilthontt/visper/api/application/usecases/reaction"
 	"github.com/hilthontt/visper/api/application/usecases/room"
+	"github.com/hilthontt/visper/api/domain/apperror"
 	"github.com/hilthontt/visper/api/domain/model"
 	"github.com/hilthontt/visper/api/infrastructure/websocket"
 	"github.com/hilthontt/visper/api/presentation/middlewares"
 )
 
+// Covers the fetch and storing the result, the fetcher has its own shorter timeout
+const previewTimeout = 10 * time.Second
+
 type MessageController interface {
 	UpdateMessage(ctx *gin.Context)
 	DeleteMessage(ctx *gin.Context)
@@ -20,26 +30,41 @@ type MessageController interface {
 	GetMessages(ctx *gin.Context)
 	GetMessagesAfter(ctx *gin.Context)
 	GetMessageCount(ctx *gin.Context)
+	GetReplies(ctx *gin.Context)
+	AddReaction(ctx *gin.Context)
+	RemoveReaction(ctx *gin.Context)
+	PostAnnouncement(ctx *gin.Context)
+	GetAnnouncements(ctx *gin.Context)
+	UnpinAnnouncement(ctx *gin.Context)
+
+	// SendFromSocket serves send_message frames, see websocket.InboundHandler
+	SendFromSocket(ctx context.Context, roomID, userID, username string, frame websocket.SendMessageFrame) *websocket.WSMessage
 }
 
 type messageController struct {
-	usecase       message.MessageUseCase
-	roomUseCase   room.RoomUseCase
-	wsRoomManager *websocket.RoomManager
-	wsCore        *websocket.Core
+	usecase             message.MessageUseCase
+	roomUseCase         room.RoomUseCase
+	notificationUseCase notification.NotificationUseCase
+	reactionUseCase     reaction.ReactionUseCase
+	wsRoomManager       *websocket.RoomManager
+	wsCore              *websocket.Core
 }
 
 func NewMessageController(
 	usecase message.MessageUseCase,
 	roomUseCase room.RoomUseCase,
+	notificationUseCase notification.NotificationUseCase,
+	reactionUseCase reaction.ReactionUseCase,
 	wsRoomManager *websocket.RoomManager,
 	wsCore *websocket.Core,
 ) MessageController {
 	return &messageController{
-		usecase:       usecase,
-		roomUseCase:   roomUseCase,
-		wsRoomManager: wsRoomManager,
-		wsCore:        wsCore,
+		usecase:             usecase,
+		roomUseCase:         roomUseCase,
+		notificationUseCase: notificationUseCase,
+		reactionUseCase:     reactionUseCase,
+		wsRoomManager:       wsRoomManager,
+		wsCore:              wsCore,
 	}
 }
 
@@ -48,7 +73,7 @@ func (c *messageController) DeleteMessage(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -57,7 +82,7 @@ func (c *messageController) DeleteMessage(ctx *gin.Context) {
 	if messageID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "message ID is required",
+			Message: middlewares.Localize(ctx, "message ID is required"),
 		})
 		return
 	}
@@ -66,7 +91,7 @@ func (c *messageController) DeleteMessage(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
@@ -75,7 +100,7 @@ func (c *messageController) DeleteMessage(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
-			Message: "room not found",
+			Message: middlewares.Localize(ctx, "room not found"),
 		})
 		return
 	}
@@ -83,32 +108,20 @@ func (c *messageController) DeleteMessage(ctx *gin.Context) {
 	if !room.IsMember(user.ID) {
 		ctx.JSON(http.StatusForbidden, ErrorResponse{
 			Error:   "forbidden",
-			Message: "you are not a member of this room",
+			Message: middlewares.Localize(ctx, "you are not a member of this room"),
 		})
 		return
 	}
 
 	err = c.usecase.Delete(ctx.Request.Context(), roomID, messageID, user.ID)
 	if err != nil {
-		status := http.StatusInternalServerError
-		errorCode := "delete_failed"
-
-		switch {
-		case err.Error() == "message not found":
-			status = http.StatusNotFound
-			errorCode = "not_found"
-		case err.Error() == "unauthorized: you can only delete your own messages":
-			status = http.StatusForbidden
-			errorCode = "forbidden"
-		}
-
-		ctx.JSON(status, ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
+	// Orphaned reactions would expire anyway, this just frees them early
+	_ = c.reactionUseCase.ClearReactions(ctx.Request.Context(), roomID, messageID)
+
 	now := time.Now()
 	wsMessage := websocket.NewMessageDeleted(roomID, messageID, now.String())
 	c.wsCore.Broadcast() <- wsMessage
@@ -124,7 +137,7 @@ func (c *messageController) UpdateMessage(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -133,7 +146,7 @@ func (c *messageController) UpdateMessage(ctx *gin.Context) {
 	if messageID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "message ID is required",
+			Message: middlewares.Localize(ctx, "message ID is required"),
 		})
 		return
 	}
@@ -142,7 +155,7 @@ func (c *messageController) UpdateMessage(ctx *gin.Context) {
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: middlewares.TranslateValidationError(err),
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
 		})
 		return
 	}
@@ -151,7 +164,7 @@ func (c *messageController) UpdateMessage(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
@@ -160,7 +173,7 @@ func (c *messageController) UpdateMessage(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
-			Message: "room not found",
+			Message: middlewares.Localize(ctx, "room not found"),
 		})
 		return
 	}
@@ -168,33 +181,14 @@ func (c *messageController) UpdateMessage(ctx *gin.Context) {
 	if !room.IsMember(user.ID) {
 		ctx.JSON(http.StatusForbidden, ErrorResponse{
 			Error:   "forbidden",
-			Message: "you are not a member of this room",
+			Message: middlewares.Localize(ctx, "you are not a member of this room"),
 		})
 		return
 	}
 
 	err = c.usecase.Update(ctx.Request.Context(), roomID, messageID, user.ID, req.Content, req.Encrypted)
 	if err != nil {
-		status := http.StatusInternalServerError
-		errorCode := "update_failed"
-
-		switch {
-		case err.Error() == "message not found":
-			status = http.StatusNotFound
-			errorCode = "not_found"
-		case err.Error() == "unauthorized: you can only edit your own messages":
-			status = http.StatusForbidden
-			errorCode = "forbidden"
-		case err.Error() == "message cannot be empty" ||
-			err.Error() == "message cannot contain only whitespace":
-			status = http.StatusBadRequest
-			errorCode = "invalid_content"
-		}
-
-		ctx.JSON(status, ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -220,7 +214,7 @@ func (c *messageController) SendMessage(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -229,7 +223,7 @@ func (c *messageController) SendMessage(ctx *gin.Context) {
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: middlewares.TranslateValidationError(err),
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
 		})
 		return
 	}
@@ -238,24 +232,82 @@ func (c *messageController) SendMessage(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
-	msg, err := c.usecase.Send(ctx.Request.Context(), roomID, user.ID, user.Username, req.Content, req.Encrypted)
-	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "message cannot be empty" ||
-			err.Error() == "message cannot contain only whitespace" {
-			status = http.StatusBadRequest
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "send_failed",
-			Message: err.Error(),
+	msg, err := c.usecase.Send(ctx.Request.Context(), roomID, user.ID, user.Username, req.Content, req.Encrypted, req.KeyVersion, req.ParentMessageID, req.Mentions)
+	var slowModeErr *message.SlowModeError
+	if errors.As(err, &slowModeErr) {
+		retryAfter := int(math.Ceil(slowModeErr.RetryAfter.Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+		ctx.JSON(http.StatusTooManyRequests, ErrorResponse{
+			Error:      "SLOW_MODE",
+			Message:    middlewares.Localize(ctx, err.Error()),
+			RetryAfter: retryAfter,
+		})
+		return
+	}
+	var blockedErr *message.ContentBlockedError
+	if errors.As(err, &blockedErr) {
+		c.notifyFlagged(ctx.Request.Context(), roomID, websocket.MessageFlaggedPayload{
+			UserID:    user.ID,
+			Username:  user.Username,
+			Content:   req.Content,
+			Blocked:   true,
+			Timestamp: time.Now().String(),
+		})
+		ctx.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "CONTENT_BLOCKED",
+			Message: middlewares.Localize(ctx, err.Error()),
 		})
 		return
 	}
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.publishSent(roomID, msg)
+
+	ctx.JSON(http.StatusCreated, c.toMessageResponse(msg))
+}
+
+func (c *messageController) SendFromSocket(ctx context.Context, roomID, userID, username string, frame websocket.SendMessageFrame) *websocket.WSMessage {
+	msg, err := c.usecase.Send(ctx, roomID, userID, username, frame.Content, frame.Encrypted, frame.KeyVersion, frame.ParentMessageID, frame.Mentions)
+
+	var slowModeErr *message.SlowModeError
+	if errors.As(err, &slowModeErr) {
+		return websocket.NewSendFailed(roomID, frame.RequestID, "SLOW_MODE", err.Error(), slowModeErr.RetryAfter)
+	}
+	var blockedErr *message.ContentBlockedError
+	if errors.As(err, &blockedErr) {
+		c.notifyFlagged(ctx, roomID, websocket.MessageFlaggedPayload{
+			UserID:    userID,
+			Username:  username,
+			Content:   frame.Content,
+			Blocked:   true,
+			Timestamp: time.Now().String(),
+		})
+		return websocket.NewSendFailed(roomID, frame.RequestID, "CONTENT_BLOCKED", err.Error(), 0)
+	}
+	if err != nil {
+		appErr, ok := apperror.As(err)
+		if !ok || appErr.Kind == apperror.KindInternal {
+			appErr = apperror.ErrInternal
+		}
+		return websocket.NewSendFailed(roomID, frame.RequestID, appErr.Code, appErr.Message, 0)
+	}
+
+	c.publishSent(roomID, msg)
+
+	return websocket.NewMessageSendAck(roomID, frame.RequestID, msg.ID, msg.CreatedAt.String())
+}
+
+// publishSent fans a stored message out to the room, shared by the REST and socket send paths
+func (c *messageController) publishSent(roomID string, msg *model.Message) {
+	ctx := context.Background()
 
 	wsMessage := websocket.NewMessageReceived(
 		roomID,
@@ -265,18 +317,222 @@ func (c *messageController) SendMessage(ctx *gin.Context) {
 		msg.Username,
 		msg.CreatedAt.String(),
 		msg.Encrypted,
+		msg.KeyVersion,
 	)
+	if msg.ParentMessageID != "" {
+		// A failed count only degrades the thread badge, the reply itself is stored
+		replyCount, _ := c.usecase.GetReplyCount(ctx, roomID, msg.ParentMessageID)
+		wsMessage = websocket.NewReplyReceived(
+			roomID,
+			msg.ID,
+			msg.ParentMessageID,
+			msg.Content,
+			msg.UserID,
+			msg.Username,
+			msg.CreatedAt.String(),
+			msg.Encrypted,
+			msg.KeyVersion,
+			replyCount,
+		)
+	}
 	c.wsCore.Broadcast() <- wsMessage
+	for _, mention := range msg.Mentions {
+		c.wsCore.Broadcast() <- websocket.NewMentioned(
+			roomID,
+			mention.UserID,
+			msg.ID,
+			msg.Content,
+			msg.UserID,
+			msg.Username,
+			msg.CreatedAt.String(),
+			msg.Encrypted,
+		)
+	}
+	if msg.Flagged {
+		c.notifyFlagged(ctx, roomID, websocket.MessageFlaggedPayload{
+			MessageID: msg.ID,
+			UserID:    msg.UserID,
+			Username:  msg.Username,
+			Content:   msg.Content,
+			Timestamp: msg.CreatedAt.String(),
+		})
+	}
+	c.wsCore.TouchPresence(roomID, msg.UserID)
+
+	// Request context is cancelled once we respond
+	go c.notificationUseCase.NotifyNewMessage(context.Background(), msg)
+	go c.attachPreview(msg)
+}
+
+func (c *messageController) attachPreview(msg *model.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+	defer cancel()
+
+	preview, err := c.usecase.GeneratePreview(ctx, msg)
+	if err != nil || preview == nil {
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewMessagePreviewReady(msg.RoomID, msg.ID, websocket.LinkPreviewPayload{
+		URL:         preview.URL,
+		Title:       preview.Title,
+		Description: preview.Description,
+		ImageURL:    preview.ImageURL,
+		SiteName:    preview.SiteName,
+	})
+}
+
+// notifyFlagged tells the room owner, the only moderator a room has, what the content filter caught
+func (c *messageController) notifyFlagged(ctx context.Context, roomID string, payload websocket.MessageFlaggedPayload) {
+	room, err := c.roomUseCase.GetByID(ctx, roomID)
+	if err != nil || room.Owner.ID == payload.UserID {
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewMessageFlagged(roomID, room.Owner.ID, payload)
+}
+
+func (c *messageController) PostAnnouncement(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	var req AnnouncementRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	msg, err := c.usecase.Announce(ctx.Request.Context(), roomID, user.ID, user.Username, req.Content, req.Encrypted)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.wsCore.BroadcastPriority(websocket.NewAnnouncement(
+		roomID,
+		msg.ID,
+		msg.Content,
+		msg.UserID,
+		msg.Username,
+		msg.CreatedAt.String(),
+		msg.Encrypted,
+		true,
+	))
+	c.wsCore.TouchPresence(roomID, user.ID)
+
+	go c.notificationUseCase.NotifyNewMessage(context.Background(), msg)
 
 	ctx.JSON(http.StatusCreated, c.toMessageResponse(msg))
 }
 
+// GetAnnouncements lets late joiners catch up on what the owner pinned
+func (c *messageController) GetAnnouncements(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	room, err := c.roomUseCase.GetByID(ctx.Request.Context(), roomID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not-found",
+			Message: middlewares.Localize(ctx, "room not found"),
+		})
+		return
+	}
+
+	if !room.IsMember(user.ID) {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "you are not a member of this room"),
+		})
+		return
+	}
+
+	announcements, err := c.usecase.GetAnnouncements(ctx.Request.Context(), roomID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "fetch_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, AnnouncementsResponse{
+		RoomID:        roomID,
+		Announcements: c.toMessageResponses(ctx, roomID, announcements),
+		Count:         len(announcements),
+	})
+}
+
+func (c *messageController) UnpinAnnouncement(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	messageID := ctx.Param("messageId")
+	if roomID == "" || messageID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID and message ID are required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if err := c.usecase.Unpin(ctx.Request.Context(), roomID, messageID, user.ID); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, MessageDeletedResponse{
+		Success:   true,
+		MessageID: messageID,
+	})
+}
+
 func (c *messageController) GetMessages(ctx *gin.Context) {
 	roomID := ctx.Param("id")
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -285,7 +541,7 @@ func (c *messageController) GetMessages(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
@@ -294,7 +550,7 @@ func (c *messageController) GetMessages(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not-found",
-			Message: "room not found",
+			Message: middlewares.Localize(ctx, "room not found"),
 		})
 		return
 	}
@@ -302,7 +558,7 @@ func (c *messageController) GetMessages(ctx *gin.Context) {
 	if !room.IsMember(user.ID) {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "you are not a member of this room",
+			Message: middlewares.Localize(ctx, "you are not a member of this room"),
 		})
 		return
 	}
@@ -318,13 +574,13 @@ func (c *messageController) GetMessages(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "fetch_failed",
-			Message: err.Error(),
+			Message: middlewares.Localize(ctx, err.Error()),
 		})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, MessagesResponse{
-		Messages: c.toMessageResponses(messages),
+		Messages: c.toMessageResponses(ctx, roomID, messages),
 		Count:    len(messages),
 		RoomID:   roomID,
 	})
@@ -335,7 +591,7 @@ func (c *messageController) GetMessagesAfter(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -344,7 +600,7 @@ func (c *messageController) GetMessagesAfter(ctx *gin.Context) {
 	if timestampStr == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "timestamp parameter is required",
+			Message: middlewares.Localize(ctx, "timestamp parameter is required"),
 		})
 		return
 	}
@@ -353,7 +609,7 @@ func (c *messageController) GetMessagesAfter(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "invalid timestamp format, use RFC3339 (e.g., 2024-01-01T12:00:00Z)",
+			Message: middlewares.Localize(ctx, "invalid timestamp format, use RFC3339 (e.g., 2024-01-01T12:00:00Z)"),
 		})
 		return
 	}
@@ -369,24 +625,99 @@ func (c *messageController) GetMessagesAfter(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "fetch_failed",
-			Message: err.Error(),
+			Message: middlewares.Localize(ctx, err.Error()),
 		})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, MessagesResponse{
-		Messages: c.toMessageResponses(messages),
+		Messages: c.toMessageResponses(ctx, roomID, messages),
 		Count:    len(messages),
 		RoomID:   roomID,
 	})
 }
 
+func (c *messageController) GetReplies(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	messageID := ctx.Param("messageId")
+	if messageID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "message ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	room, err := c.roomUseCase.GetByID(ctx.Request.Context(), roomID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: middlewares.Localize(ctx, "room not found"),
+		})
+		return
+	}
+
+	if !room.IsMember(user.ID) {
+		ctx.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "forbidden",
+			Message: middlewares.Localize(ctx, "you are not a member of this room"),
+		})
+		return
+	}
+
+	limit := int64(50)
+	if limitStr := ctx.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
+			limit = parsedLimit
+		}
+	}
+
+	offset := int64(0)
+	if offsetStr := ctx.Query("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.ParseInt(offsetStr, 10, 64); err == nil && parsedOffset > 0 {
+			offset = parsedOffset
+		}
+	}
+
+	replies, total, err := c.usecase.GetReplies(ctx.Request.Context(), roomID, messageID, offset, limit)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, RepliesResponse{
+		ParentMessageID: messageID,
+		Replies:         c.toMessageResponses(ctx, roomID, replies),
+		Count:           len(replies),
+		Total:           total,
+		Offset:          offset,
+		Limit:           limit,
+	})
+}
+
 func (c *messageController) GetMessageCount(ctx *gin.Context) {
 	roomID := ctx.Param("id")
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -395,7 +726,7 @@ func (c *messageController) GetMessageCount(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "count_failed",
-			Message: err.Error(),
+			Message: middlewares.Localize(ctx, err.Error()),
 		})
 		return
 	}
@@ -407,21 +738,127 @@ func (c *messageController) GetMessageCount(ctx *gin.Context) {
 }
 
 func (c *messageController) toMessageResponse(msg *model.Message) MessageResponse {
+	var mentions []MentionResponse
+	for _, mention := range msg.Mentions {
+		mentions = append(mentions, MentionResponse{UserID: mention.UserID, Username: mention.Username})
+	}
+
+	var preview *LinkPreviewResponse
+	if msg.Preview != nil {
+		preview = &LinkPreviewResponse{
+			URL:         msg.Preview.URL,
+			Title:       msg.Preview.Title,
+			Description: msg.Preview.Description,
+			ImageURL:    msg.Preview.ImageURL,
+			SiteName:    msg.Preview.SiteName,
+		}
+	}
+
 	return MessageResponse{
-		ID:        msg.ID,
-		RoomID:    msg.RoomID,
-		UserID:    msg.UserID,
-		Username:  msg.Username,
-		Content:   msg.Content,
-		CreatedAt: msg.CreatedAt,
-		Encrypted: msg.Encrypted,
+		ID:         msg.ID,
+		RoomID:     msg.RoomID,
+		UserID:     msg.UserID,
+		Username:   msg.Username,
+		Content:    msg.Content,
+		CreatedAt:  msg.CreatedAt,
+		Encrypted:  msg.Encrypted,
+		KeyVersion: msg.KeyVersion,
+		Type:       string(msg.Type),
+
+		ParentMessageID: msg.ParentMessageID,
+		Mentions:        mentions,
+		Preview:         preview,
 	}
 }
 
-func (c *messageController) toMessageResponses(messages []*model.Message) []MessageResponse {
+func (c *messageController) toMessageResponses(ctx *gin.Context, roomID string, messages []*model.Message) []MessageResponse {
+	messageIDs := make([]string, len(messages))
+	for i, msg := range messages {
+		messageIDs[i] = msg.ID
+	}
+
+	// Reactions are decoration, a lookup failure shouldn't hide the messages
+	reactions, err := c.reactionUseCase.GetReactionCounts(ctx.Request.Context(), roomID, messageIDs)
+	if err != nil {
+		reactions = nil
+	}
+
 	responses := make([]MessageResponse, len(messages))
 	for i, msg := range messages {
 		responses[i] = c.toMessageResponse(msg)
+		responses[i].Reactions = reactions[msg.ID]
 	}
 	return responses
 }
+
+func (c *messageController) AddReaction(ctx *gin.Context) {
+	c.handleReaction(ctx, true)
+}
+
+func (c *messageController) RemoveReaction(ctx *gin.Context) {
+	c.handleReaction(ctx, false)
+}
+
+// handleReaction serves both verbs, DELETE may pass the emoji as a query parameter instead of a body
+func (c *messageController) handleReaction(ctx *gin.Context, add bool) {
+	roomID := ctx.Param("id")
+	messageID := ctx.Param("messageId")
+	if roomID == "" || messageID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID and message ID are required"),
+		})
+		return
+	}
+
+	var req ReactionRequest
+	if emoji := ctx.Query("emoji"); !add && emoji != "" {
+		req.Emoji = emoji
+	} else if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	var (
+		counts map[string]int
+		err    error
+	)
+	if add {
+		counts, err = c.reactionUseCase.AddReaction(ctx.Request.Context(), roomID, messageID, user.ID, req.Emoji)
+	} else {
+		counts, err = c.reactionUseCase.RemoveReaction(ctx.Request.Context(), roomID, messageID, user.ID, req.Emoji)
+	}
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	emoji := strings.TrimSpace(req.Emoji)
+	if add {
+		c.wsCore.Broadcast() <- websocket.NewReactionAdded(roomID, messageID, user.ID, emoji, counts)
+	} else {
+		c.wsCore.Broadcast() <- websocket.NewReactionRemoved(roomID, messageID, user.ID, emoji, counts)
+	}
+
+	status := http.StatusOK
+	if add {
+		status = http.StatusCreated
+	}
+
+	ctx.JSON(status, ReactionsResponse{
+		MessageID: messageID,
+		Reactions: counts,
+	})
+}
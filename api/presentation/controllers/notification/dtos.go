@@ -0,0 +1,46 @@
+package notification
+
+import "time"
+
+type RegisterSubscriptionRequest struct {
+	Platform string `json:"platform" binding:"required,oneof=webpush fcm"`
+	Endpoint string `json:"endpoint" binding:"omitempty,url,max=2048"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"omitempty,max=256"`
+		Auth   string `json:"auth" binding:"omitempty,max=64"`
+	} `json:"keys"`
+	Token string `json:"token" binding:"omitempty,max=4096"`
+}
+
+type SetPreferenceRequest struct {
+	Level string `json:"level" binding:"required,oneof=all mentions none"`
+}
+
+type SubscriptionResponse struct {
+	ID        string    `json:"id"`
+	Platform  string    `json:"platform"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SubscriptionsResponse struct {
+	Subscriptions []SubscriptionResponse `json:"subscriptions"`
+}
+
+type PreferenceResponse struct {
+	RoomID string `json:"room_id"`
+	Level  string `json:"level"`
+}
+
+type VAPIDKeyResponse struct {
+	PublicKey string `json:"public_key"`
+}
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+}
+
+type SuccessResponse struct {
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
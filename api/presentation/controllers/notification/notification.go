@@ -0,0 +1,191 @@
+package notification
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/notification"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+type NotificationController interface {
+	GetVAPIDKey(ctx *gin.Context)
+	RegisterSubscription(ctx *gin.Context)
+	ListSubscriptions(ctx *gin.Context)
+	DeleteSubscription(ctx *gin.Context)
+	GetRoomPreference(ctx *gin.Context)
+	SetRoomPreference(ctx *gin.Context)
+}
+
+type notificationController struct {
+	usecase        notification.NotificationUseCase
+	vapidPublicKey string
+}
+
+func NewNotificationController(usecase notification.NotificationUseCase, vapidPublicKey string) NotificationController {
+	return &notificationController{
+		usecase:        usecase,
+		vapidPublicKey: vapidPublicKey,
+	}
+}
+
+func (c *notificationController) GetVAPIDKey(ctx *gin.Context) {
+	if c.vapidPublicKey == "" {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_configured",
+			Message: middlewares.Localize(ctx, "web push is not configured"),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, VAPIDKeyResponse{PublicKey: c.vapidPublicKey})
+}
+
+func (c *notificationController) RegisterSubscription(ctx *gin.Context) {
+	var req RegisterSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	subscription, err := c.usecase.RegisterSubscription(ctx.Request.Context(), &model.PushSubscription{
+		UserID:   user.ID,
+		Platform: req.Platform,
+		Endpoint: req.Endpoint,
+		P256dh:   req.Keys.P256dh,
+		Auth:     req.Keys.Auth,
+		Token:    req.Token,
+	})
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, toSubscriptionResponse(subscription))
+}
+
+func (c *notificationController) ListSubscriptions(ctx *gin.Context) {
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	subscriptions, err := c.usecase.ListSubscriptions(ctx.Request.Context(), user.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	response := SubscriptionsResponse{
+		Subscriptions: make([]SubscriptionResponse, 0, len(subscriptions)),
+	}
+	for _, subscription := range subscriptions {
+		response.Subscriptions = append(response.Subscriptions, toSubscriptionResponse(subscription))
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *notificationController) DeleteSubscription(ctx *gin.Context) {
+	subscriptionID := ctx.Param("subscriptionId")
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if err := c.usecase.DeleteSubscription(ctx.Request.Context(), user.ID, subscriptionID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "deletion_failed",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "subscription deleted successfully",
+	})
+}
+
+func (c *notificationController) GetRoomPreference(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	level, err := c.usecase.GetRoomPreference(ctx.Request.Context(), roomID, user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, PreferenceResponse{RoomID: roomID, Level: string(level)})
+}
+
+func (c *notificationController) SetRoomPreference(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+
+	var req SetPreferenceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	level := model.NotificationLevel(req.Level)
+	if err := c.usecase.SetRoomPreference(ctx.Request.Context(), roomID, user.ID, level); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, PreferenceResponse{RoomID: roomID, Level: req.Level})
+}
+
+func toSubscriptionResponse(subscription *model.PushSubscription) SubscriptionResponse {
+	return SubscriptionResponse{
+		ID:        subscription.ID,
+		Platform:  subscription.Platform,
+		CreatedAt: subscription.CreatedAt,
+	}
+}
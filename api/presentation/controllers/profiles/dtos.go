@@ -0,0 +1,26 @@
+package profiles
+
+import "time"
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+type ProfileResponse struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+// CaptureResponse is one set of profiles, Reason names the triggers that fired
+type CaptureResponse struct {
+	ID        string            `json:"id"`
+	Reason    string            `json:"reason"`
+	CreatedAt time.Time         `json:"created_at"`
+	Profiles  []ProfileResponse `json:"profiles"`
+}
+
+type CapturesResponse struct {
+	Captures []CaptureResponse `json:"captures"`
+}
@@ -0,0 +1,74 @@
+package profiles
+
+import (
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/profiler"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+type ProfilesController interface {
+	ListCaptures(ctx *gin.Context)
+	DownloadProfile(ctx *gin.Context)
+}
+
+type profilesController struct {
+	profiler *profiler.AdaptiveProfiler
+}
+
+func NewProfilesController(profiler *profiler.AdaptiveProfiler) ProfilesController {
+	return &profilesController{
+		profiler: profiler,
+	}
+}
+
+func (c *profilesController) ListCaptures(ctx *gin.Context) {
+	captures, err := c.profiler.Captures()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	// Download links are relative to the listing, whichever prefix it is mounted under
+	base := ctx.Request.URL.Path
+	response := CapturesResponse{Captures: make([]CaptureResponse, 0, len(captures))}
+	for _, capture := range captures {
+		item := CaptureResponse{
+			ID:        capture.ID,
+			Reason:    capture.Reason,
+			CreatedAt: capture.CreatedAt,
+			Profiles:  make([]ProfileResponse, 0, len(capture.Profiles)),
+		}
+		for _, profile := range capture.Profiles {
+			item.Profiles = append(item.Profiles, ProfileResponse{
+				Name: profile.Name,
+				Size: profile.Size,
+				URL:  path.Join(base, capture.ID, profile.Name),
+			})
+		}
+		response.Captures = append(response.Captures, item)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// DownloadProfile serves the raw pprof file, for go tool pprof
+func (c *profilesController) DownloadProfile(ctx *gin.Context) {
+	file, err := c.profiler.ProfilePath(ctx.Param("id"), ctx.Param("name"))
+	if errors.Is(err, profiler.ErrCaptureNotFound) {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: middlewares.Localize(ctx, "profile not found"),
+		})
+		return
+	}
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.FileAttachment(file, ctx.Param("name"))
+}
@@ -6,6 +6,10 @@ type CreateRoomRequest struct {
 	ExpiryHrs int `json:"expiry_hours" binding:"required,min=1,max=168"` // 1 hour to 7 days
 }
 
+type ExtendRoomRequest struct {
+	Hours int `json:"hours" binding:"required,min=1,max=168"`
+}
+
 type JoinRoomRequest struct {
 	Username string `json:"username" binding:"omitempty,max=50"`
 }
@@ -21,16 +25,49 @@ type JoinByCodeWithTokenRequest struct {
 	Username    string `json:"username"`
 }
 
+type MuteMemberRequest struct {
+	DurationMinutes int `json:"duration_minutes" binding:"required,min=1,max=10080"` // Up to the 7 day room lifetime
+}
+
+type BanMemberRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+	Reason string `json:"reason" binding:"omitempty,max=200"`
+}
+
+// Omitted fields are left unchanged
+type UpdateRoomSettingsRequest struct {
+	Name                  *string `json:"name" binding:"omitempty,max=64"`
+	Topic                 *string `json:"topic" binding:"omitempty,max=256"`
+	MaxMembers            *int    `json:"max_members" binding:"omitempty,min=0,max=1000"`       // 0 means unlimited
+	SlowModeSeconds       *int    `json:"slow_mode_seconds" binding:"omitempty,min=0,max=3600"` // 0 disables slow mode
+	ReadOnly              *bool   `json:"read_only"`
+	Moderation            *string `json:"moderation" binding:"omitempty,oneof=off flag block"`
+	MessageRetentionHours *int    `json:"message_retention_hours" binding:"omitempty,min=1,max=720"`
+	RetainUntilDeleted    *bool   `json:"retain_until_deleted"`
+}
+
+type ExportRoomRequest struct {
+	Passphrase string `json:"passphrase" binding:"omitempty,max=256"` // Defaults to the room's encryption key
+}
+
 type RoomResponse struct {
-	ID            string         `json:"id"`
-	JoinCode      string         `json:"join_code"`
-	Owner         UserResponse   `json:"owner"`
-	CreatedAt     time.Time      `json:"created_at"`
-	ExpiresAt     time.Time      `json:"expires_at"`
-	Members       []UserResponse `json:"members"`
-	CurrentUser   UserResponse   `json:"current_user"`
-	QRCodeURL     string         `json:"qr_code_url"`
-	EncryptionKey string         `json:"encryption_key"`
+	ID          string         `json:"id"`
+	JoinCode    string         `json:"join_code"`
+	Owner       UserResponse   `json:"owner"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	Members     []UserResponse `json:"members"`
+	CurrentUser UserResponse   `json:"current_user"`
+	QRCodeURL   string         `json:"qr_code_url"`
+	Name        string         `json:"name,omitempty"`
+	Topic       string         `json:"topic,omitempty"`
+	// Storage is left out when usage couldn't be looked up
+	Storage *StorageUsageResponse `json:"storage,omitempty"`
+}
+
+type StorageUsageResponse struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
 }
 
 type UserResponse struct {
@@ -38,12 +75,96 @@ type UserResponse struct {
 	Username string `json:"username"`
 }
 
+type PresenceResponse struct {
+	UserID       string     `json:"user_id"`
+	Username     string     `json:"username"`
+	Status       string     `json:"status"` // online, away or offline
+	LastActiveAt *time.Time `json:"last_active_at,omitempty"`
+}
+
+type RoomPresenceResponse struct {
+	RoomID  string             `json:"room_id"`
+	Members []PresenceResponse `json:"members"`
+}
+
+type MuteResponse struct {
+	RoomID    string    `json:"room_id"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	MutedAt   time.Time `json:"muted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type RoomBanResponse struct {
+	UserID   string    `json:"user_id"`
+	Reason   string    `json:"reason,omitempty"`
+	BannedBy string    `json:"banned_by"`
+	BannedAt time.Time `json:"banned_at"`
+}
+
+type RoomBansResponse struct {
+	RoomID string            `json:"room_id"`
+	Bans   []RoomBanResponse `json:"bans"`
+	Count  int               `json:"count"`
+}
+
+type CreateInviteRequest struct {
+	MaxUses          int `json:"max_uses" binding:"omitempty,min=0,max=1000"`            // 0 means unlimited
+	ExpiresInMinutes int `json:"expires_in_minutes" binding:"omitempty,min=0,max=43200"` // 0 lasts as long as the room
+}
+
+type RedeemInviteRequest struct {
+	Username string `json:"username" binding:"omitempty,max=50"`
+}
+
+type RoomInviteResponse struct {
+	Token     string     `json:"token"`
+	RoomID    string     `json:"room_id"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type RoomInvitesResponse struct {
+	RoomID  string               `json:"room_id"`
+	Invites []RoomInviteResponse `json:"invites"`
+	Count   int                  `json:"count"`
+}
+
+type RoomSettingsResponse struct {
+	RoomID                string `json:"room_id"`
+	Name                  string `json:"name,omitempty"`
+	Topic                 string `json:"topic,omitempty"`
+	MaxMembers            int    `json:"max_members"`
+	SlowModeSeconds       int    `json:"slow_mode_seconds"`
+	ReadOnly              bool   `json:"read_only"`
+	Moderation            string `json:"moderation"`
+	MessageRetentionHours int    `json:"message_retention_hours,omitempty"`
+	RetainUntilDeleted    bool   `json:"retain_until_deleted"`
+}
+
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error      string `json:"error"`
+	Message    string `json:"message,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"` // seconds, set for EXPORT_RATE_LIMITED
 }
 
 type SuccessResponse struct {
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
 }
+
+// RoomKeysResponse is the room key plus the retired ones, history sealed before a rotation needs them
+type RoomKeysResponse struct {
+	RoomID      string            `json:"room_id"`
+	KeyVersion  int               `json:"key_version"`
+	Key         string            `json:"key"`
+	RetiredKeys []RoomKeyResponse `json:"retired_keys,omitempty"`
+}
+
+type RoomKeyResponse struct {
+	KeyVersion int       `json:"key_version"`
+	Key        string    `json:"key"`
+	RetiredAt  time.Time `json:"retired_at"`
+}
@@ -1,10 +1,17 @@
 package room
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/export"
+	"github.com/hilthontt/visper/api/application/usecases/file"
 	"github.com/hilthontt/visper/api/application/usecases/room"
 	"github.com/hilthontt/visper/api/application/usecases/user"
 	"github.com/hilthontt/visper/api/domain/model"
@@ -26,28 +33,54 @@ type RoomController interface {
 	LeaveRoom(ctx *gin.Context)
 	CheckMembership(ctx *gin.Context)
 	KickMember(ctx *gin.Context)
+	MuteMember(ctx *gin.Context)
+	UnmuteMember(ctx *gin.Context)
+	BanMember(ctx *gin.Context)
+	UnbanMember(ctx *gin.Context)
+	ListBans(ctx *gin.Context)
+	CreateInvite(ctx *gin.Context)
+	ListInvites(ctx *gin.Context)
+	RevokeInvite(ctx *gin.Context)
+	RedeemInvite(ctx *gin.Context)
+	ExportRoom(ctx *gin.Context)
+	ExportTranscript(ctx *gin.Context)
+	GetPresence(ctx *gin.Context)
+	GetSettings(ctx *gin.Context)
+	UpdateSettings(ctx *gin.Context)
+	ExtendRoom(ctx *gin.Context)
+	GetKeys(ctx *gin.Context)
+	RotateKey(ctx *gin.Context)
 }
 
 type roomController struct {
 	usecase       room.RoomUseCase
 	userUsecase   user.UserUseCase
+	exportUsecase export.ExportUseCase
+	fileUsecase   file.FileUseCase
 	wsRoomManager *websocket.RoomManager
 	wsCore        *websocket.Core
+	memberTokens  *security.MemberTokenSigner
 	config        *config.Config
 }
 
 func NewRoomController(
 	usecase room.RoomUseCase,
 	userUsecase user.UserUseCase,
+	exportUsecase export.ExportUseCase,
+	fileUsecase file.FileUseCase,
 	wsRoomManager *websocket.RoomManager,
 	wsCore *websocket.Core,
+	memberTokens *security.MemberTokenSigner,
 	config *config.Config,
 ) RoomController {
 	return &roomController{
 		usecase:       usecase,
 		userUsecase:   userUsecase,
+		exportUsecase: exportUsecase,
+		fileUsecase:   fileUsecase,
 		wsRoomManager: wsRoomManager,
 		wsCore:        wsCore,
+		memberTokens:  memberTokens,
 		config:        config,
 	}
 }
@@ -57,7 +90,7 @@ func (c *roomController) GenerateNewJoinCode(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -66,23 +99,14 @@ func (c *roomController) GenerateNewJoinCode(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
 	room, err := c.usecase.GenerateNewJoinCode(ctx.Request.Context(), user.ID, roomID)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "only the room owner can update the room" {
-			status = http.StatusForbidden
-		} else if err.Error() == "room not found" {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "update_failed",
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -99,7 +123,7 @@ func (c *roomController) CreateRoom(ctx *gin.Context) {
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: middlewares.TranslateValidationError(err),
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
 		})
 		return
 	}
@@ -108,7 +132,7 @@ func (c *roomController) CreateRoom(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
@@ -119,20 +143,20 @@ func (c *roomController) CreateRoom(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "creation_failed",
-			Message: err.Error(),
+			Message: middlewares.Localize(ctx, err.Error()),
 		})
 		return
 	}
 
-	if err := security.SetRoomAuth(ctx.Writer, user, room.ID); err != nil {
+	if err := security.SetRoomAuth(ctx.Writer, c.memberTokens, user, room.ID); err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "auth_failed",
-			Message: "failed to set authentication",
+			Message: middlewares.Localize(ctx, "failed to set authentication"),
 		})
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, c.toRoomResponse(room, user))
+	ctx.JSON(http.StatusCreated, c.toRoomResponse(ctx, room, user))
 }
 
 func (c *roomController) GetRoom(ctx *gin.Context) {
@@ -140,7 +164,7 @@ func (c *roomController) GetRoom(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -149,25 +173,18 @@ func (c *roomController) GetRoom(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
 	room, err := c.usecase.GetByID(ctx.Request.Context(), roomID)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "room not found" || err.Error() == "room has expired" {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "not_found",
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, c.toRoomResponse(room, user))
+	ctx.JSON(http.StatusOK, c.toRoomResponse(ctx, room, user))
 }
 
 func (c *roomController) GetRoomByJoinCode(ctx *gin.Context) {
@@ -175,22 +192,14 @@ func (c *roomController) GetRoomByJoinCode(ctx *gin.Context) {
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: middlewares.TranslateValidationError(err),
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
 		})
 		return
 	}
 
 	room, err := c.usecase.GetByJoinCode(ctx.Request.Context(), req.JoinCode)
 	if err != nil {
-		status := http.StatusNotFound
-		if err.Error() != "room not found with join code: "+req.JoinCode &&
-			err.Error() != "room has expired" {
-			status = http.StatusInternalServerError
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "not_found",
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -198,7 +207,7 @@ func (c *roomController) GetRoomByJoinCode(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
@@ -207,32 +216,23 @@ func (c *roomController) GetRoomByJoinCode(ctx *gin.Context) {
 		user.Username = req.Username
 	}
 
-	if err := c.usecase.JoinRoom(ctx.Request.Context(), room.ID, *user); err != nil {
-		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "join_failed",
-			Message: err.Error(),
-		})
+	memberToken := security.MemberToken(room.ID, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err := c.usecase.JoinRoom(ctx.Request.Context(), room.ID, *user, memberToken); err != nil {
+		_ = ctx.Error(err)
 		return
 	}
 
-	if err := security.SetRoomAuth(ctx.Writer, user, room.ID); err != nil {
+	if err := security.SetRoomAuth(ctx.Writer, c.memberTokens, user, room.ID); err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "auth_failed",
-			Message: "failed to set authentication",
+			Message: middlewares.Localize(ctx, "failed to set authentication"),
 		})
 		return
 	}
 
 	room, err = c.usecase.GetByID(ctx.Request.Context(), room.ID)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "room not found" || err.Error() == "room has expired" {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "not_found",
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -242,7 +242,7 @@ func (c *roomController) GetRoomByJoinCode(ctx *gin.Context) {
 		JoinedAt: time.Now().Format(time.RFC3339),
 	})
 
-	ctx.JSON(http.StatusOK, c.toRoomResponse(room, user))
+	ctx.JSON(http.StatusOK, c.toRoomResponse(ctx, room, user))
 }
 
 func (c *roomController) DeleteRoom(ctx *gin.Context) {
@@ -250,7 +250,7 @@ func (c *roomController) DeleteRoom(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -259,22 +259,13 @@ func (c *roomController) DeleteRoom(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
 	if err := c.usecase.Delete(ctx.Request.Context(), roomID, user.ID); err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "only the room owner can delete the room" {
-			status = http.StatusForbidden
-		} else if err.Error() == "room not found" {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "deletion_failed",
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -293,7 +284,7 @@ func (c *roomController) JoinRoom(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -302,7 +293,7 @@ func (c *roomController) JoinRoom(ctx *gin.Context) {
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: middlewares.TranslateValidationError(err),
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
 		})
 		return
 	}
@@ -311,7 +302,7 @@ func (c *roomController) JoinRoom(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
@@ -320,22 +311,16 @@ func (c *roomController) JoinRoom(ctx *gin.Context) {
 		user.Username = req.Username
 	}
 
-	if err := c.usecase.JoinRoom(ctx.Request.Context(), roomID, *user); err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "room not found" || err.Error() == "room has expired" {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "join_failed",
-			Message: err.Error(),
-		})
+	memberToken := security.MemberToken(roomID, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err := c.usecase.JoinRoom(ctx.Request.Context(), roomID, *user, memberToken); err != nil {
+		_ = ctx.Error(err)
 		return
 	}
 
-	if err := security.SetRoomAuth(ctx.Writer, user, roomID); err != nil {
+	if err := security.SetRoomAuth(ctx.Writer, c.memberTokens, user, roomID); err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "auth_failed",
-			Message: "failed to set authentication",
+			Message: middlewares.Localize(ctx, "failed to set authentication"),
 		})
 		return
 	}
@@ -361,22 +346,14 @@ func (c *roomController) JoinRoomByJoinCode(ctx *gin.Context) {
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: middlewares.TranslateValidationError(err),
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
 		})
 		return
 	}
 
 	room, err := c.usecase.GetByJoinCode(ctx.Request.Context(), req.JoinCode)
 	if err != nil {
-		status := http.StatusNotFound
-		if err.Error() != "room not found with join code: "+req.JoinCode &&
-			err.Error() != "room has expired" {
-			status = http.StatusInternalServerError
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "not_found",
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -384,7 +361,7 @@ func (c *roomController) JoinRoomByJoinCode(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
@@ -393,22 +370,16 @@ func (c *roomController) JoinRoomByJoinCode(ctx *gin.Context) {
 		user.Username = req.Username
 	}
 
-	if err := c.usecase.JoinRoom(ctx.Request.Context(), room.ID, *user); err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "room not found" || err.Error() == "room has expired" {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "join_failed",
-			Message: err.Error(),
-		})
+	memberToken := security.MemberToken(room.ID, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err := c.usecase.JoinRoom(ctx.Request.Context(), room.ID, *user, memberToken); err != nil {
+		_ = ctx.Error(err)
 		return
 	}
 
-	if err := security.SetRoomAuth(ctx.Writer, user, room.ID); err != nil {
+	if err := security.SetRoomAuth(ctx.Writer, c.memberTokens, user, room.ID); err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "auth_failed",
-			Message: "failed to set authentication",
+			Message: middlewares.Localize(ctx, "failed to set authentication"),
 		})
 		return
 	}
@@ -420,7 +391,7 @@ func (c *roomController) JoinRoomByJoinCode(ctx *gin.Context) {
 	})
 	c.wsCore.Broadcast() <- joinMessage
 
-	ctx.JSON(http.StatusOK, c.toRoomResponse(room, user))
+	ctx.JSON(http.StatusOK, c.toRoomResponse(ctx, room, user))
 }
 
 func (c *roomController) LeaveRoom(ctx *gin.Context) {
@@ -428,7 +399,7 @@ func (c *roomController) LeaveRoom(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -437,22 +408,13 @@ func (c *roomController) LeaveRoom(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "room authentication required",
+			Message: middlewares.Localize(ctx, "room authentication required"),
 		})
 		return
 	}
 
 	if err := c.usecase.LeaveRoom(ctx.Request.Context(), roomID, user.ID); err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "room not found" {
-			status = http.StatusNotFound
-		} else if err.Error() == "room owner cannot leave, delete the room instead" {
-			status = http.StatusForbidden
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "leave_failed",
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -471,7 +433,7 @@ func (c *roomController) CheckMembership(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -489,7 +451,7 @@ func (c *roomController) CheckMembership(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "check_failed",
-			Message: err.Error(),
+			Message: middlewares.Localize(ctx, err.Error()),
 		})
 		return
 	}
@@ -506,7 +468,7 @@ func (c *roomController) KickMember(ctx *gin.Context) {
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
@@ -515,7 +477,7 @@ func (c *roomController) KickMember(ctx *gin.Context) {
 	if userToKickID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "user ID is required",
+			Message: middlewares.Localize(ctx, "user ID is required"),
 		})
 		return
 	}
@@ -524,7 +486,7 @@ func (c *roomController) KickMember(ctx *gin.Context) {
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
-			Message: "user to kick not found",
+			Message: middlewares.Localize(ctx, "user to kick not found"),
 		})
 		return
 	}
@@ -539,34 +501,14 @@ func (c *roomController) KickMember(ctx *gin.Context) {
 	}
 
 	if err := c.usecase.KickMember(ctx.Request.Context(), roomID, userToKickID, user.ID); err != nil {
-		status := http.StatusInternalServerError
-		errorCode := "kick_failed"
-
-		switch {
-		case err.Error() == "room not found":
-			status = http.StatusNotFound
-			errorCode = "not_found"
-		case err.Error() == "only the room owner can kick members":
-			status = http.StatusForbidden
-			errorCode = "forbidden"
-		case err.Error() == "room owner cannot be kicked, delete the room instead":
-			status = http.StatusForbidden
-			errorCode = "forbidden"
-		case err.Error() == "user is not a member of this room":
-			status = http.StatusNotFound
-			errorCode = "not_found"
-		}
-
-		ctx.JSON(status, ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
 	const reason = "Removed by room owner"
 	kickMessage := websocket.NewErrorKicked(roomID, userToKick.ID, userToKick.Username, reason)
 	c.wsCore.Broadcast() <- kickMessage
+	c.announceKeyRotation(ctx, roomID, "member_kicked")
 
 	ctx.JSON(http.StatusOK, SuccessResponse{
 		Message: "member kicked successfully",
@@ -578,12 +520,30 @@ func (c *roomController) KickMember(ctx *gin.Context) {
 	})
 }
 
-func (c *roomController) RegenerateSecureToken(ctx *gin.Context) {
+func (c *roomController) MuteMember(ctx *gin.Context) {
 	roomID := ctx.Param("id")
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: "room ID is required",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	userToMuteID := ctx.Param("userId")
+	if userToMuteID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "user ID is required"),
+		})
+		return
+	}
+
+	var req MuteMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
 		})
 		return
 	}
@@ -592,62 +552,160 @@ func (c *roomController) RegenerateSecureToken(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
-	room, err := c.usecase.RegenerateSecureCode(ctx.Request.Context(), user.ID, roomID)
+	userToMute, err := c.userUsecase.GetByID(ctx.Request.Context(), userToMuteID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: middlewares.Localize(ctx, "user to mute not found"),
+		})
+		return
+	}
+
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	mute, err := c.usecase.MuteMember(ctx.Request.Context(), roomID, userToMuteID, user.ID, duration)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "only the room owner can update the room" {
-			status = http.StatusForbidden
-		} else if err.Error() == "room not found" {
-			status = http.StatusNotFound
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewMemberMuted(roomID, userToMute.ID, userToMute.Username, mute.ExpiresAt.Format(time.RFC3339))
+
+	// Redis drops the mute on its own, this only tells clients when it happens
+	time.AfterFunc(duration, func() {
+		muted, err := c.usecase.IsMuted(context.Background(), roomID, userToMute.ID)
+		if err != nil || muted {
+			return
 		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "update_failed",
-			Message: err.Error(),
+		c.wsCore.Broadcast() <- websocket.NewMemberUnmuted(roomID, userToMute.ID, userToMute.Username)
+	})
+
+	ctx.JSON(http.StatusOK, MuteResponse{
+		RoomID:    roomID,
+		UserID:    userToMute.ID,
+		Username:  userToMute.Username,
+		MutedAt:   mute.MutedAt,
+		ExpiresAt: mute.ExpiresAt,
+	})
+}
+
+func (c *roomController) UnmuteMember(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	userToUnmuteID := ctx.Param("userId")
+	if userToUnmuteID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "user ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
+	if err := c.usecase.UnmuteMember(ctx.Request.Context(), roomID, userToUnmuteID, user.ID); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	username := ""
+	if userToUnmute, err := c.userUsecase.GetByID(ctx.Request.Context(), userToUnmuteID); err == nil {
+		username = userToUnmute.Username
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewMemberUnmuted(roomID, userToUnmuteID, username)
+
 	ctx.JSON(http.StatusOK, SuccessResponse{
-		Message: "secure token regenerated successfully",
+		Message: "member unmuted successfully",
 		Data: map[string]string{
-			"secure_code": room.SecureCode,
-			"qr_code_url": room.GetQRCodeURL(c.config.GetServerAddress()),
+			"room_id": roomID,
+			"user_id": userToUnmuteID,
 		},
 	})
 }
 
-func (c *roomController) JoinRoomByJoinCodeWithToken(ctx *gin.Context) {
-	var req JoinByCodeWithTokenRequest
+func (c *roomController) BanMember(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	var req BanMemberRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
-			Message: middlewares.TranslateValidationError(err),
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
 		})
 		return
 	}
 
-	room, err := c.usecase.GetByJoinCodeWithSecureToken(ctx.Request.Context(), req.JoinCode, req.SecureToken)
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	ban, err := c.usecase.BanMember(ctx.Request.Context(), roomID, req.UserID, user.ID, req.Reason)
 	if err != nil {
-		status := http.StatusNotFound
-		errorCode := "not_found"
-
-		if err.Error() == "invalid secure token" {
-			status = http.StatusForbidden
-			errorCode = "invalid_token"
-		} else if err.Error() != "room not found with join code: "+req.JoinCode &&
-			err.Error() != "room has expired" {
-			status = http.StatusInternalServerError
-			errorCode = "server_error"
-		}
+		_ = ctx.Error(err)
+		return
+	}
+
+	username := ""
+	if bannedUser, err := c.userUsecase.GetByID(ctx.Request.Context(), req.UserID); err == nil {
+		username = bannedUser.Username
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "Banned by room owner"
+	}
+	c.wsCore.Broadcast() <- websocket.NewErrorKicked(roomID, req.UserID, username, reason)
+	c.announceKeyRotation(ctx, roomID, "member_banned")
 
-		ctx.JSON(status, ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
+	ctx.JSON(http.StatusCreated, toRoomBanResponse(ban))
+}
+
+func (c *roomController) UnbanMember(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	userID := ctx.Param("userId")
+	if userID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "user ID is required"),
 		})
 		return
 	}
@@ -656,74 +714,793 @@ func (c *roomController) JoinRoomByJoinCodeWithToken(ctx *gin.Context) {
 	if !exists {
 		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "unauthorized",
-			Message: "user not found in context",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
-	if req.Username != "" {
-		user.Username = req.Username
+	if err := c.usecase.UnbanMember(ctx.Request.Context(), roomID, userID, user.ID); err != nil {
+		_ = ctx.Error(err)
+		return
 	}
 
-	if err := c.usecase.JoinRoom(ctx.Request.Context(), room.ID, *user); err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "room not found" || err.Error() == "room has expired" {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResponse{
-			Error:   "join_failed",
-			Message: err.Error(),
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "member unbanned successfully",
+		Data: map[string]string{
+			"room_id": roomID,
+			"user_id": userID,
+		},
+	})
+}
+
+func (c *roomController) ListBans(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
 		})
 		return
 	}
 
-	if err := security.SetRoomAuth(ctx.Writer, user, room.ID); err != nil {
-		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "auth_failed",
-			Message: "failed to set authentication",
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
 		})
 		return
 	}
 
-	joinMessage := websocket.NewMemberJoined(room.ID, websocket.MemberPayload{
-		UserID:   user.ID,
-		Username: user.Username,
-		JoinedAt: time.Now().Format(time.RFC3339),
+	bans, err := c.usecase.ListBans(ctx.Request.Context(), roomID, user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	responses := make([]RoomBanResponse, len(bans))
+	for i, ban := range bans {
+		responses[i] = toRoomBanResponse(ban)
+	}
+
+	ctx.JSON(http.StatusOK, RoomBansResponse{
+		RoomID: roomID,
+		Bans:   responses,
+		Count:  len(responses),
 	})
-	c.wsCore.Broadcast() <- joinMessage
+}
 
-	ctx.JSON(http.StatusOK, c.toRoomResponse(room, user))
+func toRoomBanResponse(ban *model.RoomBan) RoomBanResponse {
+	return RoomBanResponse{
+		UserID:   ban.UserID,
+		Reason:   ban.Reason,
+		BannedBy: ban.BannedBy,
+		BannedAt: ban.BannedAt,
+	}
 }
 
-func (c *roomController) toRoomResponse(room *model.Room, currentUser *model.User) RoomResponse {
-	members := make([]UserResponse, len(room.Members))
-	for i, member := range room.Members {
-		members[i] = UserResponse{
-			ID:       member.ID,
-			Username: member.Username,
-		}
+func (c *roomController) CreateInvite(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
 	}
 
-	expiresAt := room.CreatedAt.Add(room.Expiry)
-	if room.Expiry == 0 {
-		expiresAt = time.Time{} // Zero time if no expiry
+	var req CreateInviteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
 	}
 
-	return RoomResponse{
-		ID:        room.ID,
-		JoinCode:  room.JoinCode,
-		QRCodeURL: room.GetQRCodeURL(c.config.GetFrontEndURL()),
-		Owner: UserResponse{
-			ID:       room.Owner.ID,
-			Username: room.Owner.Username,
-		},
-		CreatedAt: room.CreatedAt,
-		ExpiresAt: expiresAt,
-		Members:   members,
-		CurrentUser: UserResponse{
-			ID:       currentUser.ID,
-			Username: currentUser.Username,
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInMinutes) * time.Minute
+	invite, err := c.usecase.CreateInvite(ctx.Request.Context(), roomID, user.ID, req.MaxUses, ttl)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, toRoomInviteResponse(invite))
+}
+
+func (c *roomController) ListInvites(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	invites, err := c.usecase.ListInvites(ctx.Request.Context(), roomID, user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	responses := make([]RoomInviteResponse, len(invites))
+	for i, invite := range invites {
+		responses[i] = toRoomInviteResponse(invite)
+	}
+
+	ctx.JSON(http.StatusOK, RoomInvitesResponse{
+		RoomID:  roomID,
+		Invites: responses,
+		Count:   len(responses),
+	})
+}
+
+func (c *roomController) RevokeInvite(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	token := ctx.Param("token")
+	if roomID == "" || token == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID and invite token are required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if err := c.usecase.RevokeInvite(ctx.Request.Context(), roomID, token, user.ID); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "invite revoked successfully",
+		Data: map[string]string{
+			"room_id": roomID,
 		},
-		EncryptionKey: room.EncryptionKey,
+	})
+}
+
+func (c *roomController) RedeemInvite(ctx *gin.Context) {
+	token := ctx.Param("token")
+	if token == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "invite token is required"),
+		})
+		return
+	}
+
+	var req RedeemInviteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if req.Username != "" {
+		user.Username = req.Username
+	}
+
+	// The member token is scoped to the room, which only the invite knows
+	invite, err := c.usecase.GetInvite(ctx.Request.Context(), token)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	memberToken := security.MemberToken(invite.RoomID, ctx.ClientIP(), ctx.Request.UserAgent())
+	room, err := c.usecase.RedeemInvite(ctx.Request.Context(), token, *user, memberToken)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	if err := security.SetRoomAuth(ctx.Writer, c.memberTokens, user, room.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "auth_failed",
+			Message: middlewares.Localize(ctx, "failed to set authentication"),
+		})
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewMemberJoined(room.ID, websocket.MemberPayload{
+		UserID:   user.ID,
+		Username: user.Username,
+		JoinedAt: time.Now().Format(time.RFC3339),
+	})
+
+	ctx.JSON(http.StatusOK, c.toRoomResponse(ctx, room, user))
+}
+
+func toRoomInviteResponse(invite *model.RoomInvite) RoomInviteResponse {
+	response := RoomInviteResponse{
+		Token:     invite.Token,
+		RoomID:    invite.RoomID,
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+		CreatedAt: invite.CreatedAt,
+	}
+	if !invite.ExpiresAt.IsZero() {
+		response.ExpiresAt = &invite.ExpiresAt
+	}
+	return response
+}
+
+func (c *roomController) RegenerateSecureToken(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	room, err := c.usecase.RegenerateSecureCode(ctx.Request.Context(), user.ID, roomID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "secure token regenerated successfully",
+		Data: map[string]string{
+			"secure_code": room.SecureCode,
+			"qr_code_url": room.GetQRCodeURL(c.config.GetServerAddress()),
+		},
+	})
+}
+
+func (c *roomController) JoinRoomByJoinCodeWithToken(ctx *gin.Context) {
+	var req JoinByCodeWithTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	room, err := c.usecase.GetByJoinCodeWithSecureToken(ctx.Request.Context(), req.JoinCode, req.SecureToken)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if req.Username != "" {
+		user.Username = req.Username
+	}
+
+	memberToken := security.MemberToken(room.ID, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err := c.usecase.JoinRoom(ctx.Request.Context(), room.ID, *user, memberToken); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	if err := security.SetRoomAuth(ctx.Writer, c.memberTokens, user, room.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "auth_failed",
+			Message: middlewares.Localize(ctx, "failed to set authentication"),
+		})
+		return
+	}
+
+	joinMessage := websocket.NewMemberJoined(room.ID, websocket.MemberPayload{
+		UserID:   user.ID,
+		Username: user.Username,
+		JoinedAt: time.Now().Format(time.RFC3339),
+	})
+	c.wsCore.Broadcast() <- joinMessage
+
+	ctx.JSON(http.StatusOK, c.toRoomResponse(ctx, room, user))
+}
+
+func (c *roomController) ExportRoom(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	// The body is optional, without it the archive is sealed with the room key
+	var req ExportRoomRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_request",
+				Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+			})
+			return
+		}
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	room, err := c.exportUsecase.PrepareExport(ctx.Request.Context(), roomID, user.ID, req.Passphrase)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	filename := fmt.Sprintf("visper-%s-%s.zip.enc", room.ID, time.Now().Format("20060102-150405"))
+	ctx.Header("Content-Type", "application/octet-stream")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(http.StatusOK)
+
+	// Headers are already sent, a failure here can only cut the stream short
+	if err := c.exportUsecase.WriteArchive(ctx.Request.Context(), room, req.Passphrase, ctx.Writer); err != nil {
+		_ = ctx.Error(err)
+	}
+}
+
+func (c *roomController) ExportTranscript(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	format, ok := export.ParseTranscriptFormat(ctx.Query("format"))
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "format must be one of json, txt or html"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	room, err := c.exportUsecase.PrepareTranscript(ctx.Request.Context(), roomID, user.ID)
+	var limitErr *export.ExportLimitError
+	if errors.As(err, &limitErr) {
+		retryAfter := int(math.Ceil(limitErr.RetryAfter.Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+		ctx.JSON(http.StatusTooManyRequests, ErrorResponse{
+			Error:      "EXPORT_RATE_LIMITED",
+			Message:    middlewares.Localize(ctx, err.Error()),
+			RetryAfter: retryAfter,
+		})
+		return
+	}
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	filename := fmt.Sprintf("visper-%s-%s.%s", room.ID, time.Now().Format("20060102-150405"), format)
+	ctx.Header("Content-Type", format.ContentType())
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(http.StatusOK)
+
+	// Headers are already sent, a failure here can only cut the stream short
+	if err := c.exportUsecase.WriteTranscript(ctx.Request.Context(), room, format, ctx.Writer); err != nil {
+		_ = ctx.Error(err)
+	}
+}
+
+func (c *roomController) GetPresence(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	room, err := c.usecase.GetByID(ctx.Request.Context(), roomID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: middlewares.Localize(ctx, "room not found"),
+		})
+		return
+	}
+
+	if !room.IsMember(user.ID) {
+		ctx.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "forbidden",
+			Message: middlewares.Localize(ctx, "you are not a member of this room"),
+		})
+		return
+	}
+
+	presence := c.wsCore.RoomPresence(roomID)
+
+	members := make([]PresenceResponse, 0, len(room.Members))
+	for _, member := range room.Members {
+		response := PresenceResponse{
+			UserID:   member.ID,
+			Username: member.Username,
+			Status:   string(websocket.PresenceOffline),
+		}
+		if entry, ok := presence[member.ID]; ok {
+			lastActiveAt := entry.LastActiveAt
+			response.Status = string(entry.Status)
+			response.LastActiveAt = &lastActiveAt
+		}
+		members = append(members, response)
+	}
+
+	ctx.JSON(http.StatusOK, RoomPresenceResponse{
+		RoomID:  roomID,
+		Members: members,
+	})
+}
+
+func (c *roomController) GetSettings(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	room, err := c.usecase.GetByID(ctx.Request.Context(), roomID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "not_found",
+			Message: middlewares.Localize(ctx, "room not found"),
+		})
+		return
+	}
+
+	if !room.IsMember(user.ID) {
+		ctx.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "forbidden",
+			Message: middlewares.Localize(ctx, "you are not a member of this room"),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, toRoomSettingsResponse(room))
+}
+
+func (c *roomController) UpdateSettings(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	var req UpdateRoomSettingsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	update := room.SettingsUpdate{
+		Name:               req.Name,
+		Topic:              req.Topic,
+		MaxMembers:         req.MaxMembers,
+		SlowModeSeconds:    req.SlowModeSeconds,
+		ReadOnly:           req.ReadOnly,
+		RetainUntilDeleted: req.RetainUntilDeleted,
+	}
+	if req.Moderation != nil {
+		moderation := model.ModerationMode(*req.Moderation)
+		update.Moderation = &moderation
+	}
+	if req.MessageRetentionHours != nil {
+		retention := time.Duration(*req.MessageRetentionHours) * time.Hour
+		update.MessageRetention = &retention
+	}
+
+	updatedRoom, err := c.usecase.UpdateSettings(ctx.Request.Context(), user.ID, roomID, update)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewRoomSettingsUpdated(roomID, websocket.RoomSettingsPayload{
+		Name:            updatedRoom.Settings.Name,
+		Topic:           updatedRoom.Settings.Topic,
+		MaxMembers:      updatedRoom.Settings.MaxMembers,
+		SlowModeSeconds: updatedRoom.Settings.SlowModeSeconds,
+		ReadOnly:        updatedRoom.Settings.ReadOnly,
+		Moderation:      string(updatedRoom.Settings.ModerationMode()),
+	})
+
+	ctx.JSON(http.StatusOK, toRoomSettingsResponse(updatedRoom))
+}
+
+func (c *roomController) ExtendRoom(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	var req ExtendRoomRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	extendedRoom, err := c.usecase.Extend(ctx.Request.Context(), roomID, user.ID, time.Duration(req.Hours)*time.Hour)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewRoomExtended(roomID, extendedRoom.CreatedAt.Add(extendedRoom.Expiry))
+
+	ctx.JSON(http.StatusOK, c.toRoomResponse(ctx, extendedRoom, user))
+}
+
+// GetKeys is the only place the room key is served, other room responses leave it out
+func (c *roomController) GetKeys(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	memberToken := security.MemberToken(roomID, ctx.ClientIP(), ctx.Request.UserAgent())
+	room, err := c.usecase.GetKeys(ctx.Request.Context(), roomID, user.ID, memberToken)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	// Keys must never end up in a shared cache
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, toRoomKeysResponse(room))
+}
+
+func (c *roomController) RotateKey(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	room, err := c.usecase.RotateKey(ctx.Request.Context(), roomID, user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewRoomKeyRotated(roomID, room.KeyVersion, "manual")
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, toRoomKeysResponse(room))
+}
+
+// announceKeyRotation follows a kick or ban, the use case has already rotated the key
+func (c *roomController) announceKeyRotation(ctx *gin.Context, roomID, reason string) {
+	room, err := c.usecase.GetByID(ctx.Request.Context(), roomID)
+	if err != nil {
+		return
+	}
+	c.wsCore.Broadcast() <- websocket.NewRoomKeyRotated(roomID, room.KeyVersion, reason)
+}
+
+func toRoomKeysResponse(room *model.Room) RoomKeysResponse {
+	response := RoomKeysResponse{
+		RoomID:     room.ID,
+		KeyVersion: room.KeyVersion,
+		Key:        room.EncryptionKey,
+	}
+	for _, key := range room.RetiredKeys {
+		response.RetiredKeys = append(response.RetiredKeys, RoomKeyResponse{
+			KeyVersion: key.Version,
+			Key:        key.Key,
+			RetiredAt:  key.RetiredAt,
+		})
+	}
+	return response
+}
+
+func toRoomSettingsResponse(room *model.Room) RoomSettingsResponse {
+	retention, _ := room.Settings.Retention()
+
+	return RoomSettingsResponse{
+		RoomID:                room.ID,
+		Name:                  room.Settings.Name,
+		Topic:                 room.Settings.Topic,
+		MaxMembers:            room.Settings.MaxMembers,
+		SlowModeSeconds:       room.Settings.SlowModeSeconds,
+		ReadOnly:              room.Settings.ReadOnly,
+		Moderation:            string(room.Settings.ModerationMode()),
+		MessageRetentionHours: int(retention / time.Hour),
+		RetainUntilDeleted:    room.Settings.RetainUntilDeleted,
+	}
+}
+
+func (c *roomController) toRoomResponse(ctx *gin.Context, room *model.Room, currentUser *model.User) RoomResponse {
+	members := make([]UserResponse, len(room.Members))
+	for i, member := range room.Members {
+		members[i] = UserResponse{
+			ID:       member.ID,
+			Username: member.Username,
+		}
+	}
+
+	expiresAt := room.CreatedAt.Add(room.Expiry)
+	if room.Expiry == 0 {
+		expiresAt = time.Time{} // Zero time if no expiry
+	}
+
+	return RoomResponse{
+		ID:        room.ID,
+		JoinCode:  room.JoinCode,
+		QRCodeURL: room.GetQRCodeURL(c.config.GetFrontEndURL()),
+		Owner: UserResponse{
+			ID:       room.Owner.ID,
+			Username: room.Owner.Username,
+		},
+		CreatedAt: room.CreatedAt,
+		ExpiresAt: expiresAt,
+		Members:   members,
+		CurrentUser: UserResponse{
+			ID:       currentUser.ID,
+			Username: currentUser.Username,
+		},
+		Name:    room.Settings.Name,
+		Topic:   room.Settings.Topic,
+		Storage: c.storageUsage(ctx, room.ID),
+	}
+}
+
+// storageUsage is best effort, a failed lookup shouldn't fail the room response
+func (c *roomController) storageUsage(ctx *gin.Context, roomID string) *StorageUsageResponse {
+	used, quota, err := c.fileUsecase.GetRoomUsage(ctx.Request.Context(), roomID)
+	if err != nil {
+		return nil
 	}
+	return &StorageUsageResponse{UsedBytes: used, QuotaBytes: quota}
 }
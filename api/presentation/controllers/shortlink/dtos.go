@@ -0,0 +1,21 @@
+package shortlink
+
+import "time"
+
+type CreateShortLinkRequest struct {
+	IncludeToken bool `json:"include_token"`
+}
+
+type ShortLinkResponse struct {
+	Code         string     `json:"code"`
+	URL          string     `json:"url"`
+	RoomID       string     `json:"room_id"`
+	IncludeToken bool       `json:"include_token"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+}
@@ -0,0 +1,90 @@
+package shortlink
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/shortlink"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+type ShortLinkController interface {
+	Create(ctx *gin.Context)
+	Redirect(ctx *gin.Context)
+}
+
+type shortLinkController struct {
+	usecase shortlink.ShortLinkUseCase
+}
+
+func NewShortLinkController(usecase shortlink.ShortLinkUseCase) ShortLinkController {
+	return &shortLinkController{
+		usecase: usecase,
+	}
+}
+
+func (c *shortLinkController) Create(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	// The body is optional, links default to join code only
+	var req CreateShortLinkRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_request",
+				Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+			})
+			return
+		}
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	link, err := c.usecase.Create(ctx.Request.Context(), roomID, user.ID, req.IncludeToken)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := ShortLinkResponse{
+		Code:         link.Code,
+		URL:          c.usecase.GetShortURL(link),
+		RoomID:       link.RoomID,
+		IncludeToken: link.IncludeToken,
+		CreatedAt:    link.CreatedAt,
+	}
+	if !link.ExpiresAt.IsZero() {
+		response.ExpiresAt = &link.ExpiresAt
+	}
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (c *shortLinkController) Redirect(ctx *gin.Context) {
+	code := ctx.Param("shortCode")
+
+	target, err := c.usecase.Resolve(ctx.Request.Context(), code)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	// Links can carry the secure token, keep them out of shared caches
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Referrer-Policy", "no-referrer")
+	ctx.Redirect(http.StatusFound, target)
+}
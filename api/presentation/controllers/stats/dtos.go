@@ -0,0 +1,26 @@
+package stats
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+type UsageStatsResponse struct {
+	ActiveRooms        int64  `json:"active_rooms"`
+	ConnectedClients   int    `json:"connected_clients"`
+	MessagesPerMinute  int64  `json:"messages_per_minute"`
+	UploadBytesPerHour int64  `json:"upload_bytes_per_hour"`
+	GeneratedAt        string `json:"generated_at"`
+}
+
+type RoomStatsResponse struct {
+	RoomID             string  `json:"room_id"`
+	Messages           int64   `json:"messages"`
+	MessageBytes       int64   `json:"message_bytes"`
+	AverageMessageSize float64 `json:"average_message_size"`
+	Members            int     `json:"members"`
+	ActiveMembers      int     `json:"active_members"`
+	Connections        int     `json:"connections"`
+	PeakConcurrency    int64   `json:"peak_concurrency"`
+	GeneratedAt        string  `json:"generated_at"`
+}
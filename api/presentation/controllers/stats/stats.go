@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/stats"
+	"github.com/hilthontt/visper/api/infrastructure/websocket"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+type StatsController interface {
+	GetUsageStats(ctx *gin.Context)
+	GetRoomStats(ctx *gin.Context)
+}
+
+type statsController struct {
+	usecase stats.StatsUseCase
+	wsCore  *websocket.Core
+}
+
+func NewStatsController(usecase stats.StatsUseCase, wsCore *websocket.Core) StatsController {
+	return &statsController{
+		usecase: usecase,
+		wsCore:  wsCore,
+	}
+}
+
+func (c *statsController) GetUsageStats(ctx *gin.Context) {
+	usage, err := c.usecase.GetUsageStats(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: middlewares.Localize(ctx, "Failed to get usage stats"),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, UsageStatsResponse{
+		ActiveRooms:        usage.ActiveRooms,
+		ConnectedClients:   c.wsCore.ClientCount(),
+		MessagesPerMinute:  usage.MessagesPerMinute,
+		UploadBytesPerHour: usage.UploadBytesPerHour,
+		GeneratedAt:        usage.GeneratedAt.Format(time.RFC3339),
+	})
+}
+
+// GetRoomStats is the owner's activity dashboard. Stored counters cover every instance,
+// active members and connections are what this instance sees right now.
+func (c *statsController) GetRoomStats(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	roomStats, err := c.usecase.GetRoomStats(ctx.Request.Context(), roomID, user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	activeMembers := 0
+	for _, entry := range c.wsCore.RoomPresence(roomID) {
+		if entry.Status == websocket.PresenceOnline {
+			activeMembers++
+		}
+	}
+
+	// The stored peak is written in the background, so it can trail the connections open right now
+	connections := c.wsCore.RoomConnectionCount(roomID)
+
+	ctx.JSON(http.StatusOK, RoomStatsResponse{
+		RoomID:             roomStats.RoomID,
+		Messages:           roomStats.Messages,
+		MessageBytes:       roomStats.MessageBytes,
+		AverageMessageSize: roomStats.AverageMessageSize(),
+		Members:            roomStats.Members,
+		ActiveMembers:      activeMembers,
+		Connections:        connections,
+		PeakConcurrency:    max(roomStats.PeakConcurrency, int64(connections)),
+		GeneratedAt:        roomStats.GeneratedAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,19 @@
+package user
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+type PurgeDataResponse struct {
+	UserID           string   `json:"user_id"`
+	MessagesDeleted  int      `json:"messages_deleted"`
+	FilesDeleted     int      `json:"files_deleted"`
+	BotsRevoked      int      `json:"bots_revoked"`
+	IdentityUnlinked bool     `json:"identity_unlinked"`
+	RoomsLeft        []string `json:"rooms_left"`
+	RoomsDeleted     []string `json:"rooms_deleted"`
+	UsernameReleased bool     `json:"username_released"`
+	Incomplete       bool     `json:"incomplete"` // some data couldn't be removed, retrying is safe
+	PurgedAt         string   `json:"purged_at"`
+}
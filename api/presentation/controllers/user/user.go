@@ -0,0 +1,71 @@
+package user
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/privacy"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+	"github.com/hilthontt/visper/api/infrastructure/websocket"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+type UserController interface {
+	PurgeData(ctx *gin.Context)
+}
+
+type userController struct {
+	privacyUseCase privacy.PrivacyUseCase
+	wsCore         *websocket.Core
+}
+
+func NewUserController(privacyUseCase privacy.PrivacyUseCase, wsCore *websocket.Core) UserController {
+	return &userController{
+		privacyUseCase: privacyUseCase,
+		wsCore:         wsCore,
+	}
+}
+
+func (c *userController) PurgeData(ctx *gin.Context) {
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	summary, err := c.privacyUseCase.PurgeUserData(ctx.Request.Context(), user.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "purge_failed",
+			Message: middlewares.Localize(ctx, "failed to purge user data"),
+		})
+		return
+	}
+
+	for _, roomID := range summary.RoomsLeft {
+		security.ClearRoomAuth(ctx.Writer, roomID)
+		c.wsCore.Broadcast() <- websocket.NewMemberLeft(roomID, user.ID, user.Username)
+	}
+
+	for _, roomID := range summary.RoomsDeleted {
+		security.ClearRoomAuth(ctx.Writer, roomID)
+		c.wsCore.Broadcast() <- websocket.NewRoomDeleted(roomID)
+	}
+
+	ctx.JSON(http.StatusOK, PurgeDataResponse{
+		UserID:           summary.UserID,
+		MessagesDeleted:  summary.MessagesDeleted,
+		FilesDeleted:     summary.FilesDeleted,
+		BotsRevoked:      summary.BotsRevoked,
+		IdentityUnlinked: summary.IdentityUnlinked,
+		RoomsLeft:        summary.RoomsLeft,
+		RoomsDeleted:     summary.RoomsDeleted,
+		UsernameReleased: summary.UsernameReleased,
+		Incomplete:       summary.Incomplete,
+		PurgedAt:         summary.PurgedAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import "time"
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url,max=2048"`
+	Events []string `json:"events" binding:"omitempty,max=10,dive,oneof=message.sent member.joined member.left member.kicked member.banned"`
+}
+
+type IncomingMessageRequest struct {
+	Content  string `json:"content" binding:"required,max=2000"`
+	Username string `json:"username" binding:"omitempty,max=50"`
+}
+
+type WebhookResponse struct {
+	ID        string    `json:"id"`
+	RoomID    string    `json:"room_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"` // only returned when the webhook is created
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhooksResponse struct {
+	RoomID   string            `json:"room_id"`
+	Webhooks []WebhookResponse `json:"webhooks"`
+	Count    int               `json:"count"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Attempts   int       `json:"attempts"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDeliveriesResponse struct {
+	WebhookID  string                    `json:"webhook_id"`
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Count      int                       `json:"count"`
+}
+
+type IncomingWebhookResponse struct {
+	RoomID string `json:"room_id"`
+	Token  string `json:"token"`
+	URL    string `json:"url"`
+}
+
+type IncomingMessageResponse struct {
+	ID        string    `json:"id"`
+	RoomID    string    `json:"room_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ErrorResponse struct {
+	Error      string `json:"error"`
+	Message    string `json:"message,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+type SuccessResponse struct {
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -0,0 +1,320 @@
+package webhook
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/application/usecases/message"
+	"github.com/hilthontt/visper/api/application/usecases/webhook"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/websocket"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+const (
+	// Messages posted through the incoming webhook are attributed to this user ID
+	incomingUserID   = "webhook"
+	incomingUsername = "Webhook"
+
+	defaultDeliveryLimit = 20
+)
+
+type WebhookController interface {
+	CreateWebhook(ctx *gin.Context)
+	ListWebhooks(ctx *gin.Context)
+	DeleteWebhook(ctx *gin.Context)
+	ListDeliveries(ctx *gin.Context)
+	CreateIncomingToken(ctx *gin.Context)
+	RevokeIncomingToken(ctx *gin.Context)
+	PostIncoming(ctx *gin.Context)
+}
+
+type webhookController struct {
+	usecase        webhook.WebhookUseCase
+	messageUsecase message.MessageUseCase
+	wsCore         *websocket.Core
+	serverURL      string
+}
+
+func NewWebhookController(
+	usecase webhook.WebhookUseCase,
+	messageUsecase message.MessageUseCase,
+	wsCore *websocket.Core,
+	serverURL string,
+) WebhookController {
+	return &webhookController{
+		usecase:        usecase,
+		messageUsecase: messageUsecase,
+		wsCore:         wsCore,
+		serverURL:      serverURL,
+	}
+}
+
+func (c *webhookController) CreateWebhook(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+
+	var req CreateWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	events := make([]model.WebhookEvent, len(req.Events))
+	for i, event := range req.Events {
+		events[i] = model.WebhookEvent(event)
+	}
+
+	created, err := c.usecase.Create(ctx.Request.Context(), roomID, user.ID, req.URL, events)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := toWebhookResponse(created)
+	response.Secret = created.Secret
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (c *webhookController) ListWebhooks(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	webhooks, err := c.usecase.List(ctx.Request.Context(), roomID, user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	responses := make([]WebhookResponse, len(webhooks))
+	for i, webhook := range webhooks {
+		responses[i] = toWebhookResponse(webhook)
+	}
+
+	ctx.JSON(http.StatusOK, WebhooksResponse{
+		RoomID:   roomID,
+		Webhooks: responses,
+		Count:    len(responses),
+	})
+}
+
+func (c *webhookController) DeleteWebhook(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	webhookID := ctx.Param("webhookId")
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if err := c.usecase.Delete(ctx.Request.Context(), roomID, webhookID, user.ID); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "webhook deleted successfully",
+	})
+}
+
+func (c *webhookController) ListDeliveries(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	webhookID := ctx.Param("webhookId")
+
+	limit, err := strconv.ParseInt(ctx.DefaultQuery("limit", strconv.Itoa(defaultDeliveryLimit)), 10, 64)
+	if err != nil || limit <= 0 {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, "invalid limit"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	deliveries, err := c.usecase.GetDeliveries(ctx.Request.Context(), roomID, webhookID, user.ID, limit)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	responses := make([]WebhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		responses[i] = WebhookDeliveryResponse{
+			ID:         delivery.ID,
+			Event:      string(delivery.Event),
+			StatusCode: delivery.StatusCode,
+			Attempts:   delivery.Attempts,
+			Success:    delivery.Success,
+			Error:      delivery.Error,
+			CreatedAt:  delivery.CreatedAt,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, WebhookDeliveriesResponse{
+		WebhookID:  webhookID,
+		Deliveries: responses,
+		Count:      len(responses),
+	})
+}
+
+func (c *webhookController) CreateIncomingToken(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	token, err := c.usecase.CreateIncomingToken(ctx.Request.Context(), roomID, user.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, IncomingWebhookResponse{
+		RoomID: roomID,
+		Token:  token,
+		URL:    c.serverURL + "/api/v1/hooks/" + token,
+	})
+}
+
+func (c *webhookController) RevokeIncomingToken(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "unauthorized",
+			Message: middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	if err := c.usecase.RevokeIncomingToken(ctx.Request.Context(), roomID, user.ID); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SuccessResponse{
+		Message: "incoming webhook revoked successfully",
+	})
+}
+
+// PostIncoming is authenticated by the token alone, external systems don't carry a Visper user
+func (c *webhookController) PostIncoming(ctx *gin.Context) {
+	room, err := c.usecase.ResolveIncomingToken(ctx.Request.Context(), ctx.Param("token"))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	var req IncomingMessageRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: middlewares.Localize(ctx, middlewares.TranslateValidationError(err)),
+		})
+		return
+	}
+
+	username := req.Username
+	if username == "" {
+		username = incomingUsername
+	}
+
+	msg, err := c.messageUsecase.Send(ctx.Request.Context(), room.ID, incomingUserID, username, req.Content, false, 0, "", nil)
+	var slowModeErr *message.SlowModeError
+	if errors.As(err, &slowModeErr) {
+		retryAfter := int(math.Ceil(slowModeErr.RetryAfter.Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+		ctx.JSON(http.StatusTooManyRequests, ErrorResponse{
+			Error:      "SLOW_MODE",
+			Message:    middlewares.Localize(ctx, err.Error()),
+			RetryAfter: retryAfter,
+		})
+		return
+	}
+	var blockedErr *message.ContentBlockedError
+	if errors.As(err, &blockedErr) {
+		ctx.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "CONTENT_BLOCKED",
+			Message: middlewares.Localize(ctx, err.Error()),
+		})
+		return
+	}
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	c.wsCore.Broadcast() <- websocket.NewMessageReceived(
+		room.ID,
+		msg.ID,
+		msg.Content,
+		msg.UserID,
+		msg.Username,
+		msg.CreatedAt.String(),
+		msg.Encrypted,
+		msg.KeyVersion,
+	)
+
+	ctx.JSON(http.StatusCreated, IncomingMessageResponse{
+		ID:        msg.ID,
+		RoomID:    room.ID,
+		CreatedAt: msg.CreatedAt,
+	})
+}
+
+func toWebhookResponse(webhook *model.RoomWebhook) WebhookResponse {
+	events := make([]string, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = string(event)
+	}
+
+	return WebhookResponse{
+		ID:        webhook.ID,
+		RoomID:    webhook.RoomID,
+		URL:       webhook.URL,
+		Events:    events,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
@@ -1,5 +1,13 @@
 package websocket
 
+import "time"
+
 type NotifySelfRoomInviteRequest struct {
 	UserID string `json:"user_id" binding:"required"`
 }
+
+// SocketTicketResponse is sent back in the auth frame, the ticket works once and only briefly
+type SocketTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
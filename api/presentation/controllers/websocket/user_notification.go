@@ -10,7 +10,6 @@ import (
 	roomUseCase "github.com/hilthontt/visper/api/application/usecases/room"
 	userUseCase "github.com/hilthontt/visper/api/application/usecases/user"
 	"github.com/hilthontt/visper/api/domain/model"
-	"github.com/hilthontt/visper/api/infrastructure/security"
 	"github.com/hilthontt/visper/api/infrastructure/websocket"
 	"github.com/hilthontt/visper/api/presentation/middlewares"
 )
@@ -44,7 +43,7 @@ func (c *userNotificationController) HandleUserNotificationConnection(ctx *gin.C
 		log.Printf("Failed to authenticate user for notification WebSocket: %v", err)
 		ctx.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "unauthorized",
-			"message": "authentication required",
+			"message": middlewares.Localize(ctx, "authentication required"),
 		})
 		return
 	}
@@ -54,7 +53,7 @@ func (c *userNotificationController) HandleUserNotificationConnection(ctx *gin.C
 		log.Printf("WebSocket upgrade failed for user %s: %v", user.ID, err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "upgrade_failed",
-			"message": "failed to upgrade connection",
+			"message": middlewares.Localize(ctx, "failed to upgrade connection"),
 		})
 		return
 	}
@@ -75,7 +74,7 @@ func (c *userNotificationController) NotifySelfRoomInvite(ctx *gin.Context) {
 	if joinCode == "" || secureCode == "" {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "invalid_request",
-			"message": "join_code and secure_code query parameters are required",
+			"message": middlewares.Localize(ctx, "join_code and secure_code query parameters are required"),
 		})
 		return
 	}
@@ -84,7 +83,7 @@ func (c *userNotificationController) NotifySelfRoomInvite(ctx *gin.Context) {
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "invalid_request",
-			"message": "user_id is required in request body",
+			"message": middlewares.Localize(ctx, "user_id is required in request body"),
 		})
 		return
 	}
@@ -98,7 +97,7 @@ func (c *userNotificationController) NotifySelfRoomInvite(ctx *gin.Context) {
 		log.Printf("Failed to get room with join code %s: %v", joinCode, err)
 		ctx.JSON(http.StatusNotFound, gin.H{
 			"error":   "room_not_found",
-			"message": "invalid join code or secure code",
+			"message": middlewares.Localize(ctx, "invalid join code or secure code"),
 		})
 		return
 	}
@@ -108,7 +107,7 @@ func (c *userNotificationController) NotifySelfRoomInvite(ctx *gin.Context) {
 		log.Printf("Failed to get/create user %s: %v", req.UserID, err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "user_error",
-			"message": "failed to process user",
+			"message": middlewares.Localize(ctx, "failed to process user"),
 		})
 		return
 	}
@@ -146,25 +145,12 @@ func (c *userNotificationController) NotifySelfRoomInvite(ctx *gin.Context) {
 	})
 }
 
+// getUserFromRequest trusts only the user UserMiddleware verified the member token of
 func (c *userNotificationController) getUserFromRequest(ctx *gin.Context) (*model.User, error) {
 	if user, exists := middlewares.GetUserFromContext(ctx); exists {
 		log.Printf("User authenticated via middleware context: %s", user.ID)
 		return user, nil
 	}
 
-	if user, err := security.GetRoomAuth(ctx.Request); err == nil {
-		log.Printf("User authenticated via room auth cookie: %s", user.ID)
-		return user, nil
-	}
-
-	if headerUserID := ctx.GetHeader("X-User-ID"); headerUserID != "" {
-		user, err := c.userUseCase.GetOrCreateUser(ctx.Request.Context(), headerUserID)
-		if err == nil {
-			log.Printf("User authenticated via X-User-ID header: %s", user.ID)
-			return user, nil
-		}
-		log.Printf("Failed to get user from X-User-ID header: %v", err)
-	}
-
 	return nil, fmt.Errorf("no valid authentication found")
 }
@@ -1,15 +1,20 @@
 package websocket
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	gorillaws "github.com/gorilla/websocket"
 	"github.com/hilthontt/visper/api/application/usecases/room"
 	userUseCase "github.com/hilthontt/visper/api/application/usecases/user"
+	"github.com/hilthontt/visper/api/domain/apperror"
 	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
 	"github.com/hilthontt/visper/api/infrastructure/security"
 	"github.com/hilthontt/visper/api/infrastructure/websocket"
 	"github.com/hilthontt/visper/api/presentation/middlewares"
@@ -17,6 +22,7 @@ import (
 
 type WebSocketController interface {
 	HandleConnection(ctx *gin.Context)
+	IssueTicket(ctx *gin.Context)
 }
 
 type webSocketController struct {
@@ -24,6 +30,10 @@ type webSocketController struct {
 	userUseCase   userUseCase.UserUseCase
 	wsRoomManager *websocket.RoomManager
 	wsCore        *websocket.Core
+	metrics       metrics.Manager
+	// requireTicket refuses sockets that don't open with an auth frame, otherwise only
+	// clients that ask for it with ?auth=ticket go through the handshake
+	requireTicket bool
 }
 
 func NewWebSocketController(
@@ -31,21 +41,67 @@ func NewWebSocketController(
 	userUseCase userUseCase.UserUseCase,
 	wsRoomManager *websocket.RoomManager,
 	wsCore *websocket.Core,
+	metrics metrics.Manager,
+	requireTicket bool,
 ) WebSocketController {
 	return &webSocketController{
 		roomUseCase:   roomUseCase,
 		userUseCase:   userUseCase,
 		wsRoomManager: wsRoomManager,
 		wsCore:        wsCore,
+		metrics:       metrics,
+		requireTicket: requireTicket,
 	}
 }
 
+// IssueTicket hands out the ticket a client sends in its auth frame once the socket is open
+func (c *webSocketController) IssueTicket(ctx *gin.Context) {
+	roomID := ctx.Param("id")
+	if roomID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_request",
+			"message": middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	user, exists := middlewares.GetUserFromContext(ctx)
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": middlewares.Localize(ctx, "user not found in context"),
+		})
+		return
+	}
+
+	memberToken := security.MemberToken(roomID, ctx.ClientIP(), ctx.Request.UserAgent())
+	ticket, err := c.roomUseCase.IssueSocketTicket(ctx.Request.Context(), roomID, user.ID, memberToken)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, SocketTicketResponse{
+		Ticket:    ticket.Token,
+		ExpiresAt: ticket.ExpiresAt,
+	})
+}
+
 func (c *webSocketController) HandleConnection(ctx *gin.Context) {
 	roomID := ctx.Param("id")
 	if roomID == "" {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "invalid_request",
-			"message": "room ID is required",
+			"message": middlewares.Localize(ctx, "room ID is required"),
+		})
+		return
+	}
+
+	if !c.wsRoomManager.AllowsOrigin(ctx.Request) {
+		c.rejected("origin")
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error":   "forbidden",
+			"message": middlewares.Localize(ctx, "origin not allowed"),
 		})
 		return
 	}
@@ -53,30 +109,25 @@ func (c *webSocketController) HandleConnection(ctx *gin.Context) {
 	user, err := c.getUserFromRequest(ctx)
 	if err != nil {
 		log.Printf("Failed to authenticate user for WebSocket: %v", err)
+		c.rejected("unauthenticated")
 		ctx.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "unauthorized",
-			"message": "authentication required - please provide X-User-ID header or valid cookies",
+			"message": middlewares.Localize(ctx, "authentication required - please provide a member token"),
 		})
 		return
 	}
 
 	room, err := c.roomUseCase.GetByID(ctx.Request.Context(), roomID)
 	if err != nil {
-		status := http.StatusNotFound
-		if err.Error() != "room not found" && err.Error() != "room has expired" {
-			status = http.StatusInternalServerError
-		}
-		ctx.JSON(status, gin.H{
-			"error":   "room_error",
-			"message": err.Error(),
-		})
+		_ = ctx.Error(err)
 		return
 	}
 
 	if !room.IsMember(user.ID) {
+		c.rejected("not_member")
 		ctx.JSON(http.StatusForbidden, gin.H{
 			"error":   "forbidden",
-			"message": "you are not a member of this room",
+			"message": middlewares.Localize(ctx, "you are not a member of this room"),
 		})
 		return
 	}
@@ -86,12 +137,27 @@ func (c *webSocketController) HandleConnection(ctx *gin.Context) {
 		log.Printf("WebSocket upgrade failed for user %s in room %s: %v", user.ID, roomID, err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "upgrade_failed",
-			"message": "failed to upgrade connection",
+			"message": middlewares.Localize(ctx, "failed to upgrade connection"),
 		})
 		return
 	}
 
+	if c.requireTicket || ctx.Query("auth") == "ticket" {
+		if err := c.authenticate(ctx, conn, roomID, user.ID); err != nil {
+			log.Printf("WebSocket auth failed for user %s in room %s: %v", user.ID, roomID, err)
+			return
+		}
+	}
+
 	client := websocket.NewClient(conn, user.ID, roomID, user.Username)
+	// A bad last_seq is treated like a fresh connection rather than refusing it
+	if lastSeq, err := strconv.ParseUint(ctx.Query("last_seq"), 10, 64); err == nil {
+		client.LastSeq = lastSeq
+		client.LastEpoch = ctx.Query("last_epoch")
+	}
+	if acks, _ := strconv.ParseBool(ctx.Query("acks")); acks {
+		client.EnableAcks()
+	}
 	c.wsCore.Register() <- client
 
 	joinMessage := websocket.NewMemberJoined(roomID, websocket.MemberPayload{
@@ -101,29 +167,48 @@ func (c *webSocketController) HandleConnection(ctx *gin.Context) {
 	})
 	c.wsCore.Broadcast() <- joinMessage
 
-	go client.WriteMessage()
+	go client.WriteMessage(c.wsCore)
 	go client.ReadMessage(c.wsCore)
 }
 
+// getUserFromRequest trusts only the user UserMiddleware verified the member token of
 func (c *webSocketController) getUserFromRequest(ctx *gin.Context) (*model.User, error) {
 	if user, exists := middlewares.GetUserFromContext(ctx); exists {
 		log.Printf("User authenticated via middleware context: %s", user.ID)
 		return user, nil
 	}
 
-	if user, err := security.GetRoomAuth(ctx.Request); err == nil {
-		log.Printf("User authenticated via room auth cookie: %s", user.ID)
-		return user, nil
+	return nil, fmt.Errorf("no valid authentication found")
+}
+
+// authenticate runs the ticket handshake on a freshly upgraded connection, closing it on failure
+func (c *webSocketController) authenticate(ctx *gin.Context, conn *gorillaws.Conn, roomID, userID string) error {
+	frame, err := websocket.ReadAuthFrame(conn)
+	if err != nil {
+		c.rejected("handshake")
+		websocket.Reject(conn, websocket.NewAuthenticationError(roomID, "the first frame must be an auth frame"))
+		return err
 	}
 
-	if headerUserID := ctx.GetHeader("X-User-ID"); headerUserID != "" {
-		user, err := c.userUseCase.GetOrCreateUser(ctx.Request.Context(), headerUserID)
-		if err == nil {
-			log.Printf("User authenticated via X-User-ID header: %s", user.ID)
-			return user, nil
+	// The hijacked request's context can't be relied on any more
+	redeemCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	memberToken := security.MemberToken(roomID, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err := c.roomUseCase.RedeemSocketTicket(redeemCtx, roomID, userID, frame.Ticket, memberToken); err != nil {
+		c.rejected("ticket")
+
+		message := "authentication failed"
+		if appErr, ok := apperror.As(err); ok && appErr.Kind != apperror.KindInternal {
+			message = appErr.Message
 		}
-		log.Printf("Failed to get user from X-User-ID header: %v", err)
+		websocket.Reject(conn, websocket.NewAuthenticationError(roomID, message))
+		return err
 	}
 
-	return nil, fmt.Errorf("no valid authentication found")
+	return nil
+}
+
+func (c *webSocketController) rejected(reason string) {
+	c.metrics.IncrementCounter(context.Background(), "websocket_auth_rejections", "reason", reason)
 }
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	authUseCase "github.com/hilthontt/visper/api/application/usecases/auth"
+	"github.com/hilthontt/visper/api/infrastructure/config"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+)
+
+const (
+	AdminTokenHeader = "X-Admin-Token"
+	AdminActorKey    = "admin_actor"
+
+	// adminTokenActor is who acted when the shared admin token was used
+	adminTokenActor = "admin-token"
+)
+
+// AdminMiddleware lets in requests with the admin token, and when OIDC login is enabled
+// members signed in with an account granted the admin role
+func AdminMiddleware(cfg *config.Config, tokens *security.MemberTokenSigner, identities authUseCase.AuthUseCase) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.Admin.Token == "" && identities == nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "admin_disabled",
+				"message": Localize(c, "Admin API is disabled, no admin token configured"),
+			})
+			c.Abort()
+			return
+		}
+
+		token := getAdminTokenFromRequest(c)
+		if cfg.Admin.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Admin.Token)) == 1 {
+			c.Set(AdminActorKey, adminTokenActor)
+			c.Next()
+			return
+		}
+
+		if identities != nil {
+			if userID, ok := signedInAdmin(c, tokens, identities); ok {
+				c.Set(AdminActorKey, userID)
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": Localize(c, "A valid admin token is required"),
+		})
+		c.Abort()
+	}
+}
+
+// signedInAdmin checks the member token of the request, a room scoped one never grants
+// admin access
+func signedInAdmin(c *gin.Context, tokens *security.MemberTokenSigner, identities authUseCase.AuthUseCase) (string, bool) {
+	memberToken := security.GetMemberToken(c.Request)
+	if memberToken == "" {
+		return "", false
+	}
+
+	claims, err := tokens.Verify(memberToken)
+	if err != nil || claims.RoomID != "" {
+		return "", false
+	}
+
+	admin, err := identities.IsAdmin(c.Request.Context(), claims.UserID)
+	return claims.UserID, err == nil && admin
+}
+
+// GetAdminActor returns who is calling the admin API, a signed in admin's user ID or
+// "admin-token"
+func GetAdminActor(c *gin.Context) string {
+	return c.GetString(AdminActorKey)
+}
+
+func getAdminTokenFromRequest(c *gin.Context) string {
+	if token := c.GetHeader(AdminTokenHeader); token != "" {
+		return token
+	}
+
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
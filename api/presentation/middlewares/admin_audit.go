@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/events"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// AdminAuditMiddleware writes every admin action to the audit log, whether it succeeded
+// or not. It goes after AdminMiddleware so turned away requests aren't logged as actions,
+// and reads are left out, they change nothing.
+func AdminAuditMiddleware(publisher *events.EventPublisher, logger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if isReadOnlyMethod(c.Request.Method) {
+			return
+		}
+
+		data := map[string]any{
+			"action": c.Request.Method + " " + c.FullPath(),
+			"status": responseStatus(c),
+			"ip":     c.ClientIP(),
+		}
+		for _, param := range c.Params {
+			data[param.Key] = param.Value
+		}
+		for key, values := range c.Request.URL.Query() {
+			if _, taken := data[key]; !taken {
+				data[key] = values[0]
+			}
+		}
+
+		// The audit entry is written even when the client hung up before the answer
+		ctx := context.WithoutCancel(c.Request.Context())
+		if err := publisher.PublishAdminAction(ctx, GetAdminActor(c), c.Param("id"), data); err != nil {
+			logger.Error("failed to publish admin action", zap.Error(err), zap.String("action", data["action"].(string)))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuditMiddlewareStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"handler error", "/rooms/room-1/kick/user-2", http.StatusForbidden},
+		{"internal handler error", "/rooms/room-1/broken", http.StatusInternalServerError},
+		{"written response", "/rooms/room-1/ok", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, b := newAuditRouter(t, AdminAuditMiddleware)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("response status = %d, want %d", rec.Code, tt.status)
+			}
+
+			entry := awaitAuditEntry(t, b)
+			if got := entry.Data["status"]; got != float64(tt.status) {
+				t.Errorf("audited status = %v, want %d", got, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/events"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// Invite and webhook tokens grant access on their own, the audit log only notes that one was used
+var auditRedactedParams = map[string]bool{"token": true}
+
+// AuditMiddleware records every change made through the API: who, which route, the entity
+// it touched, how it went and from where. It goes after the user or bot middleware so the
+// actor is known, requests turned away before that changed nothing and aren't recorded.
+func AuditMiddleware(publisher *events.EventPublisher, logger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		// An unmatched route did nothing either
+		route := c.FullPath()
+		if isReadOnlyMethod(c.Request.Method) || route == "" {
+			return
+		}
+
+		status := responseStatus(c)
+		data := map[string]any{
+			"action":  c.Request.Method + " " + route,
+			"status":  status,
+			"outcome": auditOutcome(status),
+			"ip":      c.ClientIP(),
+		}
+		for _, param := range c.Params {
+			value := param.Value
+			if auditRedactedParams[param.Key] {
+				value = "[redacted]"
+			}
+			data[param.Key] = value
+			// The last parameter is the most specific, /rooms/:id/messages/:messageId touches the message
+			data["entity_id"] = value
+		}
+
+		// Routes under /rooms/:id act on that room, others have no room to key the event by
+		roomID := ""
+		if strings.Contains(route, "/rooms/:id") {
+			roomID = c.Param("id")
+		}
+
+		actor := auditActor(c)
+		ctx := context.WithoutCancel(c.Request.Context())
+		go func() {
+			if err := publisher.PublishAPIAction(ctx, actor, roomID, data); err != nil {
+				logger.Error("failed to publish api action", zap.Error(err), zap.String("action", data["action"].(string)))
+			}
+		}()
+	}
+}
+
+func auditActor(c *gin.Context) string {
+	if user, ok := GetUserFromContext(c); ok {
+		return user.ID
+	}
+	if bot, ok := GetBotFromContext(c); ok {
+		return "bot:" + bot.ID
+	}
+	return ""
+}
+
+func auditOutcome(status int) string {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return "error"
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return "denied"
+	case status >= http.StatusBadRequest:
+		return "rejected"
+	default:
+		return "success"
+	}
+}
@@ -0,0 +1,116 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/infrastructure/broker"
+	"github.com/hilthontt/visper/api/infrastructure/events"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+)
+
+const auditTestTopic = "audit"
+
+// newAuditRouter puts ErrorMiddleware on the router and the audit middleware on a group, the way
+// the API is wired, so the audit runs before the error response is written
+func newAuditRouter(t *testing.T, audit func(*events.EventPublisher, *logger.Logger) gin.HandlerFunc) (*gin.Engine, *broker.Broker) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	b, err := broker.NewBroker(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+
+	publisher, err := events.NewEventPublisher(b, auditTestTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := logger.NewDevelopmentLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(ErrorMiddleware(log))
+	group := router.Group("/")
+	group.Use(audit(publisher, log))
+	group.POST("/rooms/:id/kick/:userId", func(ctx *gin.Context) {
+		_ = ctx.Error(apperror.ErrNotOwner)
+	})
+	group.POST("/rooms/:id/broken", func(ctx *gin.Context) {
+		_ = ctx.Error(http.ErrBodyNotAllowed)
+	})
+	group.POST("/rooms/:id/ok", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+	return router, b
+}
+
+// awaitAuditEntry waits for the one entry the request published, the audit middleware may publish
+// in the background
+func awaitAuditEntry(t *testing.T, b *broker.Broker) events.Envelope {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for partition := range 3 {
+			records, _, err := b.Fetch(auditTestTopic, partition, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) == 0 {
+				continue
+			}
+
+			var envelope events.Envelope
+			if err := json.Unmarshal(records[0].Value, &envelope); err != nil {
+				t.Fatal(err)
+			}
+			return envelope
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("no audit entry was published")
+	return events.Envelope{}
+}
+
+func TestAuditMiddlewareStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		status  int
+		outcome string
+	}{
+		{"handler error", "/rooms/room-1/kick/user-2", http.StatusForbidden, "denied"},
+		{"internal handler error", "/rooms/room-1/broken", http.StatusInternalServerError, "error"},
+		{"written response", "/rooms/room-1/ok", http.StatusNoContent, "success"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, b := newAuditRouter(t, AuditMiddleware)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("response status = %d, want %d", rec.Code, tt.status)
+			}
+
+			entry := awaitAuditEntry(t, b)
+			if got := entry.Data["status"]; got != float64(tt.status) {
+				t.Errorf("audited status = %v, want %d", got, tt.status)
+			}
+			if got := entry.Data["outcome"]; got != tt.outcome {
+				t.Errorf("audited outcome = %v, want %s", got, tt.outcome)
+			}
+		})
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"github.com/hilthontt/visper/api/infrastructure/cache"
 )
 
+// responseCacheMaxBytes keeps a few large responses from taking over the memory
+const responseCacheMaxBytes = 64 << 20
+
 type ResponseCache struct {
-	cache *cache.Cache
+	cache *cache.TypedCache[*CachedResponse]
 }
 
 type CachedResponse struct {
@@ -19,6 +22,15 @@ type CachedResponse struct {
 	Body       []byte
 }
 
+// Size lets the response cache hold it to a byte budget
+func (r *CachedResponse) Size() int64 {
+	size := int64(len(r.Body))
+	for k, v := range r.Headers {
+		size += int64(len(k) + len(v))
+	}
+	return size
+}
+
 type responseRecorder struct {
 	gin.ResponseWriter
 	statusCode int
@@ -37,9 +49,10 @@ func newResponseRecorder(w gin.ResponseWriter) *responseRecorder {
 func NewResponseCache() *ResponseCache {
 	options := cache.DefaultOptions()
 	options.CleanupInterval = 5 * time.Minute
+	options.MaxBytes = responseCacheMaxBytes
 
 	return &ResponseCache{
-		cache: cache.NewCache(options),
+		cache: cache.NewTypedCache[*CachedResponse](options),
 	}
 }
 
@@ -57,9 +70,7 @@ func (rc *ResponseCache) Middleware() gin.HandlerFunc {
 		key := r.URL.String()
 
 		// Check if we have a cached response
-		if cachedResp, found := rc.cache.Get(key); found {
-			resp := cachedResp.(*CachedResponse)
-
+		if resp, found := rc.cache.Get(key); found {
 			// Set headers
 			for k, v := range resp.Headers {
 				c.Writer.Header().Set(k, v)
@@ -1,15 +1,36 @@
 package middlewares
 
 import (
+	"sync/atomic"
+
 	"github.com/gin-gonic/gin"
-	"github.com/hilthontt/visper/api/infrastructure/config"
 )
 
-func CorsMiddleware(cfg *config.Config) gin.HandlerFunc {
+// CorsOrigins is the Access-Control-Allow-Origin value, swapped when the config reloads
+type CorsOrigins struct {
+	value atomic.Pointer[string]
+}
+
+func NewCorsOrigins(origins string) *CorsOrigins {
+	o := &CorsOrigins{}
+	o.Set(origins)
+	return o
+}
+
+func (o *CorsOrigins) Set(origins string) {
+	o.value.Store(&origins)
+}
+
+func (o *CorsOrigins) Get() string {
+	return *o.value.Load()
+}
+
+func CorsMiddleware(origins *CorsOrigins) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", cfg.Cors.AllowOrigins)
+		c.Writer.Header().Set("Access-Control-Allow-Origin", origins.Get())
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key, X-Member-Token, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Member-Token")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// ErrorResponse is the body written for every error that goes through ErrorMiddleware.
+// Code is stable and meant for programs, see the apperror package for the full list.
+// Error repeats the code for clients written against the older responses.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// ErrorResponseV2 is the v2 error body, the details sit under a single error object
+// so clients can tell an error apart from a payload without looking at the status.
+type ErrorResponseV2 struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// ErrorMiddleware turns the last error a handler attached with ctx.Error into a response,
+// unless the handler already wrote one itself
+func ErrorMiddleware(logger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		appErr, ok := apperror.As(err)
+		if !ok || appErr.Kind == apperror.KindInternal {
+			// The original text can carry internals, only the log gets to see it
+			logger.Error("request failed", zap.Error(err), zap.String("path", c.Request.URL.Path))
+			appErr = apperror.ErrInternal
+		}
+
+		status := HTTPStatus(appErr.Kind)
+		message := Localize(c, appErr.Message)
+
+		if GetAPIVersion(c) == APIVersionV2 {
+			c.JSON(status, ErrorResponseV2{Error: ErrorDetail{Code: appErr.Code, Message: message}})
+			return
+		}
+
+		c.JSON(status, ErrorResponse{
+			Error:   appErr.Code,
+			Code:    appErr.Code,
+			Message: message,
+		})
+	}
+}
+
+// responseStatus is the status the client gets. Middlewares on a route group run inside
+// ErrorMiddleware, so when they look an error the handler attached isn't written yet.
+func responseStatus(c *gin.Context) int {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return c.Writer.Status()
+	}
+
+	appErr, ok := apperror.As(c.Errors.Last().Err)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return HTTPStatus(appErr.Kind)
+}
+
+func HTTPStatus(kind apperror.Kind) int {
+	switch kind {
+	case apperror.KindInvalid:
+		return http.StatusBadRequest
+	case apperror.KindUnauthorized:
+		return http.StatusUnauthorized
+	case apperror.KindForbidden:
+		return http.StatusForbidden
+	case apperror.KindNotFound:
+		return http.StatusNotFound
+	case apperror.KindConflict:
+		return http.StatusConflict
+	case apperror.KindGone:
+		return http.StatusGone
+	case apperror.KindRateLimited:
+		return http.StatusTooManyRequests
+	case apperror.KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case apperror.KindNotImplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// FeatureFlagStore holds the feature flags in memory, the gates check it on every request.
+// The flags live in Redis, Watch picks up the ones toggled through other instances.
+type FeatureFlagStore struct {
+	repository repository.FeatureFlagRepository
+	logger     *logger.Logger
+
+	mu       sync.RWMutex
+	disabled map[model.FeatureFlag]struct{}
+}
+
+func NewFeatureFlagStore(repository repository.FeatureFlagRepository, logger *logger.Logger) *FeatureFlagStore {
+	return &FeatureFlagStore{
+		repository: repository,
+		logger:     logger,
+		disabled:   make(map[model.FeatureFlag]struct{}),
+	}
+}
+
+// Enabled reports whether the feature is on, a nil store has every feature on
+func (s *FeatureFlagStore) Enabled(flag model.FeatureFlag) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, off := s.disabled[flag]
+	return !off
+}
+
+// Refresh loads the flags, the current ones stay when that fails
+func (s *FeatureFlagStore) Refresh(ctx context.Context) error {
+	states, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	disabled := make(map[model.FeatureFlag]struct{})
+	for _, state := range states {
+		if !state.Enabled && state.Name.IsValid() {
+			disabled[state.Name] = struct{}{}
+		}
+	}
+
+	s.mu.Lock()
+	s.disabled = disabled
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch refreshes the flags every interval until ctx is done
+func (s *FeatureFlagStore) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("failed to refresh feature flags", zap.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FeatureGate turns requests away while an operator has the feature switched off
+func FeatureGate(store *FeatureFlagStore, flag model.FeatureFlag) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store.Enabled(flag) {
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "feature_disabled",
+			"message": Localize(c, "This feature is currently turned off"),
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,199 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyTTL            = 24 * time.Hour
+	idempotencyInFlightTTL    = 30 * time.Second // covers a slow handler, a crashed one frees the key on its own
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentResponseSize = 1 << 20
+)
+
+// idempotentHeaders are kept with the response, a retried join or create would otherwise
+// get the room without the member token it was issued
+var idempotentHeaders = []string{"Set-Cookie", security.MemberTokenHeader}
+
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Pending     bool        `json:"pending,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	ContentType string      `json:"contentType,omitempty"`
+	Headers     http.Header `json:"headers,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyMiddleware replays the stored response when a client retries a request with
+// the same Idempotency-Key. Keys are scoped to the user, requests without one pass through.
+func IdempotencyMiddleware(redisClient redis.UniversalClient, logger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid_request",
+				"message": Localize(c, "idempotency key is too long"),
+			})
+			c.Abort()
+			return
+		}
+
+		user, exists := GetUserFromContext(c)
+		if !exists {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid_request",
+				"message": Localize(c, "failed to read request body"),
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		key := "idempotency:" + user.ID + ":" + idempotencyKey
+		fingerprint := requestFingerprint(c, body)
+
+		pending, _ := json.Marshal(idempotentResponse{Fingerprint: fingerprint, Pending: true})
+		acquired, err := redisClient.SetNX(ctx, key, pending, idempotencyInFlightTTL).Result()
+		if err != nil {
+			// Fail open, a Redis hiccup shouldn't block sending
+			logger.Error("failed to reserve idempotency key", zap.Error(err), zap.String("userID", user.ID))
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			replayIdempotentResponse(c, redisClient, logger, key, fingerprint)
+			return
+		}
+
+		writer := &responseWriter{
+			ResponseWriter: c.Writer,
+			body:           &bytes.Buffer{},
+		}
+		c.Writer = writer
+
+		c.Next()
+
+		// Server errors aren't remembered so the retry gets a real second attempt
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || writer.body.Len() > maxIdempotentResponseSize {
+			redisClient.Del(ctx, key)
+			return
+		}
+
+		stored, err := json.Marshal(idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Headers:     storedHeaders(writer.Header()),
+			Body:        writer.body.Bytes(),
+		})
+		if err != nil {
+			redisClient.Del(ctx, key)
+			return
+		}
+
+		if err := redisClient.Set(ctx, key, stored, idempotencyTTL).Err(); err != nil {
+			logger.Error("failed to store idempotent response", zap.Error(err), zap.String("userID", user.ID))
+		}
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, redisClient redis.UniversalClient, logger *logger.Logger, key, fingerprint string) {
+	data, err := redisClient.Get(c.Request.Context(), key).Bytes()
+	if err == redis.Nil {
+		// The first attempt failed and released the key in the meantime
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "idempotency_conflict",
+			"message": Localize(c, "a request with this idempotency key is still in progress"),
+		})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		logger.Error("failed to load idempotent response", zap.Error(err))
+		c.Next()
+		return
+	}
+
+	var stored idempotentResponse
+	if err := json.Unmarshal(data, &stored); err != nil {
+		c.Next()
+		return
+	}
+
+	if stored.Fingerprint != fingerprint {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "idempotency_key_reused",
+			"message": Localize(c, "idempotency key was already used for a different request"),
+		})
+		c.Abort()
+		return
+	}
+
+	if stored.Pending {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "idempotency_conflict",
+			"message": Localize(c, "a request with this idempotency key is still in progress"),
+		})
+		c.Abort()
+		return
+	}
+
+	for name, values := range stored.Headers {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(stored.Status, stored.ContentType, stored.Body)
+	c.Abort()
+}
+
+func storedHeaders(header http.Header) http.Header {
+	var stored http.Header
+	for _, name := range idempotentHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			if stored == nil {
+				stored = make(http.Header)
+			}
+			stored[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	return stored
+}
+
+func requestFingerprint(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
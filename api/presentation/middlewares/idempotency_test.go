@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"github.com/hilthontt/visper/api/infrastructure/security"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestIdempotencyReplaysMemberToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	log, err := logger.NewDevelopmentLogger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(UserContextKey, &model.User{ID: "user-1"})
+	})
+	router.Use(IdempotencyMiddleware(client, log))
+	router.POST("/rooms", func(c *gin.Context) {
+		calls++
+		http.SetCookie(c.Writer, &http.Cookie{Name: "visper_member", Value: "token-1", HttpOnly: true})
+		c.Header(security.MemberTokenHeader, "token-1")
+		c.Header("X-Request-Id", "not-replayed")
+		c.JSON(http.StatusCreated, gin.H{"id": "room-1"})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/rooms", strings.NewReader(`{"name":"general"}`))
+		req.Header.Set(IdempotencyKeyHeader, "create-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send()
+	retried := send()
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if retried.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatal("retry wasn't replayed")
+	}
+	if retried.Code != first.Code || retried.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", retried.Code, retried.Body, first.Code, first.Body)
+	}
+	if got, want := retried.Header().Values("Set-Cookie"), first.Header().Values("Set-Cookie"); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("replayed Set-Cookie = %v, want %v", got, want)
+	}
+	if got := retried.Header().Get(security.MemberTokenHeader); got != "token-1" {
+		t.Errorf("replayed %s = %q, want %q", security.MemberTokenHeader, got, "token-1")
+	}
+	if got := retried.Header().Get("X-Request-Id"); got != "" {
+		t.Errorf("replayed X-Request-Id = %q, only the member token headers are kept", got)
+	}
+}
@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/i18n"
+	"golang.org/x/text/language"
+)
+
+const (
+	LocaleContextKey = "locale"
+)
+
+func LocaleMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+
+		c.Set(LocaleContextKey, lang)
+		c.Header("Content-Language", lang.String())
+		c.Writer.Header().Add("Vary", "Accept-Language")
+
+		c.Next()
+	}
+}
+
+// Localize translates a human readable error message, error codes are never translated
+func Localize(c *gin.Context, message string) string {
+	return i18n.Translate(GetLocaleFromContext(c), message)
+}
+
+func GetLocaleFromContext(c *gin.Context) language.Tag {
+	if value, exists := c.Get(LocaleContextKey); exists {
+		if lang, ok := value.(language.Tag); ok {
+			return lang
+		}
+	}
+
+	return i18n.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+}
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/maintenance"
+)
+
+// maintenanceRetryAfter is a hint for clients, in seconds
+const maintenanceRetryAfter = "120"
+
+func MaintenanceMiddleware(mode *maintenance.Mode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !mode.IsEnabled() || isReadOnlyMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", maintenanceRetryAfter)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "maintenance_mode",
+			"message": Localize(c, mode.Message()),
+		})
+		c.Abort()
+	}
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
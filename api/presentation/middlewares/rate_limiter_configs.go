@@ -35,5 +35,26 @@ func MessageSendingRateLimiterConfig() RateLimiterConfig {
 		RequestsPerWindow: 30,               // 30 messages
 		Window:            time.Minute,      // per minute
 		BlockDuration:     time.Minute * 10, // block for 10 minutes
+		Tier:              "send",
+	}
+}
+
+// BotRateLimiterConfig for bot tokens, integrations post in bursts but shouldn't drown a room
+func BotRateLimiterConfig() RateLimiterConfig {
+	return RateLimiterConfig{
+		RequestsPerWindow: 120,             // 120 requests
+		Window:            time.Minute,     // per minute
+		BlockDuration:     time.Minute * 1, // block for 1 minute
+		Tier:              "bot",
+	}
+}
+
+// BotMessageSendingRateLimiterConfig for bot message sending, on top of the bot tier
+func BotMessageSendingRateLimiterConfig() RateLimiterConfig {
+	return RateLimiterConfig{
+		RequestsPerWindow: 60,              // 60 messages
+		Window:            time.Minute,     // per minute
+		BlockDuration:     time.Minute * 2, // block for 2 minutes
+		Tier:              "bot:send",
 	}
 }
@@ -0,0 +1,110 @@
+package middlewares
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/domain/repository"
+	"github.com/hilthontt/visper/api/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// RateLimitAllowlist holds the rate limit exemptions in memory, the limiters check it on
+// every request. The exemptions live in Redis, Watch picks up the ones added through
+// other instances.
+type RateLimitAllowlist struct {
+	repository repository.RateLimitRepository
+	logger     *logger.Logger
+
+	mu       sync.RWMutex
+	ids      map[string]struct{} // Users and bots
+	networks []netip.Prefix
+}
+
+func NewRateLimitAllowlist(repository repository.RateLimitRepository, logger *logger.Logger) *RateLimitAllowlist {
+	return &RateLimitAllowlist{
+		repository: repository,
+		logger:     logger,
+		ids:        make(map[string]struct{}),
+	}
+}
+
+// Exempts reports whether a request of the user from ip skips the rate limits. Bot IDs
+// can't be claimed by users, so a bot's exemption only ever matches its token.
+func (a *RateLimitAllowlist) Exempts(userID, ip string) bool {
+	if a == nil {
+		return false
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if _, ok := a.ids[userID]; ok && userID != "" {
+		return true
+	}
+	if len(a.networks) == 0 {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, network := range a.networks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh loads the exemptions, the current ones stay when that fails
+func (a *RateLimitAllowlist) Refresh(ctx context.Context) error {
+	exemptions, err := a.repository.GetExemptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]struct{})
+	var networks []netip.Prefix
+	for _, exemption := range exemptions {
+		if exemption.Kind != model.RateLimitExemptCIDR {
+			ids[exemption.Value] = struct{}{}
+			continue
+		}
+		network, err := netip.ParsePrefix(exemption.Value)
+		if err != nil {
+			a.logger.Warn("skipping invalid rate limit exemption", zap.String("value", exemption.Value), zap.Error(err))
+			continue
+		}
+		networks = append(networks, network)
+	}
+
+	a.mu.Lock()
+	a.ids = ids
+	a.networks = networks
+	a.mu.Unlock()
+	return nil
+}
+
+// Watch refreshes the exemptions every interval until ctx is done
+func (a *RateLimitAllowlist) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.Refresh(ctx); err != nil && ctx.Err() == nil {
+			a.logger.Warn("failed to refresh rate limit exemptions", zap.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -3,10 +3,15 @@ package middlewares
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/infrastructure/cache"
 	"github.com/hilthontt/visper/api/infrastructure/logger"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
@@ -16,8 +21,31 @@ type RateLimiterConfig struct {
 	RequestsPerWindow int           // Number of requests allowed
 	Window            time.Duration // Time window
 	BlockDuration     time.Duration // How long to block after exceeding limit
+	Tier              string        // Keeps limiters stacked on the same route from sharing a counter
+	Algorithm         RateLimitAlgorithm
+	Burst             int // Token bucket size or leaky bucket queue, RequestsPerWindow by default
+	Exemptions        *RateLimitAllowlist
+	// Tuning applies the configured algorithm and burst on every request, so a config
+	// reload reaches the limiters already set up
+	Tuning *RateLimitTuning
 }
 
+// RateLimitAlgorithm decides how requests are counted against a limit
+type RateLimitAlgorithm string
+
+const (
+	// SlidingWindow allows RequestsPerWindow in any window, the default
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+	// TokenBucket lets a burst of Burst requests through at once, then refills at the limit's rate
+	TokenBucket RateLimitAlgorithm = "token_bucket"
+	// LeakyBucket spaces requests out evenly at the limit's rate, a burst waits its turn in a
+	// queue of Burst requests
+	LeakyBucket RateLimitAlgorithm = "leaky_bucket"
+)
+
+// maxLeakyBucketWait caps how long a queued request is held, whatever the queue's length
+const maxLeakyBucketWait = 5 * time.Second
+
 func DefaultRateLimiterConfig() RateLimiterConfig {
 	return RateLimiterConfig{
 		RequestsPerWindow: 150,             // 150 requests
@@ -26,48 +54,169 @@ func DefaultRateLimiterConfig() RateLimiterConfig {
 	}
 }
 
-const rateLimitScript = `
+// WithAlgorithm counts the tier's requests with algorithm, with a burst of burstRatio times its
+// limit. The tier's own settings apply where these are empty.
+func (c RateLimiterConfig) WithAlgorithm(algorithm RateLimitAlgorithm, burstRatio float64) RateLimiterConfig {
+	if algorithm != "" {
+		c.Algorithm = algorithm
+	}
+	if burstRatio > 0 {
+		c.Burst = max(int(math.Ceil(float64(c.RequestsPerWindow)*burstRatio)), 1)
+	}
+	return c
+}
+
+// RateLimitTuning is the configured algorithm and burst ratio, shared by every limiter
+type RateLimitTuning struct {
+	mu         sync.RWMutex
+	algorithm  RateLimitAlgorithm
+	burstRatio float64
+}
+
+func NewRateLimitTuning(algorithm RateLimitAlgorithm, burstRatio float64) *RateLimitTuning {
+	return &RateLimitTuning{algorithm: algorithm, burstRatio: burstRatio}
+}
+
+func (t *RateLimitTuning) Set(algorithm RateLimitAlgorithm, burstRatio float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.algorithm = algorithm
+	t.burstRatio = burstRatio
+}
+
+// tuned is the tier with the current tuning applied
+func (c RateLimiterConfig) tuned() RateLimiterConfig {
+	if c.Tuning == nil {
+		return c
+	}
+
+	c.Tuning.mu.RLock()
+	defer c.Tuning.mu.RUnlock()
+	return c.WithAlgorithm(c.Tuning.algorithm, c.Tuning.burstRatio)
+}
+
+func (c RateLimiterConfig) burst() int {
+	if c.Burst > 0 {
+		return c.Burst
+	}
+	return c.RequestsPerWindow
+}
+
+// The scripts check the block, count the request and block the user once over the limit,
+// all in one round trip. They return the status, the remaining requests, the milliseconds
+// until the limit is back to full or the block ends, and how long the request has to wait.
+// KEYS are the counter and the block, ARGV the time and window in nanoseconds, the limit,
+// the counter's expiry in seconds, the block in milliseconds, a unique member, the burst and
+// the longest a queued request may wait in nanoseconds.
+const rateLimitBlockCheck = `
 local key = KEYS[1]
+local blockKey = KEYS[2]
 local now = tonumber(ARGV[1])
 local window = tonumber(ARGV[2])
 local limit = tonumber(ARGV[3])
 local expiry = tonumber(ARGV[4])
+local blockMs = tonumber(ARGV[5])
+local member = ARGV[6]
+local burst = tonumber(ARGV[7])
 
+local blockTTL = redis.call('PTTL', blockKey)
+if blockTTL > 0 then
+    return {2, 0, blockTTL, 0}
+end
+`
+
+var slidingWindowScript = redis.NewScript(rateLimitBlockCheck + `
 -- Remove old entries outside the window
-local windowStart = now - window
-redis.call('ZREMRANGEBYSCORE', key, 0, windowStart)
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
 
--- Count current requests in window
 local currentCount = redis.call('ZCARD', key)
+if currentCount >= limit then
+    redis.call('SET', blockKey, '1', 'PX', blockMs)
+    return {0, 0, blockMs, 0}
+end
 
--- Add current request
-redis.call('ZADD', key, now, now)
-
--- Set expiry on the key
+redis.call('ZADD', key, now, member)
 redis.call('EXPIRE', key, expiry)
 
--- Calculate remaining
-local remaining = math.max(limit - currentCount - 1, 0)
+-- The window frees up when its oldest request falls out
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local resetMs = math.ceil((tonumber(oldest[2]) + window - now) / 1000000)
 
--- Check if allowed
-local allowed = currentCount < limit
+return {1, limit - currentCount - 1, resetMs, 0}
+`)
 
-return {allowed and 1 or 0, remaining, currentCount + 1}
-`
+var tokenBucketScript = redis.NewScript(rateLimitBlockCheck + `
+local rate = limit / window
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(now - ts, 0) * rate)
+if tokens < 1 then
+    redis.call('SET', blockKey, '1', 'PX', blockMs)
+    return {0, 0, blockMs, 0}
+end
+
+tokens = tokens - 1
+redis.call('HSET', key, 'tokens', tokens, 'ts', now)
+redis.call('EXPIRE', key, expiry)
+
+return {1, math.floor(tokens), math.ceil((burst - tokens) / rate / 1000000), 0}
+`)
 
-const checkBlockScript = `
-local blockKey = KEYS[1]
+// The leaky bucket keeps the time its queue runs empty, each request adds one interval
+var leakyBucketScript = redis.NewScript(rateLimitBlockCheck + `
+local interval = window / limit
+local maxWait = tonumber(ARGV[8])
+local emptyAt = math.max(tonumber(redis.call('GET', key)) or now, now)
 
-local exists = redis.call('EXISTS', blockKey)
-if exists == 0 then
-    return {0, 0}
+local wait = emptyAt - now
+if wait > maxWait then
+    redis.call('SET', blockKey, '1', 'PX', blockMs)
+    return {0, 0, blockMs, 0}
 end
 
-local ttl = redis.call('TTL', blockKey)
-return {1, ttl}
-`
+emptyAt = emptyAt + interval
+redis.call('SET', key, emptyAt, 'EX', expiry)
+
+return {1, math.floor((maxWait - wait) / interval), math.ceil((emptyAt - now) / 1000000), math.ceil(wait / 1000000)}
+`)
 
-func RateLimiterMiddleware(redisClient *redis.Client, logger *logger.Logger, config RateLimiterConfig) gin.HandlerFunc {
+type rateLimitStatus int
+
+const (
+	rateLimitExceeded rateLimitStatus = iota // This request went over, the user is blocked now
+	rateLimitAllowed
+	rateLimitBlocked // Blocked by an earlier request
+)
+
+type rateLimitResult struct {
+	status    rateLimitStatus
+	remaining int
+	reset     time.Duration // Until the limit is back to full, or the block ends
+	wait      time.Duration // Before the request may go on, for the leaky bucket
+}
+
+// rateLimiter counts requests with one algorithm, in Redis through its script and in this
+// process while Redis is down
+type rateLimiter interface {
+	script() *redis.Script
+	local(state *localRateLimitState, now time.Time, config RateLimiterConfig) rateLimitResult
+}
+
+func limiterFor(algorithm RateLimitAlgorithm) rateLimiter {
+	switch algorithm {
+	case TokenBucket:
+		return tokenBucketLimiter{}
+	case LeakyBucket:
+		return leakyBucketLimiter{}
+	default:
+		return slidingWindowLimiter{}
+	}
+}
+
+func RateLimiterMiddleware(redisClient redis.UniversalClient, logger *logger.Logger, config RateLimiterConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := GetUserFromContext(c)
 		if !exists {
@@ -76,53 +225,39 @@ func RateLimiterMiddleware(redisClient *redis.Client, logger *logger.Logger, con
 			return
 		}
 
-		ctx := c.Request.Context()
-
-		blockKey := fmt.Sprintf("ratelimit:block:%s", user.ID)
-		blockResult, err := redisClient.Eval(ctx, checkBlockScript, []string{blockKey}).Result()
-		if err != nil {
-			logger.Error("failed to check if user is blocked", zap.Error(err), zap.String("userID", user.ID))
+		// A claimed X-User-ID could name any exempt user
+		exemptUser := user.ID
+		if !IsUserSigned(c) {
+			exemptUser = ""
+		}
+		if config.Exemptions.Exempts(exemptUser, c.ClientIP()) {
 			c.Next()
 			return
 		}
 
-		blockInfo := blockResult.([]any)
-		isBlocked := blockInfo[0].(int64) == 1
-
-		if isBlocked {
-			ttl := time.Duration(blockInfo[1].(int64)) * time.Second
-
-			c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", config.RequestsPerWindow))
-			c.Header("X-RateLimit-Remaining", "0")
-			c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(ttl).Unix()))
-			c.Header("Retry-After", fmt.Sprintf("%d", int(ttl.Seconds())))
+		config := config.tuned()
+		result, err := checkRateLimit(c.Request.Context(), redisClient, user.ID, config)
+		if err != nil {
+			logger.Warn("rate limit check fell back to the local limiter", zap.Error(err), zap.String("userID", user.ID))
+		}
+		setRateLimitHeaders(c, config, result)
 
+		switch result.status {
+		case rateLimitAllowed:
+			if !waitTurn(c.Request.Context(), result.wait) {
+				c.Abort()
+				return
+			}
+			c.Next()
+		case rateLimitBlocked:
+			c.Header("Retry-After", fmt.Sprintf("%d", int(result.reset.Seconds())))
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "rate_limit_exceeded",
-				"message":     "Too many requests. You have been temporarily blocked.",
-				"retry_after": int(ttl.Seconds()),
+				"message":     Localize(c, "Too many requests. You have been temporarily blocked."),
+				"retry_after": int(result.reset.Seconds()),
 			})
 			c.Abort()
-			return
-		}
-
-		allowed, remaining, resetTime, err := checkRateLimitAtomic(ctx, redisClient, user.ID, config)
-		if err != nil {
-			logger.Error("failed to check rate limit", zap.Error(err), zap.String("userID", user.ID))
-			c.Next()
-			return
-		}
-
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", config.RequestsPerWindow))
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", resetTime.Unix()))
-
-		if !allowed {
-			// Block user
-			if err := blockUser(ctx, redisClient, user.ID, config.BlockDuration); err != nil {
-				logger.Error("failed to block user", zap.Error(err), zap.String("userID", user.ID))
-			}
-
+		default:
 			logger.Warn("rate limit exceeded",
 				zap.String("userID", user.ID),
 				zap.String("username", user.Username),
@@ -132,45 +267,256 @@ func RateLimiterMiddleware(redisClient *redis.Client, logger *logger.Logger, con
 			c.Header("Retry-After", fmt.Sprintf("%d", int(config.BlockDuration.Seconds())))
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "rate_limit_exceeded",
-				"message":     fmt.Sprintf("Rate limit exceeded. Maximum %d requests per %v.", config.RequestsPerWindow, config.Window),
+				"message":     Localize(c, fmt.Sprintf("Rate limit exceeded. Maximum %d requests per %v.", config.RequestsPerWindow, config.Window)),
 				"retry_after": int(config.BlockDuration.Seconds()),
 			})
 			c.Abort()
-			return
 		}
+	}
+}
 
-		c.Next()
+// setRateLimitHeaders reports the quota in the RateLimit fields of the IETF draft
+// (draft-ietf-httpapi-ratelimit-headers), the reset in seconds from now, and in the
+// X-RateLimit ones older clients read, the reset as a Unix time
+func setRateLimitHeaders(c *gin.Context, config RateLimiterConfig, result rateLimitResult) {
+	resetSeconds := int(math.Ceil(result.reset.Seconds()))
+
+	c.Header("RateLimit-Limit", strconv.Itoa(config.RequestsPerWindow))
+	c.Header("RateLimit-Remaining", strconv.Itoa(max(result.remaining, 0)))
+	c.Header("RateLimit-Reset", strconv.Itoa(resetSeconds))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", config.RequestsPerWindow, int(config.Window.Seconds())))
+
+	c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", config.RequestsPerWindow))
+	c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", result.remaining))
+	c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(result.reset).Unix()))
+}
+
+// waitTurn holds a request the leaky bucket queued, false when the client gave up first
+func waitTurn(ctx context.Context, wait time.Duration) bool {
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
-func checkRateLimitAtomic(ctx context.Context, client *redis.Client, userID string, config RateLimiterConfig) (allowed bool, remaining int, resetTime time.Time, err error) {
-	key := fmt.Sprintf("ratelimit:%s", userID)
+// checkRateLimit counts a request of the user against config in Redis. When Redis can't be
+// reached the request is counted by this instance alone, the error says so.
+func checkRateLimit(ctx context.Context, client redis.UniversalClient, userID string, config RateLimiterConfig) (rateLimitResult, error) {
+	// Both keys go into one script, on a cluster they have to share a slot
+	tag := cache.HashTag(client, userID)
+	key := fmt.Sprintf("ratelimit:%s", tag)
+	if config.Tier != "" {
+		key = fmt.Sprintf("ratelimit:%s:%s", config.Tier, tag)
+	}
+	if config.Algorithm != "" && config.Algorithm != SlidingWindow {
+		// Each algorithm keeps a differently shaped counter
+		key += ":" + string(config.Algorithm)
+	}
+	blockKey := fmt.Sprintf("ratelimit:block:%s", tag)
+	limiter := limiterFor(config.Algorithm)
 	now := time.Now()
 
-	result, err := client.Eval(ctx, rateLimitScript,
-		[]string{key},
+	res, err := limiter.script().Run(ctx, client,
+		[]string{key, blockKey},
 		now.UnixNano(),
 		config.Window.Nanoseconds(),
 		config.RequestsPerWindow,
 		int(config.Window.Seconds())+60, // expiry buffer
-	).Result()
+		config.BlockDuration.Milliseconds(),
+		fmt.Sprintf("%d-%d", now.UnixNano(), rand.Uint32()), // Unique for requests in the same nanosecond
+		config.burst(),
+		leakyBucketMaxWait(config).Nanoseconds(),
+	).Int64Slice()
+	if err != nil || len(res) != 4 {
+		if err == nil {
+			err = fmt.Errorf("unexpected result %v", res)
+		}
+		return localRateLimits.check(limiter, key, blockKey, now, config), fmt.Errorf("rate limit script failed: %w", err)
+	}
+
+	return rateLimitResult{
+		status:    rateLimitStatus(res[0]),
+		remaining: int(res[1]),
+		reset:     time.Duration(res[2]) * time.Millisecond,
+		wait:      time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
+
+// leakyBucketMaxWait is how long the last request in a full queue waits
+func leakyBucketMaxWait(config RateLimiterConfig) time.Duration {
+	interval := config.Window / time.Duration(max(config.RequestsPerWindow, 1))
+	return min(time.Duration(config.burst()-1)*interval, maxLeakyBucketWait)
+}
+
+// localRateLimits stands in for Redis while it is down. Each instance then allows the
+// whole limit on its own, which beats letting every request through.
+var localRateLimits = &localRateLimiter{
+	states: make(map[string]*localRateLimitState),
+	blocks: make(map[string]time.Time),
+}
+
+const maxLocalRateLimitKeys = 100_000
+
+type localRateLimiter struct {
+	mu     sync.Mutex
+	states map[string]*localRateLimitState
+	blocks map[string]time.Time
+}
+
+// localRateLimitState is a counter of one of the algorithms
+type localRateLimitState struct {
+	hits    []time.Time // Sliding window
+	tokens  float64     // Token bucket
+	emptyAt time.Time   // Leaky bucket, and for the token bucket when tokens were last added
+}
+
+func (l *localRateLimiter) check(limiter rateLimiter, key, blockKey string, now time.Time, config RateLimiterConfig) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until, ok := l.blocks[blockKey]; ok && now.Before(until) {
+		return rateLimitResult{status: rateLimitBlocked, reset: until.Sub(now)}
+	}
+
+	state, ok := l.states[key]
+	if !ok {
+		if len(l.states) >= maxLocalRateLimitKeys {
+			l.prune(now, config.Window)
+		}
+		state = &localRateLimitState{tokens: float64(config.burst()), emptyAt: now}
+		l.states[key] = state
+	}
+
+	result := limiter.local(state, now, config)
+	if result.status == rateLimitExceeded {
+		l.blocks[blockKey] = now.Add(config.BlockDuration)
+		result.reset = config.BlockDuration
+	}
+	return result
+}
+
+// prune drops counters idle for a window and blocks that ended, l.mu is held
+func (l *localRateLimiter) prune(now time.Time, window time.Duration) {
+	for key, state := range l.states {
+		last := state.emptyAt
+		if len(state.hits) > 0 {
+			last = state.hits[len(state.hits)-1]
+		}
+		if now.Sub(last) > window {
+			delete(l.states, key)
+		}
+	}
+	for key, until := range l.blocks {
+		if now.After(until) {
+			delete(l.blocks, key)
+		}
+	}
+}
 
-	if err != nil {
-		return false, 0, time.Time{}, fmt.Errorf("rate limit script failed: %w", err)
+type slidingWindowLimiter struct{}
+
+func (slidingWindowLimiter) script() *redis.Script {
+	return slidingWindowScript
+}
+
+func (slidingWindowLimiter) local(state *localRateLimitState, now time.Time, config RateLimiterConfig) rateLimitResult {
+	start := 0
+	for start < len(state.hits) && !state.hits[start].After(now.Add(-config.Window)) {
+		start++
 	}
+	state.hits = state.hits[start:]
 
-	resultArray := result.([]any)
-	allowedInt := resultArray[0].(int64)
-	remainingInt := resultArray[1].(int64)
+	if len(state.hits) >= config.RequestsPerWindow {
+		return rateLimitResult{status: rateLimitExceeded}
+	}
+	state.hits = append(state.hits, now)
 
-	allowed = allowedInt == 1
-	remaining = int(remainingInt)
-	resetTime = now.Add(config.Window)
+	return rateLimitResult{
+		status:    rateLimitAllowed,
+		remaining: config.RequestsPerWindow - len(state.hits),
+		reset:     state.hits[0].Add(config.Window).Sub(now),
+	}
+}
 
-	return allowed, remaining, resetTime, nil
+type tokenBucketLimiter struct{}
+
+func (tokenBucketLimiter) script() *redis.Script {
+	return tokenBucketScript
 }
 
-func blockUser(ctx context.Context, client *redis.Client, userID string, duration time.Duration) error {
-	key := fmt.Sprintf("ratelimit:block:%s", userID)
-	return client.Set(ctx, key, "1", duration).Err()
+func (tokenBucketLimiter) local(state *localRateLimitState, now time.Time, config RateLimiterConfig) rateLimitResult {
+	rate := float64(config.RequestsPerWindow) / float64(config.Window) // Tokens per nanosecond
+	burst := float64(config.burst())
+
+	state.tokens = min(burst, state.tokens+float64(max(now.Sub(state.emptyAt), 0))*rate)
+	state.emptyAt = now
+	if state.tokens < 1 {
+		return rateLimitResult{status: rateLimitExceeded}
+	}
+	state.tokens--
+
+	return rateLimitResult{
+		status:    rateLimitAllowed,
+		remaining: int(state.tokens),
+		reset:     time.Duration((burst - state.tokens) / rate),
+	}
+}
+
+type leakyBucketLimiter struct{}
+
+func (leakyBucketLimiter) script() *redis.Script {
+	return leakyBucketScript
+}
+
+func (leakyBucketLimiter) local(state *localRateLimitState, now time.Time, config RateLimiterConfig) rateLimitResult {
+	interval := config.Window / time.Duration(max(config.RequestsPerWindow, 1))
+	maxWait := leakyBucketMaxWait(config)
+
+	emptyAt := state.emptyAt
+	if emptyAt.Before(now) {
+		emptyAt = now
+	}
+
+	wait := emptyAt.Sub(now)
+	if wait > maxWait {
+		return rateLimitResult{status: rateLimitExceeded}
+	}
+	state.emptyAt = emptyAt.Add(interval)
+
+	return rateLimitResult{
+		status:    rateLimitAllowed,
+		remaining: int((maxWait - wait) / interval),
+		reset:     state.emptyAt.Sub(now),
+		wait:      wait,
+	}
+}
+
+// AllowRequest applies the same block and rate limit checks as RateLimiterMiddleware, for
+// callers outside an HTTP request such as WebSocket frames. retryAfter is set when not allowed.
+// err reports a Redis failure, the local limiter decided in its place.
+func AllowRequest(ctx context.Context, client redis.UniversalClient, userID string, config RateLimiterConfig) (allowed bool, retryAfter time.Duration, err error) {
+	if config.Exemptions.Exempts(userID, "") {
+		return true, 0, nil
+	}
+
+	config = config.tuned()
+	result, err := checkRateLimit(ctx, client, userID, config)
+
+	switch result.status {
+	case rateLimitAllowed:
+		return waitTurn(ctx, result.wait), 0, err
+	case rateLimitBlocked:
+		return false, result.reset, err
+	default:
+		return false, config.BlockDuration, err
+	}
 }
@@ -0,0 +1,142 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+)
+
+// burstStep sends requests at once, offset after the first step, and expects allowed of them through
+type burstStep struct {
+	offset   time.Duration
+	requests int
+	allowed  int
+}
+
+func newTestLocalLimiter() *localRateLimiter {
+	return &localRateLimiter{
+		states: make(map[string]*localRateLimitState),
+		blocks: make(map[string]time.Time),
+	}
+}
+
+func TestLocalRateLimiterBursts(t *testing.T) {
+	// 10 requests per 10 seconds is one a second, the burst is half the limit
+	base := RateLimiterConfig{
+		RequestsPerWindow: 10,
+		Window:            10 * time.Second,
+		BlockDuration:     time.Minute,
+		Burst:             5,
+	}
+
+	tests := []struct {
+		name      string
+		algorithm RateLimitAlgorithm
+		burst     int
+		steps     []burstStep
+	}{
+		{"sliding window allows the whole limit at once", SlidingWindow, 5, []burstStep{{0, 15, 10}}},
+		{"sliding window frees up once the window passed", SlidingWindow, 5, []burstStep{{0, 10, 10}, {10 * time.Second, 10, 10}}},
+		{"sliding window ignores the burst", SlidingWindow, 1, []burstStep{{0, 10, 10}}},
+		{"token bucket allows the burst at once", TokenBucket, 5, []burstStep{{0, 8, 5}}},
+		{"token bucket burst defaults to the limit", TokenBucket, 0, []burstStep{{0, 12, 10}}},
+		{"token bucket refills at the limit's rate", TokenBucket, 5, []burstStep{{0, 5, 5}, {2 * time.Second, 3, 2}}},
+		{"token bucket refills no further than the burst", TokenBucket, 5, []burstStep{{0, 5, 5}, {time.Minute, 8, 5}}},
+		{"leaky bucket queues the burst", LeakyBucket, 5, []burstStep{{0, 8, 5}}},
+		{"leaky bucket drains at the limit's rate", LeakyBucket, 5, []burstStep{{0, 5, 5}, {2 * time.Second, 3, 2}}},
+		{"leaky bucket with a burst of one allows one at a time", LeakyBucket, 1, []burstStep{{0, 2, 1}}},
+		{"sliding window stays blocked after going over", SlidingWindow, 5, []burstStep{{0, 11, 10}, {10 * time.Second, 1, 0}}},
+		{"token bucket stays blocked after going over", TokenBucket, 5, []burstStep{{0, 6, 5}, {2 * time.Second, 1, 0}}},
+		{"leaky bucket stays blocked after going over", LeakyBucket, 5, []burstStep{{0, 6, 5}, {5 * time.Second, 1, 0}}},
+		{"block ends after the block duration", TokenBucket, 5, []burstStep{{0, 6, 5}, {time.Minute, 5, 5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			config.Burst = tt.burst
+			limiter := limiterFor(tt.algorithm)
+			local := newTestLocalLimiter()
+			start := time.Now()
+
+			for i, step := range tt.steps {
+				now := start.Add(step.offset)
+				allowed := 0
+				for range step.requests {
+					if local.check(limiter, "user", "block", now, config).status == rateLimitAllowed {
+						allowed++
+					}
+				}
+				if allowed != step.allowed {
+					t.Errorf("step %d: %d of %d requests allowed, want %d", i, allowed, step.requests, step.allowed)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalRateLimiterSteadyRate(t *testing.T) {
+	config := RateLimiterConfig{
+		RequestsPerWindow: 10,
+		Window:            10 * time.Second,
+		BlockDuration:     time.Minute,
+		Burst:             5,
+	}
+
+	// One request a second for three windows is right at the limit, no algorithm should refuse it
+	for _, algorithm := range []RateLimitAlgorithm{SlidingWindow, TokenBucket, LeakyBucket} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			limiter := limiterFor(algorithm)
+			local := newTestLocalLimiter()
+			start := time.Now()
+
+			for i := range 30 {
+				result := local.check(limiter, "user", "block", start.Add(time.Duration(i)*time.Second), config)
+				if result.status != rateLimitAllowed {
+					t.Fatalf("request %d refused with status %d", i, result.status)
+				}
+				if result.wait != 0 {
+					t.Fatalf("request %d waited %v", i, result.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestLeakyBucketSpacesOutBurst(t *testing.T) {
+	config := RateLimiterConfig{
+		RequestsPerWindow: 10,
+		Window:            10 * time.Second,
+		BlockDuration:     time.Minute,
+		Burst:             5,
+	}
+	limiter := limiterFor(LeakyBucket)
+	local := newTestLocalLimiter()
+	now := time.Now()
+
+	for i := range 5 {
+		result := local.check(limiter, "user", "block", now, config)
+		if result.status != rateLimitAllowed {
+			t.Fatalf("request %d refused with status %d", i, result.status)
+		}
+		if want := time.Duration(i) * time.Second; result.wait != want {
+			t.Errorf("request %d waits %v, want %v", i, result.wait, want)
+		}
+		if want := 4 - i; result.remaining != want {
+			t.Errorf("request %d leaves %d, want %d", i, result.remaining, want)
+		}
+	}
+}
+
+func TestLeakyBucketWaitIsCapped(t *testing.T) {
+	// A queue of 100 at one a second would hold requests for over a minute
+	config := RateLimiterConfig{
+		RequestsPerWindow: 10,
+		Window:            10 * time.Second,
+		BlockDuration:     time.Minute,
+		Burst:             100,
+	}
+
+	if got := leakyBucketMaxWait(config); got != maxLeakyBucketWait {
+		t.Errorf("leakyBucketMaxWait() = %v, want %v", got, maxLeakyBucketWait)
+	}
+}
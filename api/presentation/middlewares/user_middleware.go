@@ -14,15 +14,42 @@ import (
 
 const (
 	UserContextKey = "user"
+	// UserSignedKey is set when the user was identified by a signed token rather than a
+	// claimed X-User-ID
+	UserSignedKey = "user_signed"
 )
 
-func UserMiddleware(userUC userUseCase.UserUseCase, logger *logger.Logger) gin.HandlerFunc {
+// memberAuth is how authenticateMember identified the user of a request
+type memberAuth struct {
+	userID  string
+	refresh bool // Issue a new token
+	signed  bool // From a verified token, a claimed X-User-ID is not
+}
+
+// UserMiddleware identifies the user of every request by its signed member token, a
+// request without a valid one gets a new user. A fresh token is sent whenever one is
+// issued, in the cookie and the X-Member-Token header. allowUserIDHeader trusts a bare
+// X-User-ID as well, for clients that don't send tokens yet. Such a user is never issued
+// a token, which would turn the claim into a signed identity.
+func UserMiddleware(userUC userUseCase.UserUseCase, tokens *security.MemberTokenSigner, allowUserIDHeader bool, logger *logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := getUserIDFromRequest(c)
-		if userID == "" {
-			userID = uuid.NewString()
-			setUserIDCookie(c, userID)
-			logger.Debug("generated new user ID", zap.String("userID", userID))
+		auth := authenticateMember(c, tokens, allowUserIDHeader)
+		// Bots only authenticate through their token, never through a claimed user ID
+		if auth.userID == "" || model.IsBotID(auth.userID) {
+			auth = memberAuth{userID: uuid.NewString(), refresh: true, signed: true}
+			logger.Debug("generated new user ID", zap.String("userID", auth.userID))
+		}
+		userID := auth.userID
+		refresh := auth.refresh && auth.signed
+
+		if refresh {
+			token, err := tokens.Issue(userID, "")
+			if err != nil {
+				logger.Error("failed to issue member token", zap.Error(err), zap.String("userID", userID))
+			} else {
+				security.SetMemberToken(c.Writer, token, tokens.TTL())
+				c.Header(security.MemberTokenHeader, token)
+			}
 		}
 
 		user, err := userUC.GetOrCreateUser(c.Request.Context(), userID)
@@ -30,28 +57,60 @@ func UserMiddleware(userUC userUseCase.UserUseCase, logger *logger.Logger) gin.H
 			logger.Error("failed to get or create user", zap.Error(err), zap.String("userID", userID))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "internal_server_error",
-				"message": "Failed to initialize user session",
+				"message": Localize(c, "Failed to initialize user session"),
+			})
+			c.Abort()
+			return
+		}
+
+		banned, err := userUC.IsBanned(c.Request.Context(), user.ID)
+		if err != nil {
+			// Fail open, a Redis hiccup should not lock everyone out
+			logger.Error("failed to check user ban", zap.Error(err), zap.String("userID", user.ID))
+		}
+
+		if banned {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "banned",
+				"message": Localize(c, "This account has been banned"),
 			})
 			c.Abort()
 			return
 		}
 
 		c.Set(UserContextKey, user)
+		c.Set(UserSignedKey, auth.signed)
 
 		c.Next()
 	}
 }
 
-func getUserIDFromRequest(c *gin.Context) string {
-	if headerUserID := c.GetHeader("X-User-ID"); headerUserID != "" {
-		return headerUserID
+// authenticateMember returns the user the request's token was signed for, and whether
+// the user should get a new token. A room token only counts in its own room.
+func authenticateMember(c *gin.Context, tokens *security.MemberTokenSigner, allowUserIDHeader bool) memberAuth {
+	if token := security.GetMemberToken(c.Request); token != "" {
+		if claims, err := tokens.Verify(token); err == nil && claims.RoomID == "" {
+			return memberAuth{userID: claims.UserID, refresh: tokens.NeedsRefresh(claims), signed: true}
+		}
+	}
+
+	if token := security.GetRoomAuthToken(c.Request); token != "" {
+		if claims, err := tokens.Verify(token); err == nil && claims.RoomID != "" && claims.RoomID == c.Param("id") {
+			return memberAuth{userID: claims.UserID, refresh: true, signed: true}
+		}
+	}
+
+	if headerUserID := c.GetHeader("X-User-ID"); headerUserID != "" && allowUserIDHeader {
+		return memberAuth{userID: headerUserID}
 	}
 
-	return security.GetUserID(c.Request)
+	return memberAuth{}
 }
 
-func setUserIDCookie(c *gin.Context, userID string) {
-	security.SetUserID(c.Writer, userID)
+// IsUserSigned reports whether the request's user was identified by a signed token, only
+// those are trusted with anything granted to a particular user
+func IsUserSigned(c *gin.Context) bool {
+	return c.GetBool(UserSignedKey)
 }
 
 func GetUserFromContext(c *gin.Context) (*model.User, bool) {
@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/apperror"
+	"github.com/hilthontt/visper/api/infrastructure/metrics"
+)
+
+const (
+	APIVersionContextKey = "api_version"
+
+	APIVersionV1 = "v1"
+	APIVersionV2 = "v2"
+)
+
+// VersionPolicy is where an API version sits in its lifecycle, zero times mean not scheduled
+type VersionPolicy struct {
+	Version      string
+	DeprecatedAt time.Time
+	SunsetAt     time.Time
+	Link         string // Migration guide advertised while the version is deprecated
+}
+
+var errVersionSunset = apperror.New(apperror.KindGone, "API_VERSION_SUNSET", "this API version is no longer available")
+
+// APIVersionMiddleware tags the request with its version, advertises deprecation (RFC 9745) and
+// sunset (RFC 8594) dates, and records request metrics per version
+func APIVersionMiddleware(policy VersionPolicy, m metrics.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(APIVersionContextKey, policy.Version)
+		c.Header("API-Version", policy.Version)
+
+		now := time.Now()
+		if !policy.DeprecatedAt.IsZero() && !now.Before(policy.DeprecatedAt) {
+			c.Header("Deprecation", fmt.Sprintf("@%d", policy.DeprecatedAt.Unix()))
+			if policy.Link != "" {
+				c.Header("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", policy.Link))
+			}
+		}
+		if !policy.SunsetAt.IsZero() {
+			c.Header("Sunset", policy.SunsetAt.UTC().Format(http.TimeFormat))
+
+			if !now.Before(policy.SunsetAt) {
+				_ = c.Error(errVersionSunset)
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			// ErrorMiddleware writes the response after us, the status is not known yet
+			if appErr, ok := apperror.As(c.Errors.Last().Err); ok {
+				status = HTTPStatus(appErr.Kind)
+			} else {
+				status = http.StatusInternalServerError
+			}
+		}
+
+		m.IncrementCounter(c.Request.Context(), "http_requests_total",
+			"version", policy.Version, "method", c.Request.Method, "status", strconv.Itoa(status))
+		m.RecordHistogram(c.Request.Context(), "http_request_duration_seconds", time.Since(now).Seconds(),
+			"version", policy.Version)
+	}
+}
+
+// GetAPIVersion returns the version the request came in on, v1 outside the versioned groups
+func GetAPIVersion(c *gin.Context) string {
+	if version := c.GetString(APIVersionContextKey); version != "" {
+		return version
+	}
+	return APIVersionV1
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/admin"
+)
+
+func AdminRoutes(router *gin.RouterGroup, controller admin.AdminController) {
+	router.GET("/rooms", controller.ListRooms)
+	router.DELETE("/rooms/:id", controller.ForceDeleteRoom)
+
+	router.GET("/users/:userId", controller.GetUser)
+	router.POST("/broadcast", controller.Broadcast)
+
+	router.GET("/bans", controller.ListBans)
+	router.POST("/bans/:userId", controller.BanUser)
+	router.DELETE("/bans/:userId", controller.UnbanUser)
+
+	router.GET("/rate-limits", controller.ListRateLimitBlocks)
+	router.DELETE("/rate-limits/:userId", controller.ClearRateLimitBlock)
+
+	// Networks hold a slash, so exemptions are removed by query: ?kind=cidr&value=10.0.0.0/8
+	router.GET("/rate-limit-exemptions", controller.ListRateLimitExemptions)
+	router.POST("/rate-limit-exemptions", controller.AddRateLimitExemption)
+	router.DELETE("/rate-limit-exemptions", controller.RemoveRateLimitExemption)
+
+	router.GET("/feature-flags", controller.ListFeatureFlags)
+	router.PUT("/feature-flags/:name", controller.SetFeatureFlag)
+
+	router.GET("/maintenance", controller.GetMaintenance)
+	router.PUT("/maintenance", controller.SetMaintenance)
+
+	router.GET("/broker/topics", controller.ListTopics)
+	router.POST("/broker/topics", controller.CreateTopic)
+	router.DELETE("/broker/topics/:name", controller.DeleteTopic)
+	router.GET("/broker/groups", controller.ListConsumerGroups)
+
+	router.GET("/audit-logs", controller.ListAuditLogs)
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/auth"
+)
+
+func AuthRoutes(router *gin.RouterGroup, controller auth.AuthController) {
+	authGroup := router.Group("/auth")
+	{
+		authGroup.GET("/oidc/login", controller.Login)
+		authGroup.GET("/oidc/callback", controller.Callback)
+		authGroup.GET("/me", controller.Me)
+		authGroup.DELETE("/identity", controller.Unlink)
+		authGroup.POST("/logout", controller.Logout)
+	}
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/domain/model"
+	"github.com/hilthontt/visper/api/presentation/controllers/bot"
+	"github.com/hilthontt/visper/api/presentation/controllers/message"
+	"github.com/hilthontt/visper/api/presentation/middlewares"
+)
+
+func BotRoutes(router *gin.RouterGroup, controller bot.BotController) {
+	bots := router.Group("/bots")
+	{
+		bots.GET("", controller.ListBots)
+		bots.POST("", controller.RegisterBot)
+		bots.DELETE("/:botId", controller.RevokeBot)
+	}
+
+	router.POST("/rooms/:id/bots/:botId", controller.AddToRoom)
+}
+
+// BotAPIRoutes are reached with a bot token, each route is gated on the scope it needs
+func BotAPIRoutes(router *gin.RouterGroup, controller bot.BotController, messageController message.MessageController, sendLimiter, idempotency gin.HandlerFunc) {
+	router.GET("/me", controller.Me)
+
+	read := middlewares.RequireBotScope(model.BotScopeRead)
+	send := middlewares.RequireBotScope(model.BotScopeSend)
+
+	router.GET("/rooms/:id/messages", read, messageController.GetMessages)
+	router.GET("/rooms/:id/messages/after", read, messageController.GetMessagesAfter)
+	router.POST("/rooms/:id/messages", send, sendLimiter, idempotency, messageController.SendMessage)
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/loglevel"
+)
+
+// LogLevelRoutes leaves reading the levels open like the metrics, changing them goes through guard
+func LogLevelRoutes(router *gin.RouterGroup, controller loglevel.LogLevelController, guard ...gin.HandlerFunc) {
+	router.GET("/loglevel", controller.GetLevels)
+	router.PUT("/loglevel", append(guard, controller.SetLevel)...)
+}
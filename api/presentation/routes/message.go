@@ -5,11 +5,18 @@ import (
 	"github.com/hilthontt/visper/api/presentation/controllers/message"
 )
 
-func MessageRoutes(router *gin.RouterGroup, controller message.MessageController) {
-	router.POST("/rooms/:id/messages", controller.SendMessage)
+func MessageRoutes(router *gin.RouterGroup, controller message.MessageController, idempotency gin.HandlerFunc) {
+	router.POST("/rooms/:id/messages", idempotency, controller.SendMessage)
 	router.GET("/rooms/:id/messages", controller.GetMessages)
 	router.GET("/rooms/:id/messages/after", controller.GetMessagesAfter)
 	router.GET("/rooms/:id/messages/count", controller.GetMessageCount)
 	router.DELETE("/rooms/:id/messages/:messageId", controller.DeleteMessage)
 	router.PUT("/rooms/:id/messages/:messageId", controller.UpdateMessage)
+	router.GET("/rooms/:id/messages/:messageId/replies", controller.GetReplies)
+	router.POST("/rooms/:id/messages/:messageId/reactions", controller.AddReaction)
+	router.DELETE("/rooms/:id/messages/:messageId/reactions", controller.RemoveReaction)
+
+	router.GET("/rooms/:id/announcements", controller.GetAnnouncements)
+	router.POST("/rooms/:id/announcements", controller.PostAnnouncement)
+	router.DELETE("/rooms/:id/announcements/:messageId", controller.UnpinAnnouncement)
 }
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/notification"
+)
+
+func NotificationRoutes(router *gin.RouterGroup, controller notification.NotificationController) {
+	pushGroup := router.Group("/notifications/push")
+	{
+		pushGroup.GET("/vapid-key", controller.GetVAPIDKey)
+		pushGroup.GET("/subscriptions", controller.ListSubscriptions)
+		pushGroup.POST("/subscriptions", controller.RegisterSubscription)
+		pushGroup.DELETE("/subscriptions/:subscriptionId", controller.DeleteSubscription)
+	}
+
+	router.GET("/rooms/:id/notifications", controller.GetRoomPreference)
+	router.PUT("/rooms/:id/notifications", controller.SetRoomPreference)
+}
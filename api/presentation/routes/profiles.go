@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/profiles"
+)
+
+// ProfileRoutes go through guard, unlike the metrics a profile shows what the server is doing
+func ProfileRoutes(router *gin.RouterGroup, controller profiles.ProfilesController, guard ...gin.HandlerFunc) {
+	group := router.Group("/profiles", guard...)
+	{
+		group.GET("", controller.ListCaptures)
+		group.GET("/:id/:name", controller.DownloadProfile)
+	}
+}
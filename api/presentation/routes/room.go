@@ -5,14 +5,21 @@ import (
 	"github.com/hilthontt/visper/api/presentation/controllers/room"
 )
 
-func RoomRoutes(router *gin.RouterGroup, controller room.RoomController) {
+func RoomRoutes(router *gin.RouterGroup, controller room.RoomController, idempotency, creationGate gin.HandlerFunc) {
 	rooms := router.Group("/rooms")
 	{
-		rooms.POST("", controller.CreateRoom)
+		rooms.POST("", creationGate, idempotency, controller.CreateRoom)
 		rooms.GET("/:id", controller.GetRoom)
 		rooms.DELETE("/:id", controller.DeleteRoom)
+		rooms.POST("/:id/export", controller.ExportRoom)
+		rooms.GET("/:id/export", controller.ExportTranscript)
 		rooms.PUT("/:id/join-code", controller.GenerateNewJoinCode)
 		rooms.PUT("/:id/secure-token", controller.RegenerateSecureToken)
+		rooms.GET("/:id/settings", controller.GetSettings)
+		rooms.GET("/:id/keys", controller.GetKeys)
+		rooms.POST("/:id/keys/rotate", controller.RotateKey)
+		rooms.PATCH("/:id/settings", controller.UpdateSettings)
+		rooms.POST("/:id/extend", controller.ExtendRoom)
 
 		rooms.POST("/join-code", controller.JoinRoomByJoinCode)
 		rooms.POST("/join-code/secure", controller.JoinRoomByJoinCodeWithToken)
@@ -20,6 +27,18 @@ func RoomRoutes(router *gin.RouterGroup, controller room.RoomController) {
 		rooms.POST("/:id/join", controller.JoinRoom)
 		rooms.POST("/:id/leave", controller.LeaveRoom)
 		rooms.GET("/:id/membership", controller.CheckMembership)
+		rooms.GET("/:id/presence", controller.GetPresence)
 		rooms.POST("/:id/membership/:userId", controller.KickMember)
+		rooms.POST("/:id/members/:userId/mute", controller.MuteMember)
+		rooms.DELETE("/:id/members/:userId/mute", controller.UnmuteMember)
+
+		rooms.GET("/:id/bans", controller.ListBans)
+		rooms.POST("/:id/bans", controller.BanMember)
+		rooms.DELETE("/:id/bans/:userId", controller.UnbanMember)
+
+		rooms.GET("/:id/invites", controller.ListInvites)
+		rooms.POST("/:id/invites", controller.CreateInvite)
+		rooms.DELETE("/:id/invites/:token", controller.RevokeInvite)
+		rooms.POST("/invites/:token/redeem", controller.RedeemInvite)
 	}
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/shortlink"
+)
+
+func ShortLinkRoutes(router *gin.RouterGroup, controller shortlink.ShortLinkController) {
+	router.POST("/rooms/:id/shortlink", controller.Create)
+}
+
+func ShortLinkRedirectRoutes(router *gin.RouterGroup, controller shortlink.ShortLinkController) {
+	router.GET("/j/:shortCode", controller.Redirect)
+}
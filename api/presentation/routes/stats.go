@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/stats"
+)
+
+func StatsRoutes(router *gin.RouterGroup, controller stats.StatsController) {
+	router.GET("/stats", controller.GetUsageStats)
+}
+
+// RoomStatsRoutes sits beside the room routes, the owner check happens in the use case
+func RoomStatsRoutes(router *gin.RouterGroup, controller stats.StatsController) {
+	router.GET("/rooms/:id/stats", controller.GetRoomStats)
+}
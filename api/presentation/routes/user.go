@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/user"
+)
+
+func UserRoutes(router *gin.RouterGroup, controller user.UserController) {
+	router.DELETE("/users/me/data", controller.PurgeData)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hilthontt/visper/api/presentation/controllers/webhook"
+)
+
+func WebhookRoutes(router *gin.RouterGroup, controller webhook.WebhookController) {
+	rooms := router.Group("/rooms/:id/webhooks")
+	{
+		rooms.GET("", controller.ListWebhooks)
+		rooms.POST("", controller.CreateWebhook)
+		rooms.POST("/incoming", controller.CreateIncomingToken)
+		rooms.DELETE("/incoming", controller.RevokeIncomingToken)
+		rooms.DELETE("/:webhookId", controller.DeleteWebhook)
+		rooms.GET("/:webhookId/deliveries", controller.ListDeliveries)
+	}
+}
+
+func IncomingWebhookRoutes(router *gin.RouterGroup, controller webhook.WebhookController) {
+	router.POST("/:token", controller.PostIncoming)
+}
@@ -13,6 +13,7 @@ func WebsocketRoutes(
 	rooms := router.Group("/rooms")
 	{
 		rooms.GET("/:id/ws", controller.HandleConnection)
+		rooms.POST("/:id/ws-ticket", controller.IssueTicket)
 	}
 
 	users := router.Group("/users")
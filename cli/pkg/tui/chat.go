@@ -3,8 +3,12 @@ package tui
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,7 +18,6 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	apisdk "github.com/hilthontt/visper/api-sdk"
-	"github.com/hilthontt/visper/api-sdk/option"
 	stringfunction "github.com/hilthontt/visper/cli/pkg/string_function"
 	"github.com/hilthontt/visper/cli/pkg/tui/embeds"
 	"github.com/hilthontt/visper/cli/pkg/tui/validate"
@@ -73,6 +76,9 @@ type chatState struct {
 
 	fileExplorer fileExplorerState
 
+	uploading      bool
+	uploadProgress int // percent, only reported for uploads over 1MB
+
 	// AI enhancement
 	aiEnhancing    bool
 	aiEnhanceStyle string
@@ -84,6 +90,29 @@ type chatState struct {
 	imagePreviews map[string]string // messageID+dimensions -> rendered preview string
 	imageFetching map[string]bool   // messageID -> currently fetching
 
+	presence map[string]string // userID -> online, away or offline
+	mentions map[string]bool   // messageIDs that mention the current user
+
+	announcements []apisdk.MessageResponse // pinned, oldest first
+
+	slowModeUntil time.Time
+}
+
+// slowModeMsg is returned when the server rejects a send under slow mode,
+// content is handed back so the draft isn't lost
+type slowModeMsg struct {
+	retryAfter time.Duration
+	content    string
+}
+
+type slowModeTickMsg struct{}
+
+type announcementsLoadedMsg struct {
+	announcements []apisdk.MessageResponse
+}
+
+type presenceLoadedMsg struct {
+	statuses map[string]string
 }
 
 type participantSearchResultMsg struct {
@@ -137,10 +166,6 @@ func (m model) ChatSwitch(newRoom *apisdk.RoomResponse) (model, tea.Cmd) {
 	m = m.SwitchPage(chatPage)
 	m.state.chat.room = newRoom
 
-	if newRoom.EncryptionKey != "" {
-		m.client.Message.SetEncryptionKey(newRoom.EncryptionKey)
-	}
-
 	if m.state.chat.roomCode == "" {
 		msgInput := textinput.New()
 		msgInput.Placeholder = "Type a message..."
@@ -196,6 +221,8 @@ func (m model) ChatSwitch(newRoom *apisdk.RoomResponse) (model, tea.Cmd) {
 			imageFailed:          make(map[string]bool),
 			imagePreviews:        make(map[string]string),
 			imageFetching:        make(map[string]bool),
+			presence:             make(map[string]string),
+			mentions:             make(map[string]bool),
 		}
 
 		return m, tea.Batch(
@@ -250,8 +277,8 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 		if m.state.chat.room == nil {
 			return m, nil
 		}
-		// Show uploading indicator by reusing the AI enhancing flag concept
-		m.state.chat.aiEnhancing = true
+		m.state.chat.uploading = true
+		m.state.chat.uploadProgress = 0
 		return m, m.uploadFile(msg.path)
 
 	case imageFetchedMsg:
@@ -273,7 +300,7 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 		m.state.chat.messagesViewport.SetContent(m.renderMessages())
 		return m, nil
 	case fileUploadResultMsg:
-		m.state.chat.aiEnhancing = false
+		m.state.chat.uploading = false
 		if msg.err != nil {
 			m.state.notify = notifyState{
 				open:          true,
@@ -287,16 +314,8 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 		// Auto-send the image URL as a message
 		fileURL := msg.fileURL
 		roomID := m.state.chat.room.ID
-		userID := ""
-		if m.userID != nil {
-			userID = *m.userID
-		}
 
 		go func() {
-			opts := []option.RequestOption{}
-			if userID != "" {
-				opts = append(opts, option.WithHeader("X-User-ID", userID))
-			}
 			_, err := m.client.Message.Send(
 				m.context,
 				roomID,
@@ -304,7 +323,6 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 					Content:   fileURL,
 					Encrypted: true,
 				},
-				opts...,
 			)
 			if err != nil {
 				log.Printf("Failed to send image message: %v", err)
@@ -346,16 +364,10 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 	case kickMemberSubmittedMsg:
 		if m.state.chat.room != nil {
 			go func() {
-				opts := []option.RequestOption{}
-				if m.userID != nil && *m.userID != "" {
-					opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-				}
-
 				_, err := m.client.Room.KickMember(
 					m.context,
 					m.state.chat.room.ID,
 					msg.userID,
-					opts...,
 				)
 				if err != nil {
 					log.Printf("Failed to kick member: %v", err)
@@ -371,16 +383,10 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 	case messageDeleteSubmittedMsg:
 		if m.state.chat.room != nil {
 			go func() {
-				opts := []option.RequestOption{}
-				if m.userID != nil && *m.userID != "" {
-					opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-				}
-
 				_, err := m.client.Message.Delete(
 					m.context,
 					m.state.chat.room.ID,
 					msg.messageID,
-					opts...,
 				)
 				if err != nil {
 					log.Printf("Failed to delete message: %v", err)
@@ -391,11 +397,6 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 	case messageEditSubmittedMsg:
 		if m.state.chat.room != nil {
 			go func() {
-				opts := []option.RequestOption{}
-				if m.userID != nil && *m.userID != "" {
-					opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-				}
-
 				_, err := m.client.Message.Update(
 					m.context,
 					m.state.chat.room.ID,
@@ -403,7 +404,6 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 					apisdk.UpdateMessageParams{
 						Content: msg.newContent,
 					},
-					opts...,
 				)
 				if err != nil {
 					log.Printf("Failed to update message: %v", err)
@@ -429,8 +429,71 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 		m.state.chat.wsMsgChan = msg.msgChan
 		return m, tea.Batch(
 			waitForWSMessage(msg.msgChan),
+			m.fetchPresence(),
+			m.fetchAnnouncements(),
 		)
 
+	case presenceLoadedMsg:
+		for userID, status := range msg.statuses {
+			m.state.chat.presence[userID] = status
+		}
+		return m, nil
+
+	case slowModeMsg:
+		if m.state.chat.messageInput.Value() == "" {
+			m.state.chat.messageInput.SetValue(msg.content)
+		}
+		return m, m.startSlowModeCountdown(msg.retryAfter)
+
+	case wsUploadProgressMsg:
+		if m.state.chat.uploading {
+			m.state.chat.uploadProgress = msg.percent
+		}
+		if m.state.chat.wsMsgChan != nil {
+			cmds = append(cmds, waitForWSMessage(m.state.chat.wsMsgChan))
+		}
+		return m, tea.Batch(cmds...)
+
+	case wsSlowModeMsg:
+		cmds = append(cmds, m.startSlowModeCountdown(msg.retryAfter))
+		if m.state.chat.wsMsgChan != nil {
+			cmds = append(cmds, waitForWSMessage(m.state.chat.wsMsgChan))
+		}
+		return m, tea.Batch(cmds...)
+
+	case slowModeTickMsg:
+		return m, m.updateSlowModeCountdown()
+
+	case announcementsLoadedMsg:
+		m.state.chat.announcements = msg.announcements
+		m.state.chat.messagesViewport.SetContent(m.renderMessages())
+		return m, nil
+
+	case wsAnnouncementMsg:
+		m.state.chat.messages = append(m.state.chat.messages, msg.message)
+		m.state.chat.announcements = append(m.state.chat.announcements, msg.message)
+		m.state.chat.messagesViewport.SetContent(m.renderMessages())
+		m.state.chat.messagesViewport.GotoBottom()
+		if m.state.chat.wsMsgChan != nil {
+			return m, waitForWSMessage(m.state.chat.wsMsgChan)
+		}
+		return m, nil
+
+	case wsMentionedMsg:
+		m.state.chat.mentions[msg.messageID] = true
+		m.state.chat.messagesViewport.SetContent(m.renderMessages())
+		if m.state.chat.wsMsgChan != nil {
+			return m, waitForWSMessage(m.state.chat.wsMsgChan)
+		}
+		return m, nil
+
+	case wsPresenceChangedMsg:
+		m.state.chat.presence[msg.userID] = msg.status
+		if m.state.chat.wsMsgChan != nil {
+			return m, waitForWSMessage(m.state.chat.wsMsgChan)
+		}
+		return m, nil
+
 	case wsMessageReceivedMsg:
 		m.state.chat.messages = append(m.state.chat.messages, msg.message)
 
@@ -571,6 +634,18 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 			return m, waitForWSMessage(m.state.chat.wsMsgChan)
 		}
 		return m, nil
+	case wsRoomExpiringSoonMsg:
+		m.state.notify = notifyState{
+			open:          true,
+			title:         "Room Expiring Soon",
+			content:       fmt.Sprintf("This room expires in %s", msg.remaining),
+			confirmAction: NoAction,
+		}
+		if m.state.chat.wsMsgChan != nil {
+			return m, waitForWSMessage(m.state.chat.wsMsgChan)
+		}
+		return m, nil
+
 	case wsRoomDeletedMsg:
 		m.state.notify = notifyState{
 			open:          true,
@@ -578,6 +653,10 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 			content:       "This room has been deleted by the owner",
 			confirmAction: NoAction,
 		}
+		if msg.expired {
+			m.state.notify.title = "Room Expired"
+			m.state.notify.content = "This room has reached its expiry time"
+		}
 		return m, tea.Tick(2*time.Second, func(t time.Time) tea.Msg {
 			return wsRoomDeletedTimeoutMsg{}
 		})
@@ -672,15 +751,9 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 				case "y", "Y", "enter":
 					if m.state.chat.room != nil {
 						go func() {
-							opts := []option.RequestOption{}
-							if m.userID != nil && *m.userID != "" {
-								opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-							}
-
 							err := m.client.Room.GenerateNewJoinCode(
 								m.context,
 								m.state.chat.room.ID,
-								opts...,
 							)
 							if err != nil {
 								log.Printf("Failed to generate new join code: %v", err)
@@ -913,25 +986,11 @@ func (m model) ChatUpdate(msg tea.Msg) (model, tea.Cmd) {
 				m.state.chat.messageInput.SetValue("")
 
 				if m.state.chat.room != nil {
-					go func() {
-						opts := []option.RequestOption{}
-						if m.userID != nil && *m.userID != "" {
-							opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-						}
-
-						_, err := m.client.Message.Send(
-							m.context,
-							m.state.chat.room.ID,
-							apisdk.SendMessageParams{
-								Content:   content,
-								Encrypted: true,
-							},
-							opts...,
-						)
-						if err != nil {
-							log.Printf("Failed to send message: %v", err)
-						}
-					}()
+					// Owners post announcements with /announce, the server rejects anyone else
+					if announcement, ok := strings.CutPrefix(content, "/announce "); ok && strings.TrimSpace(announcement) != "" {
+						return m, m.sendAnnouncement(strings.TrimSpace(announcement))
+					}
+					return m, m.sendMessage(content)
 				}
 
 				return m, nil
@@ -1178,7 +1237,7 @@ func (m model) renderParticipantsSidebar(width, height int) string {
 
 		p := m.state.chat.participants[idx]
 		statusIcon := "●"
-		status := m.theme.Base().Foreground(lipgloss.Color("#10B981")).Render(statusIcon)
+		status := m.theme.Base().Foreground(presenceColor(m.state.chat.presence[p.ID])).Render(statusIcon)
 		username := m.theme.TextBody().Render(p.Username)
 
 		line := lipgloss.JoinHorizontal(lipgloss.Left, status, " ", username)
@@ -1224,6 +1283,12 @@ func (m model) renderChatCenter(width, height int) string {
 		sb.WriteString("\n")
 	}
 
+	if m.state.chat.uploading {
+		uploadStyle := m.theme.Base().Foreground(lipgloss.Color("#F59E0B")).Bold(true)
+		sb.WriteString(uploadStyle.Render("  ⇪ Uploading image... " + renderProgressBar(m.state.chat.uploadProgress, 20)))
+		sb.WriteString("\n")
+	}
+
 	var hint string
 	if m.state.chat.editMode {
 		hint = m.theme.TextAccent().Bold(true).Render("EDIT MODE: ↑/↓ to select, Enter to edit, Esc to cancel")
@@ -1250,6 +1315,18 @@ func (m model) renderMessages() string {
 		userID = *m.userID
 	}
 
+	if n := len(m.state.chat.announcements); n > 0 {
+		pinned := m.state.chat.announcements[n-1]
+		banner := m.theme.Base().
+			Foreground(m.theme.Highlight()).
+			Bold(true).
+			Padding(0, 1).
+			MarginBottom(1).
+			Render("📌 " + pinned.Username + ": " + pinned.Content)
+		sb.WriteString(banner)
+		sb.WriteString("\n")
+	}
+
 	for i, msg := range m.state.chat.messages {
 		timestamp := m.theme.TextBody().Faint(true).Render(msg.CreatedAt.Format("3:04 PM"))
 
@@ -1274,6 +1351,14 @@ func (m model) renderMessages() string {
 		}
 
 		header := lipgloss.JoinHorizontal(lipgloss.Left, selectionIndicator, timestamp, " ", username)
+		if msg.IsAnnouncement() {
+			badge := m.theme.Base().Foreground(m.theme.Highlight()).Bold(true).Render(" 📢 Announcement")
+			header = lipgloss.JoinHorizontal(lipgloss.Left, header, badge)
+		}
+		if m.mentionsCurrentUser(msg, userID) {
+			badge := m.theme.Base().Foreground(m.theme.Highlight()).Bold(true).Render(" @you")
+			header = lipgloss.JoinHorizontal(lipgloss.Left, header, badge)
+		}
 		content := m.renderMessageContent(msg, isOwnMessage)
 
 		msgStyle := m.theme.Base().
@@ -1288,6 +1373,20 @@ func (m model) renderMessages() string {
 	return sb.String()
 }
 
+// Live messages are flagged by the mention event, history carries the server's resolved mentions
+func (m model) mentionsCurrentUser(msg apisdk.MessageResponse, userID string) bool {
+	if m.state.chat.mentions[msg.ID] {
+		return true
+	}
+
+	for _, mention := range msg.Mentions {
+		if mention.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
 func (m model) renderMessageContent(msg apisdk.MessageResponse, isOwnMessage bool) string {
 	if isImageURL(msg.Content) {
 		if m.state.chat.imageFailed[msg.ID] {
@@ -1311,6 +1410,9 @@ func (m model) renderMessageContent(msg apisdk.MessageResponse, isOwnMessage boo
 		return m.theme.TextBody().Faint(true).Render("⏳ loading image...")
 	}
 
+	if msg.IsAnnouncement() {
+		return m.theme.Base().Foreground(m.theme.Highlight()).Bold(true).Render(msg.Content)
+	}
 	if isOwnMessage {
 		return m.theme.TextAccent().Render(msg.Content)
 	}
@@ -1417,6 +1519,124 @@ func (m model) openEditMessageModal(currentContent string) model {
 	return m
 }
 
+func (m model) fetchPresence() tea.Cmd {
+	return func() tea.Msg {
+		if m.state.chat.room == nil {
+			return nil
+		}
+
+		res, err := m.client.Room.GetPresence(m.context, m.state.chat.room.ID)
+		if err != nil {
+			log.Printf("Failed to fetch presence: %v", err)
+			return nil
+		}
+
+		statuses := make(map[string]string, len(res.Members))
+		for _, member := range res.Members {
+			statuses[member.UserID] = member.Status
+		}
+		return presenceLoadedMsg{statuses: statuses}
+	}
+}
+
+func (m model) fetchAnnouncements() tea.Cmd {
+	return func() tea.Msg {
+		if m.state.chat.room == nil {
+			return nil
+		}
+
+		res, err := m.client.Message.ListAnnouncements(m.context, m.state.chat.room.ID)
+		if err != nil {
+			log.Printf("Failed to fetch announcements: %v", err)
+			return nil
+		}
+		return announcementsLoadedMsg{announcements: res.Announcements}
+	}
+}
+
+func (m model) sendAnnouncement(content string) tea.Cmd {
+	roomID := m.state.chat.room.ID
+
+	return func() tea.Msg {
+		_, err := m.client.Message.Announce(m.context, roomID, apisdk.AnnouncementParams{Content: content, Encrypted: true})
+		if err != nil {
+			log.Printf("Failed to post announcement: %v", err)
+		}
+		return nil
+	}
+}
+
+func (m model) sendMessage(content string) tea.Cmd {
+	roomID := m.state.chat.room.ID
+
+	return func() tea.Msg {
+		_, err := m.client.Message.Send(
+			m.context,
+			roomID,
+			apisdk.SendMessageParams{
+				Content:   content,
+				Encrypted: true,
+			},
+		)
+		if err == nil {
+			return nil
+		}
+
+		var apiErr *apisdk.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests && apiErr.Response != nil {
+			if seconds, convErr := strconv.Atoi(apiErr.Response.Header.Get("Retry-After")); convErr == nil {
+				return slowModeMsg{retryAfter: time.Duration(seconds) * time.Second, content: content}
+			}
+		}
+
+		log.Printf("Failed to send message: %v", err)
+		return nil
+	}
+}
+
+// startSlowModeCountdown only schedules a tick when no countdown is running, a later deadline just extends it
+func (m *model) startSlowModeCountdown(retryAfter time.Duration) tea.Cmd {
+	if retryAfter <= 0 {
+		return nil
+	}
+
+	running := time.Now().Before(m.state.chat.slowModeUntil)
+	if until := time.Now().Add(retryAfter); until.After(m.state.chat.slowModeUntil) {
+		m.state.chat.slowModeUntil = until
+	}
+
+	if running {
+		return nil
+	}
+	return m.updateSlowModeCountdown()
+}
+
+func (m *model) updateSlowModeCountdown() tea.Cmd {
+	remaining := time.Until(m.state.chat.slowModeUntil)
+	if remaining <= 0 {
+		m.state.chat.messageInput.Placeholder = "Type a message..."
+		return nil
+	}
+
+	seconds := int(math.Ceil(remaining.Seconds()))
+	m.state.chat.messageInput.Placeholder = fmt.Sprintf("Slow mode: you can send again in %ds", seconds)
+
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return slowModeTickMsg{}
+	})
+}
+
+func presenceColor(status string) lipgloss.Color {
+	switch status {
+	case "online":
+		return lipgloss.Color("#10B981")
+	case "away":
+		return lipgloss.Color("#F59E0B")
+	default:
+		return lipgloss.Color("#6B7280")
+	}
+}
+
 func (m model) fetchImage(messageID, url string) tea.Cmd {
 	return func() tea.Msg {
 		bytes, err := fetchImageBytes(url)
@@ -2,21 +2,15 @@ package tui
 
 import (
 	"log"
-
-	apisdk "github.com/hilthontt/visper/api-sdk"
 )
 
-func (m model) decryptContent(content string, encrypted bool) string {
+// decryptContent opens content with the room key of keyVersion, zero tries every key we hold
+func (m model) decryptContent(content string, encrypted bool, keyVersion int) string {
 	if !encrypted {
 		return content
 	}
 
-	if m.state.chat.room == nil || m.state.chat.room.EncryptionKey == "" {
-		log.Printf("Cannot decrypt: no encryption key available")
-		return "[Decryption failed: no key]"
-	}
-
-	decrypted, err := apisdk.DecryptWithKeyB64(content, m.state.chat.room.EncryptionKey)
+	decrypted, err := m.client.Message.Decrypt(content, keyVersion)
 	if err != nil {
 		log.Printf("Failed to decrypt message: %v", err)
 		return "[Decryption failed]"
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -14,11 +15,6 @@ type fileUploadResultMsg struct {
 
 func (m model) uploadFile(filePath string) tea.Cmd {
 	return func() tea.Msg {
-		userID := ""
-		if m.userID != nil {
-			userID = *m.userID
-		}
-
 		if m.state.chat.room == nil {
 			return fileUploadResultMsg{err: fmt.Errorf("not in a room")}
 		}
@@ -27,7 +23,6 @@ func (m model) uploadFile(filePath string) tea.Cmd {
 			m.context,
 			m.state.chat.room.ID,
 			filePath,
-			userID,
 		)
 		if err != nil {
 			return fileUploadResultMsg{err: err}
@@ -39,3 +34,9 @@ func (m model) uploadFile(filePath string) tea.Cmd {
 		}
 	}
 }
+
+// renderProgressBar draws percent as a bar width cells wide, small uploads stay at 0% until they are done
+func renderProgressBar(percent, width int) string {
+	filled := min(max(percent, 0), 100) * width / 100
+	return fmt.Sprintf("[%s%s] %d%%", strings.Repeat("█", filled), strings.Repeat("░", width-filled), percent)
+}
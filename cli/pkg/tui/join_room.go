@@ -8,7 +8,6 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	apisdk "github.com/hilthontt/visper/api-sdk"
-	"github.com/hilthontt/visper/api-sdk/option"
 )
 
 type joinRoomState struct {
@@ -107,15 +106,10 @@ func (m model) JoinRoomUpdate(msg tea.Msg) (model, tea.Cmd) {
 			m.state.joinRoom.error = ""
 			m.state.joinRoom.joining = true
 
-			opts := []option.RequestOption{}
-			if m.userID != nil && *m.userID != "" {
-				opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-			}
-
 			roomToJoin, err := m.client.Room.GetByJoinCode(m.context, apisdk.JoinByCodeParams{
 				JoinCode: roomCode,
 				Username: "",
-			}, opts...)
+			})
 
 			if err != nil {
 				m.state.joinRoom.error = "Failed to join room"
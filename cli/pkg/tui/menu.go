@@ -19,7 +19,7 @@ func (m model) MenuSwitch() (model, tea.Cmd) {
 
 	// Reconnect notification WebSocket if we came from chat
 	// (where it was disconnected)
-	if m.state.notification.wsConn == nil && m.userID != nil && *m.userID != "" {
+	if m.state.notification.wsConn == nil {
 		ctx, cancel := context.WithCancel(context.Background())
 		m.state.notification.wsCtx = ctx
 		m.state.notification.wsCancel = cancel
@@ -7,7 +7,6 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	apisdk "github.com/hilthontt/visper/api-sdk"
-	"github.com/hilthontt/visper/api-sdk/option"
 )
 
 type newRoomState struct {
@@ -24,7 +23,7 @@ func (m model) NewRoomSwitch() (model, tea.Cmd) {
 	m = m.initNewRoom()
 
 	// Reconnect notification WebSocket if coming from chat (where it was disconnected)
-	if m.state.notification.wsConn == nil && m.userID != nil && *m.userID != "" {
+	if m.state.notification.wsConn == nil {
 		ctx, cancel := context.WithCancel(context.Background())
 		m.state.notification.wsCtx = ctx
 		m.state.notification.wsCancel = cancel
@@ -187,15 +186,10 @@ func (m model) NewRoomUpdate(msg tea.Msg) (model, tea.Cmd) {
 			m.state.newRoom.creating = true
 			m.state.newRoom.error = ""
 
-			opts := []option.RequestOption{}
-			if m.userID != nil && *m.userID != "" {
-				opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-			}
-
 			// Create room with 24 hour expiry
 			newRoom, err := m.client.Room.Create(m.context, apisdk.RoomCreateParams{
 				ExpiryHours: 24,
-			}, opts...)
+			})
 
 			if err != nil {
 				m.state.newRoom.creating = false
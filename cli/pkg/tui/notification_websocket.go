@@ -7,7 +7,6 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	apisdk "github.com/hilthontt/visper/api-sdk"
-	"github.com/hilthontt/visper/api-sdk/option"
 )
 
 type notificationWSConnectedMsg struct {
@@ -38,21 +37,8 @@ type roomInviteDeclinedMsg struct{}
 
 func (m model) connectNotificationWebSocket() tea.Cmd {
 	return func() tea.Msg {
-		var userID string
-		if m.userID != nil {
-			userID = *m.userID
-		}
-
-		if userID == "" {
-			log.Printf("Cannot connect notification WebSocket: no user ID")
-			return nil
-		}
-
-		opts := []option.RequestOption{
-			option.WithHeader("X-User-ID", userID),
-		}
-
-		ws, err := m.client.ConnectNotificationWebSocket(m.context, opts...)
+		// The client's member session says who we are, the handshake starts one if needed
+		ws, err := m.client.ConnectNotificationWebSocket(m.context)
 		if err != nil {
 			log.Printf("Failed to connect notification WebSocket: %v", err)
 			return notificationWSErrorMsg{
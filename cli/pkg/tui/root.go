@@ -11,9 +11,7 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/google/uuid"
 	apisdk "github.com/hilthontt/visper/api-sdk"
-	"github.com/hilthontt/visper/api-sdk/option"
 	filepreview "github.com/hilthontt/visper/cli/pkg/file_preview"
 	"github.com/hilthontt/visper/cli/pkg/generator"
 	"github.com/hilthontt/visper/cli/pkg/settings_manager"
@@ -108,11 +106,6 @@ type model struct {
 func NewModel(renderer *lipgloss.Renderer, generator *generator.Generator) (tea.Model, error) {
 	ctx := context.Background()
 
-	userID := uuid.NewString()
-
-	// TODO Display in header
-	log.Printf("USERID: %s\n", userID)
-
 	m := model{
 		context:  ctx,
 		page:     splashPage,
@@ -135,7 +128,6 @@ func NewModel(renderer *lipgloss.Renderer, generator *generator.Generator) (tea.
 		generator:       generator,
 		imagePreviewer:  filepreview.NewImagePreviewer(),
 		settingsManager: settings_manager.NewSettingsManager(),
-		userID:          &userID,
 	}
 
 	return m, nil
@@ -147,15 +139,13 @@ func (m model) Init() tea.Cmd {
 	}
 
 	// Only initialize the context, don't connect yet
-	if m.userID != nil && *m.userID != "" {
-		ctx, cancel := context.WithCancel(context.Background())
-		m.state.notification = notificationListenerState{
-			wsCtx:    ctx,
-			wsCancel: cancel,
-		}
-		// Don't connect here - client doesn't exist yet!
-		// Connection will happen in SplashUpdate after client is ready
+	ctx, cancel := context.WithCancel(context.Background())
+	m.state.notification = notificationListenerState{
+		wsCtx:    ctx,
+		wsCancel: cancel,
 	}
+	// Don't connect here - client doesn't exist yet!
+	// Connection will happen in SplashUpdate after client is ready
 
 	return tea.Batch(cmds...)
 }
@@ -409,17 +399,12 @@ func (m model) Cleanup() {
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 			defer cancel()
 
-			opts := []option.RequestOption{}
-			if m.userID != nil && *m.userID != "" {
-				opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-			}
-
 			var err error
 
 			if m.state.chat.isRoomOwner {
-				err = m.client.Room.Delete(ctx, roomID, opts...)
+				err = m.client.Room.Delete(ctx, roomID)
 			} else {
-				_, err = m.client.Room.Leave(ctx, roomID, opts...)
+				_, err = m.client.Room.Leave(ctx, roomID)
 			}
 
 			if err != nil {
@@ -445,17 +430,11 @@ func (m model) cleanupCmd() tea.Msg {
 
 func (m model) joinRoomFromInvite(invite *roomInviteData) tea.Cmd {
 	return func() tea.Msg {
-		opts := []option.RequestOption{}
-		if m.userID != nil && *m.userID != "" {
-			opts = append(opts, option.WithHeader("X-User-ID", *m.userID))
-		}
-
 		room, err := m.client.Room.GetByJoinCode(
 			m.context,
 			apisdk.JoinByCodeParams{
 				JoinCode: invite.joinCode,
 			},
-			opts...,
 		)
 		if err != nil {
 			log.Printf("Failed to join room from invite: %v", err)
@@ -79,14 +79,12 @@ func (m model) SplashUpdate(msg tea.Msg) (model, tea.Cmd) {
 		cmds = append(cmds, m.LoadCmds()...)
 
 		// Initialize notification context and connect
-		if m.userID != nil && *m.userID != "" {
-			ctx, cancel := context.WithCancel(context.Background())
-			m.state.notification = notificationListenerState{
-				wsCtx:    ctx,
-				wsCancel: cancel,
-			}
-			cmds = append(cmds, m.connectNotificationWebSocket())
+		ctx, cancel := context.WithCancel(context.Background())
+		m.state.notification = notificationListenerState{
+			wsCtx:    ctx,
+			wsCancel: cancel,
 		}
+		cmds = append(cmds, m.connectNotificationWebSocket())
 
 		return m, tea.Batch(cmds...)
 
@@ -3,10 +3,10 @@ package tui
 import (
 	"context"
 	"log"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	apisdk "github.com/hilthontt/visper/api-sdk"
-	"github.com/hilthontt/visper/api-sdk/option"
 )
 
 type wsConnectedMsg struct {
@@ -39,6 +39,20 @@ type wsMemberLeftMsg struct {
 	username string
 }
 
+type wsAnnouncementMsg struct {
+	message apisdk.MessageResponse
+}
+
+type wsMentionedMsg struct {
+	messageID string
+	username  string
+}
+
+type wsPresenceChangedMsg struct {
+	userID string
+	status string
+}
+
 type wsMemberListMsg struct {
 	members []apisdk.UserResponse
 }
@@ -49,7 +63,13 @@ type wsKickedMsg struct {
 	reason   string
 }
 
-type wsRoomDeletedMsg struct{}
+type wsRoomDeletedMsg struct {
+	expired bool
+}
+
+type wsRoomExpiringSoonMsg struct {
+	remaining time.Duration
+}
 
 type wsRoomUpdatedMsg struct {
 	joinCode string
@@ -60,6 +80,14 @@ type wsErrorMsg struct {
 	message string
 }
 
+type wsSlowModeMsg struct {
+	retryAfter time.Duration
+}
+
+type wsUploadProgressMsg struct {
+	percent int
+}
+
 type wsDisconnectedMsg struct{}
 
 type wsKickTimeoutMsg struct{}
@@ -77,17 +105,15 @@ func getStringField(data map[string]any, keys ...string) (string, bool) {
 
 func (m model) connectWebSocket(roomID string) tea.Cmd {
 	return func() tea.Msg {
-		var userID string
-		if m.userID != nil {
-			userID = *m.userID
-		}
-
-		opts := []option.RequestOption{}
-		if userID != "" {
-			opts = append(opts, option.WithHeader("X-User-ID", userID))
+		// Keys are only served to members, so fetch them once we are in the room
+		keys, err := m.client.Room.GetKeys(m.context, roomID)
+		if err != nil {
+			log.Printf("Failed to fetch room keys: %v", err)
+		} else {
+			m.client.Message.SetRoomKeys(keys)
 		}
 
-		ws, err := m.client.Room.ConnectWebSocket(m.context, roomID, opts...)
+		ws, err := m.client.Room.ConnectWebSocket(m.context, roomID)
 		if err != nil {
 			log.Printf("Failed to connect WebSocket: %v", err)
 			return wsErrorMsg{
@@ -125,13 +151,14 @@ func (m model) listenWebSocket() tea.Cmd {
 					if encVal, ok := data["encrypted"].(bool); ok {
 						encrypted = encVal
 					}
+					keyVersion, _ := data["keyVersion"].(float64)
 
 					log.Printf("🔍 WS Message Received:")
 					log.Printf("   Content (first 50 chars): %s", content[:min(50, len(content))])
 					log.Printf("   Encrypted flag: %v", encrypted)
-					log.Printf("   Has encryption key: %v", m.state.chat.room != nil && m.state.chat.room.EncryptionKey != "")
+					log.Printf("   Key version: %d", int(keyVersion))
 
-					content = m.decryptContent(content, encrypted)
+					content = m.decryptContent(content, encrypted, int(keyVersion))
 
 					if okID && okUserID && okUsername && okContent {
 						msg := apisdk.MessageResponse{
@@ -163,7 +190,7 @@ func (m model) listenWebSocket() tea.Cmd {
 						encrypted = encVal
 					}
 
-					content = m.decryptContent(content, encrypted)
+					content = m.decryptContent(content, encrypted, 0)
 
 					if idOk && contentOk {
 						select {
@@ -268,6 +295,67 @@ func (m model) listenWebSocket() tea.Cmd {
 					}
 				}
 
+			case apisdk.Announcement:
+				if data, ok := wsMsg.Data.(map[string]any); ok {
+					id, okID := getStringField(data, "id", "ID")
+					userID, okUserID := getStringField(data, "userId", "UserID", "user_id")
+					username, _ := getStringField(data, "username", "Username")
+					content, okContent := getStringField(data, "content", "Content")
+					encrypted, _ := data["encrypted"].(bool)
+					keyVersion, _ := data["keyVersion"].(float64)
+
+					if okID && okUserID && okContent {
+						msg := apisdk.MessageResponse{
+							ID:       id,
+							RoomID:   wsMsg.RoomID,
+							UserID:   userID,
+							Username: username,
+							Content:  m.decryptContent(content, encrypted, int(keyVersion)),
+							Type:     "announcement",
+						}
+
+						select {
+						case msgChan <- wsAnnouncementMsg{message: msg}:
+						case <-m.state.chat.wsCtx.Done():
+							return
+						}
+					} else {
+						log.Printf("Invalid announcement payload: %+v", data)
+					}
+				}
+
+			case apisdk.Mentioned:
+				if data, ok := wsMsg.Data.(map[string]any); ok {
+					messageID, okID := getStringField(data, "messageId", "MessageID")
+					username, _ := getStringField(data, "username", "Username")
+
+					if okID {
+						select {
+						case msgChan <- wsMentionedMsg{messageID: messageID, username: username}:
+						case <-m.state.chat.wsCtx.Done():
+							return
+						}
+					} else {
+						log.Printf("Invalid mention payload: %+v", data)
+					}
+				}
+
+			case apisdk.PresenceChanged:
+				if data, ok := wsMsg.Data.(map[string]any); ok {
+					userID, okID := getStringField(data, "userId", "UserID", "user_id")
+					status, okStatus := getStringField(data, "status", "Status")
+
+					if okID && okStatus {
+						select {
+						case msgChan <- wsPresenceChangedMsg{userID: userID, status: status}:
+						case <-m.state.chat.wsCtx.Done():
+							return
+						}
+					} else {
+						log.Printf("Invalid presence changed payload: %+v", data)
+					}
+				}
+
 			case apisdk.Kicked:
 				if data, ok := wsMsg.Data.(map[string]any); ok {
 					userID, okUserID := getStringField(data, "userId", "userID")
@@ -294,9 +382,51 @@ func (m model) listenWebSocket() tea.Cmd {
 					}
 				}
 
-			case apisdk.RoomDeleted:
+			case apisdk.SlowMode:
+				if data, ok := wsMsg.Data.(map[string]any); ok {
+					seconds, _ := data["retryAfter"].(float64)
+
+					select {
+					case msgChan <- wsSlowModeMsg{retryAfter: time.Duration(seconds) * time.Second}:
+					case <-m.state.chat.wsCtx.Done():
+						return
+					}
+				}
+
+			case apisdk.UploadProgress:
+				if data, ok := wsMsg.Data.(map[string]any); ok {
+					percent, _ := data["percent"].(float64)
+
+					select {
+					case msgChan <- wsUploadProgressMsg{percent: int(percent)}:
+					case <-m.state.chat.wsCtx.Done():
+						return
+					}
+				}
+
+			case apisdk.RoomKeyRotated:
+				// The new key is never pushed over the socket, fetch it like on join
+				keys, err := m.client.Room.GetKeys(m.state.chat.wsCtx, wsMsg.RoomID)
+				if err != nil {
+					log.Printf("Failed to refresh room keys: %v", err)
+				} else {
+					m.client.Message.SetRoomKeys(keys)
+				}
+
+			case apisdk.RoomExpiringSoon:
+				if data, ok := wsMsg.Data.(map[string]any); ok {
+					seconds, _ := data["secondsLeft"].(float64)
+
+					select {
+					case msgChan <- wsRoomExpiringSoonMsg{remaining: time.Duration(seconds) * time.Second}:
+					case <-m.state.chat.wsCtx.Done():
+						return
+					}
+				}
+
+			case apisdk.RoomDeleted, apisdk.RoomExpired:
 				select {
-				case msgChan <- wsRoomDeletedMsg{}:
+				case msgChan <- wsRoomDeletedMsg{expired: wsMsg.Type == apisdk.RoomExpired}:
 				case <-m.state.chat.wsCtx.Done():
 					return
 				}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogConfig writes one line per request, as JSON or in Apache's combined format
+// followed by the latency, backend and request ID
+type AccessLogConfig struct {
+	Format     string   `json:"format"` // json, combined or off
+	Path       string   `json:"path"`   // stdout when empty
+	MaxSizeMB  int      `json:"max_size_mb"`
+	MaxBackups int      `json:"max_backups"`
+	Exclude    []string `json:"exclude"` // Paths not logged, a trailing slash covers everything below
+}
+
+const (
+	defaultAccessLogBackups = 5
+	requestIDHeader         = "X-Request-ID"
+)
+
+// AccessLogger writes the access log
+type AccessLogger struct {
+	format   string
+	exclude  []string
+	clientIP func(*http.Request) string
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+type accessEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	ClientIP  string    `json:"client_ip"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Protocol  string    `json:"protocol"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Latency   float64   `json:"latency_seconds"`
+	Backend   string    `json:"backend,omitempty"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+type accessRecordKey struct{}
+
+// accessRecord carries what only the load balancer knows back to the access log
+type accessRecord struct {
+	backend string
+}
+
+// NewAccessLogger opens the access log, it returns nil when logging is off
+func NewAccessLogger(config AccessLogConfig, clientIP func(*http.Request) string) (*AccessLogger, error) {
+	format := config.Format
+	if format == "" {
+		format = "json"
+	}
+
+	switch format {
+	case "off":
+		return nil, nil
+	case "json", "combined":
+	default:
+		return nil, fmt.Errorf("unknown access log format: %s", config.Format)
+	}
+
+	var out io.Writer = os.Stdout
+	if config.Path != "" {
+		backups := config.MaxBackups
+		if backups <= 0 {
+			backups = defaultAccessLogBackups
+		}
+
+		file, err := openRotatingFile(config.Path, int64(config.MaxSizeMB)<<20, backups)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+
+	return &AccessLogger{
+		format:   format,
+		exclude:  config.Exclude,
+		clientIP: clientIP,
+		out:      out,
+	}, nil
+}
+
+func (l *AccessLogger) excluded(path string) bool {
+	for _, exclude := range l.exclude {
+		if path == exclude || (strings.HasSuffix(exclude, "/") && strings.HasPrefix(path, exclude)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *AccessLogger) write(entry accessEntry) {
+	var line bytes.Buffer
+	if l.format == "json" {
+		json.NewEncoder(&line).Encode(entry)
+	} else {
+		fmt.Fprintf(&line, "%s - - [%s] %q %d %s %q %q %.3f %q %q\n",
+			entry.ClientIP,
+			entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+			entry.Method+" "+entry.Path+" "+entry.Protocol,
+			entry.Status,
+			combinedBytes(entry.Bytes),
+			orDash(entry.Referer),
+			orDash(entry.UserAgent),
+			entry.Latency,
+			orDash(entry.Backend),
+			orDash(entry.RequestID),
+		)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line.Bytes())
+}
+
+func combinedBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// AccessLogMiddleware logs every request once it has been answered, a nil logger
+// logs nothing
+func AccessLogMiddleware(l *AccessLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.excluded(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			record := &accessRecord{}
+			aw := &accessResponseWriter{ResponseWriter: w}
+
+			next.ServeHTTP(aw, r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, record)))
+
+			status := aw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			l.write(accessEntry{
+				Time:      start.UTC(),
+				RequestID: r.Header.Get(requestIDHeader),
+				ClientIP:  l.clientIP(r),
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Protocol:  r.Proto,
+				Status:    status,
+				Bytes:     aw.bytes,
+				Latency:   time.Since(start).Seconds(),
+				Backend:   record.backend,
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+			})
+		})
+	}
+}
+
+// recordBackend notes the backend chosen for r in its access log entry
+func recordBackend(r *http.Request, backend *Backend) {
+	if record, ok := r.Context().Value(accessRecordKey{}).(*accessRecord); ok {
+		record.backend = backend.URL.Host
+	}
+}
+
+// RequestIDMiddleware gives every request an X-Request-ID, keeping the client's own, and
+// returns it on the response so a request can be followed through the backends' logs
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+			r.Header.Set(requestIDHeader, id)
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessResponseWriter captures the status code and the size of the body
+type accessResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *accessResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessResponseWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack hands the connection to a WebSocket tunnel, which only happens once the
+// backend switched protocols
+func (w *accessResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *accessResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// rotatingFile is an append-only file moved aside to path.1, path.2 and so on once it
+// reaches maxSize, keeping maxBackups old files
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups up by one and starts a new file, f.mu is held
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	// When the file can't be moved aside it is reopened and keeps growing
+	os.Rename(f.path, f.path+".1")
+
+	return f.open()
+}
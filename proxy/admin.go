@@ -0,0 +1,332 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdminAPI manages the load balancer at runtime. Every endpoint needs the admin token as a
+// bearer token and every change is written to the audit log.
+type AdminAPI struct {
+	lb         *LoadBalancer
+	token      string
+	configPath string
+	audit      *auditLog
+}
+
+// BackendStatus is the live state of a backend
+type BackendStatus struct {
+	URL             string  `json:"url"`
+	Alive           bool    `json:"alive"`
+	Draining        bool    `json:"draining"`
+	Ejected         bool    `json:"ejected"`
+	Weight          int     `json:"weight"`
+	Group           string  `json:"group,omitempty"`
+	Connections     int     `json:"connections"`
+	Tunnels         int     `json:"tunnels"`
+	FailCount       int     `json:"fail_count"`
+	AvgResponseTime float64 `json:"avg_response_seconds"`
+}
+
+type backendRequest struct {
+	URL           string `json:"url"`
+	Weight        int    `json:"weight"`
+	Group         string `json:"group"`
+	ProxyProtocol string `json:"proxy_protocol"`
+}
+
+type strategyRequest struct {
+	Strategy string `json:"strategy"`
+}
+
+// NewAdminAPI creates the admin API, it is disabled when token is empty
+func NewAdminAPI(lb *LoadBalancer, token, configPath string, audit io.Writer) *AdminAPI {
+	return &AdminAPI{
+		lb:         lb,
+		token:      token,
+		configPath: configPath,
+		audit:      &auditLog{enc: json.NewEncoder(audit)},
+	}
+}
+
+// Register adds the admin endpoints to mux
+func (a *AdminAPI) Register(mux *http.ServeMux) {
+	if a.token == "" {
+		log.Printf("Admin API disabled, no admin token configured")
+	}
+
+	mux.Handle("GET /admin/backends", a.authenticated(a.listBackends))
+	mux.Handle("POST /admin/backends", a.authenticated(a.addBackend))
+	mux.Handle("DELETE /admin/backends", a.authenticated(a.removeBackend))
+	mux.Handle("POST /admin/backends/drain", a.authenticated(a.drainBackend))
+	mux.Handle("POST /admin/backends/resume", a.authenticated(a.resumeBackend))
+	mux.Handle("GET /admin/strategy", a.authenticated(a.getStrategy))
+	mux.Handle("PUT /admin/strategy", a.authenticated(a.setStrategy))
+	mux.Handle("POST /admin/reload", a.authenticated(a.reload))
+	mux.Handle("GET /admin/canary", a.authenticated(a.getCanary))
+	mux.Handle("POST /admin/canary", a.authenticated(a.startCanary))
+	mux.Handle("POST /admin/canary/promote", a.authenticated(a.promoteCanary))
+	mux.Handle("POST /admin/canary/rollback", a.authenticated(a.rollbackCanary))
+}
+
+func (a *AdminAPI) authenticated(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.token == "" {
+			http.Error(w, "Admin API is disabled", http.StatusForbidden)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			a.audit.record(r, "authenticate", r.URL.Path, fmt.Errorf("invalid admin token"))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+func (a *AdminAPI) listBackends(w http.ResponseWriter, r *http.Request) {
+	backends := a.lb.Backends()
+	statuses := make([]BackendStatus, 0, len(backends))
+
+	for _, b := range backends {
+		b.mux.RLock()
+		statuses = append(statuses, BackendStatus{
+			URL:             b.URL.String(),
+			Alive:           b.Alive,
+			Draining:        b.draining,
+			Ejected:         b.outlier.ejected(time.Now()),
+			Weight:          b.weight,
+			Group:           b.group,
+			Connections:     b.connections,
+			Tunnels:         len(b.tunnels),
+			FailCount:       b.failCount,
+			AvgResponseTime: b.latency.average,
+		})
+		b.mux.RUnlock()
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (a *AdminAPI) addBackend(w http.ResponseWriter, r *http.Request) {
+	var req backendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		http.Error(w, "Body must be a JSON object with a url", http.StatusBadRequest)
+		return
+	}
+
+	err := a.lb.AddBackend(BackendConfig{
+		URL:           req.URL,
+		Weight:        req.Weight,
+		Group:         req.Group,
+		ProxyProtocol: req.ProxyProtocol,
+	})
+	a.audit.record(r, "add_backend", req.URL, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (a *AdminAPI) removeBackend(w http.ResponseWriter, r *http.Request) {
+	backendURL := r.URL.Query().Get("url")
+
+	err := a.lb.RemoveBackend(backendURL)
+	a.audit.record(r, "remove_backend", backendURL, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AdminAPI) drainBackend(w http.ResponseWriter, r *http.Request) {
+	a.setDraining(w, r, true)
+}
+
+func (a *AdminAPI) resumeBackend(w http.ResponseWriter, r *http.Request) {
+	a.setDraining(w, r, false)
+}
+
+func (a *AdminAPI) setDraining(w http.ResponseWriter, r *http.Request, draining bool) {
+	var req backendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		http.Error(w, "Body must be a JSON object with a url", http.StatusBadRequest)
+		return
+	}
+
+	action := "resume_backend"
+	if draining {
+		action = "drain_backend"
+	}
+
+	err := a.lb.SetDraining(req.URL, draining)
+	a.audit.record(r, action, req.URL, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AdminAPI) getStrategy(w http.ResponseWriter, r *http.Request) {
+	a.lb.mux.Lock()
+	strategy := a.lb.strategy
+	a.lb.mux.Unlock()
+
+	writeJSON(w, http.StatusOK, strategyRequest{Strategy: strategy.String()})
+}
+
+func (a *AdminAPI) setStrategy(w http.ResponseWriter, r *http.Request) {
+	var req strategyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Body must be a JSON object with a strategy", http.StatusBadRequest)
+		return
+	}
+
+	strategy, err := parseStrategyString(req.Strategy)
+	if err == nil {
+		a.lb.mux.Lock()
+		a.lb.strategy = strategy
+		a.lb.mux.Unlock()
+	}
+	a.audit.record(r, "set_strategy", req.Strategy, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AdminAPI) reload(w http.ResponseWriter, r *http.Request) {
+	// Reload configuration
+	err := reloadConfiguration(a.lb, a.configPath)
+	a.audit.record(r, "reload", a.configPath, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Configuration reloaded successfully"))
+}
+
+func (a *AdminAPI) getCanary(w http.ResponseWriter, r *http.Request) {
+	status := a.lb.CanaryStatus()
+	if status == nil {
+		http.Error(w, "No canary", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (a *AdminAPI) startCanary(w http.ResponseWriter, r *http.Request) {
+	var config CanaryConfig
+
+	// Durations may be strings like "1h", as in the config file
+	var raw any
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		http.Error(w, "Body must be a JSON object with a group", http.StatusBadRequest)
+		return
+	}
+	if err := decodeNormalized(raw, &config, "canary"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := a.lb.StartCanary(config)
+	a.audit.record(r, "start_canary", config.Group, err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, a.lb.CanaryStatus())
+}
+
+func (a *AdminAPI) promoteCanary(w http.ResponseWriter, r *http.Request) {
+	err := a.lb.PromoteCanary()
+	a.audit.record(r, "promote_canary", "", err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AdminAPI) rollbackCanary(w http.ResponseWriter, r *http.Request) {
+	err := a.lb.RollbackCanary()
+	a.audit.record(r, "rollback_canary", "", err)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// auditLog writes one JSON line per admin action
+type auditLog struct {
+	enc *json.Encoder
+	mu  sync.Mutex
+}
+
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Client string    `json:"client"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Error  string    `json:"error,omitempty"`
+}
+
+func (l *auditLog) record(r *http.Request, action, target string, err error) {
+	entry := auditEntry{
+		Time:   time.Now().UTC(),
+		Client: getClientIP(r),
+		Action: action,
+		Target: target,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(entry); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
+
+// openAuditLog opens the audit log for appending, stdout when path is empty
+func openAuditLog(path string) (io.Writer, error) {
+	if path == "" {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+}
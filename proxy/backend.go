@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"log"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -24,6 +28,8 @@ const (
 	IPHash
 	Random
 	WeightedRoundRobin
+	LeastResponseTime
+	PeakEWMA
 )
 
 // Backend represents a server to forward requests to
@@ -35,6 +41,26 @@ type Backend struct {
 	failCount    int
 	weight       int
 	connections  int
+	draining     bool // Takes no new traffic while alive
+	outlier      outlierStats
+	tunnels      map[*tunnel]struct{}
+	latency      latencyStats
+	rampStart    time.Time // When it last came back, for slow start
+	group        string    // For canaries
+
+	timeout         time.Duration      // For the response headers, none when zero
+	proxyProtocol   string             // PROXY protocol version connections start with
+	healthCheck     *HealthCheckConfig // Overrides the load balancer's
+	healthStop      chan struct{}
+	healthSuccesses int
+	healthFailures  int
+}
+
+// currentWeight is the backend's weight, reloads may change it
+func (b *Backend) currentWeight() int {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.weight
 }
 
 // SetAlive updates the alive status of the backend
@@ -47,10 +73,10 @@ func (b *Backend) SetAlive(alive bool) {
 	b.mux.Unlock()
 }
 
-// IsAlive returns true if the backend is alive
+// IsAlive returns true if the backend is alive, not draining and not ejected as an outlier
 func (b *Backend) IsAlive() bool {
 	b.mux.RLock()
-	alive := b.Alive
+	alive := b.Alive && !b.draining && !b.outlier.ejected(time.Now())
 	b.mux.RUnlock()
 	return alive
 }
@@ -71,15 +97,39 @@ func (b *Backend) IncreaseFailCount() int {
 	return count
 }
 
+func newBackend(u *url.URL, weight int) *Backend {
+	return &Backend{
+		URL:          u,
+		Alive:        true, // Assume alive until health check
+		ReverseProxy: createOptimizedReverseProxy(u),
+		weight:       weight,
+	}
+}
+
 // LoadBalancer represents the load balancer
 type LoadBalancer struct {
-	backends            []*Backend
+	// backends is replaced as a whole, never changed in place, so routing reads it
+	// without waiting for a reload
+	backends            atomic.Pointer[[]*Backend]
+	backendsMu          sync.Mutex // Serializes the writers of backends
 	current             int
 	atomicCurrent       uint32
 	mux                 sync.Mutex
 	healthCheckInterval time.Duration
 	maxFailCount        int
+	healthCheckConfig   HealthCheckConfig
+	healthClient        *http.Client
+	slowStart           time.Duration
+	limits              LimitsConfig // Set once at startup
+	outlierDetection    OutlierConfig
 	strategy            Strategy
+	drainTimeout        time.Duration
+	stickyMode          StickyMode
+	stickyCookie        string
+	mirrors             []*mirror
+	mirrorClient        *http.Client
+	mirrorSlots         chan struct{} // Bounds the shadow requests in flight
+	canary              *canaryState
 	metrics             *Metrics
 }
 
@@ -118,25 +168,39 @@ func NewLoadBalancer(
 	}
 
 	lb := &LoadBalancer{
-		backends:            backends,
 		healthCheckInterval: healthCheckInterval,
 		maxFailCount:        maxFailCount,
 		strategy:            strategy,
+		stickyCookie:        defaultStickyCookie,
+		healthClient:        newHealthClient(),
+		mirrorClient:        newMirrorClient(),
+		mirrorSlots:         make(chan struct{}, maxMirrorsInFlight),
 	}
+	lb.backends.Store(&backends)
 
 	// Start health checks
-	go lb.healthCheck()
+	for _, backend := range backends {
+		lb.startHealthCheck(backend)
+	}
+	go lb.detectOutliers()
 
 	return lb
 }
 
 func (lb *LoadBalancer) chooseBackendByStrategy(r *http.Request) *Backend {
+	backends := lb.Backends()
+
 	lb.mux.Lock()
 	defer lb.mux.Unlock()
 
+	// A canary narrows the choice to its side of the split
+	if pool := lb.canaryPool(backends, rand.Float64()*100); pool != nil {
+		backends = pool
+	}
+
 	// Count alive backends
 	aliveCount := 0
-	for _, b := range lb.backends {
+	for _, b := range backends {
 		if b.IsAlive() {
 			aliveCount++
 		}
@@ -146,33 +210,50 @@ func (lb *LoadBalancer) chooseBackendByStrategy(r *http.Request) *Backend {
 		return nil
 	}
 
+	backend := lb.selectByStrategy(r, backends)
+
+	// A backend ramping up after it recovered only takes its share of the traffic
+	if backend != nil && !backend.admitSlowStart(time.Now(), lb.slowStart) {
+		if alternative := lb.slowStartAlternative(backends, backend); alternative != nil {
+			return alternative
+		}
+	}
+	return backend
+}
+
+// selectByStrategy picks an alive one of backends, lb.mux is held
+func (lb *LoadBalancer) selectByStrategy(r *http.Request, backends []*Backend) *Backend {
 	switch lb.strategy {
 	case RoundRobin:
-		return lb.fastRoundRobinSelect()
+		return lb.fastRoundRobinSelect(backends)
 	case LeastConnections:
-		return lb.leastConnectionsSelect()
+		return lb.leastConnectionsSelect(backends)
 	case IPHash:
-		return lb.ipHashSelect(r)
+		return lb.ipHashSelect(r, backends)
 	case Random:
-		return lb.randomSelect()
+		return lb.randomSelect(backends)
 	case WeightedRoundRobin:
-		return lb.weightedRoundRobinSelect()
+		return lb.weightedRoundRobinSelect(backends)
+	case LeastResponseTime:
+		return lb.leastResponseTimeSelect(backends)
+	case PeakEWMA:
+		return lb.peakEWMASelect(backends)
 	default:
-		return lb.roundRobinSelect()
+		return lb.roundRobinSelect(backends)
 	}
 }
 
 // roundRobinSelect selects a backend using round-robin algorithm
-func (lb *LoadBalancer) roundRobinSelect() *Backend {
+func (lb *LoadBalancer) roundRobinSelect(backends []*Backend) *Backend {
 	// Initial position
 	initialPosition := lb.current
 
 	// Find next alive backend
-	for i := 0; i < len(lb.backends); i++ {
-		idx := (initialPosition + i) % len(lb.backends)
-		if lb.backends[idx].IsAlive() {
+	for i := 0; i < len(backends); i++ {
+		idx := (initialPosition + i) % len(backends)
+		if backends[idx].IsAlive() {
 			lb.current = idx
-			return lb.backends[idx]
+			return backends[idx]
 		}
 	}
 
@@ -180,11 +261,11 @@ func (lb *LoadBalancer) roundRobinSelect() *Backend {
 }
 
 // leastConnectionsSelect selects the backend with the least active connections
-func (lb *LoadBalancer) leastConnectionsSelect() *Backend {
+func (lb *LoadBalancer) leastConnectionsSelect(backends []*Backend) *Backend {
 	var leastConnBackend *Backend
 	leastConn := -1
 
-	for _, b := range lb.backends {
+	for _, b := range backends {
 		if !b.IsAlive() {
 			continue
 		}
@@ -203,21 +284,21 @@ func (lb *LoadBalancer) leastConnectionsSelect() *Backend {
 }
 
 // ipHashSelect selects a backend based on client IP hash
-func (lb *LoadBalancer) ipHashSelect(r *http.Request) *Backend {
+func (lb *LoadBalancer) ipHashSelect(r *http.Request, backends []*Backend) *Backend {
 	// Extract client IP
 	ip := getClientIP(r)
 
 	// Hash the IP
 	hash := fnv.New32()
 	hash.Write([]byte(ip))
-	idx := hash.Sum32() % uint32(len(lb.backends))
+	idx := hash.Sum32() % uint32(len(backends))
 
 	// Find the selected backend or next available
 	initialIdx := idx
-	for i := 0; i < len(lb.backends); i++ {
-		checkIdx := (initialIdx + uint32(i)) % uint32(len(lb.backends))
-		if lb.backends[checkIdx].IsAlive() {
-			return lb.backends[checkIdx]
+	for i := 0; i < len(backends); i++ {
+		checkIdx := (initialIdx + uint32(i)) % uint32(len(backends))
+		if backends[checkIdx].IsAlive() {
+			return backends[checkIdx]
 		}
 	}
 
@@ -225,10 +306,10 @@ func (lb *LoadBalancer) ipHashSelect(r *http.Request) *Backend {
 }
 
 // randomSelect randomly selects an alive backend
-func (lb *LoadBalancer) randomSelect() *Backend {
+func (lb *LoadBalancer) randomSelect(backends []*Backend) *Backend {
 	// Count alive backends and get their indices
 	var aliveIndices []int
-	for i, b := range lb.backends {
+	for i, b := range backends {
 		if b.IsAlive() {
 			aliveIndices = append(aliveIndices, i)
 		}
@@ -240,16 +321,16 @@ func (lb *LoadBalancer) randomSelect() *Backend {
 
 	// Pick a random alive backend
 	randomIdx := aliveIndices[rand.Intn(len(aliveIndices))]
-	return lb.backends[randomIdx]
+	return backends[randomIdx]
 }
 
 // weightedRoundRobinSelect selects a backend based on its weight
-func (lb *LoadBalancer) weightedRoundRobinSelect() *Backend {
+func (lb *LoadBalancer) weightedRoundRobinSelect(backends []*Backend) *Backend {
 	// Count total weight of alive backends
 	totalWeight := 0
-	for _, b := range lb.backends {
+	for _, b := range backends {
 		if b.IsAlive() {
-			totalWeight += b.weight
+			totalWeight += b.currentWeight()
 		}
 	}
 
@@ -262,58 +343,211 @@ func (lb *LoadBalancer) weightedRoundRobinSelect() *Backend {
 	currentWeight := 0
 
 	// Find the backend that contains this weight point
-	for _, b := range lb.backends {
+	for _, b := range backends {
 		if !b.IsAlive() {
 			continue
 		}
 
-		currentWeight += b.weight
+		currentWeight += b.currentWeight()
 		if targetWeight < currentWeight {
 			return b
 		}
 	}
 
 	// Fallback - should not reach here
-	return lb.roundRobinSelect()
+	return lb.roundRobinSelect(backends)
 }
 
-// getClientIP extracts the client IP from a request
-func getClientIP(r *http.Request) string {
-	// Check for X-Forwarded-For header first
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		// X-Forwarded-For can contain multiple IPs, use the first one
-		ips := strings.Split(xForwardedFor, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+func (lb *LoadBalancer) fastRoundRobinSelect(backends []*Backend) *Backend {
+	numBackends := len(backends)
+	initialIndex := int(atomic.LoadUint32(&lb.atomicCurrent)) % numBackends
+
+	for i := range numBackends {
+		idx := (initialIndex + i) % numBackends
+		if backends[idx].IsAlive() {
+			atomic.StoreUint32(&lb.atomicCurrent, uint32(idx+1))
+			return backends[idx]
 		}
 	}
 
-	// Otherwise use RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+	return nil
+}
+
+// SetBackends replaces the backends, the ones already known by URL are kept along with their
+// health and connections. Removed backends get no new traffic and their tunnels are drained.
+func (lb *LoadBalancer) SetBackends(configs []BackendConfig) error {
+	urls := make([]*url.URL, len(configs))
+	for i, config := range configs {
+		parsedURL, err := url.Parse(config.URL)
+		if err != nil {
+			return fmt.Errorf("invalid backend URL %s: %w", config.URL, err)
+		}
+		if parsedURL.Host == "" {
+			return fmt.Errorf("invalid backend URL %s", config.URL)
+		}
+		urls[i] = parsedURL
+
+		if config.HealthCheck != nil {
+			if err := config.HealthCheck.validate(); err != nil {
+				return fmt.Errorf("backend %s: %w", config.URL, err)
+			}
+		}
+		if !validProxyProtocol(config.ProxyProtocol) {
+			return fmt.Errorf("backend %s: unknown PROXY protocol version %s", config.URL, config.ProxyProtocol)
+		}
 	}
-	return ip
+
+	lb.backendsMu.Lock()
+	defer lb.backendsMu.Unlock()
+
+	oldBackends := lb.Backends()
+	backends := make([]*Backend, len(configs))
+
+	for i, config := range configs {
+		weight := config.Weight
+		if weight <= 0 {
+			weight = 1 // Default weight
+		}
+
+		for _, oldBackend := range oldBackends {
+			if oldBackend.URL.String() == urls[i].String() {
+				// Keep the existing backend but update its weight
+				oldBackend.mux.Lock()
+				oldBackend.weight = weight
+				oldBackend.timeout = config.Timeout
+				oldBackend.healthCheck = config.HealthCheck
+				oldBackend.group = config.Group
+				oldBackend.setProxyProtocol(config.ProxyProtocol)
+				oldBackend.mux.Unlock()
+				backends[i] = oldBackend
+				break
+			}
+		}
+
+		if backends[i] == nil {
+			backends[i] = newBackend(urls[i], weight)
+			backends[i].timeout = config.Timeout
+			backends[i].healthCheck = config.HealthCheck
+			backends[i].group = config.Group
+			backends[i].proxyProtocol = config.ProxyProtocol
+			lb.startHealthCheck(backends[i])
+		}
+	}
+	lb.backends.Store(&backends)
+
+	drainTimeout := lb.drainTimeoutOrDefault()
+	for _, oldBackend := range oldBackends {
+		if !slices.Contains(backends, oldBackend) {
+			oldBackend.stopHealthCheck()
+			go oldBackend.drain(drainTimeout)
+		}
+	}
+
+	return nil
 }
 
-func (lb *LoadBalancer) fastRoundRobinSelect() *Backend {
-	numBackends := len(lb.backends)
-	initialIndex := int(atomic.LoadUint32(&lb.atomicCurrent)) % numBackends
+// AddBackend adds a backend unless one with the same URL exists
+func (lb *LoadBalancer) AddBackend(config BackendConfig) error {
+	parsedURL, err := url.Parse(config.URL)
+	if err != nil || parsedURL.Host == "" {
+		return fmt.Errorf("invalid backend URL %s", config.URL)
+	}
 
-	for i := range numBackends {
-		idx := (initialIndex + i) % numBackends
-		if lb.backends[idx].IsAlive() {
-			atomic.StoreUint32(&lb.atomicCurrent, uint32(idx+1))
-			return lb.backends[idx]
+	weight := config.Weight
+	if weight <= 0 {
+		weight = 1 // Default weight
+	}
+
+	lb.backendsMu.Lock()
+	defer lb.backendsMu.Unlock()
+
+	current := lb.Backends()
+	for _, b := range current {
+		if b.URL.String() == parsedURL.String() {
+			return fmt.Errorf("backend %s already exists", config.URL)
+		}
+	}
+
+	if config.HealthCheck != nil {
+		if err := config.HealthCheck.validate(); err != nil {
+			return err
+		}
+	}
+	if !validProxyProtocol(config.ProxyProtocol) {
+		return fmt.Errorf("unknown PROXY protocol version %s", config.ProxyProtocol)
+	}
+
+	backend := newBackend(parsedURL, weight)
+	backend.timeout = config.Timeout
+	backend.healthCheck = config.HealthCheck
+	backend.group = config.Group
+	backend.proxyProtocol = config.ProxyProtocol
+	lb.startHealthCheck(backend)
+
+	backends := append(slices.Clone(current), backend)
+	lb.backends.Store(&backends)
+	return nil
+}
+
+// RemoveBackend stops sending traffic to a backend and drains its tunnels
+func (lb *LoadBalancer) RemoveBackend(backendURL string) error {
+	lb.backendsMu.Lock()
+	defer lb.backendsMu.Unlock()
+
+	current := lb.Backends()
+	idx := slices.IndexFunc(current, func(b *Backend) bool {
+		return b.URL.String() == backendURL
+	})
+	if idx < 0 {
+		return fmt.Errorf("backend %s does not exist", backendURL)
+	}
+
+	removed := current[idx]
+	backends := slices.Delete(slices.Clone(current), idx, idx+1)
+	lb.backends.Store(&backends)
+	removed.stopHealthCheck()
+	go removed.drain(lb.drainTimeoutOrDefault())
+
+	return nil
+}
+
+// SetDraining stops or resumes new traffic to a backend, requests and tunnels already on
+// it are left to finish
+func (lb *LoadBalancer) SetDraining(backendURL string, draining bool) error {
+	for _, b := range lb.Backends() {
+		if b.URL.String() == backendURL {
+			b.mux.Lock()
+			b.draining = draining
+			b.mux.Unlock()
+			return nil
 		}
 	}
+	return fmt.Errorf("backend %s does not exist", backendURL)
+}
+
+// drainTimeoutOrDefault is how long removed backends keep their tunnels
+func (lb *LoadBalancer) drainTimeoutOrDefault() time.Duration {
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+
+	if lb.drainTimeout <= 0 {
+		return defaultDrainTimeout
+	}
+	return lb.drainTimeout
+}
 
+// Backends returns the current backends. The slice is never modified, a change replaces it.
+func (lb *LoadBalancer) Backends() []*Backend {
+	if backends := lb.backends.Load(); backends != nil {
+		return *backends
+	}
 	return nil
 }
 
 // NextBackend returns the next available backend using round-robin selection
 func (lb *LoadBalancer) NextBackend() *Backend {
+	backends := lb.Backends()
+
 	lb.mux.Lock()
 	defer lb.mux.Unlock()
 
@@ -321,11 +555,11 @@ func (lb *LoadBalancer) NextBackend() *Backend {
 	initialIndex := lb.current
 
 	// Try to find a healthy backend
-	for i := 0; i < len(lb.backends); i++ {
-		idx := (initialIndex + i) % len(lb.backends)
-		if lb.backends[idx].IsAlive() {
+	for i := 0; i < len(backends); i++ {
+		idx := (initialIndex + i) % len(backends)
+		if backends[idx].IsAlive() {
 			lb.current = idx
-			return lb.backends[idx]
+			return backends[idx]
 		}
 	}
 
@@ -333,75 +567,6 @@ func (lb *LoadBalancer) NextBackend() *Backend {
 	return nil
 }
 
-// healthCheck performs health checks on all backends
-func (lb *LoadBalancer) healthCheck() {
-	// Create a transport with connection pooling
-	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   2 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   2 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   3 * time.Second,
-	}
-
-	ticker := time.NewTicker(lb.healthCheckInterval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		// Use a worker pool to check health in parallel
-		results := make(chan struct {
-			index int
-			alive bool
-		}, len(lb.backends))
-
-		// Launch goroutines for each backend
-		for i, backend := range lb.backends {
-			go func(i int, backend *Backend) {
-				alive := isBackendAliveHTTP(backend.URL, client)
-				results <- struct {
-					index int
-					alive bool
-				}{i, alive}
-			}(i, backend)
-		}
-
-		// Collect results
-		for i := 0; i < len(lb.backends); i++ {
-			result := <-results
-			backend := lb.backends[result.index]
-			backend.SetAlive(result.alive)
-
-			// Update metrics
-			backendLabel := backend.URL.Host
-			if result.alive {
-				lb.metrics.backendUpGauge.WithLabelValues(backendLabel).Set(1)
-			} else {
-				lb.metrics.backendUpGauge.WithLabelValues(backendLabel).Set(0)
-				lb.metrics.backendErrors.WithLabelValues(backendLabel, "health_check").Inc()
-			}
-		}
-	}
-}
-
-// isBackendAliveHTTP checks if a backend is alive by making an HTTP request
-func isBackendAliveHTTP(u *url.URL, client *http.Client) bool {
-	resp, err := client.Get(u.String() + "/health") // Assuming backends have a /health endpoint
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-	return resp.StatusCode < 500 // Consider any non-5xx response as alive
-}
-
 type bufferPoolAdapter struct {
 	pool *sync.Pool
 }
@@ -434,6 +599,19 @@ func createOptimizedReverseProxy(target *url.URL) *httputil.ReverseProxy {
 			req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
 		}
 
+		req.Header.Set("X-Forwarded-Proto", forwardedProto(req))
+		// The reverse proxy appends the peer, a chain from anyone else is dropped
+		if !peerTrusted(req) {
+			req.Header.Del("X-Forwarded-For")
+		}
+		req.Header.Set("X-Real-IP", getClientIP(req))
+		injectTrace(req)
+
+		// The connection was opened for this client
+		if wantsProxyHeader(req.Context()) {
+			req.Close = true
+		}
+
 		if _, ok := req.Header["User-Agent"]; !ok {
 			req.Header.Set("User-Agent", "")
 		}
@@ -442,10 +620,10 @@ func createOptimizedReverseProxy(target *url.URL) *httputil.ReverseProxy {
 	// Create a transport with optimized connection pooling
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
+		DialContext: proxyProtocolDialer(&net.Dialer{
 			Timeout:   10 * time.Second,
 			KeepAlive: 30 * time.Second,
-		}).DialContext,
+		}),
 		MaxIdleConns:          100,
 		MaxIdleConnsPerHost:   100, // Important for load balancers
 		IdleConnTimeout:       90 * time.Second,
@@ -457,19 +635,72 @@ func createOptimizedReverseProxy(target *url.URL) *httputil.ReverseProxy {
 
 	proxy := &httputil.ReverseProxy{
 		Director:  director,
-		Transport: transport,
+		Transport: &timeoutTransport{base: transport},
 		// BufferPool: bufferPool,
+		ErrorHandler: proxyErrorHandler,
 	}
 
-	//  Add WebSocket upgrade handling
-	proxy.ModifyResponse = func(r *http.Response) error {
-		// Allow WebSocket upgrade responses to pass through
-		return nil
-	}
+	//  Buffer responses when limits ask for it, WebSocket upgrades pass through
+	proxy.ModifyResponse = modifyResponse
 
 	return proxy
 }
 
+type backendTimeoutKey struct{}
+
+var errBackendTimeout = errors.New("backend timed out")
+
+// timeoutTransport gives up on a backend that doesn't send its response headers within
+// the timeout in the request's context. The body may take longer, it can be a stream.
+type timeoutTransport struct {
+	base http.RoundTripper
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timeout, _ := req.Context().Value(backendTimeoutKey{}).(time.Duration)
+	if timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(timeout, cancel)
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		// Fired, the response is gone with the canceled context even if it made it
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("%w after %s", errBackendTimeout, timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *timeoutTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// cancelOnClose releases the request's context once the body is done with
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 func isWebSocketRequest(r *http.Request) bool {
 	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket" &&
 		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
@@ -477,13 +708,27 @@ func isWebSocketRequest(r *http.Request) bool {
 
 // ServeHTTP implements the http.Handler interface
 func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	backend := lb.chooseBackendByStrategy(r)
+	var backend *Backend
+	if isStickyRequest(r) {
+		backend = lb.chooseStickyBackend(w, r)
+	} else {
+		backend = lb.chooseBackendByStrategy(r)
+	}
 	if backend == nil {
 		http.Error(w, "No available backends", http.StatusServiceUnavailable)
 		lb.metrics.requestCount.WithLabelValues("none", "503", r.Method).Inc()
 		return
 	}
 
+	recordBackend(r, backend)
+
+	lb.mux.Lock()
+	strategy := lb.strategy
+	lb.mux.Unlock()
+
+	r, span := startForwardSpan(r, backend, strategy.String())
+	defer span.End()
+
 	// Track request start time
 	start := time.Now()
 
@@ -499,12 +744,18 @@ func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if isWebSocketRequest(r) {
 		log.Printf("WebSocket upgrade request to: %s", backend.URL.Host)
 
-		// For WebSocket, we need to proxy the raw connection
-		// Just use the reverse proxy - it will handle the upgrade
-		backend.ReverseProxy.ServeHTTP(w, r)
+		// Blocks for the lifetime of the socket, so it stays out of the duration histograms
+		statusCode := lb.proxyWebSocket(w, r, backend)
+		backend.recordOutcome(statusCode < http.StatusInternalServerError)
+		lb.recordCanaryOutcome(backend, statusCode < http.StatusInternalServerError)
+		endSpan(span, statusCode)
 
-		// Don't track metrics the same way for long-lived connections
-		// The connection counter will be decremented when the WS closes
+		backend.mux.Lock()
+		backend.connections--
+		backend.mux.Unlock()
+
+		lb.metrics.activeConnections.WithLabelValues(backendLabel).Dec()
+		lb.metrics.requestCount.WithLabelValues(backendLabel, fmt.Sprintf("%d", statusCode), r.Method).Inc()
 		return
 	}
 
@@ -514,6 +765,22 @@ func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		statusCode:     http.StatusOK,
 	}
 
+	if lb.limits.BufferResponses {
+		r = bufferResponses(r, lb.limits.MaxBufferedBytes)
+	}
+
+	backend.mux.RLock()
+	timeout, proxyProtocol := backend.timeout, backend.proxyProtocol
+	backend.mux.RUnlock()
+	if timeout > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), backendTimeoutKey{}, timeout))
+	}
+	if proxyProtocol != "" {
+		r = r.WithContext(withProxyHeader(r.Context(), proxyHeaderFor(r, proxyProtocol)))
+	}
+
+	lb.mirror(r)
+
 	// Forward the request to the backend
 	log.Printf("Forwarding request to: %s", backend.URL.Host)
 	backend.ReverseProxy.ServeHTTP(wrappedWriter, r)
@@ -532,8 +799,13 @@ func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Update request metrics
 	statusCode := fmt.Sprintf("%d", wrappedWriter.statusCode)
 	lb.metrics.requestCount.WithLabelValues(backendLabel, statusCode, r.Method).Inc()
-	lb.metrics.requestDuration.WithLabelValues(backendLabel).Observe(duration)
-	lb.metrics.backendResponseTime.WithLabelValues(backendLabel).Observe(duration)
+	observeWithTrace(lb.metrics.requestDuration.WithLabelValues(backendLabel), r.Context(), duration)
+	observeWithTrace(lb.metrics.backendResponseTime.WithLabelValues(backendLabel), r.Context(), duration)
+	endSpan(span, wrappedWriter.statusCode)
+	backend.ObserveResponseTime(duration)
+
+	backend.recordOutcome(wrappedWriter.statusCode < http.StatusInternalServerError)
+	lb.recordCanaryOutcome(backend, wrappedWriter.statusCode < http.StatusInternalServerError)
 
 	// Reset fail count on successful request
 	if wrappedWriter.statusCode < http.StatusInternalServerError {
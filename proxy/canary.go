@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"time"
+)
+
+// CanaryConfig sends a growing share of the traffic to the backends of a group, ramping
+// from StartPercent to EndPercent over Duration. When the group's error rate in a window
+// passes MaxErrorRate it is rolled back and gets no traffic at all.
+type CanaryConfig struct {
+	Group        string        `json:"group"`
+	StartPercent float64       `json:"start_percent"`
+	EndPercent   float64       `json:"end_percent"`
+	Duration     time.Duration `json:"duration"`
+	MaxErrorRate float64       `json:"max_error_rate"`
+	MinRequests  int           `json:"min_requests"` // In a window before the error rate counts
+	Window       time.Duration `json:"window"`
+}
+
+const (
+	CanaryRunning    = "running"
+	CanaryPromoted   = "promoted"
+	CanaryRolledBack = "rolled_back"
+
+	defaultCanaryMaxErrorRate = 0.05
+	defaultCanaryMinRequests  = 20
+	defaultCanaryWindow       = time.Minute
+)
+
+// CanaryStatus is the state of the current canary
+type CanaryStatus struct {
+	Group     string    `json:"group"`
+	Status    string    `json:"status"`
+	Percent   float64   `json:"percent"`
+	StartedAt time.Time `json:"started_at"`
+	Requests  int       `json:"window_requests"`
+	Errors    int       `json:"window_errors"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// canaryState is guarded by lb.mux
+type canaryState struct {
+	config      CanaryConfig
+	status      string
+	reason      string
+	started     time.Time
+	windowStart time.Time
+	requests    int
+	errors      int
+}
+
+func (c CanaryConfig) withDefaults() CanaryConfig {
+	if c.EndPercent == 0 {
+		c.EndPercent = 100
+	}
+	if c.MaxErrorRate <= 0 {
+		c.MaxErrorRate = defaultCanaryMaxErrorRate
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultCanaryMinRequests
+	}
+	if c.Window <= 0 {
+		c.Window = defaultCanaryWindow
+	}
+	return c
+}
+
+func (c CanaryConfig) validate() error {
+	if c.Group == "" {
+		return fmt.Errorf("a canary needs a backend group")
+	}
+	if c.StartPercent < 0 || c.EndPercent > 100 || c.StartPercent > c.EndPercent {
+		return fmt.Errorf("percentages must satisfy 0 <= start_percent <= end_percent <= 100")
+	}
+	if c.Duration < 0 {
+		return fmt.Errorf("duration must not be negative")
+	}
+	if c.MaxErrorRate > 1 {
+		return fmt.Errorf("max_error_rate must be at most 1")
+	}
+	return nil
+}
+
+// percent is the share of the traffic the group gets at now
+func (c *canaryState) percent(now time.Time) float64 {
+	switch c.status {
+	case CanaryPromoted:
+		return 100
+	case CanaryRunning:
+		if c.config.Duration <= 0 {
+			return c.config.EndPercent
+		}
+		progress := min(float64(now.Sub(c.started))/float64(c.config.Duration), 1)
+		return c.config.StartPercent + (c.config.EndPercent-c.config.StartPercent)*progress
+	default:
+		return 0
+	}
+}
+
+// StartCanary starts ramping traffic to a backend group, replacing any earlier canary
+func (lb *LoadBalancer) StartCanary(config CanaryConfig) error {
+	config = config.withDefaults()
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+
+	found := false
+	for _, b := range lb.Backends() {
+		if b.groupName() == config.Group {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("no backends in group %s", config.Group)
+	}
+
+	now := time.Now()
+	lb.canary = &canaryState{
+		config:      config,
+		status:      CanaryRunning,
+		started:     now,
+		windowStart: now,
+	}
+	log.Printf("Canary for group %s started at %.1f%%", config.Group, config.StartPercent)
+	return nil
+}
+
+// PromoteCanary sends all traffic to the canary group
+func (lb *LoadBalancer) PromoteCanary() error {
+	return lb.finishCanary(CanaryPromoted, "promoted through the admin API")
+}
+
+// RollbackCanary takes all traffic off the canary group
+func (lb *LoadBalancer) RollbackCanary() error {
+	return lb.finishCanary(CanaryRolledBack, "rolled back through the admin API")
+}
+
+func (lb *LoadBalancer) finishCanary(status, reason string) error {
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+
+	if lb.canary == nil {
+		return fmt.Errorf("no canary")
+	}
+
+	lb.canary.status = status
+	lb.canary.reason = reason
+	log.Printf("Canary for group %s %s", lb.canary.config.Group, reason)
+	return nil
+}
+
+// CanaryStatus returns the current canary, nil when none was started
+func (lb *LoadBalancer) CanaryStatus() *CanaryStatus {
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+
+	c := lb.canary
+	if c == nil {
+		return nil
+	}
+
+	return &CanaryStatus{
+		Group:     c.config.Group,
+		Status:    c.status,
+		Percent:   c.percent(time.Now()),
+		StartedAt: c.started,
+		Requests:  c.requests,
+		Errors:    c.errors,
+		Reason:    c.reason,
+	}
+}
+
+// canaryPool is the part of backends a request may go to, nil when every backend
+// may take it. A roll in [0, 100) below the canary's percent goes to its group, grouped
+// backends get no other traffic. The other side takes over when one has no alive
+// backends. lb.mux is held.
+func (lb *LoadBalancer) canaryPool(backends []*Backend, roll float64) []*Backend {
+	grouped := false
+	for _, b := range backends {
+		if b.groupName() != "" {
+			grouped = true
+			break
+		}
+	}
+	if !grouped {
+		return nil
+	}
+
+	group := ""
+	percent := 0.0
+	if lb.canary != nil {
+		group = lb.canary.config.Group
+		percent = lb.canary.percent(time.Now())
+	}
+
+	var canary, stable []*Backend
+	for _, b := range backends {
+		switch b.groupName() {
+		case "":
+			stable = append(stable, b)
+		case group:
+			canary = append(canary, b)
+		}
+	}
+
+	primary, fallback := stable, canary
+	if roll < percent {
+		primary, fallback = canary, stable
+	}
+	// Rolled back or promoted, one side gets nothing even when the other is down
+	if percent <= 0 || percent >= 100 {
+		fallback = nil
+	}
+
+	for _, b := range primary {
+		if b.IsAlive() {
+			return primary
+		}
+	}
+	return fallback
+}
+
+// canaryAllows reports whether a request may still be sent to b, for sticky clients
+// pinned to a backend that no longer gets traffic
+func (lb *LoadBalancer) canaryAllows(b *Backend) bool {
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+
+	group := b.groupName()
+	if group == "" {
+		return lb.canary == nil || lb.canary.status != CanaryPromoted
+	}
+	return lb.canary != nil && lb.canary.config.Group == group && lb.canary.percent(time.Now()) > 0
+}
+
+// recordCanaryOutcome counts a response of a canary backend and rolls the canary back
+// once its error rate in the window passes the limit
+func (lb *LoadBalancer) recordCanaryOutcome(b *Backend, ok bool) {
+	lb.mux.Lock()
+	defer lb.mux.Unlock()
+
+	c := lb.canary
+	if c == nil || c.status != CanaryRunning || b.groupName() != c.config.Group {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(c.windowStart) >= c.config.Window {
+		c.windowStart = now
+		c.requests, c.errors = 0, 0
+	}
+
+	c.requests++
+	if !ok {
+		c.errors++
+	}
+
+	if c.requests >= c.config.MinRequests {
+		rate := float64(c.errors) / float64(c.requests)
+		if rate > c.config.MaxErrorRate {
+			c.status = CanaryRolledBack
+			c.reason = fmt.Sprintf("error rate %.1f%% passed %.1f%%", rate*100, c.config.MaxErrorRate*100)
+			log.Printf("Canary for group %s rolled back, %s", c.config.Group, c.reason)
+		}
+	}
+}
+
+// clientRoll places a client at a fixed point of the split, so with IP hash stickiness a
+// client stays on its side while the canary ramps up
+func clientRoll(r *http.Request) float64 {
+	hash := fnv.New32a()
+	hash.Write([]byte(getClientIP(r)))
+	return float64(hash.Sum32()%10000) / 100
+}
+
+func (b *Backend) groupName() string {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.group
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ClientIPConfig decides who the client behind a request is. Only trusted proxies may
+// name it in X-Forwarded-For or X-Real-IP, or in a PROXY protocol header when accepting
+// those is on. Without trusted proxies, a PROXY protocol header is expected from everyone.
+type ClientIPConfig struct {
+	TrustedProxies       []string      `json:"trusted_proxies"`
+	AcceptProxyProtocol  bool          `json:"accept_proxy_protocol"`
+	ProxyProtocolTimeout time.Duration `json:"proxy_protocol_timeout"` // To read the header in
+}
+
+// ClientIPResolver finds the client address of requests
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+type clientIPKey struct{}
+
+// clientInfo is what ClientIPMiddleware found out about a request's client
+type clientInfo struct {
+	addr        netip.Addr
+	peerTrusted bool
+}
+
+// NewClientIPResolver creates the resolver for config
+func NewClientIPResolver(config ClientIPConfig) (*ClientIPResolver, error) {
+	trusted, err := parsePrefixes(config.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	if config.ProxyProtocolTimeout < 0 {
+		return nil, fmt.Errorf("proxy_protocol_timeout must not be negative")
+	}
+	return &ClientIPResolver{trusted: trusted}, nil
+}
+
+// resolve follows X-Forwarded-For from the right through trusted proxies only, so a client
+// can't pick its own address. A trusted peer without one may name the client in X-Real-IP.
+func (c *ClientIPResolver) resolve(r *http.Request) (clientInfo, bool) {
+	addr, ok := peerAddr(r)
+	if !ok || !containsAddr(c.trusted, addr) {
+		return clientInfo{addr: addr}, ok
+	}
+	info := clientInfo{addr: addr, peerTrusted: true}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			info.addr = realIP.Unmap()
+		}
+		return info, true
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		info.addr = hop.Unmap()
+		if !containsAddr(c.trusted, info.addr) {
+			break
+		}
+	}
+	return info, true
+}
+
+// ClientIPMiddleware works out the client of every request once, for the rate limits,
+// logs, traces and the headers passed on to the backends
+func ClientIPMiddleware(c *ClientIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if info, ok := c.resolve(r); ok {
+				r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, info))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// peerAddr is the address of the connection's other end, the sender of a PROXY protocol
+// header names it when one was accepted
+func peerAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// clientAddr is the client behind r, the peer when ClientIPMiddleware didn't see r
+func clientAddr(r *http.Request) (netip.Addr, bool) {
+	if info, ok := r.Context().Value(clientIPKey{}).(clientInfo); ok {
+		return info.addr, true
+	}
+	return peerAddr(r)
+}
+
+// getClientIP is clientAddr as a string, RemoteAddr when it can't be parsed
+func getClientIP(r *http.Request) string {
+	if addr, ok := clientAddr(r); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+// peerTrusted reports whether the forwarding headers r came with can be passed on
+func peerTrusted(r *http.Request) bool {
+	info, _ := r.Context().Value(clientIPKey{}).(clientInfo)
+	return info.peerTrusted
+}
+
+// forwardedFor is the X-Forwarded-For a backend gets for r, the chain r came with when a
+// trusted proxy sent it and then the peer
+func forwardedFor(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if prior := strings.Join(r.Header.Values("X-Forwarded-For"), ", "); prior != "" && peerTrusted(r) {
+		return prior + ", " + host
+	}
+	return host
+}
+
+// setClientHeaders sets the client headers of out, a request to a backend made for r
+func setClientHeaders(out, r *http.Request) {
+	out.Header.Set("X-Forwarded-For", forwardedFor(r))
+	out.Header.Set("X-Real-IP", getClientIP(r))
+}
@@ -1,23 +1,304 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	defaultListenAddr   = ":5004"
+	defaultStrategy     = "round_robin"
+	defaultMaxFailCount = 3
 )
 
 // Config represents the load balancer configuration
 type Config struct {
-	ListenAddr          string          `json:"listen_addr"`
-	HealthCheckInterval time.Duration   `json:"health_check_interval"`
-	MaxFailCount        int             `json:"max_fail_count"`
-	Strategy            string          `json:"strategy"`
-	Backends            []BackendConfig `json:"backends"`
+	ListenAddr          string            `json:"listen_addr"`
+	HealthCheckInterval time.Duration     `json:"health_check_interval"`
+	MaxFailCount        int               `json:"max_fail_count"`
+	HealthCheck         HealthCheckConfig `json:"health_check"`
+	SlowStart           time.Duration     `json:"slow_start"`
+	OutlierDetection    OutlierConfig     `json:"outlier_detection"`
+	Limits              LimitsConfig      `json:"limits"`
+	RateLimit           RateLimitConfig   `json:"rate_limit"`
+	ClientIP            ClientIPConfig    `json:"client_ip"`
+	Strategy            string            `json:"strategy"`
+	DrainTimeout        time.Duration     `json:"drain_timeout"`
+	Backends            []BackendConfig   `json:"backends"`
+	Mirrors             []MirrorConfig    `json:"mirrors"`
+	StickySessions      StickyConfig      `json:"sticky_sessions"`
+	TLS                 TLSConfig         `json:"tls"`
+	Discovery           DiscoveryConfig   `json:"discovery"`
+	AdminToken          string            `json:"admin_token"`
+	AuditLogPath        string            `json:"audit_log_path"`
+	AccessLog           AccessLogConfig   `json:"access_log"`
+	Tracing             TracingConfig     `json:"tracing"`
 }
 
 // BackendConfig represents a backend server configuration
 type BackendConfig struct {
-	URL    string `json:"url"`
-	Weight int    `json:"weight"`
+	URL         string             `json:"url"`
+	Weight      int                `json:"weight"`
+	Timeout     time.Duration      `json:"timeout"` // For the response headers, none when zero
+	HealthCheck *HealthCheckConfig `json:"health_check,omitempty"`
+	Group       string             `json:"group"` // Canary group, takes traffic only through a canary
+
+	// ProxyProtocol is the PROXY protocol version, v1 or v2, connections to the backend start
+	// with. Such connections carry one client each and aren't reused.
+	ProxyProtocol string `json:"proxy_protocol"`
+}
+
+// loadConfig reads a JSON or, by its extension, YAML config file and fills in the
+// defaults. Durations may be written as numbers of nanoseconds or as strings like "30s",
+// unknown keys are an error so a typo doesn't silently fall back to a default.
+func loadConfig(path string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var raw any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	}
+	if err != nil {
+		return config, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	// Both formats end up decoded through the JSON tags
+	if err := decodeNormalized(raw, &config, "config"); err != nil {
+		return config, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	config.applyDefaults()
+	return config, nil
+}
+
+// decodeNormalized decodes the generic value raw into v, a pointer, with its durations
+// normalized and unknown keys rejected
+func decodeNormalized(raw any, v any, path string) error {
+	raw, err := normalizeDurations(raw, reflect.TypeOf(v), path)
+	if err != nil {
+		return err
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(normalized))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// normalizeDurations turns duration strings into nanoseconds wherever t, the type v is
+// decoded into, has a time.Duration. Anything else is left for the decoder to check.
+func normalizeDurations(v any, t reflect.Type, path string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == durationType:
+		if s, ok := v.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return int64(d), nil
+		}
+	case t.Kind() == reflect.Struct:
+		fields, ok := v.(map[string]any)
+		if !ok {
+			return v, nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous {
+				if _, err := normalizeDurations(fields, field.Type, path); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			value, ok := fields[name]
+			if name == "" || name == "-" || !ok {
+				continue
+			}
+
+			normalized, err := normalizeDurations(value, field.Type, path+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = normalized
+		}
+	case t.Kind() == reflect.Slice:
+		items, ok := v.([]any)
+		if !ok {
+			return v, nil
+		}
+		for i, item := range items {
+			normalized, err := normalizeDurations(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = normalized
+		}
+	}
+	return v, nil
+}
+
+// applyDefaults fills in what the config leaves out
+func (c *Config) applyDefaults() {
+	if c.ListenAddr == "" {
+		c.ListenAddr = defaultListenAddr
+	}
+	if c.Strategy == "" {
+		c.Strategy = defaultStrategy
+	}
+	if c.HealthCheckInterval <= 0 {
+		c.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if c.MaxFailCount <= 0 {
+		c.MaxFailCount = defaultMaxFailCount
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = defaultDrainTimeout
+	}
+	if c.StickySessions.CookieName == "" {
+		c.StickySessions.CookieName = defaultStickyCookie
+	}
+	for i := range c.Backends {
+		if c.Backends[i].Weight == 0 {
+			c.Backends[i].Weight = 1
+		}
+	}
+}
+
+// validate checks the whole config and reports every problem it finds at once
+func (c Config) validate() error {
+	var errs []error
+
+	if _, err := parseStrategyString(c.Strategy); err != nil {
+		errs = append(errs, fmt.Errorf("strategy: %w", err))
+	}
+	if _, err := parseStickyMode(c.StickySessions.Mode); err != nil {
+		errs = append(errs, fmt.Errorf("sticky_sessions: %w", err))
+	}
+	if err := c.HealthCheck.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("health_check: %w", err))
+	}
+
+	if c.Discovery.Provider != "" {
+		if _, err := NewDiscoverer(c.Discovery); err != nil {
+			errs = append(errs, fmt.Errorf("discovery: %w", err))
+		}
+	} else if len(c.Backends) == 0 {
+		errs = append(errs, fmt.Errorf("backends: at least one backend is required without discovery"))
+	}
+
+	seen := make(map[string]bool)
+	for i, backend := range c.Backends {
+		u, err := url.Parse(backend.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("backends[%d]: invalid url %q", i, backend.URL))
+		} else if seen[u.String()] {
+			errs = append(errs, fmt.Errorf("backends[%d]: duplicate url %q", i, backend.URL))
+		} else {
+			seen[u.String()] = true
+		}
+
+		if backend.Weight < 0 {
+			errs = append(errs, fmt.Errorf("backends[%d]: weight must not be negative", i))
+		}
+		if backend.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("backends[%d]: timeout must not be negative", i))
+		}
+		if !validProxyProtocol(backend.ProxyProtocol) {
+			errs = append(errs, fmt.Errorf("backends[%d]: unknown proxy_protocol: %s", i, backend.ProxyProtocol))
+		}
+		if backend.HealthCheck != nil {
+			if err := backend.HealthCheck.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("backends[%d].health_check: %w", i, err))
+			}
+		}
+	}
+
+	for i, mirror := range c.Mirrors {
+		if err := mirror.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("mirrors[%d]: %w", i, err))
+		}
+	}
+
+	if c.OutlierDetection.ErrorRate < 0 || c.OutlierDetection.ErrorRate > 1 {
+		errs = append(errs, fmt.Errorf("outlier_detection: error_rate must be between 0 and 1"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing: sample_ratio must be between 0 and 1"))
+	}
+
+	switch c.AccessLog.Format {
+	case "", "json", "combined", "off":
+	default:
+		errs = append(errs, fmt.Errorf("access_log: unknown format: %s", c.AccessLog.Format))
+	}
+
+	if _, err := NewRateLimiter(c.RateLimit, nil); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit: %w", err))
+	}
+	if _, err := NewClientIPResolver(c.ClientIP); err != nil {
+		errs = append(errs, fmt.Errorf("client_ip: %w", err))
+	}
+
+	if c.TLS.Enabled() {
+		if _, _, err := buildTLSConfig(c.TLS); err != nil {
+			errs = append(errs, fmt.Errorf("tls: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// String returns the strategy's name in the configuration
+func (s Strategy) String() string {
+	switch s {
+	case RoundRobin:
+		return "round_robin"
+	case LeastConnections:
+		return "least_connections"
+	case IPHash:
+		return "ip_hash"
+	case Random:
+		return "random"
+	case WeightedRoundRobin:
+		return "weighted_round_robin"
+	case LeastResponseTime:
+		return "least_response_time"
+	case PeakEWMA:
+		return "peak_ewma"
+	default:
+		return fmt.Sprintf("strategy(%d)", int(s))
+	}
 }
 
 // parseStrategyString converts a strategy string to a Strategy enum
@@ -33,6 +314,10 @@ func parseStrategyString(s string) (Strategy, error) {
 		return Random, nil
 	case "weighted_round_robin":
 		return WeightedRoundRobin, nil
+	case "least_response_time":
+		return LeastResponseTime, nil
+	case "peak_ewma":
+		return PeakEWMA, nil
 	default:
 		return 0, fmt.Errorf("unknown strategy: %s", s)
 	}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDelay lets an editor finish writing before the file is read
+const configReloadDelay = 250 * time.Millisecond
+
+// watchConfig reloads the configuration on SIGHUP and, with watchFile, when its file
+// changes, until ctx is done. A reload that fails is logged and the proxy keeps running
+// on the config it had.
+func watchConfig(ctx context.Context, lb *LoadBalancer, configPath string, watchFile bool) error {
+	// Without a watcher its channels stay nil and only SIGHUP reloads
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+
+	dir, name := filepath.Split(filepath.Clean(configPath))
+	if dir == "" {
+		dir = "."
+	}
+
+	if watchFile {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
+
+		// The directory is watched, editors and Kubernetes config maps replace the file
+		// instead of writing to it, which would end a watch on the file itself
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	reload := func(reason string) {
+		if err := reloadConfiguration(lb, configPath); err != nil {
+			log.Printf("Config reload on %s failed, keeping the current config: %v", reason, err)
+		}
+	}
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			base := filepath.Base(event.Name)
+			if base != name && base != "..data" {
+				continue
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+				pending = time.After(configReloadDelay)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				return nil
+			}
+			log.Printf("Config watcher error: %v", err)
+		case <-pending:
+			pending = nil
+			reload("file change")
+		case <-hup:
+			reload("SIGHUP")
+		}
+	}
+}
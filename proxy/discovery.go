@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDiscoveryInterval = 10 * time.Second
+	defaultDockerSocket      = "/var/run/docker.sock"
+	defaultDockerLabel       = "visper.proxy"
+	defaultConsulAddr        = "http://127.0.0.1:8500"
+	consulWaitTime           = 5 * time.Minute
+)
+
+// DiscoveryConfig replaces the static backend list with backends found at runtime
+type DiscoveryConfig struct {
+	Provider     string        `json:"provider"` // dns_srv, docker or consul
+	Interval     time.Duration `json:"interval"`
+	Scheme       string        `json:"scheme"`
+	Service      string        `json:"service"` // SRV name or Consul service
+	DockerSocket string        `json:"docker_socket"`
+	DockerLabel  string        `json:"docker_label"`
+	ConsulAddr   string        `json:"consul_addr"`
+	ConsulToken  string        `json:"consul_token"`
+}
+
+// Discoverer finds the backends to balance over
+type Discoverer interface {
+	// Watch calls update with the backends whenever they change, until ctx is done
+	Watch(ctx context.Context, update func([]BackendConfig))
+}
+
+// NewDiscoverer creates the discoverer for the configured provider
+func NewDiscoverer(c DiscoveryConfig) (Discoverer, error) {
+	if c.Interval <= 0 {
+		c.Interval = defaultDiscoveryInterval
+	}
+	if c.Scheme == "" {
+		c.Scheme = "http"
+	}
+
+	switch c.Provider {
+	case "dns_srv":
+		if c.Service == "" {
+			return nil, fmt.Errorf("dns_srv discovery needs a service, e.g. _http._tcp.api.visper.local")
+		}
+		return &dnsSRVDiscoverer{config: c, resolver: net.DefaultResolver}, nil
+	case "docker":
+		if c.DockerSocket == "" {
+			c.DockerSocket = defaultDockerSocket
+		}
+		if c.DockerLabel == "" {
+			c.DockerLabel = defaultDockerLabel
+		}
+		return newDockerDiscoverer(c), nil
+	case "consul":
+		if c.Service == "" {
+			return nil, fmt.Errorf("consul discovery needs a service")
+		}
+		if c.ConsulAddr == "" {
+			c.ConsulAddr = defaultConsulAddr
+		}
+		return &consulDiscoverer{config: c, client: &http.Client{Timeout: consulWaitTime + 30*time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("unknown discovery provider: %s", c.Provider)
+	}
+}
+
+// poll looks the backends up every interval and reports them when they differ from the
+// last lookup, backends are sorted so the order doesn't count. Failed lookups keep the
+// current backends.
+func poll(ctx context.Context, interval time.Duration, lookup func(context.Context) ([]BackendConfig, error), update func([]BackendConfig)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []BackendConfig
+	for {
+		backends, err := lookup(ctx)
+		if err != nil {
+			log.Printf("Backend discovery failed: %v", err)
+		} else if last == nil || !slices.Equal(last, backends) {
+			update(backends)
+			last = backends
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sortBackends(backends []BackendConfig) []BackendConfig {
+	slices.SortFunc(backends, func(a, b BackendConfig) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+	return backends
+}
+
+func backendURL(scheme, host string, port int) string {
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// dnsSRVDiscoverer resolves an SRV record, every target is a backend weighted like the record
+type dnsSRVDiscoverer struct {
+	config   DiscoveryConfig
+	resolver *net.Resolver
+}
+
+func (d *dnsSRVDiscoverer) Watch(ctx context.Context, update func([]BackendConfig)) {
+	poll(ctx, d.config.Interval, d.lookup, update)
+}
+
+func (d *dnsSRVDiscoverer) lookup(ctx context.Context) ([]BackendConfig, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", d.config.Service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", d.config.Service, err)
+	}
+
+	backends := make([]BackendConfig, 0, len(records))
+	for _, record := range records {
+		backends = append(backends, BackendConfig{
+			URL:    backendURL(d.config.Scheme, strings.TrimSuffix(record.Target, "."), int(record.Port)),
+			Weight: max(int(record.Weight), 1),
+		})
+	}
+	return sortBackends(backends), nil
+}
+
+// dockerDiscoverer scans the running containers labelled <label>.enable=true. The port is
+// taken from <label>.port, the weight from <label>.weight and the network the container is
+// reached on from <label>.network, the first one otherwise.
+type dockerDiscoverer struct {
+	config DiscoveryConfig
+	client *http.Client
+}
+
+type dockerContainer struct {
+	ID              string            `json:"Id"`
+	Labels          map[string]string `json:"Labels"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func newDockerDiscoverer(c DiscoveryConfig) *dockerDiscoverer {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", c.DockerSocket)
+		},
+	}
+
+	return &dockerDiscoverer{
+		config: c,
+		client: &http.Client{Transport: transport, Timeout: 10 * time.Second},
+	}
+}
+
+func (d *dockerDiscoverer) Watch(ctx context.Context, update func([]BackendConfig)) {
+	poll(ctx, d.config.Interval, d.lookup, update)
+}
+
+func (d *dockerDiscoverer) lookup(ctx context.Context) ([]BackendConfig, error) {
+	label := d.config.DockerLabel
+	filters := fmt.Sprintf(`{"label":["%s.enable=true"],"status":["running"]}`, label)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/json?filters="+url.QueryEscape(filters), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker returned %s", resp.Status)
+	}
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("failed to decode containers: %w", err)
+	}
+
+	backends := make([]BackendConfig, 0, len(containers))
+	for _, container := range containers {
+		port, err := strconv.Atoi(container.Labels[label+".port"])
+		if err != nil {
+			log.Printf("Skipping container %.12s, %s.port is not a port", container.ID, label)
+			continue
+		}
+
+		ip := ""
+		if network, ok := container.Labels[label+".network"]; ok {
+			ip = container.NetworkSettings.Networks[network].IPAddress
+		} else {
+			names := make([]string, 0, len(container.NetworkSettings.Networks))
+			for name := range container.NetworkSettings.Networks {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			if len(names) > 0 {
+				ip = container.NetworkSettings.Networks[names[0]].IPAddress
+			}
+		}
+		if ip == "" {
+			log.Printf("Skipping container %.12s, it has no address", container.ID)
+			continue
+		}
+
+		weight, _ := strconv.Atoi(container.Labels[label+".weight"])
+		backends = append(backends, BackendConfig{
+			URL:    backendURL(d.config.Scheme, ip, port),
+			Weight: max(weight, 1),
+		})
+	}
+	return sortBackends(backends), nil
+}
+
+// consulDiscoverer watches the healthy instances of a Consul service with blocking queries,
+// so changes are picked up as soon as Consul sees them
+type consulDiscoverer struct {
+	config DiscoveryConfig
+	client *http.Client
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+func (d *consulDiscoverer) Watch(ctx context.Context, update func([]BackendConfig)) {
+	var index uint64
+	var last []BackendConfig
+
+	for ctx.Err() == nil {
+		backends, nextIndex, err := d.lookup(ctx, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Backend discovery failed: %v", err)
+			index = 0
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.config.Interval):
+			}
+			continue
+		}
+
+		// The index going backwards means Consul was reset, start over
+		if nextIndex < index {
+			nextIndex = 0
+		}
+		index = nextIndex
+
+		if last == nil || !slices.Equal(last, backends) {
+			update(backends)
+			last = backends
+		}
+
+		// Without an index the query can't block, fall back to polling
+		if index == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.config.Interval):
+			}
+		}
+	}
+}
+
+func (d *consulDiscoverer) lookup(ctx context.Context, index uint64) ([]BackendConfig, uint64, error) {
+	query := url.Values{}
+	query.Set("passing", "true")
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", consulWaitTime.String())
+	}
+
+	endpoint := strings.TrimSuffix(d.config.ConsulAddr, "/") + "/v1/health/service/" + url.PathEscape(d.config.Service) + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if d.config.ConsulToken != "" {
+		req.Header.Set("X-Consul-Token", d.config.ConsulToken)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul returned %s", resp.Status)
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode consul response: %w", err)
+	}
+
+	nextIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	backends := make([]BackendConfig, 0, len(entries))
+	for _, entry := range entries {
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+
+		weight, _ := strconv.Atoi(entry.Service.Meta["weight"])
+		backends = append(backends, BackendConfig{
+			URL:    backendURL(d.config.Scheme, address, entry.Service.Port),
+			Weight: max(weight, 1),
+		})
+	}
+	return sortBackends(backends), nextIndex, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHealthCheckPath     = "/health"
+	defaultHealthCheckTimeout  = 3 * time.Second
+	defaultHealthCheckInterval = 30 * time.Second
+)
+
+// HealthCheckConfig describes how a backend is probed. Zero fields fall back to the
+// top-level health_check, then to the defaults: GET /health answered below 500 within 3s,
+// every health_check_interval, down after max_fail_count failures and up after a success.
+type HealthCheckConfig struct {
+	Mode               string        `json:"mode"` // http or tcp, which only connects
+	Path               string        `json:"path"`
+	Method             string        `json:"method"`
+	ExpectedStatus     []int         `json:"expected_status"`
+	Timeout            time.Duration `json:"timeout"`
+	Interval           time.Duration `json:"interval"`
+	HealthyThreshold   int           `json:"healthy_threshold"`
+	UnhealthyThreshold int           `json:"unhealthy_threshold"`
+}
+
+// merge fills the zero fields of c from fallback
+func (c HealthCheckConfig) merge(fallback HealthCheckConfig) HealthCheckConfig {
+	if c.Mode == "" {
+		c.Mode = fallback.Mode
+	}
+	if c.Path == "" {
+		c.Path = fallback.Path
+	}
+	if c.Method == "" {
+		c.Method = fallback.Method
+	}
+	if len(c.ExpectedStatus) == 0 {
+		c.ExpectedStatus = fallback.ExpectedStatus
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = fallback.Timeout
+	}
+	if c.Interval <= 0 {
+		c.Interval = fallback.Interval
+	}
+	if c.HealthyThreshold <= 0 {
+		c.HealthyThreshold = fallback.HealthyThreshold
+	}
+	if c.UnhealthyThreshold <= 0 {
+		c.UnhealthyThreshold = fallback.UnhealthyThreshold
+	}
+	return c
+}
+
+// validate checks a health check before it is used
+func (c HealthCheckConfig) validate() error {
+	switch c.Mode {
+	case "", "http", "tcp":
+	default:
+		return fmt.Errorf("unknown health check mode: %s", c.Mode)
+	}
+	for _, status := range c.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid expected health check status: %d", status)
+		}
+	}
+	return nil
+}
+
+// healthCheckFor resolves the backend's health check, read again before every probe so a
+// reload applies from the next one
+func (lb *LoadBalancer) healthCheckFor(b *Backend) HealthCheckConfig {
+	lb.mux.Lock()
+	interval := lb.healthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	defaults := HealthCheckConfig{
+		Mode:               "http",
+		Path:               defaultHealthCheckPath,
+		Method:             http.MethodGet,
+		Timeout:            defaultHealthCheckTimeout,
+		Interval:           interval,
+		HealthyThreshold:   1,
+		UnhealthyThreshold: max(lb.maxFailCount, 1),
+	}
+	global := lb.healthCheckConfig
+	lb.mux.Unlock()
+
+	b.mux.RLock()
+	var override HealthCheckConfig
+	if b.healthCheck != nil {
+		override = *b.healthCheck
+	}
+	b.mux.RUnlock()
+
+	return override.merge(global).merge(defaults)
+}
+
+// startHealthCheck probes the backend on its own interval until stopHealthCheck
+func (lb *LoadBalancer) startHealthCheck(b *Backend) {
+	stop := make(chan struct{})
+
+	b.mux.Lock()
+	b.healthStop = stop
+	b.mux.Unlock()
+
+	go lb.runHealthCheck(b, stop)
+}
+
+func (b *Backend) stopHealthCheck() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.healthStop != nil {
+		close(b.healthStop)
+		b.healthStop = nil
+	}
+}
+
+func (lb *LoadBalancer) runHealthCheck(b *Backend, stop chan struct{}) {
+	for {
+		hc := lb.healthCheckFor(b)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(hc.Interval):
+		}
+
+		err := lb.probe(b, hc)
+		changed, alive := b.recordProbe(err == nil, hc)
+
+		// Update metrics
+		backendLabel := b.URL.Host
+		if alive {
+			lb.metrics.backendUpGauge.WithLabelValues(backendLabel).Set(1)
+		} else {
+			lb.metrics.backendUpGauge.WithLabelValues(backendLabel).Set(0)
+		}
+		if err != nil {
+			lb.metrics.backendErrors.WithLabelValues(backendLabel, "health_check").Inc()
+		}
+
+		if changed && alive {
+			log.Printf("Backend %s is healthy again", backendLabel)
+		} else if changed {
+			log.Printf("Backend %s is down: %v", backendLabel, err)
+		}
+	}
+}
+
+// recordProbe counts consecutive results and flips the backend once a threshold is met
+func (b *Backend) recordProbe(ok bool, hc HealthCheckConfig) (changed, alive bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if ok {
+		b.healthFailures = 0
+		b.healthSuccesses++
+		if !b.Alive && b.healthSuccesses >= hc.HealthyThreshold {
+			b.Alive = true
+			b.failCount = 0
+			b.rampStart = time.Now()
+			changed = true
+		}
+	} else {
+		b.healthSuccesses = 0
+		b.healthFailures++
+		if b.Alive && b.healthFailures >= hc.UnhealthyThreshold {
+			b.Alive = false
+			changed = true
+		}
+	}
+	return changed, b.Alive
+}
+
+// probe runs one health check against the backend
+func (lb *LoadBalancer) probe(b *Backend, hc HealthCheckConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.Timeout)
+	defer cancel()
+
+	// A backend expecting the PROXY protocol is told the probe is the proxy's own
+	b.mux.RLock()
+	if b.proxyProtocol != "" {
+		ctx = withProxyHeader(ctx, proxyHeader{version: b.proxyProtocol})
+	}
+	b.mux.RUnlock()
+
+	if hc.Mode == "tcp" {
+		conn, err := proxyProtocolDialer(&net.Dialer{})(ctx, "tcp", backendAddr(b.URL))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	target := *b.URL
+	target.Path = singleJoiningSlash(b.URL.Path, hc.Path)
+	target.RawQuery = ""
+
+	req, err := http.NewRequestWithContext(ctx, hc.Method, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Close = wantsProxyHeader(ctx)
+
+	resp, err := lb.healthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if len(hc.ExpectedStatus) == 0 {
+		// Consider any non-5xx response as alive
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("health check returned %d", resp.StatusCode)
+		}
+		return nil
+	}
+	if !slices.Contains(hc.ExpectedStatus, resp.StatusCode) {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newHealthClient creates the client shared by HTTP probes, the timeout is per probe
+func newHealthClient() *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: proxyProtocolDialer(&net.Dialer{
+			Timeout:   2 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}),
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   2 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// backendAddr is the host:port a backend listens on
+func backendAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
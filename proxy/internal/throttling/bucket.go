@@ -0,0 +1,173 @@
+package throttling
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BucketConfig is a token bucket refilled at Rate tokens per second up to Burst
+type BucketConfig struct {
+	Rate  float64
+	Burst int
+}
+
+// BucketResult describes a bucket after a request took from it
+type BucketResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Until the request would be allowed, 0 when it is
+	Reset      time.Duration // Until the bucket is full again
+}
+
+// takeScript refills the bucket for the time since it was last used and takes cost tokens
+// from it when enough are left, atomically so every proxy node shares the bucket
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+
+return {allowed, tostring(tokens)}
+`)
+
+// TokenBuckets rate limits keys with token buckets kept in Redis. While Redis is
+// unreachable every node falls back to buckets of its own.
+type TokenBuckets struct {
+	name   string
+	config BucketConfig
+	client *redis.Client
+
+	mu    sync.Mutex
+	local map[string]*localBucket
+}
+
+type localBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// maxLocalBuckets bounds the fallback buckets, full ones are dropped past it
+const maxLocalBuckets = 100_000
+
+func NewTokenBuckets(name string, config BucketConfig, client *redis.Client) *TokenBuckets {
+	return &TokenBuckets{
+		name:   name,
+		config: config,
+		client: client,
+		local:  make(map[string]*localBucket),
+	}
+}
+
+// Take removes cost tokens from the key's bucket if it has them
+func (b *TokenBuckets) Take(ctx context.Context, key string, cost int) BucketResult {
+	now := time.Now()
+	redisKey := fmt.Sprintf("%s:bucket:%s:%s", RedisPrefix, b.name, key)
+
+	tokens, allowed, err := b.takeRedis(ctx, redisKey, now, cost)
+	if err != nil {
+		tokens, allowed = b.takeLocal(key, now, cost)
+	}
+
+	return b.result(tokens, allowed, cost)
+}
+
+func (b *TokenBuckets) takeRedis(ctx context.Context, key string, now time.Time, cost int) (float64, bool, error) {
+	if b.client == nil {
+		return 0, false, redis.Nil
+	}
+
+	res, err := takeScript.Run(ctx, b.client, []string{key},
+		b.config.Rate, b.config.Burst, now.UnixMilli(), cost).Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(res) != 2 {
+		return 0, false, fmt.Errorf("unexpected token bucket reply: %v", res)
+	}
+
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return tokens, allowed == 1, nil
+}
+
+func (b *TokenBuckets) takeLocal(key string, now time.Time, cost int) (float64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bucket, ok := b.local[key]
+	if !ok {
+		if len(b.local) >= maxLocalBuckets {
+			b.pruneLocal(now)
+		}
+		bucket = &localBucket{tokens: float64(b.config.Burst), ts: now}
+		b.local[key] = bucket
+	}
+
+	bucket.tokens = b.refill(bucket.tokens, now.Sub(bucket.ts))
+	bucket.ts = now
+
+	if bucket.tokens < float64(cost) {
+		return bucket.tokens, false
+	}
+	bucket.tokens -= float64(cost)
+	return bucket.tokens, true
+}
+
+// pruneLocal drops the buckets that have refilled completely, b.mu is held
+func (b *TokenBuckets) pruneLocal(now time.Time) {
+	for key, bucket := range b.local {
+		if b.refill(bucket.tokens, now.Sub(bucket.ts)) >= float64(b.config.Burst) {
+			delete(b.local, key)
+		}
+	}
+}
+
+func (b *TokenBuckets) refill(tokens float64, elapsed time.Duration) float64 {
+	return math.Min(float64(b.config.Burst), tokens+elapsed.Seconds()*b.config.Rate)
+}
+
+func (b *TokenBuckets) result(tokens float64, allowed bool, cost int) BucketResult {
+	res := BucketResult{
+		Allowed:   allowed,
+		Limit:     b.config.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     b.secondsToRefill(float64(b.config.Burst) - tokens),
+	}
+	if !allowed {
+		res.RetryAfter = b.secondsToRefill(float64(cost) - tokens)
+	}
+	return res
+}
+
+func (b *TokenBuckets) secondsToRefill(missing float64) time.Duration {
+	if missing <= 0 || b.config.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(missing / b.config.Rate * float64(time.Second))
+}
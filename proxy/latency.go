@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// ewmaDecay is how long the peak EWMA takes to forget most of a slow response
+	ewmaDecay = 10 * time.Second
+	// responseTimeAlpha weights the newest sample of the average response time
+	responseTimeAlpha = 0.3
+)
+
+// latencyStats follows a backend's response times, guarded by the backend's mux
+type latencyStats struct {
+	average   float64 // Seconds, exponentially weighted
+	peakEWMA  float64 // Seconds, jumps to slow responses and decays back over ewmaDecay
+	updatedAt time.Time
+}
+
+// ObserveResponseTime records how many seconds the backend took to answer a request,
+// alongside the backend_response_seconds histogram
+func (b *Backend) ObserveResponseTime(rtt float64) {
+	now := time.Now()
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	stats := &b.latency
+	if stats.updatedAt.IsZero() {
+		stats.average = rtt
+		stats.peakEWMA = rtt
+		stats.updatedAt = now
+		return
+	}
+
+	stats.average = responseTimeAlpha*rtt + (1-responseTimeAlpha)*stats.average
+
+	if rtt > stats.peakEWMA {
+		stats.peakEWMA = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(stats.updatedAt)) / float64(ewmaDecay))
+		stats.peakEWMA = stats.peakEWMA*w + rtt*(1-w)
+	}
+	stats.updatedAt = now
+}
+
+// peakEWMACost is the backend's decayed latency times the requests waiting on it
+func (b *Backend) peakEWMACost(now time.Time) float64 {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	latency := b.latency.peakEWMA
+	if !b.latency.updatedAt.IsZero() {
+		latency *= math.Exp(-float64(now.Sub(b.latency.updatedAt)) / float64(ewmaDecay))
+	}
+	return latency * float64(b.connections+1)
+}
+
+// responseTimeCost is the backend's average response time times the requests waiting on it
+func (b *Backend) responseTimeCost() float64 {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	return b.latency.average * float64(b.connections+1)
+}
+
+// leastResponseTimeSelect selects the alive backend expected to answer soonest. Backends
+// without samples cost nothing, so they are tried first.
+func (lb *LoadBalancer) leastResponseTimeSelect(backends []*Backend) *Backend {
+	return lb.lowestCostSelect(backends, func(b *Backend) float64 {
+		return b.responseTimeCost()
+	})
+}
+
+// peakEWMASelect selects the alive backend with the lowest peak-EWMA cost, which reacts to
+// a backend slowing down at once and forgives it gradually
+func (lb *LoadBalancer) peakEWMASelect(backends []*Backend) *Backend {
+	now := time.Now()
+	return lb.lowestCostSelect(backends, func(b *Backend) float64 {
+		return b.peakEWMACost(now)
+	})
+}
+
+// lowestCostSelect starts scanning after the last pick so equal costs are spread round-robin
+func (lb *LoadBalancer) lowestCostSelect(backends []*Backend, cost func(b *Backend) float64) *Backend {
+	var best *Backend
+	bestIdx := 0
+	bestCost := math.Inf(1)
+
+	for i := range backends {
+		idx := (lb.current + 1 + i) % len(backends)
+		b := backends[idx]
+		if !b.IsAlive() {
+			continue
+		}
+
+		if c := cost(b); c < bestCost {
+			best, bestIdx, bestCost = b, idx, c
+		}
+	}
+
+	if best != nil {
+		lb.current = bestIdx
+	}
+	return best
+}
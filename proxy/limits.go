@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// LimitsConfig rejects oversized requests at the proxy and controls whether responses are
+// buffered before they are sent on. Zero values leave a limit off.
+type LimitsConfig struct {
+	MaxBodyBytes   int64 `json:"max_body_bytes"`
+	MaxHeaderBytes int   `json:"max_header_bytes"`
+	MaxHeaderCount int   `json:"max_header_count"`
+
+	// BufferResponses reads responses of up to MaxBufferedBytes fully from the backend
+	// first, freeing its connection from slow clients. Event streams, upgrades and larger
+	// responses are always streamed.
+	BufferResponses  bool  `json:"buffer_responses"`
+	MaxBufferedBytes int64 `json:"max_buffered_bytes"`
+}
+
+const defaultMaxBufferedBytes = 1 << 20
+
+type bufferLimitKey struct{}
+
+// limitError is the JSON body of a rejected request, shaped like the API's errors
+type limitError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+func writeLimitError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(limitError{Error: code, Message: message})
+}
+
+// LimitsMiddleware answers 431 to requests with too many or too large headers and 413 to
+// bodies over the limit. A body without a Content-Length is cut off once it passes the
+// limit, the reverse proxy then answers 413 too.
+func LimitsMiddleware(limits LimitsConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limits.MaxHeaderCount > 0 || limits.MaxHeaderBytes > 0 {
+				count, size := 0, 0
+				for name, values := range r.Header {
+					count += len(values)
+					for _, value := range values {
+						size += len(name) + len(value)
+					}
+				}
+
+				if limits.MaxHeaderCount > 0 && count > limits.MaxHeaderCount {
+					writeLimitError(w, http.StatusRequestHeaderFieldsTooLarge, "headers_too_large",
+						fmt.Sprintf("request has %d headers, at most %d are allowed", count, limits.MaxHeaderCount))
+					return
+				}
+				if limits.MaxHeaderBytes > 0 && size > limits.MaxHeaderBytes {
+					writeLimitError(w, http.StatusRequestHeaderFieldsTooLarge, "headers_too_large",
+						fmt.Sprintf("request headers exceed %d bytes", limits.MaxHeaderBytes))
+					return
+				}
+			}
+
+			if limits.MaxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+				if r.ContentLength > limits.MaxBodyBytes {
+					writeLimitError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+						fmt.Sprintf("request body exceeds %d bytes", limits.MaxBodyBytes))
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBodyBytes)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// proxyErrorHandler answers 413 when the request body ran over its limit while it was
+// being forwarded, and 502 like the default handler otherwise
+func proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeLimitError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+			fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit))
+		return
+	}
+
+	log.Printf("http: proxy error: %v", err)
+	if errors.Is(err, errBackendTimeout) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+// bufferResponses makes the reverse proxy buffer the response to r
+func bufferResponses(r *http.Request, maxBytes int64) *http.Request {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBufferedBytes
+	}
+	return r.WithContext(context.WithValue(r.Context(), bufferLimitKey{}, maxBytes))
+}
+
+// modifyResponse buffers the response when the request asked for it
+func modifyResponse(resp *http.Response) error {
+	maxBytes, ok := resp.Request.Context().Value(bufferLimitKey{}).(int64)
+	if !ok {
+		// Allow WebSocket upgrade responses to pass through
+		return nil
+	}
+	return bufferResponse(resp, maxBytes)
+}
+
+// bufferResponse reads a response of up to maxBytes into memory. Anything it can't
+// buffer, event streams, upgrades and larger bodies, is passed through as a stream.
+func bufferResponse(resp *http.Response, maxBytes int64) error {
+	if resp.StatusCode == http.StatusSwitchingProtocols || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return nil
+	}
+	if resp.ContentLength > maxBytes {
+		return nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return err
+	}
+
+	if int64(len(buf)) > maxBytes {
+		// Longer than it looked, stream what is left after what was read
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), resp.Body), resp.Body}
+		return nil
+	}
+
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(buf))
+	resp.ContentLength = int64(len(buf))
+	resp.Header.Set("Content-Length", fmt.Sprint(len(buf)))
+	resp.Header.Del("Transfer-Encoding")
+	resp.TransferEncoding = nil
+	return nil
+}
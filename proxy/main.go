@@ -2,28 +2,57 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hilthontt/visper/proxy/internal/throttling"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 	// Define command line flags
-	configPath := flag.String("config", "", "Path to configuration file")
-	listenAddr := flag.String("listen", ":5004", "Address to listen on")
-	strategyStr := flag.String("strategy", "round_robin", "Load balancing strategy")
-	healthCheckInterval := flag.Duration("health-check-interval", 30*time.Second, "Health check interval")
-	maxFailCount := flag.Int("max-fail-count", 3, "Maximum failure count before marking backend as down")
+	configPath := flag.String("config", "", "Path to a JSON or YAML configuration file")
+	validateOnly := flag.Bool("validate-config", false, "Check the configuration file and exit without starting the server")
+	watchConfigFile := flag.Bool("watch-config", true, "Reload the config file when it changes, SIGHUP reloads it either way")
+	listenAddr := flag.String("listen", defaultListenAddr, "Address to listen on")
+	strategyStr := flag.String("strategy", defaultStrategy, "Load balancing strategy")
+	healthCheckInterval := flag.Duration("health-check-interval", defaultHealthCheckInterval, "Health check interval")
+	maxFailCount := flag.Int("max-fail-count", defaultMaxFailCount, "Maximum failure count before marking backend as down")
+	slowStart := flag.Duration("slow-start", 0, "How long a recovered backend takes to ramp up to its full share of traffic")
+	rateLimit := flag.Int("rate-limit", 100, "Requests per minute each client IP may send, 0 disables it")
+	allowCIDRs := flag.String("allow", "", "Comma separated CIDRs of the only clients let through")
+	denyCIDRs := flag.String("deny", "", "Comma separated CIDRs of clients that are refused")
+	trustedProxies := flag.String("trusted-proxies", "", "Comma separated CIDRs of proxies whose X-Forwarded-For, X-Real-IP and PROXY protocol headers are believed")
+	acceptProxyProtocol := flag.Bool("proxy-protocol", false, "Expect a PROXY protocol header on connections from the trusted proxies, or from everyone without any")
+	maxBodyBytes := flag.Int64("max-body-bytes", 0, "Largest request body forwarded to a backend, 0 for no limit")
+	outlierErrorRate := flag.Float64("outlier-error-rate", 0, "Eject backends whose share of 5xx answers reaches this rate, 0 disables it")
+	drainTimeout := flag.Duration("drain-timeout", defaultDrainTimeout, "How long WebSocket tunnels to a removed backend may stay open after a reload")
+	stickyStr := flag.String("sticky", "none", "Sticky sessions for WebSocket traffic: none, cookie or ip_hash")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file, enables HTTPS")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	autocertDomains := flag.String("autocert-domains", "", "Comma separated domains to get Let's Encrypt certificates for, enables HTTPS")
+	autocertCache := flag.String("autocert-cache", defaultAutocertCacheDir, "Directory Let's Encrypt certificates are cached in")
+	httpRedirectAddr := flag.String("http-redirect-addr", "", "Address of a plain HTTP listener redirecting to HTTPS, e.g. :80")
+	hstsMaxAge := flag.Int("hsts-max-age", 0, "Strict-Transport-Security max-age in seconds, 0 disables it")
+	discoveryProvider := flag.String("discovery", "", "Find backends at runtime instead of the static list: dns_srv, docker or consul")
+	discoveryService := flag.String("discovery-service", "", "SRV name or Consul service to discover backends for")
+	discoveryInterval := flag.Duration("discovery-interval", defaultDiscoveryInterval, "How often backends are looked up")
+	adminToken := flag.String("admin-token", os.Getenv("PROXY_ADMIN_TOKEN"), "Bearer token for the admin API, it is disabled without one")
+	auditLogPath := flag.String("audit-log", "", "File admin actions are appended to, stdout by default")
+	accessLogPath := flag.String("access-log", "", "File the access log is written to, stdout by default")
+	accessLogFormat := flag.String("access-log-format", "json", "Access log format: json, combined or off")
+	jaegerEndpoint := flag.String("jaeger-endpoint", "", "Jaeger collector endpoint spans are exported to, e.g. http://localhost:14268/api/traces")
+	stickyCookie := flag.String("sticky-cookie", defaultStickyCookie, "Cookie pinning WebSocket clients when -sticky=cookie")
 
 	flag.Parse()
 
@@ -31,13 +60,9 @@ func main() {
 
 	// If config file is provided, load it
 	if *configPath != "" {
-		data, err := os.ReadFile(*configPath)
-		if err != nil {
-			log.Fatalf("Error reading config file: %v", err)
-		}
-
-		if err := json.Unmarshal(data, &config); err != nil {
-			log.Fatalf("Error parsing config file: %v", err)
+		var err error
+		if config, err = loadConfig(*configPath); err != nil {
+			log.Fatalf("%v", err)
 		}
 	} else {
 		// Use command line flags
@@ -45,11 +70,64 @@ func main() {
 			ListenAddr:          *listenAddr,
 			HealthCheckInterval: *healthCheckInterval,
 			MaxFailCount:        *maxFailCount,
+			SlowStart:           *slowStart,
+			OutlierDetection:    OutlierConfig{ErrorRate: *outlierErrorRate},
+			Limits:              LimitsConfig{MaxBodyBytes: *maxBodyBytes},
 			Strategy:            *strategyStr,
+			DrainTimeout:        *drainTimeout,
 			Backends: []BackendConfig{
 				{URL: "http://localhost:5005", Weight: 1},
 			},
+			StickySessions: StickyConfig{
+				Mode:       *stickyStr,
+				CookieName: *stickyCookie,
+			},
+			AdminToken:   *adminToken,
+			AuditLogPath: *auditLogPath,
+			Tracing:      TracingConfig{JaegerEndpoint: *jaegerEndpoint},
+			AccessLog: AccessLogConfig{
+				Format: *accessLogFormat,
+				Path:   *accessLogPath,
+			},
+			RateLimit: RateLimitConfig{
+				GlobalPerMinute: defaultGlobalPerMinute,
+				PerIP:           BucketLimit{RequestsPerMinute: *rateLimit},
+				Allow:           splitList(*allowCIDRs),
+				Deny:            splitList(*denyCIDRs),
+			},
+			ClientIP: ClientIPConfig{
+				TrustedProxies:      splitList(*trustedProxies),
+				AcceptProxyProtocol: *acceptProxyProtocol,
+			},
+			Discovery: DiscoveryConfig{
+				Provider: *discoveryProvider,
+				Service:  *discoveryService,
+				Interval: *discoveryInterval,
+			},
+			TLS: TLSConfig{
+				CertFile:         *tlsCert,
+				KeyFile:          *tlsKey,
+				AutocertCacheDir: *autocertCache,
+				HTTPRedirectAddr: *httpRedirectAddr,
+				HSTSMaxAge:       *hstsMaxAge,
+			},
+		}
+		if *autocertDomains != "" {
+			config.TLS.AutocertDomains = strings.Split(*autocertDomains, ",")
+		}
+		config.applyDefaults()
+	}
+
+	if err := config.validate(); err != nil {
+		if *validateOnly {
+			fmt.Fprintf(os.Stderr, "Configuration is invalid:\n%v\n", err)
+			os.Exit(1)
 		}
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if *validateOnly {
+		fmt.Println("Configuration is valid")
+		return
 	}
 
 	// Parse strategy
@@ -58,17 +136,17 @@ func main() {
 		log.Fatalf("Invalid strategy: %v", err)
 	}
 
-	// Extract backends and weights
-	backendURLs := make([]string, len(config.Backends))
-	weights := make([]int, len(config.Backends))
-
-	for i, backend := range config.Backends {
-		backendURLs[i] = backend.URL
-		weights[i] = backend.Weight
+	stickyMode, err := parseStickyMode(config.StickySessions.Mode)
+	if err != nil {
+		log.Fatalf("Invalid sticky sessions: %v", err)
 	}
 
 	metrics := NewMetrics("loadBalancer")
 
+	if _, err := initTracing(config.Tracing); err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
+	}
+
 	redisClient := redis.NewFailoverClient(&redis.FailoverOptions{
 		MasterName: "mymaster",
 		SentinelAddrs: []string{
@@ -88,8 +166,11 @@ func main() {
 			return (&net.Dialer{}).DialContext(ctx, "tcp4", "127.0.0.1:"+port)
 		},
 	})
-	globalCfg := throttling.ThrottleConfig{MaxRequests: 10_000, Interval: time.Minute, Spans: 6, Cooldown: 30 * time.Second}
-	ipCfg := throttling.ThrottleConfig{MaxRequests: 100, Interval: time.Minute, Spans: 6, Cooldown: 30 * time.Second}
+	globalPerMinute := config.RateLimit.GlobalPerMinute
+	if globalPerMinute <= 0 {
+		globalPerMinute = defaultGlobalPerMinute
+	}
+	globalCfg := throttling.ThrottleConfig{MaxRequests: globalPerMinute, Interval: time.Minute, Spans: 6, Cooldown: 30 * time.Second}
 
 	ht := throttling.NewHierarchicalThrottler(
 		&throttling.ThrottleLevel{
@@ -97,83 +178,199 @@ func main() {
 			KeyExtractor: func(r *http.Request) string { return "global" },
 			Throttler:    throttling.NewThrottler(globalCfg, redisClient),
 		},
-		&throttling.ThrottleLevel{
-			Name:         "per-ip",
-			KeyExtractor: func(r *http.Request) string { return r.RemoteAddr },
-			Throttler:    throttling.NewThrottler(ipCfg, redisClient),
-		},
 	)
 
 	go ht.Start(context.Background())
 
-	// Create load balancer
+	rateLimiter, err := NewRateLimiter(config.RateLimit, redisClient)
+	if err != nil {
+		log.Fatalf("Invalid rate limit: %v", err)
+	}
+
+	clientIPResolver, err := NewClientIPResolver(config.ClientIP)
+	if err != nil {
+		log.Fatalf("Invalid client IP settings: %v", err)
+	}
+
+	// Create load balancer, the backends are added below so their health checks apply
 	lb := NewLoadBalancer(
-		backendURLs,
-		weights,
+		nil,
+		nil,
 		config.HealthCheckInterval,
 		config.MaxFailCount,
 		strategy,
 	)
 	lb.metrics = metrics
+	lb.drainTimeout = config.DrainTimeout
+	lb.healthCheckConfig = config.HealthCheck
+	lb.slowStart = config.SlowStart
+	lb.outlierDetection = config.OutlierDetection
+	lb.limits = config.Limits
+	lb.SetStickySessions(stickyMode, config.StickySessions.CookieName)
+	if err := lb.SetMirrors(config.Mirrors); err != nil {
+		log.Fatalf("Invalid mirrors: %v", err)
+	}
+
+	// Discovered backends replace the static list
+	if config.Discovery.Provider == "" {
+		if err := lb.SetBackends(config.Backends); err != nil {
+			log.Fatalf("Invalid backends: %v", err)
+		}
+	}
+
+	if config.Discovery.Provider != "" {
+		discoverer, err := NewDiscoverer(config.Discovery)
+		if err != nil {
+			log.Fatalf("Invalid discovery: %v", err)
+		}
+
+		go discoverer.Watch(context.Background(), func(backends []BackendConfig) {
+			if err := lb.SetBackends(backends); err != nil {
+				log.Printf("Failed to apply discovered backends: %v", err)
+				return
+			}
+			log.Printf("Discovered %d backends with %s", len(backends), config.Discovery.Provider)
+		})
+	}
+
+	if *configPath != "" {
+		go func() {
+			if err := watchConfig(context.Background(), lb, *configPath, *watchConfigFile); err != nil {
+				log.Printf("Config watcher stopped: %v", err)
+			}
+		}()
+	}
 
 	mux := http.NewServeMux()
 
 	mux.Handle("/", lb)
-	mux.Handle("/metrics", promhttp.Handler())
-	mux.HandleFunc("/admin/reload", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+	// OpenMetrics carries the trace exemplars of the latency histograms
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	))
 
-		// Reload configuration
-		if err := reloadConfiguration(lb, *configPath); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	auditLog, err := openAuditLog(config.AuditLogPath)
+	if err != nil {
+		log.Fatalf("Error opening audit log: %v", err)
+	}
+	NewAdminAPI(lb, config.AdminToken, *configPath, auditLog).Register(mux)
 
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Configuration reloaded successfully"))
-	})
+	accessLogger, err := NewAccessLogger(config.AccessLog, getClientIP)
+	if err != nil {
+		log.Fatalf("Error opening access log: %v", err)
+	}
 
 	// Start server
 	server := http.Server{
-		Addr:    config.ListenAddr,
-		Handler: chain(mux, HierarchicalThrottlingMiddleware(ht)),
+		Addr: config.ListenAddr,
+		Handler: chain(mux,
+			RequestIDMiddleware,
+			ClientIPMiddleware(clientIPResolver),
+			TracingMiddleware,
+			AccessLogMiddleware(accessLogger),
+			HSTSMiddleware(config.TLS.HSTSMaxAge, config.TLS.HSTSSubdomains),
+			IPFilterMiddleware(rateLimiter),
+			LimitsMiddleware(config.Limits),
+			HierarchicalThrottlingMiddleware(ht),
+			RateLimitMiddleware(rateLimiter),
+		),
+	}
+	if config.Limits.MaxHeaderBytes > 0 {
+		// Leaves room for LimitsMiddleware to answer with JSON before the server cuts it off
+		server.MaxHeaderBytes = 2 * config.Limits.MaxHeaderBytes
+	}
+
+	// Listeners are inherited from the previous process after a restart
+	restarter := newRestarter()
+
+	listener, err := restarter.Listen(config.ListenAddr)
+	if err != nil {
+		log.Fatalf("Error listening on %s: %v", config.ListenAddr, err)
+	}
+	if config.ClientIP.AcceptProxyProtocol {
+		listener = newProxyProtocolListener(listener, clientIPResolver.trusted, config.ClientIP.ProxyProtocolTimeout)
+	}
+
+	if !config.TLS.Enabled() {
+		log.Printf("Starting load balancer on %s with strategy: %s", config.ListenAddr, config.Strategy)
+		log.Printf("Metrics available at %s/metrics", config.ListenAddr)
+		go serve(&server, listener, false)
+
+		restarter.Ready()
+		serveUntilStopped(restarter, lb, &server)
+		return
+	}
+
+	tlsConfig, certManager, err := buildTLSConfig(config.TLS)
+	if err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
 	}
+	server.TLSConfig = tlsConfig
+	// Disable HTTP/2 for WebSocket support, upgrades need a connection to hijack
+	server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+
+	servers := []*http.Server{&server}
+
+	if config.TLS.HTTPRedirectAddr != "" {
+		redirect := redirectToHTTPS(config.ListenAddr)
+		if certManager != nil {
+			// Answers Let's Encrypt's HTTP challenges and redirects everything else
+			redirect = certManager.HTTPHandler(redirect)
+		}
+
+		redirectListener, err := restarter.Listen(config.TLS.HTTPRedirectAddr)
+		if err != nil {
+			log.Fatalf("Error listening on %s: %v", config.TLS.HTTPRedirectAddr, err)
+		}
 
-	log.Printf("Starting load balancer on %s with strategy: %s", config.ListenAddr, config.Strategy)
+		redirectServer := &http.Server{Addr: config.TLS.HTTPRedirectAddr, Handler: redirect}
+		servers = append(servers, redirectServer)
+
+		log.Printf("Redirecting HTTP on %s to HTTPS", config.TLS.HTTPRedirectAddr)
+		go serve(redirectServer, redirectListener, false)
+	}
+
+	log.Printf("Starting load balancer with TLS on %s with strategy: %s", config.ListenAddr, config.Strategy)
 	log.Printf("Metrics available at %s/metrics", config.ListenAddr)
-	log.Fatal(server.ListenAndServe())
+	go serve(&server, listener, true)
+
+	restarter.Ready()
+	serveUntilStopped(restarter, lb, servers...)
 }
 
+// reloadMu keeps the admin API, SIGHUP and the config watcher from reloading at once
+var reloadMu sync.Mutex
+
+// reloadConfiguration applies the config file to the running load balancer. The whole
+// file is checked before anything changes, an invalid one leaves the current config in
+// place. Backends still in the file keep their connections, health and statistics.
 func reloadConfiguration(lb *LoadBalancer, configPath string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
 	if configPath == "" {
 		return fmt.Errorf("no config file provided")
 	}
 
-	data, err := os.ReadFile(configPath)
+	config, err := loadConfig(configPath)
 	if err != nil {
-		return fmt.Errorf("error reading config file: %w", err)
+		return err
 	}
-
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("error parsing config file: %v", err)
+	if err := config.validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	strategy, err := parseStrategyString(config.Strategy)
-	if err != nil {
-		return fmt.Errorf("invalid strategy: %v", err)
-	}
+	// Both were validated above
+	strategy, _ := parseStrategyString(config.Strategy)
+	stickyMode, _ := parseStickyMode(config.StickySessions.Mode)
 
-	backendURLs := make([]string, len(config.Backends))
-	weights := make([]int, len(config.Backends))
-
-	// Extract backends and weights
-	for i, backend := range config.Backends {
-		backendURLs[i] = backend.URL
-		weights[i] = backend.Weight
+	// With discovery the backends are kept up to date by the discoverer. They are set
+	// first, as the only step that can still fail, so a failure leaves everything as it was.
+	if config.Discovery.Provider == "" {
+		if err := lb.SetBackends(config.Backends); err != nil {
+			return err
+		}
 	}
 
 	// Update load balancer configuration
@@ -181,35 +378,16 @@ func reloadConfiguration(lb *LoadBalancer, configPath string) error {
 	lb.healthCheckInterval = config.HealthCheckInterval
 	lb.maxFailCount = config.MaxFailCount
 	lb.strategy = strategy
-
-	// Update backends (keep the existing ones if they're still in the config)
-	oldBackends := lb.backends
-	lb.backends = make([]*Backend, len(config.Backends))
-
-	for i, backendURL := range backendURLs {
-		// Check if this backend already exists
-		found := false
-		for _, oldBackend := range oldBackends {
-			// Keep the existing backend but update its weight
-			lb.backends[i] = oldBackend
-			oldBackend.weight = weights[i]
-			found = true
-			break
-		}
-
-		if !found {
-			parsedURL, _ := url.Parse(backendURL) // Error already checked earlier
-			lb.backends[i] = &Backend{
-				URL:          parsedURL,
-				Alive:        true, // Assume alive until health check
-				ReverseProxy: createOptimizedReverseProxy(parsedURL),
-				weight:       weights[i],
-			}
-		}
-	}
-
+	lb.drainTimeout = config.DrainTimeout
+	lb.healthCheckConfig = config.HealthCheck
+	lb.slowStart = config.SlowStart
+	lb.outlierDetection = config.OutlierDetection
 	lb.mux.Unlock()
 
-	log.Printf("Configuration reloaded with %d backends and strategy: %s", len(lb.backends), config.Strategy)
+	lb.SetStickySessions(stickyMode, config.StickySessions.CookieName)
+	// Validated with the rest of the config
+	lb.SetMirrors(config.Mirrors)
+
+	log.Printf("Configuration reloaded with %d backends and strategy: %s", len(lb.Backends()), config.Strategy)
 	return nil
 }
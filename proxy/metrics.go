@@ -14,6 +14,9 @@ type Metrics struct {
 	activeConnections   *prometheus.GaugeVec
 	backendResponseTime *prometheus.HistogramVec
 	backendErrors       *prometheus.CounterVec
+	websocketTunnels    *prometheus.GaugeVec
+	outlierEjections    *prometheus.CounterVec
+	mirrorRequests      *prometheus.CounterVec
 }
 
 // NewMetrics creates a new metrics collection
@@ -69,6 +72,30 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"backend", "error_type"},
 		),
+		websocketTunnels: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "websocket_tunnels_active",
+				Help:      "Number of open WebSocket tunnels per backend",
+			},
+			[]string{"backend"},
+		),
+		outlierEjections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "outlier_ejections_total",
+				Help:      "Total number of times a backend was ejected for its error rate",
+			},
+			[]string{"backend"},
+		),
+		mirrorRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "mirror_requests_total",
+				Help:      "Total number of requests mirrored to shadow backends by their status, or why they weren't sent",
+			},
+			[]string{"backend", "result"},
+		),
 	}
 
 	// Register metrics
@@ -78,6 +105,9 @@ func NewMetrics(namespace string) *Metrics {
 	prometheus.MustRegister(m.activeConnections)
 	prometheus.MustRegister(m.backendResponseTime)
 	prometheus.MustRegister(m.backendErrors)
+	prometheus.MustRegister(m.websocketTunnels)
+	prometheus.MustRegister(m.outlierEjections)
+	prometheus.MustRegister(m.mirrorRequests)
 
 	return m
 }
@@ -89,7 +119,7 @@ type metricsResponseWriter struct {
 }
 
 // WriteHeader intercepts the status code
-func (w *metricsResponseWriter) WriterHeader(statusCode int) {
+func (w *metricsResponseWriter) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 	w.ResponseWriter.WriteHeader(statusCode)
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/hilthontt/visper/proxy/internal/throttling"
@@ -11,7 +12,8 @@ func ThrottlingMiddleware(throttler *throttling.Throttler) func(http.Handler) ht
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			route := r.Method + ":" + r.URL.Path
 			if !throttler.IncrementAndCheck(route, 1) {
-				http.Error(w, "Rate limit exceeded. Please try again later.", http.StatusTooManyRequests)
+				writeLimitError(w, http.StatusTooManyRequests, "rate_limited",
+					"rate limit exceeded, please try again later")
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -23,10 +25,28 @@ func HierarchicalThrottlingMiddleware(ht *throttling.HierarchicalThrottler) func
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if !ht.CheckRequest(r) {
-				http.Error(w, "Rate limit exceeded. Please try again later.", http.StatusTooManyRequests)
+				writeLimitError(w, http.StatusTooManyRequests, "rate_limited",
+					"rate limit exceeded, please try again later")
 				return
 			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// HSTSMiddleware tells browsers to only use HTTPS for the next maxAge seconds
+func HSTSMiddleware(maxAge int, includeSubdomains bool) func(http.Handler) http.Handler {
+	value := fmt.Sprintf("max-age=%d", maxAge)
+	if includeSubdomains {
+		value += "; includeSubDomains"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil && maxAge > 0 {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
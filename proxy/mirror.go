@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MirrorConfig copies a share of the live traffic to a shadow backend. Its responses are
+// thrown away, clients only ever see the answer of the main pool.
+type MirrorConfig struct {
+	URL          string        `json:"url"`
+	Percent      float64       `json:"percent"`     // Of the matching requests, 0 to 100
+	PathPrefix   string        `json:"path_prefix"` // Every path when empty
+	Methods      []string      `json:"methods"`     // Every method when empty
+	MaxBodyBytes int64         `json:"max_body_bytes"`
+	Timeout      time.Duration `json:"timeout"`
+}
+
+const (
+	defaultMirrorMaxBodyBytes = 64 << 10
+	defaultMirrorTimeout      = 5 * time.Second
+	maxMirrorsInFlight        = 256
+
+	// shadowHeader marks mirrored requests, so a shadow backend can skip side effects
+	// such as notifications
+	shadowHeader = "X-Shadow-Request"
+)
+
+type mirror struct {
+	config MirrorConfig
+	target *url.URL
+}
+
+func (c MirrorConfig) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid url %q", c.URL)
+	}
+	if c.Percent < 0 || c.Percent > 100 {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+	if c.MaxBodyBytes < 0 || c.Timeout < 0 {
+		return fmt.Errorf("max_body_bytes and timeout must not be negative")
+	}
+	return nil
+}
+
+// SetMirrors replaces the mirroring rules
+func (lb *LoadBalancer) SetMirrors(configs []MirrorConfig) error {
+	mirrors := make([]*mirror, 0, len(configs))
+	for i, config := range configs {
+		if err := config.validate(); err != nil {
+			return fmt.Errorf("mirrors[%d]: %w", i, err)
+		}
+
+		if config.MaxBodyBytes == 0 {
+			config.MaxBodyBytes = defaultMirrorMaxBodyBytes
+		}
+		if config.Timeout == 0 {
+			config.Timeout = defaultMirrorTimeout
+		}
+		config.Methods = slices.Clone(config.Methods)
+		for j, method := range config.Methods {
+			config.Methods[j] = strings.ToUpper(method)
+		}
+
+		target, _ := url.Parse(config.URL)
+		mirrors = append(mirrors, &mirror{config: config, target: target})
+	}
+
+	lb.mux.Lock()
+	lb.mirrors = mirrors
+	lb.mux.Unlock()
+	return nil
+}
+
+func (m *mirror) matches(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, m.config.PathPrefix) {
+		return false
+	}
+	if len(m.config.Methods) > 0 && !slices.Contains(m.config.Methods, r.Method) {
+		return false
+	}
+	return rand.Float64()*100 < m.config.Percent
+}
+
+// mirror sends copies of r to the shadow backends whose rule picks it. A body is read
+// into memory first so both the backend and the shadows get it, which is why requests
+// with a larger or unknown length are not mirrored.
+func (lb *LoadBalancer) mirror(r *http.Request) {
+	lb.mux.Lock()
+	mirrors := lb.mirrors
+	lb.mux.Unlock()
+
+	var body []byte
+	bodyRead := false
+
+	for _, m := range mirrors {
+		if !m.matches(r) {
+			continue
+		}
+		shadowLabel := m.target.Host
+
+		hasBody := r.Body != nil && r.Body != http.NoBody
+		if hasBody && (r.ContentLength < 0 || r.ContentLength > m.config.MaxBodyBytes) {
+			lb.metrics.mirrorRequests.WithLabelValues(shadowLabel, "body_too_large").Inc()
+			continue
+		}
+
+		if hasBody && !bodyRead {
+			read, err := io.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+			// Whatever happens the backend still gets the whole body
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(read), r.Body), r.Body}
+			if err != nil {
+				return
+			}
+			body, bodyRead = read, true
+		}
+
+		select {
+		case lb.mirrorSlots <- struct{}{}:
+		default:
+			// Shadow traffic never queues up behind a slow shadow
+			lb.metrics.mirrorRequests.WithLabelValues(shadowLabel, "dropped").Inc()
+			continue
+		}
+
+		req, err := m.request(r, body)
+		if err != nil {
+			<-lb.mirrorSlots
+			log.Printf("Failed to mirror request to %s: %v", shadowLabel, err)
+			continue
+		}
+
+		go func() {
+			defer func() { <-lb.mirrorSlots }()
+			lb.sendMirror(req, shadowLabel, m.config.Timeout)
+		}()
+	}
+}
+
+// request copies r for the shadow backend, detached from the client's cancellation
+func (m *mirror) request(r *http.Request, body []byte) (*http.Request, error) {
+	target := *m.target
+	target.Path = singleJoiningSlash(m.target.Path, r.URL.Path)
+	target.RawQuery = r.URL.RawQuery
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(context.WithoutCancel(r.Context()), r.Method, target.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = r.Header.Clone()
+	req.Header.Del("Connection")
+	req.Header.Del("Upgrade")
+	req.Header.Set(shadowHeader, "true")
+	setClientHeaders(req, r)
+	req.Header.Set("X-Forwarded-Proto", forwardedProto(r))
+	injectTrace(req)
+
+	return req, nil
+}
+
+func (lb *LoadBalancer) sendMirror(req *http.Request, shadowLabel string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	resp, err := lb.mirrorClient.Do(req.WithContext(ctx))
+	if err != nil {
+		lb.metrics.mirrorRequests.WithLabelValues(shadowLabel, "error").Inc()
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	lb.metrics.mirrorRequests.WithLabelValues(shadowLabel, fmt.Sprint(resp.StatusCode)).Inc()
+}
+
+// newMirrorClient creates the client shadow requests go out with, redirects are left
+// to the shadow's own clients
+func newMirrorClient() *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   2 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:        maxMirrorsInFlight,
+		MaxIdleConnsPerHost: maxMirrorsInFlight,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 2 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	// slowStartMinFactor is the share of traffic a backend gets as soon as it comes back
+	slowStartMinFactor = 0.1
+
+	defaultOutlierInterval       = 10 * time.Second
+	defaultOutlierMinRequests    = 20
+	defaultOutlierBaseEjection   = 30 * time.Second
+	defaultOutlierMaxEjectionPct = 50
+	maxOutlierEjection           = 10 * time.Minute
+	outlierTick                  = 100 * time.Millisecond
+)
+
+// OutlierConfig ejects backends whose error rate spikes even though their health checks
+// pass. Detection is off while ErrorRate is 0.
+type OutlierConfig struct {
+	ErrorRate          float64       `json:"error_rate"` // Share of 5xx answers, e.g. 0.5
+	MinRequests        int           `json:"min_requests"`
+	Interval           time.Duration `json:"interval"`
+	BaseEjection       time.Duration `json:"base_ejection"` // Doubles with every ejection in a row
+	MaxEjectionPercent int           `json:"max_ejection_percent"`
+}
+
+func (c OutlierConfig) withDefaults() OutlierConfig {
+	if c.MinRequests <= 0 {
+		c.MinRequests = defaultOutlierMinRequests
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultOutlierInterval
+	}
+	if c.BaseEjection <= 0 {
+		c.BaseEjection = defaultOutlierBaseEjection
+	}
+	if c.MaxEjectionPercent <= 0 {
+		c.MaxEjectionPercent = defaultOutlierMaxEjectionPct
+	}
+	return c
+}
+
+// outlierStats counts a backend's answers over the current interval, guarded by its mux
+type outlierStats struct {
+	requests     int
+	errors       int
+	ejections    int // In a row, forgiven one per healthy interval
+	ejectedUntil time.Time
+}
+
+func (s *outlierStats) ejected(now time.Time) bool {
+	return now.Before(s.ejectedUntil)
+}
+
+// recordOutcome counts a proxied request for outlier detection
+func (b *Backend) recordOutcome(ok bool) {
+	b.mux.Lock()
+	b.outlier.requests++
+	if !ok {
+		b.outlier.errors++
+	}
+	b.mux.Unlock()
+}
+
+// slowStartFactor is the share of its traffic a backend takes while it ramps up after
+// coming back, from slowStartMinFactor up to 1 over window. b.mux is held.
+func (b *Backend) slowStartFactor(now time.Time, window time.Duration) float64 {
+	if window <= 0 || b.rampStart.IsZero() {
+		return 1
+	}
+
+	elapsed := now.Sub(b.rampStart)
+	if elapsed >= window {
+		return 1
+	}
+	return max(float64(elapsed)/float64(window), slowStartMinFactor)
+}
+
+// admitSlowStart decides if a request picked for the backend may go to it
+func (b *Backend) admitSlowStart(now time.Time, window time.Duration) bool {
+	b.mux.RLock()
+	factor := b.slowStartFactor(now, window)
+	b.mux.RUnlock()
+
+	return factor >= 1 || rand.Float64() < factor
+}
+
+// slowStartAlternative picks another alive backend, by weight and how far each has
+// ramped up, for a request the ramping one turned away. lb.mux is held.
+func (lb *LoadBalancer) slowStartAlternative(backends []*Backend, skip *Backend) *Backend {
+	now := time.Now()
+	weights := make([]float64, len(backends))
+	total := 0.0
+
+	for i, b := range backends {
+		if b == skip || !b.IsAlive() {
+			continue
+		}
+
+		b.mux.RLock()
+		weights[i] = float64(max(b.weight, 1)) * b.slowStartFactor(now, lb.slowStart)
+		b.mux.RUnlock()
+		total += weights[i]
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	target := rand.Float64() * total
+	for i, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+		target -= weight
+		if target < 0 {
+			return backends[i]
+		}
+	}
+	return nil
+}
+
+// detectOutliers looks at every backend's error rate once per interval and ejects the
+// ones above the configured rate, never more than the allowed share of the backends
+func (lb *LoadBalancer) detectOutliers() {
+	// Ticks faster than any sensible interval so a reloaded one applies right away
+	ticker := time.NewTicker(outlierTick)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for now := range ticker.C {
+		lb.mux.Lock()
+		config := lb.outlierDetection.withDefaults()
+		lb.mux.Unlock()
+		backends := lb.Backends()
+
+		if now.Sub(last) < config.Interval {
+			continue
+		}
+		last = now
+
+		ejected := 0
+		for _, b := range backends {
+			b.mux.RLock()
+			if b.outlier.ejected(now) {
+				ejected++
+			}
+			b.mux.RUnlock()
+		}
+
+		for _, b := range backends {
+			b.mux.Lock()
+			stats := &b.outlier
+			requests, errors := stats.requests, stats.errors
+			stats.requests, stats.errors = 0, 0
+
+			if config.ErrorRate <= 0 || stats.ejected(now) {
+				b.mux.Unlock()
+				continue
+			}
+
+			outlier := requests >= config.MinRequests && float64(errors)/float64(requests) >= config.ErrorRate
+			if !outlier {
+				if stats.ejections > 0 {
+					stats.ejections--
+				}
+				b.mux.Unlock()
+				continue
+			}
+
+			if (ejected+1)*100 > config.MaxEjectionPercent*len(backends) {
+				b.mux.Unlock()
+				log.Printf("Backend %s is an outlier but too many backends are ejected already", b.URL.Host)
+				continue
+			}
+
+			duration := min(config.BaseEjection<<min(stats.ejections, 8), maxOutlierEjection)
+			stats.ejections++
+			stats.ejectedUntil = now.Add(duration)
+			// It ramps up again once the ejection is over
+			b.rampStart = stats.ejectedUntil
+			b.mux.Unlock()
+
+			ejected++
+			lb.metrics.outlierEjections.WithLabelValues(b.URL.Host).Inc()
+			log.Printf("Ejected backend %s for %s, %d of %d requests failed", b.URL.Host, duration, errors, requests)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The PROXY protocol lets a TCP load balancer pass the client's address on in a header
+// sent before anything else on the connection, in the text v1 or the binary v2 format.
+const (
+	ProxyProtocolV1 = "v1"
+	ProxyProtocolV2 = "v2"
+
+	defaultProxyProtocolTimeout = 5 * time.Second
+	maxProxyV1HeaderLen         = 107
+)
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var errNoProxyHeader = errors.New("no PROXY protocol header")
+
+func validProxyProtocol(version string) bool {
+	return version == "" || version == ProxyProtocolV1 || version == ProxyProtocolV2
+}
+
+// proxyProtocolListener reads the PROXY protocol header of the connections from trusted
+// sources, every connection when there are none. Headers are read off the accept loop,
+// so a slow sender holds up nobody else.
+type proxyProtocolListener struct {
+	net.Listener
+	trusted []netip.Prefix
+	timeout time.Duration
+
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// proxiedConn is a connection whose peer is the client a PROXY protocol header named
+type proxiedConn struct {
+	net.Conn
+	reader *bufio.Reader // Holds what was read past the header
+	remote net.Addr
+}
+
+func (c *proxiedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func newProxyProtocolListener(ln net.Listener, trusted []netip.Prefix, timeout time.Duration) net.Listener {
+	if timeout <= 0 {
+		timeout = defaultProxyProtocolTimeout
+	}
+
+	l := &proxyProtocolListener{
+		Listener: ln,
+		trusted:  trusted,
+		timeout:  timeout,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l
+}
+
+func (l *proxyProtocolListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go l.handshake(conn)
+	}
+}
+
+func (l *proxyProtocolListener) handshake(conn net.Conn) {
+	if len(l.trusted) > 0 {
+		if addr, err := netip.ParseAddrPort(conn.RemoteAddr().String()); err != nil || !containsAddr(l.trusted, addr.Addr().Unmap()) {
+			l.deliver(conn)
+			return
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(l.timeout))
+	reader := bufio.NewReader(conn)
+	remote, err := readProxyHeader(reader)
+	if err != nil {
+		log.Printf("Dropping connection from %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	if remote == nil {
+		// A health check of the load balancer in front, or an address it can't tell
+		remote = conn.RemoteAddr()
+	}
+	l.deliver(&proxiedConn{Conn: conn, reader: reader, remote: remote})
+}
+
+func (l *proxyProtocolListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *proxyProtocolListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// readProxyHeader reads a v1 or v2 header and returns the client it names, nil for a
+// header that names none
+func readProxyHeader(reader *bufio.Reader) (net.Addr, error) {
+	start, err := reader.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, fmt.Errorf("reading PROXY protocol header: %w", err)
+	}
+
+	switch {
+	case bytes.Equal(start, proxyV2Signature):
+		return readProxyV2Header(reader)
+	case bytes.HasPrefix(start, []byte("PROXY ")):
+		return readProxyV1Header(reader)
+	default:
+		return nil, errNoProxyHeader
+	}
+}
+
+func readProxyV1Header(reader *bufio.Reader) (net.Addr, error) {
+	line, err := reader.ReadSlice('\n')
+	if err != nil || len(line) > maxProxyV1HeaderLen || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("malformed PROXY protocol v1 header")
+	}
+
+	// PROXY TCP4 <source> <destination> <source port> <destination port>
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("malformed PROXY protocol v1 header")
+	}
+
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed PROXY protocol v1 source: %w", err)
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("malformed PROXY protocol v1 source port: %w", err)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+func readProxyV2Header(reader *bufio.Reader) (net.Addr, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, fmt.Errorf("reading PROXY protocol v2 header: %w", err)
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", header[12]>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("reading PROXY protocol v2 addresses: %w", err)
+	}
+
+	// LOCAL connections come from the sender itself
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+
+	switch header[13] >> 4 {
+	case 1: // IPv4, source and destination addresses then ports
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("short PROXY protocol v2 IPv4 addresses")
+		}
+		ip := netip.AddrFrom4([4]byte(payload[0:4]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, binary.BigEndian.Uint16(payload[8:10]))), nil
+	case 2: // IPv6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("short PROXY protocol v2 IPv6 addresses")
+		}
+		ip := netip.AddrFrom16([16]byte(payload[0:16]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, binary.BigEndian.Uint16(payload[32:34]))), nil
+	default:
+		// Unix sockets and unspecified families name no TCP client
+		return nil, nil
+	}
+}
+
+type proxyHeaderKey struct{}
+
+// proxyHeader is the header a connection to a backend starts with. Without addresses it
+// says the proxy connects on its own behalf, as it does for health checks.
+type proxyHeader struct {
+	version     string
+	source      netip.AddrPort
+	destination netip.AddrPort
+}
+
+// proxyHeaderFor is the header sending r on needs. The source is r's client with the
+// peer's port, the destination where r arrived.
+func proxyHeaderFor(r *http.Request, version string) proxyHeader {
+	h := proxyHeader{version: version}
+
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return h
+	}
+	client, ok := clientAddr(r)
+	if !ok {
+		return h
+	}
+	source := netip.AddrPortFrom(client, peer.Port())
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return h
+	}
+	destination, err := netip.ParseAddrPort(local.String())
+	if err != nil {
+		return h
+	}
+
+	source = netip.AddrPortFrom(source.Addr().Unmap(), source.Port())
+	destination = netip.AddrPortFrom(destination.Addr().Unmap(), destination.Port())
+	if source.Addr().Is4() != destination.Addr().Is4() {
+		return h
+	}
+	h.source, h.destination = source, destination
+	return h
+}
+
+func (h proxyHeader) bytes() []byte {
+	known := h.source.IsValid()
+
+	if h.version == ProxyProtocolV1 {
+		if !known {
+			return []byte("PROXY UNKNOWN\r\n")
+		}
+		family := "TCP4"
+		if h.source.Addr().Is6() {
+			family = "TCP6"
+		}
+		return fmt.Appendf(nil, "PROXY %s %s %s %d %d\r\n",
+			family, h.source.Addr(), h.destination.Addr(), h.source.Port(), h.destination.Port())
+	}
+
+	buf := bytes.NewBuffer(slices.Clone(proxyV2Signature))
+	if !known {
+		buf.Write([]byte{0x20, 0x00, 0, 0}) // LOCAL, unspecified family
+		return buf.Bytes()
+	}
+
+	var addrs []byte
+	family := byte(0x11) // TCP over IPv4
+	if h.source.Addr().Is4() {
+		src, dst := h.source.Addr().As4(), h.destination.Addr().As4()
+		addrs = append(append(addrs, src[:]...), dst[:]...)
+	} else {
+		family = 0x21 // TCP over IPv6
+		src, dst := h.source.Addr().As16(), h.destination.Addr().As16()
+		addrs = append(append(addrs, src[:]...), dst[:]...)
+	}
+	addrs = binary.BigEndian.AppendUint16(addrs, h.source.Port())
+	addrs = binary.BigEndian.AppendUint16(addrs, h.destination.Port())
+
+	buf.Write([]byte{0x21, family}) // Version 2, PROXY command
+	binary.Write(buf, binary.BigEndian, uint16(len(addrs)))
+	buf.Write(addrs)
+	return buf.Bytes()
+}
+
+// withProxyHeader makes the connections dialed for ctx start with the header. A header
+// belongs to one client, so these connections must not be reused for another request.
+func withProxyHeader(ctx context.Context, h proxyHeader) context.Context {
+	return context.WithValue(ctx, proxyHeaderKey{}, h)
+}
+
+func wantsProxyHeader(ctx context.Context) bool {
+	_, ok := ctx.Value(proxyHeaderKey{}).(proxyHeader)
+	return ok
+}
+
+// setProxyProtocol changes the header b's connections start with, b.mux is held. Idle
+// connections opened without a header would otherwise still be reused.
+func (b *Backend) setProxyProtocol(version string) {
+	if b.proxyProtocol == version {
+		return
+	}
+	b.proxyProtocol = version
+
+	if closer, ok := b.ReverseProxy.Transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// proxyProtocolDialer dials with dialer and writes the PROXY protocol header of the
+// context, if it has one, before anything else, TLS included
+func proxyProtocolDialer(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		h, ok := ctx.Value(proxyHeaderKey{}).(proxyHeader)
+		if !ok {
+			return conn, nil
+		}
+		if _, err := conn.Write(h.bytes()); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("writing PROXY protocol header: %w", err)
+		}
+		return conn, nil
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hilthontt/visper/proxy/internal/throttling"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitConfig limits clients with token buckets shared through Redis and filters them
+// by address. Client limits without requests per minute are off, the global limit on all
+// clients together defaults to 10000 a minute.
+type RateLimitConfig struct {
+	GlobalPerMinute int          `json:"global_per_minute"`
+	PerIP           BucketLimit  `json:"per_ip"`
+	Routes          []RouteLimit `json:"routes"`
+
+	// Allow and Deny hold CIDRs or single addresses. Denied clients are always refused,
+	// with an allow list only the clients on it get through.
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// BucketLimit lets a client send RequestsPerMinute on average and Burst at once,
+// Burst defaults to RequestsPerMinute
+type BucketLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	Burst             int `json:"burst"`
+}
+
+// RouteLimit is a per client limit on the requests whose path starts with Prefix,
+// of any method when Method is empty
+type RouteLimit struct {
+	Method string `json:"method"`
+	Prefix string `json:"prefix"`
+	BucketLimit
+}
+
+const defaultGlobalPerMinute = 10_000
+
+func (l BucketLimit) enabled() bool {
+	return l.RequestsPerMinute > 0
+}
+
+func (l BucketLimit) bucket() throttling.BucketConfig {
+	burst := l.Burst
+	if burst <= 0 {
+		burst = l.RequestsPerMinute
+	}
+	return throttling.BucketConfig{Rate: float64(l.RequestsPerMinute) / 60, Burst: burst}
+}
+
+// RateLimiter decides which clients may reach the backends
+type RateLimiter struct {
+	allow  []netip.Prefix
+	deny   []netip.Prefix
+	perIP  *throttling.TokenBuckets
+	routes []routeBuckets
+}
+
+type routeBuckets struct {
+	method  string
+	prefix  string
+	buckets *throttling.TokenBuckets
+}
+
+// NewRateLimiter creates the limiter for config, its buckets live in client
+func NewRateLimiter(config RateLimitConfig, client *redis.Client) (*RateLimiter, error) {
+	var rl RateLimiter
+	var err error
+
+	if rl.allow, err = parsePrefixes(config.Allow); err != nil {
+		return nil, fmt.Errorf("invalid allow list: %w", err)
+	}
+	if rl.deny, err = parsePrefixes(config.Deny); err != nil {
+		return nil, fmt.Errorf("invalid deny list: %w", err)
+	}
+
+	if config.PerIP.enabled() {
+		rl.perIP = throttling.NewTokenBuckets("ip", config.PerIP.bucket(), client)
+	}
+
+	for _, route := range config.Routes {
+		if route.Prefix == "" {
+			return nil, fmt.Errorf("route limit without a prefix")
+		}
+		if !route.enabled() {
+			continue
+		}
+
+		method := strings.ToUpper(route.Method)
+		rl.routes = append(rl.routes, routeBuckets{
+			method:  method,
+			prefix:  route.Prefix,
+			buckets: throttling.NewTokenBuckets("route:"+method+route.Prefix, route.bucket(), client),
+		})
+	}
+
+	return &rl, nil
+}
+
+// parsePrefixes parses CIDRs, a bare address is a prefix of its own
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether the allow and deny lists let addr through
+func (rl *RateLimiter) allowed(addr netip.Addr) bool {
+	if containsAddr(rl.deny, addr) {
+		return false
+	}
+	return len(rl.allow) == 0 || containsAddr(rl.allow, addr)
+}
+
+// take takes a token from every bucket r counts against and returns the tightest result
+func (rl *RateLimiter) take(r *http.Request, client string) (throttling.BucketResult, bool) {
+	var tightest throttling.BucketResult
+	limited := false
+
+	consider := func(res throttling.BucketResult) {
+		if !limited || (!res.Allowed && tightest.Allowed) ||
+			(res.Allowed == tightest.Allowed && res.Remaining < tightest.Remaining) {
+			tightest = res
+		}
+		limited = true
+	}
+
+	if rl.perIP != nil {
+		consider(rl.perIP.Take(r.Context(), client, 1))
+	}
+
+	for _, route := range rl.routes {
+		if route.method != "" && route.method != r.Method {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, route.prefix) {
+			continue
+		}
+		consider(route.buckets.Take(r.Context(), client, 1))
+	}
+
+	return tightest, limited
+}
+
+// IPFilterMiddleware answers 403 to clients the allow and deny lists keep out
+func IPFilterMiddleware(rl *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(rl.allow) == 0 && len(rl.deny) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			addr, ok := clientAddr(r)
+			if !ok || !rl.allowed(addr) {
+				writeLimitError(w, http.StatusForbidden, "forbidden", "client address is not allowed")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitMiddleware answers 429 once a client runs out of tokens and reports the
+// remaining quota in the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+func RateLimitMiddleware(rl *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, limited := rl.take(r, getClientIP(r))
+			if !limited {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(max(res.Remaining, 0)))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
+				writeLimitError(w, http.StatusTooManyRequests, "rate_limited",
+					"rate limit exceeded, please try again later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
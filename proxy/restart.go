@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A restart on SIGUSR2 starts a new process of the same binary that inherits the listening
+// sockets as extra files, so no connection is refused while both run. Once the new process
+// serves, the old one stops accepting, finishes its requests and drains its tunnels.
+const (
+	inheritedListenersEnv = "PROXY_INHERITED_LISTENERS" // Addresses of fds 3, 4 and so on
+	restartReadyEnv       = "PROXY_RESTART_READY_FD"
+	restartReadyTimeout   = 30 * time.Second
+)
+
+// restarter hands the listeners over to the next process
+type restarter struct {
+	mu        sync.Mutex
+	inherited map[string]*os.File
+	listeners []restartListener
+}
+
+type restartListener struct {
+	addr string
+	ln   *net.TCPListener
+}
+
+func newRestarter() *restarter {
+	r := &restarter{inherited: make(map[string]*os.File)}
+
+	if addrs := os.Getenv(inheritedListenersEnv); addrs != "" {
+		for i, addr := range strings.Split(addrs, ",") {
+			r.inherited[addr] = os.NewFile(uintptr(3+i), addr)
+		}
+	}
+	return r
+}
+
+// Listen returns the listener on addr the previous process handed over, or a new one
+func (r *restarter) Listen(addr string) (net.Listener, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ln net.Listener
+	var err error
+	if f, ok := r.inherited[addr]; ok {
+		delete(r.inherited, addr)
+		ln, err = net.FileListener(f)
+		f.Close()
+		if err == nil {
+			log.Printf("Inherited the listener on %s", addr)
+		}
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tcpListener, ok := ln.(*net.TCPListener)
+	if !ok {
+		ln.Close()
+		return nil, fmt.Errorf("listener on %s is not TCP", addr)
+	}
+
+	r.listeners = append(r.listeners, restartListener{addr: addr, ln: tcpListener})
+	return ln, nil
+}
+
+// Ready tells the previous process this one is serving, it then stops accepting
+func (r *restarter) Ready() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Listeners the new config no longer uses
+	for addr, f := range r.inherited {
+		f.Close()
+		delete(r.inherited, addr)
+	}
+
+	fd, err := strconv.Atoi(os.Getenv(restartReadyEnv))
+	if err != nil {
+		return
+	}
+	ready := os.NewFile(uintptr(fd), "ready")
+	ready.Write([]byte{1})
+	ready.Close()
+}
+
+// Restart starts the next process on the same listeners and returns once it is serving.
+// If it fails to start, this process carries on as if nothing happened.
+func (r *restarter) Restart() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	files := make([]*os.File, 0, len(r.listeners)+1)
+	addrs := make([]string, 0, len(r.listeners))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, l := range r.listeners {
+		f, err := l.ln.File()
+		if err != nil {
+			return fmt.Errorf("passing the listener on %s: %w", l.addr, err)
+		}
+		files = append(files, f)
+		addrs = append(addrs, l.addr)
+	}
+
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+	files = append(files, readyWriter)
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, inheritedListenersEnv+"=") && !strings.HasPrefix(kv, restartReadyEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		inheritedListenersEnv+"="+strings.Join(addrs, ","),
+		fmt.Sprintf("%s=%d", restartReadyEnv, 3+len(addrs)),
+	)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.ExtraFiles = files
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Only the new process holds the pipe now, it closes when that process exits
+	readyWriter.Close()
+
+	go cmd.Wait()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return fmt.Errorf("new process exited before it was ready")
+		}
+		return nil
+	case <-time.After(restartReadyTimeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("new process wasn't ready within %s", restartReadyTimeout)
+	}
+}
+
+// serveUntilStopped waits for SIGINT or SIGTERM, or for SIGUSR2 and a successful restart,
+// then shuts the servers down gracefully and drains the WebSocket tunnels, all within the
+// drain timeout
+func serveUntilStopped(r *restarter, lb *LoadBalancer, servers ...*http.Server) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
+
+	for sig := range signals {
+		if sig != syscall.SIGUSR2 {
+			log.Printf("Received %s, shutting down", sig)
+			break
+		}
+
+		if err := r.Restart(); err != nil {
+			log.Printf("Restart failed, still serving: %v", err)
+			continue
+		}
+		log.Printf("New process is serving, draining this one")
+		break
+	}
+	signal.Stop(signals)
+
+	timeout := lb.drainTimeoutOrDefault()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				log.Printf("Error shutting down %s: %v", server.Addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Hijacked connections aren't waited for by Shutdown, the tunnels get what is left
+	deadline, _ := ctx.Deadline()
+	remaining := time.Until(deadline)
+	for _, b := range lb.Backends() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.drain(remaining)
+		}()
+	}
+	wg.Wait()
+}
+
+// serve runs the server on ln until it is shut down
+func serve(server *http.Server, ln net.Listener, tls bool) {
+	var err error
+	if tls {
+		err = server.ServeTLS(ln, "", "")
+	} else {
+		err = server.Serve(ln)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
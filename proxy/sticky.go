@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StickyMode decides how WebSocket clients are pinned to a backend
+type StickyMode int
+
+const (
+	StickyNone StickyMode = iota
+	StickyCookie
+	StickyIPHash
+)
+
+const (
+	defaultStickyCookie = "visper_backend"
+	stickyCookieMaxAge  = 24 * time.Hour
+)
+
+// StickyConfig pins WebSocket traffic, /ws and upgrade requests, so a client that
+// reconnects lands on the node holding its room state
+type StickyConfig struct {
+	Mode       string `json:"mode"`
+	CookieName string `json:"cookie_name"`
+}
+
+// parseStickyMode converts a sticky mode string to a StickyMode enum
+func parseStickyMode(s string) (StickyMode, error) {
+	switch s {
+	case "", "none":
+		return StickyNone, nil
+	case "cookie":
+		return StickyCookie, nil
+	case "ip_hash":
+		return StickyIPHash, nil
+	default:
+		return 0, fmt.Errorf("unknown sticky mode: %s", s)
+	}
+}
+
+// isStickyRequest returns true for requests that must stay on one backend
+func isStickyRequest(r *http.Request) bool {
+	return r.URL.Path == "/ws" || strings.HasPrefix(r.URL.Path, "/ws/") || isWebSocketRequest(r)
+}
+
+// backendID identifies a backend in the affinity cookie without exposing its address
+func backendID(b *Backend) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(b.URL.String()))
+	return fmt.Sprintf("%x", hash.Sum64())
+}
+
+// chooseStickyBackend returns the backend the client is pinned to. When that backend is
+// down the client fails over to the one the strategy picks and is pinned to it instead.
+func (lb *LoadBalancer) chooseStickyBackend(w http.ResponseWriter, r *http.Request) *Backend {
+	lb.mux.Lock()
+	mode, cookieName := lb.stickyMode, lb.stickyCookie
+	lb.mux.Unlock()
+
+	switch mode {
+	case StickyCookie:
+		cookie, err := r.Cookie(cookieName)
+		if err == nil {
+			backend := lb.backendByID(cookie.Value)
+			if backend != nil && backend.IsAlive() && lb.canaryAllows(backend) {
+				return backend
+			}
+			log.Printf("Sticky backend %s is unavailable, failing over", cookie.Value)
+		}
+
+		backend := lb.chooseBackendByStrategy(r)
+		if backend != nil {
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookieName,
+				Value:    backendID(backend),
+				Path:     "/",
+				MaxAge:   int(stickyCookieMaxAge.Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		return backend
+	case StickyIPHash:
+		backends := lb.Backends()
+
+		lb.mux.Lock()
+		defer lb.mux.Unlock()
+
+		if pool := lb.canaryPool(backends, clientRoll(r)); pool != nil {
+			backends = pool
+		}
+
+		if len(backends) == 0 {
+			return nil
+		}
+		// ipHashSelect moves on to the next alive backend when the hashed one is down
+		return lb.ipHashSelect(r, backends)
+	default:
+		return lb.chooseBackendByStrategy(r)
+	}
+}
+
+// SetStickySessions pins WebSocket traffic with mode, cookieName defaults to visper_backend
+func (lb *LoadBalancer) SetStickySessions(mode StickyMode, cookieName string) {
+	if cookieName == "" {
+		cookieName = defaultStickyCookie
+	}
+
+	lb.mux.Lock()
+	lb.stickyMode = mode
+	lb.stickyCookie = cookieName
+	lb.mux.Unlock()
+}
+
+// backendByID finds the backend a cookie refers to
+func (lb *LoadBalancer) backendByID(id string) *Backend {
+	for _, b := range lb.Backends() {
+		if backendID(b) == id {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const defaultAutocertCacheDir = "autocert-cache"
+
+// TLSConfig terminates TLS in the proxy, either with a certificate from disk or one
+// obtained from Let's Encrypt for AutocertDomains
+type TLSConfig struct {
+	CertFile         string   `json:"cert_file"`
+	KeyFile          string   `json:"key_file"`
+	AutocertDomains  []string `json:"autocert_domains"`
+	AutocertCacheDir string   `json:"autocert_cache_dir"`
+	AutocertEmail    string   `json:"autocert_email"`
+	MinVersion       string   `json:"min_version"`
+	CipherSuites     []string `json:"cipher_suites"` // Only apply to TLS 1.2, 1.3 suites are fixed
+	HSTSMaxAge       int      `json:"hsts_max_age"`  // Seconds, 0 disables the header
+	HSTSSubdomains   bool     `json:"hsts_include_subdomains"`
+	HTTPRedirectAddr string   `json:"http_redirect_addr"`
+}
+
+// Enabled returns true when the proxy serves HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || len(c.AutocertDomains) > 0
+}
+
+// buildTLSConfig creates the listener's TLS configuration. With autocert it also returns
+// the manager, whose HTTP handler has to answer the ACME challenges on port 80.
+func buildTLSConfig(c TLSConfig) (*tls.Config, *autocert.Manager, error) {
+	if c.CertFile != "" && len(c.AutocertDomains) > 0 {
+		return nil, nil, fmt.Errorf("cert_file and autocert_domains are mutually exclusive")
+	}
+
+	minVersion, err := parseTLSVersion(c.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cipherSuites, err := parseCipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		NextProtos:   []string{"http/1.1"},
+	}
+
+	if len(c.AutocertDomains) == 0 {
+		if c.KeyFile == "" {
+			return nil, nil, fmt.Errorf("key_file is required with cert_file")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		return tlsConfig, nil, nil
+	}
+
+	cacheDir := c.AutocertCacheDir
+	if cacheDir == "" {
+		cacheDir = defaultAutocertCacheDir
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.AutocertDomains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      c.AutocertEmail,
+	}
+	tlsConfig.GetCertificate = manager.GetCertificate
+	tlsConfig.NextProtos = append(tlsConfig.NextProtos, acme.ALPNProto)
+
+	return tlsConfig, manager, nil
+}
+
+// parseTLSVersion converts a version string to its tls constant, TLS 1.2 by default
+func parseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version: %s", s)
+	}
+}
+
+// parseCipherSuites resolves suite names, only the ones Go considers secure are accepted
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite: %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL on the HTTPS listener
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// forwardedProto is the scheme the client used to reach the proxy
+func forwardedProto(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	proxyTracerName         = "github.com/hilthontt/visper/proxy"
+	defaultTraceServiceName = "visper-proxy"
+)
+
+// TracingConfig exports the proxy's spans to Jaeger like the API does. Without an endpoint
+// no spans are recorded, but incoming trace context is still passed on to the backends.
+type TracingConfig struct {
+	JaegerEndpoint string  `json:"jaeger_endpoint"`
+	ServiceName    string  `json:"service_name"`
+	SampleRatio    float64 `json:"sample_ratio"` // Of traces started here, 1 by default
+}
+
+// initTracing installs the W3C trace context propagator and, with an endpoint, a
+// tracer provider exporting to Jaeger
+func initTracing(config TracingConfig) (*sdktrace.TracerProvider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if config.JaegerEndpoint == "" {
+		return nil, nil
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = defaultTraceServiceName
+	}
+	ratio := config.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+
+	exp, err := jaeger.New(
+		jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(config.JaegerEndpoint)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			attribute.String("go.version", runtime.Version()),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(proxyTracerName)
+}
+
+// TracingMiddleware continues the client's trace, or starts one, with a server span
+// covering the request's whole time in the proxy
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := tracer().Start(ctx, "proxy "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(getClientIP(r)),
+			),
+		)
+		defer span.End()
+
+		tw := &accessResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+
+		endSpan(span, tw.status)
+	})
+}
+
+// startForwardSpan starts the client span for forwarding r to backend, the backend
+// continues the trace from it
+func startForwardSpan(r *http.Request, backend *Backend, strategy string) (*http.Request, trace.Span) {
+	ctx, span := tracer().Start(r.Context(), "forward "+backend.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.ServerAddress(backend.URL.Host),
+			attribute.String("proxy.backend", backend.URL.String()),
+			attribute.String("proxy.strategy", strategy),
+		),
+	)
+	return r.WithContext(ctx), span
+}
+
+// endSpan records the response status, marking server errors as failed
+func endSpan(span trace.Span, status int) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
+
+// injectTrace writes the trace context of req's span into its headers
+func injectTrace(req *http.Request) {
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// observeWithTrace observes v with the sampled trace in ctx as its exemplar, so a slow
+// bucket on a dashboard links to a trace
+func observeWithTrace(obs prometheus.Observer, ctx context.Context, v float64) {
+	sc := trace.SpanContextFromContext(ctx)
+	if exemplars, ok := obs.(prometheus.ExemplarObserver); ok && sc.IsSampled() {
+		exemplars.ObserveWithExemplar(v, prometheus.Labels{"trace_id": sc.TraceID().String()})
+		return
+	}
+	obs.Observe(v)
+}
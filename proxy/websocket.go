@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	tunnelDialTimeout   = 10 * time.Second
+	drainPollInterval   = time.Second
+	defaultDrainTimeout = 30 * time.Second
+)
+
+// tunnel is an upgraded connection copied between a client and a backend
+type tunnel struct {
+	client   net.Conn
+	upstream net.Conn
+}
+
+func (t *tunnel) close() {
+	t.client.Close()
+	t.upstream.Close()
+}
+
+// addTunnel tracks an open tunnel to the backend
+func (b *Backend) addTunnel(t *tunnel) {
+	b.mux.Lock()
+	if b.tunnels == nil {
+		b.tunnels = make(map[*tunnel]struct{})
+	}
+	b.tunnels[t] = struct{}{}
+	b.mux.Unlock()
+}
+
+func (b *Backend) removeTunnel(t *tunnel) {
+	b.mux.Lock()
+	delete(b.tunnels, t)
+	b.mux.Unlock()
+}
+
+// TunnelCount returns the number of open WebSocket tunnels to the backend
+func (b *Backend) TunnelCount() int {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return len(b.tunnels)
+}
+
+// drain gives the backend's tunnels until timeout to close on their own, then closes
+// what is left. The backend must no longer be selectable.
+func (b *Backend) drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if b.TunnelCount() == 0 {
+			return
+		}
+		time.Sleep(drainPollInterval)
+	}
+
+	b.mux.Lock()
+	tunnels := make([]*tunnel, 0, len(b.tunnels))
+	for t := range b.tunnels {
+		tunnels = append(tunnels, t)
+	}
+	b.mux.Unlock()
+
+	if len(tunnels) > 0 {
+		log.Printf("Closing %d WebSocket tunnels to %s after draining", len(tunnels), b.URL.Host)
+	}
+	for _, t := range tunnels {
+		t.close()
+	}
+}
+
+// proxyWebSocket forwards an upgrade request to the backend and, once the backend switches
+// protocols, hijacks the client connection and copies both ways until either side closes.
+// It returns the status code the backend answered with.
+func (lb *LoadBalancer) proxyWebSocket(w http.ResponseWriter, r *http.Request, backend *Backend) int {
+	backendLabel := backend.URL.Host
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket upgrade not supported", http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+
+	upstream, err := dialBackend(r, backend)
+	if err != nil {
+		log.Printf("Failed to reach %s for WebSocket upgrade: %v", backendLabel, err)
+		backend.IncreaseFailCount()
+		lb.metrics.backendErrors.WithLabelValues(backendLabel, "websocket_dial").Inc()
+		http.Error(w, "Backend unavailable", http.StatusBadGateway)
+		return http.StatusBadGateway
+	}
+
+	outReq := r.Clone(r.Context())
+	outReq.URL.Path = singleJoiningSlash(backend.URL.Path, r.URL.Path)
+	outReq.Host = backend.URL.Host
+	setClientHeaders(outReq, r)
+	outReq.Header.Set("X-Forwarded-Proto", forwardedProto(r))
+	injectTrace(outReq)
+
+	_ = upstream.SetDeadline(time.Now().Add(tunnelDialTimeout))
+	if err := outReq.Write(upstream); err != nil {
+		upstream.Close()
+		lb.metrics.backendErrors.WithLabelValues(backendLabel, "websocket_upgrade").Inc()
+		http.Error(w, "Backend unavailable", http.StatusBadGateway)
+		return http.StatusBadGateway
+	}
+
+	upstreamReader := bufio.NewReader(upstream)
+	resp, err := http.ReadResponse(upstreamReader, outReq)
+	if err != nil {
+		upstream.Close()
+		lb.metrics.backendErrors.WithLabelValues(backendLabel, "websocket_upgrade").Inc()
+		http.Error(w, "Backend unavailable", http.StatusBadGateway)
+		return http.StatusBadGateway
+	}
+	_ = upstream.SetDeadline(time.Time{})
+
+	// The backend refused the upgrade, pass its answer on like any other response
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer upstream.Close()
+		defer resp.Body.Close()
+
+		for name, values := range resp.Header {
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return resp.StatusCode
+	}
+
+	client, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		http.Error(w, "WebSocket upgrade not supported", http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+
+	// Headers set on w, such as the sticky cookie, were never written, carry them over
+	for name, values := range w.Header() {
+		for _, value := range values {
+			resp.Header.Add(name, value)
+		}
+	}
+	handshake := bufio.NewWriter(client)
+	fmt.Fprintf(handshake, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(handshake)
+	handshake.WriteString("\r\n")
+	if err := handshake.Flush(); err != nil {
+		client.Close()
+		upstream.Close()
+		return http.StatusSwitchingProtocols
+	}
+
+	t := &tunnel{client: client, upstream: upstream}
+	backend.addTunnel(t)
+	lb.metrics.websocketTunnels.WithLabelValues(backendLabel).Inc()
+	defer func() {
+		t.close()
+		backend.removeTunnel(t)
+		lb.metrics.websocketTunnels.WithLabelValues(backendLabel).Dec()
+	}()
+
+	// Either side closing ends the tunnel, closing both unblocks the other copy
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, clientBuf.Reader)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstreamReader)
+		done <- struct{}{}
+	}()
+
+	<-done
+	t.close()
+	<-done
+
+	return http.StatusSwitchingProtocols
+}
+
+// dialBackend opens a raw connection to the backend for the tunnel of r
+func dialBackend(r *http.Request, backend *Backend) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: tunnelDialTimeout, KeepAlive: 30 * time.Second}
+
+	ctx := r.Context()
+	backend.mux.RLock()
+	if backend.proxyProtocol != "" {
+		ctx = withProxyHeader(ctx, proxyHeaderFor(r, backend.proxyProtocol))
+	}
+	backend.mux.RUnlock()
+
+	conn, err := proxyProtocolDialer(dialer)(ctx, "tcp", backendAddr(backend.URL))
+	if err != nil || backend.URL.Scheme != "https" {
+		return conn, err
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: backend.URL.Hostname()})
+	handshakeCtx, cancel := context.WithTimeout(ctx, tunnelDialTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -4,6 +4,7 @@ from fastapi import Depends, Request
 from redis import Redis
 
 from ..core.utils.cache import async_get_redis
+from ..core.utils.member_token import verify_member_token
 from ..schemas.rate_limit import sanitize_path
 from ..core.logger import logging
 from ..core.config import settings
@@ -15,7 +16,8 @@ DEFAULT_LIMIT = settings.DEFAULT_RATE_LIMIT_LIMIT
 DEFAULT_PERIOD = settings.DEFAULT_RATE_LIMIT_PERIOD
 
 USER_CONTEXT_KEY = "user"
-USER_ID_HEADER_KEY = "X-User-ID"
+MEMBER_TOKEN_HEADER_KEY = "X-Member-Token"
+MEMBER_TOKEN_COOKIE_KEY = "visper_user_id"
 
 async def get_user_from_redis(redis: Redis, user_id: str) -> dict[str, Any] | None:
     """Get existing user from Redis (created by GO API)."""
@@ -32,14 +34,15 @@ async def get_user_from_redis(redis: Redis, user_id: str) -> dict[str, Any] | No
     return json.loads(user_data)
 
 def get_user_id_from_request(request: Request) -> str | None:
-    """Extract user ID from header or cookie."""
-    if user_id := request.headers.get("X-User-ID"):
-        return user_id
-    
-    if user_id := request.cookies.get("user_id"):
-        return user_id
-    
-    return None
+    """Extract the user ID from the member token in the header or cookie.
+
+    Only the Go API's signed tokens are trusted, a claimed user ID could name anyone.
+    """
+    token = request.headers.get(MEMBER_TOKEN_HEADER_KEY) or request.cookies.get(MEMBER_TOKEN_COOKIE_KEY)
+    if not token:
+        return None
+
+    return verify_member_token(token)
 
 async def get_current_user(
     request: Request,
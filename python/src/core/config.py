@@ -114,6 +114,10 @@ class AIEnhancementSettings(BaseSettings):
     # Fallback settings
     ENABLE_RULE_BASED_FALLBACK: bool = True
     
+class MemberTokenSettings(BaseSettings):
+    # The Go API's "<id>:<secret>" keys, comma separated like its MEMBER_TOKEN_KEYS
+    MEMBER_TOKEN_KEYS: str = ""
+
 class Settings(
     AppSettings,
     RedisCacheSettings,
@@ -127,6 +131,7 @@ class Settings(
     ConsoleLoggerSettings,
     OllamaSettings,
     AIEnhancementSettings,
+    MemberTokenSettings,
 ):
     model_config = SettingsConfigDict(
         env_file=os.path.join(os.path.dirname(os.path.realpath(__file__)), "..", "..", ".env"),
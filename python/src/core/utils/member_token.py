@@ -0,0 +1,61 @@
+import base64
+import binascii
+import hashlib
+import hmac
+import json
+import time
+
+from ..config import settings
+
+# Member tokens are issued by the Go API as "v1.<key ID>.<claims>.<signature>", the claims
+# base64url JSON and the signature the base64url HMAC-SHA256 of everything before it
+MEMBER_TOKEN_VERSION = "v1"
+
+
+def _member_token_keys() -> dict[str, bytes]:
+    keys: dict[str, bytes] = {}
+    for key in settings.MEMBER_TOKEN_KEYS.split(","):
+        key_id, _, secret = key.strip().partition(":")
+        if key_id and secret:
+            keys[key_id] = secret.encode()
+    return keys
+
+
+def _b64decode(value: str) -> bytes:
+    return base64.urlsafe_b64decode(value + "=" * (-len(value) % 4))
+
+
+def verify_member_token(token: str) -> str | None:
+    """Return the user ID a valid, unexpired member token was issued to, None otherwise.
+
+    Room scoped tokens are only good in their room on the Go API, so they are refused here.
+    """
+    parts = token.split(".")
+    if len(parts) != 4 or parts[0] != MEMBER_TOKEN_VERSION:
+        return None
+
+    secret = _member_token_keys().get(parts[1])
+    if secret is None:
+        return None
+
+    try:
+        signature = _b64decode(parts[3])
+        payload = _b64decode(parts[2])
+    except (binascii.Error, ValueError):
+        return None
+
+    expected = hmac.new(secret, ".".join(parts[:3]).encode(), hashlib.sha256).digest()
+    if not hmac.compare_digest(signature, expected):
+        return None
+
+    try:
+        claims = json.loads(payload)
+    except ValueError:
+        return None
+
+    if not isinstance(claims, dict) or not claims.get("uid") or claims.get("rid"):
+        return None
+    if time.time() >= claims.get("exp", 0):
+        return None
+
+    return claims["uid"]